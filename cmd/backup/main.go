@@ -0,0 +1,130 @@
+// Command backup snapshots the data directory (index, metadata stores and
+// image files) into dated, hash-verified backup sets and restores them.
+//
+//	go run ./cmd/backup backup               # full backup
+//	go run ./cmd/backup backup -incremental  # only files changed since the last set
+//	go run ./cmd/backup list
+//	go run ./cmd/backup verify -set 20250314-020000.000
+//	go run ./cmd/backup restore -set 20250314-020000.000 -to ./data-restored
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+func main() {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+
+	// Same .env as the server, but no Gemini key is needed here
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	dataDir := flags.String("data", envOr("DATA_DIR", "./data"), "data directory to back up")
+	backupDir := flags.String("backups", envOr("BACKUP_DIR", "./backups"), "directory holding the backup sets")
+	incremental := flags.Bool("incremental", false, "backup: only copy files changed since the latest set")
+	set := flags.String("set", "", "verify/restore: backup set ID (default: latest)")
+	target := flags.String("to", "", "restore: directory to restore into (must be empty unless -overwrite)")
+	overwrite := flags.Bool("overwrite", false, "restore: write into a non-empty directory")
+	flags.Parse(args)
+
+	backups := service.NewBackupService(*dataDir, *backupDir, logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch command {
+	case "backup":
+		manifest, err := backups.Backup(ctx, *incremental)
+		if err != nil {
+			logger.Fatalf("Backup failed: %v", err)
+		}
+		fmt.Println(manifest.ID)
+
+	case "list":
+		ids, err := backups.List()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		for _, id := range ids {
+			manifest, err := backups.Manifest(id)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			kind := "full"
+			if manifest.Base != "" {
+				kind = "incremental on " + manifest.Base
+			}
+			fmt.Printf("%s  %6d files  %6d copied  %9.1f MB  %s\n",
+				id, len(manifest.Files), manifest.Copied, float64(manifest.Bytes)/(1024*1024), kind)
+		}
+
+	case "verify":
+		id := latestOr(backups, *set, logger)
+		failed, err := backups.Verify(ctx, id)
+		if err != nil {
+			logger.Fatalf("Verify failed: %v", err)
+		}
+		for _, path := range failed {
+			fmt.Println("MISMATCH", path)
+		}
+		if len(failed) > 0 {
+			logger.Fatalf("Backup %s: %d files failed verification", id, len(failed))
+		}
+		logger.Infof("Backup %s verified", id)
+
+	case "restore":
+		if *target == "" {
+			logger.Fatal("-to is required")
+		}
+		id := latestOr(backups, *set, logger)
+		if err := backups.Restore(ctx, id, *target, *overwrite); err != nil {
+			logger.Fatalf("Restore failed: %v", err)
+		}
+
+	default:
+		usage()
+	}
+}
+
+// latestOr returns id, or the latest backup set if id is empty
+func latestOr(backups *service.BackupService, id string, logger *logrus.Logger) string {
+	if id != "" {
+		return id
+	}
+	ids, err := backups.List()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if len(ids) == 0 {
+		logger.Fatal("No backup sets found")
+	}
+	return ids[len(ids)-1]
+}
+
+func envOr(key, defaultVal string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultVal
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: backup <backup|list|verify|restore> [flags]  (backup <command> -h for flags)")
+	os.Exit(2)
+}
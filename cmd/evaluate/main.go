@@ -0,0 +1,111 @@
+// Command evaluate compares two AI models or prompt versions over a labeled
+// sample set and writes an agreement/quality report.
+//
+//	go run ./cmd/evaluate -samples eval/samples.json -b-model gemini-3-pro-preview
+//	go run ./cmd/evaluate -samples eval/samples.json -b-prompt eval/prompt_v2.txt
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/config"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+func main() {
+	samplesPath := flag.String("samples", "", "JSON array of labeled samples: [{\"image\", \"category\", \"tags\"}]")
+	modelA := flag.String("a-model", "", "model for variant A (default: GEMINI_MODEL)")
+	modelB := flag.String("b-model", "", "model for variant B (default: GEMINI_MODEL)")
+	promptA := flag.String("a-prompt", "", "file with the 2D analysis prompt for variant A (default: built-in)")
+	promptB := flag.String("b-prompt", "", "file with the 2D analysis prompt for variant B (default: built-in)")
+	outPath := flag.String("out", "", "where to write the full report (default: eval-<timestamp>.json)")
+	flag.Parse()
+
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+
+	if *samplesPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatalf("Failed to load config: %v", err)
+	}
+
+	samples, err := service.LoadEvalSamples(*samplesPath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	categoryPrompts, err := service.LoadCategoryPrompts(cfg.CategoryPromptsFile)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	var variants []service.EvalVariant
+	for _, v := range []struct{ name, model, prompt string }{
+		{"A", *modelA, *promptA},
+		{"B", *modelB, *promptB},
+	} {
+		model := v.model
+		if model == "" {
+			model = cfg.GeminiModel
+		}
+		ai, err := service.NewAIService(cfg.GeminiAPIKey, model)
+		if err != nil {
+			logger.Fatalf("Failed to initialize variant %s: %v", v.name, err)
+		}
+		defer ai.Close()
+		ai.SetCategoryPrompts(categoryPrompts)
+
+		label := model
+		if v.prompt != "" {
+			prompt, err := os.ReadFile(v.prompt)
+			if err != nil {
+				logger.Fatalf("Failed to read prompt for variant %s: %v", v.name, err)
+			}
+			ai.SetPrompt2D(string(prompt))
+			label += " + " + filepath.Base(v.prompt)
+		}
+		logger.Infof("Variant %s: %s", v.name, label)
+		variants = append(variants, service.EvalVariant{Name: v.name, Analyzer: ai})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := service.Evaluate(ctx, samples, variants, logger)
+	if err != nil {
+		logger.Fatalf("Evaluation failed: %v", err)
+	}
+
+	if *outPath == "" {
+		*outPath = fmt.Sprintf("eval-%s.json", report.StartedAt.Format("20060102-150405"))
+	}
+	data, _ := json.MarshalIndent(report, "", "  ")
+	if err := os.WriteFile(*outPath, data, 0644); err != nil {
+		logger.Fatalf("Failed to write report: %v", err)
+	}
+
+	fmt.Printf("\n%d samples (%s)\n\n", report.Samples, time.Since(report.StartedAt).Round(time.Second))
+	fmt.Printf("%-8s %9s %7s %10s %12s %12s\n", "variant", "analyzed", "failed", "category", "tag overlap", "latency")
+	for _, score := range report.Variants {
+		fmt.Printf("%-8s %9d %7d %9.1f%% %11.1f%% %10dms\n",
+			score.Name, score.Analyzed, score.Failed, score.CategoryAccuracy*100, score.TagOverlap*100, score.MeanLatencyMs)
+	}
+	if a := report.Agreement; a != nil {
+		fmt.Printf("\nA/B agreement over %d samples: category %.1f%%, tag overlap %.1f%%\n", a.Compared, a.Category*100, a.TagOverlap*100)
+	}
+	fmt.Printf("\nFull report with both analyses: %s\n", *outPath)
+}
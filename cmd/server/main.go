@@ -2,19 +2,30 @@ package main
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/yourcompany/image-warehousing/internal/api"
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
 	"github.com/yourcompany/image-warehousing/internal/config"
+	"github.com/yourcompany/image-warehousing/internal/models"
 	"github.com/yourcompany/image-warehousing/internal/service"
 )
 
 func main() {
+	// `server mcp` exposes a running server to AI agents over stdio instead
+	if len(os.Args) > 1 && os.Args[1] == "mcp" {
+		runMCP(os.Args[2:])
+		return
+	}
+
 	// Initialize logger
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.TextFormatter{
@@ -30,23 +41,125 @@ func main() {
 		logger.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Application log format, levels and file
+	logging, err := service.ConfigureLogging(logger, service.LogOptions{
+		Level:           cfg.LogLevel,
+		Format:          cfg.LogFormat,
+		ComponentLevels: cfg.LogLevels,
+		File:            cfg.LogFile,
+		MaxSize:         cfg.LogFileMaxSize,
+		MaxFiles:        cfg.LogFileMaxFiles,
+	})
+	if err != nil {
+		logger.Fatalf("Invalid log settings: %v", err)
+	}
+
 	logger.Info("Configuration loaded successfully")
 
+	// Listen right away so orchestrators see the server alive: /health
+	// answers while it initializes, /readyz only once it is done
+	gate := api.NewStartupGate()
+	var handler http.Handler = gate
+
+	// Access log, apart from the application log on stderr
+	var accessLogFile *service.RotatingFile
+	if cfg.AccessLog != "" {
+		var out io.Writer = os.Stdout
+		if cfg.AccessLog != "stdout" {
+			accessLogFile, err = service.OpenRotatingFile(cfg.AccessLog, cfg.AccessLogMaxSize, cfg.AccessLogMaxFiles)
+			if err != nil {
+				logger.Fatalf("Failed to open access log: %v", err)
+			}
+			out = accessLogFile
+		}
+		accessLog, err := middleware.AccessLog(out, cfg.AccessLogFormat)
+		if err != nil {
+			logger.Fatalf("Invalid ACCESS_LOG_FORMAT: %v", err)
+		}
+		handler = accessLog(handler)
+		logger.Infof("Writing %s access log to %s", cfg.AccessLogFormat, cfg.AccessLog)
+	}
+
+	srv := &http.Server{
+		Addr:         ":" + cfg.ServerPort,
+		Handler:      handler,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+	go func() {
+		logger.Infof("Server listening on port %s", cfg.ServerPort)
+		logger.Infof("API base URL: http://localhost:%s/api/v1", cfg.ServerPort)
+
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	// Data directory layout, upgraded by any pending migrations
+	gate.SetStage("migrating data directory")
+	bootstrap := service.NewBootstrap(cfg.DataDir)
+	applied, err := bootstrap.Migrate()
+	if err != nil {
+		logger.Fatalf("Failed to migrate data directory: %v", err)
+	}
+	for _, name := range applied {
+		logger.Infof("Applied data migration: %s", name)
+	}
+
 	// Initialize services
+	gate.SetStage("initializing services")
 	logger.Info("Initializing services...")
 
 	// Storage service
-	storageService := service.NewStorageService(cfg.DataDir)
+	storageService := service.NewStorageServiceWithTempDir(cfg.DataDir, cfg.TempDir)
+	storageService.SetNormalizeSRGB(cfg.NormalizeSRGB)
+	if err := storageService.SetLayout(cfg.StorageLayout); err != nil {
+		logger.Fatalf("Invalid STORAGE_LAYOUT: %v", err)
+	}
+	if cfg.ThumbnailCacheSize > 0 {
+		storageService.SetThumbnailCache(service.NewThumbnailCache(cfg.ThumbnailCacheSize))
+	}
 	if err := storageService.Initialize(); err != nil {
 		logger.Fatalf("Failed to initialize storage service: %v", err)
 	}
-	logger.Info("Storage service initialized")
+	logger.Infof("Storage service initialized (layout: %s)", storageService.Layout())
+
+	// Locks shared with other instances using the same data directory
+	coordinator, err := service.NewCoordinator(cfg.CoordinationURL, cfg.DataDir, cfg.InstanceID, cfg.LockTTL)
+	if err != nil {
+		logger.Fatalf("Invalid COORDINATION_URL: %v", err)
+	}
 
-	// Index service
+	// Index service, journaling every mutation
 	indexService := service.NewIndexService(cfg.DataDir)
+	if coordinator != nil {
+		indexService.SetCoordinator(coordinator)
+		logger.Infof("Coordinating with other instances as %s", coordinator.Owner())
+	}
+	if cfg.IndexSharding {
+		indexService.EnableSharding()
+	}
 	if err := indexService.InitializeIndex(); err != nil {
 		logger.Fatalf("Failed to initialize index: %v", err)
 	}
+	journal := service.NewIndexJournal(filepath.Join(cfg.DataDir, "journal.jsonl"))
+	if err := journal.Load(); err != nil {
+		logger.Fatalf("Failed to load index journal: %v", err)
+	}
+	indexService.SetJournal(journal)
+	if recovered, err := indexService.RecoverJournal(); err != nil {
+		logger.Fatalf("Failed to recover index from journal: %v", err)
+	} else if recovered {
+		logger.Warn("Applied the last journaled index change, which was interrupted")
+	}
+	if from, err := indexService.MigrateIndex(); err != nil {
+		logger.Fatalf("Failed to migrate index: %v", err)
+	} else if from != service.IndexSchemaVersion {
+		for _, migration := range service.IndexMigrations(from) {
+			logger.Infof("Migrated index: %s", migration)
+		}
+	}
 	logger.Info("Index service initialized")
 
 	// AI service
@@ -55,38 +168,253 @@ func main() {
 		logger.Fatalf("Failed to initialize AI service: %v", err)
 	}
 	defer aiService.Close()
+	aiService.SetEmbeddingModel(cfg.EmbeddingModel)
+	aiService.SetMultimodalEmbeddingModel(cfg.ImageEmbeddingModel)
+	aiService.SetInlineLimit(cfg.GeminiInlineLimit)
+	categoryPrompts, err := service.LoadCategoryPrompts(cfg.CategoryPromptsFile)
+	if err != nil {
+		logger.Fatalf("Failed to load category prompts: %v", err)
+	}
+	aiService.SetCategoryPrompts(categoryPrompts)
+	aiService.SetAesthetics(cfg.AestheticScoring)
+	aiService.SetCategoryDepth(cfg.CategoryDepth)
 	logger.Infof("AI service initialized (model: %s)", cfg.GeminiModel)
 
+	// Status store (bounded, TTL-evicting, persisted across restarts). Each
+	// coordinated instance keeps its own snapshot of the jobs it runs.
+	statusPath := filepath.Join(cfg.DataDir, "status.json")
+	if coordinator != nil {
+		statusPath = filepath.Join(cfg.DataDir, "status", url.PathEscape(coordinator.Owner())+".json")
+		if err := os.MkdirAll(filepath.Dir(statusPath), 0755); err != nil {
+			logger.Fatalf("Failed to create status directory: %v", err)
+		}
+	}
+	statusStore := service.NewStatusStore(statusPath, cfg.StatusTTL, cfg.StatusMaxEntries)
+	if err := statusStore.Load(); err != nil {
+		logger.Warnf("Failed to load status snapshot: %v", err)
+	}
+	statusCtx, stopStatus := context.WithCancel(context.Background())
+	defer stopStatus()
+	go statusStore.Run(statusCtx, time.Minute)
+
+	// Analysis cache (identical content, model and prompt skip the AI call)
+	analysisCache := service.NewAnalysisCache(filepath.Join(cfg.DataDir, "analysis_cache.json"), cfg.AnalysisCacheSize)
+	if cfg.AnalysisCache {
+		if err := analysisCache.Load(); err != nil {
+			logger.Warnf("Failed to load analysis cache: %v", err)
+		}
+		go analysisCache.Run(statusCtx, time.Minute)
+		aiService.SetAnalysisCache(analysisCache)
+	}
+
 	// Image service (with workers)
-	imageService := service.NewImageService(storageService, aiService, indexService, logger)
-	imageService.StartWorkers(3) // Start 3 worker goroutines
+	imageService := service.NewImageService(storageService, aiService, indexService, statusStore, logging.For(service.LogComponentImages))
+	imageService.SetAnalysisStore(service.NewAnalysisStore(filepath.Join(cfg.DataDir, "analyses")))
+	imageService.SetHistory(service.NewImageHistory(filepath.Join(cfg.DataDir, "history.jsonl")))
+	imageService.SetStageTimeouts(service.StageTimeouts{
+		Thumbnail: cfg.ThumbnailTimeout,
+		Analysis:  cfg.AnalysisTimeout,
+	})
+	imageService.SetFrameExtractor(service.NewFFmpegFrameExtractor(cfg.FFmpegPath, cfg.FFprobePath))
+	imageService.SetCoordinator(coordinator)
+
+	// CDN in front of /data/: links point at it, changed files are purged
+	service.SetCDNBaseURL(cfg.CDNBaseURL)
+	if cfg.CDNPurgeURL != "" {
+		imageService.SetCDNPurger(service.NewCDNPurger(cfg.CDNPurgeURL, logging.For(service.LogComponentWebhooks)))
+	}
+
+	// Category sprite sheets, extended as images are indexed
+	spriteService := service.NewSpriteService(indexService, storageService, cfg.DataDir, logging.For(service.LogComponentRenditions))
+	imageService.OnIndexed(spriteService.HandleIndexed)
+	imageService.OnUpdated(spriteService.HandleUpdated)
+
+	// Background removal renditions
+	var remover service.BackgroundRemover = service.NewBorderKeyRemover()
+	if cfg.CutoutServiceURL != "" {
+		remover = service.NewHTTPBackgroundRemover(cfg.CutoutServiceURL, cfg.CutoutTimeout)
+	}
+	cutoutService := service.NewCutoutService(indexService, storageService, remover, cfg.CutoutIngestCategories, logging.For(service.LogComponentRenditions))
+	imageService.OnIndexed(cutoutService.HandleIndexed)
+
+	// Upscaled renditions
+	var upscaler service.Upscaler = service.ResampleUpscaler{}
+	if cfg.UpscalerURL != "" {
+		upscaler = service.NewHTTPUpscaler(cfg.UpscalerURL, cfg.UpscaleTimeout)
+	}
+	upscaleService := service.NewUpscaleService(imageService, storageService, upscaler, float64(cfg.UpscaleMaxMegapixels), logging.For(service.LogComponentRenditions))
+	renditionService := service.NewRenditionService(storageService, imageService, cutoutService, upscaleService, logging.For(service.LogComponentRenditions))
+
+	// XMP sidecars for Lightroom and Bridge, kept current when enabled
+	xmpService := service.NewXMPService(indexService, imageService, storageService, logging.For(service.LogComponentRenditions))
+	if cfg.XMPSidecars {
+		imageService.OnIndexed(xmpService.HandleIndexed)
+		imageService.OnUpdated(xmpService.HandleUpdated)
+	}
+
+	// Workers are shared by 2D and 3D jobs; capping 3D below the worker
+	// count keeps a burst of 3D uploads from starving 2D ones
+	imageService.SetTypeConcurrency(models.ImageType2D, cfg.Max2DJobs)
+	imageService.SetTypeConcurrency(models.ImageType3D, cfg.Max3DJobs)
+	imageService.SetQueueCapacity(cfg.QueueSize)
+	imageService.StartWorkers(cfg.Workers)
+
+	// Embedding store and backfill
+	embeddingStore := service.NewEmbeddingStore(filepath.Join(cfg.DataDir, "embeddings.json"))
+	if err := embeddingStore.Load(); err != nil {
+		logger.Warnf("Failed to load embeddings: %v", err)
+	}
+	backfillService := service.NewBackfillService(indexService, aiService, embeddingStore, imageService,
+		filepath.Join(cfg.DataDir, "backfill.json"), cfg.EmbeddingRatePerMinute, logging.For(service.LogComponentSearch))
+	if cfg.ImageEmbeddingModel != "" {
+		backfillService.SetImageEmbeddings(storageService)
+	}
+	consolidateService := service.NewConsolidateService(storageService, indexService, imageService, logging.For(service.LogComponentMaintenance))
 
 	// Search service
-	searchService := service.NewSearchService(indexService, aiService, logger)
+	searchService := service.NewSearchService(indexService, aiService, logging.For(service.LogComponentSearch))
+	searchService.SetChunking(cfg.SearchChunkSize, cfg.SearchConcurrency, cfg.SearchRatePerMinute)
+	weights := models.RankingWeights{
+		Relevance:   cfg.SearchWeightRelevance,
+		Title:       cfg.SearchWeightTitle,
+		Tags:        cfg.SearchWeightTags,
+		Description: cfg.SearchWeightDescription,
+		Embedding:   cfg.SearchWeightEmbedding,
+		Recency:     cfg.SearchWeightRecency,
+	}
+	if err := weights.Validate(); err != nil {
+		logger.Fatalf("Invalid SEARCH_WEIGHT_*: %v", err)
+	}
+	searchService.SetRanking(weights, embeddingStore, aiService)
+	searchSessions := service.NewSearchSessionStore(cfg.SearchSessionTTL)
+	go searchSessions.Run(statusCtx, 5*time.Minute)
+	searchService.SetSessions(searchSessions)
+	searchService.SetSearchLog(service.NewSearchLog(filepath.Join(cfg.DataDir, "searches.jsonl")))
 	logger.Info("Search service initialized")
 
-	// Create router
-	router := api.NewRouter(cfg, storageService, imageService, indexService, searchService, logger)
+	// Idempotency keys for upload retries
+	idempotencyStore := service.NewIdempotencyStore(cfg.IdempotencyTTL)
+	go idempotencyStore.Run(statusCtx, time.Hour)
 
-	// Create HTTP server
-	srv := &http.Server{
-		Addr:         ":" + cfg.ServerPort,
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	// Progress of uploads that name a session; idle sessions expire after an hour
+	uploadSessions := service.NewUploadSessionStore(time.Hour)
+	go uploadSessions.Run(statusCtx, 10*time.Minute)
+
+	// Digest reports
+	reportService := service.NewReportService(indexService, cfg.ReportWebhookURL, cfg.PublicBaseURL, logging.For(service.LogComponentMaintenance))
+	reportService.SetStatePath(filepath.Join(cfg.DataDir, "report_schedule.json"))
+
+	// Bulk metadata edits
+	bulkService := service.NewBulkUpdateService(indexService, imageService, searchService, logging.For(service.LogComponentMaintenance))
+
+	// Review annotations
+	annotationStore := service.NewAnnotationStore(filepath.Join(cfg.DataDir, "annotations.json"))
+	annotationStore.SetCoordinator(coordinator)
+	if err := annotationStore.Load(); err != nil {
+		logger.Warnf("Failed to load annotations: %v", err)
 	}
 
-	// Start server in a goroutine
-	go func() {
-		logger.Infof("Server listening on port %s", cfg.ServerPort)
-		logger.Infof("API base URL: http://localhost:%s/api/v1", cfg.ServerPort)
-		logger.Info("Ready to accept requests!")
+	// View and download counts, saved every minute
+	popularityStore := service.NewPopularityStore(filepath.Join(cfg.DataDir, "popularity.json"))
+	popularityStore.SetCoordinator(coordinator)
+	if err := popularityStore.Load(); err != nil {
+		logger.Warnf("Failed to load popularity counts: %v", err)
+	}
+	go popularityStore.Run(statusCtx, time.Minute)
 
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Fatalf("Server error: %v", err)
+	// Per-user favorites
+	favoriteStore := service.NewFavoriteStore(filepath.Join(cfg.DataDir, "favorites.json"))
+	favoriteStore.SetCoordinator(coordinator)
+	if err := favoriteStore.Load(); err != nil {
+		logger.Warnf("Failed to load favorites: %v", err)
+	}
+
+	// Projects
+	projectStore := service.NewProjectStore(filepath.Join(cfg.DataDir, "projects.json"))
+	projectStore.SetCoordinator(coordinator)
+	if err := projectStore.Load(); err != nil {
+		logger.Warnf("Failed to load projects: %v", err)
+	}
+
+	// Read-only guest tokens for embedded galleries
+	guestTokens := service.NewGuestTokenStore(filepath.Join(cfg.DataDir, "guest_tokens.json"))
+	guestTokens.SetCoordinator(coordinator)
+	if err := guestTokens.Load(); err != nil {
+		logger.Warnf("Failed to load guest tokens: %v", err)
+	}
+
+	// Bulk delete by filter (dry run, then confirm)
+	bulkDeleteService := service.NewBulkDeleteService(indexService, imageService, searchService, popularityStore, favoriteStore, logging.For(service.LogComponentMaintenance))
+
+	// Retention rules by category and legal holds, recorded in the audit log
+	retentionPolicy, err := service.LoadRetentionPolicy(cfg.RetentionRulesFile, cfg.RetentionWarning)
+	if err != nil {
+		logger.Fatalf("Failed to load retention rules: %v", err)
+	}
+	if err := retentionPolicy.SetFlagFile(filepath.Join(cfg.DataDir, "retention_flags.json")); err != nil {
+		logger.Fatalf("Failed to load retention flags: %v", err)
+	}
+	imageService.SetRetentionPolicy(retentionPolicy)
+	vocabulary, err := service.LoadVocabulary(cfg.VocabularyFile)
+	if err != nil {
+		logger.Fatalf("Failed to load vocabulary: %v", err)
+	}
+	imageService.SetVocabulary(vocabulary)
+	auditLog := service.NewAuditLog(filepath.Join(cfg.DataDir, "audit.jsonl"))
+	imageService.SetLegalHolds(projectStore, auditLog)
+	retentionService := service.NewRetentionService(retentionPolicy, indexService, imageService, popularityStore, favoriteStore, auditLog, logging.For(service.LogComponentMaintenance))
+	erasureService := service.NewErasureService(storageService, indexService, imageService, annotationStore, embeddingStore, popularityStore, favoriteStore, auditLog, logging.For(service.LogComponentMaintenance))
+	erasureService.SetAnalysisCache(analysisCache)
+	erasureService.SetSprites(spriteService)
+
+	// Tasks that must not run on several instances at once: the digest,
+	// retention enforcement and resuming an interrupted backfill
+	go service.RunAsLeader(statusCtx, coordinator, "leader", func(ctx context.Context) {
+		if err := backfillService.ResumeIfInterrupted(ctx); err != nil {
+			logger.Warnf("Failed to resume embedding backfill: %v", err)
 		}
-	}()
+		go retentionService.Run(ctx, cfg.RetentionInterval)
+		reportService.Run(ctx, cfg.ReportInterval)
+		<-ctx.Done()
+	})
+
+	// Editorial workflow, announcing transitions on the event webhook
+	notifier := service.NewWebhookNotifier(cfg.WebhookURL, logging.For(service.LogComponentWebhooks))
+	workflowService := service.NewWorkflowService(indexService, imageService, notifier, logging.For(service.LogComponentWebhooks))
+
+	// API tokens and roles
+	tokens, err := middleware.ParseTokens(cfg.APITokens)
+	if err != nil {
+		logger.Fatalf("Invalid API_TOKENS: %v", err)
+	}
+	if len(tokens) == 0 {
+		logger.Warn("API_TOKENS not set: authentication is disabled")
+		if !cfg.AnonymousAccess {
+			logger.Warn("ANONYMOUS_ACCESS=false has no effect without API_TOKENS")
+		}
+	}
+
+	// Preview watermarking
+	watermarker, err := service.NewWatermarker(cfg.DataDir, cfg.WatermarkText, cfg.WatermarkLogo, cfg.WatermarkOpacity)
+	if err != nil {
+		logger.Fatalf("Invalid watermark settings: %v", err)
+	}
+
+	// Create router
+	router := api.NewRouter(cfg, storageService, imageService, indexService, searchService, idempotencyStore, uploadSessions, backfillService, consolidateService, retentionService, erasureService, auditLog, reportService, bulkService, bulkDeleteService, spriteService, cutoutService, upscaleService, renditionService, xmpService, annotationStore, projectStore, guestTokens, journal, popularityStore, favoriteStore, workflowService, watermarker, tokens, logging.For(service.LogComponentHTTP))
+
+	// Demo images for a fresh data directory
+	if cfg.SeedDir != "" {
+		gate.SetStage("seeding demo images")
+		if seeded, err := bootstrap.Seed(statusCtx, cfg.SeedDir, storageService, imageService, indexService); err != nil {
+			logger.Warnf("Failed to seed demo images (%d queued): %v", seeded, err)
+		} else if seeded > 0 {
+			logger.Infof("Queued %d demo images from %s", seeded, cfg.SeedDir)
+		}
+	}
+
+	gate.Ready(router)
+	logger.Info("Ready to accept requests!")
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
@@ -103,5 +431,20 @@ func main() {
 		logger.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	stopStatus()
+	if err := statusStore.Save(); err != nil {
+		logger.Errorf("Failed to save status snapshot: %v", err)
+	}
+	if err := popularityStore.Save(); err != nil {
+		logger.Errorf("Failed to save popularity counts: %v", err)
+	}
+	if err := analysisCache.Save(); err != nil {
+		logger.Errorf("Failed to save analysis cache: %v", err)
+	}
+	if accessLogFile != nil {
+		accessLogFile.Close()
+	}
+
 	logger.Info("Server stopped gracefully")
+	logging.Close()
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/mcp"
+)
+
+// runMCP serves the warehouse to AI agents over stdio (Model Context
+// Protocol). It talks to a running server's API, so start the server first:
+//
+//	server mcp -url http://localhost:8080 -token <editor token>
+func runMCP(args []string) {
+	// Logs go to stderr; stdout carries the protocol
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+	logger.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+
+	// Same .env as the server; no Gemini key is needed here
+	_ = godotenv.Load()
+
+	flags := flag.NewFlagSet("mcp", flag.ExitOnError)
+	baseURL := flags.String("url", envOr("MCP_WAREHOUSE_URL", "http://localhost:"+envOr("SERVER_PORT", "8080")), "warehouse server to expose")
+	token := flags.String("token", os.Getenv("MCP_API_TOKEN"), "API token to call the server with (editor role to upload)")
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := mcp.NewServer(mcp.NewTools(mcp.NewClient(*baseURL, *token)), "image-warehouse", logger)
+	logger.Infof("MCP server ready (warehouse: %s)", *baseURL)
+	if err := server.Serve(ctx, os.Stdin, os.Stdout); err != nil && err != context.Canceled {
+		logger.Fatalf("MCP server error: %v", err)
+	}
+}
+
+func envOr(key, defaultVal string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultVal
+}
@@ -259,6 +259,12 @@ function updateCategoryFilter(categories) {
     };
 }
 
+// Path of the view that represents a 3D object: its poster view, else the
+// front view or any view
+function posterViewPath(img) {
+    return img.views[img.poster_view] || img.views.front || Object.values(img.views)[0];
+}
+
 function displayImages(images) {
     const grid = document.getElementById('warehouseGrid');
 
@@ -271,10 +277,10 @@ function displayImages(images) {
     }
 
     grid.innerHTML = images.map(img => {
-        // For 3D objects, use the front view as thumbnail
+        // For 3D objects, use the poster view as thumbnail
         let thumbnailPath = img.thumbnail_path || img.file_path;
-        if (img.type === '3D' && img.views && img.views.front) {
-            thumbnailPath = img.views.front;
+        if (img.type === '3D' && img.views) {
+            thumbnailPath = posterViewPath(img) || thumbnailPath;
         }
 
         return `
@@ -322,6 +328,9 @@ async function showImageModal(imageId) {
             const modelExt = img.model_filename ? img.model_filename.split('.').pop().toLowerCase() : '';
             const supportsModelViewer = ['glb', 'gltf'].includes(modelExt);
             const supportsThreeJs = ['stl', 'obj', 'fbx'].includes(modelExt);
+            // iOS opens USDZ links marked rel="ar" in AR Quick Look
+            const supportsQuickLook = modelExt === 'usdz';
+            const previewView = posterViewPath(img);
 
             modalBody.innerHTML = `
                 <h2>${img.title || 'Untitled'} <span class="badge-3d">3D</span></h2>
@@ -349,12 +358,19 @@ async function showImageModal(imageId) {
                     </div>
                 ` : ''}
 
+                ${img.model_file_path && supportsQuickLook ? `
+                    <h3>View in AR:</h3>
+                    <a rel="ar" href="/data/${img.model_file_path}">
+                        <img src="/data/${previewView}" alt="${img.title || 'Untitled'}" style="max-width: 100%; max-height: 300px; border-radius: 8px;">
+                    </a>
+                ` : ''}
+
                 ${img.model_file_path ? `
                     <div class="download-section">
-                        <a href="/data/${img.model_file_path}" download="${img.model_filename || 'model'}" class="btn download-btn">
+                        <a href="/data/${img.model_file_path}?download=1" download="${img.model_filename || 'model'}" class="btn download-btn">
                             📥 Download 3D Model (${img.model_filename || 'model'})
                         </a>
-                        ${!supportsModelViewer && !supportsThreeJs ? '<p style="color: #999; margin-top: 10px; font-size: 0.9em;">Note: Interactive preview only available for .glb, .gltf, .stl, .obj, and .fbx files</p>' : ''}
+                        ${!supportsModelViewer && !supportsThreeJs && !supportsQuickLook ? '<p style="color: #999; margin-top: 10px; font-size: 0.9em;">Note: Interactive preview only available for .glb, .gltf, .stl, .obj, and .fbx files</p>' : ''}
                     </div>
                 ` : ''}
 
@@ -464,10 +480,10 @@ function displaySearchResults(data, query) {
             // Find image metadata
             const img = allImages.find(i => i.id === result.image_id) || {};
 
-            // Get thumbnail path - for 3D objects, use front view
+            // Get thumbnail path - for 3D objects, use the poster view
             let thumbnailPath = img.thumbnail_path || img.file_path;
-            if (img.type === '3D' && img.views && img.views.front) {
-                thumbnailPath = img.views.front;
+            if (img.type === '3D' && img.views) {
+                thumbnailPath = posterViewPath(img) || thumbnailPath;
             }
 
             return `
@@ -885,4 +901,10 @@ window.uploadAll = uploadAll;
 window.addEventListener('load', () => {
     loadWarehouse();
     init3DUpload();
+
+    // Detail links (/?image=<id>) open the image
+    const linkedImage = new URLSearchParams(window.location.search).get('image');
+    if (linkedImage) {
+        showImageModal(encodeURIComponent(linkedImage));
+    }
 });
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type AdminHandler struct {
+	backfillService    *service.BackfillService
+	consolidateService *service.ConsolidateService
+	retentionService   *service.RetentionService
+	auditLog           *service.AuditLog
+}
+
+func NewAdminHandler(backfill *service.BackfillService, consolidate *service.ConsolidateService, retention *service.RetentionService, audit *service.AuditLog) *AdminHandler {
+	return &AdminHandler{
+		backfillService:    backfill,
+		consolidateService: consolidate,
+		retentionService:   retention,
+		auditLog:           audit,
+	}
+}
+
+// HandleStartBackfill starts computing embeddings for images that lack one.
+// Progress is also visible through the jobs API.
+func (h *AdminHandler) HandleStartBackfill(w http.ResponseWriter, r *http.Request) {
+	// Detach from the request: the backfill outlives it
+	progress, err := h.backfillService.Start(context.Background())
+	if err != nil {
+		if errors.Is(err, service.ErrBackfillRunning) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(progress)
+			return
+		}
+		http.Error(w, "Failed to start backfill: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(progress)
+}
+
+// HandleBackfillStatus returns the current or last backfill progress
+func (h *AdminHandler) HandleBackfillStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.backfillService.Progress())
+}
+
+// HandleCancelBackfill stops a running backfill; it can be resumed later
+func (h *AdminHandler) HandleCancelBackfill(w http.ResponseWriter, r *http.Request) {
+	if !h.backfillService.Cancel() {
+		http.Error(w, "No backfill running", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// HandleStartConsolidation starts replacing byte-identical stored files with
+// hard links. With ?dry_run=true the duplicates are only reported. Progress
+// is also visible through the jobs API.
+func (h *AdminHandler) HandleStartConsolidation(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	report, err := h.consolidateService.Start(dryRun)
+	if err != nil {
+		if errors.Is(err, service.ErrConsolidationRunning) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(report)
+			return
+		}
+		http.Error(w, "Failed to start consolidation: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(report)
+}
+
+// HandleConsolidationReport returns the current or last consolidation report,
+// including the duplicate groups and the space reclaimed
+func (h *AdminHandler) HandleConsolidationReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.consolidateService.Report())
+}
+
+// HandleRetention returns the retention rules and the images they delete
+// within the warning period (or overdue, until the next enforcement)
+func (h *AdminHandler) HandleRetention(w http.ResponseWriter, r *http.Request) {
+	pending, err := h.retentionService.Pending(time.Now())
+	if err != nil {
+		http.Error(w, "Failed to check retention: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	policy := h.retentionService.Policy()
+	rules := policy.Rules()
+	if rules == nil {
+		rules = []service.RetentionRule{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules":          rules,
+		"warning_period": policy.Warning().String(),
+		"pending":        pending,
+	})
+}
+
+// HandleEnforceRetention deletes the images past their retention now,
+// instead of at the next scheduled run
+func (h *AdminHandler) HandleEnforceRetention(w http.ResponseWriter, r *http.Request) {
+	deleted, err := h.retentionService.Enforce(r.Context(), time.Now())
+	if err != nil {
+		http.Error(w, "Retention enforcement failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"deleted": deleted})
+}
+
+// HandleAuditLog returns audit entries, newest first. action=name keeps one
+// action (e.g. retention.delete); limit=N (default 100, max 1000).
+func (h *AdminHandler) HandleAuditLog(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit (1-1000)", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	entries, err := h.auditLog.Entries(r.URL.Query().Get("action"), limit)
+	if err != nil {
+		http.Error(w, "Failed to read audit log: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"entries": entries})
+}
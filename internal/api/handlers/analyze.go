@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type AnalyzeHandler struct {
+	storageService *service.StorageService
+	imageService   *service.ImageService
+	maxUploadSize  int64
+}
+
+func NewAnalyzeHandler(storage *service.StorageService, image *service.ImageService, maxSize int64) *AnalyzeHandler {
+	return &AnalyzeHandler{
+		storageService: storage,
+		imageService:   image,
+		maxUploadSize:  maxSize,
+	}
+}
+
+// HandleAnalyze runs the AI analysis on an uploaded image and returns the
+// proposed category, tags and description. Nothing is stored or indexed.
+func (h *AnalyzeHandler) HandleAnalyze(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(h.maxUploadSize); err != nil {
+		http.Error(w, "File too large or invalid form", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		http.Error(w, "No image provided", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// The model needs a file on disk; it is removed as soon as we're done
+	imageID, tempPath, err := h.storageService.SaveImageToTemp(file, header.Filename)
+	if err != nil {
+		http.Error(w, "Failed to save image", http.StatusInternalServerError)
+		return
+	}
+	defer h.storageService.CleanupTemp(&models.UploadJob{ImageID: imageID, Type: models.ImageType2D, FilePath: tempPath})
+
+	preview, err := h.imageService.PreviewAnalysis(r.Context(), tempPath)
+	if err != nil {
+		http.Error(w, "Failed to analyze image: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// HandleCategorize returns only the category and tags the warehouse would
+// file an uploaded image under, so other tools can reuse its taxonomy. An
+// optional comma-separated categories field limits the primary categories
+// that may be predicted. Nothing is stored or indexed.
+func (h *AnalyzeHandler) HandleCategorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(h.maxUploadSize); err != nil {
+		http.Error(w, "File too large or invalid form", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		http.Error(w, "No image provided", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	var allowed []string
+	for _, category := range strings.Split(r.FormValue("categories"), ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			allowed = append(allowed, category)
+		}
+	}
+
+	imageID, tempPath, err := h.storageService.SaveImageToTemp(file, header.Filename)
+	if err != nil {
+		http.Error(w, "Failed to save image", http.StatusInternalServerError)
+		return
+	}
+	defer h.storageService.CleanupTemp(&models.UploadJob{ImageID: imageID, Type: models.ImageType2D, FilePath: tempPath})
+
+	prediction, err := h.imageService.PredictCategory(r.Context(), tempPath, allowed)
+	if err != nil {
+		http.Error(w, "Failed to categorize image: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prediction)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type AnnotationsHandler struct {
+	indexService *service.IndexService
+	annotations  *service.AnnotationStore
+}
+
+func NewAnnotationsHandler(index *service.IndexService, annotations *service.AnnotationStore) *AnnotationsHandler {
+	return &AnnotationsHandler{
+		indexService: index,
+		annotations:  annotations,
+	}
+}
+
+// HandleListAnnotations returns the annotations of an image
+func (h *AnnotationsHandler) HandleListAnnotations(w http.ResponseWriter, r *http.Request) {
+	imageID := mux.Vars(r)["id"]
+	if _, err := h.indexService.GetImageByID(imageID); err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	annotations := h.annotations.List(imageID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"annotations": annotations,
+		"total":       len(annotations),
+	})
+}
+
+// HandleCreateAnnotation pins a comment to a rect or point of an image.
+// Coordinates are normalized (0..1); the caller is the author unless the
+// body names one.
+func (h *AnnotationsHandler) HandleCreateAnnotation(w http.ResponseWriter, r *http.Request) {
+	imageID := mux.Vars(r)["id"]
+	if _, err := h.indexService.GetImageByID(imageID); err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	var annotation models.Annotation
+	if err := json.NewDecoder(r.Body).Decode(&annotation); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	annotation.ImageID = imageID
+	if annotation.Author == "" {
+		annotation.Author = middleware.PrincipalFrom(r.Context()).Name
+	}
+
+	if err := annotation.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.annotations.Add(annotation)
+	if err != nil {
+		http.Error(w, "Failed to save annotation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type BulkUpdateHandler struct {
+	bulkService *service.BulkUpdateService
+}
+
+func NewBulkUpdateHandler(bulk *service.BulkUpdateService) *BulkUpdateHandler {
+	return &BulkUpdateHandler{
+		bulkService: bulk,
+	}
+}
+
+// HandleBulkUpdate starts an asynchronous bulk metadata update and returns
+// its job ID; progress is available from the jobs API
+func (h *BulkUpdateHandler) HandleBulkUpdate(w http.ResponseWriter, r *http.Request) {
+	var req service.BulkUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	jobID, err := h.bulkService.Start(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id": jobID,
+		"status": "queued",
+	})
+}
+
+// maxMetadataImportSize caps the size of a metadata import file
+const maxMetadataImportSize = 20 << 20
+
+// HandleMetadataImport sets titles, artists, tags and custom attributes of
+// many images from a CSV or JSON file, sent as the "file" form field or as
+// the body. With dry_run=true the rows are only validated. Otherwise a valid
+// import runs as a background job; one with invalid rows changes nothing.
+func (h *BulkUpdateHandler) HandleMetadataImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxMetadataImportSize)
+
+	var body io.Reader = r.Body
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	isCSV := mediaType == "text/csv"
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Missing file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+		isCSV = strings.EqualFold(filepath.Ext(header.Filename), ".csv")
+	}
+
+	var rows []service.MetadataImportRow
+	var err error
+	if isCSV {
+		rows, err = service.ParseMetadataCSV(body)
+	} else {
+		rows, err = service.ParseMetadataJSON(body)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := h.bulkService.PlanImport(rows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("dry_run") == "true" {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"dry_run": true,
+			"import":  plan,
+		})
+		return
+	}
+	jobID, err := h.bulkService.StartImport(plan)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  err.Error(),
+			"import": plan,
+		})
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id": jobID,
+		"status": "queued",
+		"import": plan,
+	})
+}
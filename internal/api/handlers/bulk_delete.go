@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type BulkDeleteHandler struct {
+	bulkDelete *service.BulkDeleteService
+}
+
+func NewBulkDeleteHandler(bulkDelete *service.BulkDeleteService) *BulkDeleteHandler {
+	return &BulkDeleteHandler{
+		bulkDelete: bulkDelete,
+	}
+}
+
+// bulkDeleteRequest is a filter, plus the token from its dry run to execute it
+type bulkDeleteRequest struct {
+	Filter            service.BulkDeleteFilter `json:"filter"`
+	ConfirmationToken string                   `json:"confirmation_token,omitempty"`
+}
+
+// HandleBulkDelete deletes every image matching a filter in two steps.
+// Without a confirmation_token the request is a dry run: nothing is deleted
+// and the response lists the matches with a token. Sending the same filter
+// with that token deletes exactly the previewed images as a background job.
+func (h *BulkDeleteHandler) HandleBulkDelete(w http.ResponseWriter, r *http.Request) {
+	var req bulkDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	actor := middleware.PrincipalFrom(r.Context()).Name
+
+	if req.ConfirmationToken != "" {
+		jobID, count, err := h.bulkDelete.Confirm(req.Filter, req.ConfirmationToken, actor)
+		if err != nil {
+			if errors.Is(err, service.ErrConfirmationToken) {
+				http.Error(w, "Invalid or expired confirmation token; run the dry run again", http.StatusConflict)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id": jobID,
+			"status": "queued",
+			"count":  count,
+		})
+		return
+	}
+
+	preview, err := h.bulkDelete.Preview(r.Context(), req.Filter, actor)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	images := make([]GridImage, 0, len(preview.Images))
+	for _, img := range preview.Images {
+		images = append(images, toGridImage(img))
+	}
+	resp := map[string]interface{}{
+		"dry_run": true,
+		"count":   preview.Count,
+		"ids":     preview.IDs,
+		"images":  images,
+	}
+	if preview.Held > 0 {
+		resp["held"] = preview.Held
+	}
+	if preview.ConfirmationToken != "" {
+		resp["confirmation_token"] = preview.ConfirmationToken
+		resp["expires_at"] = preview.ExpiresAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
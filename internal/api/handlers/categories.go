@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type CategoriesHandler struct {
+	spriteService *service.SpriteService
+	watermarker   *service.Watermarker
+}
+
+func NewCategoriesHandler(sprite *service.SpriteService, watermarker *service.Watermarker) *CategoriesHandler {
+	return &CategoriesHandler{
+		spriteService: sprite,
+		watermarker:   watermarker,
+	}
+}
+
+// HandleGetSprite returns the sprite manifest of a category: the sheet URL
+// and the offset of every thumbnail in it
+func (h *CategoriesHandler) HandleGetSprite(w http.ResponseWriter, r *http.Request) {
+	category := mux.Vars(r)["cat"]
+	if !service.ValidCategoryPath(category) {
+		http.Error(w, "Invalid category", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := h.spriteService.Get(category)
+	if err != nil {
+		http.Error(w, "Failed to build sprite sheet", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// HandleGetSpriteImage serves the packed sprite sheet of a category
+func (h *CategoriesHandler) HandleGetSpriteImage(w http.ResponseWriter, r *http.Request) {
+	category := mux.Vars(r)["cat"]
+
+	// Make sure the sheet exists and is current
+	if _, err := h.spriteService.Get(category); err != nil {
+		http.Error(w, "Sprite sheet not available", http.StatusNotFound)
+		return
+	}
+
+	path, err := h.spriteService.ImagePath(category)
+	if err != nil {
+		http.Error(w, "Invalid category", http.StatusBadRequest)
+		return
+	}
+
+	// URLs carry the sheet version, so the image can be cached for long
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	serveRendition(w, r, h.watermarker, nil, path)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+// Page sizes of the change feed
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+type ChangesHandler struct {
+	journal *service.IndexJournal
+}
+
+func NewChangesHandler(journal *service.IndexJournal) *ChangesHandler {
+	return &ChangesHandler{
+		journal: journal,
+	}
+}
+
+// change is one entry of the change feed
+type change struct {
+	Seq     int64                  `json:"seq"`
+	Time    time.Time              `json:"time"`
+	Op      string                 `json:"op"` // append, update, delete or revert
+	ImageID string                 `json:"image_id"`
+	Image   *service.ImageMetadata `json:"image"` // state after the change; a tombstone for deletes, null for a reverted append
+}
+
+// HandleChanges returns index mutations after since=seq (default 0), oldest
+// first, up to limit=N (default 100, max 1000). Clients store last_seq and
+// pass it as since on the next call; has_more means another page is ready.
+func (h *ChangesHandler) HandleChanges(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	if value := r.URL.Query().Get("since"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+		since = n
+	}
+	limit := defaultChangesLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxChangesLimit {
+			http.Error(w, "Invalid limit (1-1000)", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	latest := h.journal.LastSeq()
+	entries, err := h.journal.Since(since, limit)
+	if err != nil {
+		http.Error(w, "Failed to read changes", http.StatusInternalServerError)
+		return
+	}
+
+	changes := make([]change, 0, len(entries))
+	lastSeq := since
+	for _, entry := range entries {
+		var image *service.ImageMetadata
+		if entry.Section != "" {
+			image = entry.Image()
+		}
+		changes = append(changes, change{
+			Seq:     entry.Seq,
+			Time:    entry.Time,
+			Op:      entry.Op,
+			ImageID: entry.ImageID,
+			Image:   image,
+		})
+		lastSeq = entry.Seq
+	}
+	if since > latest {
+		// The client is ahead of the journal (e.g. after a restore): resync
+		lastSeq = latest
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"changes":  changes,
+		"last_seq": lastSeq,
+		"has_more": lastSeq < latest,
+	})
+}
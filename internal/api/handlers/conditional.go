@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeConditionalJSON encodes v as JSON with a content-hash ETag and, when
+// lastModified is non-zero, a Last-Modified header. Requests whose
+// If-None-Match or If-Modified-Since show the client already has this
+// representation get 304 Not Modified with no body.
+func writeConditionalJSON(w http.ResponseWriter, r *http.Request, v interface{}, lastModified time.Time) {
+	writeImageJSON(w, r, v, 0, lastModified)
+}
+
+// writeImageJSON writes an image the way writeConditionalJSON does, with its
+// revision leading the ETag, so the tag can be sent back as If-Match on PATCH
+func writeImageJSON(w http.ResponseWriter, r *http.Request, v interface{}, revision int, lastModified time.Time) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
+	writeTagged(w, r, body, "application/json", contentETag(body, revision), lastModified)
+}
+
+// writeConditional writes an encoded body the way writeConditionalJSON does
+func writeConditional(w http.ResponseWriter, r *http.Request, body []byte, contentType string, lastModified time.Time) {
+	writeTagged(w, r, body, contentType, contentETag(body, 0), lastModified)
+}
+
+func writeTagged(w http.ResponseWriter, r *http.Request, body []byte, contentType, etag string, lastModified time.Time) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	// Representations depend on the caller (favorites, roles) and language
+	addVary(w.Header(), "Authorization", "X-Actor", "Accept-Language")
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+// contentETag returns a strong ETag hashing body. With a revision it reads
+// "<revision>.<hash>": the hash changes with anything in the representation
+// (popularity, favorites, links), the revision names the metadata version.
+func contentETag(body []byte, revision int) string {
+	sum := sha256.Sum256(body)
+	tag := hex.EncodeToString(sum[:16])
+	if revision > 0 {
+		tag = strconv.Itoa(revision) + "." + tag
+	}
+	return `"` + tag + `"`
+}
+
+// revisionFromIfMatch reads the expected revision from an If-Match header:
+// an image's ETag ("3.<hash>") or a bare revision ("3")
+func revisionFromIfMatch(header string) (int, error) {
+	tag := strings.Trim(strings.TrimPrefix(strings.TrimSpace(header), "W/"), `"`)
+	revision, _, _ := strings.Cut(tag, ".")
+	rev, err := strconv.Atoi(revision)
+	if err != nil || rev <= 0 {
+		return 0, fmt.Errorf("invalid If-Match revision %q", header)
+	}
+	return rev, nil
+}
+
+// addVary adds header names to Vary, skipping those already listed
+func addVary(h http.Header, names ...string) {
+	listed := make(map[string]bool)
+	for _, value := range h.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			listed[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+	for _, name := range names {
+		if !listed[http.CanonicalHeaderKey(name)] {
+			h.Add("Vary", name)
+			listed[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// notModified evaluates conditional request headers. If-None-Match takes
+// precedence over If-Modified-Since, as required by RFC 7232.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			candidate = strings.TrimPrefix(candidate, "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err == nil && !lastModified.Truncate(time.Second).After(since) {
+			return true
+		}
+	}
+
+	return false
+}
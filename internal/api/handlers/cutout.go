@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type CutoutHandler struct {
+	indexService  *service.IndexService
+	cutoutService *service.CutoutService
+}
+
+func NewCutoutHandler(index *service.IndexService, cutouts *service.CutoutService) *CutoutHandler {
+	return &CutoutHandler{
+		indexService:  index,
+		cutoutService: cutouts,
+	}
+}
+
+// HandleGetCutout serves the background-removed PNG of an image, creating it
+// on first request. 3D objects are cut out from their front view.
+func (h *CutoutHandler) HandleGetCutout(w http.ResponseWriter, r *http.Request) {
+	metadata, err := h.indexService.GetImageByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	path, err := h.cutoutService.Cutout(r.Context(), metadata)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNoCutoutSource):
+			http.Error(w, "No cutout for this image", http.StatusNotFound)
+		case errors.Is(err, service.ErrBackgroundNotUniform):
+			http.Error(w, "Background could not be removed: it is not a plain backdrop", http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, "Failed to create cutout", http.StatusBadGateway)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// storageStatsTTL is how long walked storage stats are reused
+const storageStatsTTL = time.Minute
+
+// recentFailures is how many failed jobs the stats include
+const recentFailures = 10
+
+// DashboardHandler serves the admin dashboard page and the stats behind it
+type DashboardHandler struct {
+	imageService   *service.ImageService
+	storageService *service.StorageService
+
+	statsMutex sync.Mutex
+	storage    *service.StorageStats
+}
+
+func NewDashboardHandler(image *service.ImageService, storage *service.StorageService) *DashboardHandler {
+	return &DashboardHandler{
+		imageService:   image,
+		storageService: storage,
+	}
+}
+
+// HandlePage serves the admin dashboard. The page itself is public; the APIs
+// it calls need an admin token when API_TOKENS is set.
+func (h *DashboardHandler) HandlePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(dashboardPage)
+}
+
+// HandleStats returns what the dashboard shows: queue metrics, running jobs,
+// recent failures, AI usage and versions, and storage use. Storage is walked at most once
+// a minute.
+func (h *DashboardHandler) HandleStats(w http.ResponseWriter, r *http.Request) {
+	failures := h.imageService.ListJobs(models.JobStateError)
+	if len(failures) > recentFailures {
+		failures = failures[:recentFailures]
+	}
+
+	response := map[string]interface{}{
+		"queue":           h.imageService.Metrics(),
+		"running":         h.imageService.ListJobs(models.JobStateRunning),
+		"recent_failures": failures,
+		"ai_usage":        h.imageService.AIUsage(),
+		"ai_versions":     h.imageService.AIVersions(),
+	}
+	if storage, err := h.storageStats(); err == nil {
+		response["storage"] = storage
+	} else {
+		response["storage_error"] = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// storageStats returns cached storage stats, walking the data directory again
+// once they are older than storageStatsTTL
+func (h *DashboardHandler) storageStats() (*service.StorageStats, error) {
+	h.statsMutex.Lock()
+	defer h.statsMutex.Unlock()
+
+	if h.storage != nil && time.Since(h.storage.ComputedAt) < storageStatsTTL {
+		return h.storage, nil
+	}
+	stats, err := h.storageService.Stats()
+	if err != nil {
+		return nil, err
+	}
+	h.storage = stats
+	return stats, nil
+}
+
+// HandleScaleWorkers sets the number of processing workers, e.g.
+// {"workers": 8}. Removed workers finish their current job first.
+func (h *DashboardHandler) HandleScaleWorkers(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Workers int `json:"workers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.imageService.SetWorkers(req.Workers); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.imageService.Metrics())
+}
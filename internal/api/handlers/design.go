@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+// maxDesignFrames bounds the frames of one design export
+const maxDesignFrames = 50
+
+// DesignHandler archives frames exported by design-tool plugins (Figma).
+// Each frame becomes a 2D upload that keeps its document, node and deep link.
+type DesignHandler struct {
+	uploads *UploadHandler
+}
+
+func NewDesignHandler(uploads *UploadHandler) *DesignHandler {
+	return &DesignHandler{
+		uploads: uploads,
+	}
+}
+
+// designExportRequest is the body of a design export: the document, the
+// upload fields shared by all frames, and the frames
+type designExportRequest struct {
+	Tool         string `json:"tool"` // default figma
+	DocumentID   string `json:"document_id"`
+	DocumentName string `json:"document_name"`
+	Version      string `json:"version"`
+
+	Artist     string          `json:"artist"`
+	Tags       json.RawMessage `json:"tags"`
+	Project    string          `json:"project"`
+	Visibility string          `json:"visibility"`
+	Priority   string          `json:"priority"`
+
+	Frames []designFrame `json:"frames"`
+}
+
+// designFrame is one exported frame
+type designFrame struct {
+	NodeID      string `json:"node_id"`
+	Name        string `json:"name"`
+	Page        string `json:"page"`
+	URL         string `json:"url"`          // deep link; built from the IDs for Figma if empty
+	ImageBase64 string `json:"image_base64"` // the export (PNG, JPG, ...), plain base64 or a data: URI
+	Filename    string `json:"filename"`
+}
+
+// designFrameResult reports what became of a frame
+type designFrameResult struct {
+	NodeID string `json:"node_id"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HandleExport queues the frames of a design export
+func (h *DesignHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	// All frames of an export together are limited to the upload size
+	r.Body = http.MaxBytesReader(w, r.Body, h.uploads.maxUploadSize/3*4+64<<10)
+
+	var req designExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Export too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Tool == "" {
+		req.Tool = models.DesignToolFigma
+	}
+	if len(req.Frames) == 0 {
+		http.Error(w, "No frames provided", http.StatusBadRequest)
+		return
+	}
+	if len(req.Frames) > maxDesignFrames {
+		http.Error(w, fmt.Sprintf("Too many frames (at most %d per export)", maxDesignFrames), http.StatusBadRequest)
+		return
+	}
+
+	tags, err := parseIngestTags(req.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Check every frame before queueing any, so a bad export files nothing
+	type preparedFrame struct {
+		job      *models.UploadJob
+		data     []byte
+		filename string
+	}
+	prepared := make([]preparedFrame, len(req.Frames))
+	for i, frame := range req.Frames {
+		source := &models.DesignSource{
+			Tool:         req.Tool,
+			DocumentID:   req.DocumentID,
+			DocumentName: req.DocumentName,
+			NodeID:       frame.NodeID,
+			NodeName:     frame.Name,
+			Page:         frame.Page,
+			Version:      req.Version,
+			URL:          frame.URL,
+		}
+		source.Normalize()
+		if err := source.Validate(); err != nil {
+			http.Error(w, frameError(i, frame, err), http.StatusBadRequest)
+			return
+		}
+
+		form := url.Values{}
+		form.Set("title", source.NodeName)
+		form.Set("artist", req.Artist)
+		form.Set("project", req.Project)
+		form.Set("visibility", req.Visibility)
+		form.Set("priority", req.Priority)
+		if len(tags) > 0 {
+			encoded, _ := json.Marshal(tags)
+			form.Set("tags", string(encoded))
+		}
+		job, err := newUploadJob(form, h.uploads.projects)
+		if err != nil {
+			http.Error(w, frameError(i, frame, err), http.StatusBadRequest)
+			return
+		}
+		job.DesignSource = source
+
+		data, contentType, err := decodeBase64Image(frame.ImageBase64)
+		if err != nil {
+			http.Error(w, frameError(i, frame, err), http.StatusBadRequest)
+			return
+		}
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+		filename := service.CleanFilename(frame.Filename)
+		if filename == "" {
+			filename = "frame"
+		}
+		prepared[i] = preparedFrame{job: job, data: data, filename: service.WithImageExtension(filename, contentType)}
+	}
+
+	// Queue the frames; one that fails doesn't hold back the others
+	results := make([]designFrameResult, len(prepared))
+	status := http.StatusAccepted
+	for i, frame := range prepared {
+		results[i] = h.queueFrame(frame.job, frame.data, frame.filename)
+		if results[i].Error != "" {
+			status = http.StatusMultiStatus
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"document_id": prepared[0].job.DesignSource.DocumentID,
+		"frames":      results,
+	})
+}
+
+// queueFrame saves a frame to temp and queues it
+func (h *DesignHandler) queueFrame(job *models.UploadJob, data []byte, filename string) designFrameResult {
+	result := designFrameResult{NodeID: job.DesignSource.NodeID}
+
+	saveStart := time.Now()
+	imageID, tempPath, err := h.uploads.storageService.SaveImageToTemp(bytes.NewReader(data), filename)
+	if err != nil {
+		result.Status, result.Error = "error", "Failed to save image"
+		return result
+	}
+	job.ImageID = imageID
+	job.FilePath = tempPath
+	job.OriginalFilename = filename
+	job.Timeline = []models.StageTiming{models.TimeStage(models.StageSave, saveStart)}
+
+	if err := h.uploads.imageService.QueueJob(job); err != nil {
+		h.uploads.storageService.CleanupTemp(job)
+		result.Status = "error"
+		if errors.Is(err, service.ErrQueueFull) {
+			result.Error = "Processing queue is full, retry later"
+		} else {
+			result.Error = "Failed to queue job"
+		}
+		return result
+	}
+
+	result.ID, result.Status = imageID, "processing"
+	return result
+}
+
+// frameError names the frame a validation error is about
+func frameError(i int, frame designFrame, err error) string {
+	name := frame.Name
+	if name == "" {
+		name = frame.NodeID
+	}
+	return fmt.Sprintf("Frame %d (%s): %v", i+1, name, err)
+}
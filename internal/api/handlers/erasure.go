@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type ErasureHandler struct {
+	erasure *service.ErasureService
+}
+
+func NewErasureHandler(erasure *service.ErasureService) *ErasureHandler {
+	return &ErasureHandler{
+		erasure: erasure,
+	}
+}
+
+// HandleExport downloads a ZIP of everything kept about an artist's images:
+// ?artist=Name
+func (h *ErasureHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	artist := strings.TrimSpace(r.URL.Query().Get("artist"))
+	if artist == "" {
+		http.Error(w, "artist is required", http.StatusBadRequest)
+		return
+	}
+	actor := middleware.PrincipalFrom(r.Context()).Name
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": artist + ".zip"}))
+	if err := h.erasure.Export(artist, actor, w); err != nil {
+		// Nothing is written before the images are found, so these can
+		// still be reported
+		w.Header().Del("Content-Disposition")
+		if errors.Is(err, service.ErrNoArtistImages) {
+			http.Error(w, "No images by this artist", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Export failed: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// erasureRequest names the artist to erase, plus the token from the dry run
+// to execute it
+type erasureRequest struct {
+	Artist            string `json:"artist"`
+	ConfirmationToken string `json:"confirmation_token,omitempty"`
+}
+
+// HandleErase erases an artist's images in two steps, like a bulk delete.
+// Without a confirmation_token the request is a dry run listing the images;
+// sending the same artist with the token erases them as a background job.
+func (h *ErasureHandler) HandleErase(w http.ResponseWriter, r *http.Request) {
+	var req erasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	actor := middleware.PrincipalFrom(r.Context()).Name
+
+	if req.ConfirmationToken != "" {
+		jobID, count, err := h.erasure.Confirm(req.Artist, req.ConfirmationToken, actor)
+		if err != nil {
+			http.Error(w, "Invalid or expired confirmation token; run the dry run again", http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":  jobID,
+			"status":  "queued",
+			"count":   count,
+			"backups": service.ErasureBackupsNote,
+		})
+		return
+	}
+
+	preview, err := h.erasure.Preview(req.Artist, actor)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		DryRun bool `json:"dry_run"`
+		*service.ErasurePreview
+	}{true, preview})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type FavoritesHandler struct {
+	indexService *service.IndexService
+	favorites    *service.FavoriteStore
+}
+
+func NewFavoritesHandler(index *service.IndexService, favorites *service.FavoriteStore) *FavoritesHandler {
+	return &FavoritesHandler{
+		indexService: index,
+		favorites:    favorites,
+	}
+}
+
+// favoriteUser returns whose favorites a request works on: the API token's
+// name, or the X-Actor name when auth is disabled (every caller is then an
+// admin). Anonymous callers of a server with tokens have no favorites.
+func favoriteUser(r *http.Request) (string, bool) {
+	principal := middleware.PrincipalFrom(r.Context())
+	if !principal.Authenticated && principal.Role != models.RoleAdmin {
+		return "", false
+	}
+	return principal.Name, true
+}
+
+// HandleAddFavorite stars an image for the caller
+func (h *FavoritesHandler) HandleAddFavorite(w http.ResponseWriter, r *http.Request) {
+	user, ok := favoriteUser(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	imageID := mux.Vars(r)["id"]
+	if _, err := h.indexService.GetImageByID(imageID); err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	favorite, err := h.favorites.Add(user, imageID)
+	if err != nil {
+		http.Error(w, "Failed to save favorite", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(favorite)
+}
+
+// HandleRemoveFavorite unstars an image for the caller
+func (h *FavoritesHandler) HandleRemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	user, ok := favoriteUser(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	removed, err := h.favorites.Remove(user, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to save favorite", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Not a favorite", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// favoriteImage is an entry of the favorites list
+type favoriteImage struct {
+	models.Favorite
+	Image *service.ImageMetadata `json:"image"`
+}
+
+// HandleListFavorites returns the caller's favorites with their metadata,
+// most recently starred first. view=grid returns the compact projection.
+func (h *FavoritesHandler) HandleListFavorites(w http.ResponseWriter, r *http.Request) {
+	user, ok := favoriteUser(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	view := r.URL.Query().Get("view")
+	if view != "" && view != "full" && view != "grid" {
+		http.Error(w, "Invalid view (use grid or full)", http.StatusBadRequest)
+		return
+	}
+
+	images, err := h.indexService.GetAllImages()
+	if err != nil {
+		http.Error(w, "Failed to load images", http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[string]*service.ImageMetadata, len(images))
+	for _, img := range images {
+		byID[img.ID] = img
+	}
+
+	favorites := h.favorites.List(user)
+	entries := make([]interface{}, 0, len(favorites))
+	for _, favorite := range favorites {
+		img, ok := byID[favorite.ImageID]
+		if !ok {
+			continue
+		}
+		img.Favorite = true
+		if view == "grid" {
+			entries = append(entries, toGridImage(img))
+		} else {
+			entries = append(entries, favoriteImage{Favorite: favorite, Image: img})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"favorites": entries,
+		"total":     len(entries),
+	})
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+const (
+	defaultFeedEntries = 20
+	maxFeedEntries     = 100
+)
+
+// FeedHandler serves newly processed images as an Atom feed, for feed
+// readers and Slack's RSS app
+type FeedHandler struct {
+	indexService *service.IndexService
+	baseURL      string // public URL of the server; taken from the request if empty
+}
+
+func NewFeedHandler(index *service.IndexService, baseURL string) *FeedHandler {
+	return &FeedHandler{
+		indexService: index,
+		baseURL:      strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// atomFeed is an Atom feed (RFC 4287) with Media RSS thumbnails
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	MediaNS string      `xml:"xmlns:media,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID         string          `xml:"id"`
+	Title      string          `xml:"title"`
+	Published  string          `xml:"published"`
+	Updated    string          `xml:"updated"`
+	Author     *atomPerson     `xml:"author,omitempty"`
+	Categories []atomCategory  `xml:"category"`
+	Links      []atomLink      `xml:"link"`
+	Thumbnail  *mediaThumbnail `xml:"media:thumbnail,omitempty"`
+	Content    *atomContent    `xml:"content,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// mediaThumbnail is the Media RSS thumbnail element; it names its link "url"
+type mediaThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+// HandleFeed lists the latest processed images, newest first, as Atom.
+// category, tag (manual or detected) and project filter as in the list;
+// limit=N entries (1-100, default 20). Private images are left out.
+func (h *FeedHandler) HandleFeed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := defaultFeedEntries
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxFeedEntries {
+			http.Error(w, "Invalid limit (1-100)", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	lastModified, _ := h.indexService.LastModified()
+	images, err := h.indexService.GetAllImages()
+	if err != nil {
+		http.Error(w, "Failed to load images", http.StatusInternalServerError)
+		return
+	}
+
+	category, tag, project := query.Get("category"), query.Get("tag"), query.Get("project")
+	type dated struct {
+		img      *service.ImageMetadata
+		uploaded time.Time
+	}
+	var entries []dated
+	for _, img := range images {
+		if img.Visibility == models.VisibilityPrivate {
+			continue
+		}
+		if (category != "" && !img.InCategory(category)) || (project != "" && img.Project != project) ||
+			(tag != "" && !hasTag(img, tag)) {
+			continue
+		}
+		uploaded, err := img.UploadedTime()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, dated{img, uploaded})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].uploaded.After(entries[j].uploaded)
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	base := linkBase(h.baseURL, r)
+	self := base + r.URL.RequestURI()
+
+	title := "Image Warehouse"
+	switch {
+	case category != "":
+		title += ": " + category
+	case tag != "":
+		title += ": " + tag
+	case project != "":
+		title += ": " + project
+	}
+
+	feed := atomFeed{
+		MediaNS: "http://search.yahoo.com/mrss/",
+		ID:      self,
+		Title:   title,
+		Updated: lastModified.UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Rel: "self", Type: "application/atom+xml", Href: self}},
+	}
+	if len(entries) > 0 {
+		feed.Updated = entries[0].uploaded.UTC().Format(time.RFC3339)
+	} else if lastModified.IsZero() {
+		feed.Updated = time.Now().UTC().Format(time.RFC3339)
+	}
+	for _, entry := range entries {
+		feed.Entries = append(feed.Entries, toAtomEntry(entry.img, entry.uploaded, base))
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		http.Error(w, "Failed to encode feed", http.StatusInternalServerError)
+		return
+	}
+	body = append([]byte(xml.Header), body...)
+	writeConditional(w, r, append(body, '\n'), "application/atom+xml; charset=utf-8", lastModified)
+}
+
+// toAtomEntry builds the feed entry of an image: a link to the file (3D
+// objects: the preview), the thumbnail, and the description
+func toAtomEntry(img *service.ImageMetadata, uploaded time.Time, base string) atomEntry {
+	entry := atomEntry{
+		ID:        base + "/api/v1/images/" + url.PathEscape(img.ID),
+		Title:     img.Title,
+		Published: uploaded.UTC().Format(time.RFC3339),
+		Updated:   uploaded.UTC().Format(time.RFC3339),
+	}
+	if img.Artist != "" {
+		entry.Author = &atomPerson{Name: img.Artist}
+	}
+	if img.Category != "" {
+		entry.Categories = append(entry.Categories, atomCategory{Term: img.Category})
+	}
+	for _, tag := range img.Tags {
+		entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+	}
+
+	entry.Links = append(entry.Links, atomLink{Rel: "related", Type: "application/json", Href: entry.ID})
+	thumbnail := img.PreviewThumbnail()
+	if file := img.FilePath; file != "" {
+		entry.Links = append(entry.Links, atomLink{Rel: "alternate", Type: img.MimeType, Href: assetBase(base) + "/data/" + file})
+	} else if thumbnail != "" {
+		entry.Links = append(entry.Links, atomLink{Rel: "alternate", Type: "image/jpeg", Href: assetBase(base) + "/data/" + thumbnail})
+	}
+
+	var content strings.Builder
+	if thumbnail != "" {
+		thumbURL := assetBase(base) + "/data/" + thumbnail
+		entry.Thumbnail = &mediaThumbnail{URL: thumbURL}
+		content.WriteString(`<p><img src="` + html.EscapeString(thumbURL) + `" alt="` + html.EscapeString(img.Title) + `"></p>`)
+	}
+	if img.Description != "" {
+		content.WriteString("<p>" + html.EscapeString(img.Description) + "</p>")
+	}
+	if content.Len() > 0 {
+		entry.Content = &atomContent{Type: "html", Body: content.String()}
+	}
+	return entry
+}
+
+// hasTag reports whether an image has a manual tag or detected object,
+// ignoring case
+func hasTag(img *service.ImageMetadata, tag string) bool {
+	for _, values := range [][]string{img.Tags, img.Objects} {
+		for _, value := range values {
+			if strings.EqualFold(value, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestBaseURL is the scheme and host the request was sent to
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+// GalleryHandler serves embeddable galleries to holders of a guest token and
+// lets admins issue and revoke those tokens
+type GalleryHandler struct {
+	indexService *service.IndexService
+	projects     *service.ProjectStore
+	guestTokens  *service.GuestTokenStore
+}
+
+func NewGalleryHandler(index *service.IndexService, projects *service.ProjectStore, guestTokens *service.GuestTokenStore) *GalleryHandler {
+	return &GalleryHandler{
+		indexService: index,
+		projects:     projects,
+		guestTokens:  guestTokens,
+	}
+}
+
+// createGuestTokenRequest is the body of POST /admin/guest-tokens
+type createGuestTokenRequest struct {
+	Name      string `json:"name"`
+	Project   string `json:"project,omitempty"`
+	Category  string `json:"category,omitempty"`
+	ExpiresIn string `json:"expires_in,omitempty"` // e.g. 720h; empty never expires
+}
+
+// HandleGallery lists the images a guest token (token=) covers, newest first,
+// with /data/ URLs that carry the token. It needs no API token and may be
+// fetched from any origin.
+func (h *GalleryHandler) HandleGallery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	token := r.URL.Query().Get("token")
+	guest, err := h.guestTokens.Resolve(token, time.Now())
+	if err != nil {
+		http.Error(w, "Invalid or expired guest token", http.StatusUnauthorized)
+		return
+	}
+
+	images, err := h.indexService.GetAllImages()
+	if err != nil {
+		http.Error(w, "Failed to load images", http.StatusInternalServerError)
+		return
+	}
+	grid := []GridImage{}
+	for i := len(images) - 1; i >= 0; i-- {
+		if guest.Allows(images[i]) {
+			grid = append(grid, withGuestToken(toGridImage(images[i]), token))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":     guest.Name,
+		"project":  guest.Project,
+		"category": guest.Category,
+		"images":   grid,
+		"total":    len(grid),
+	})
+}
+
+// withGuestToken adds the guest token to the /data/ URLs of a grid image.
+// The turntable URL is an API route, which doesn't take guest tokens.
+func withGuestToken(grid GridImage, token string) GridImage {
+	query := "?token=" + url.QueryEscape(token)
+	for _, u := range []*string{&grid.ThumbnailURL, &grid.SquareThumbnailURL} {
+		if *u != "" {
+			*u += query
+		}
+	}
+	return grid
+}
+
+// HandleCreateGuestToken issues a guest token for one project or category.
+// The token is only returned in this response.
+func (h *GalleryHandler) HandleCreateGuestToken(w http.ResponseWriter, r *http.Request) {
+	var req createGuestTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	guest := service.GuestToken{
+		Name:      req.Name,
+		Project:   req.Project,
+		Category:  req.Category,
+		CreatedBy: middleware.PrincipalFrom(r.Context()).Name,
+	}
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid expires_in", http.StatusBadRequest)
+			return
+		}
+		expires := time.Now().Add(d).Truncate(time.Second)
+		guest.ExpiresAt = &expires
+	}
+	guest.Normalize()
+	if guest.Project != "" {
+		if _, err := h.projects.Get(guest.Project); err != nil {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	created, token, err := h.guestTokens.Create(guest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"guest_token": created,
+		"token":       token,
+		"gallery_url": "/api/v1/gallery?token=" + url.QueryEscape(token),
+	})
+}
+
+// HandleListGuestTokens lists the guest tokens, without their secrets
+func (h *GalleryHandler) HandleListGuestTokens(w http.ResponseWriter, r *http.Request) {
+	tokens := h.guestTokens.List()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"guest_tokens": tokens,
+		"total":        len(tokens),
+	})
+}
+
+// HandleRevokeGuestToken revokes a guest token; galleries using it stop
+// working at once
+func (h *GalleryHandler) HandleRevokeGuestToken(w http.ResponseWriter, r *http.Request) {
+	if err := h.guestTokens.Revoke(mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, service.ErrGuestTokenNotFound) {
+			http.Error(w, "Guest token not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to revoke guest token", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
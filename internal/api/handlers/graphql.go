@@ -0,0 +1,579 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/graphql"
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+const (
+	maxGraphQLRequestSize = 1 << 20
+	defaultGraphQLImages  = 50
+	maxGraphQLImages      = 500
+	maxGraphQLSearches    = 5 // per query, each embedding the query text
+)
+
+// GraphQLHandler serves a read-only GraphQL API over images, projects,
+// categories and search, so gallery UIs can fetch nested data (image ->
+// renditions -> project) in one request and only the fields they show
+type GraphQLHandler struct {
+	schema        *graphql.Schema
+	indexService  *service.IndexService
+	searchService *service.SearchService
+	projects      *service.ProjectStore
+	renditions    *service.RenditionService
+	annotations   *service.AnnotationStore
+	popularity    *service.PopularityStore
+}
+
+func NewGraphQLHandler(index *service.IndexService, search *service.SearchService, projects *service.ProjectStore,
+	renditions *service.RenditionService, annotations *service.AnnotationStore, popularity *service.PopularityStore) *GraphQLHandler {
+	h := &GraphQLHandler{
+		indexService:  index,
+		searchService: search,
+		projects:      projects,
+		renditions:    renditions,
+		annotations:   annotations,
+		popularity:    popularity,
+	}
+	h.schema = h.buildSchema()
+	return h
+}
+
+// HandleGraphQL runs a query sent as JSON ({"query", "variables",
+// "operationName"}) by POST, or as query parameters by GET. Results are
+// always 200 with data and/or errors, as GraphQL clients expect.
+func (h *GraphQLHandler) HandleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				http.Error(w, "Invalid variables (must be a JSON object)", http.StatusBadRequest)
+				return
+			}
+		}
+	} else {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGraphQLRequestSize))
+		if err != nil {
+			http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/graphql" {
+			req.Query = string(body)
+		} else if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		http.Error(w, "Query required", http.StatusBadRequest)
+		return
+	}
+
+	// Resolvers share one read of the index per request
+	ctx := context.WithValue(r.Context(), graphQLSnapshotKey{}, &graphQLSnapshot{index: h.indexService})
+	resp := h.schema.Execute(ctx, req)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+type graphQLSnapshotKey struct{}
+
+// graphQLSnapshot is the index as read by the first resolver of a request
+type graphQLSnapshot struct {
+	index  *service.IndexService
+	images []*service.ImageMetadata
+	byID   map[string]*service.ImageMetadata
+}
+
+func snapshotImages(ctx context.Context) ([]*service.ImageMetadata, map[string]*service.ImageMetadata, error) {
+	s := ctx.Value(graphQLSnapshotKey{}).(*graphQLSnapshot)
+	if s.byID == nil {
+		images, err := s.index.GetAllImages()
+		if err != nil {
+			return nil, nil, errors.New("failed to load images")
+		}
+		s.images = images
+		s.byID = make(map[string]*service.ImageMetadata, len(images))
+		for _, img := range images {
+			s.byID[img.ID] = img
+		}
+	}
+	return s.images, s.byID, nil
+}
+
+// graphQLCategory is a category of the Category type
+type graphQLCategory struct {
+	path  string
+	count int
+}
+
+// graphQLPage is a page of images of the ImagePage type
+type graphQLPage struct {
+	total int
+	items []*service.ImageMetadata
+}
+
+// pageArgs are the limit and offset arguments of image lists
+var pageArgs = map[string]graphql.Arg{
+	"limit":  {Type: graphql.Int, Default: defaultGraphQLImages},
+	"offset": {Type: graphql.Int, Default: 0},
+}
+
+// paginate applies limit and offset to a list of images
+func paginate(images []*service.ImageMetadata, args graphql.Args) ([]*service.ImageMetadata, error) {
+	limit, offset := args.Int("limit"), args.Int("offset")
+	if limit < 0 || limit > maxGraphQLImages {
+		return nil, fmt.Errorf("limit must be between 0 and %d", maxGraphQLImages)
+	}
+	if offset < 0 {
+		return nil, errors.New("offset cannot be negative")
+	}
+	if offset > len(images) {
+		offset = len(images)
+	}
+	images = images[offset:]
+	if len(images) > limit {
+		images = images[:limit]
+	}
+	return images, nil
+}
+
+func (h *GraphQLHandler) buildSchema() *graphql.Schema {
+	image := graphql.NewObject("Image")
+	rendition := graphql.NewObject("Rendition")
+	license := graphql.NewObject("License")
+	location := graphql.NewObject("Location")
+	project := graphql.NewObject("Project")
+	category := graphql.NewObject("Category")
+	page := graphql.NewObject("ImagePage")
+	searchResult := graphql.NewObject("SearchResult")
+	searchMatch := graphql.NewObject("SearchMatch")
+	query := graphql.NewObject("Query")
+
+	// Image
+	for name, get := range map[string]func(img *service.ImageMetadata) interface{}{
+		"id":                 func(img *service.ImageMetadata) interface{} { return img.ID },
+		"title":              func(img *service.ImageMetadata) interface{} { return img.Title },
+		"artist":             func(img *service.ImageMetadata) interface{} { return img.Artist },
+		"description":        func(img *service.ImageMetadata) interface{} { return img.Description },
+		"category":           func(img *service.ImageMetadata) interface{} { return img.Category },
+		"subCategory":        func(img *service.ImageMetadata) interface{} { return img.SubCategory },
+		"type":               func(img *service.ImageMetadata) interface{} { return img.Type },
+		"tags":               func(img *service.ImageMetadata) interface{} { return nonNilStrings(img.Tags) },
+		"objects":            func(img *service.ImageMetadata) interface{} { return nonNilStrings(img.Objects) },
+		"visibility":         func(img *service.ImageMetadata) interface{} { return img.Visibility },
+		"workflow":           func(img *service.ImageMetadata) interface{} { return img.Workflow },
+		"aiProvider":         func(img *service.ImageMetadata) interface{} { return img.AIProvider },
+		"aiModel":            func(img *service.ImageMetadata) interface{} { return img.AIModel },
+		"promptVersion":      func(img *service.ImageMetadata) interface{} { return img.PromptVersion },
+		"externalId":         func(img *service.ImageMetadata) interface{} { return img.ExternalID },
+		"originalFilename":   func(img *service.ImageMetadata) interface{} { return img.OriginalFilename },
+		"mimeType":           func(img *service.ImageMetadata) interface{} { return img.MimeType },
+		"colorSpace":         func(img *service.ImageMetadata) interface{} { return img.ColorSpace },
+		"width":              func(img *service.ImageMetadata) interface{} { return img.Width },
+		"height":             func(img *service.ImageMetadata) interface{} { return img.Height },
+		"megapixels":         func(img *service.ImageMetadata) interface{} { return img.Megapixels },
+		"aspectRatio":        func(img *service.ImageMetadata) interface{} { return img.AspectRatio },
+		"triangles":          func(img *service.ImageMetadata) interface{} { return img.Triangles },
+		"lodTags":            func(img *service.ImageMetadata) interface{} { return nonNilStrings(img.LODTags) },
+		"uploadedAt":         func(img *service.ImageMetadata) interface{} { return graphQLTime(img.UploadedTime()) },
+		"capturedAt":         func(img *service.ImageMetadata) interface{} { return graphQLTime(img.CapturedTime()) },
+		"revision":           func(img *service.ImageMetadata) interface{} { return img.Revision },
+		"attributes":         func(img *service.ImageMetadata) interface{} { return img.Attributes },
+		"fileUrl":            func(img *service.ImageMetadata) interface{} { return dataURL(img.FilePath) },
+		"modelUrl":           func(img *service.ImageMetadata) interface{} { return dataURL(img.ModelFilePath) },
+		"thumbnailUrl":       func(img *service.ImageMetadata) interface{} { return dataURL(img.PreviewThumbnail()) },
+		"squareThumbnailUrl": func(img *service.ImageMetadata) interface{} { return dataURL(img.SquareThumbnail) },
+		"annotationCount":    func(img *service.ImageMetadata) interface{} { return h.annotations.Count(img.ID) },
+		"views":              func(img *service.ImageMetadata) interface{} { return h.counts(img.ID).Views },
+		"downloads":          func(img *service.ImageMetadata) interface{} { return h.counts(img.ID).Downloads },
+	} {
+		get := get
+		image.Fields[name] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			return get(source.(*service.ImageMetadata)), nil
+		}}
+	}
+	image.Fields["license"] = &graphql.Field{Type: license, Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*service.ImageMetadata).License, nil
+	}}
+	image.Fields["location"] = &graphql.Field{Type: location, Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*service.ImageMetadata).Location, nil
+	}}
+	image.Fields["project"] = &graphql.Field{Type: project, Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		id := source.(*service.ImageMetadata).Project
+		if id == "" {
+			return nil, nil
+		}
+		p, err := h.projects.Get(id)
+		if err != nil {
+			return nil, nil // project deleted since
+		}
+		return &p, nil
+	}}
+	image.Fields["renditions"] = &graphql.Field{
+		Type: rendition,
+		Args: map[string]graphql.Arg{"kind": {Type: graphql.String}},
+		Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			renditions := h.renditions.List(source.(*service.ImageMetadata))
+			if kind := args.String("kind"); kind != "" {
+				filtered := renditions[:0]
+				for _, r := range renditions {
+					if r.Kind == kind {
+						filtered = append(filtered, r)
+					}
+				}
+				renditions = filtered
+			}
+			items := make([]*models.Rendition, len(renditions))
+			for i := range renditions {
+				items[i] = &renditions[i]
+			}
+			return items, nil
+		},
+	}
+
+	// Rendition, License, Location
+	for name, get := range map[string]func(r *models.Rendition) interface{}{
+		"kind":      func(r *models.Rendition) interface{} { return r.Kind },
+		"path":      func(r *models.Rendition) interface{} { return r.Path },
+		"url":       func(r *models.Rendition) interface{} { return r.URL },
+		"width":     func(r *models.Rendition) interface{} { return r.Width },
+		"height":    func(r *models.Rendition) interface{} { return r.Height },
+		"size":      func(r *models.Rendition) interface{} { return r.Size },
+		"createdAt": func(r *models.Rendition) interface{} { return r.CreatedAt.UTC().Format(time.RFC3339) },
+	} {
+		get := get
+		rendition.Fields[name] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			return get(source.(*models.Rendition)), nil
+		}}
+	}
+	for name, get := range map[string]func(l *models.License) interface{}{
+		"type":         func(l *models.License) interface{} { return l.Type },
+		"rightsHolder": func(l *models.License) interface{} { return l.RightsHolder },
+		"expiresOn":    func(l *models.License) interface{} { return l.ExpiresOn },
+		"restrictions": func(l *models.License) interface{} { return l.Restrictions },
+		"expired":      func(l *models.License) interface{} { return l.Expired(time.Now()) },
+	} {
+		get := get
+		license.Fields[name] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			return get(source.(*models.License)), nil
+		}}
+	}
+	location.Fields["lat"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*models.GeoPoint).Lat, nil
+	}}
+	location.Fields["lon"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*models.GeoPoint).Lon, nil
+	}}
+
+	// Project: a named collection of images
+	for name, get := range map[string]func(p *models.Project) interface{}{
+		"id":                func(p *models.Project) interface{} { return p.ID },
+		"name":              func(p *models.Project) interface{} { return p.Name },
+		"description":       func(p *models.Project) interface{} { return p.Description },
+		"defaultTags":       func(p *models.Project) interface{} { return nonNilStrings(p.DefaultTags) },
+		"defaultVisibility": func(p *models.Project) interface{} { return p.DefaultVisibility },
+		"categories":        func(p *models.Project) interface{} { return nonNilStrings(p.Categories) },
+		"createdAt":         func(p *models.Project) interface{} { return p.CreatedAt.UTC().Format(time.RFC3339) },
+		"updatedAt":         func(p *models.Project) interface{} { return p.UpdatedAt.UTC().Format(time.RFC3339) },
+	} {
+		get := get
+		project.Fields[name] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			return get(source.(*models.Project)), nil
+		}}
+	}
+	project.Fields["images"] = &graphql.Field{Type: page, Args: pageArgs, Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		id := source.(*models.Project).ID
+		return imagePage(ctx, args, func(img *service.ImageMetadata) bool { return img.Project == id })
+	}}
+
+	// Category
+	category.Fields["path"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*graphQLCategory).path, nil
+	}}
+	category.Fields["name"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		path := source.(*graphQLCategory).path
+		return path[strings.LastIndex(path, "/")+1:], nil
+	}}
+	category.Fields["parent"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		path := source.(*graphQLCategory).path
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			return path[:i], nil
+		}
+		return nil, nil
+	}}
+	category.Fields["count"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*graphQLCategory).count, nil
+	}}
+	category.Fields["children"] = &graphql.Field{Type: category, Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return categories(ctx, source.(*graphQLCategory).path)
+	}}
+	category.Fields["images"] = &graphql.Field{Type: page, Args: pageArgs, Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		path := source.(*graphQLCategory).path
+		return imagePage(ctx, args, func(img *service.ImageMetadata) bool { return img.InCategory(path) })
+	}}
+
+	// ImagePage
+	page.Fields["total"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*graphQLPage).total, nil
+	}}
+	page.Fields["items"] = &graphql.Field{Type: image, Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*graphQLPage).items, nil
+	}}
+
+	// SearchResult
+	searchResult.Fields["score"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*models.SearchResult).RelevanceScore, nil
+	}}
+	searchResult.Fields["reason"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*models.SearchResult).Reason, nil
+	}}
+	searchResult.Fields["warnings"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return nonNilStrings(source.(*models.SearchResult).Warnings), nil
+	}}
+	searchResult.Fields["matches"] = &graphql.Field{Type: searchMatch, Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		matches := source.(*models.SearchResult).Matches
+		items := make([]*models.SearchMatch, len(matches))
+		for i := range matches {
+			items[i] = &matches[i]
+		}
+		return items, nil
+	}}
+	searchResult.Fields["image"] = &graphql.Field{Type: image, Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		_, byID, err := snapshotImages(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return byID[source.(*models.SearchResult).ImageID], nil
+	}}
+	searchMatch.Fields["field"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*models.SearchMatch).Field, nil
+	}}
+	searchMatch.Fields["value"] = &graphql.Field{Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+		return source.(*models.SearchMatch).Value, nil
+	}}
+
+	// Query
+	query.Fields["image"] = &graphql.Field{
+		Type: image,
+		Args: map[string]graphql.Arg{"id": {Type: graphql.ID, Required: true}},
+		Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			_, byID, err := snapshotImages(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return byID[args.String("id")], nil
+		},
+	}
+	imagesArgs := map[string]graphql.Arg{
+		"category":      {Type: graphql.String},
+		"subCategory":   {Type: graphql.String},
+		"project":       {Type: graphql.String},
+		"tag":           {Type: graphql.String},
+		"artist":        {Type: graphql.String},
+		"type":          {Type: graphql.String},
+		"visibility":    {Type: graphql.String},
+		"workflow":      {Type: graphql.String},
+		"aiModel":       {Type: graphql.String},
+		"promptVersion": {Type: graphql.String},
+	}
+	for name, arg := range pageArgs {
+		imagesArgs[name] = arg
+	}
+	query.Fields["images"] = &graphql.Field{
+		Type: page,
+		Args: imagesArgs,
+		Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			return imagePage(ctx, args, func(img *service.ImageMetadata) bool {
+				return (!args.Has("category") || img.InCategory(args.String("category"))) &&
+					(!args.Has("subCategory") || img.SubCategory == args.String("subCategory")) &&
+					(!args.Has("project") || img.Project == args.String("project")) &&
+					(!args.Has("tag") || hasTag(img, args.String("tag"))) &&
+					(!args.Has("artist") || strings.EqualFold(img.Artist, args.String("artist"))) &&
+					(!args.Has("type") || img.Type == args.String("type")) &&
+					(!args.Has("visibility") || img.Visibility == args.String("visibility")) &&
+					(!args.Has("workflow") || img.Workflow == args.String("workflow")) &&
+					(!args.Has("aiModel") || img.AIModel == args.String("aiModel")) &&
+					(!args.Has("promptVersion") || img.PromptVersion == args.String("promptVersion"))
+			})
+		},
+	}
+	query.Fields["categories"] = &graphql.Field{
+		Type: category,
+		Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			return categories(ctx, "")
+		},
+	}
+	query.Fields["category"] = &graphql.Field{
+		Type: category,
+		Args: map[string]graphql.Arg{"path": {Type: graphql.String, Required: true}},
+		Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			counts, err := categoryCounts(ctx)
+			if err != nil {
+				return nil, err
+			}
+			path := strings.Trim(args.String("path"), "/")
+			if counts[path] == 0 {
+				return nil, nil
+			}
+			return &graphQLCategory{path: path, count: counts[path]}, nil
+		},
+	}
+	query.Fields["projects"] = &graphql.Field{
+		Type: project,
+		Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			list := h.projects.List()
+			items := make([]*models.Project, len(list))
+			for i := range list {
+				items[i] = &list[i]
+			}
+			return items, nil
+		},
+	}
+	query.Fields["project"] = &graphql.Field{
+		Type: project,
+		Args: map[string]graphql.Arg{"id": {Type: graphql.ID, Required: true}},
+		Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			p, err := h.projects.Get(args.String("id"))
+			if err != nil {
+				return nil, nil
+			}
+			return &p, nil
+		},
+	}
+	query.Fields["search"] = &graphql.Field{
+		Type: searchResult,
+		Cost: 1,
+		Args: map[string]graphql.Arg{
+			"query": {Type: graphql.String, Required: true},
+			"limit": {Type: graphql.Int, Default: 20},
+		},
+		Resolve: func(ctx context.Context, source interface{}, args graphql.Args) (interface{}, error) {
+			if h.searchService == nil {
+				return nil, errors.New("search is not available")
+			}
+			limit := args.Int("limit")
+			if limit < 1 || limit > 100 {
+				return nil, errors.New("limit must be between 1 and 100")
+			}
+			resp, err := h.searchService.Search(ctx, args.String("query"), limit)
+			if err != nil {
+				return nil, fmt.Errorf("search failed: %v", err)
+			}
+			items := make([]*models.SearchResult, len(resp.Results))
+			for i := range resp.Results {
+				items[i] = &resp.Results[i]
+			}
+			return items, nil
+		},
+	}
+
+	return &graphql.Schema{Query: query, MaxCost: maxGraphQLSearches}
+}
+
+// imagePage returns the page of images matching filter, in index order
+func imagePage(ctx context.Context, args graphql.Args, filter func(img *service.ImageMetadata) bool) (*graphQLPage, error) {
+	images, _, err := snapshotImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matched []*service.ImageMetadata
+	for _, img := range images {
+		if filter(img) {
+			matched = append(matched, img)
+		}
+	}
+	items, err := paginate(matched, args)
+	if err != nil {
+		return nil, err
+	}
+	return &graphQLPage{total: len(matched), items: items}, nil
+}
+
+// categoryCounts counts the images under every category path. An image in
+// a/b counts for both a and a/b.
+func categoryCounts(ctx context.Context) (map[string]int, error) {
+	images, _, err := snapshotImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, img := range images {
+		path := strings.Trim(img.Category, "/")
+		for path != "" {
+			counts[path]++
+			i := strings.LastIndex(path, "/")
+			if i < 0 {
+				break
+			}
+			path = path[:i]
+		}
+	}
+	return counts, nil
+}
+
+// categories returns the direct children of parent, or the primary
+// categories if parent is empty, sorted by path
+func categories(ctx context.Context, parent string) ([]*graphQLCategory, error) {
+	counts, err := categoryCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result []*graphQLCategory
+	for path, count := range counts {
+		i := strings.LastIndex(path, "/")
+		if (i < 0 && parent == "") || (i >= 0 && path[:i] == parent) {
+			result = append(result, &graphQLCategory{path: path, count: count})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].path < result[j].path })
+	return result, nil
+}
+
+// counts returns the popularity of an image, zero if it was never served
+func (h *GraphQLHandler) counts(imageID string) models.Popularity {
+	if p := h.popularity.Get(imageID); p != nil {
+		return *p
+	}
+	return models.Popularity{}
+}
+
+// nonNilStrings makes absent lists encode as [] rather than null
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// dataURL returns the /data/ URL of a data-relative path, nil if empty
+func dataURL(path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	return service.DataURL(path)
+}
+
+// graphQLTime formats an index timestamp as RFC 3339, nil if unset
+func graphQLTime(t time.Time, err error) interface{} {
+	if err != nil {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
@@ -2,13 +2,27 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
 	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
 )
 
 func TestHealthHandler_HandleHealth(t *testing.T) {
@@ -67,7 +81,7 @@ func TestSearchHandler_HandleSearch_Success(t *testing.T) {
 }
 
 func TestSearchHandler_HandleSearch_InvalidJSON(t *testing.T) {
-	handler := NewSearchHandler(nil)
+	handler := NewSearchHandler(nil, "")
 
 	// Send invalid JSON
 	req := httptest.NewRequest(http.MethodPost, "/search", strings.NewReader("invalid json"))
@@ -84,7 +98,7 @@ func TestSearchHandler_HandleSearch_InvalidJSON(t *testing.T) {
 }
 
 func TestSearchHandler_HandleSearch_EmptyQuery(t *testing.T) {
-	handler := NewSearchHandler(nil)
+	handler := NewSearchHandler(nil, "")
 
 	// Send request with empty query
 	reqBody := models.SearchRequest{
@@ -195,7 +209,7 @@ func TestNewHealthHandler(t *testing.T) {
 }
 
 func TestNewSearchHandler(t *testing.T) {
-	handler := NewSearchHandler(nil)
+	handler := NewSearchHandler(nil, "")
 
 	if handler == nil {
 		t.Fatal("NewSearchHandler returned nil")
@@ -244,3 +258,1336 @@ func TestSearchHandler_ContentType(t *testing.T) {
 		t.Errorf("expected Content-Type %s, got %s", expectedContentType, contentType)
 	}
 }
+
+func TestUploadHandler_IdempotentReplay(t *testing.T) {
+	imageService := service.NewImageService(nil, nil, nil, nil, logrus.New())
+	idempotency := service.NewIdempotencyStore(time.Hour)
+	handler := NewUploadHandler(nil, imageService, idempotency, nil, 1024)
+
+	idempotency.Remember(service.IdempotencyKey{Caller: "ci", Route: "/api/v1/images/upload", Key: "retry-123"}, "img-001")
+
+	upload := func(caller string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/images/upload", nil)
+		req.Header.Set("Idempotency-Key", "retry-123")
+		req.Header.Set("X-Actor", caller)
+		w := httptest.NewRecorder()
+		middleware.Auth(nil)(http.HandlerFunc(handler.Handle2DUpload)).ServeHTTP(w, req)
+		return w
+	}
+
+	// Another caller's key is not replayed
+	if w := upload("other"); w.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("expected another caller's key not to be replayed")
+	}
+
+	w := upload("ci")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for replay, got %d", w.Code)
+	}
+	if w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected Idempotent-Replayed header")
+	}
+
+	var response map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response["id"] != "img-001" {
+		t.Errorf("expected original id img-001, got %v", response["id"])
+	}
+}
+
+func TestToGridImage(t *testing.T) {
+	img2D := &service.ImageMetadata{
+		ID:            "img-001",
+		Title:         "Sunset",
+		Category:      "landscapes",
+		ThumbnailPath: "categories/landscapes/img-001_thumb.jpg",
+		Description:   "long description that the grid view drops",
+	}
+	grid := toGridImage(img2D)
+	if grid.ThumbnailURL != "/data/categories/landscapes/img-001_thumb.jpg" {
+		t.Errorf("unexpected 2D thumbnail URL %s", grid.ThumbnailURL)
+	}
+
+	img3D := &service.ImageMetadata{
+		ID:       "obj-001",
+		Title:    "Robot",
+		Category: "sculpture",
+		Type:     "3D",
+		Views: map[string]string{
+			"front": "categories/sculpture/obj-001/front.png",
+			"back":  "categories/sculpture/obj-001/back.png",
+		},
+	}
+	grid = toGridImage(img3D)
+	if grid.ThumbnailURL != "/data/categories/sculpture/obj-001/front_thumb.jpg" {
+		t.Errorf("unexpected 3D thumbnail URL %s", grid.ThumbnailURL)
+	}
+}
+
+func TestProjectFields(t *testing.T) {
+	images := []*service.ImageMetadata{
+		{ID: "img-001", Title: "Sunset", Artist: "Alice", Category: "landscapes"},
+	}
+
+	projected := projectFields(images, parseFields("title, artist"))
+	if len(projected) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(projected))
+	}
+	item := projected[0]
+	if item["id"] != "img-001" || item["title"] != "Sunset" || item["artist"] != "Alice" {
+		t.Errorf("unexpected projection %v", item)
+	}
+	if _, ok := item["category"]; ok {
+		t.Error("unrequested field category should be dropped")
+	}
+}
+
+func TestWriteConditionalJSON_ETag(t *testing.T) {
+	payload := map[string]interface{}{"total": 3}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/images", nil)
+	w := httptest.NewRecorder()
+	writeConditionalJSON(w, req, payload, time.Time{})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag header")
+	}
+
+	// Same content with matching If-None-Match is not re-sent
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/images", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	writeConditionalJSON(w, req, payload, time.Time{})
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Error("304 response should have no body")
+	}
+
+	// Changed content gets a fresh response
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/images", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	writeConditionalJSON(w, req, map[string]interface{}{"total": 4}, time.Time{})
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for changed content, got %d", w.Code)
+	}
+}
+
+func TestWriteConditionalJSON_IfModifiedSince(t *testing.T) {
+	lastModified := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/images", nil)
+	req.Header.Set("If-Modified-Since", lastModified.Format(http.TimeFormat))
+	w := httptest.NewRecorder()
+	writeConditionalJSON(w, req, map[string]int{"total": 1}, lastModified)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/images", nil)
+	req.Header.Set("If-Modified-Since", lastModified.Add(-time.Hour).Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	writeConditionalJSON(w, req, map[string]int{"total": 1}, lastModified)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for stale client copy, got %d", w.Code)
+	}
+}
+
+func TestAnalyzeHandler_NoImage(t *testing.T) {
+	handler := NewAnalyzeHandler(nil, nil, 1024)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "no file")
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/analyze", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	handler.HandleAnalyze(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without an image, got %d", w.Code)
+	}
+}
+
+func TestCategorizeHandler_NoImage(t *testing.T) {
+	handler := NewAnalyzeHandler(nil, nil, 1024)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("categories", "animals,products")
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/categorize", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	handler.HandleCategorize(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without an image, got %d", w.Code)
+	}
+}
+
+func TestParseDimensionFilter(t *testing.T) {
+	query, _ := url.ParseQuery("min_width=3840&min_height=2160&aspect_ratio=16:9&min_megapixels=8")
+	filter, err := parseDimensionFilter(query)
+	if err != nil {
+		t.Fatalf("parseDimensionFilter failed: %v", err)
+	}
+	if filter.MinWidth != 3840 || filter.MinHeight != 2160 || filter.MinMegapixels != 8 || filter.AspectRatio < 1.77 || filter.AspectRatio > 1.78 {
+		t.Errorf("unexpected filter %+v", filter)
+	}
+
+	for _, bad := range []string{"min_width=wide", "aspect_ratio=16:0", "min_width=200&max_width=100"} {
+		query, _ := url.ParseQuery(bad)
+		if _, err := parseDimensionFilter(query); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	images := []*service.ImageMetadata{
+		{ID: "a", Category: "animals/cats", Project: "p1", Quality: &models.Quality{Score: 80}},
+		{ID: "b", Category: "animals/dogs", Project: "p1", Quality: &models.Quality{Score: 40}},
+		{ID: "c", Category: "landscapes", Project: "p2"},
+	}
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "a,b,c"},
+		{"category=animals", "a,b"},
+		{"category=animals&project=p1&min_quality=50", "a"},
+		{"prompt_version_not=v2", "a,b,c"},
+		{"project=p3", ""},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		filters, err := listFilters(query, time.Now())
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		var ids []string
+		for _, img := range images {
+			if matchesAll(filters, img) {
+				ids = append(ids, img.ID)
+			}
+		}
+		if got := strings.Join(ids, ","); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"min_quality=101", "min_aesthetic=x", "min_confidence=0.5", "min_width=wide"} {
+		query, _ := url.ParseQuery(bad)
+		if _, err := listFilters(query, time.Now()); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestParsePolygonFilter(t *testing.T) {
+	query, _ := url.ParseQuery("max_tris=20000&lod=Game_Ready")
+	filter, err := parsePolygonFilter(query)
+	if err != nil {
+		t.Fatalf("parsePolygonFilter failed: %v", err)
+	}
+	if filter.MaxTriangles != 20000 || filter.MinTriangles != 0 || filter.LOD != "game-ready" {
+		t.Errorf("unexpected filter %+v", filter)
+	}
+
+	for _, bad := range []string{"max_tris=lots", "min_tris=-1", "min_tris=500&max_tris=100"} {
+		query, _ := url.ParseQuery(bad)
+		if _, err := parsePolygonFilter(query); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestDataHandler_CountsOriginals(t *testing.T) {
+	dataDir := t.TempDir()
+	index := service.NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	img := &models.Image{ID: "img-1", Title: "T", Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		FilePath: "categories/animals/img-1.jpg", ThumbnailPath: "categories/animals/img-1_thumb.jpg"}
+	if err := index.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dataDir, "categories", "animals"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"img-1.jpg", "img-1_thumb.jpg"} {
+		if err := os.WriteFile(filepath.Join(dataDir, "categories", "animals", name), []byte("jpeg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	popularity := service.NewPopularityStore(filepath.Join(dataDir, "popularity.json"))
+	handler := NewDataHandler(service.NewStorageService(dataDir), nil, index, popularity, nil, nil)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d", target, w.Code)
+		}
+		return w
+	}
+
+	get("/categories/animals/img-1.jpg")
+	get("/categories/animals/img-1_thumb.jpg")
+	w := get("/categories/animals/img-1.jpg?download=1")
+	if disposition := w.Header().Get("Content-Disposition"); disposition != `attachment; filename=img-1.jpg` {
+		t.Errorf("unexpected Content-Disposition %q", disposition)
+	}
+
+	counts := popularity.Get("img-1")
+	if counts == nil || counts.Views != 1 || counts.Downloads != 1 || counts.LastAccessed == nil {
+		t.Errorf("expected one view and one download, got %+v", counts)
+	}
+}
+
+func TestDataHandler_DownloadOriginalFilename(t *testing.T) {
+	dataDir := t.TempDir()
+	index := service.NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	img := &models.Image{ID: "img-1", Title: "T", Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		FilePath: "categories/animals/img-1.jpg", ThumbnailPath: "categories/animals/img-1_thumb.jpg", OriginalFilename: "Sunset at the pier.jpg"}
+	if err := index.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dataDir, "categories", "animals"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "categories", "animals", "img-1.jpg"), []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewDataHandler(service.NewStorageService(dataDir), nil, index, service.NewPopularityStore(filepath.Join(dataDir, "popularity.json")), nil, nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/categories/animals/img-1.jpg?download=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); disposition != `attachment; filename="Sunset at the pier.jpg"` {
+		t.Errorf("unexpected Content-Disposition %q", disposition)
+	}
+}
+
+// newDataTestIndex indexes img-1 and writes its original and thumbnail, an
+// unindexed file next to them and the files /data/ must never serve
+func newDataTestIndex(t *testing.T) (string, *service.IndexService) {
+	dataDir := t.TempDir()
+	index := service.NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	img := &models.Image{ID: "img-1", Title: "T", Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		FilePath: "categories/animals/img-1.jpg", ThumbnailPath: "categories/animals/img-1_thumb.jpg"}
+	if err := index.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	for _, dir := range []string{"categories/animals", "temp"} {
+		if err := os.MkdirAll(filepath.Join(dataDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"categories/animals/img-1.jpg", "categories/animals/img-1_thumb.jpg", "categories/animals/stray.jpg", "temp/upload.jpg"} {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte("jpeg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dataDir, index
+}
+
+func TestDataHandler_OnlyIndexedFiles(t *testing.T) {
+	dataDir, index := newDataTestIndex(t)
+	handler := NewDataHandler(service.NewStorageService(dataDir), nil, index, service.NewPopularityStore(filepath.Join(dataDir, "popularity.json")), nil, nil)
+
+	for target, want := range map[string]int{
+		"/categories/animals/img-1_thumb.jpg":        http.StatusOK,
+		"/index.md":                                  http.StatusNotFound,
+		"/popularity.json":                           http.StatusNotFound,
+		"/temp/upload.jpg":                           http.StatusNotFound,
+		"/categories/animals/stray.jpg":              http.StatusNotFound,
+		"/categories/animals":                        http.StatusNotFound,
+		"/categories/animals/../../index.md":         http.StatusNotFound,
+		"/categories/animals/%2e%2e/%2e%2e/index.md": http.StatusNotFound,
+		"/categories//animals/img-1.jpg":             http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != want {
+			t.Errorf("GET %s: expected %d, got %d", target, want, w.Code)
+		}
+	}
+}
+
+func TestDataHandler_ThumbnailCache(t *testing.T) {
+	dataDir, index := newDataTestIndex(t)
+	storage := service.NewStorageService(dataDir)
+	storage.SetThumbnailCache(service.NewThumbnailCache(1 << 20))
+	handler := NewDataHandler(storage, nil, index, service.NewPopularityStore(filepath.Join(dataDir, "popularity.json")), nil, nil)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/categories/animals/img-1_thumb.jpg", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "jpeg" || w.Header().Get("Content-Type") != "image/jpeg" {
+			t.Fatalf("unexpected response %d %q (%s)", w.Code, w.Body.String(), w.Header().Get("Content-Type"))
+		}
+	}
+	// Originals are read from disk
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/categories/animals/img-1.jpg", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the original, got %d", w.Code)
+	}
+
+	if stats := storage.ThumbnailCache().Stats(); stats.Entries != 1 || stats.Misses != 1 || stats.Hits != 2 {
+		t.Errorf("expected one miss and two hits, got %+v", stats)
+	}
+}
+
+func TestDataHandler_CacheControl(t *testing.T) {
+	dataDir, index := newDataTestIndex(t)
+	handler := NewDataHandler(service.NewStorageService(dataDir), nil, index, service.NewPopularityStore(filepath.Join(dataDir, "popularity.json")), nil, nil)
+
+	cacheControl := func(target string) string {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d", target, w.Code)
+		}
+		return w.Header().Get("Cache-Control")
+	}
+
+	if got := cacheControl("/categories/animals/img-1_thumb.jpg"); got != "public, max-age=86400" {
+		t.Errorf("unexpected rendition Cache-Control %q", got)
+	}
+	if got := cacheControl("/categories/animals/img-1_thumb.jpg?v=123"); got != "public, max-age=31536000, immutable" {
+		t.Errorf("unexpected versioned rendition Cache-Control %q", got)
+	}
+	if got := cacheControl("/categories/animals/img-1.jpg"); got != "" {
+		t.Errorf("expected no Cache-Control on originals, got %q", got)
+	}
+
+	// Behind a CDN, links point at it and originals are revalidated
+	service.SetCDNBaseURL("https://cdn.example.com")
+	defer service.SetCDNBaseURL("")
+	if got := cacheControl("/categories/animals/img-1.jpg"); got != "no-cache" {
+		t.Errorf("expected originals to be revalidated behind a CDN, got %q", got)
+	}
+	img, _ := index.GetImageByID("img-1")
+	if grid := toGridImage(img); grid.ThumbnailURL != "https://cdn.example.com/data/categories/animals/img-1_thumb.jpg" {
+		t.Errorf("expected the thumbnail on the CDN, got %s", grid.ThumbnailURL)
+	}
+	if links := metadataLinks("https://warehouse.example.com", img); links.File != "https://cdn.example.com/data/categories/animals/img-1.jpg" || !strings.HasPrefix(links.Self, "https://warehouse.example.com/") {
+		t.Errorf("unexpected links %+v", links)
+	}
+}
+
+func TestDataHandler_SignedURLs(t *testing.T) {
+	dataDir, index := newDataTestIndex(t)
+	handler := NewDataHandler(service.NewStorageService(dataDir), nil, index, service.NewPopularityStore(filepath.Join(dataDir, "popularity.json")),
+		service.NewURLSigner("secret", time.Hour), nil)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/images/{id}/signed-url", handler.HandleSignedURL).Methods("GET")
+	router.PathPrefix("/data/").Handler(http.StripPrefix("/data/", handler))
+	tokens := map[string]models.Principal{"alice-token": {Name: "alice", Role: models.RoleViewer, Authenticated: true}}
+	server := middleware.Auth(tokens)(router)
+
+	do := func(target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("/data/categories/animals/img-1.jpg", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for an unsigned anonymous request, got %d", w.Code)
+	}
+	if w := do("/data/categories/animals/img-1.jpg", "alice-token"); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a token holder, got %d", w.Code)
+	}
+	if w := do("/api/v1/images/img-1/signed-url", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for anonymous signing, got %d", w.Code)
+	}
+	if w := do("/api/v1/images/img-1/signed-url?path=categories/animals/stray.jpg", "alice-token"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a file of no image, got %d", w.Code)
+	}
+
+	w := do("/api/v1/images/img-1/signed-url?path=categories/animals/img-1_thumb.jpg&ttl=10m", "alice-token")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var response struct {
+		URL       string    `json:"url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if d := time.Until(response.ExpiresAt); d <= 0 || d > 10*time.Minute {
+		t.Errorf("unexpected expiry %v", response.ExpiresAt)
+	}
+	if w := do(response.URL, ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a signed URL, got %d", w.Code)
+	}
+	// The signature covers the path
+	if w := do(strings.Replace(response.URL, "img-1_thumb.jpg", "img-1.jpg", 1), ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a signature of another file, got %d", w.Code)
+	}
+}
+
+func TestGalleryHandler_GuestToken(t *testing.T) {
+	dataDir, index := newDataTestIndex(t)
+	guestTokens := service.NewGuestTokenStore(filepath.Join(dataDir, "guest_tokens.json"))
+	_, animals, _ := guestTokens.Create(service.GuestToken{Name: "Animals", Category: "animals"})
+	_, landscape, _ := guestTokens.Create(service.GuestToken{Name: "Landscape", Category: "landscape"})
+
+	// A private image in the token's category is neither listed nor served
+	private := &models.Image{ID: "img-2", Title: "P", Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		Visibility: models.VisibilityPrivate, FilePath: "categories/animals/img-2.jpg", ThumbnailPath: "categories/animals/img-2_thumb.jpg"}
+	if err := index.AppendToIndex(private); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, private.ThumbnailPath), []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data := NewDataHandler(service.NewStorageService(dataDir), nil, index, service.NewPopularityStore(filepath.Join(dataDir, "popularity.json")),
+		service.NewURLSigner("secret", time.Hour), guestTokens)
+	gallery := NewGalleryHandler(index, service.NewProjectStore(filepath.Join(dataDir, "projects.json")), guestTokens)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/gallery", gallery.HandleGallery).Methods("GET")
+	router.PathPrefix("/data/").Handler(http.StripPrefix("/data/", data))
+	server := middleware.Auth(map[string]models.Principal{"key": {Name: "alice", Role: models.RoleAdmin, Authenticated: true}})(router)
+
+	do := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	if w := do("/api/v1/gallery?token=gt_unknown"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unknown token, got %d", w.Code)
+	}
+	w := do("/api/v1/gallery?token=" + animals)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("expected an embeddable 200, got %d", w.Code)
+	}
+	var response struct {
+		Images []GridImage `json:"images"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Images) != 1 || response.Images[0].ThumbnailURL != "/data/categories/animals/img-1_thumb.jpg?token="+animals {
+		t.Fatalf("unexpected gallery %+v", response.Images)
+	}
+
+	if w := do(response.Images[0].ThumbnailURL); w.Code != http.StatusOK {
+		t.Errorf("expected the gallery's thumbnail to be served, got %d", w.Code)
+	}
+	if w := do("/data/categories/animals/img-1.jpg?token=" + landscape); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a token of another category, got %d", w.Code)
+	}
+	if w := do("/data/categories/animals/img-2_thumb.jpg?token=" + animals); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a private image, got %d", w.Code)
+	}
+}
+
+func TestDataHandler_GuestTokensWithoutSigning(t *testing.T) {
+	dataDir, index := newDataTestIndex(t)
+	guestTokens := service.NewGuestTokenStore(filepath.Join(dataDir, "guest_tokens.json"))
+	_, animals, _ := guestTokens.Create(service.GuestToken{Name: "Animals", Category: "animals"})
+	_, landscape, _ := guestTokens.Create(service.GuestToken{Name: "Landscape", Category: "landscape"})
+
+	data := NewDataHandler(service.NewStorageService(dataDir), nil, index, service.NewPopularityStore(filepath.Join(dataDir, "popularity.json")), nil, guestTokens)
+	gallery := NewGalleryHandler(index, service.NewProjectStore(filepath.Join(dataDir, "projects.json")), guestTokens)
+	router := mux.NewRouter()
+	router.PathPrefix("/data/").Handler(http.StripPrefix("/data/", data))
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(middleware.RequireCaller("/api/v1/gallery"))
+	api.HandleFunc("/gallery", gallery.HandleGallery).Methods("GET")
+	api.HandleFunc("/images", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	server := middleware.Auth(map[string]models.Principal{"key": {Name: "alice", Role: models.RoleViewer, Authenticated: true}})(router)
+
+	do := func(target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	// A guest token is checked even with anonymous access on
+	if w := do("/data/categories/animals/img-1.jpg?token="+landscape, ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a token of another category, got %d", w.Code)
+	}
+	if w := do("/data/categories/animals/img-1.jpg", ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for an anonymous caller, got %d", w.Code)
+	}
+
+	data.SetAnonymousAccess(false)
+	if w := do("/data/categories/animals/img-1.jpg", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a guest token, got %d", w.Code)
+	}
+	if w := do("/data/categories/animals/img-1.jpg?token="+animals, ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a guest token covering the image, got %d", w.Code)
+	}
+	if w := do("/data/categories/animals/img-1.jpg", "key"); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a token holder, got %d", w.Code)
+	}
+
+	// Guests reach their gallery but not the rest of the API
+	if w := do("/api/v1/gallery?token="+animals, ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for the gallery, got %d", w.Code)
+	}
+	if w := do("/api/v1/images?token="+animals, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an anonymous listing, got %d", w.Code)
+	}
+	if w := do("/api/v1/images", "key"); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a token holder's listing, got %d", w.Code)
+	}
+}
+
+func TestWithGuestToken_OnlyDataURLs(t *testing.T) {
+	grid := withGuestToken(GridImage{ThumbnailURL: "/data/a_thumb.jpg", TurntableURL: "/api/v1/images/a/turntable"}, "gt_x")
+	if grid.ThumbnailURL != "/data/a_thumb.jpg?token=gt_x" || grid.TurntableURL != "/api/v1/images/a/turntable" {
+		t.Errorf("unexpected URLs %+v", grid)
+	}
+}
+
+func TestFavoritesHandler_PerCaller(t *testing.T) {
+	dataDir := t.TempDir()
+	index := service.NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	img := &models.Image{ID: "img-1", Title: "T", Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()}
+	if err := index.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	handler := NewFavoritesHandler(index, service.NewFavoriteStore(filepath.Join(dataDir, "favorites.json")))
+	router := mux.NewRouter()
+	router.HandleFunc("/images/{id}/favorite", handler.HandleAddFavorite).Methods("POST")
+	router.HandleFunc("/favorites", handler.HandleListFavorites).Methods("GET")
+	tokens := map[string]models.Principal{
+		"alice-token": {Name: "alice", Role: models.RoleViewer, Authenticated: true},
+		"bob-token":   {Name: "bob", Role: models.RoleViewer, Authenticated: true},
+	}
+	server := middleware.Auth(tokens)(router)
+
+	do := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/images/img-1/favorite", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for anonymous callers, got %d", w.Code)
+	}
+	if w := do("POST", "/images/missing/favorite", "alice-token"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown image, got %d", w.Code)
+	}
+	if w := do("POST", "/images/img-1/favorite", "alice-token"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	count := func(token string) int {
+		var response struct {
+			Total int `json:"total"`
+		}
+		json.NewDecoder(do("GET", "/favorites", token).Body).Decode(&response)
+		return response.Total
+	}
+	if got := count("alice-token"); got != 1 {
+		t.Errorf("expected 1 favorite for alice, got %d", got)
+	}
+	if got := count("bob-token"); got != 0 {
+		t.Errorf("expected no favorites for bob, got %d", got)
+	}
+}
+
+func TestUploadSessionsHandler_Track(t *testing.T) {
+	handler := NewUploadSessionsHandler(service.NewUploadSessionStore(time.Hour))
+	var midway service.UploadSession
+	upload := func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 4)
+		r.Body.Read(buf)
+		midway, _ = handler.sessions.Get(r.Header.Get(UploadSessionHeader))
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/uploads", handler.HandleCreateSession).Methods("POST")
+	router.HandleFunc("/uploads/{session}", handler.HandleGetSession).Methods("GET")
+	router.HandleFunc("/upload", handler.Track(upload)).Methods("POST")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/uploads", nil))
+	var created service.UploadSession
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&created) != nil || created.ID == "" {
+		t.Fatalf("expected a created session, got %d %s", w.Code, w.Body.String())
+	}
+
+	send := func(session string) int {
+		req := httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789"))
+		req.Header.Set(UploadSessionHeader, session)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := send(created.ID); code != http.StatusAccepted {
+		t.Fatalf("expected the upload to pass through, got %d", code)
+	}
+	if midway.State != service.UploadSessionReceiving || midway.Received != 4 || midway.Total != 10 {
+		t.Errorf("unexpected progress during the upload: %+v", midway)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/uploads/"+created.ID, nil))
+	var done service.UploadSession
+	json.NewDecoder(w.Body).Decode(&done)
+	if done.State != service.UploadSessionComplete || done.Received != 10 || done.Percent != 100 || done.StatusCode != http.StatusAccepted {
+		t.Errorf("unexpected final progress: %+v", done)
+	}
+
+	if code := send(created.ID); code != http.StatusConflict {
+		t.Errorf("expected 409 for a reused session, got %d", code)
+	}
+	if code := send("unknown"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown session, got %d", code)
+	}
+}
+
+func TestWriteQueueFull(t *testing.T) {
+	imageService := service.NewImageService(nil, nil, nil, nil, logrus.New())
+	imageService.SetQueueCapacity(5)
+
+	w := httptest.NewRecorder()
+	writeQueueFull(w, imageService)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "5" {
+		t.Errorf("expected Retry-After 5 before any job finished, got %q", w.Header().Get("Retry-After"))
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if body["queue_capacity"] != float64(5) || body["queue_length"] != float64(0) {
+		t.Errorf("unexpected body %v", body)
+	}
+}
+
+func TestDashboardHandler(t *testing.T) {
+	dataDir := t.TempDir()
+	storage := service.NewStorageService(dataDir)
+	if err := storage.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	imageService := service.NewImageService(storage, nil, service.NewIndexService(dataDir), nil, logrus.New())
+	handler := NewDashboardHandler(imageService, storage)
+
+	w := httptest.NewRecorder()
+	handler.HandlePage(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), "/admin/stats") {
+		t.Errorf("unexpected page: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	w = httptest.NewRecorder()
+	handler.HandleStats(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/stats", nil))
+	var stats map[string]json.RawMessage
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+	for _, key := range []string{"queue", "running", "recent_failures", "ai_usage", "storage"} {
+		if _, ok := stats[key]; !ok {
+			t.Errorf("expected %s in the stats", key)
+		}
+	}
+
+	for body, want := range map[string]int{`{"workers": 3}`: http.StatusOK, `{"workers": 0}`: http.StatusBadRequest, `nope`: http.StatusBadRequest} {
+		w = httptest.NewRecorder()
+		handler.HandleScaleWorkers(w, httptest.NewRequest(http.MethodPut, "/api/v1/admin/workers", strings.NewReader(body)))
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", body, want, w.Code)
+		}
+	}
+	if workers := imageService.Metrics().Workers; workers != 3 {
+		t.Errorf("expected 3 workers, got %d", workers)
+	}
+}
+
+func TestUploadHandler_HandlePage(t *testing.T) {
+	handler := NewUploadHandler(nil, nil, nil, nil, 1<<20)
+	w := httptest.NewRecorder()
+	handler.HandlePage(w, httptest.NewRequest(http.MethodGet, "/upload", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("unexpected page: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, endpoint := range []string{"/images/upload", "/images/upload-3d"} {
+		if !strings.Contains(body, "'"+endpoint+"'") {
+			t.Errorf("expected the page to post to %s", endpoint)
+		}
+	}
+}
+
+func TestIngestHandler(t *testing.T) {
+	dataDir := t.TempDir()
+	storage := service.NewStorageService(dataDir)
+	if err := storage.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	imageService := service.NewImageService(storage, nil, service.NewIndexService(dataDir), nil, logrus.New())
+	uploads := NewUploadHandler(storage, imageService, service.NewIdempotencyStore(time.Hour), nil, 1024)
+	handler := NewIngestHandler(uploads, service.NewImageDownloader(time.Minute, 1024, true))
+
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, "\x89PNG\r\n\x1a\nfrom-url")
+	}))
+	defer source.Close()
+
+	send := func(body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/images/ingest", strings.NewReader(body))
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		handler.HandleIngest(w, req)
+		return w
+	}
+	queued := func(w *httptest.ResponseRecorder) *models.Image {
+		t.Helper()
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+		}
+		var response map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		img, err := imageService.GetStatus(response["id"].(string))
+		if err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+		return img
+	}
+
+	// Base64 as a data URI, tags as a comma-separated string
+	png := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\nfrom-base64"))
+	img := queued(send(`{"image_base64": "data:image/png;base64,`+png+`", "title": "Sketch", "artist": "Ada", "tags": "ink, draft,", "visibility": "private", "attributes": {"client": "Acme"}}`, nil))
+	if img.Title != "Sketch" || img.Visibility != "private" || strings.Join(img.ManualTags, ",") != "ink,draft" || img.Attributes["client"] != "Acme" {
+		t.Errorf("unexpected status %+v", img)
+	}
+
+	// URL, tags as an array; the file is named after the URL
+	queued(send(`{"image_url": "`+source.URL+`/files/abc", "title": "Photo", "artist": "Ada", "tags": ["cat"]}`, nil))
+	entries, _ := os.ReadDir(filepath.Join(dataDir, "temp"))
+	var names []string
+	for _, entry := range entries {
+		names = append(names, filepath.Ext(entry.Name()))
+	}
+	if len(names) != 2 || names[0] != ".png" || names[1] != ".png" {
+		t.Errorf("expected two .png temp files, got %v", names)
+	}
+
+	// The idempotency key may be sent in the body
+	body := `{"image_base64": "` + png + `", "filename": "a.png", "title": "T", "artist": "A", "idempotency_key": "zap-1"}`
+	first := queued(send(body, nil))
+	replay := send(body, nil)
+	if replay.Code != http.StatusOK || !strings.Contains(replay.Body.String(), first.ID) {
+		t.Errorf("expected a replay of %s, got %d: %s", first.ID, replay.Code, replay.Body.String())
+	}
+
+	for body, want := range map[string]int{
+		`nope`:                            http.StatusBadRequest,
+		`{"title": "T", "artist": "A"}`:   http.StatusBadRequest,
+		`{"image_base64": "` + png + `"}`: http.StatusBadRequest,
+		`{"image_base64": "%%%", "title": "T", "artist": "A"}`:                                                           http.StatusBadRequest,
+		`{"image_base64": "` + png + `", "title": "T", "artist": "A", "tags": 5}`:                                        http.StatusBadRequest,
+		`{"image_base64": "` + png + `", "image_url": "` + source.URL + `", "title": "T", "artist": "A"}`:                http.StatusBadRequest,
+		`{"image_url": "ftp://example.com/a.png", "title": "T", "artist": "A"}`:                                          http.StatusBadRequest,
+		`{"image_base64": "` + base64.StdEncoding.EncodeToString(make([]byte, 2048)) + `", "title": "T", "artist": "A"}`: http.StatusRequestEntityTooLarge,
+	} {
+		if w := send(body, nil); w.Code != want {
+			t.Errorf("%.60s: expected %d, got %d", body, want, w.Code)
+		}
+	}
+}
+
+func TestDesignHandler_HandleExport(t *testing.T) {
+	dataDir := t.TempDir()
+	storage := service.NewStorageService(dataDir)
+	if err := storage.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	imageService := service.NewImageService(storage, nil, service.NewIndexService(dataDir), nil, logrus.New())
+	handler := NewDesignHandler(NewUploadHandler(storage, imageService, service.NewIdempotencyStore(time.Hour), nil, 1024))
+
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.HandleExport(w, httptest.NewRequest(http.MethodPost, "/api/v1/design/frames", strings.NewReader(body)))
+		return w
+	}
+	png := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\nframe"))
+
+	w := send(`{"document_id": "AbC123", "document_name": "Checkout", "version": "v7", "artist": "Design Team", "tags": ["checkout"], "frames": [
+		{"node_id": "1:2", "name": "Login", "page": "Screens", "image_base64": "` + png + `"},
+		{"node_id": "1:3", "name": "Cart", "url": "https://www.figma.com/design/AbC123/Checkout?node-id=1-3", "image_base64": "data:image/png;base64,` + png + `"}]}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		DocumentID string `json:"document_id"`
+		Frames     []struct {
+			NodeID string `json:"node_id"`
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"frames"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.DocumentID != "AbC123" || len(response.Frames) != 2 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+
+	login, err := imageService.GetStatus(response.Frames[0].ID)
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	expected := models.DesignSource{Tool: "figma", DocumentID: "AbC123", DocumentName: "Checkout", NodeID: "1:2", NodeName: "Login", Page: "Screens", Version: "v7", URL: "https://www.figma.com/design/AbC123?node-id=1-2"}
+	if login.Title != "Login" || login.Artist != "Design Team" || login.DesignSource == nil || *login.DesignSource != expected {
+		t.Errorf("unexpected frame status %+v (source %+v)", login, login.DesignSource)
+	}
+	cart, _ := imageService.GetStatus(response.Frames[1].ID)
+	if cart == nil || cart.DesignSource.URL != "https://www.figma.com/design/AbC123/Checkout?node-id=1-3" {
+		t.Errorf("expected the given deep link to be kept, got %+v", cart)
+	}
+
+	// A bad frame rejects the whole export
+	for _, body := range []string{
+		`{"document_id": "AbC123", "artist": "A", "frames": []}`,
+		`{"artist": "A", "frames": [{"node_id": "1:2", "name": "Login", "image_base64": "` + png + `"}]}`,
+		`{"document_id": "AbC123", "frames": [{"node_id": "1:2", "name": "Login", "image_base64": "` + png + `"}]}`,
+		`{"document_id": "AbC123", "artist": "A", "frames": [{"node_id": "1:2", "name": "Login", "image_base64": "` + png + `"}, {"node_id": "1:3", "name": "Cart"}]}`,
+		`{"document_id": "AbC123", "artist": "A", "frames": [{"node_id": "1:2", "name": "Login", "url": "javascript:alert(1)", "image_base64": "` + png + `"}]}`,
+	} {
+		if w := send(body); w.Code != http.StatusBadRequest {
+			t.Errorf("%.80s: expected 400, got %d", body, w.Code)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dataDir, "temp")); len(entries) != 2 {
+		t.Errorf("expected only the 2 valid frames in temp, found %d files", len(entries))
+	}
+}
+
+func TestXMPHandler(t *testing.T) {
+	dataDir, index := newDataTestIndex(t)
+	storage := service.NewStorageService(dataDir)
+	imageService := service.NewImageService(storage, nil, index, nil, logrus.New())
+	handler := NewXMPHandler(index, service.NewXMPService(index, imageService, storage, logrus.New()))
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/images/{id}/xmp", handler.HandleGetXMP).Methods("GET")
+	router.HandleFunc("/api/v1/images/xmp-export", handler.HandleExport).Methods("POST")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/images/img-1/xmp", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if disposition := w.Header().Get("Content-Disposition"); disposition != `attachment; filename="img-1.xmp"` {
+		t.Errorf("unexpected Content-Disposition %q", disposition)
+	}
+	if !strings.Contains(w.Body.String(), `<rdf:li xml:lang="x-default">T</rdf:li>`) {
+		t.Errorf("expected the title in the sidecar:\n%s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/images/missing/xmp", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown image, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/images/xmp-export", strings.NewReader(`{"category": "animals"}`)))
+	var response struct {
+		JobID string `json:"job_id"`
+	}
+	if w.Code != http.StatusAccepted || json.NewDecoder(w.Body).Decode(&response) != nil || response.JobID == "" {
+		t.Fatalf("expected 202 with a job ID, got %d", w.Code)
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if job, err := imageService.GetJob(response.JobID); err == nil && job.State != models.JobStateRunning {
+			break
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "categories", "animals", "img-1.xmp")); err != nil {
+		t.Errorf("expected the export to write the sidecar: %v", err)
+	}
+}
+
+func TestFeedHandler(t *testing.T) {
+	index := service.NewIndexService(t.TempDir())
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	now := time.Now()
+	for _, img := range []*models.Image{
+		{ID: "old", Title: "Old cat", Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: now.Add(-2 * time.Hour),
+			FilePath: "categories/animals/old.jpg", ThumbnailPath: "categories/animals/old_thumb.jpg", ManualTags: []string{"Cat"}},
+		{ID: "new", Title: "New dog", Artist: "B", Category: "animals", Type: models.ImageType2D, UploadedAt: now.Add(-time.Hour),
+			FilePath: "categories/animals/new.jpg", ThumbnailPath: "categories/animals/new_thumb.jpg"},
+		{ID: "hidden", Title: "Private", Artist: "C", Category: "animals", Type: models.ImageType2D, UploadedAt: now, Visibility: models.VisibilityPrivate},
+		{ID: "hill", Title: "Hill", Artist: "D", Category: "landscape", Type: models.ImageType2D, UploadedAt: now.Add(-3 * time.Hour)},
+	} {
+		if err := index.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+	handler := NewFeedHandler(index, "https://warehouse.example.com/")
+
+	type feed struct {
+		Title   string `xml:"title"`
+		Entries []struct {
+			ID        string `xml:"id"`
+			Title     string `xml:"title"`
+			Thumbnail struct {
+				URL string `xml:"url,attr"`
+			} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+		} `xml:"entry"`
+	}
+	get := func(target string) (*httptest.ResponseRecorder, feed) {
+		w := httptest.NewRecorder()
+		handler.HandleFeed(w, httptest.NewRequest(http.MethodGet, target, nil))
+		var parsed feed
+		if w.Code == http.StatusOK {
+			if err := xml.Unmarshal(w.Body.Bytes(), &parsed); err != nil {
+				t.Fatalf("%s: invalid feed: %v\n%s", target, err, w.Body.String())
+			}
+		}
+		return w, parsed
+	}
+
+	// Newest first, private images left out
+	w, parsed := get("/api/v1/feed.atom?category=animals")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/atom+xml; charset=utf-8" {
+		t.Fatalf("expected an Atom feed, got %d (%s)", w.Code, w.Header().Get("Content-Type"))
+	}
+	if parsed.Title != "Image Warehouse: animals" || len(parsed.Entries) != 2 || parsed.Entries[0].Title != "New dog" || parsed.Entries[1].Title != "Old cat" {
+		t.Fatalf("unexpected feed %+v", parsed)
+	}
+	if parsed.Entries[0].ID != "https://warehouse.example.com/api/v1/images/new" ||
+		parsed.Entries[0].Thumbnail.URL != "https://warehouse.example.com/data/categories/animals/new_thumb.jpg" {
+		t.Errorf("expected absolute links, got %+v", parsed.Entries[0])
+	}
+
+	if _, parsed := get("/api/v1/feed.atom?tag=cat"); len(parsed.Entries) != 1 || parsed.Entries[0].Title != "Old cat" {
+		t.Errorf("expected only the tagged image, got %+v", parsed.Entries)
+	}
+	if _, parsed := get("/api/v1/feed.atom?limit=1"); len(parsed.Entries) != 1 || parsed.Entries[0].Title != "New dog" {
+		t.Errorf("expected the newest image only, got %+v", parsed.Entries)
+	}
+	if w, _ := get("/api/v1/feed.atom?limit=0"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for limit=0, got %d", w.Code)
+	}
+
+	// Feed readers polling with the ETag get 304
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/feed.atom?category=animals", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	handler.HandleFeed(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", w.Code)
+	}
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	dataDir, index := newDataTestIndex(t)
+	img := &models.Image{ID: "img-2", Title: "Fox", Artist: "B", Category: "animals/wild", Type: models.ImageType2D, UploadedAt: time.Now(), Project: "zoo"}
+	if err := index.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	projects := service.NewProjectStore(filepath.Join(dataDir, "projects.json"))
+	if _, _, err := projects.Put(models.Project{ID: "zoo", Name: "Zoo"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	handler := NewGraphQLHandler(index, nil, projects,
+		service.NewRenditionService(service.NewStorageService(dataDir), nil, nil, nil, logrus.New()),
+		service.NewAnnotationStore(filepath.Join(dataDir, "annotations.json")),
+		service.NewPopularityStore(filepath.Join(dataDir, "popularity.json")))
+
+	run := func(req *http.Request) (int, string) {
+		w := httptest.NewRecorder()
+		handler.HandleGraphQL(w, req)
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	body := `{"query":"query ($id: ID!) { image(id: $id) { title thumbnailUrl renditions { kind url } project { name } } }","variables":{"id":"img-1"}}`
+	code, got := run(httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(body)))
+	thumb, _ := os.Stat(filepath.Join(dataDir, "categories/animals/img-1_thumb.jpg"))
+	want := fmt.Sprintf(`{"data":{"image":{"title":"T","thumbnailUrl":"/data/categories/animals/img-1_thumb.jpg","renditions":[{"kind":"thumbnail","url":"/data/categories/animals/img-1_thumb.jpg?v=%d"}],"project":null}}}`, thumb.ModTime().Unix())
+	if code != http.StatusOK || got != want {
+		t.Errorf("image query: got %d %s\nwant %s", code, got, want)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(`{ categories { path count children { path images { total items { title project { id } } } } } }`))
+	req.Header.Set("Content-Type", "application/graphql")
+	code, got = run(req)
+	want = `{"data":{"categories":[{"path":"animals","count":2,"children":[{"path":"animals/wild","images":{"total":1,"items":[{"title":"Fox","project":{"id":"zoo"}}]}}]}]}}`
+	if code != http.StatusOK || got != want {
+		t.Errorf("categories query: got %d %s\nwant %s", code, got, want)
+	}
+
+	code, got = run(httptest.NewRequest(http.MethodGet, "/api/v1/graphql?query="+url.QueryEscape(`{ images(project: "zoo", limit: 1000) { total } }`), nil))
+	if code != http.StatusOK || !strings.Contains(got, `"path":["images"]`) || !strings.Contains(got, "limit must be between 0 and 500") {
+		t.Errorf("expected a limit error on images, got %d %s", code, got)
+	}
+
+	searches := `{ a: search(query: "a") { score } b: search(query: "b") { score } c: search(query: "c") { score } d: search(query: "d") { score } e: search(query: "e") { score } f: search(query: "f") { score } }`
+	code, got = run(httptest.NewRequest(http.MethodGet, "/api/v1/graphql?query="+url.QueryEscape(searches), nil))
+	if code != http.StatusOK || !strings.Contains(got, "query is too expensive") || strings.Contains(got, `"data"`) {
+		t.Errorf("expected more than 5 searches to be rejected, got %d %s", code, got)
+	}
+
+	code, got = run(httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(`{"query":"{ image { id } }"}`)))
+	if code != http.StatusOK || !strings.Contains(got, `argument \"id\" of Query.image is required`) || strings.Contains(got, `"data"`) {
+		t.Errorf("expected a validation error, got %d %s", code, got)
+	}
+
+	if code, _ = run(httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(`{"query":`))); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for malformed JSON, got %d", code)
+	}
+}
+
+func TestImagesHandler_HandleGetAnalysis(t *testing.T) {
+	dataDir, index := newDataTestIndex(t)
+	imageService := service.NewImageService(nil, nil, index, nil, logrus.New())
+	store := service.NewAnalysisStore(filepath.Join(dataDir, "analyses"))
+	imageService.SetAnalysisStore(store)
+	store.Put("img-1", &models.AIAnalysis{Description: "A cat", Mood: "Calm", PromptVersion: "3f2a9c01b7de", RawResponse: `{"description":"A cat","mood":"calm"}`})
+	handler := NewImagesHandler(nil, imageService, index, service.NewAnnotationStore(filepath.Join(dataDir, "annotations.json")), nil, nil, nil,
+		service.NewPopularityStore(filepath.Join(dataDir, "popularity.json")), service.NewFavoriteStore(filepath.Join(dataDir, "favorites.json")), "")
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/images", handler.HandleListImages).Methods("GET")
+	router.HandleFunc("/api/v1/images/{id}/analysis", handler.HandleGetAnalysis).Methods("GET")
+
+	get := func(target string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	code, body := get("/api/v1/images/img-1/analysis")
+	analysis, _ := body["analysis"].(map[string]interface{})
+	raw, _ := body["raw"].(map[string]interface{})
+	if code != http.StatusOK || analysis["description"] != "A cat" || analysis["prompt_version"] != "3f2a9c01b7de" || raw["mood"] != "calm" {
+		t.Fatalf("unexpected analysis response %d: %v", code, body)
+	}
+	if _, ok := analysis["raw_response"]; ok {
+		t.Error("expected the raw response only as raw")
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/images/img-1/analysis", nil)
+	req.Header.Set("Accept-Language", "fr-FR, en;q=0.5")
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"mood":{"code":"calm","label":"Calme"}`) || w.Header().Get("Vary") != "Accept-Language" {
+		t.Errorf("expected the mood term in French, got %s", w.Body.String())
+	}
+	if _, body := get("/api/v1/images/img-1/analysis?raw=false"); body["raw"] != nil {
+		t.Errorf("expected no raw response with raw=false, got %v", body)
+	}
+	if code, _ := get("/api/v1/images/missing/analysis"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an image without analysis, got %d", code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/images?include_analysis=true&raw=false", nil))
+	var list struct {
+		Images []service.ImageMetadata `json:"images"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Images) != 1 || list.Images[0].AIAnalysis == nil {
+		t.Fatalf("expected the listed image with its analysis, got %s", w.Body.String())
+	}
+	if analysis := list.Images[0].AIAnalysis; analysis.Description != "A cat" || analysis.RawResponse != "" {
+		t.Errorf("expected the analysis without the raw response, got %+v", analysis)
+	}
+}
+
+func TestImageLinks(t *testing.T) {
+	dataDir, index := newDataTestIndex(t)
+	imageService := service.NewImageService(nil, nil, index, nil, logrus.New())
+	renditions := service.NewRenditionService(service.NewStorageService(dataDir), imageService, nil, nil, logrus.New())
+	handler := NewImagesHandler(nil, imageService, index, service.NewAnnotationStore(filepath.Join(dataDir, "annotations.json")), nil, nil, renditions,
+		service.NewPopularityStore(filepath.Join(dataDir, "popularity.json")), service.NewFavoriteStore(filepath.Join(dataDir, "favorites.json")),
+		"https://warehouse.example.com/")
+	share := NewShareHandler(index, "")
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/images/{id}", handler.HandleGetImage).Methods("GET")
+	router.HandleFunc("/share/{id}", share.HandleShare).Methods("GET")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/images/img-1", nil))
+	var img service.ImageMetadata
+	if err := json.Unmarshal(w.Body.Bytes(), &img); err != nil || img.Links == nil {
+		t.Fatalf("expected the image with its links, got %s", w.Body.String())
+	}
+	want := models.ImageLinks{
+		Self:      "https://warehouse.example.com/api/v1/images/img-1",
+		File:      "https://warehouse.example.com/data/categories/animals/img-1.jpg",
+		Thumbnail: "https://warehouse.example.com/data/categories/animals/img-1_thumb.jpg",
+		Detail:    "https://warehouse.example.com/?image=img-1",
+		Share:     "https://warehouse.example.com/share/img-1",
+	}
+	if *img.Links != want {
+		t.Errorf("unexpected links %+v", *img.Links)
+	}
+
+	// Without PUBLIC_BASE_URL, links use the host of the request
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080/share/img-1", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, `<meta property="og:image" content="http://localhost:8080/data/categories/animals/img-1_thumb.jpg">`) ||
+		!strings.Contains(body, `url=http://localhost:8080/?image=img-1`) {
+		t.Errorf("unexpected share page %d: %s", w.Code, body)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/health", NewHealthHandler().HandleHealth).Methods("GET")
+	tokens := map[string]models.Principal{"key": {Name: "alice", Role: models.RoleAdmin, Authenticated: true}}
+
+	if _, err := middleware.AccessLog(io.Discard, "apache"); err == nil {
+		t.Errorf("expected an unknown format to be rejected")
+	}
+
+	var out bytes.Buffer
+	accessLog, err := middleware.AccessLog(&out, middleware.AccessLogCombined)
+	if err != nil {
+		t.Fatalf("AccessLog failed: %v", err)
+	}
+	server := accessLog(middleware.Auth(tokens)(router))
+
+	req := httptest.NewRequest(http.MethodGet, "/health?q=\"x\"", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("Authorization", "Bearer key")
+	req.Header.Set("User-Agent", "curl/8.0")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	line := out.String()
+	pattern := `^203\.0\.113\.7 - alice \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [-+]\d{4}\] "GET /health\?q=\\"x\\" HTTP/1\.1" 200 ` +
+		fmt.Sprint(w.Body.Len()) + ` "-" "curl/8\.0"\n$`
+	if !regexp.MustCompile(pattern).MatchString(line) {
+		t.Errorf("unexpected combined line %q", line)
+	}
+
+	// Anonymous callers are logged without a user, as JSON here
+	out.Reset()
+	accessLog, _ = middleware.AccessLog(&out, middleware.AccessLogJSON)
+	server = accessLog(middleware.Auth(tokens)(router))
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	var entry map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON line, got %q (%v)", out.String(), err)
+	}
+	if entry["status"] != float64(http.StatusNotFound) || entry["uri"] != "/missing" || entry["user"] != nil {
+		t.Errorf("unexpected JSON entry %v", entry)
+	}
+}
+
+func TestImagesHandler_ETagRoundTrip(t *testing.T) {
+	dataDir, index := newDataTestIndex(t)
+	imageService := service.NewImageService(nil, nil, index, service.NewStatusStore("", 0, 0), logrus.New())
+	favorites := service.NewFavoriteStore(filepath.Join(dataDir, "favorites.json"))
+	renditions := service.NewRenditionService(service.NewStorageService(dataDir), imageService, nil, nil, logrus.New())
+	handler := NewImagesHandler(nil, imageService, index, service.NewAnnotationStore(filepath.Join(dataDir, "annotations.json")), nil, nil, renditions,
+		service.NewPopularityStore(filepath.Join(dataDir, "popularity.json")), favorites, "")
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/images/{id}", handler.HandleGetImage).Methods("GET")
+	router.HandleFunc("/api/v1/images/{id}", handler.HandleUpdateImage).Methods("PATCH")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/images/img-1", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `"1.`) {
+		t.Fatalf("expected a revision ETag, got %d %q", w.Code, etag)
+	}
+	if vary := strings.Join(w.Header().Values("Vary"), ", "); vary != "Authorization, X-Actor, Accept-Language" {
+		t.Errorf("unexpected Vary %q", vary)
+	}
+
+	// The ETag of a GET is accepted as If-Match
+	patch := func(ifMatch string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/images/img-1", strings.NewReader(`{"title": "Renamed"}`))
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := patch(etag); code != http.StatusOK {
+		t.Fatalf("expected the ETag to be accepted as If-Match, got %d", code)
+	}
+	if code := patch(etag); code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 for a stale ETag, got %d", code)
+	}
+	if code := patch(`"abc"`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a tag without a revision, got %d", code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/images/img-1", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("ETag"), `"2.`) {
+		t.Errorf("expected the edited image with a new ETag, got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+
+	// Starring changes what callers see, so it counts as a modification
+	if _, err := favorites.Add("alice", "img-1"); err != nil {
+		t.Fatal(err)
+	}
+	if modified := favorites.LastModified(); modified.IsZero() || !handler.lastModified().Equal(modified) {
+		t.Errorf("expected Last-Modified to follow favorites, got %v", handler.lastModified())
+	}
+}
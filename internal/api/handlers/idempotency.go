@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+// idempotencyKey returns the request's Idempotency-Key header scoped to the
+// caller and the endpoint; its Key is empty if the client did not send one
+func idempotencyKey(r *http.Request) service.IdempotencyKey {
+	return scopedIdempotencyKey(r, r.Header.Get("Idempotency-Key"))
+}
+
+// scopedIdempotencyKey scopes a key sent by the client to the caller and the
+// endpoint
+func scopedIdempotencyKey(r *http.Request, key string) service.IdempotencyKey {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return service.IdempotencyKey{}
+	}
+	return service.IdempotencyKey{Caller: middleware.PrincipalFrom(r.Context()).Name, Route: r.URL.Path, Key: key}
+}
+
+// writeIdempotentReplay answers a retried upload with the image ID created by
+// the original request instead of creating a new job
+func writeIdempotentReplay(w http.ResponseWriter, imageService *service.ImageService, imageID string) {
+	status := "processing"
+	if img, err := imageService.GetStatus(imageID); err == nil {
+		status = img.Status
+	}
+
+	response := map[string]interface{}{
+		"id":      imageID,
+		"status":  status,
+		"message": "Duplicate request: returning the original upload",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
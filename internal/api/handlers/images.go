@@ -2,54 +2,528 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
+	"github.com/yourcompany/image-warehousing/internal/models"
 	"github.com/yourcompany/image-warehousing/internal/service"
 )
 
 type ImagesHandler struct {
-	imageService *service.ImageService
-	indexService *service.IndexService
+	storageService *service.StorageService
+	imageService   *service.ImageService
+	indexService   *service.IndexService
+	annotations    *service.AnnotationStore
+	projects       *service.ProjectStore
+	watermarker    *service.Watermarker
+	renditions     *service.RenditionService
+	popularity     *service.PopularityStore
+	favorites      *service.FavoriteStore
+	baseURL        string // PUBLIC_BASE_URL; taken from the request if empty
 }
 
-func NewImagesHandler(image *service.ImageService, index *service.IndexService) *ImagesHandler {
+func NewImagesHandler(storage *service.StorageService, image *service.ImageService, index *service.IndexService, annotations *service.AnnotationStore, projects *service.ProjectStore, watermarker *service.Watermarker, renditions *service.RenditionService, popularity *service.PopularityStore, favorites *service.FavoriteStore, baseURL string) *ImagesHandler {
 	return &ImagesHandler{
-		imageService: image,
-		indexService: index,
+		storageService: storage,
+		imageService:   image,
+		indexService:   index,
+		annotations:    annotations,
+		projects:       projects,
+		watermarker:    watermarker,
+		renditions:     renditions,
+		popularity:     popularity,
+		favorites:      favorites,
+		baseURL:        baseURL,
 	}
 }
 
-// HandleListImages lists all images from the index
+// lastModified returns when listed metadata last changed: the latest of the
+// index, the annotation store, the popularity counts and the favorites
+func (h *ImagesHandler) lastModified() time.Time {
+	lastModified, _ := h.indexService.LastModified()
+	for _, modified := range []time.Time{h.annotations.LastModified(), h.popularity.LastModified(), h.favorites.LastModified()} {
+		if modified.After(lastModified) {
+			lastModified = modified
+		}
+	}
+	return lastModified
+}
+
+// HandleListImages lists the images of the index, narrowed by the filters
+// of listFilters and with detected series collapsed unless expand_series=true.
+// sort, view, fields and include_analysis shape the result; see the README
+// for every parameter.
 func (h *ImagesHandler) HandleListImages(w http.ResponseWriter, r *http.Request) {
-	// Get query parameters
-	category := r.URL.Query().Get("category")
+	query := r.URL.Query()
+	sortBy := query.Get("sort")
+	if sortBy != "" && sortBy != "popular" && sortBy != "quality" && sortBy != "aesthetic" {
+		http.Error(w, "Invalid sort (use popular, quality or aesthetic)", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	filters, err := listFilters(query, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Get all images from index
-	images, err := h.indexService.GetAllImages()
+	lastModified := h.lastModified()
+	images, err := h.indexService.GetImages(query.Get("include_deleted") == "true")
 	if err != nil {
 		http.Error(w, "Failed to load images", http.StatusInternalServerError)
 		return
 	}
+	starred := h.starred(w, r)
+	retention := h.imageService.RetentionPolicy()
+	base := linkBase(h.baseURL, r)
+	for _, img := range images {
+		img.AnnotationCount = h.annotations.Count(img.ID)
+		img.Popularity = h.popularity.Get(img.ID)
+		img.Favorite = starred[img.ID]
+		img.HeldBy = h.imageService.HeldBy(img)
+		if img.HeldBy == "" {
+			img.Retention = retention.Info(img, now)
+		}
+		if !img.Deleted {
+			img.Links = metadataLinks(base, img)
+		}
+	}
+	service.DetectSeries(images)
+
+	var filtered []*service.ImageMetadata
+	for _, img := range images {
+		if matchesAll(filters, img) {
+			filtered = append(filtered, img)
+		}
+	}
+	images = filtered
+
+	// One image per series unless expanded; a series' own images are listed
+	// uncollapsed
+	if query.Get("series") == "" && query.Get("expand_series") != "true" {
+		images = service.CollapseSeries(images)
+	}
 
-	// Filter by category if specified
-	if category != "" {
-		var filtered []*service.ImageMetadata
+	// Full analyses on request, raw provider responses unless raw=false
+	if r.URL.Query().Get("include_analysis") == "true" {
+		addVary(w.Header(), "Accept-Language")
 		for _, img := range images {
-			if img.Category == category {
-				filtered = append(filtered, img)
+			if img.Deleted {
+				continue
+			}
+			if analysis, err := h.imageService.GetAnalysis(img.ID); err == nil {
+				img.AIAnalysis = h.presentAnalysis(r, analysis)
+			}
+		}
+	}
+
+	switch sortBy {
+	case "popular":
+		sortByPopularity(images)
+	case "quality":
+		sortByQuality(images)
+	case "aesthetic":
+		sortByAesthetics(images)
+	}
+
+	var result interface{} = images
+	switch view := r.URL.Query().Get("view"); view {
+	case "", "full":
+		if fieldsParam := r.URL.Query().Get("fields"); fieldsParam != "" {
+			result = projectFields(images, parseFields(fieldsParam))
+		}
+	case "grid":
+		grid := make([]GridImage, len(images))
+		for i, img := range images {
+			grid[i] = toGridImage(img)
+		}
+		result = grid
+	default:
+		http.Error(w, "Invalid view (use grid or full)", http.StatusBadRequest)
+		return
+	}
+
+	writeConditionalJSON(w, r, map[string]interface{}{
+		"images": result,
+		"total":  len(images),
+	}, lastModified)
+}
+
+// imageFilter reports whether a listed image passes a filter
+type imageFilter func(img *service.ImageMetadata) bool
+
+func matchesAll(filters []imageFilter, img *service.ImageMetadata) bool {
+	for _, matches := range filters {
+		if !matches(img) {
+			return false
+		}
+	}
+	return true
+}
+
+// listFilters builds the filters of the list query parameters. Empty
+// parameters add no filter.
+func listFilters(query url.Values, now time.Time) ([]imageFilter, error) {
+	var filters []imageFilter
+	// equal filters on a string field when the parameter is set
+	equal := func(name string, field func(img *service.ImageMetadata) string) {
+		if value := query.Get(name); value != "" {
+			filters = append(filters, func(img *service.ImageMetadata) bool { return field(img) == value })
+		}
+	}
+
+	if series := query.Get("series"); series != "" {
+		filters = append(filters, func(img *service.ImageMetadata) bool { return img.Series != nil && img.Series.ID == series })
+	}
+	if query.Get("expiring") == "true" {
+		filters = append(filters, func(img *service.ImageMetadata) bool { return img.Retention != nil && img.Retention.Warning != "" })
+	}
+	if query.Get("legal_hold") == "true" {
+		filters = append(filters, func(img *service.ImageMetadata) bool { return img.HeldBy != "" })
+	}
+	// category=primary matches its sub categories too
+	if category := query.Get("category"); category != "" {
+		filters = append(filters, func(img *service.ImageMetadata) bool { return img.InCategory(category) })
+	}
+	equal("sub_category", func(img *service.ImageMetadata) string { return img.SubCategory })
+	equal("visibility", func(img *service.ImageMetadata) string { return img.Visibility })
+	equal("project", func(img *service.ImageMetadata) string { return img.Project })
+	equal("workflow", func(img *service.ImageMetadata) string { return img.Workflow })
+	if document := query.Get("source_document"); document != "" {
+		filters = append(filters, func(img *service.ImageMetadata) bool {
+			return img.DesignSource != nil && img.DesignSource.DocumentID == document
+		})
+	}
+
+	// What analyzed the image. prompt_version_not=<current version> finds
+	// analyses to re-run after a prompt change, including unstamped ones.
+	equal("ai_provider", func(img *service.ImageMetadata) string { return img.AIProvider })
+	equal("ai_model", func(img *service.ImageMetadata) string { return img.AIModel })
+	equal("prompt_version", func(img *service.ImageMetadata) string { return img.PromptVersion })
+	if version := query.Get("prompt_version_not"); version != "" {
+		filters = append(filters, func(img *service.ImageMetadata) bool { return img.PromptVersion != version })
+	}
+
+	if licenseType := query.Get("license"); licenseType != "" {
+		filters = append(filters, func(img *service.ImageMetadata) bool {
+			return img.License != nil && strings.EqualFold(img.License.Type, licenseType)
+		})
+	}
+	if query.Get("exclude_expired") == "true" {
+		filters = append(filters, func(img *service.ImageMetadata) bool { return !img.License.Expired(now) })
+	}
+	if attributes := parseAttributeFilter(query); len(attributes) > 0 {
+		filters = append(filters, func(img *service.ImageMetadata) bool { return img.HasAttributes(attributes) })
+	}
+
+	// Scores; unscored images don't pass
+	if value := query.Get("min_quality"); value != "" {
+		minQuality, err := strconv.Atoi(value)
+		if err != nil || minQuality < 0 || minQuality > 100 {
+			return nil, errors.New("Invalid min_quality (use 0-100)")
+		}
+		filters = append(filters, func(img *service.ImageMetadata) bool { return img.Quality != nil && img.Quality.Score >= minQuality })
+	}
+	if value := query.Get("min_aesthetic"); value != "" {
+		minAesthetic, err := strconv.ParseFloat(value, 64)
+		if err != nil || minAesthetic < 0 || minAesthetic > 10 {
+			return nil, errors.New("Invalid min_aesthetic (use 1-10)")
+		}
+		filters = append(filters, func(img *service.ImageMetadata) bool {
+			return img.Aesthetics != nil && img.Aesthetics.Score >= minAesthetic
+		})
+	}
+	if query.Get("unseen") == "true" {
+		filters = append(filters, func(img *service.ImageMetadata) bool { return img.Popularity == nil || img.Popularity.Views == 0 })
+	}
+
+	dimensions, err := parseDimensionFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	if !dimensions.IsZero() {
+		filters = append(filters, dimensions.Matches)
+	}
+	size, err := service.ParseSizeFilter(query.Get("min_size"), query.Get("max_size"))
+	if err != nil {
+		return nil, err
+	}
+	if !size.IsZero() {
+		filters = append(filters, size.Matches)
+	}
+	polygons, err := parsePolygonFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	if !polygons.IsZero() {
+		filters = append(filters, polygons.Matches)
+	}
+	textures, err := service.ParseTextureFilter(query.Get("min_texture"), query.Get("shading"))
+	if err != nil {
+		return nil, err
+	}
+	if !textures.IsZero() {
+		filters = append(filters, textures.Matches)
+	}
+
+	features, err := parseFeatureFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	if features.Feature == "" && features.MinConfidence > 0 {
+		return nil, errors.New("min_confidence needs a feature")
+	}
+	if features.Feature != "" {
+		filters = append(filters, func(img *service.ImageMetadata) bool { return features.Matches(img, "") })
+	}
+	return filters, nil
+}
+
+// parseAttributeFilter reads attr.<name>=<value> query parameters; an empty
+// value only requires the attribute to be set
+func parseAttributeFilter(query url.Values) map[string]string {
+	attributes := make(map[string]string)
+	for key, values := range query {
+		if name, ok := strings.CutPrefix(key, "attr."); ok && name != "" {
+			attributes[strings.ToLower(name)] = strings.TrimSpace(values[0])
+		}
+	}
+	return attributes
+}
+
+// parseDimensionFilter reads the dimension filter query parameters
+func parseDimensionFilter(query url.Values) (service.DimensionFilter, error) {
+	var filter service.DimensionFilter
+	ints := map[string]*int{
+		"min_width":  &filter.MinWidth,
+		"min_height": &filter.MinHeight,
+		"max_width":  &filter.MaxWidth,
+		"max_height": &filter.MaxHeight,
+	}
+	for name, target := range ints {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %s", name, value)
 			}
+			*target = n
 		}
-		images = filtered
 	}
 
+	floats := map[string]*float64{
+		"min_megapixels":   &filter.MinMegapixels,
+		"aspect_tolerance": &filter.AspectTolerance,
+	}
+	for name, target := range floats {
+		if value := query.Get(name); value != "" {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %s", name, value)
+			}
+			*target = f
+		}
+	}
+
+	ratio, err := service.ParseAspectRatio(query.Get("aspect_ratio"))
+	if err != nil {
+		return filter, err
+	}
+	filter.AspectRatio = ratio
+
+	return filter, filter.Validate()
+}
+
+// parsePolygonFilter reads the triangle budget and LOD query parameters
+func parsePolygonFilter(query url.Values) (service.PolygonFilter, error) {
+	filter := service.PolygonFilter{LOD: models.NormalizeLODTag(query.Get("lod"))}
+	ints := map[string]*int{
+		"min_tris": &filter.MinTriangles,
+		"max_tris": &filter.MaxTriangles,
+	}
+	for name, target := range ints {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %s", name, value)
+			}
+			*target = n
+		}
+	}
+	return filter, filter.Validate()
+}
+
+// parseFeatureFilter reads the feature and min_confidence query parameters
+func parseFeatureFilter(query url.Values) (service.FeatureFilter, error) {
+	filter := service.FeatureFilter{Feature: query.Get("feature")}
+	if value := query.Get("min_confidence"); value != "" {
+		confidence, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid min_confidence: %s", value)
+		}
+		filter.MinConfidence = confidence
+	}
+	return filter, filter.Validate()
+}
+
+// starred returns the images the caller starred. Responses that mark them
+// vary by caller.
+func (h *ImagesHandler) starred(w http.ResponseWriter, r *http.Request) map[string]bool {
+	addVary(w.Header(), "Authorization", "X-Actor")
+	user, ok := favoriteUser(r)
+	if !ok {
+		return nil
+	}
+	return h.favorites.Starred(user)
+}
+
+// sortByPopularity orders images by popularity score, most popular first;
+// ties keep index order
+func sortByPopularity(images []*service.ImageMetadata) {
+	score := func(img *service.ImageMetadata) int {
+		if img.Popularity == nil {
+			return 0
+		}
+		return img.Popularity.Score()
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		return score(images[i]) > score(images[j])
+	})
+}
+
+// sortByQuality orders images by quality score, best first; unscored images
+// go last and ties keep index order
+func sortByQuality(images []*service.ImageMetadata) {
+	score := func(img *service.ImageMetadata) int {
+		if img.Quality == nil {
+			return -1
+		}
+		return img.Quality.Score
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		return score(images[i]) > score(images[j])
+	})
+}
+
+// sortByAesthetics orders images by AI aesthetic score, best first;
+// unrated images go last and ties keep index order
+func sortByAesthetics(images []*service.ImageMetadata) {
+	score := func(img *service.ImageMetadata) float64 {
+		if img.Aesthetics == nil {
+			return -1
+		}
+		return img.Aesthetics.Score
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		return score(images[i]) > score(images[j])
+	})
+}
+
+// HandleRecentlyViewed returns the most recently viewed images, most recent
+// first, for dashboards. limit=N (default 20, max 100); view=grid returns
+// the compact projection.
+func (h *ImagesHandler) HandleRecentlyViewed(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > 100 {
+			http.Error(w, "Invalid limit (1-100)", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	view := r.URL.Query().Get("view")
+	if view != "" && view != "full" && view != "grid" {
+		http.Error(w, "Invalid view (use grid or full)", http.StatusBadRequest)
+		return
+	}
+
+	all, err := h.indexService.GetAllImages()
+	if err != nil {
+		http.Error(w, "Failed to load images", http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[string]*service.ImageMetadata, len(all))
+	for _, img := range all {
+		byID[img.ID] = img
+	}
+
+	// Viewed images may have been deleted since; ask for enough to fill the page
+	starred := h.starred(w, r)
+	images := make([]*service.ImageMetadata, 0, limit)
+	for _, id := range h.popularity.RecentlyViewed(limit * 2) {
+		img, ok := byID[id]
+		if !ok {
+			continue
+		}
+		img.AnnotationCount = h.annotations.Count(id)
+		img.Popularity = h.popularity.Get(id)
+		img.Favorite = starred[id]
+		img.Links = metadataLinks(linkBase(h.baseURL, r), img)
+		images = append(images, img)
+		if len(images) == limit {
+			break
+		}
+	}
+
+	var result interface{} = images
+	if view == "grid" {
+		grid := make([]GridImage, len(images))
+		for i, img := range images {
+			grid[i] = toGridImage(img)
+		}
+		result = grid
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"images": images,
+		"images": result,
 		"total":  len(images),
 	})
 }
 
+// HandleGeoImages returns the images with a GPS location as a GeoJSON
+// FeatureCollection for map views. bbox=minLon,minLat,maxLon,maxLat limits
+// them to an area; category and project filter as in the list.
+func (h *ImagesHandler) HandleGeoImages(w http.ResponseWriter, r *http.Request) {
+	var bbox *models.BoundingBox
+	if param := r.URL.Query().Get("bbox"); param != "" {
+		var err error
+		if bbox, err = models.ParseBoundingBox(param); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	category := r.URL.Query().Get("category")
+	project := r.URL.Query().Get("project")
+
+	images, err := h.indexService.GetAllImages()
+	if err != nil {
+		http.Error(w, "Failed to load images", http.StatusInternalServerError)
+		return
+	}
+
+	collection := GeoFeatureCollection{Type: "FeatureCollection", Features: []GeoFeature{}}
+	for _, img := range images {
+		if img.Location == nil || (bbox != nil && !bbox.Contains(*img.Location)) {
+			continue
+		}
+		if (category != "" && !img.InCategory(category)) || (project != "" && img.Project != project) {
+			continue
+		}
+		collection.Features = append(collection.Features, toGeoFeature(img))
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	json.NewEncoder(w).Encode(collection)
+}
+
 // HandleGetImage gets a single image by ID
 func (h *ImagesHandler) HandleGetImage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -69,12 +543,311 @@ func (h *ImagesHandler) HandleGetImage(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Image not found", http.StatusNotFound)
 			return
 		}
+		metadata.AnnotationCount = h.annotations.Count(imageID)
+		metadata.Popularity = h.popularity.Get(imageID)
+		metadata.Favorite = h.starred(w, r)[imageID]
+		metadata.Renditions = h.renditions.List(metadata)
+		metadata.Links = metadataLinks(linkBase(h.baseURL, r), metadata)
+
+		writeImageJSON(w, r, metadata, metadata.Revision, h.lastModified())
+		return
+	}
+
+	// Processed images already have their renditions
+	if metadata, err := h.indexService.GetImageByID(imageID); err == nil {
+		image.Renditions = h.renditions.List(metadata)
+	}
+
+	if image.AIAnalysis != nil {
+		addVary(w.Header(), "Accept-Language")
+		image.AIAnalysis = h.presentAnalysis(r, image.AIAnalysis)
+	}
+	image.Links = statusLinks(linkBase(h.baseURL, r), image)
+
+	// Edits of a processed image touch the index, not ProcessedAt
+	var lastModified time.Time
+	if image.ProcessedAt != nil {
+		lastModified = *image.ProcessedAt
+		if modified := h.lastModified(); modified.After(lastModified) {
+			lastModified = modified
+		}
+	}
+	writeImageJSON(w, r, image, image.Revision, lastModified)
+}
+
+// updateImageRequest is the body of PATCH /images/{id}
+type updateImageRequest struct {
+	service.ImageUpdate
+	Revision int `json:"revision"`
+}
+
+// HandleUpdateImage applies a partial metadata update. The expected revision
+// must be supplied in the body or as If-Match; a stale revision yields 412.
+func (h *ImagesHandler) HandleUpdateImage(w http.ResponseWriter, r *http.Request) {
+	imageID := mux.Vars(r)["id"]
+	if imageID == "" {
+		http.Error(w, "Image ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req updateImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		rev, err := revisionFromIfMatch(ifMatch)
+		if err != nil {
+			http.Error(w, "Invalid If-Match revision", http.StatusBadRequest)
+			return
+		}
+		req.Revision = rev
+	}
+	if req.Revision <= 0 {
+		http.Error(w, "Expected revision required", http.StatusPreconditionRequired)
+		return
+	}
+
+	if (req.Title != nil && strings.TrimSpace(*req.Title) == "") ||
+		(req.Artist != nil && strings.TrimSpace(*req.Artist) == "") {
+		http.Error(w, "Title and artist cannot be empty", http.StatusBadRequest)
+		return
+	}
+	if req.Visibility != nil && !models.IsValidVisibility(*req.Visibility) {
+		http.Error(w, "Invalid visibility (use public or private)", http.StatusBadRequest)
+		return
+	}
+	if req.Project != nil {
+		*req.Project = strings.ToLower(strings.TrimSpace(*req.Project))
+		if _, err := h.projects.Get(*req.Project); *req.Project != "" && err != nil {
+			http.Error(w, "Unknown project: "+*req.Project, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.License != nil {
+		req.License.Normalize()
+		if err := req.License.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Attributes != nil {
+		req.Attributes = models.NormalizeAttributes(req.Attributes)
+		if err := models.ValidateAttributes(req.Attributes); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.FocalPoint != nil {
+		req.FocalPoint.Source = models.FocalSourceManual
+		if err := req.FocalPoint.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Bounds != nil {
+		req.Bounds.Source = models.BoundsSourceManual
+		if unit, ok := models.NormalizeLengthUnit(req.Bounds.Unit); ok {
+			req.Bounds.Unit = unit
+		}
+		if err := req.Bounds.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Triangles != nil && *req.Triangles < 0 {
+		http.Error(w, "Triangle count cannot be negative", http.StatusBadRequest)
+		return
+	}
+	if req.LODTags != nil {
+		*req.LODTags = models.NormalizeLODTags(*req.LODTags)
+		if err := models.ValidateLODTags(*req.LODTags); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.PosterView != nil {
+		*req.PosterView = strings.TrimSpace(*req.PosterView)
+	}
+
+	req.ImageUpdate.Actor = middleware.PrincipalFrom(r.Context()).Name
+	updated, err := h.imageService.UpdateImage(imageID, req.Revision, req.ImageUpdate)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrImageNotFound):
+			http.Error(w, "Image not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrFocalPointUnsupported), errors.Is(err, service.ErrBoundsUnsupported),
+			errors.Is(err, service.ErrPolygonBudgetUnsupported), errors.Is(err, service.ErrPosterViewUnsupported),
+			errors.Is(err, service.ErrUnknownPosterView):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrRevisionMismatch):
+			http.Error(w, "Image was modified by another request; reload and retry", http.StatusPreconditionFailed)
+		default:
+			http.Error(w, "Failed to update image", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	updated.Links = metadataLinks(linkBase(h.baseURL, r), updated)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// HandleDeleteImage deletes an image and its files, leaving a tombstone in the
+// index that records the caller as the actor. Images on legal hold are kept
+// (409).
+func (h *ImagesHandler) HandleDeleteImage(w http.ResponseWriter, r *http.Request) {
+	imageID := mux.Vars(r)["id"]
+	if imageID == "" {
+		http.Error(w, "Image ID required", http.StatusBadRequest)
+		return
+	}
+
+	// With ErrFilesRemain the image is gone all the same; the files left are
+	// logged
+	if err := h.imageService.DeleteImage(imageID, middleware.PrincipalFrom(r.Context()).Name); err != nil && !errors.Is(err, service.ErrFilesRemain) {
+		if errors.Is(err, service.ErrImageNotFound) {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrLegalHold) {
+			http.Error(w, "Image is on legal hold", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to delete image", http.StatusInternalServerError)
+		return
+	}
+	h.popularity.Forget(imageID)
+	h.favorites.Forget(imageID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetTurntable serves the animated turntable preview of a 3D object
+func (h *ImagesHandler) HandleGetTurntable(w http.ResponseWriter, r *http.Request) {
+	metadata, err := h.indexService.GetImageByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+	if metadata.TurntablePath == "" {
+		http.Error(w, "No turntable for this image", http.StatusNotFound)
+		return
+	}
+
+	path, err := h.storageService.StoredPath(metadata.TurntablePath)
+	if err != nil {
+		http.Error(w, "No turntable for this image", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	serveRendition(w, r, h.watermarker, nil, path)
+}
+
+// analysisResponse is the full AI analysis of an image
+type analysisResponse struct {
+	ImageID  string             `json:"image_id"`
+	Analysis *models.AIAnalysis `json:"analysis"`
+	Raw      json.RawMessage    `json:"raw,omitempty"` // the provider's response as it returned it
+}
 
+// HandleGetHistory returns the recorded events of an image, oldest first.
+// The history outlives the image, so a deleted image's can still be read.
+func (h *ImagesHandler) HandleGetHistory(w http.ResponseWriter, r *http.Request) {
+	imageID := mux.Vars(r)["id"]
+	events, err := h.imageService.History(imageID)
+	if err != nil {
+		http.Error(w, "Failed to read history", http.StatusInternalServerError)
+		return
+	}
+	if len(events) == 0 {
+		if _, err := h.indexService.GetImageByID(imageID); errors.Is(err, service.ErrImageNotFound) {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"image_id": imageID,
+		"events":   events,
+	})
+}
+
+// HandleGetAnalysis returns the full AI analysis of an image, with the raw
+// provider response as JSON unless raw=false
+func (h *ImagesHandler) HandleGetAnalysis(w http.ResponseWriter, r *http.Request) {
+	imageID := mux.Vars(r)["id"]
+	analysis, err := h.imageService.GetAnalysis(imageID)
+	if errors.Is(err, service.ErrAnalysisNotFound) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load analysis", http.StatusInternalServerError)
+		return
+	}
+
+	response := analysisResponse{ImageID: imageID, Analysis: h.presentAnalysis(r, analysis)}
+	if raw := []byte(response.Analysis.RawResponse); json.Valid(raw) {
+		response.Analysis.RawResponse = ""
+		response.Raw = raw
+	}
+
+	addVary(w.Header(), "Accept-Language")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// presentAnalysis returns a copy of analysis as responses show it: with its
+// vocabulary terms in the language of the request's Accept-Language, and
+// without the raw provider response if the request asks for raw=false
+func (h *ImagesHandler) presentAnalysis(r *http.Request, analysis *models.AIAnalysis) *models.AIAnalysis {
+	presented := *analysis
+	if r.URL.Query().Get("raw") == "false" {
+		presented.RawResponse = ""
+	}
+	presented.Terms = h.imageService.Vocabulary().Terms(analysis, service.ParseAcceptLanguage(r.Header.Get("Accept-Language")))
+	return &presented
+}
+
+// HandleGetImageByExternalID looks up an image by its client-supplied external ID
+func (h *ImagesHandler) HandleGetImageByExternalID(w http.ResponseWriter, r *http.Request) {
+	externalID := mux.Vars(r)["id"]
+	if externalID == "" {
+		http.Error(w, "External ID required", http.StatusBadRequest)
+		return
+	}
+
+	// Indexed images first
+	if metadata, err := h.indexService.GetImageByExternalID(externalID); err == nil {
+		metadata.Links = metadataLinks(linkBase(h.baseURL, r), metadata)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(metadata)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(image)
+	// Then uploads that are still being processed
+	if imageID, ok := h.imageService.LookupPendingExternalID(externalID); ok {
+		if image, err := h.imageService.GetStatus(imageID); err == nil {
+			if image.AIAnalysis != nil {
+				addVary(w.Header(), "Accept-Language")
+				image.AIAnalysis = h.presentAnalysis(r, image.AIAnalysis)
+			}
+			image.Links = statusLinks(linkBase(h.baseURL, r), image)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(image)
+			return
+		}
+	}
+
+	http.Error(w, "Image not found", http.StatusNotFound)
 }
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+// IngestHandler accepts 2D uploads as a flat JSON object, for automation
+// tools (Zapier, Make, webhooks) that can't send multipart forms. The image
+// is given as base64 or as a URL to download; the rest follows the upload form.
+type IngestHandler struct {
+	uploads    *UploadHandler
+	downloader *service.ImageDownloader
+}
+
+func NewIngestHandler(uploads *UploadHandler, downloader *service.ImageDownloader) *IngestHandler {
+	return &IngestHandler{
+		uploads:    uploads,
+		downloader: downloader,
+	}
+}
+
+// ingestRequest is the JSON body of an ingest. Fields are named like the
+// upload form's; tags may also be a comma-separated string.
+type ingestRequest struct {
+	ImageURL    string `json:"image_url"`
+	ImageBase64 string `json:"image_base64"` // plain base64 or a data: URI
+	Filename    string `json:"filename"`
+
+	Title             string            `json:"title"`
+	Artist            string            `json:"artist"`
+	Tags              json.RawMessage   `json:"tags"`
+	ExternalID        string            `json:"external_id"`
+	Project           string            `json:"project"`
+	Visibility        string            `json:"visibility"`
+	Priority          string            `json:"priority"`
+	License           string            `json:"license"`
+	RightsHolder      string            `json:"rights_holder"`
+	LicenseExpires    string            `json:"license_expires"`
+	UsageRestrictions string            `json:"usage_restrictions"`
+	Attributes        map[string]string `json:"attributes"`
+
+	// For tools that can't set the Idempotency-Key header
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+// HandleIngest queues an image sent as JSON; the response is the upload's
+func (h *IngestHandler) HandleIngest(w http.ResponseWriter, r *http.Request) {
+	// Base64 is a third larger than the image
+	r.Body = http.MaxBytesReader(w, r.Body, h.uploads.maxUploadSize/3*4+64<<10)
+
+	var req ingestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Image too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	// Retried request with a known idempotency key: return the original image
+	idemKey := idempotencyKey(r)
+	if idemKey.Key == "" {
+		idemKey = scopedIdempotencyKey(r, req.IdempotencyKey)
+	}
+	if idemKey.Key != "" {
+		if imageID, ok := h.uploads.idempotency.Lookup(idemKey); ok {
+			writeIdempotentReplay(w, h.uploads.imageService, imageID)
+			return
+		}
+	}
+
+	form, err := req.form()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	job, err := newUploadJob(form, h.uploads.projects)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get the image
+	saveStart := time.Now()
+	var content io.Reader
+	filename := service.CleanFilename(req.Filename)
+	switch {
+	case req.ImageURL != "" && req.ImageBase64 != "":
+		http.Error(w, "Send either image_url or image_base64, not both", http.StatusBadRequest)
+		return
+	case req.ImageBase64 != "":
+		data, contentType, err := decodeBase64Image(req.ImageBase64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if int64(len(data)) > h.uploads.maxUploadSize {
+			http.Error(w, "Image too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+		if filename == "" {
+			filename = "image"
+		}
+		filename = service.WithImageExtension(filename, contentType)
+		content = bytes.NewReader(data)
+	case req.ImageURL != "":
+		download, err := h.downloader.Download(r.Context(), req.ImageURL)
+		if err != nil {
+			writeDownloadError(w, err)
+			return
+		}
+		defer download.Body.Close()
+		if filename == "" {
+			filename = download.Filename
+		}
+		filename = service.WithImageExtension(filename, download.ContentType)
+		content = download.Body
+	default:
+		http.Error(w, "No image provided (set image_url or image_base64)", http.StatusBadRequest)
+		return
+	}
+
+	// Save to temp
+	imageID, tempPath, err := h.uploads.storageService.SaveImageToTemp(content, filename)
+	if err != nil {
+		if req.ImageURL != "" {
+			writeDownloadError(w, err)
+			return
+		}
+		http.Error(w, "Failed to save image", http.StatusInternalServerError)
+		return
+	}
+	job.ImageID = imageID
+	job.FilePath = tempPath
+	job.OriginalFilename = filename
+	job.Timeline = []models.StageTiming{models.TimeStage(models.StageSave, saveStart)}
+
+	h.uploads.queueUpload(w, job, idemKey)
+}
+
+// form maps the request onto the upload form's fields
+func (req *ingestRequest) form() (url.Values, error) {
+	form := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			form.Set(name, value)
+		}
+	}
+	set("title", req.Title)
+	set("artist", req.Artist)
+	set("external_id", req.ExternalID)
+	set("project", req.Project)
+	set("visibility", req.Visibility)
+	set("priority", req.Priority)
+	set("license", req.License)
+	set("rights_holder", req.RightsHolder)
+	set("license_expires", req.LicenseExpires)
+	set("usage_restrictions", req.UsageRestrictions)
+
+	tags, err := parseIngestTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		encoded, _ := json.Marshal(tags)
+		form.Set("tags", string(encoded))
+	}
+	if len(req.Attributes) > 0 {
+		encoded, _ := json.Marshal(req.Attributes)
+		form.Set("attributes", string(encoded))
+	}
+	return form, nil
+}
+
+// parseIngestTags reads tags given as a JSON array or a comma-separated string
+func parseIngestTags(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var tags []string
+	if err := json.Unmarshal(raw, &tags); err == nil {
+		return tags, nil
+	}
+	var list string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, errors.New("Invalid tags format (use an array or a comma-separated string)")
+	}
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// decodeBase64Image decodes plain base64 or a data: URI, returning the data
+// URI's content type if given
+func decodeBase64Image(value string) ([]byte, string, error) {
+	var contentType string
+	if rest, ok := strings.CutPrefix(value, "data:"); ok {
+		header, payload, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(header, ";base64") {
+			return nil, "", errors.New("Invalid image_base64 (data URIs must be base64)")
+		}
+		contentType, _, _ = mime.ParseMediaType(strings.TrimSuffix(header, ";base64"))
+		value = payload
+	}
+
+	// Tolerate line breaks and missing padding
+	value = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, value)
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, "", errors.New("Invalid image_base64 (not valid base64)")
+	}
+	if len(data) == 0 {
+		return nil, "", errors.New("Invalid image_base64 (empty image)")
+	}
+	return data, contentType, nil
+}
+
+// writeDownloadError answers a failed image_url download
+func writeDownloadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrDownloadTooLarge):
+		http.Error(w, "Image too large", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, service.ErrURLNotAllowed), errors.Is(err, service.ErrDownloadNotImage):
+		http.Error(w, "Invalid image_url: "+err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Could not fetch image_url: "+err.Error(), http.StatusBadGateway)
+	}
+}
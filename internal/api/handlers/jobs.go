@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type JobsHandler struct {
+	imageService *service.ImageService
+}
+
+func NewJobsHandler(image *service.ImageService) *JobsHandler {
+	return &JobsHandler{
+		imageService: image,
+	}
+}
+
+// HandleListJobs lists queued, running and recently finished jobs.
+// Optional ?state= filters by queued, running, completed, error or cancelled.
+func (h *JobsHandler) HandleListJobs(w http.ResponseWriter, r *http.Request) {
+	jobs := h.imageService.ListJobs(r.URL.Query().Get("state"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs":  jobs,
+		"total": len(jobs),
+	})
+}
+
+// HandleGetJob returns a single job
+func (h *JobsHandler) HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+	if jobID == "" {
+		http.Error(w, "Job ID required", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.imageService.GetJob(jobID)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// HandleCancelJob cancels a queued or in-flight processing job
+func (h *JobsHandler) HandleCancelJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+	if jobID == "" {
+		http.Error(w, "Job ID required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.imageService.CancelJob(jobID); err != nil {
+		if errors.Is(err, service.ErrJobNotFound) {
+			http.Error(w, "Job not found or already finished", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrJobNotCancellable) {
+			http.Error(w, "Job is already storing its files and can no longer be cancelled", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to cancel job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      jobID,
+		"status":  "cancelling",
+		"message": "Cancellation requested",
+	})
+}
+
+// HandleRetryJob queues a failed upload again from its uploaded files
+func (h *JobsHandler) HandleRetryJob(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+	if jobID == "" {
+		http.Error(w, "Job ID required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.imageService.RetryJob(jobID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrJobNotFound):
+			http.Error(w, "No failed job with this ID", http.StatusNotFound)
+		case errors.Is(err, service.ErrJobNotRetryable):
+			http.Error(w, "Job can't be retried: its uploaded files are gone", http.StatusConflict)
+		case errors.Is(err, service.ErrQueueFull):
+			writeQueueFull(w, h.imageService)
+		default:
+			http.Error(w, "Failed to retry job: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      jobID,
+		"status":  "queued",
+		"message": "Job queued again",
+	})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type LegalHoldHandler struct {
+	imageService *service.ImageService
+}
+
+func NewLegalHoldHandler(imageService *service.ImageService) *LegalHoldHandler {
+	return &LegalHoldHandler{
+		imageService: imageService,
+	}
+}
+
+// HandleImageHold places (PUT) or clears (DELETE) the legal hold of an
+// image. While held, the image can't be deleted, by a bulk delete or a
+// retention rule either.
+func (h *LegalHoldHandler) HandleImageHold(w http.ResponseWriter, r *http.Request) {
+	hold := r.Method == http.MethodPut
+	updated, err := h.imageService.SetLegalHold(mux.Vars(r)["id"], hold, middleware.PrincipalFrom(r.Context()).Name)
+	if err != nil {
+		if errors.Is(err, service.ErrImageNotFound) {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to change legal hold", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// HandleProjectHold places (PUT) or clears (DELETE) the legal hold of a
+// project, which covers all of its images and keeps the project itself
+func (h *LegalHoldHandler) HandleProjectHold(w http.ResponseWriter, r *http.Request) {
+	hold := r.Method == http.MethodPut
+	project, err := h.imageService.SetProjectLegalHold(mux.Vars(r)["id"], hold, middleware.PrincipalFrom(r.Context()).Name)
+	if err != nil {
+		if errors.Is(err, service.ErrProjectNotFound) {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to change legal hold", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+// linkBase is the public URL of the server: PUBLIC_BASE_URL, or the scheme
+// and host the request was sent to when it isn't set
+func linkBase(baseURL string, r *http.Request) string {
+	if baseURL != "" {
+		return strings.TrimRight(baseURL, "/")
+	}
+	return requestBaseURL(r)
+}
+
+// imageLinks builds the URLs of an image; file and thumbnail are paths in
+// the data directory and may be empty
+func imageLinks(base, id, file, thumbnail string) *models.ImageLinks {
+	return &models.ImageLinks{
+		Self:      base + "/api/v1/images/" + url.PathEscape(id),
+		File:      dataFileURL(base, file),
+		Thumbnail: dataFileURL(base, thumbnail),
+		Detail:    base + "/?image=" + url.QueryEscape(id),
+		Share:     base + "/share/" + url.PathEscape(id),
+	}
+}
+
+// assetBase is the base of data file URLs: the CDN when CDN_BASE_URL is
+// set, else the server's
+func assetBase(base string) string {
+	if cdn := service.CDNBaseURL(); cdn != "" {
+		return cdn
+	}
+	return base
+}
+
+// dataFileURL is the URL a data file is served at, or empty without a path
+func dataFileURL(base, path string) string {
+	if path == "" {
+		return ""
+	}
+	return assetBase(base) + (&url.URL{Path: "/data/" + path}).EscapedPath()
+}
+
+// metadataLinks builds the URLs of an indexed image
+func metadataLinks(base string, img *service.ImageMetadata) *models.ImageLinks {
+	file := img.FilePath
+	if file == "" {
+		file = img.ModelFilePath
+	}
+	return imageLinks(base, img.ID, file, img.PreviewThumbnail())
+}
+
+// statusLinks builds the URLs of an image that may still be processing
+func statusLinks(base string, img *models.Image) *models.ImageLinks {
+	file := img.FilePath
+	if file == "" {
+		file = img.ModelFilePath
+	}
+	return imageLinks(base, img.ID, file, img.ThumbnailPath)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type MetricsHandler struct {
+	imageService   *service.ImageService
+	storageService *service.StorageService
+}
+
+func NewMetricsHandler(image *service.ImageService, storage *service.StorageService) *MetricsHandler {
+	return &MetricsHandler{
+		imageService:   image,
+		storageService: storage,
+	}
+}
+
+// metricsResponse is the queue metrics, plus the thumbnail cache's when it
+// is enabled
+type metricsResponse struct {
+	service.QueueMetrics
+	ThumbnailCache *service.ThumbnailCacheStats `json:"thumbnail_cache,omitempty"`
+}
+
+// HandleMetrics returns processing queue and status metrics, and those of
+// the thumbnail cache
+func (h *MetricsHandler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	response := metricsResponse{QueueMetrics: h.imageService.Metrics()}
+	if cache := h.storageService.ThumbnailCache(); cache != nil {
+		stats := cache.Stats()
+		response.ThumbnailCache = &stats
+	}
+	writeConditionalJSON(w, r, response, time.Time{})
+}
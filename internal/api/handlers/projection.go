@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+// GridImage is the compact projection returned by view=grid, carrying only
+// what a gallery needs to render a tile
+type GridImage struct {
+	ID                 string `json:"id"`
+	Title              string `json:"title"`
+	ThumbnailURL       string `json:"thumbnail_url,omitempty"`
+	SquareThumbnailURL string `json:"square_thumbnail_url,omitempty"` // subject-centered square crop
+	TurntableURL       string `json:"turntable_url,omitempty"`        // 3D objects with a full view set
+	Category           string `json:"category"`
+	Favorite           bool   `json:"favorite,omitempty"`  // starred by the caller
+	SeriesID           string `json:"series_id,omitempty"` // detected series the image stands for
+	SeriesSize         int    `json:"series_size,omitempty"`
+}
+
+// toGridImage builds the grid projection of an image. 3D objects use the
+// thumbnail of their poster view.
+func toGridImage(img *service.ImageMetadata) GridImage {
+	grid := GridImage{
+		ID:       img.ID,
+		Title:    img.Title,
+		Category: img.Category,
+		Favorite: img.Favorite,
+	}
+
+	if thumb := img.PreviewThumbnail(); thumb != "" {
+		grid.ThumbnailURL = service.DataURL(thumb)
+	}
+	if img.SquareThumbnail != "" {
+		grid.SquareThumbnailURL = service.DataURL(img.SquareThumbnail)
+	}
+	if img.TurntablePath != "" {
+		grid.TurntableURL = "/api/v1/images/" + img.ID + "/turntable"
+	}
+	if img.Series != nil {
+		grid.SeriesID, grid.SeriesSize = img.Series.ID, img.Series.Size
+	}
+
+	return grid
+}
+
+// GeoFeatureCollection is a GeoJSON FeatureCollection of image locations
+type GeoFeatureCollection struct {
+	Type     string       `json:"type"`
+	Features []GeoFeature `json:"features"`
+}
+
+// GeoFeature is a GeoJSON Point feature for one image
+type GeoFeature struct {
+	Type       string      `json:"type"`
+	ID         string      `json:"id"`
+	Geometry   GeoGeometry `json:"geometry"`
+	Properties GridImage   `json:"properties"`
+}
+
+// GeoGeometry is a GeoJSON Point; coordinates are [lon, lat]
+type GeoGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// toGeoFeature builds the GeoJSON feature of an image with a location. Its
+// properties are the grid projection, so map pins can show a thumbnail.
+func toGeoFeature(img *service.ImageMetadata) GeoFeature {
+	return GeoFeature{
+		Type: "Feature",
+		ID:   img.ID,
+		Geometry: GeoGeometry{
+			Type:        "Point",
+			Coordinates: [2]float64{img.Location.Lon, img.Location.Lat},
+		},
+		Properties: toGridImage(img),
+	}
+}
+
+// projectFields reduces each image to the requested JSON fields
+func projectFields(images []*service.ImageMetadata, fields []string) []map[string]interface{} {
+	projected := make([]map[string]interface{}, 0, len(images))
+	for _, img := range images {
+		data, _ := json.Marshal(img)
+		var full map[string]interface{}
+		json.Unmarshal(data, &full)
+
+		item := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			if value, ok := full[field]; ok {
+				item[field] = value
+			}
+		}
+		projected = append(projected, item)
+	}
+	return projected
+}
+
+// parseFields splits a comma-separated fields parameter, always keeping id
+func parseFields(param string) []string {
+	fields := []string{"id"}
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if field != "" && field != "id" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type ProjectsHandler struct {
+	projects *service.ProjectStore
+}
+
+func NewProjectsHandler(projects *service.ProjectStore) *ProjectsHandler {
+	return &ProjectsHandler{
+		projects: projects,
+	}
+}
+
+// HandleListProjects returns all projects
+func (h *ProjectsHandler) HandleListProjects(w http.ResponseWriter, r *http.Request) {
+	projects := h.projects.List()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"projects": projects,
+		"total":    len(projects),
+	})
+}
+
+// HandleGetProject returns a project
+func (h *ProjectsHandler) HandleGetProject(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.Get(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+// HandlePutProject creates or replaces the project with the ID in the path.
+// Defaults only apply to later uploads; existing images are not changed.
+func (h *ProjectsHandler) HandlePutProject(w http.ResponseWriter, r *http.Request) {
+	var project models.Project
+	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	project.ID = mux.Vars(r)["id"]
+
+	project.Normalize()
+	if err := project.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, created, err := h.projects.Put(project)
+	if err != nil {
+		http.Error(w, "Failed to save project", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(saved)
+}
+
+// HandleDeleteProject deletes a project. Its images keep their project field,
+// so they can still be listed by it. A project on legal hold is kept (409).
+func (h *ProjectsHandler) HandleDeleteProject(w http.ResponseWriter, r *http.Request) {
+	if err := h.projects.Delete(mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, service.ErrProjectNotFound) {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrLegalHold) {
+			http.Error(w, "Project is on legal hold", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to delete project", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+// needsWatermark reports whether previews served to this request must carry
+// the watermark: anonymous callers and viewers get marked copies, editors and
+// above the clean files
+func needsWatermark(r *http.Request, watermarker *service.Watermarker) bool {
+	return watermarker.Enabled() && !middleware.PrincipalFrom(r.Context()).Role.AtLeast(models.RoleEditor)
+}
+
+// serveRendition serves a stored preview rendition, substituting the
+// watermarked copy when the caller needs one. Thumbnails are served through
+// cache when one is given.
+func serveRendition(w http.ResponseWriter, r *http.Request, watermarker *service.Watermarker, cache *service.ThumbnailCache, filePath string) {
+	if watermarker.Enabled() {
+		// The response depends on the caller's role
+		w.Header().Add("Vary", "Authorization")
+	}
+
+	if needsWatermark(r, watermarker) {
+		marked, err := watermarker.WatermarkedPath(filePath)
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to watermark image", http.StatusInternalServerError)
+			return
+		}
+		filePath = marked
+	}
+
+	serveFile(w, r, cache, filePath)
+}
+
+// serveFile serves a stored file, thumbnails from cache when there is one
+func serveFile(w http.ResponseWriter, r *http.Request, cache *service.ThumbnailCache, filePath string) {
+	if cache == nil || !service.IsThumbnail(filePath) {
+		http.ServeFile(w, r, filePath)
+		return
+	}
+	content, modTime, err := cache.Read(filePath)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, filePath, modTime, bytes.NewReader(content))
+}
+
+// DataHandler serves the stored files of indexed images from the data
+// directory. Only paths the index accounts for are served, after
+// canonicalization and symlink checks; everything else under the data
+// directory (the index, metadata stores, temp uploads) is not found. Preview
+// renditions are watermarked for callers that need it; originals are always
+// served as is.
+type DataHandler struct {
+	storageService *service.StorageService
+	watermarker    *service.Watermarker
+	indexService   *service.IndexService
+	popularity     *service.PopularityStore
+	signer         *service.URLSigner
+	guestTokens    *service.GuestTokenStore
+	anonymous      bool // anonymous callers may fetch files unsigned
+}
+
+func NewDataHandler(storage *service.StorageService, watermarker *service.Watermarker, index *service.IndexService, popularity *service.PopularityStore, signer *service.URLSigner, guestTokens *service.GuestTokenStore) *DataHandler {
+	return &DataHandler{
+		storageService: storage,
+		watermarker:    watermarker,
+		indexService:   index,
+		popularity:     popularity,
+		signer:         signer,
+		guestTokens:    guestTokens,
+		anonymous:      true,
+	}
+}
+
+// SetAnonymousAccess sets whether anonymous callers may fetch files without a
+// signed URL or guest token when signing is disabled
+func (h *DataHandler) SetAnonymousAccess(allowed bool) {
+	h.anonymous = allowed
+}
+
+// ServeHTTP serves the file at the request path, relative to the data
+// directory. A guest token (token=) from a caller without an API token must
+// cover the image. Otherwise such callers need a signed URL (see
+// HandleSignedURL) with signing enabled, and are turned away when anonymous
+// access is off. Requests for an image's original count as a view, or as a download
+// with download=1, which also makes browsers save the file.
+func (h *DataHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	relPath := strings.TrimPrefix(r.URL.Path, "/")
+	if _, err := service.CanonicalPath(relPath); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	imageID, ok := h.indexService.ImageForFile(relPath)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if !unsignedAccess(r) {
+		if token := r.URL.Query().Get("token"); token != "" {
+			if !h.guestAccess(token, imageID) {
+				http.Error(w, "Invalid guest token", http.StatusForbidden)
+				return
+			}
+		} else if h.signer.Enabled() {
+			if err := h.signer.Verify(relPath, r.URL.Query(), time.Now()); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		} else if !h.anonymous {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	resolved, err := h.storageService.ResolvePath(relPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if info, err := os.Stat(resolved); err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	fullPath, _ := h.storageService.StoredPath(relPath)
+	if !service.IsPreviewRendition(relPath) {
+		h.recordAccess(w, r, relPath)
+	}
+	if service.IsRendition(relPath) {
+		private := h.signer.Enabled() || !h.anonymous || (h.watermarker.Enabled() && service.IsPreviewRendition(relPath))
+		w.Header().Set("Cache-Control", renditionCacheControl(r, private))
+	} else if service.CDNBaseURL() != "" {
+		// Revalidated on every request, so views and downloads are counted
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if !h.watermarker.Enabled() || !service.IsPreviewRendition(relPath) {
+		serveFile(w, r, h.storageService.ThumbnailCache(), fullPath)
+		return
+	}
+
+	serveRendition(w, r, h.watermarker, h.storageService.ThumbnailCache(), fullPath)
+}
+
+// renditionCacheControl returns the Cache-Control of a rendition. Versioned
+// URLs (v=, as listed by /renditions) are cached for a year as immutable,
+// others for a day. Private responses, which depend on the caller's role or
+// signature, are only cached by browsers.
+func renditionCacheControl(r *http.Request, private bool) string {
+	scope := "public"
+	if private {
+		scope = "private"
+	}
+	if r.URL.Query().Get("v") != "" {
+		return scope + ", max-age=31536000, immutable"
+	}
+	return scope + ", max-age=86400"
+}
+
+// unsignedAccess reports whether the caller may fetch data files without a
+// signature: callers with an API token, and everyone when auth is disabled
+// (they are admins then). Anonymous callers may need a signed URL or guest
+// token.
+func unsignedAccess(r *http.Request) bool {
+	principal := middleware.PrincipalFrom(r.Context())
+	return principal.Authenticated || principal.Role.AtLeast(models.RoleAdmin)
+}
+
+// guestAccess reports whether a guest token grants access to an image
+func (h *DataHandler) guestAccess(token, imageID string) bool {
+	guest, err := h.guestTokens.Resolve(token, time.Now())
+	if err != nil {
+		return false
+	}
+	img, err := h.indexService.GetImageByID(imageID)
+	return err == nil && guest.Allows(img)
+}
+
+// HandleSignedURL returns a /data/ URL for one of an image's files that works
+// without an API token until it expires. path defaults to the original (the
+// model of a 3D object) and ttl, e.g. 15m, to DATA_URL_TTL. Without
+// DATA_URL_SECRET the plain URL is returned. Only callers that could fetch
+// the file unsigned may sign URLs.
+func (h *DataHandler) HandleSignedURL(w http.ResponseWriter, r *http.Request) {
+	if !unsignedAccess(r) {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	metadata, err := h.indexService.GetImageByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	relPath := r.URL.Query().Get("path")
+	if relPath == "" {
+		relPath = metadata.FilePath
+		if relPath == "" {
+			relPath = metadata.ModelFilePath
+		}
+	}
+	if _, err := service.CanonicalPath(relPath); err != nil {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	if owner, ok := h.indexService.ImageForFile(relPath); !ok || owner != metadata.ID {
+		http.Error(w, "Path is not a file of this image", http.StatusNotFound)
+		return
+	}
+
+	var ttl time.Duration
+	if raw := r.URL.Query().Get("ttl"); raw != "" {
+		if ttl, err = time.ParseDuration(raw); err != nil || ttl <= 0 {
+			http.Error(w, "Invalid ttl", http.StatusBadRequest)
+			return
+		}
+	}
+
+	signed, expires := h.signer.URL(relPath, ttl, time.Now())
+	if download := r.URL.Query().Get("download"); download == "1" || download == "true" {
+		if strings.Contains(signed, "?") {
+			signed += "&download=1"
+		} else {
+			signed += "?download=1"
+		}
+	}
+
+	response := map[string]interface{}{"image_id": metadata.ID, "path": relPath, "url": signed}
+	if !expires.IsZero() {
+		response["expires_at"] = expires
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// recordAccess counts a GET of an original file. Range requests past the
+// start of the file continue a transfer that was already counted.
+func (h *DataHandler) recordAccess(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		return
+	}
+	if rng := r.Header.Get("Range"); rng != "" && !strings.HasPrefix(rng, "bytes=0-") {
+		return
+	}
+	imageID, ok := h.indexService.ImageForOriginal(name)
+	if !ok {
+		return
+	}
+
+	if download := r.URL.Query().Get("download"); download == "1" || download == "true" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": h.downloadFilename(imageID, name)}))
+		h.popularity.RecordDownload(imageID, time.Now())
+		return
+	}
+	h.popularity.RecordView(imageID, time.Now())
+}
+
+// downloadFilename is the name a downloaded original is saved under: the
+// filename it was uploaded with, or the stored name for images uploaded
+// before it was recorded and for the views of 3D objects
+func (h *DataHandler) downloadFilename(imageID, name string) string {
+	if metadata, err := h.indexService.GetImageByID(imageID); err == nil {
+		switch {
+		case name == metadata.FilePath && metadata.OriginalFilename != "":
+			return metadata.OriginalFilename
+		case name == metadata.ModelFilePath && metadata.ModelFilename != "":
+			return metadata.ModelFilename
+		}
+	}
+	return path.Base(name)
+}
+
+type RenditionsHandler struct {
+	indexService     *service.IndexService
+	renditionService *service.RenditionService
+}
+
+func NewRenditionsHandler(index *service.IndexService, renditions *service.RenditionService) *RenditionsHandler {
+	return &RenditionsHandler{
+		indexService:     index,
+		renditionService: renditions,
+	}
+}
+
+// HandleListRenditions lists the derivative files of an image
+func (h *RenditionsHandler) HandleListRenditions(w http.ResponseWriter, r *http.Request) {
+	metadata, err := h.indexService.GetImageByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"image_id":   metadata.ID,
+		"renditions": h.renditionService.List(metadata),
+	})
+}
+
+// HandleRegenerateRendition recreates one rendition of an image from its source
+func (h *RenditionsHandler) HandleRegenerateRendition(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	metadata, err := h.indexService.GetImageByID(vars["id"])
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	rendition, err := h.renditionService.Regenerate(r.Context(), metadata, vars["kind"])
+	if err != nil {
+		writeRenditionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rendition)
+}
+
+// HandleDeleteRendition deletes an optional rendition (cutout or upscale)
+func (h *RenditionsHandler) HandleDeleteRendition(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	metadata, err := h.indexService.GetImageByID(vars["id"])
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.renditionService.Delete(metadata, vars["kind"]); err != nil {
+		writeRenditionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleImageHealth checks that an image's original, derivatives and index
+// entry are intact
+func (h *RenditionsHandler) HandleImageHealth(w http.ResponseWriter, r *http.Request) {
+	metadata, err := h.indexService.GetImageByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.renditionService.Health(metadata))
+}
+
+// HandleHealImage regenerates the broken derivatives of an image and returns
+// the health report after the fixes
+func (h *RenditionsHandler) HandleHealImage(w http.ResponseWriter, r *http.Request) {
+	metadata, err := h.indexService.GetImageByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.renditionService.Heal(r.Context(), metadata))
+}
+
+// writeRenditionError maps rendition errors to HTTP statuses
+func writeRenditionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnknownRendition):
+		http.Error(w, "Unknown rendition for this image", http.StatusNotFound)
+	case errors.Is(err, service.ErrRenditionRequired), errors.Is(err, service.ErrUpscaleTooLarge),
+		errors.Is(err, service.ErrBackgroundNotUniform):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to update rendition", http.StatusInternalServerError)
+	}
+}
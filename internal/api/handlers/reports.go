@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type ReportsHandler struct {
+	reportService *service.ReportService
+}
+
+func NewReportsHandler(report *service.ReportService) *ReportsHandler {
+	return &ReportsHandler{
+		reportService: report,
+	}
+}
+
+// HandleWeeklyReport returns a digest of images processed in the last 7 days.
+// Optional ?days=N changes the period.
+func (h *ReportsHandler) HandleWeeklyReport(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		parsed, err := strconv.Atoi(daysStr)
+		if err != nil || parsed <= 0 || parsed > 366 {
+			http.Error(w, "Invalid days (1-366)", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -days)
+
+	report, err := h.reportService.Generate(from, to)
+	if err != nil {
+		http.Error(w, "Failed to generate report", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(h.reportService.FormatText(report)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
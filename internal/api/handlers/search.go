@@ -2,19 +2,27 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/yourcompany/image-warehousing/internal/models"
 	"github.com/yourcompany/image-warehousing/internal/service"
 )
 
 type SearchHandler struct {
 	searchService *service.SearchService
+	baseURL       string // PUBLIC_BASE_URL; taken from the request if empty
 }
 
-func NewSearchHandler(search *service.SearchService) *SearchHandler {
+func NewSearchHandler(search *service.SearchService, baseURL string) *SearchHandler {
 	return &SearchHandler{
 		searchService: search,
+		baseURL:       baseURL,
 	}
 }
 
@@ -38,13 +46,285 @@ func (h *SearchHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Perform search
-	results, err := h.searchService.Search(r.Context(), req.Query, req.Limit)
+	filter, err := searchFilter(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results, err := h.searchService.SearchWithFilter(r.Context(), req.Query, req.Limit, filter)
 	if err != nil {
 		http.Error(w, "Search failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Return results
+	h.addLinks(r, results)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(results)
 }
+
+// HandleBatchSearch runs several searches in one call, sharing a single read
+// of the index and batching the AI calls. Each query takes the same fields
+// as HandleSearch; responses come back in query order.
+func (h *SearchHandler) HandleBatchSearch(w http.ResponseWriter, r *http.Request) {
+	var req models.BatchSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Queries) == 0 {
+		http.Error(w, "At least one query is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Queries) > service.MaxBatchQueries {
+		http.Error(w, service.ErrTooManyQueries.Error(), http.StatusBadRequest)
+		return
+	}
+
+	queries := make([]service.BatchQuery, len(req.Queries))
+	for i := range req.Queries {
+		q := &req.Queries[i]
+		if q.Query == "" {
+			http.Error(w, fmt.Sprintf("Query %d: query is required", i+1), http.StatusBadRequest)
+			return
+		}
+		if q.Limit == 0 {
+			q.Limit = 10
+		}
+		filter, err := searchFilter(q)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Query %d: %v", i+1, err), http.StatusBadRequest)
+			return
+		}
+		queries[i] = service.BatchQuery{Query: q.Query, Limit: q.Limit, Filter: filter}
+	}
+
+	responses, err := h.searchService.SearchBatch(r.Context(), queries)
+	if err != nil {
+		http.Error(w, "Search failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, response := range responses {
+		h.addLinks(r, response)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.BatchSearchResponse{Results: responses})
+}
+
+// HandleStartSearchSession runs a search like HandleSearch and opens a
+// session its results can be refined in
+func (h *SearchHandler) HandleStartSearchSession(w http.ResponseWriter, r *http.Request) {
+	var req models.SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Query == "" {
+		http.Error(w, "Query is required", http.StatusBadRequest)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	filter, err := searchFilter(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := h.searchService.StartSession(r.Context(), req.Query, req.Limit, filter)
+	if err != nil {
+		http.Error(w, "Search failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.addLinks(r, &response.SearchResponse)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleRefineSearchSession refines the last search of a session, e.g.
+// "only the darker ones"
+func (h *SearchHandler) HandleRefineSearchSession(w http.ResponseWriter, r *http.Request) {
+	var req models.SearchRefineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Query == "" {
+		http.Error(w, "Query is required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.searchService.RefineSession(r.Context(), mux.Vars(r)["id"], req.Query, req.Limit)
+	if err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			http.Error(w, "Search session not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Search failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.addLinks(r, &response.SearchResponse)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleGetSearchSession returns the turns of a search session
+func (h *SearchHandler) HandleGetSearchSession(w http.ResponseWriter, r *http.Request) {
+	session, err := h.searchService.Session(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Search session not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// HandleDeleteSearchSession ends a search session
+func (h *SearchHandler) HandleDeleteSearchSession(w http.ResponseWriter, r *http.Request) {
+	if err := h.searchService.EndSession(mux.Vars(r)["id"]); err != nil {
+		http.Error(w, "Search session not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSearchStats returns the most asked and the zero-result queries of
+// the last 30 days. Optional ?days=N (1-366) changes the period and
+// ?limit=N (1-100, default 20) the length of the lists.
+func (h *SearchHandler) HandleSearchStats(w http.ResponseWriter, r *http.Request) {
+	searchLog := h.searchService.SearchLog()
+	if searchLog == nil {
+		http.Error(w, "Search log is not enabled", http.StatusNotFound)
+		return
+	}
+
+	days := 30
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		parsed, err := strconv.Atoi(daysStr)
+		if err != nil || parsed <= 0 || parsed > 366 {
+			http.Error(w, "Invalid days (1-366)", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 100 {
+			http.Error(w, "Invalid limit (1-100)", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	to := time.Now()
+	stats, err := searchLog.Stats(to.AddDate(0, 0, -days), to, limit)
+	if err != nil {
+		http.Error(w, "Failed to read search log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// addLinks fills in the URLs of the result images. Results may be shared
+// with a session, so they are copied first.
+func (h *SearchHandler) addLinks(r *http.Request, response *models.SearchResponse) {
+	if response == nil {
+		return
+	}
+	base := linkBase(h.baseURL, r)
+	results := make([]models.SearchResult, len(response.Results))
+	for i, result := range response.Results {
+		if result.Image != nil {
+			img := *result.Image
+			img.Links = statusLinks(base, &img)
+			result.Image = &img
+		}
+		results[i] = result
+	}
+	response.Results = results
+}
+
+// searchFilter builds and validates the metadata filter of a search request
+func searchFilter(req *models.SearchRequest) (service.SearchFilter, error) {
+	category := strings.Trim(strings.ToLower(strings.TrimSpace(req.Category)), "/")
+	if category != "" && !service.ValidCategoryPath(category) {
+		return service.SearchFilter{}, errors.New("Invalid category")
+	}
+	if req.Workflow != "" && !models.IsValidWorkflowState(req.Workflow) {
+		return service.SearchFilter{}, errors.New("Invalid workflow state")
+	}
+
+	aspectRatio, err := service.ParseAspectRatio(req.AspectRatio)
+	if err != nil {
+		return service.SearchFilter{}, err
+	}
+	dimensions := service.DimensionFilter{
+		MinWidth:        req.MinWidth,
+		MinHeight:       req.MinHeight,
+		MaxWidth:        req.MaxWidth,
+		MaxHeight:       req.MaxHeight,
+		MinMegapixels:   req.MinMegapixels,
+		AspectRatio:     aspectRatio,
+		AspectTolerance: req.AspectTolerance,
+	}
+	if err := dimensions.Validate(); err != nil {
+		return service.SearchFilter{}, err
+	}
+
+	size, err := service.ParseSizeFilter(req.MinSize, req.MaxSize)
+	if err != nil {
+		return service.SearchFilter{}, err
+	}
+
+	polygons := service.PolygonFilter{
+		MinTriangles: req.MinTris,
+		MaxTriangles: req.MaxTris,
+		LOD:          models.NormalizeLODTag(req.LOD),
+	}
+	if err := polygons.Validate(); err != nil {
+		return service.SearchFilter{}, err
+	}
+
+	textures, err := service.ParseTextureFilter(req.MinTexture, req.Shading)
+	if err != nil {
+		return service.SearchFilter{}, err
+	}
+
+	features := service.FeatureFilter{Feature: req.Feature, MinConfidence: req.MinConfidence}
+	if err := features.Validate(); err != nil {
+		return service.SearchFilter{}, err
+	}
+
+	if req.Weights != nil {
+		if err := req.Weights.Validate(); err != nil {
+			return service.SearchFilter{}, err
+		}
+	}
+
+	return service.SearchFilter{
+		Category:       category,
+		Workflow:       req.Workflow,
+		License:        req.License,
+		ExcludeExpired: req.ExcludeExpiredLicenses,
+		Dimensions:     dimensions,
+		Size:           size,
+		Polygons:       polygons,
+		Textures:       textures,
+		Attributes:     models.NormalizeAttributes(req.Attributes),
+		Project:        strings.ToLower(strings.TrimSpace(req.Project)),
+		Features:       features,
+		Weights:        req.Weights,
+	}, nil
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+// sharePage previews an image in chat and mail apps (Open Graph tags) and
+// sends browsers on to its detail page
+var sharePage = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>{{.Title}}</title>
+<meta property="og:type" content="website">
+<meta property="og:url" content="{{.Links.Share}}">
+<meta property="og:title" content="{{.Title}}">
+{{- if .Description}}
+<meta property="og:description" content="{{.Description}}">
+{{- end}}
+{{- if .Links.Thumbnail}}
+<meta property="og:image" content="{{.Links.Thumbnail}}">
+{{- end}}
+<meta http-equiv="refresh" content="0; url={{.Links.Detail}}">
+</head>
+<body><a href="{{.Links.Detail}}">{{.Title}}</a></body>
+</html>
+`))
+
+type ShareHandler struct {
+	indexService *service.IndexService
+	baseURL      string // PUBLIC_BASE_URL; taken from the request if empty
+}
+
+func NewShareHandler(index *service.IndexService, baseURL string) *ShareHandler {
+	return &ShareHandler{
+		indexService: index,
+		baseURL:      baseURL,
+	}
+}
+
+// HandleShare serves the share link of an image. Private images are
+// previewed without their title, description and thumbnail.
+func (h *ShareHandler) HandleShare(w http.ResponseWriter, r *http.Request) {
+	img, err := h.indexService.GetImageByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	links := metadataLinks(linkBase(h.baseURL, r), img)
+	page := struct {
+		Title       string
+		Description string
+		Links       *models.ImageLinks
+	}{Title: "Image Warehouse", Links: links}
+	if img.Visibility == models.VisibilityPrivate {
+		links.Thumbnail = ""
+	} else {
+		page.Title, page.Description = img.Title, img.Description
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	sharePage.Execute(w, page)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+// defaultTimelineSamples is the number of sample thumbnails per bucket
+const defaultTimelineSamples = 4
+
+type TimelineHandler struct {
+	indexService *service.IndexService
+}
+
+func NewTimelineHandler(index *service.IndexService) *TimelineHandler {
+	return &TimelineHandler{
+		indexService: index,
+	}
+}
+
+// timelineBucket is a day or month of the timeline response
+type timelineBucket struct {
+	Date    string      `json:"date"`
+	Count   int         `json:"count"`
+	Samples []GridImage `json:"samples"`
+}
+
+// HandleTimeline groups images into day or month buckets for chronological
+// browsing. by=upload (default) or capture (EXIF date, 2D photos only);
+// interval=day (default) or month; samples=N thumbnails per bucket (0-20);
+// category and project filter as in the list.
+func (h *TimelineHandler) HandleTimeline(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	by := query.Get("by")
+	if by == "" {
+		by = service.TimelineByUpload
+	}
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = service.TimelineDay
+	}
+	samples := defaultTimelineSamples
+	if value := query.Get("samples"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > 20 {
+			http.Error(w, "Invalid samples (0-20)", http.StatusBadRequest)
+			return
+		}
+		samples = n
+	}
+
+	lastModified, _ := h.indexService.LastModified()
+	images, err := h.indexService.GetAllImages()
+	if err != nil {
+		http.Error(w, "Failed to load images", http.StatusInternalServerError)
+		return
+	}
+
+	category, project := query.Get("category"), query.Get("project")
+	filtered := images[:0]
+	for _, img := range images {
+		if (category == "" || img.InCategory(category)) && (project == "" || img.Project == project) {
+			filtered = append(filtered, img)
+		}
+	}
+
+	timeline, err := service.BuildTimeline(filtered, by, interval, samples)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buckets := make([]timelineBucket, 0, len(timeline.Buckets))
+	for _, bucket := range timeline.Buckets {
+		grid := make([]GridImage, 0, len(bucket.Samples))
+		for _, img := range bucket.Samples {
+			grid = append(grid, toGridImage(img))
+		}
+		buckets = append(buckets, timelineBucket{Date: bucket.Date, Count: bucket.Count, Samples: grid})
+	}
+
+	writeConditionalJSON(w, r, map[string]interface{}{
+		"by":       by,
+		"interval": interval,
+		"buckets":  buckets,
+		"undated":  timeline.Undated,
+	}, lastModified)
+}
@@ -2,7 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/yourcompany/image-warehousing/internal/models"
 	"github.com/yourcompany/image-warehousing/internal/service"
@@ -11,18 +18,31 @@ import (
 type UploadHandler struct {
 	storageService *service.StorageService
 	imageService   *service.ImageService
+	idempotency    *service.IdempotencyStore
+	projects       *service.ProjectStore
 	maxUploadSize  int64
 }
 
-func NewUploadHandler(storage *service.StorageService, image *service.ImageService, maxSize int64) *UploadHandler {
+func NewUploadHandler(storage *service.StorageService, image *service.ImageService, idempotency *service.IdempotencyStore, projects *service.ProjectStore, maxSize int64) *UploadHandler {
 	return &UploadHandler{
 		storageService: storage,
 		imageService:   image,
+		idempotency:    idempotency,
+		projects:       projects,
 		maxUploadSize:  maxSize,
 	}
 }
 
 func (h *UploadHandler) Handle2DUpload(w http.ResponseWriter, r *http.Request) {
+	// Retried request with a known Idempotency-Key: return the original image
+	idemKey := idempotencyKey(r)
+	if idemKey.Key != "" {
+		if imageID, ok := h.idempotency.Lookup(idemKey); ok {
+			writeIdempotentReplay(w, h.imageService, imageID)
+			return
+		}
+	}
+
 	// Parse multipart form
 	if err := r.ParseMultipartForm(h.maxUploadSize); err != nil {
 		http.Error(w, "File too large or invalid form", http.StatusBadRequest)
@@ -37,44 +57,119 @@ func (h *UploadHandler) Handle2DUpload(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
+	job, err := newUploadJob(r.Form, h.projects)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Save to temp
+	saveStart := time.Now()
+	imageID, tempPath, err := h.storageService.SaveImageToTemp(file, header.Filename)
+	if err != nil {
+		http.Error(w, "Failed to save image", http.StatusInternalServerError)
+		return
+	}
+	job.ImageID = imageID
+	job.FilePath = tempPath
+	job.OriginalFilename = service.CleanFilename(header.Filename)
+	job.Timeline = []models.StageTiming{models.TimeStage(models.StageSave, saveStart)}
+
+	h.queueUpload(w, job, idemKey)
+}
+
+// newUploadJob builds a 2D upload job from the upload form's metadata fields;
+// the caller sets the image ID and file
+func newUploadJob(form url.Values, projects *service.ProjectStore) (*models.UploadJob, error) {
 	// Get metadata
-	title := r.FormValue("title")
-	artist := r.FormValue("artist")
+	title := form.Get("title")
+	artist := form.Get("artist")
 
 	// Parse tags (JSON array)
 	var tags []string
-	tagsStr := r.FormValue("tags")
+	tagsStr := form.Get("tags")
 	if tagsStr != "" {
 		if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
-			http.Error(w, "Invalid tags format", http.StatusBadRequest)
-			return
+			return nil, errors.New("Invalid tags format")
 		}
 	}
 
 	// Validate required fields
 	if title == "" || artist == "" {
-		http.Error(w, "Title and artist are required", http.StatusBadRequest)
-		return
+		return nil, errors.New("Title and artist are required")
 	}
 
-	// Save to temp
-	imageID, tempPath, err := h.storageService.SaveImageToTemp(file, header.Filename)
+	// Optional caller-supplied record ID (must be unique)
+	externalID := strings.TrimSpace(form.Get("external_id"))
+
+	// Optional license fields
+	license, err := parseLicenseForm(form)
 	if err != nil {
-		http.Error(w, "Failed to save image", http.StatusInternalServerError)
-		return
+		return nil, err
+	}
+
+	// Optional custom attributes (JSON object, e.g. {"client": "Acme", "sku": "A-100"})
+	attributes, err := parseAttributesForm(form)
+	if err != nil {
+		return nil, err
+	}
+
+	// Optional project (its defaults apply) and visibility
+	project, visibility, err := parseProjectForm(form, projects)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse priority (interactive uploads can jump ahead of bulk ingests)
+	priority, err := models.ParseJobPriority(form.Get("priority"))
+	if err != nil {
+		return nil, errors.New("Invalid priority (use low, normal or high)")
 	}
 
-	// Queue job for processing
 	job := &models.UploadJob{
-		ImageID:    imageID,
 		Type:       models.ImageType2D,
-		FilePath:   tempPath,
 		Title:      title,
 		Artist:     artist,
 		ManualTags: tags,
+		Priority:   priority,
+		ExternalID: externalID,
+		License:    license,
+		Attributes: attributes,
+		Visibility: visibility,
+	}
+	applyProject(job, project)
+	return job, nil
+}
+
+// queueUpload queues a saved 2D upload and answers with its image ID. The
+// temp file is removed if the job isn't queued.
+func (h *UploadHandler) queueUpload(w http.ResponseWriter, job *models.UploadJob, idemKey service.IdempotencyKey) {
+	imageID := job.ImageID
+
+	// A concurrent request with the same key may have won the race
+	if idemKey.Key != "" {
+		if originalID, ok := h.idempotency.Remember(idemKey, imageID); !ok {
+			h.storageService.CleanupTemp(job)
+			writeIdempotentReplay(w, h.imageService, originalID)
+			return
+		}
 	}
 
 	if err := h.imageService.QueueJob(job); err != nil {
+		if idemKey.Key != "" {
+			h.idempotency.Forget(idemKey)
+		}
+		if errors.Is(err, service.ErrExternalIDConflict) {
+			h.storageService.CleanupTemp(job)
+			http.Error(w, "External ID already in use: "+job.ExternalID, http.StatusConflict)
+			return
+		}
+		if errors.Is(err, service.ErrQueueFull) {
+			h.storageService.CleanupTemp(job)
+			writeQueueFull(w, h.imageService)
+			return
+		}
+		h.storageService.CleanupTemp(job)
 		http.Error(w, "Failed to queue job", http.StatusInternalServerError)
 		return
 	}
@@ -90,3 +185,93 @@ func (h *UploadHandler) Handle2DUpload(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(response)
 }
+
+// writeQueueFull refuses an upload while the processing queue is saturated,
+// telling the client how long to back off and how deep the queue is
+func writeQueueFull(w http.ResponseWriter, imageService *service.ImageService) {
+	metrics := imageService.Metrics()
+	retryAfter := int(math.Ceil(imageService.RetryAfter().Seconds()))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":          "Processing queue is full, retry later",
+		"queue_length":   metrics.QueueLength,
+		"queue_capacity": metrics.QueueCapacity,
+		"retry_after":    retryAfter,
+	})
+}
+
+// parseAttributesForm reads the optional custom attributes, a JSON object of
+// strings; empty values are dropped
+func parseAttributesForm(form url.Values) (map[string]string, error) {
+	raw := form.Get("attributes")
+	if raw == "" {
+		return nil, nil
+	}
+
+	var attributes map[string]string
+	if err := json.Unmarshal([]byte(raw), &attributes); err != nil {
+		return nil, errors.New("invalid attributes format (use a JSON object of strings)")
+	}
+	attributes = models.NormalizeAttributes(attributes)
+	for name, value := range attributes {
+		if value == "" {
+			delete(attributes, name)
+		}
+	}
+	return attributes, models.ValidateAttributes(attributes)
+}
+
+// parseProjectForm reads the optional project and visibility form fields.
+// The project must exist.
+func parseProjectForm(form url.Values, projects *service.ProjectStore) (*models.Project, string, error) {
+	visibility := strings.ToLower(strings.TrimSpace(form.Get("visibility")))
+	if visibility != "" && !models.IsValidVisibility(visibility) {
+		return nil, "", errors.New("invalid visibility (use public or private)")
+	}
+
+	id := strings.ToLower(strings.TrimSpace(form.Get("project")))
+	if id == "" {
+		return nil, visibility, nil
+	}
+	project, err := projects.Get(id)
+	if err != nil {
+		return nil, "", fmt.Errorf("unknown project: %s", id)
+	}
+	return &project, visibility, nil
+}
+
+// applyProject files a job under its project: the default tags are added,
+// the default visibility applies unless the upload set one, and the AI
+// category is limited to the project's categories
+func applyProject(job *models.UploadJob, project *models.Project) {
+	if project == nil {
+		return
+	}
+	job.Project = project.ID
+	job.ManualTags = project.WithDefaultTags(job.ManualTags)
+	if job.Visibility == "" {
+		job.Visibility = project.DefaultVisibility
+	}
+	job.Categories = project.Categories
+}
+
+// parseLicenseForm reads the optional license form fields; nil if none are set
+func parseLicenseForm(form url.Values) (*models.License, error) {
+	license := &models.License{
+		Type:         form.Get("license"),
+		RightsHolder: form.Get("rights_holder"),
+		ExpiresOn:    form.Get("license_expires"),
+		Restrictions: form.Get("usage_restrictions"),
+	}
+	license.Normalize()
+	if license.IsZero() {
+		return nil, nil
+	}
+	if err := license.Validate(); err != nil {
+		return nil, err
+	}
+	return license, nil
+}
@@ -2,8 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/yourcompany/image-warehousing/internal/models"
 	"github.com/yourcompany/image-warehousing/internal/service"
@@ -12,62 +19,50 @@ import (
 type Upload3DHandler struct {
 	storageService *service.StorageService
 	imageService   *service.ImageService
+	idempotency    *service.IdempotencyStore
+	projects       *service.ProjectStore
 	maxUploadSize  int64
 }
 
-func NewUpload3DHandler(storage *service.StorageService, image *service.ImageService, maxSize int64) *Upload3DHandler {
+func NewUpload3DHandler(storage *service.StorageService, image *service.ImageService, idempotency *service.IdempotencyStore, projects *service.ProjectStore, maxSize int64) *Upload3DHandler {
 	return &Upload3DHandler{
 		storageService: storage,
 		imageService:   image,
+		idempotency:    idempotency,
+		projects:       projects,
 		maxUploadSize:  maxSize * 6, // 6 images
 	}
 }
 
 func (h *Upload3DHandler) Handle3DUpload(w http.ResponseWriter, r *http.Request) {
+	// Retried request with a known Idempotency-Key: return the original object
+	idemKey := idempotencyKey(r)
+	if idemKey.Key != "" {
+		if imageID, ok := h.idempotency.Lookup(idemKey); ok {
+			writeIdempotentReplay(w, h.imageService, imageID)
+			return
+		}
+	}
+
 	// Parse multipart form (larger size for 6 images)
 	if err := r.ParseMultipartForm(h.maxUploadSize); err != nil {
 		http.Error(w, "Files too large or invalid form", http.StatusBadRequest)
 		return
 	}
 
-	// Get the 3D model file (required)
-	modelFile, modelHeader, err := r.FormFile("model")
-	if err != nil {
-		http.Error(w, "Missing 3D model file", http.StatusBadRequest)
-		return
-	}
-	defer modelFile.Close()
-
-	// Check if 4-surface or 6-surface mode
-	mode := r.FormValue("mode") // "4" or "6"
-	var views []string
-
-	if mode == "4" {
-		views = []string{"front", "back", "left", "right"}
+	// The files come from a zip archive or from separate form fields
+	var files *upload3DFiles
+	var err error
+	if _, ok := r.MultipartForm.File["archive"]; ok {
+		files, err = h.archiveFiles(r)
 	} else {
-		// Default to 6-surface mode
-		views = []string{"front", "back", "left", "right", "top", "bottom"}
+		files, err = h.formFiles(r)
 	}
-
-	viewFiles := make(map[string]multipart.File)
-	viewFilenames := make(map[string]string)
-
-	for _, view := range views {
-		file, header, err := r.FormFile(view)
-		if err != nil {
-			http.Error(w, "Missing view: "+view, http.StatusBadRequest)
-			return
-		}
-		viewFiles[view] = file
-		viewFilenames[view] = header.Filename
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-
-	// Defer closing all files
-	defer func() {
-		for _, file := range viewFiles {
-			file.Close()
-		}
-	}()
+	defer files.close()
 
 	// Get metadata
 	title := r.FormValue("title")
@@ -89,8 +84,59 @@ func (h *Upload3DHandler) Handle3DUpload(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Optional caller-supplied record ID (must be unique)
+	externalID := strings.TrimSpace(r.FormValue("external_id"))
+
+	// Optional license fields
+	license, err := parseLicenseForm(r.Form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Optional custom attributes (JSON object, e.g. {"client": "Acme", "sku": "A-100"})
+	attributes, err := parseAttributesForm(r.Form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Optional real-world size (e.g. "120x80x45 cm"); measured from the model otherwise
+	var bounds *models.Bounds
+	if value := strings.TrimSpace(r.FormValue("bounds")); value != "" {
+		bounds, err = models.ParseBounds(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bounds.Source = models.BoundsSourceManual
+	}
+
+	// Optional triangle count (counted from the model otherwise) and LOD tags
+	// (JSON array, e.g. ["lod0", "game-ready"])
+	triangles, lodTags, err := parsePolygonForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Optional project (its defaults apply) and visibility
+	project, visibility, err := parseProjectForm(r.Form, h.projects)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Parse priority (interactive uploads can jump ahead of bulk ingests)
+	priority, err := models.ParseJobPriority(r.FormValue("priority"))
+	if err != nil {
+		http.Error(w, "Invalid priority (use low, normal or high)", http.StatusBadRequest)
+		return
+	}
+
 	// Save to temp (including model file)
-	imageID, tempPaths, modelPath, err := h.storageService.Save3DObjectToTemp(modelFile, modelHeader.Filename, viewFiles, viewFilenames)
+	saveStart := time.Now()
+	imageID, tempPaths, modelPath, err := files.save()
 	if err != nil {
 		http.Error(w, "Failed to save 3D object: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -102,21 +148,62 @@ func (h *Upload3DHandler) Handle3DUpload(w http.ResponseWriter, r *http.Request)
 		Type:           models.ImageType3D,
 		FilePaths:      tempPaths,
 		ModelFilePath:  modelPath,
-		ModelFilename:  modelHeader.Filename,
+		ModelFilename:  files.modelFilename,
+		Bounds:         bounds,
+		Triangles:      triangles,
+		LODTags:        lodTags,
 		Title:          title,
 		Artist:         artist,
 		ManualTags:     tags,
+		Priority:       priority,
+		ExternalID:     externalID,
+		License:        license,
+		Attributes:     attributes,
+		Visibility:     visibility,
+		Timeline:       []models.StageTiming{models.TimeStage(models.StageSave, saveStart)},
+	}
+	applyProject(job, project)
+	if files.hasClip {
+		job.ClipPath = tempPaths[service.ClipView]
+		job.ClipFrames = files.clipFrames
+		job.FilePaths = nil
+	}
+
+	// A concurrent request with the same key may have won the race
+	if idemKey.Key != "" {
+		if originalID, ok := h.idempotency.Remember(idemKey, imageID); !ok {
+			h.storageService.CleanupTemp(job)
+			writeIdempotentReplay(w, h.imageService, originalID)
+			return
+		}
 	}
 
 	if err := h.imageService.QueueJob(job); err != nil {
+		if idemKey.Key != "" {
+			h.idempotency.Forget(idemKey)
+		}
+		if errors.Is(err, service.ErrExternalIDConflict) {
+			h.storageService.CleanupTemp(job)
+			http.Error(w, "External ID already in use: "+externalID, http.StatusConflict)
+			return
+		}
+		if errors.Is(err, service.ErrQueueFull) {
+			h.storageService.CleanupTemp(job)
+			writeQueueFull(w, h.imageService)
+			return
+		}
+		h.storageService.CleanupTemp(job)
 		http.Error(w, "Failed to queue job", http.StatusInternalServerError)
 		return
 	}
 
 	// Return response
-	viewCount := len(views)
+	viewCount := len(files.views)
 	var viewsText string
-	if viewCount == 4 {
+	if files.hasClip {
+		viewCount = files.clipFrames
+		viewsText = "turntable clip, " + strconv.Itoa(files.clipFrames) + " frames"
+	} else if viewCount == 4 {
 		viewsText = "4 views"
 	} else {
 		viewsText = "6 views"
@@ -132,3 +219,145 @@ func (h *Upload3DHandler) Handle3DUpload(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(response)
 }
+
+// upload3DFiles are the files of a 3D upload
+type upload3DFiles struct {
+	modelFilename string
+	views         []string // view names; ClipView alone for a turntable clip
+	hasClip       bool
+	clipFrames    int
+
+	// save writes the files to a new temp object folder and returns its ID,
+	// the view paths and the model path
+	save  func() (string, map[string]string, string, error)
+	close func()
+}
+
+// formFiles reads the model, the views (or a turntable clip) and the
+// optional materials from separate form fields
+func (h *Upload3DHandler) formFiles(r *http.Request) (*upload3DFiles, error) {
+	// Get the 3D model file (required)
+	modelFile, modelHeader, err := r.FormFile("model")
+	if err != nil {
+		return nil, errors.New("Missing 3D model file")
+	}
+
+	// The model's content must match its extension (e.g. a USDZ archive)
+	if err := service.ValidateModelFile(modelFile, modelHeader.Filename); err != nil {
+		modelFile.Close()
+		return nil, err
+	}
+	if _, err := modelFile.Seek(0, io.SeekStart); err != nil {
+		modelFile.Close()
+		return nil, errors.New("Failed to read 3D model file")
+	}
+
+	files := &upload3DFiles{modelFilename: service.CleanFilename(modelHeader.Filename), clipFrames: service.DefaultClipFrames}
+	viewFiles := make(map[string]multipart.File)
+	viewFilenames := make(map[string]string)
+	files.close = func() {
+		modelFile.Close()
+		for _, file := range viewFiles {
+			file.Close()
+		}
+	}
+
+	// A turntable clip replaces the discrete views: frames are extracted
+	// from it during processing
+	_, files.hasClip = r.MultipartForm.File[service.ClipView]
+	if frames := r.FormValue("frames"); files.hasClip && frames != "" {
+		n, err := strconv.Atoi(frames)
+		if err != nil || n < service.MinClipFrames || n > service.MaxClipFrames {
+			files.close()
+			return nil, service.ErrInvalidFrameCount
+		}
+		files.clipFrames = n
+	}
+
+	// Check if 4-surface or 6-surface mode
+	mode := r.FormValue("mode") // "4" or "6"
+	if files.hasClip {
+		files.views = []string{service.ClipView}
+	} else if mode == "4" {
+		files.views = service.Views4
+	} else {
+		// Default to 6-surface mode
+		files.views = service.Views6
+	}
+
+	for _, view := range files.views {
+		file, header, err := r.FormFile(view)
+		if err != nil {
+			files.close()
+			return nil, errors.New("Missing view: " + view)
+		}
+		viewFiles[view] = file
+		viewFilenames[view] = header.Filename
+	}
+
+	// Optional companion files of the model: .mtl libraries and textures
+	materials := r.MultipartForm.File["materials"]
+	for _, header := range materials {
+		if !service.IsMaterialFile(header.Filename) {
+			files.close()
+			return nil, errors.New("Unsupported material file: " + header.Filename + " (use .mtl, .bin or texture images)")
+		}
+	}
+
+	files.save = func() (string, map[string]string, string, error) {
+		imageID, tempPaths, modelPath, err := h.storageService.Save3DObjectToTemp(modelFile, modelHeader.Filename, viewFiles, viewFilenames)
+		if err != nil || len(materials) == 0 {
+			return imageID, tempPaths, modelPath, err
+		}
+		if err := h.storageService.SaveMaterialsToTemp(imageID, materials); err != nil {
+			h.storageService.CleanupTemp(&models.UploadJob{ImageID: imageID, Type: models.ImageType3D})
+			return "", nil, "", err
+		}
+		return imageID, tempPaths, modelPath, nil
+	}
+	return files, nil
+}
+
+// archiveFiles reads the model, views and materials from a zip archive,
+// laid out by its manifest.json or by file names
+func (h *Upload3DHandler) archiveFiles(r *http.Request) (*upload3DFiles, error) {
+	file, header, err := r.FormFile("archive")
+	if err != nil {
+		return nil, errors.New("Missing archive file")
+	}
+	archive, err := service.OpenArchive3D(file, header.Size, h.maxUploadSize)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &upload3DFiles{
+		modelFilename: path.Base(archive.Manifest.Model),
+		views:         archive.ViewNames(),
+		save: func() (string, map[string]string, string, error) {
+			return h.storageService.Save3DArchiveToTemp(archive)
+		},
+		close: func() { file.Close() },
+	}, nil
+}
+
+// parsePolygonForm reads the optional triangle count and LOD tags
+func parsePolygonForm(r *http.Request) (int, []string, error) {
+	triangles := 0
+	if value := strings.TrimSpace(r.FormValue("triangles")); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, nil, fmt.Errorf("invalid triangles: %s", value)
+		}
+		triangles = n
+	}
+
+	var lodTags []string
+	if value := r.FormValue("lod_tags"); value != "" {
+		if err := json.Unmarshal([]byte(value), &lodTags); err != nil {
+			return 0, nil, errors.New("invalid lod_tags format (use a JSON array of strings)")
+		}
+		lodTags = models.NormalizeLODTags(lodTags)
+	}
+	return triangles, lodTags, models.ValidateLODTags(lodTags)
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed upload.html
+var uploadPage []byte
+
+// HandlePage serves the upload form: drag-and-drop images, or a 3D model with
+// its views, posted to the upload endpoints. The page itself is public; the
+// uploads need an editor token when API_TOKENS is set.
+func (h *UploadHandler) HandlePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(uploadPage)
+}
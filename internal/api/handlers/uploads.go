@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+// UploadSessionHeader names the upload session an upload reports its
+// progress to
+const UploadSessionHeader = "Upload-Session"
+
+type UploadSessionsHandler struct {
+	sessions *service.UploadSessionStore
+}
+
+func NewUploadSessionsHandler(sessions *service.UploadSessionStore) *UploadSessionsHandler {
+	return &UploadSessionsHandler{
+		sessions: sessions,
+	}
+}
+
+// HandleCreateSession starts an upload session. Send its ID in the
+// Upload-Session header of the upload and poll HandleGetSession for the
+// bytes received.
+func (h *UploadSessionsHandler) HandleCreateSession(w http.ResponseWriter, r *http.Request) {
+	session := h.sessions.Create()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// HandleGetSession returns the progress of an upload session
+func (h *UploadSessionsHandler) HandleGetSession(w http.ResponseWriter, r *http.Request) {
+	session, err := h.sessions.Get(mux.Vars(r)["session"])
+	if err != nil {
+		http.Error(w, "Upload session not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(session)
+}
+
+// Track counts the request body of an upload that names a session in the
+// Upload-Session header as it is read, and records the response status when
+// the upload finishes. Uploads without the header pass through.
+func (h *UploadSessionsHandler) Track(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSpace(r.Header.Get(UploadSessionHeader))
+		if id == "" {
+			next(w, r)
+			return
+		}
+
+		if err := h.sessions.Start(id, r.ContentLength); err != nil {
+			if errors.Is(err, service.ErrUploadSessionUsed) {
+				http.Error(w, "Upload session already used", http.StatusConflict)
+				return
+			}
+			http.Error(w, "Unknown upload session: "+id, http.StatusBadRequest)
+			return
+		}
+
+		r.Body = &progressReader{ReadCloser: r.Body, add: func(n int64) { h.sessions.AddReceived(id, n) }}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() { h.sessions.Finish(id, recorder.status) }()
+		next(recorder, r)
+	}
+}
+
+// progressReader reports the bytes read from a request body
+type progressReader struct {
+	io.ReadCloser
+	add func(n int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.add(int64(n))
+	}
+	return n, err
+}
+
+// statusRecorder remembers the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type UpscaleHandler struct {
+	indexService   *service.IndexService
+	upscaleService *service.UpscaleService
+}
+
+func NewUpscaleHandler(index *service.IndexService, upscales *service.UpscaleService) *UpscaleHandler {
+	return &UpscaleHandler{
+		indexService:   index,
+		upscaleService: upscales,
+	}
+}
+
+// HandleUpscale creates (or returns the existing) upscaled rendition of a 2D
+// image at ?factor=2 or 4 and links it in the image's metadata
+func (h *UpscaleHandler) HandleUpscale(w http.ResponseWriter, r *http.Request) {
+	factor, err := strconv.Atoi(r.URL.Query().Get("factor"))
+	if err != nil || !service.ValidUpscaleFactor(factor) {
+		http.Error(w, service.ErrInvalidUpscaleFactor.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metadata, err := h.indexService.GetImageByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	result, err := h.upscaleService.Upscale(r.Context(), metadata, factor)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUpscaleUnsupported), errors.Is(err, service.ErrUpscaleTooLarge):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to upscale image", http.StatusBadGateway)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
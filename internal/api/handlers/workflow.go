@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/api/middleware"
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type WorkflowHandler struct {
+	workflowService *service.WorkflowService
+}
+
+func NewWorkflowHandler(workflow *service.WorkflowService) *WorkflowHandler {
+	return &WorkflowHandler{
+		workflowService: workflow,
+	}
+}
+
+// transitionRequest is the body of POST /images/{id}/workflow
+type transitionRequest struct {
+	To      string `json:"to"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// HandleTransition moves an image to another workflow state. Submitting for
+// review needs the editor role; approving and rejecting need reviewer.
+func (h *WorkflowHandler) HandleTransition(w http.ResponseWriter, r *http.Request) {
+	imageID := mux.Vars(r)["id"]
+
+	var req transitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !models.IsValidWorkflowState(req.To) {
+		http.Error(w, "Invalid workflow state (use draft, in-review, approved or rejected)", http.StatusBadRequest)
+		return
+	}
+
+	principal := middleware.PrincipalFrom(r.Context())
+	updated, err := h.workflowService.Transition(imageID, req.To, principal, req.Comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrImageNotFound):
+			http.Error(w, "Image not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrForbidden):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, service.ErrRevisionMismatch):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Failed to change workflow state", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
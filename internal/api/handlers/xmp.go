@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yourcompany/image-warehousing/internal/service"
+)
+
+type XMPHandler struct {
+	indexService *service.IndexService
+	xmpService   *service.XMPService
+}
+
+func NewXMPHandler(index *service.IndexService, xmp *service.XMPService) *XMPHandler {
+	return &XMPHandler{
+		indexService: index,
+		xmpService:   xmp,
+	}
+}
+
+// HandleGetXMP serves an image's metadata as an XMP sidecar, named after the
+// original file so it can be dropped next to it
+func (h *XMPHandler) HandleGetXMP(w http.ResponseWriter, r *http.Request) {
+	metadata, err := h.indexService.GetImageByID(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Image not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/rdf+xml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", service.XMPSidecarName(metadata)))
+	w.Write(service.BuildXMP(metadata))
+}
+
+// HandleExport starts writing the sidecars of many images and returns its
+// job ID; progress is available from the jobs API
+func (h *XMPHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	var req service.XMPExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	jobID, err := h.xmpService.StartExport(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job_id": jobID,
+		"status": "queued",
+	})
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Access log formats
+const (
+	AccessLogCommon   = "common"   // NCSA Common Log Format
+	AccessLogCombined = "combined" // Common plus referer and user agent
+	AccessLogJSON     = "json"     // one JSON object per line
+)
+
+// clfTime is the timestamp layout of the Common Log Format
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+type accessUserKey struct{}
+
+// accessLogEntry is a line of the JSON access log
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	User       string    `json:"user,omitempty"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Protocol   string    `json:"protocol"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMs float64   `json:"duration_ms"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// accessRecorder captures the status and size of a response
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rw *accessRecorder) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *accessRecorder) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// AccessLog writes a line per request to out in a standard format (common,
+// combined or json), for log pipelines such as GoAccess or ELK. It is kept
+// apart from the application log. The user is the API token's name, as
+// resolved by Auth further in.
+func AccessLog(out io.Writer, format string) (func(http.Handler) http.Handler, error) {
+	switch format {
+	case AccessLogCommon, AccessLogCombined, AccessLogJSON:
+	default:
+		return nil, fmt.Errorf("unknown access log format %q (want common, combined or json)", format)
+	}
+
+	var mutex sync.Mutex
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			user := new(string)
+			rw := &accessRecorder{ResponseWriter: w}
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), accessUserKey{}, user)))
+
+			if rw.status == 0 {
+				rw.status = http.StatusOK
+			}
+			entry := accessLogEntry{
+				Time:       start,
+				RemoteAddr: remoteHost(r.RemoteAddr),
+				User:       *user,
+				Method:     r.Method,
+				URI:        r.RequestURI,
+				Protocol:   r.Proto,
+				Status:     rw.status,
+				Bytes:      rw.bytes,
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+				Referer:    r.Referer(),
+				UserAgent:  r.UserAgent(),
+			}
+			line := formatAccessLine(entry, format)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			io.WriteString(out, line)
+		})
+	}, nil
+}
+
+// setAccessUser records the caller's name for the access log, if the
+// request is being logged
+func setAccessUser(ctx context.Context, name string) {
+	if user, ok := ctx.Value(accessUserKey{}).(*string); ok {
+		*user = name
+	}
+}
+
+// formatAccessLine renders an entry as a line of the given format
+func formatAccessLine(entry accessLogEntry, format string) string {
+	if format == AccessLogJSON {
+		data, _ := json.Marshal(entry)
+		return string(data) + "\n"
+	}
+
+	bytes := "-"
+	if entry.Bytes > 0 {
+		bytes = strconv.FormatInt(entry.Bytes, 10)
+	}
+	line := fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s`,
+		entry.RemoteAddr, clfField(strings.Join(strings.Fields(clfEscape(entry.User)), "_")), entry.Time.Format(clfTime),
+		entry.Method, clfEscape(entry.URI), entry.Protocol, entry.Status, bytes)
+	if format == AccessLogCombined {
+		line += fmt.Sprintf(` "%s" "%s"`, clfField(clfEscape(entry.Referer)), clfField(clfEscape(entry.UserAgent)))
+	}
+	return line + "\n"
+}
+
+// clfField returns "-" for an empty field, as the Common Log Format has it
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// clfEscape escapes quotes, backslashes and control characters, so a value
+// can't break out of its quoted field or the line
+func clfEscape(s string) string {
+	quoted := strconv.Quote(s)
+	return quoted[1 : len(quoted)-1]
+}
+
+// remoteHost returns the host of a RemoteAddr, without the port
+func remoteHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
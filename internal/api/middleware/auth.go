@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+type principalKey struct{}
+
+// ParseTokens parses API_TOKENS, a comma-separated list of name:token:role
+// entries, into a token -> principal map
+func ParseTokens(spec string) (map[string]models.Principal, error) {
+	tokens := make(map[string]models.Principal)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid API token entry %q (want name:token:role)", entry)
+		}
+		role, err := models.ParseRole(parts[2])
+		if err != nil {
+			return nil, err
+		}
+		tokens[parts[1]] = models.Principal{Name: parts[0], Role: role, Authenticated: true}
+	}
+	return tokens, nil
+}
+
+// Auth resolves the caller of each request from its bearer token. Without
+// configured tokens auth is disabled: every caller is an admin named by the
+// X-Actor header. With tokens, requests without one are anonymous viewers and
+// unknown tokens are rejected.
+func Auth(tokens map[string]models.Principal) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := models.Principal{Name: "anonymous", Role: models.RoleViewer}
+
+			if len(tokens) == 0 {
+				principal.Role = models.RoleAdmin
+				if actor := r.Header.Get("X-Actor"); actor != "" {
+					principal.Name = actor
+				}
+			} else if token := bearerToken(r); token != "" {
+				known, ok := tokens[token]
+				if !ok {
+					http.Error(w, "Invalid API token", http.StatusUnauthorized)
+					return
+				}
+				principal = known
+			}
+
+			if principal.Name != "anonymous" {
+				setAccessUser(r.Context(), principal.Name)
+			}
+			ctx := context.WithValue(r.Context(), principalKey{}, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireRole rejects requests whose caller has less than the given role
+func RequireRole(role models.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := PrincipalFrom(r.Context())
+		if !principal.Role.AtLeast(role) {
+			if !principal.Authenticated {
+				http.Error(w, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "Insufficient role", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireCaller rejects anonymous callers, except on the open paths. It
+// guards the API when ANONYMOUS_ACCESS is off.
+func RequireCaller(open ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := PrincipalFrom(r.Context())
+			if principal.Authenticated || principal.Role.AtLeast(models.RoleAdmin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			for _, path := range open {
+				if r.URL.Path == path {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+		})
+	}
+}
+
+// PrincipalFrom returns the caller stored in ctx by Auth. Requests that did
+// not pass through Auth are treated as anonymous viewers.
+func PrincipalFrom(ctx context.Context) models.Principal {
+	if principal, ok := ctx.Value(principalKey{}).(models.Principal); ok {
+		return principal
+	}
+	return models.Principal{Name: "anonymous", Role: models.RoleViewer}
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
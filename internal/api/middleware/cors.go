@@ -20,8 +20,9 @@ func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
 
 			if allowed {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
-				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, Upload-Session, If-None-Match, If-Modified-Since, If-Match, X-Actor")
+				w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified")
 				w.Header().Set("Access-Control-Max-Age", "3600")
 			}
 
@@ -8,16 +8,36 @@ import (
 	"github.com/yourcompany/image-warehousing/internal/api/handlers"
 	"github.com/yourcompany/image-warehousing/internal/api/middleware"
 	"github.com/yourcompany/image-warehousing/internal/config"
+	"github.com/yourcompany/image-warehousing/internal/models"
 	"github.com/yourcompany/image-warehousing/internal/service"
 )
 
 type Router struct {
-	router          *mux.Router
-	uploadHandler   *handlers.UploadHandler
-	upload3DHandler *handlers.Upload3DHandler
-	searchHandler   *handlers.SearchHandler
-	imagesHandler   *handlers.ImagesHandler
-	healthHandler   *handlers.HealthHandler
+	router             *mux.Router
+	uploadHandler      *handlers.UploadHandler
+	upload3DHandler    *handlers.Upload3DHandler
+	searchHandler      *handlers.SearchHandler
+	imagesHandler      *handlers.ImagesHandler
+	healthHandler      *handlers.HealthHandler
+	metricsHandler     *handlers.MetricsHandler
+	jobsHandler        *handlers.JobsHandler
+	adminHandler       *handlers.AdminHandler
+	dashboardHandler   *handlers.DashboardHandler
+	reportsHandler     *handlers.ReportsHandler
+	bulkHandler        *handlers.BulkUpdateHandler
+	bulkDeleteHandler  *handlers.BulkDeleteHandler
+	analyzeHandler     *handlers.AnalyzeHandler
+	categoriesHandler  *handlers.CategoriesHandler
+	annotationsHandler *handlers.AnnotationsHandler
+	workflowHandler    *handlers.WorkflowHandler
+	cutoutHandler      *handlers.CutoutHandler
+	upscaleHandler     *handlers.UpscaleHandler
+	renditionsHandler  *handlers.RenditionsHandler
+	projectsHandler    *handlers.ProjectsHandler
+	timelineHandler    *handlers.TimelineHandler
+	changesHandler     *handlers.ChangesHandler
+	favoritesHandler   *handlers.FavoritesHandler
+	uploadsHandler     *handlers.UploadSessionsHandler
 }
 
 func NewRouter(
@@ -26,20 +46,75 @@ func NewRouter(
 	imageService   *service.ImageService,
 	indexService   *service.IndexService,
 	searchService  *service.SearchService,
+	idempotency    *service.IdempotencyStore,
+	uploadSessions *service.UploadSessionStore,
+	backfill       *service.BackfillService,
+	consolidate    *service.ConsolidateService,
+	retention      *service.RetentionService,
+	erasure        *service.ErasureService,
+	audit          *service.AuditLog,
+	reportService  *service.ReportService,
+	bulkService    *service.BulkUpdateService,
+	bulkDelete     *service.BulkDeleteService,
+	spriteService  *service.SpriteService,
+	cutoutService  *service.CutoutService,
+	upscaleService *service.UpscaleService,
+	renditions     *service.RenditionService,
+	xmpService     *service.XMPService,
+	annotations    *service.AnnotationStore,
+	projects       *service.ProjectStore,
+	guestTokens    *service.GuestTokenStore,
+	journal        *service.IndexJournal,
+	popularity     *service.PopularityStore,
+	favorites      *service.FavoriteStore,
+	workflow       *service.WorkflowService,
+	watermarker    *service.Watermarker,
+	tokens         map[string]models.Principal,
 	logger         *logrus.Logger,
 ) *Router {
 	r := mux.NewRouter()
 
 	// Initialize handlers
-	uploadHandler := handlers.NewUploadHandler(storageService, imageService, cfg.MaxUploadSize)
-	upload3DHandler := handlers.NewUpload3DHandler(storageService, imageService, cfg.MaxUploadSize)
-	searchHandler := handlers.NewSearchHandler(searchService)
-	imagesHandler := handlers.NewImagesHandler(imageService, indexService)
+	uploadHandler := handlers.NewUploadHandler(storageService, imageService, idempotency, projects, cfg.MaxUploadSize)
+	upload3DHandler := handlers.NewUpload3DHandler(storageService, imageService, idempotency, projects, cfg.MaxUploadSize)
+	searchHandler := handlers.NewSearchHandler(searchService, cfg.PublicBaseURL)
+	imagesHandler := handlers.NewImagesHandler(storageService, imageService, indexService, annotations, projects, watermarker, renditions, popularity, favorites, cfg.PublicBaseURL)
 	healthHandler := handlers.NewHealthHandler()
+	metricsHandler := handlers.NewMetricsHandler(imageService, storageService)
+	jobsHandler := handlers.NewJobsHandler(imageService)
+	adminHandler := handlers.NewAdminHandler(backfill, consolidate, retention, audit)
+	dashboardHandler := handlers.NewDashboardHandler(imageService, storageService)
+	reportsHandler := handlers.NewReportsHandler(reportService)
+	bulkHandler := handlers.NewBulkUpdateHandler(bulkService)
+	bulkDeleteHandler := handlers.NewBulkDeleteHandler(bulkDelete)
+	erasureHandler := handlers.NewErasureHandler(erasure)
+	analyzeHandler := handlers.NewAnalyzeHandler(storageService, imageService, cfg.MaxUploadSize)
+	categoriesHandler := handlers.NewCategoriesHandler(spriteService, watermarker)
+	annotationsHandler := handlers.NewAnnotationsHandler(indexService, annotations)
+	workflowHandler := handlers.NewWorkflowHandler(workflow)
+	cutoutHandler := handlers.NewCutoutHandler(indexService, cutoutService)
+	upscaleHandler := handlers.NewUpscaleHandler(indexService, upscaleService)
+	renditionsHandler := handlers.NewRenditionsHandler(indexService, renditions)
+	xmpHandler := handlers.NewXMPHandler(indexService, xmpService)
+	projectsHandler := handlers.NewProjectsHandler(projects)
+	legalHoldHandler := handlers.NewLegalHoldHandler(imageService)
+	galleryHandler := handlers.NewGalleryHandler(indexService, projects, guestTokens)
+	timelineHandler := handlers.NewTimelineHandler(indexService)
+	feedHandler := handlers.NewFeedHandler(indexService, cfg.PublicBaseURL)
+	shareHandler := handlers.NewShareHandler(indexService, cfg.PublicBaseURL)
+	graphqlHandler := handlers.NewGraphQLHandler(indexService, searchService, projects, renditions, annotations, popularity)
+	changesHandler := handlers.NewChangesHandler(journal)
+	favoritesHandler := handlers.NewFavoritesHandler(indexService, favorites)
+	uploadsHandler := handlers.NewUploadSessionsHandler(uploadSessions)
+	designHandler := handlers.NewDesignHandler(uploadHandler)
+	ingestHandler := handlers.NewIngestHandler(uploadHandler, service.NewImageDownloader(cfg.IngestURLTimeout, cfg.MaxUploadSize, cfg.IngestAllowPrivateURLs))
+	dataHandler := handlers.NewDataHandler(storageService, watermarker, indexService, popularity, service.NewURLSigner(cfg.DataURLSecret, cfg.DataURLTTL), guestTokens)
+	dataHandler.SetAnonymousAccess(cfg.AnonymousAccess)
 
 	// Apply global middleware
 	r.Use(middleware.Logger(logger))
 	r.Use(middleware.CORS(cfg.AllowedOrigins))
+	r.Use(middleware.Auth(tokens))
 
 	// Static file serving (frontend)
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("./frontend"))))
@@ -47,34 +122,209 @@ func NewRouter(
 		http.ServeFile(w, r, "./frontend/index.html")
 	}).Methods("GET")
 
-	// Serve data files (images, thumbnails)
-	r.PathPrefix("/data/").Handler(http.StripPrefix("/data/", http.FileServer(http.Dir(cfg.DataDir))))
+	// Admin dashboard (embedded page; its APIs need the admin role)
+	r.HandleFunc("/admin", dashboardHandler.HandlePage).Methods("GET")
+
+	// Upload form for people without curl (embedded page; uploads need the
+	// editor role)
+	r.HandleFunc("/upload", uploadHandler.HandlePage).Methods("GET")
+
+	// Share links: a preview for chat and mail apps that opens the web UI
+	share := http.Handler(http.HandlerFunc(shareHandler.HandleShare))
+	if !cfg.AnonymousAccess {
+		share = middleware.RequireCaller()(share)
+	}
+	r.Handle("/share/{id}", share).Methods("GET")
+
+	// Serve data files (images, thumbnails; previews watermarked for viewers).
+	// Only files of indexed images are served; with DATA_URL_SECRET set or
+	// ANONYMOUS_ACCESS off, callers without an API token need a signed URL
+	// or a guest token.
+	// 3D models get their model/* content types, e.g. USDZ for AR Quick Look.
+	service.RegisterModelContentTypes()
+	r.PathPrefix("/data/").Handler(http.StripPrefix("/data/", dataHandler))
 
 	// API routes
 	api := r.PathPrefix("/api/v1").Subrouter()
 
-	// Upload endpoints
-	api.HandleFunc("/images/upload", uploadHandler.Handle2DUpload).Methods("POST")
-	api.HandleFunc("/images/upload-3d", upload3DHandler.Handle3DUpload).Methods("POST")
+	// Without anonymous access, only guest galleries and the health check
+	// are open to callers without an API token
+	if !cfg.AnonymousAccess {
+		api.Use(middleware.RequireCaller("/api/v1/gallery", "/api/v1/health"))
+	}
+
+	// Routes that change data need at least the editor role (always granted
+	// when API_TOKENS is unset)
+	editor := func(h http.HandlerFunc) http.HandlerFunc { return middleware.RequireRole(models.RoleEditor, h) }
+	admin := func(h http.HandlerFunc) http.HandlerFunc { return middleware.RequireRole(models.RoleAdmin, h) }
+
+	// Upload endpoints (an Upload-Session header reports progress)
+	api.HandleFunc("/images/upload", editor(uploadsHandler.Track(uploadHandler.Handle2DUpload))).Methods("POST")
+	api.HandleFunc("/images/upload-3d", editor(uploadsHandler.Track(upload3DHandler.Handle3DUpload))).Methods("POST")
+
+	// JSON ingest for automation tools that can't send multipart forms
+	api.HandleFunc("/images/ingest", editor(ingestHandler.HandleIngest)).Methods("POST")
+
+	// Frames exported by design-tool plugins (Figma), linked to their document
+	api.HandleFunc("/design/frames", editor(designHandler.HandleExport)).Methods("POST")
+
+	// Upload sessions: bytes received while a large upload streams in
+	api.HandleFunc("/uploads", editor(uploadsHandler.HandleCreateSession)).Methods("POST")
+	api.HandleFunc("/uploads/{session}", uploadsHandler.HandleGetSession).Methods("GET")
+
+	// Dry-run analysis (nothing is stored)
+	api.HandleFunc("/analyze", editor(analyzeHandler.HandleAnalyze)).Methods("POST")
+	api.HandleFunc("/categorize", editor(analyzeHandler.HandleCategorize)).Methods("POST")
 
 	// Image listing endpoints
 	api.HandleFunc("/images", imagesHandler.HandleListImages).Methods("GET")
+	api.HandleFunc("/images/bulk-update", editor(bulkHandler.HandleBulkUpdate)).Methods("POST")
+	api.HandleFunc("/images/metadata-import", editor(bulkHandler.HandleMetadataImport)).Methods("POST")
+	api.HandleFunc("/images/bulk-delete", editor(bulkDeleteHandler.HandleBulkDelete)).Methods("POST")
+	api.HandleFunc("/images/xmp-export", editor(xmpHandler.HandleExport)).Methods("POST")
+	api.HandleFunc("/images/geo", imagesHandler.HandleGeoImages).Methods("GET")
+	api.HandleFunc("/images/recently-viewed", imagesHandler.HandleRecentlyViewed).Methods("GET")
+	api.HandleFunc("/images/by-external-id/{id}", imagesHandler.HandleGetImageByExternalID).Methods("GET")
 	api.HandleFunc("/images/{id}", imagesHandler.HandleGetImage).Methods("GET")
+	api.HandleFunc("/images/{id}/turntable", imagesHandler.HandleGetTurntable).Methods("GET")
+	api.HandleFunc("/images/{id}/cutout", cutoutHandler.HandleGetCutout).Methods("GET")
+	api.HandleFunc("/images/{id}/xmp", xmpHandler.HandleGetXMP).Methods("GET")
+	api.HandleFunc("/images/{id}/analysis", imagesHandler.HandleGetAnalysis).Methods("GET")
+	api.HandleFunc("/images/{id}/history", imagesHandler.HandleGetHistory).Methods("GET")
+	api.HandleFunc("/images/{id}/upscale", editor(upscaleHandler.HandleUpscale)).Methods("POST")
+
+	// Derivative files: list, regenerate from source, delete optional ones
+	api.HandleFunc("/images/{id}/renditions", renditionsHandler.HandleListRenditions).Methods("GET")
+	api.HandleFunc("/images/{id}/renditions/{kind}", editor(renditionsHandler.HandleRegenerateRendition)).Methods("POST")
+	api.HandleFunc("/images/{id}/renditions/{kind}", editor(renditionsHandler.HandleDeleteRendition)).Methods("DELETE")
+	api.HandleFunc("/images/{id}/health", renditionsHandler.HandleImageHealth).Methods("GET")
+	api.HandleFunc("/images/{id}/health/fix", editor(renditionsHandler.HandleHealImage)).Methods("POST")
+
+	// Signed /data/ URLs, to share a file without an API token
+	api.HandleFunc("/images/{id}/signed-url", dataHandler.HandleSignedURL).Methods("GET")
+
+	// Review annotations
+	api.HandleFunc("/images/{id}/annotations", annotationsHandler.HandleListAnnotations).Methods("GET")
+	api.HandleFunc("/images/{id}/annotations", editor(annotationsHandler.HandleCreateAnnotation)).Methods("POST")
+	api.HandleFunc("/images/{id}", editor(imagesHandler.HandleUpdateImage)).Methods("PATCH")
+	api.HandleFunc("/images/{id}", editor(imagesHandler.HandleDeleteImage)).Methods("DELETE")
+
+	// Per-user favorites
+	api.HandleFunc("/images/{id}/favorite", favoritesHandler.HandleAddFavorite).Methods("POST")
+	api.HandleFunc("/images/{id}/favorite", favoritesHandler.HandleRemoveFavorite).Methods("DELETE")
+	api.HandleFunc("/favorites", favoritesHandler.HandleListFavorites).Methods("GET")
+
+	// Editorial workflow (transition rules and roles are checked per state)
+	api.HandleFunc("/images/{id}/workflow", editor(workflowHandler.HandleTransition)).Methods("POST")
+
+	// Category sprite sheets (category may be "primary" or "primary/sub")
+	api.HandleFunc("/categories/{cat:.+}/sprite", categoriesHandler.HandleGetSprite).Methods("GET")
+	api.HandleFunc("/categories/{cat:.+}/sprite.jpg", categoriesHandler.HandleGetSpriteImage).Methods("GET")
+
+	// Projects (per-client upload defaults; list and search take project=)
+	api.HandleFunc("/projects", projectsHandler.HandleListProjects).Methods("GET")
+	api.HandleFunc("/projects/{id}", projectsHandler.HandleGetProject).Methods("GET")
+	api.HandleFunc("/projects/{id}", admin(projectsHandler.HandlePutProject)).Methods("PUT")
+	api.HandleFunc("/projects/{id}", admin(projectsHandler.HandleDeleteProject)).Methods("DELETE")
+
+	// Legal holds (block every deletion until an admin clears them)
+	api.HandleFunc("/images/{id}/legal-hold", admin(legalHoldHandler.HandleImageHold)).Methods("PUT", "DELETE")
+	api.HandleFunc("/projects/{id}/legal-hold", admin(legalHoldHandler.HandleProjectHold)).Methods("PUT", "DELETE")
+
+	// Embeddable galleries: read-only guest tokens (token=) scoped to one
+	// project or category, issued and revoked by admins
+	api.HandleFunc("/gallery", galleryHandler.HandleGallery).Methods("GET")
+	api.HandleFunc("/admin/guest-tokens", admin(galleryHandler.HandleListGuestTokens)).Methods("GET")
+	api.HandleFunc("/admin/guest-tokens", admin(galleryHandler.HandleCreateGuestToken)).Methods("POST")
+	api.HandleFunc("/admin/guest-tokens/{id}", admin(galleryHandler.HandleRevokeGuestToken)).Methods("DELETE")
+
+	// Chronological browsing (day/month buckets by upload or capture date)
+	api.HandleFunc("/timeline", timelineHandler.HandleTimeline).Methods("GET")
+
+	// Atom feed of newly processed images
+	api.HandleFunc("/feed.atom", feedHandler.HandleFeed).Methods("GET")
+
+	// Index change feed for sync clients and replicas
+	api.HandleFunc("/changes", changesHandler.HandleChanges).Methods("GET")
 
 	// Search endpoint
 	api.HandleFunc("/search", searchHandler.HandleSearch).Methods("POST")
+	api.HandleFunc("/search/batch", searchHandler.HandleBatchSearch).Methods("POST")
+	api.HandleFunc("/search/sessions", searchHandler.HandleStartSearchSession).Methods("POST")
+	api.HandleFunc("/search/sessions/{id}", searchHandler.HandleGetSearchSession).Methods("GET")
+	api.HandleFunc("/search/sessions/{id}", searchHandler.HandleRefineSearchSession).Methods("POST")
+	api.HandleFunc("/search/sessions/{id}", searchHandler.HandleDeleteSearchSession).Methods("DELETE")
+
+	// Query analytics: what users search for and what finds nothing
+	api.HandleFunc("/stats/searches", editor(searchHandler.HandleSearchStats)).Methods("GET")
+
+	// Read-only GraphQL API over images, projects, categories and search
+	api.HandleFunc("/graphql", graphqlHandler.HandleGraphQL).Methods("GET", "POST")
+
+	// Processing jobs
+	api.HandleFunc("/jobs", jobsHandler.HandleListJobs).Methods("GET")
+	api.HandleFunc("/jobs/{id}", jobsHandler.HandleGetJob).Methods("GET")
+	api.HandleFunc("/jobs/{id}", editor(jobsHandler.HandleCancelJob)).Methods("DELETE")
+	api.HandleFunc("/jobs/{id}/retry", editor(jobsHandler.HandleRetryJob)).Methods("POST")
+
+	// Digest reports
+	api.HandleFunc("/reports/weekly", reportsHandler.HandleWeeklyReport).Methods("GET")
+
+	// Admin: embedding backfill
+	api.HandleFunc("/admin/backfill/embeddings", admin(adminHandler.HandleStartBackfill)).Methods("POST")
+	api.HandleFunc("/admin/backfill/embeddings", admin(adminHandler.HandleBackfillStatus)).Methods("GET")
+	api.HandleFunc("/admin/backfill/embeddings", admin(adminHandler.HandleCancelBackfill)).Methods("DELETE")
+
+	// Admin: duplicate file consolidation
+	api.HandleFunc("/admin/consolidate", admin(adminHandler.HandleStartConsolidation)).Methods("POST")
+	api.HandleFunc("/admin/consolidate", admin(adminHandler.HandleConsolidationReport)).Methods("GET")
+
+	// Admin: retention rules, upcoming deletions and the audit log
+	api.HandleFunc("/admin/retention", admin(adminHandler.HandleRetention)).Methods("GET")
+	api.HandleFunc("/admin/retention/enforce", admin(adminHandler.HandleEnforceRetention)).Methods("POST")
+	api.HandleFunc("/admin/audit", admin(adminHandler.HandleAuditLog)).Methods("GET")
+
+	// Admin: contributor data requests, exporting or erasing an artist's images
+	api.HandleFunc("/admin/erasure/export", admin(erasureHandler.HandleExport)).Methods("GET")
+	api.HandleFunc("/admin/erasure", admin(erasureHandler.HandleErase)).Methods("POST")
+
+	// Admin: dashboard stats and worker scaling
+	api.HandleFunc("/admin/stats", admin(dashboardHandler.HandleStats)).Methods("GET")
+	api.HandleFunc("/admin/workers", admin(dashboardHandler.HandleScaleWorkers)).Methods("PUT")
+
+	// Queue and status metrics
+	api.HandleFunc("/metrics", metricsHandler.HandleMetrics).Methods("GET")
 
 	// Health check
 	api.HandleFunc("/health", healthHandler.HandleHealth).Methods("GET")
 	r.HandleFunc("/health", healthHandler.HandleHealth).Methods("GET") // Also at root
 
 	return &Router{
-		router:          r,
-		uploadHandler:   uploadHandler,
-		upload3DHandler: upload3DHandler,
-		searchHandler:   searchHandler,
-		imagesHandler:   imagesHandler,
-		healthHandler:   healthHandler,
+		router:             r,
+		uploadHandler:      uploadHandler,
+		upload3DHandler:    upload3DHandler,
+		searchHandler:      searchHandler,
+		imagesHandler:      imagesHandler,
+		healthHandler:      healthHandler,
+		metricsHandler:     metricsHandler,
+		jobsHandler:        jobsHandler,
+		adminHandler:       adminHandler,
+		dashboardHandler:   dashboardHandler,
+		reportsHandler:     reportsHandler,
+		bulkHandler:        bulkHandler,
+		bulkDeleteHandler:  bulkDeleteHandler,
+		analyzeHandler:     analyzeHandler,
+		categoriesHandler:  categoriesHandler,
+		annotationsHandler: annotationsHandler,
+		workflowHandler:    workflowHandler,
+		cutoutHandler:      cutoutHandler,
+		upscaleHandler:     upscaleHandler,
+		renditionsHandler:  renditionsHandler,
+		projectsHandler:    projectsHandler,
+		timelineHandler:    timelineHandler,
+		changesHandler:     changesHandler,
+		favoritesHandler:   favoritesHandler,
+		uploadsHandler:     uploadsHandler,
 	}
 }
 
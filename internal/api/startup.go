@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StartupGate is the server's handler while it initializes. It answers
+// /readyz with 503 until Ready is called and every other request except
+// the liveness checks with 503 and a Retry-After, so orchestrators can
+// start the server early and route traffic only once it is ready.
+type StartupGate struct {
+	mutex   sync.RWMutex
+	stage   string
+	since   time.Time
+	handler http.Handler
+}
+
+// NewStartupGate creates a gate in the "starting" stage
+func NewStartupGate() *StartupGate {
+	return &StartupGate{stage: "starting", since: time.Now()}
+}
+
+// SetStage names the initialization step in progress, reported by /readyz
+func (g *StartupGate) SetStage(stage string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.stage = stage
+}
+
+// Ready opens the gate: all requests go to handler from now on
+func (g *StartupGate) Ready(handler http.Handler) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.handler = handler
+	g.stage = "ready"
+}
+
+func (g *StartupGate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mutex.RLock()
+	handler, stage, since := g.handler, g.stage, g.since
+	g.mutex.RUnlock()
+
+	switch {
+	case r.URL.Path == "/readyz":
+		status := http.StatusOK
+		if handler == nil {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":         stage,
+			"uptime_seconds": int(time.Since(since).Seconds()),
+		})
+
+	case handler != nil:
+		handler.ServeHTTP(w, r)
+
+	case r.URL.Path == "/health" || r.URL.Path == "/api/v1/health":
+		// Alive while initializing; readiness is /readyz
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":    "starting",
+			"timestamp": time.Now().Format(time.RFC3339),
+			"service":   "image-warehousing",
+		})
+
+	default:
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "Server is starting", http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStartupGate(t *testing.T) {
+	gate := NewStartupGate()
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		gate.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	gate.SetStage("migrating data directory")
+	if w := serve("/readyz"); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "migrating data directory") {
+		t.Errorf("expected /readyz to fail while starting, got %d %s", w.Code, w.Body)
+	}
+	if w := serve("/health"); w.Code != http.StatusOK {
+		t.Errorf("expected /health to pass while starting, got %d", w.Code)
+	}
+	if w := serve("/api/v1/images"); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After while starting, got %d", w.Code)
+	}
+
+	gate.Ready(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	if w := serve("/readyz"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"ready"`) {
+		t.Errorf("expected /readyz to pass once ready, got %d %s", w.Code, w.Body)
+	}
+	if w := serve("/api/v1/images"); w.Code != http.StatusTeapot {
+		t.Errorf("expected requests to reach the router once ready, got %d", w.Code)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,26 +15,303 @@ type Config struct {
 	GeminiAPIKey   string
 	GeminiModel    string
 	DataDir        string
+	TempDir        string
 	MaxUploadSize  int64
 	AllowedOrigins []string
+
+	// Processing status retention
+	StatusTTL        time.Duration
+	StatusMaxEntries int
+
+	// Processing workers, and the most 2D and 3D jobs they run at once
+	// (0 = no limit besides the worker count)
+	Workers   int
+	QueueSize int // jobs that may wait for a worker; more uploads get 429
+	Max2DJobs int
+	Max3DJobs int
+
+	// Per-stage processing timeouts
+	ThumbnailTimeout time.Duration
+	AnalysisTimeout  time.Duration
+
+	// How long upload Idempotency-Key values are remembered
+	IdempotencyTTL time.Duration
+
+	// Total image bytes sent inline with a Gemini request; larger payloads
+	// are uploaded through the Files API
+	GeminiInlineLimit int64
+
+	// JSON file of per-category prompt specializations; empty disables them
+	CategoryPromptsFile string
+
+	// JSON file replacing the built-in scene type, mood and style vocabulary
+	VocabularyFile string
+
+	// Reuse analyses of identical content (same model and prompt), keeping
+	// up to AnalysisCacheSize of them
+	AnalysisCache     bool
+	AnalysisCacheSize int
+
+	// Bytes of served thumbnails kept in memory (0 = no cache)
+	ThumbnailCacheSize int64
+
+	// Ask the AI for an aesthetic score, composition score and critique
+	AestheticScoring bool
+
+	// AI search over large indexes: chunk size in bytes, chunks searched at
+	// once, and AI calls per minute (0 = unlimited)
+	SearchChunkSize     int
+	SearchConcurrency   int
+	SearchRatePerMinute int
+
+	// How long an idle conversational search session is kept
+	SearchSessionTTL time.Duration
+
+	// Search ranking weights: the AI's relevance, query terms in the title,
+	// tags and description, embedding similarity, and recency
+	SearchWeightRelevance   float64
+	SearchWeightTitle       float64
+	SearchWeightTags        float64
+	SearchWeightDescription float64
+	SearchWeightEmbedding   float64
+	SearchWeightRecency     float64
+
+	// Embeddings
+	EmbeddingModel         string
+	ImageEmbeddingModel    string // multimodal; empty embeds images through their text
+	EmbeddingRatePerMinute int
+
+	// Categorization depth: 1 = primary, 2 = primary/sub
+	CategoryDepth int
+
+	// API access: comma-separated name:token:role entries; empty disables auth.
+	// Without anonymous access, callers without a token only reach guest
+	// galleries and the files their guest tokens cover.
+	APITokens       string
+	AnonymousAccess bool
+
+	// Event webhook (workflow transitions, ...)
+	WebhookURL string
+
+	// CDN in front of /data/: data file links point at CDNBaseURL, and
+	// replaced or deleted files are purged through CDNPurgeURL
+	CDNBaseURL  string
+	CDNPurgeURL string
+
+	// HTTP access log, apart from the application log: "stdout" or a file
+	// path (rotated at AccessLogMaxSize bytes, keeping AccessLogMaxFiles);
+	// empty disables it. AccessLogFormat is common, combined or json.
+	AccessLog         string
+	AccessLogFormat   string
+	AccessLogMaxSize  int64
+	AccessLogMaxFiles int
+
+	// Application log: default level, text or json, per-component levels
+	// (component=level, ...) and a file written instead of stderr, rotated
+	// by lumberjack
+	LogLevel        string
+	LogFormat       string
+	LogLevels       string
+	LogFile         string
+	LogFileMaxSize  int64
+	LogFileMaxFiles int
+
+	// Convert thumbnails of wide-gamut images to sRGB instead of embedding
+	// the source ICC profile
+	NormalizeSRGB bool
+
+	// Folder layout of stored images: category, date, artist, flat-hash or cas
+	StorageLayout string
+
+	// Watermark on previews served to anonymous and viewer-role callers;
+	// disabled when neither text nor logo is set
+	WatermarkText    string
+	WatermarkLogo    string // path to a PNG logo
+	WatermarkOpacity int    // percent
+
+	// Signed /data/ URLs: with a secret, anonymous callers need a signed URL
+	// to fetch files; TTL is the default validity of a signed URL
+	DataURLSecret string
+	DataURLTTL    time.Duration
+
+	// Background removal: external matting service (built-in keying for
+	// plain backdrops when empty) and primary categories cut out at ingest
+	CutoutServiceURL       string
+	CutoutTimeout          time.Duration
+	CutoutIngestCategories []string
+
+	// Turntable clips: ffmpeg and ffprobe binaries used to extract frames
+	FFmpegPath  string
+	FFprobePath string
+
+	// Upscaling: external upscaler (built-in resampling when empty) and the
+	// largest result allowed
+	UpscalerURL          string
+	UpscaleTimeout       time.Duration
+	UpscaleMaxMegapixels int64
+
+	// JSON ingest: timeout of image_url downloads, and whether they may
+	// reach loopback and private network addresses
+	IngestURLTimeout       time.Duration
+	IngestAllowPrivateURLs bool
+
+	// Write XMP sidecars next to the originals at ingest and on every edit
+	XMPSidecars bool
+
+	// Split the index into a file per primary category
+	IndexSharding bool
+
+	// Images queued as demo uploads when the server starts on an empty index
+	SeedDir string
+
+	// Coordination of instances sharing the data directory: "file" (lease
+	// files in DATA_DIR/locks) or a redis:// URL; empty for one instance
+	CoordinationURL string
+	InstanceID      string // names this instance in locks; defaults to the hostname
+	LockTTL         time.Duration
+
+	// Retention: JSON file of category -> period (e.g. "90d", "forever"),
+	// how long before deletion images are flagged, and how often the rules
+	// are enforced
+	RetentionRulesFile string
+	RetentionWarning   time.Duration
+	RetentionInterval  time.Duration
+
+	// Digest reports
+	PublicBaseURL    string
+	ReportWebhookURL string
+	ReportInterval   time.Duration
 }
 
 func Load() (*Config, error) {
 	// Load .env file if it exists (ignore error in production)
 	_ = godotenv.Load()
+	if err := loadEnvFiles(); err != nil {
+		return nil, err
+	}
 
 	cfg := &Config{
 		ServerPort:    getEnv("SERVER_PORT", "8080"),
 		GeminiAPIKey:  getEnv("GEMINI_API_KEY", ""),
 		GeminiModel:   getEnv("GEMINI_MODEL", "gemini-3-flash-preview"),
 		DataDir:       getEnv("DATA_DIR", "./data"),
+		TempDir:       getEnv("TEMP_DIR", ""),                     // defaults to DATA_DIR/temp
 		MaxUploadSize: getEnvAsInt64("MAX_UPLOAD_SIZE", 52428800), // 50MB default
+
+		StatusTTL:        getEnvAsDuration("STATUS_TTL", 24*time.Hour),
+		StatusMaxEntries: int(getEnvAsInt64("STATUS_MAX_ENTRIES", 10000)),
+
+		Workers:   int(getEnvAsInt64("WORKERS", 3)),
+		QueueSize: int(getEnvAsInt64("QUEUE_SIZE", 100)),
+		Max2DJobs: int(getEnvAsInt64("MAX_2D_JOBS", 0)),
+		Max3DJobs: int(getEnvAsInt64("MAX_3D_JOBS", 2)),
+
+		ThumbnailTimeout: getEnvAsDuration("THUMBNAIL_TIMEOUT", 30*time.Second),
+		AnalysisTimeout:  getEnvAsDuration("ANALYSIS_TIMEOUT", 2*time.Minute),
+
+		IdempotencyTTL: getEnvAsDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+
+		GeminiInlineLimit: getEnvAsInt64("GEMINI_INLINE_LIMIT", 14<<20),
+
+		CategoryPromptsFile: getEnv("CATEGORY_PROMPTS_FILE", ""),
+
+		VocabularyFile: getEnv("VOCABULARY_FILE", ""),
+
+		AnalysisCache:     getEnvAsBool("ANALYSIS_CACHE", true),
+		AnalysisCacheSize: int(getEnvAsInt64("ANALYSIS_CACHE_SIZE", 10000)),
+
+		ThumbnailCacheSize: getEnvAsInt64("THUMBNAIL_CACHE_SIZE", 0),
+
+		AestheticScoring: getEnvAsBool("AESTHETIC_SCORING", false),
+
+		SearchChunkSize:     int(getEnvAsInt64("SEARCH_CHUNK_SIZE", 1<<20)),
+		SearchConcurrency:   int(getEnvAsInt64("SEARCH_CONCURRENCY", 4)),
+		SearchRatePerMinute: int(getEnvAsInt64("SEARCH_RATE_PER_MINUTE", 0)),
+
+		SearchSessionTTL: getEnvAsDuration("SEARCH_SESSION_TTL", 30*time.Minute),
+
+		SearchWeightRelevance:   getEnvAsFloat("SEARCH_WEIGHT_RELEVANCE", 1),
+		SearchWeightTitle:       getEnvAsFloat("SEARCH_WEIGHT_TITLE", 0),
+		SearchWeightTags:        getEnvAsFloat("SEARCH_WEIGHT_TAGS", 0),
+		SearchWeightDescription: getEnvAsFloat("SEARCH_WEIGHT_DESCRIPTION", 0),
+		SearchWeightEmbedding:   getEnvAsFloat("SEARCH_WEIGHT_EMBEDDING", 0),
+		SearchWeightRecency:     getEnvAsFloat("SEARCH_WEIGHT_RECENCY", 0),
+
+		EmbeddingModel:         getEnv("EMBEDDING_MODEL", "text-embedding-004"),
+		ImageEmbeddingModel:    getEnv("IMAGE_EMBEDDING_MODEL", ""),
+		EmbeddingRatePerMinute: int(getEnvAsInt64("EMBEDDING_RATE_PER_MINUTE", 60)),
+
+		CategoryDepth: int(getEnvAsInt64("CATEGORY_DEPTH", 1)),
+
+		APITokens:       getEnv("API_TOKENS", ""),
+		AnonymousAccess: getEnvAsBool("ANONYMOUS_ACCESS", true),
+		WebhookURL:      getEnv("WEBHOOK_URL", ""),
+
+		CDNBaseURL:  getEnv("CDN_BASE_URL", ""),
+		CDNPurgeURL: getEnv("CDN_PURGE_URL", ""),
+
+		AccessLog:         getEnv("ACCESS_LOG", ""),
+		AccessLogFormat:   getEnv("ACCESS_LOG_FORMAT", "combined"),
+		AccessLogMaxSize:  getEnvAsInt64("ACCESS_LOG_MAX_SIZE", 100<<20),
+		AccessLogMaxFiles: int(getEnvAsInt64("ACCESS_LOG_MAX_FILES", 5)),
+
+		LogLevel:        getEnv("LOG_LEVEL", "info"),
+		LogFormat:       getEnv("LOG_FORMAT", "text"),
+		LogLevels:       getEnv("LOG_LEVELS", ""),
+		LogFile:         getEnv("LOG_FILE", ""),
+		LogFileMaxSize:  getEnvAsInt64("LOG_FILE_MAX_SIZE", 100<<20),
+		LogFileMaxFiles: int(getEnvAsInt64("LOG_FILE_MAX_FILES", 5)),
+
+		NormalizeSRGB: getEnvAsBool("COLOR_NORMALIZE_SRGB", false),
+
+		StorageLayout: getEnv("STORAGE_LAYOUT", "category"),
+
+		WatermarkText:    getEnv("WATERMARK_TEXT", ""),
+		WatermarkLogo:    getEnv("WATERMARK_LOGO", ""),
+		WatermarkOpacity: int(getEnvAsInt64("WATERMARK_OPACITY", 40)),
+
+		DataURLSecret: getEnv("DATA_URL_SECRET", ""),
+		DataURLTTL:    getEnvAsDuration("DATA_URL_TTL", time.Hour),
+
+		CutoutServiceURL: getEnv("CUTOUT_SERVICE_URL", ""),
+		CutoutTimeout:    getEnvAsDuration("CUTOUT_TIMEOUT", time.Minute),
+
+		FFmpegPath:  getEnv("FFMPEG_PATH", "ffmpeg"),
+		FFprobePath: getEnv("FFPROBE_PATH", "ffprobe"),
+
+		UpscalerURL:          getEnv("UPSCALER_URL", ""),
+		UpscaleTimeout:       getEnvAsDuration("UPSCALE_TIMEOUT", 2*time.Minute),
+		UpscaleMaxMegapixels: getEnvAsInt64("UPSCALE_MAX_MEGAPIXELS", 100),
+
+		IngestURLTimeout:       getEnvAsDuration("INGEST_URL_TIMEOUT", time.Minute),
+		IngestAllowPrivateURLs: getEnvAsBool("INGEST_ALLOW_PRIVATE_URLS", false),
+
+		XMPSidecars: getEnvAsBool("XMP_SIDECARS", false),
+		SeedDir:     getEnv("SEED_DIR", ""),
+
+		IndexSharding: getEnvAsBool("INDEX_SHARDING", false),
+
+		CoordinationURL: getEnv("COORDINATION_URL", ""),
+		InstanceID:      getEnv("INSTANCE_ID", ""),
+		LockTTL:         getEnvAsDuration("LOCK_TTL", 30*time.Second),
+
+		RetentionRulesFile: getEnv("RETENTION_RULES_FILE", ""),
+		RetentionWarning:   getEnvAsDuration("RETENTION_WARNING", 14*24*time.Hour),
+		RetentionInterval:  getEnvAsDuration("RETENTION_INTERVAL", time.Hour),
+
+		PublicBaseURL:    getEnv("PUBLIC_BASE_URL", ""),
+		ReportWebhookURL: getEnv("REPORT_WEBHOOK_URL", ""),
+		ReportInterval:   getEnvAsDuration("REPORT_INTERVAL", 7*24*time.Hour),
 	}
 
 	// Parse allowed origins
 	originsStr := getEnv("ALLOWED_ORIGINS", "http://localhost:3000")
 	cfg.AllowedOrigins = strings.Split(originsStr, ",")
 
+	if categories := getEnv("CUTOUT_INGEST_CATEGORIES", ""); categories != "" {
+		cfg.CutoutIngestCategories = strings.Split(categories, ",")
+	}
+
 	// Validate required fields
 	if cfg.GeminiAPIKey == "" {
 		return nil, fmt.Errorf("GEMINI_API_KEY is required")
@@ -42,6 +320,27 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// secretVars may be given as a file instead, by setting <name>_FILE
+var secretVars = []string{"GEMINI_API_KEY", "API_TOKENS", "DATA_URL_SECRET", "WEBHOOK_URL", "REPORT_WEBHOOK_URL", "COORDINATION_URL", "CDN_PURGE_URL"}
+
+// loadEnvFiles sets unset secret variables from the files their _FILE
+// variables name, so they can come from Docker or Kubernetes secrets
+// (e.g. GEMINI_API_KEY_FILE=/run/secrets/gemini)
+func loadEnvFiles() error {
+	for _, key := range secretVars {
+		path := os.Getenv(key + "_FILE")
+		if path == "" || os.Getenv(key) != "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s_FILE: %w", key, err)
+		}
+		os.Setenv(key, strings.TrimRight(string(data), "\r\n"))
+	}
+	return nil
+}
+
 func getEnv(key, defaultVal string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -56,3 +355,27 @@ func getEnvAsInt64(key string, defaultVal int64) int64 {
 	}
 	return defaultVal
 }
+
+func getEnvAsBool(key string, defaultVal bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultVal
+}
+
+func getEnvAsFloat(key string, defaultVal float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultVal
+}
+
+func getEnvAsDuration(key string, defaultVal time.Duration) time.Duration {
+	valueStr := getEnv(key, "")
+	if value, err := time.ParseDuration(valueStr); err == nil {
+		return value
+	}
+	return defaultVal
+}
@@ -0,0 +1,239 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// Conformance cases from the GraphQL spec (October 2021), each checked
+// against the whole response as clients receive it: data and errors, with
+// messages, locations and paths.
+
+// runJSON decodes an HTTP request body and returns the encoded response
+func runJSON(t *testing.T, body string) string {
+	t.Helper()
+	var req Request
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("invalid request %s: %v", body, err)
+	}
+	resp, err := json.Marshal(testSchema().Execute(context.Background(), req))
+	if err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	return string(resp)
+}
+
+type conformanceCase struct {
+	name string
+	body string // request as sent over HTTP
+	want string // response
+}
+
+func runConformance(t *testing.T, tests []conformanceCase) {
+	t.Helper()
+	for _, tt := range tests {
+		if got := runJSON(t, tt.body); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Section 2.10 and 6.1.2: variables
+func TestConformance_Variables(t *testing.T) {
+	runConformance(t, []conformanceCase{
+		{
+			"JSON numbers coerce to Int and ID",
+			`{"query": "query ($n: Int, $id: ID) { books(limit: $n) { id } book(id: $id) { id } }", "variables": {"n": 2, "id": 3}}`,
+			`{"data":{"books":[{"id":"1"},{"id":"2"}],"book":{"id":"3"}}}`,
+		},
+		{
+			"an Int variable is accepted for a Float or ID argument",
+			`{"query": "query ($id: Int!) { book(id: $id) { id } }", "variables": {"id": 2}}`,
+			`{"data":{"book":{"id":"2"}}}`,
+		},
+		{
+			"the variable's default applies when it is not given",
+			`{"query": "query ($n: Int = 1) { books(limit: $n) { id } }"}`,
+			`{"data":{"books":[{"id":"1"}]}}`,
+		},
+		{
+			"a given value overrides the default",
+			`{"query": "query ($n: Int = 1) { books(limit: $n) { id } }", "variables": {"n": 3}}`,
+			`{"data":{"books":[{"id":"1"},{"id":"2"},{"id":"3"}]}}`,
+		},
+		{
+			"an unset optional variable leaves the argument's default",
+			`{"query": "query ($upper: Boolean) { book(id: 1) { title(upper: $upper) } }"}`,
+			`{"data":{"book":{"title":"Dune"}}}`,
+		},
+		{
+			"variables in directives and fragments",
+			`{"query": "query ($more: Boolean!, $upper: Boolean!) { book(id: 1) { id ...F @include(if: $more) } } fragment F on Book { title(upper: $upper) }", "variables": {"more": true, "upper": true}}`,
+			`{"data":{"book":{"id":"1","title":"DUNE"}}}`,
+		},
+		{
+			"variables not declared by the operation are ignored",
+			`{"query": "{ book(id: 1) { id } }", "variables": {"unused": 1}}`,
+			`{"data":{"book":{"id":"1"}}}`,
+		},
+		{
+			"a missing non-null variable is reported at its definition",
+			`{"query": "query Q(\n  $id: ID!\n) { book(id: $id) { id } }"}`,
+			`{"errors":[{"message":"variable $id of type ID! is required","locations":[{"line":2,"column":3}]}]}`,
+		},
+		{
+			"null for a non-null variable is an error",
+			`{"query": "query ($id: ID!) { book(id: $id) { id } }", "variables": {"id": null}}`,
+			`{"errors":[{"message":"variable $id of type ID! is required","locations":[{"line":1,"column":8}]}]}`,
+		},
+		{
+			"values of the wrong type are rejected before execution",
+			`{"query": "query ($n: Int, $b: Boolean) { books(limit: $n) { title(upper: $b) } }", "variables": {"n": "2", "b": "yes"}}`,
+			`{"errors":[{"message":"variable $n: expected Int, got \"2\"","locations":[{"line":1,"column":8}]},{"message":"variable $b: expected Boolean, got \"yes\"","locations":[{"line":1,"column":17}]}]}`,
+		},
+		{
+			"Int is 32-bit",
+			`{"query": "query ($n: Int) { books(limit: $n) { id } }", "variables": {"n": 2147483648}}`,
+			`{"errors":[{"message":"variable $n: expected Int, got 2.147483648e+09","locations":[{"line":1,"column":8}]}]}`,
+		},
+		{
+			"a variable must be compatible with the argument",
+			`{"query": "query ($t: Boolean) { books(limit: $t) { id } }", "variables": {"t": true}}`,
+			`{"errors":[{"message":"variable $t of type Boolean cannot be used as argument \"limit\" of type Int","locations":[{"line":1,"column":29}]}]}`,
+		},
+		{
+			"a list variable can't be passed to a scalar argument",
+			`{"query": "query ($ids: [ID]) { book(id: $ids) { id } }", "variables": {"ids": [1]}}`,
+			`{"errors":[{"message":"variable $ids of type [ID] cannot be used as argument \"id\" of type ID","locations":[{"line":1,"column":27}]}]}`,
+		},
+		{
+			"variables of unknown types are rejected",
+			`{"query": "query ($f: BookFilter) { books { id } }"}`,
+			`{"errors":[{"message":"variable $f has unknown type BookFilter","locations":[{"line":1,"column":8}]}]}`,
+		},
+	})
+}
+
+// Sections 2.8, 5.5 and 6.3.2: fragments
+func TestConformance_Fragments(t *testing.T) {
+	runConformance(t, []conformanceCase{
+		{
+			"fragments spreading fragments, used more than once",
+			`{"query": "{ a: book(id: 1) { ...Full } b: book(id: 2) { ...Full } } fragment Full on Book { ...Id author { ...Name } } fragment Id on Book { id } fragment Name on Author { name }"}`,
+			`{"data":{"a":{"id":"1","author":{"name":"Frank"}},"b":{"id":"2","author":{"name":"Jane"}}}}`,
+		},
+		{
+			"fragments apply to every item of a list",
+			`{"query": "{ books(limit: 2) { ...on Book { id } } }"}`,
+			`{"data":{"books":[{"id":"1"},{"id":"2"}]}}`,
+		},
+		{
+			"inline fragments without a type condition carry directives",
+			`{"query": "{ book(id: 1) { id ... @skip(if: true) { title } ... @include(if: true) { tags } } }"}`,
+			`{"data":{"book":{"id":"1","tags":["sf"]}}}`,
+		},
+		{
+			"the first occurrence of a field fixes its position in the response",
+			`{"query": "{ book(id: 1) { ...T id title } } fragment T on Book { title }"}`,
+			`{"data":{"book":{"title":"Dune","id":"1"}}}`,
+		},
+		{
+			"subselections of the same field merge across fragments",
+			`{"query": "{ book(id: 1) { author { name } ... on Book { author { __typename } } } }"}`,
+			`{"data":{"book":{"author":{"name":"Frank","__typename":"Author"}}}}`,
+		},
+		{
+			"fragment cycles through other fragments are rejected",
+			`{"query": "{ books { ...A } } fragment A on Book { ...B } fragment B on Book { ...A }"}`,
+			`{"errors":[{"message":"fragment \"A\" spreads itself","locations":[{"line":1,"column":69}]}]}`,
+		},
+		{
+			"fragment names are unique",
+			`{"query": "{ books { ...A } } fragment A on Book { id } fragment A on Book { title }"}`,
+			`{"errors":[{"message":"fragment \"A\" is defined more than once","locations":[{"line":1,"column":46}]}]}`,
+		},
+		{
+			"a fragment must apply to the type it is spread on",
+			`{"query": "{ books { ...N } } fragment N on Author { name }"}`,
+			`{"errors":[{"message":"fragment \"N\" on Author cannot be spread on Book","locations":[{"line":1,"column":11}]}]}`,
+		},
+	})
+}
+
+// Sections 2.7 and 5.3.2: aliases and field merging
+func TestConformance_Aliases(t *testing.T) {
+	runConformance(t, []conformanceCase{
+		{
+			"the same field under several aliases with different arguments",
+			`{"query": "{ a: book(id: 1) { title } b: book(id: 2) { title } c: book(id: 9) { title } }"}`,
+			`{"data":{"a":{"title":"Dune"},"b":{"title":"Emma"},"c":null}}`,
+		},
+		{
+			"aliases on scalars and __typename",
+			`{"query": "{ book(id: 1) { kind: __typename plain: title loud: title(upper: true) } }"}`,
+			`{"data":{"book":{"kind":"Book","plain":"Dune","loud":"DUNE"}}}`,
+		},
+		{
+			"an alias may reuse another field's name",
+			`{"query": "{ book(id: 1) { title: id id: title } }"}`,
+			`{"data":{"book":{"title":"1","id":"Dune"}}}`,
+		},
+		{
+			"identical fields under one key merge",
+			`{"query": "{ book(id: 1) { title title t: title t: title } }"}`,
+			`{"data":{"book":{"title":"Dune","t":"Dune"}}}`,
+		},
+		{
+			"an argument given explicitly differs from its default",
+			`{"query": "{ book(id: 1) { title title(upper: false) } }"}`,
+			`{"errors":[{"message":"fields \"title\" conflict: they select different fields or arguments","locations":[{"line":1,"column":23}]}]}`,
+		},
+		{
+			"one key can't select different arguments",
+			`{"query": "{ book(id: 1) { t: title t: title(upper: true) } }"}`,
+			`{"errors":[{"message":"fields \"t\" conflict: they select different fields or arguments","locations":[{"line":1,"column":26}]}]}`,
+		},
+		{
+			"field errors are located by response key and list index",
+			`{"query": "{ books(limit: 2) { oops: broken } }"}`,
+			`{"data":{"books":[{"oops":null},{"oops":null}]},"errors":[{"message":"boom","locations":[{"line":1,"column":21}],"path":["books",0,"oops"]},{"message":"boom","locations":[{"line":1,"column":21}],"path":["books",1,"oops"]}]}`,
+		},
+	})
+}
+
+// Section 7.1.2: the shape of errors
+func TestConformance_ErrorShapes(t *testing.T) {
+	runConformance(t, []conformanceCase{
+		{
+			"syntax errors have no data and a location",
+			`{"query": "{\n  books {\n    id\n  ]\n}"}`,
+			`{"errors":[{"message":"Syntax error: unexpected \"]\"","locations":[{"line":4,"column":3}]}]}`,
+		},
+		{
+			"every validation error is reported, each with its location",
+			`{"query": "{ books { isbn } book(id: 1, id: 2) { id } }"}`,
+			`{"errors":[{"message":"unknown field \"isbn\" on Book","locations":[{"line":1,"column":11}]},{"message":"argument \"id\" is given more than once","locations":[{"line":1,"column":30}]}]}`,
+		},
+		{
+			"an unknown operation name is a request error without a location",
+			`{"query": "query A { books { id } }", "operationName": "B"}`,
+			`{"errors":[{"message":"unknown operation \"B\""}]}`,
+		},
+		{
+			"a field error keeps the rest of the data",
+			`{"query": "{ book(id: 1) { id broken title } }"}`,
+			`{"data":{"book":{"id":"1","broken":null,"title":"Dune"}},"errors":[{"message":"boom","locations":[{"line":1,"column":20}],"path":["book","broken"]}]}`,
+		},
+		{
+			"a null object is not an error",
+			`{"query": "{ book(id: 3) { author { name } } }"}`,
+			`{"data":{"book":{"author":null}}}`,
+		},
+		{
+			"an empty document is an error",
+			`{"query": "# nothing here"}`,
+			`{"errors":[{"message":"document has no operation"}]}`,
+		},
+	})
+}
@@ -0,0 +1,608 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Execute runs a query. Errors are reported in the response, never returned.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return errorResponse(err)
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	v := &validator{schema: s, doc: doc, op: op, variables: make(map[string]*variableDefinition)}
+	if errs := v.validate(); len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+	variables, errs := coerceVariables(op, req.Variables)
+	if len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+
+	e := &executor{doc: doc, variables: variables}
+	data := e.selectionSet(ctx, s.Query, nil, op.selection, nil)
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func errorResponse(err error) *Response {
+	if gqlErr, ok := err.(*Error); ok {
+		return &Response{Errors: []*Error{gqlErr}}
+	}
+	return &Response{Errors: []*Error{{Message: err.Error()}}}
+}
+
+// selectOperation picks the operation to run: the named one, or the only one
+func selectOperation(doc *document, name string) (*operation, error) {
+	var op *operation
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, &Error{Message: "operationName is required for a document with several operations"}
+		}
+		op = doc.operations[0]
+	} else {
+		for _, candidate := range doc.operations {
+			if candidate.name == name {
+				op = candidate
+			}
+		}
+		if op == nil {
+			return nil, &Error{Message: fmt.Sprintf("unknown operation %q", name)}
+		}
+	}
+	if op.kind != "query" {
+		return nil, &Error{Message: fmt.Sprintf("%s operations are not supported", op.kind), Locations: []Location{op.loc}}
+	}
+	return op, nil
+}
+
+// validator checks an operation against the schema before it runs
+type validator struct {
+	schema    *Schema
+	doc       *document
+	op        *operation
+	variables map[string]*variableDefinition
+	errors    []*Error
+	cost      int // of the fields validated so far
+}
+
+func (v *validator) validate() []*Error {
+	for _, def := range v.op.variables {
+		if _, exists := v.variables[def.name]; exists {
+			v.fail(def.loc, "variable $%s is defined more than once", def.name)
+		}
+		v.variables[def.name] = def
+		switch ScalarType(baseType(def.typ)) {
+		case String, Int, Float, Boolean, ID:
+		default:
+			v.fail(def.loc, "variable $%s has unknown type %s", def.name, baseType(def.typ))
+		}
+	}
+
+	maxDepth := v.schema.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	v.selectionSet(v.schema.Query, v.op.selection, 1, maxDepth, nil)
+	return v.errors
+}
+
+func (v *validator) fail(loc Location, format string, args ...interface{}) {
+	v.errors = append(v.errors, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}})
+}
+
+// selectionSet validates the selections on an object type. spreading holds
+// the fragments being expanded, to catch cycles.
+func (v *validator) selectionSet(obj *Object, selections []selection, depth, maxDepth int, spreading []string) {
+	if depth > maxDepth {
+		v.fail(selectionLoc(selections[0]), "query is nested too deeply (at most %d levels)", maxDepth)
+		return
+	}
+
+	fields := make(map[string]*field)
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			v.directives(sel.directives)
+			if prev, ok := fields[sel.responseKey()]; ok && (prev.name != sel.name || !sameArguments(prev.arguments, sel.arguments)) {
+				v.fail(sel.loc, "fields %q conflict: they select different fields or arguments", sel.responseKey())
+			}
+			fields[sel.responseKey()] = sel
+			v.field(obj, sel, depth, maxDepth, spreading)
+
+		case *fragmentSpread:
+			v.directives(sel.directives)
+			frag, ok := v.doc.fragments[sel.name]
+			if !ok {
+				v.fail(sel.loc, "unknown fragment %q", sel.name)
+				continue
+			}
+			if containsString(spreading, sel.name) {
+				v.fail(sel.loc, "fragment %q spreads itself", sel.name)
+				continue
+			}
+			if frag.typeCondition != obj.Name {
+				v.fail(sel.loc, "fragment %q on %s cannot be spread on %s", sel.name, frag.typeCondition, obj.Name)
+				continue
+			}
+			v.selectionSet(obj, frag.selection, depth, maxDepth, append(spreading, sel.name))
+
+		case *inlineFragment:
+			v.directives(sel.directives)
+			if sel.typeCondition != "" && sel.typeCondition != obj.Name {
+				v.fail(sel.loc, "fragment on %s cannot be spread on %s", sel.typeCondition, obj.Name)
+				continue
+			}
+			v.selectionSet(obj, sel.selection, depth, maxDepth, spreading)
+		}
+	}
+}
+
+func (v *validator) field(obj *Object, f *field, depth, maxDepth int, spreading []string) {
+	if f.name == "__typename" {
+		if len(f.arguments) > 0 || f.selection != nil {
+			v.fail(f.loc, "__typename takes no arguments or selections")
+		}
+		return
+	}
+	if strings.HasPrefix(f.name, "__") {
+		v.fail(f.loc, "introspection field %q is not supported", f.name)
+		return
+	}
+
+	def, ok := obj.Fields[f.name]
+	if !ok {
+		v.fail(f.loc, "unknown field %q on %s", f.name, obj.Name)
+		return
+	}
+
+	v.cost += def.Cost
+	if limit := v.schema.MaxCost; limit > 0 && v.cost > limit && v.cost-def.Cost <= limit {
+		// Reported once, at the field that goes over
+		v.fail(f.loc, "query is too expensive (cost %d, at most %d)", v.cost, limit)
+	}
+
+	given := make(map[string]bool)
+	for _, arg := range f.arguments {
+		if given[arg.name] {
+			v.fail(arg.loc, "argument %q is given more than once", arg.name)
+		}
+		given[arg.name] = true
+		declared, ok := def.Args[arg.name]
+		if !ok {
+			v.fail(arg.loc, "unknown argument %q on %s.%s", arg.name, obj.Name, f.name)
+			continue
+		}
+		v.argument(declared, arg)
+	}
+	for name, declared := range def.Args {
+		if declared.Required && !given[name] {
+			v.fail(f.loc, "argument %q of %s.%s is required", name, obj.Name, f.name)
+		}
+	}
+
+	switch {
+	case def.Type == nil && f.selection != nil:
+		v.fail(f.loc, "field %q is a scalar and takes no selections", f.name)
+	case def.Type != nil && f.selection == nil:
+		v.fail(f.loc, "field %q of type %s needs a selection of subfields", f.name, def.Type.Name)
+	case def.Type != nil:
+		v.selectionSet(def.Type, f.selection, depth+1, maxDepth, spreading)
+	}
+}
+
+// argument checks a literal against its type, or a variable's type
+// against the argument's
+func (v *validator) argument(declared Arg, arg *argument) {
+	if ref, ok := arg.value.(variableRef); ok {
+		def, ok := v.variables[string(ref)]
+		if !ok {
+			v.fail(arg.loc, "variable $%s is not defined", ref)
+			return
+		}
+		if strings.HasPrefix(def.typ, "[") || !compatible(ScalarType(baseType(def.typ)), declared.Type) {
+			v.fail(arg.loc, "variable $%s of type %s cannot be used as argument %q of type %s", ref, def.typ, arg.name, declared.Type)
+		}
+		return
+	}
+	if arg.value == nil {
+		if declared.Required {
+			v.fail(arg.loc, "argument %q cannot be null", arg.name)
+		}
+		return
+	}
+	if _, err := coerceScalar(declared.Type, arg.value); err != nil {
+		v.fail(arg.loc, "argument %q: %v", arg.name, err)
+	}
+}
+
+// directives checks @skip and @include, the only directives supported
+func (v *validator) directives(directives []*directive) {
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			v.fail(d.loc, "unknown directive @%s", d.name)
+			continue
+		}
+		if len(d.arguments) != 1 || d.arguments[0].name != "if" {
+			v.fail(d.loc, "@%s takes a single argument \"if\"", d.name)
+			continue
+		}
+		v.argument(Arg{Type: Boolean, Required: true}, d.arguments[0])
+	}
+}
+
+// compatible reports whether a variable of one type may be passed as an
+// argument of another
+func compatible(variable, arg ScalarType) bool {
+	switch arg {
+	case ID:
+		return variable == ID || variable == String || variable == Int
+	case String:
+		return variable == String || variable == ID
+	case Float:
+		return variable == Float || variable == Int
+	}
+	return variable == arg
+}
+
+// coerceVariables applies defaults and checks the given values against the
+// declared types
+func coerceVariables(op *operation, given map[string]interface{}) (map[string]interface{}, []*Error) {
+	values := make(map[string]interface{})
+	var errs []*Error
+	for _, def := range op.variables {
+		raw, ok := given[def.name]
+		if !ok && def.defaultValue != nil {
+			raw, ok = def.defaultValue, true
+		}
+		if !ok || raw == nil {
+			if strings.HasSuffix(def.typ, "!") {
+				errs = append(errs, &Error{Message: fmt.Sprintf("variable $%s of type %s is required", def.name, def.typ), Locations: []Location{def.loc}})
+			}
+			continue
+		}
+
+		value, err := coerceVariable(def.typ, raw)
+		if err != nil {
+			errs = append(errs, &Error{Message: fmt.Sprintf("variable $%s: %v", def.name, err), Locations: []Location{def.loc}})
+			continue
+		}
+		values[def.name] = value
+	}
+	return values, errs
+}
+
+func coerceVariable(typ string, raw interface{}) (interface{}, error) {
+	typ = strings.TrimSuffix(typ, "!")
+	if !strings.HasPrefix(typ, "[") {
+		return coerceScalar(ScalarType(typ), raw)
+	}
+
+	inner := typ[1 : len(typ)-1]
+	items, ok := raw.([]interface{})
+	if !ok {
+		// A single value is a list of one
+		items = []interface{}{raw}
+	}
+	list := make([]interface{}, len(items))
+	for i, item := range items {
+		if item == nil {
+			if strings.HasSuffix(inner, "!") {
+				return nil, fmt.Errorf("item %d cannot be null", i)
+			}
+			continue
+		}
+		value, err := coerceVariable(inner, item)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = value
+	}
+	return list, nil
+}
+
+// coerceScalar converts a literal or JSON value to an argument type
+func coerceScalar(typ ScalarType, raw interface{}) (interface{}, error) {
+	switch typ {
+	case String:
+		if s, ok := raw.(string); ok {
+			return s, nil
+		}
+	case ID:
+		switch value := raw.(type) {
+		case string:
+			return value, nil
+		case int64:
+			return strconv.FormatInt(value, 10), nil
+		case float64:
+			if value == math.Trunc(value) {
+				return strconv.FormatFloat(value, 'f', -1, 64), nil
+			}
+		}
+	case Int:
+		switch value := raw.(type) {
+		case int64:
+			if value >= math.MinInt32 && value <= math.MaxInt32 {
+				return int(value), nil
+			}
+		case float64: // from JSON variables
+			if value == math.Trunc(value) && value >= math.MinInt32 && value <= math.MaxInt32 {
+				return int(value), nil
+			}
+		}
+	case Float:
+		switch value := raw.(type) {
+		case int64:
+			return float64(value), nil
+		case float64:
+			return value, nil
+		}
+	case Boolean:
+		if b, ok := raw.(bool); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("expected %s, got %s", typ, describe(raw))
+}
+
+func describe(raw interface{}) string {
+	switch value := raw.(type) {
+	case enumValue:
+		return string(value)
+	case string:
+		return strconv.Quote(value)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprint(raw)
+}
+
+func sameArguments(a, b []*argument) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x.name == y.name && reflect.DeepEqual(x.value, y.value) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func selectionLoc(sel selection) Location {
+	switch sel := sel.(type) {
+	case *field:
+		return sel.loc
+	case *fragmentSpread:
+		return sel.loc
+	case *inlineFragment:
+		return sel.loc
+	}
+	return Location{}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// executor resolves a validated operation
+type executor struct {
+	doc       *document
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+// selectionSet resolves the fields selected on an object
+func (e *executor) selectionSet(ctx context.Context, obj *Object, source interface{}, selections []selection, path []interface{}) *orderedMap {
+	result := &orderedMap{values: make(map[string]interface{})}
+	keys, grouped := e.collectFields(selections, nil, nil)
+	for _, key := range keys {
+		fields := grouped[key]
+		fieldPath := append(append([]interface{}{}, path...), key)
+		result.set(key, e.field(ctx, obj, source, fields, fieldPath))
+	}
+	return result
+}
+
+// collectFields groups the selected fields by response key, in order,
+// expanding fragments and applying @skip and @include
+func (e *executor) collectFields(selections []selection, keys []string, grouped map[string][]*field) ([]string, map[string][]*field) {
+	if grouped == nil {
+		grouped = make(map[string][]*field)
+	}
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			if !e.included(sel.directives) {
+				continue
+			}
+			key := sel.responseKey()
+			if _, seen := grouped[key]; !seen {
+				keys = append(keys, key)
+			}
+			grouped[key] = append(grouped[key], sel)
+		case *fragmentSpread:
+			if e.included(sel.directives) {
+				keys, grouped = e.collectFields(e.doc.fragments[sel.name].selection, keys, grouped)
+			}
+		case *inlineFragment:
+			if e.included(sel.directives) {
+				keys, grouped = e.collectFields(sel.selection, keys, grouped)
+			}
+		}
+	}
+	return keys, grouped
+}
+
+func (e *executor) included(directives []*directive) bool {
+	for _, d := range directives {
+		condition, _ := e.value(d.arguments[0].value).(bool)
+		if (d.name == "skip" && condition) || (d.name == "include" && !condition) {
+			return false
+		}
+	}
+	return true
+}
+
+// field resolves one response key; fields selected more than once under the
+// same key have their subselections merged
+func (e *executor) field(ctx context.Context, obj *Object, source interface{}, fields []*field, path []interface{}) interface{} {
+	f := fields[0]
+	if f.name == "__typename" {
+		return obj.Name
+	}
+	def := obj.Fields[f.name]
+
+	args, err := e.arguments(def, f)
+	if err == nil {
+		err = ctx.Err()
+	}
+	var resolved interface{}
+	if err == nil {
+		resolved, err = def.Resolve(ctx, source, args)
+	}
+	if err != nil {
+		e.errors = append(e.errors, &Error{Message: err.Error(), Locations: []Location{f.loc}, Path: path})
+		return nil
+	}
+
+	if def.Type == nil || isNil(resolved) {
+		return resolved
+	}
+	var selections []selection
+	for _, f := range fields {
+		selections = append(selections, f.selection...)
+	}
+
+	list := reflect.ValueOf(resolved)
+	if list.Kind() != reflect.Slice {
+		return e.selectionSet(ctx, def.Type, resolved, selections, path)
+	}
+	items := make([]interface{}, list.Len())
+	for i := range items {
+		item := list.Index(i).Interface()
+		if !isNil(item) {
+			items[i] = e.selectionSet(ctx, def.Type, item, selections, append(append([]interface{}{}, path...), i))
+		}
+	}
+	return items
+}
+
+// arguments coerces the arguments of a field, filling in defaults
+func (e *executor) arguments(def *Field, f *field) (Args, error) {
+	args := make(Args)
+	for _, arg := range f.arguments {
+		raw := e.value(arg.value)
+		if raw == nil {
+			continue
+		}
+		if _, isVariable := arg.value.(variableRef); isVariable {
+			// Variables were coerced to their declared type; convert to the argument's
+			value, err := coerceScalar(def.Args[arg.name].Type, toLiteral(raw))
+			if err != nil {
+				return nil, fmt.Errorf("argument %q: %v", arg.name, err)
+			}
+			args[arg.name] = value
+			continue
+		}
+		value, _ := coerceScalar(def.Args[arg.name].Type, raw) // checked by the validator
+		args[arg.name] = value
+	}
+	for name, declared := range def.Args {
+		if _, ok := args[name]; ok {
+			continue
+		}
+		if declared.Required {
+			return nil, fmt.Errorf("argument %q cannot be null", name)
+		}
+		if declared.Default != nil {
+			args[name] = declared.Default
+		}
+	}
+	return args, nil
+}
+
+// value resolves a variable reference
+func (e *executor) value(v value) interface{} {
+	if ref, ok := v.(variableRef); ok {
+		return e.variables[string(ref)]
+	}
+	return v
+}
+
+// toLiteral maps a coerced variable back to literal form for coerceScalar
+func toLiteral(v interface{}) interface{} {
+	if n, ok := v.(int); ok {
+		return int64(n)
+	}
+	return v
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// orderedMap is an object result; keys are encoded in selection order, as
+// the spec requires
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if _, exists := m.values[key]; !exists {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		b.Write(name)
+		b.WriteByte(':')
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,231 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testAuthor struct {
+	Name string
+}
+
+type testBook struct {
+	ID     string
+	Title  string
+	Author *testAuthor
+	Tags   []string
+}
+
+var testBooks = []*testBook{
+	{ID: "1", Title: "Dune", Author: &testAuthor{Name: "Frank"}, Tags: []string{"sf"}},
+	{ID: "2", Title: "Emma", Author: &testAuthor{Name: "Jane"}},
+	{ID: "3", Title: "Untitled"},
+}
+
+func testSchema() *Schema {
+	author := NewObject("Author")
+	author.Fields["name"] = &Field{Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+		return source.(*testAuthor).Name, nil
+	}}
+
+	book := NewObject("Book")
+	book.Fields["id"] = &Field{Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+		return source.(*testBook).ID, nil
+	}}
+	book.Fields["title"] = &Field{
+		Args: map[string]Arg{"upper": {Type: Boolean, Default: false}},
+		Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			if args.Bool("upper") {
+				return strings.ToUpper(source.(*testBook).Title), nil
+			}
+			return source.(*testBook).Title, nil
+		},
+	}
+	book.Fields["tags"] = &Field{Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+		return source.(*testBook).Tags, nil
+	}}
+	book.Fields["author"] = &Field{Type: author, Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+		return source.(*testBook).Author, nil // typed nil for book 3
+	}}
+	book.Fields["broken"] = &Field{Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+		return nil, errors.New("boom")
+	}}
+
+	query := NewObject("Query")
+	query.Fields["books"] = &Field{
+		Type: book,
+		Args: map[string]Arg{"limit": {Type: Int, Default: 10}},
+		Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			limit := args.Int("limit")
+			if limit > len(testBooks) {
+				limit = len(testBooks)
+			}
+			return testBooks[:limit], nil
+		},
+	}
+	query.Fields["book"] = &Field{
+		Type: book,
+		Args: map[string]Arg{"id": {Type: ID, Required: true}},
+		Resolve: func(ctx context.Context, source interface{}, args Args) (interface{}, error) {
+			for _, b := range testBooks {
+				if b.ID == args.String("id") {
+					return b, nil
+				}
+			}
+			return nil, nil
+		},
+	}
+	return &Schema{Query: query, MaxDepth: 3}
+}
+
+func run(t *testing.T, req Request) (string, []*Error) {
+	t.Helper()
+	resp := testSchema().Execute(context.Background(), req)
+	if resp.Data == nil {
+		return "", resp.Errors
+	}
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatalf("failed to encode data: %v", err)
+	}
+	return string(data), resp.Errors
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			"fields in selection order, nested objects and null objects",
+			Request{Query: `{ books { title id author { name } } }`},
+			`{"books":[{"title":"Dune","id":"1","author":{"name":"Frank"}},{"title":"Emma","id":"2","author":{"name":"Jane"}},{"title":"Untitled","id":"3","author":null}]}`,
+		},
+		{
+			"aliases, arguments and __typename",
+			Request{Query: `query { first: book(id: 1) { __typename loud: title(upper: true) title tags } }`},
+			`{"first":{"__typename":"Book","loud":"DUNE","title":"Dune","tags":["sf"]}}`,
+		},
+		{
+			"variables with defaults",
+			Request{Query: `query Books($n: Int = 1, $id: ID!) { books(limit: $n) { id } book(id: $id) { title } }`, Variables: map[string]interface{}{"id": "2"}},
+			`{"books":[{"id":"1"}],"book":{"title":"Emma"}}`,
+		},
+		{
+			"named and inline fragments merge with fields",
+			Request{Query: `
+				query { book(id: "1") { ...Basics ... on Book { author { name } } author { __typename } } }
+				fragment Basics on Book { id title }`},
+			`{"book":{"id":"1","title":"Dune","author":{"name":"Frank","__typename":"Author"}}}`,
+		},
+		{
+			"skip and include",
+			Request{Query: `query ($more: Boolean!) { book(id: "2") { id title @include(if: $more) tags @skip(if: true) } }`, Variables: map[string]interface{}{"more": false}},
+			`{"book":{"id":"2"}}`,
+		},
+		{
+			"operation picked by name",
+			Request{Query: `query A { book(id: "1") { id } } query B { book(id: "2") { id } }`, OperationName: "B"},
+			`{"book":{"id":"2"}}`,
+		},
+		{
+			"strings with escapes and block strings",
+			Request{Query: "{ a: book(id: \"\\u0031\") { id } b: book(id: \"\"\"\n    2\n  \"\"\") { id } }"},
+			`{"a":{"id":"1"},"b":{"id":"2"}}`,
+		},
+	}
+	for _, tt := range tests {
+		got, errs := run(t, tt.req)
+		if len(errs) > 0 {
+			t.Errorf("%s: unexpected errors %v", tt.name, errs)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExecute_FieldErrors(t *testing.T) {
+	got, errs := run(t, Request{Query: `{ book(id: "1") { id broken } }`})
+	if got != `{"book":{"id":"1","broken":null}}` {
+		t.Errorf("expected the failed field to be null, got %s", got)
+	}
+	if len(errs) != 1 || errs[0].Message != "boom" {
+		t.Fatalf("expected one field error, got %v", errs)
+	}
+	if path, _ := json.Marshal(errs[0].Path); string(path) != `["book","broken"]` || errs[0].Locations[0] != (Location{Line: 1, Column: 22}) {
+		t.Errorf("unexpected error path %s at %+v", path, errs[0].Locations)
+	}
+}
+
+func TestExecute_RequestErrors(t *testing.T) {
+	tests := []struct {
+		query     string
+		variables map[string]interface{}
+		want      string
+	}{
+		{`{ book(id: "1") { id `, nil, "Syntax error: unexpected end of query"},
+		{`{ books { isbn } }`, nil, `unknown field "isbn" on Book`},
+		{`{ books }`, nil, `needs a selection of subfields`},
+		{`{ books { id { x } } }`, nil, `is a scalar and takes no selections`},
+		{`{ book { id } }`, nil, `argument "id" of Query.book is required`},
+		{`{ books(limit: "ten") { id } }`, nil, `argument "limit": expected Int, got "ten"`},
+		{`{ books(first: 1) { id } }`, nil, `unknown argument "first"`},
+		{`query ($n: String) { books(limit: $n) { id } }`, nil, `cannot be used as argument "limit"`},
+		{`{ books(limit: $n) { id } }`, nil, `variable $n is not defined`},
+		{`query ($id: ID!) { book(id: $id) { id } }`, nil, `variable $id of type ID! is required`},
+		{`query ($n: Int) { books(limit: $n) { id } }`, map[string]interface{}{"n": 1.5}, `expected Int, got 1.5`},
+		{`{ books { ...Missing } }`, nil, `unknown fragment "Missing"`},
+		{`{ books { ...A } } fragment A on Book { ...A }`, nil, `fragment "A" spreads itself`},
+		{`{ books { ... on Author { name } } }`, nil, `fragment on Author cannot be spread on Book`},
+		{`{ books { id: title id } }`, nil, `fields "id" conflict`},
+		{`{ books { author { name @deprecated } } }`, nil, `unknown directive @deprecated`},
+		{`{ __schema { types { name } } }`, nil, `introspection field "__schema" is not supported`},
+		{`{ books { author { books { id } } } }`, nil, `unknown field "books" on Author`},
+		{`mutation { books { id } }`, nil, `mutation operations are not supported`},
+		{`query A { books { id } } query B { books { id } }`, nil, `operationName is required`},
+	}
+	for _, tt := range tests {
+		got, errs := run(t, Request{Query: tt.query, Variables: tt.variables})
+		if got != "" {
+			t.Errorf("%s: expected no data, got %s", tt.query, got)
+		}
+		if len(errs) == 0 || !strings.Contains(errs[0].Message, tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.query, tt.want, errs)
+		}
+	}
+}
+
+func TestExecute_MaxCost(t *testing.T) {
+	schema := testSchema()
+	schema.Query.Fields["book"].Cost = 1
+	schema.MaxCost = 2
+
+	resp := schema.Execute(context.Background(), Request{Query: `{ a: book(id: 1) { id } b: book(id: 2) { id } books { id } }`})
+	if len(resp.Errors) != 0 {
+		t.Fatalf("expected a query within the limit to run, got %v", resp.Errors)
+	}
+	for _, query := range []string{
+		`{ a: book(id: 1) { id } b: book(id: 2) { id } c: book(id: 3) { id } }`,
+		`{ a: book(id: 1) { id } ...F ...F } fragment F on Query { b: book(id: 2) { id } }`,
+	} {
+		resp := schema.Execute(context.Background(), Request{Query: query})
+		if resp.Data != nil || len(resp.Errors) != 1 || resp.Errors[0].Message != "query is too expensive (cost 3, at most 2)" {
+			t.Errorf("%s: expected a cost error, got %v", query, resp.Errors)
+		}
+	}
+}
+
+func TestExecute_MaxDepth(t *testing.T) {
+	schema := testSchema()
+	schema.MaxDepth = 2
+	resp := schema.Execute(context.Background(), Request{Query: `{ books { author { name } } }`})
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "nested too deeply") {
+		t.Errorf("expected a depth error, got %v", resp.Errors)
+	}
+}
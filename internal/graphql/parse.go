@@ -0,0 +1,764 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed request: its operations and fragment definitions
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind      string // query, mutation or subscription
+	name      string
+	variables []*variableDefinition
+	selection []selection
+	loc       Location
+}
+
+type variableDefinition struct {
+	name         string
+	typ          string // as written, e.g. [String!]!
+	defaultValue value
+	loc          Location
+}
+
+type fragment struct {
+	name          string
+	typeCondition string
+	selection     []selection
+	loc           Location
+}
+
+// selection is a *field, *fragmentSpread or *inlineFragment
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	arguments  []*argument
+	directives []*directive
+	selection  []selection
+	loc        Location
+}
+
+// responseKey is the name of the field in the result
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+	loc        Location
+}
+
+type inlineFragment struct {
+	typeCondition string // empty for the enclosing type
+	directives    []*directive
+	selection     []selection
+	loc           Location
+}
+
+type argument struct {
+	name  string
+	value value
+	loc   Location
+}
+
+type directive struct {
+	name      string
+	arguments []*argument
+	loc       Location
+}
+
+// value is an input literal: a variableRef, int64, float64, string,
+// bool, nil, enumValue, []value or map[string]value
+type value = interface{}
+
+type variableRef string
+
+type enumValue string
+
+// Location is a line and column in the query, both starting at 1
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Token kinds
+const (
+	tokenEOF = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  int
+	value string
+	loc   Location
+}
+
+// lexer splits a query into tokens; commas are insignificant, as in the spec
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	col  int
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	loc := Location{Line: l.line, Column: l.col}
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF, loc: loc}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("!$()&:=@[]{}|", c) >= 0:
+		l.advance(1)
+		return token{kind: tokenPunct, value: string(c), loc: loc}, nil
+	case c == '.':
+		if !strings.HasPrefix(l.src[l.pos:], "...") {
+			return token{}, syntaxError(loc, "unexpected \".\"")
+		}
+		l.advance(3)
+		return token{kind: tokenPunct, value: "...", loc: loc}, nil
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.advance(1)
+		}
+		return token{kind: tokenName, value: l.src[start:l.pos], loc: loc}, nil
+	case c == '-' || isDigit(c):
+		return l.number(loc)
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.blockString(loc)
+		}
+		return l.string(loc)
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, syntaxError(loc, fmt.Sprintf("unexpected character %q", r))
+}
+
+// skipIgnored skips whitespace, commas, comments and a byte order mark
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == '\n':
+			l.pos++
+			l.line++
+			l.col = 1
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			l.advance(1)
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance(1)
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\ufeff"):
+			l.pos += len("\ufeff")
+		default:
+			return
+		}
+	}
+}
+
+// advance moves n bytes forward on the current line
+func (l *lexer) advance(n int) {
+	l.pos += n
+	l.col += n
+}
+
+func (l *lexer) number(loc Location) (token, error) {
+	start := l.pos
+	if l.src[l.pos] == '-' {
+		l.advance(1)
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.advance(1)
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return token{}, syntaxError(loc, "invalid number")
+	}
+	kind := tokenInt
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		l.advance(1)
+		if digits() == 0 {
+			return token{}, syntaxError(loc, "invalid number")
+		}
+		kind = tokenFloat
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		l.advance(1)
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.advance(1)
+		}
+		if digits() == 0 {
+			return token{}, syntaxError(loc, "invalid number")
+		}
+		kind = tokenFloat
+	}
+	return token{kind: kind, value: l.src[start:l.pos], loc: loc}, nil
+}
+
+func (l *lexer) string(loc Location) (token, error) {
+	l.advance(1)
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.advance(1)
+			return token{kind: tokenString, value: b.String(), loc: loc}, nil
+		case c == '\n':
+			return token{}, syntaxError(loc, "unterminated string")
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, syntaxError(loc, "unterminated string")
+			}
+			escape := l.src[l.pos+1]
+			l.advance(2)
+			switch escape {
+			case '"', '\\', '/':
+				b.WriteByte(escape)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return token{}, syntaxError(loc, "invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return token{}, syntaxError(loc, "invalid unicode escape")
+				}
+				b.WriteRune(rune(code))
+				l.advance(4)
+			default:
+				return token{}, syntaxError(loc, fmt.Sprintf("invalid escape \\%c", escape))
+			}
+		default:
+			_, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			b.WriteString(l.src[l.pos : l.pos+size])
+			l.advance(size)
+		}
+	}
+	return token{}, syntaxError(loc, "unterminated string")
+}
+
+// blockString reads a """ string; common indentation is removed as in the spec
+func (l *lexer) blockString(loc Location) (token, error) {
+	l.advance(3)
+	var raw strings.Builder
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], `"""`):
+			l.advance(3)
+			return token{kind: tokenString, value: dedentBlockString(raw.String()), loc: loc}, nil
+		case strings.HasPrefix(l.src[l.pos:], `\"""`):
+			raw.WriteString(`"""`)
+			l.advance(4)
+		case l.src[l.pos] == '\n':
+			raw.WriteByte('\n')
+			l.pos++
+			l.line++
+			l.col = 1
+		default:
+			raw.WriteByte(l.src[l.pos])
+			l.advance(1)
+		}
+	}
+	return token{}, syntaxError(loc, "unterminated block string")
+}
+
+func dedentBlockString(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parser reads a document with one token of lookahead
+type parser struct {
+	lexer *lexer
+	tok   token
+}
+
+// parse parses a query document
+func parse(src string) (*document, error) {
+	p := &parser{lexer: &lexer{src: src, line: 1, col: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunct, "{"):
+			op := &operation{kind: "query", loc: p.tok.loc}
+			selection, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			op.selection = selection
+			doc.operations = append(doc.operations, op)
+		case p.peek(tokenName, "query"), p.peek(tokenName, "mutation"), p.peek(tokenName, "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.peek(tokenName, "fragment"):
+			frag, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := doc.fragments[frag.name]; exists {
+				return nil, &Error{Message: fmt.Sprintf("fragment %q is defined more than once", frag.name), Locations: []Location{frag.loc}}
+			}
+			doc.fragments[frag.name] = frag
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "document has no operation"}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peek(kind int, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+// expect consumes a punctuator or keyword
+func (p *parser) expect(kind int, value string) error {
+	if !p.peek(kind, value) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+// skip consumes a punctuator if it is next
+func (p *parser) skip(value string) (bool, error) {
+	if !p.peek(tokenPunct, value) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return syntaxError(p.tok.loc, "unexpected end of query")
+	}
+	return syntaxError(p.tok.loc, fmt.Sprintf("unexpected %q", p.tok.value))
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: p.tok.value, loc: p.tok.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenName {
+		op.name = p.tok.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.peek(tokenPunct, ")") {
+			def, err := p.variableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, def)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek(tokenPunct, "@") {
+		return nil, syntaxError(p.tok.loc, "operation directives are not supported")
+	}
+	selection, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selection = selection
+	return op, nil
+}
+
+func (p *parser) variableDefinition() (*variableDefinition, error) {
+	def := &variableDefinition{loc: p.tok.loc}
+	if err := p.expect(tokenPunct, "$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	def.name = name
+	if err := p.expect(tokenPunct, ":"); err != nil {
+		return nil, err
+	}
+	if def.typ, err = p.typeRef(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip("="); err != nil {
+		return nil, err
+	} else if ok {
+		if def.defaultValue, err = p.value(true); err != nil {
+			return nil, err
+		}
+	}
+	return def, nil
+}
+
+// typeRef reads a type such as String, [ID!] or Int! and returns it as written
+func (p *parser) typeRef() (string, error) {
+	var typ string
+	if ok, err := p.skip("["); err != nil {
+		return "", err
+	} else if ok {
+		inner, err := p.typeRef()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect(tokenPunct, "]"); err != nil {
+			return "", err
+		}
+		typ = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		typ = name
+	}
+	if ok, err := p.skip("!"); err != nil {
+		return "", err
+	} else if ok {
+		typ += "!"
+	}
+	return typ, nil
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	frag := &fragment{loc: p.tok.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, syntaxError(frag.loc, "fragment cannot be named \"on\"")
+	}
+	frag.name = name
+	if err := p.expect(tokenName, "on"); err != nil {
+		return nil, err
+	}
+	if frag.typeCondition, err = p.name(); err != nil {
+		return nil, err
+	}
+	if p.peek(tokenPunct, "@") {
+		return nil, syntaxError(p.tok.loc, "fragment definition directives are not supported")
+	}
+	if frag.selection, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return frag, nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect(tokenPunct, "{"); err != nil {
+		return nil, err
+	}
+	var selections []selection
+	for !p.peek(tokenPunct, "}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+	if len(selections) == 0 {
+		return nil, syntaxError(p.tok.loc, "empty selection set")
+	}
+	return selections, p.advance()
+}
+
+func (p *parser) selection() (selection, error) {
+	loc := p.tok.loc
+	if ok, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		if p.tok.kind == tokenName && p.tok.value != "on" {
+			spread := &fragmentSpread{name: p.tok.value, loc: loc}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			directives, err := p.directives()
+			if err != nil {
+				return nil, err
+			}
+			spread.directives = directives
+			return spread, nil
+		}
+
+		inline := &inlineFragment{loc: loc}
+		if p.peek(tokenName, "on") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if inline.typeCondition, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if inline.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		if inline.selection, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+		return inline, nil
+	}
+
+	f := &field{loc: loc}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		f.alias = name
+		if name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	f.name = name
+	if f.arguments, err = p.arguments(); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek(tokenPunct, "{") {
+		if f.selection, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) arguments() ([]*argument, error) {
+	if ok, err := p.skip("("); err != nil || !ok {
+		return nil, err
+	}
+	var args []*argument
+	for !p.peek(tokenPunct, ")") {
+		arg := &argument{loc: p.tok.loc}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		arg.name = name
+		if err := p.expect(tokenPunct, ":"); err != nil {
+			return nil, err
+		}
+		if arg.value, err = p.value(false); err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) == 0 {
+		return nil, syntaxError(p.tok.loc, "empty argument list")
+	}
+	return args, p.advance()
+}
+
+func (p *parser) directives() ([]*directive, error) {
+	var directives []*directive
+	for p.peek(tokenPunct, "@") {
+		d := &directive{loc: p.tok.loc}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		d.name = name
+		if d.arguments, err = p.arguments(); err != nil {
+			return nil, err
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+// value reads an input value; constant values (variable defaults) can't
+// reference variables
+func (p *parser) value(constant bool) (value, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenPunct:
+		switch tok.value {
+		case "$":
+			if constant {
+				return nil, syntaxError(tok.loc, "variables are not allowed in default values")
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			return variableRef(name), nil
+		case "[":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			list := []value{}
+			for !p.peek(tokenPunct, "]") {
+				item, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			return list, p.advance()
+		case "{":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			object := map[string]value{}
+			for !p.peek(tokenPunct, "}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(tokenPunct, ":"); err != nil {
+					return nil, err
+				}
+				if object[name], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+			return object, p.advance()
+		}
+	case tokenInt:
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, syntaxError(tok.loc, "integer out of range")
+		}
+		return n, p.advance()
+	case tokenFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, syntaxError(tok.loc, "invalid float")
+		}
+		return f, p.advance()
+	case tokenString:
+		return tok.value, p.advance()
+	case tokenName:
+		var v value
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = enumValue(tok.value)
+		}
+		return v, p.advance()
+	}
+	return nil, p.unexpected()
+}
+
+func syntaxError(loc Location, message string) *Error {
+	return &Error{Message: "Syntax error: " + message, Locations: []Location{loc}}
+}
@@ -0,0 +1,141 @@
+// Package graphql executes GraphQL queries against a schema of Go resolver
+// functions. It covers the query language clients use — operations,
+// variables, aliases, arguments, nested selections, fragments and
+// @skip/@include — over object types and scalars. Mutations, subscriptions
+// and introspection beyond __typename are not supported. Validation is
+// lenient only where results don't change: unused fragments and variables
+// are allowed. conformance_test.go checks the behavior against the spec.
+package graphql
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultMaxDepth bounds how deeply selections may nest, so one request
+// can't fan out without limit over nested lists
+const DefaultMaxDepth = 8
+
+// Schema is the query root of a GraphQL API. MaxCost bounds the summed Cost
+// of the fields a query selects, so aliases can't repeat an expensive field
+// without limit.
+type Schema struct {
+	Query    *Object
+	MaxDepth int // 0 means DefaultMaxDepth
+	MaxCost  int // 0 means no limit
+}
+
+// Object is an object type. Fields may be added after the object is
+// referenced, so types can refer to each other.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// NewObject creates an object type without fields
+func NewObject(name string) *Object {
+	return &Object{Name: name, Fields: make(map[string]*Field)}
+}
+
+// Field is a field of an object type
+type Field struct {
+	// Type is the object type of the resolved value, or nil for scalars and
+	// lists of scalars (returned as-is and encoded as JSON). A resolved
+	// slice is a list of objects of this type.
+	Type    *Object
+	Args    map[string]Arg
+	Resolve ResolveFunc
+	// Cost counts against the schema's MaxCost once for every time the
+	// field appears in a query, fragments included
+	Cost int
+}
+
+// ResolveFunc returns the value of a field. source is the value the parent
+// field resolved to (nil for query root fields). A returned error nulls the
+// field and is reported with its path; sibling fields are unaffected.
+type ResolveFunc func(ctx context.Context, source interface{}, args Args) (interface{}, error)
+
+// ScalarType is the type of an argument
+type ScalarType string
+
+// Argument types
+const (
+	String  ScalarType = "String"
+	Int     ScalarType = "Int"
+	Float   ScalarType = "Float"
+	Boolean ScalarType = "Boolean"
+	ID      ScalarType = "ID"
+)
+
+// Arg declares an argument of a field
+type Arg struct {
+	Type     ScalarType
+	Required bool
+	Default  interface{} // used when the argument is not given
+}
+
+// Args are the coerced arguments of a field: string (String and ID), int,
+// float64 or bool. Arguments that were not given and have no default are
+// absent.
+type Args map[string]interface{}
+
+// String returns a String or ID argument, or "" if absent
+func (a Args) String(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+// Int returns an Int argument, or 0 if absent
+func (a Args) Int(name string) int {
+	n, _ := a[name].(int)
+	return n
+}
+
+// Float returns a Float argument, or 0 if absent
+func (a Args) Float(name string) float64 {
+	f, _ := a[name].(float64)
+	return f
+}
+
+// Bool returns a Boolean argument, or false if absent
+func (a Args) Bool(name string) bool {
+	b, _ := a[name].(bool)
+	return b
+}
+
+// Has reports whether an argument was given or has a default
+func (a Args) Has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is absent when the request
+// failed before execution (syntax, validation or variable errors).
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error, located in the query and, for field errors, in
+// the response
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// baseType strips list and non-null markers from a variable type
+func baseType(typ string) string {
+	return strings.Trim(typ, "[]!")
+}
@@ -0,0 +1,152 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the warehouse's REST API on behalf of the MCP tools
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the server at baseURL (e.g.
+// http://localhost:8080). The token is sent as a bearer token if set.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// BaseURL returns the server the client talks to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// APIError is a non-2xx response from the warehouse
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("warehouse returned %d: %s", e.Status, e.Message)
+}
+
+// Search runs a natural-language search
+func (c *Client) Search(ctx context.Context, query string, limit int) (json.RawMessage, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "limit": limit})
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, http.MethodPost, "/api/v1/search", "application/json", bytes.NewReader(body), nil)
+}
+
+// GetImage returns an image's metadata, or its processing status while the
+// upload is still being processed
+func (c *Client) GetImage(ctx context.Context, id string) (json.RawMessage, error) {
+	return c.do(ctx, http.MethodGet, "/api/v1/images/"+url.PathEscape(id), "", nil, nil)
+}
+
+// ListImages lists images with only the given fields
+func (c *Client) ListImages(ctx context.Context, fields ...string) (json.RawMessage, error) {
+	query := url.Values{"fields": {strings.Join(fields, ",")}}
+	return c.do(ctx, http.MethodGet, "/api/v1/images?"+query.Encode(), "", nil, nil)
+}
+
+// Upload is a 2D image to file, with the upload form's metadata
+type Upload struct {
+	Filename       string
+	Content        io.Reader
+	Title          string
+	Artist         string
+	Tags           []string
+	Project        string
+	ExternalID     string
+	Visibility     string
+	IdempotencyKey string
+}
+
+// UploadImage sends an image to the upload endpoint; the server processes it
+// in the background
+func (c *Client) UploadImage(ctx context.Context, upload Upload) (json.RawMessage, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("image", upload.Filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, upload.Content); err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	var tags string
+	if len(upload.Tags) > 0 {
+		encoded, _ := json.Marshal(upload.Tags)
+		tags = string(encoded)
+	}
+	for name, value := range map[string]string{
+		"title":       upload.Title,
+		"artist":      upload.Artist,
+		"tags":        tags, // JSON array, like the upload form
+		"project":     upload.Project,
+		"external_id": upload.ExternalID,
+		"visibility":  upload.Visibility,
+	} {
+		if value != "" {
+			form.WriteField(name, value)
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	var header http.Header
+	if upload.IdempotencyKey != "" {
+		header = http.Header{"Idempotency-Key": {upload.IdempotencyKey}}
+	}
+	return c.do(ctx, http.MethodPost, "/api/v1/images/upload", form.FormDataContentType(), &body, header)
+}
+
+// do sends a request and returns the JSON response body
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, header http.Header) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("warehouse not reachable at %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, &APIError{Status: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	return data, nil
+}
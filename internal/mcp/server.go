@@ -0,0 +1,209 @@
+// Package mcp exposes the warehouse to AI agents and IDE assistants as a
+// Model Context Protocol server: JSON-RPC 2.0 messages, one per line, over
+// stdin and stdout. Tools go through the warehouse's REST API, so uploads
+// are processed by the running server and API token roles apply.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProtocolVersion is the latest MCP revision the server speaks
+const ProtocolVersion = "2025-03-26"
+
+// supportedVersions are the revisions a client may ask for in initialize
+var supportedVersions = []string{ProtocolVersion, "2024-11-05"}
+
+// maxMessageSize bounds one message; uploads may carry base64 image data
+const maxMessageSize = 96 << 20
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Server answers MCP requests with the warehouse's tools
+type Server struct {
+	tools  *Tools
+	name   string
+	logger *logrus.Logger
+}
+
+func NewServer(tools *Tools, name string, logger *logrus.Logger) *Server {
+	return &Server{
+		tools:  tools,
+		name:   name,
+		logger: logger,
+	}
+}
+
+// Serve reads messages from in and writes responses to out until in is
+// closed or ctx is done. Requests are answered in order.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	encoder := json.NewEncoder(out)
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if reply := s.handleMessage(ctx, line); reply != nil {
+			if err := encoder.Encode(reply); err != nil {
+				return fmt.Errorf("failed to write response: %w", err)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// handleMessage answers one line: a request, a notification or a batch.
+// It returns nil when nothing is to be sent back.
+func (s *Server) handleMessage(ctx context.Context, line []byte) interface{} {
+	if line[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(line, &batch); err != nil || len(batch) == 0 {
+			return errorResponse(nil, codeParseError, "invalid batch")
+		}
+		var replies []*response
+		for _, message := range batch {
+			if reply := s.handleRequest(ctx, message); reply != nil {
+				replies = append(replies, reply)
+			}
+		}
+		if len(replies) == 0 {
+			return nil
+		}
+		return replies
+	}
+
+	if reply := s.handleRequest(ctx, line); reply != nil {
+		return reply
+	}
+	return nil
+}
+
+// handleRequest answers a single message; notifications get no response
+func (s *Server) handleRequest(ctx context.Context, message []byte) *response {
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		return errorResponse(nil, codeParseError, "invalid JSON")
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, codeInvalidRequest, "not a JSON-RPC 2.0 request")
+	}
+	if len(req.ID) == 0 {
+		s.logger.Debugf("MCP notification %s", req.Method)
+		return nil
+	}
+
+	result, rpcErr := s.call(ctx, req.Method, req.Params)
+	if rpcErr != nil {
+		return &response{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+	return &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// call dispatches a request method
+func (s *Server) call(ctx context.Context, method string, params json.RawMessage) (interface{}, *rpcError) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &p); err != nil {
+				return nil, &rpcError{Code: codeInvalidParams, Message: "invalid initialize params"}
+			}
+		}
+		version := ProtocolVersion
+		if slices.Contains(supportedVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		return map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": s.name, "version": "1.0.0"},
+			"instructions":    "Search, inspect and file images in the image warehouse. Uploads are categorized by AI in the background; poll get_image until status is completed.",
+		}, nil
+
+	case "ping":
+		return map[string]interface{}{}, nil
+
+	case "tools/list":
+		return map[string]interface{}{"tools": s.tools.List()}, nil
+
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil || p.Name == "" {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "tools/call needs a tool name"}
+		}
+		result, err := s.tools.Call(ctx, p.Name, p.Arguments)
+		if err != nil {
+			if err == errUnknownTool {
+				return nil, &rpcError{Code: codeInvalidParams, Message: "unknown tool: " + p.Name}
+			}
+			// Tool failures are results, so the agent sees and can react to them
+			s.logger.Warnf("MCP tool %s failed: %v", p.Name, err)
+			return toolResult(err.Error(), true), nil
+		}
+		return toolResult(result, false), nil
+	}
+
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + method}
+}
+
+// toolResult wraps a tool's text output as a tools/call result
+func toolResult(text string, isError bool) map[string]interface{} {
+	result := map[string]interface{}{
+		"content": []map[string]interface{}{{"type": "text", "text": text}},
+	}
+	if isError {
+		result["isError"] = true
+	}
+	return result
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: message}}
+}
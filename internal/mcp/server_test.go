@@ -0,0 +1,382 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeWarehouse stands in for the server's REST API and records uploads
+type fakeWarehouse struct {
+	lastSearch map[string]interface{}
+	lastUpload map[string]string
+	lastAuth   string
+}
+
+func (f *fakeWarehouse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lastAuth = r.Header.Get("Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/search":
+		json.NewDecoder(r.Body).Decode(&f.lastSearch)
+		io.WriteString(w, `{"query":"cat","results":[{"image":{"id":"img-1","title":"Night Cat"},"score":0.9}],"total":1}`)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/images/img-1":
+		io.WriteString(w, `{"id":"img-1","title":"Night Cat","file_path":"animals/cat.jpg","thumbnail_path":"thumbnails/cat.jpg"}`)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/images":
+		if r.URL.Query().Get("fields") != "category" {
+			http.Error(w, "unexpected fields", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"images":[{"category":"animals"},{"category":"landscapes"},{"category":"animals"},{"category":""}],"total":4}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/images/upload":
+		file, header, err := r.FormFile("image")
+		if err != nil {
+			http.Error(w, "No image file provided", http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("title") == "" || r.FormValue("artist") == "" {
+			http.Error(w, "Title and artist are required", http.StatusBadRequest)
+			return
+		}
+		var tags []string
+		if raw := r.FormValue("tags"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &tags); err != nil {
+				http.Error(w, "Invalid tags format", http.StatusBadRequest)
+				return
+			}
+		}
+		content, _ := io.ReadAll(file)
+		f.lastUpload = map[string]string{
+			"filename":        header.Filename,
+			"content":         string(content),
+			"title":           r.FormValue("title"),
+			"artist":          r.FormValue("artist"),
+			"tags":            strings.Join(tags, ","),
+			"visibility":      r.FormValue("visibility"),
+			"idempotency_key": r.Header.Get("Idempotency-Key"),
+		}
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, `{"id":"img-2","status":"queued"}`)
+	default:
+		http.Error(w, "Image not found", http.StatusNotFound)
+	}
+}
+
+func newTestServer(t *testing.T) (*Server, *fakeWarehouse) {
+	t.Helper()
+	warehouse := &fakeWarehouse{}
+	api := httptest.NewServer(warehouse)
+	t.Cleanup(api.Close)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewServer(NewTools(NewClient(api.URL+"/", "secret")), "image-warehouse", logger), warehouse
+}
+
+// exchange sends the given lines and returns the decoded responses
+func exchange(t *testing.T, server *Server, lines ...string) []json.RawMessage {
+	t.Helper()
+	var out strings.Builder
+	if err := server.Serve(context.Background(), strings.NewReader(strings.Join(lines, "\n")), &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+	var replies []json.RawMessage
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		replies = append(replies, json.RawMessage(scanner.Text()))
+	}
+	return replies
+}
+
+// callTool runs tools/call and returns the text and isError of the result
+func callTool(t *testing.T, server *Server, name string, arguments interface{}) (string, bool) {
+	t.Helper()
+	params, _ := json.Marshal(map[string]interface{}{"name": name, "arguments": arguments})
+	replies := exchange(t, server, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":`+string(params)+`}`)
+	if len(replies) != 1 {
+		t.Fatalf("expected 1 response, got %d", len(replies))
+	}
+	var resp struct {
+		Result struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+			IsError bool `json:"isError"`
+		} `json:"result"`
+		Error *rpcError `json:"error"`
+	}
+	if err := json.Unmarshal(replies[0], &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	if len(resp.Result.Content) != 1 || resp.Result.Content[0].Type != "text" {
+		t.Fatalf("expected one text content, got %+v", resp.Result.Content)
+	}
+	return resp.Result.Content[0].Text, resp.Result.IsError
+}
+
+func TestServer_Initialize(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tests := []struct {
+		requested string
+		expected  string
+	}{
+		{"2024-11-05", "2024-11-05"},
+		{ProtocolVersion, ProtocolVersion},
+		{"1999-01-01", ProtocolVersion},
+	}
+	for _, tt := range tests {
+		replies := exchange(t, server, `{"jsonrpc":"2.0","id":"init","method":"initialize","params":{"protocolVersion":"`+tt.requested+`","capabilities":{},"clientInfo":{"name":"test"}}}`)
+		var resp struct {
+			ID     string `json:"id"`
+			Result struct {
+				ProtocolVersion string                 `json:"protocolVersion"`
+				Capabilities    map[string]interface{} `json:"capabilities"`
+				ServerInfo      struct {
+					Name string `json:"name"`
+				} `json:"serverInfo"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(replies[0], &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.ID != "init" {
+			t.Errorf("expected id init, got %q", resp.ID)
+		}
+		if resp.Result.ProtocolVersion != tt.expected {
+			t.Errorf("requested %s: expected version %s, got %s", tt.requested, tt.expected, resp.Result.ProtocolVersion)
+		}
+		if _, ok := resp.Result.Capabilities["tools"]; !ok {
+			t.Error("expected tools capability")
+		}
+		if resp.Result.ServerInfo.Name != "image-warehouse" {
+			t.Errorf("expected server name image-warehouse, got %q", resp.Result.ServerInfo.Name)
+		}
+	}
+}
+
+func TestServer_ToolsList(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	replies := exchange(t, server, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	var resp struct {
+		Result struct {
+			Tools []Tool `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(replies[0], &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	var names []string
+	for _, tool := range resp.Result.Tools {
+		names = append(names, tool.Name)
+		if tool.InputSchema["type"] != "object" {
+			t.Errorf("tool %s: expected object input schema", tool.Name)
+		}
+	}
+	if strings.Join(names, ",") != "search_images,get_image,upload_image,list_categories" {
+		t.Errorf("unexpected tools: %v", names)
+	}
+}
+
+func TestTools_SearchImages(t *testing.T) {
+	server, warehouse := newTestServer(t)
+
+	text, isError := callTool(t, server, "search_images", map[string]interface{}{"query": "cat", "limit": 500})
+	if isError {
+		t.Fatalf("unexpected tool error: %s", text)
+	}
+	if !strings.Contains(text, "Night Cat") {
+		t.Errorf("expected search results, got %s", text)
+	}
+	if warehouse.lastSearch["query"] != "cat" || warehouse.lastSearch["limit"] != float64(maxSearchLimit) {
+		t.Errorf("expected query cat with limit %d, got %v", maxSearchLimit, warehouse.lastSearch)
+	}
+	if warehouse.lastAuth != "Bearer secret" {
+		t.Errorf("expected bearer token, got %q", warehouse.lastAuth)
+	}
+
+	callTool(t, server, "search_images", map[string]interface{}{"query": "cat"})
+	if warehouse.lastSearch["limit"] != float64(defaultSearchLimit) {
+		t.Errorf("expected default limit %d, got %v", defaultSearchLimit, warehouse.lastSearch["limit"])
+	}
+
+	if text, isError := callTool(t, server, "search_images", map[string]interface{}{"query": " "}); !isError {
+		t.Errorf("expected an error for an empty query, got %s", text)
+	}
+}
+
+func TestTools_GetImage(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	text, isError := callTool(t, server, "get_image", map[string]interface{}{"id": "img-1"})
+	if isError {
+		t.Fatalf("unexpected tool error: %s", text)
+	}
+	var image map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &image); err != nil {
+		t.Fatalf("expected JSON output: %v", err)
+	}
+	fileURL, _ := image["file_url"].(string)
+	if !strings.HasSuffix(fileURL, "/data/animals/cat.jpg") || strings.Contains(fileURL, "//data") {
+		t.Errorf("unexpected file_url %q", fileURL)
+	}
+	if thumb, _ := image["thumbnail_url"].(string); !strings.HasSuffix(thumb, "/data/thumbnails/cat.jpg") {
+		t.Errorf("unexpected thumbnail_url %q", thumb)
+	}
+	if _, ok := image["model_file_url"]; ok {
+		t.Error("expected no model_file_url for a 2D image")
+	}
+
+	// API errors are tool errors the agent can read
+	text, isError = callTool(t, server, "get_image", map[string]interface{}{"id": "missing"})
+	if !isError || !strings.Contains(text, "404") || !strings.Contains(text, "Image not found") {
+		t.Errorf("expected a 404 tool error, got %v %s", isError, text)
+	}
+}
+
+func TestTools_ListCategories(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	text, isError := callTool(t, server, "list_categories", nil)
+	if isError {
+		t.Fatalf("unexpected tool error: %s", text)
+	}
+	var result struct {
+		Categories  []categoryCount `json:"categories"`
+		TotalImages int             `json:"total_images"`
+	}
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		t.Fatalf("expected JSON output: %v", err)
+	}
+	expected := []categoryCount{{"animals", 2}, {"landscapes", 1}}
+	if len(result.Categories) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, result.Categories)
+	}
+	for i := range expected {
+		if result.Categories[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, result.Categories)
+		}
+	}
+	if result.TotalImages != 4 {
+		t.Errorf("expected 4 images, got %d", result.TotalImages)
+	}
+}
+
+func TestTools_UploadImage(t *testing.T) {
+	server, warehouse := newTestServer(t)
+
+	path := filepath.Join(t.TempDir(), "sketch.png")
+	if err := os.WriteFile(path, []byte("png-bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	text, isError := callTool(t, server, "upload_image", map[string]interface{}{
+		"path":            path,
+		"title":           "Sketch",
+		"artist":          "Ada",
+		"tags":            []string{"draft", "ink"},
+		"visibility":      "private",
+		"idempotency_key": "key-1",
+	})
+	if isError {
+		t.Fatalf("unexpected tool error: %s", text)
+	}
+	if !strings.Contains(text, "img-2") {
+		t.Errorf("expected the new image id, got %s", text)
+	}
+	expected := map[string]string{
+		"filename":        "sketch.png",
+		"content":         "png-bytes",
+		"title":           "Sketch",
+		"artist":          "Ada",
+		"tags":            "draft,ink",
+		"visibility":      "private",
+		"idempotency_key": "key-1",
+	}
+	for field, value := range expected {
+		if warehouse.lastUpload[field] != value {
+			t.Errorf("expected %s %q, got %q", field, value, warehouse.lastUpload[field])
+		}
+	}
+
+	// Base64 data instead of a path
+	data := base64.StdEncoding.EncodeToString([]byte("jpeg-bytes"))
+	if text, isError := callTool(t, server, "upload_image", map[string]interface{}{"data": data, "filename": "photo.jpg", "title": "Photo", "artist": "Ada"}); isError {
+		t.Fatalf("unexpected tool error: %s", text)
+	}
+	if warehouse.lastUpload["filename"] != "photo.jpg" || warehouse.lastUpload["content"] != "jpeg-bytes" {
+		t.Errorf("unexpected upload %v", warehouse.lastUpload)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{},
+		{"data": data},
+		{"data": "not base64!", "filename": "x.png"},
+		{"path": path, "data": data, "filename": "x.png"},
+		{"path": filepath.Join(t.TempDir(), "missing.png")},
+	} {
+		if text, isError := callTool(t, server, "upload_image", args); !isError {
+			t.Errorf("args %v: expected a tool error, got %s", args, text)
+		}
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	replies := exchange(t, server,
+		`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"delete_everything"}}`,
+		`not json`,
+		`{"id":3,"method":"ping"}`,
+	)
+	expected := []int{codeMethodNotFound, codeInvalidParams, codeParseError, codeInvalidRequest}
+	if len(replies) != len(expected) {
+		t.Fatalf("expected %d responses, got %d", len(expected), len(replies))
+	}
+	for i, reply := range replies {
+		var resp response
+		if err := json.Unmarshal(reply, &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Error == nil || resp.Error.Code != expected[i] {
+			t.Errorf("response %d: expected error %d, got %s", i, expected[i], reply)
+		}
+	}
+}
+
+func TestServer_NotificationsAndBatches(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	replies := exchange(t, server,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		``,
+		`[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/cancelled"},{"jsonrpc":"2.0","id":2,"method":"tools/list"}]`,
+		`[{"jsonrpc":"2.0","method":"notifications/cancelled"}]`,
+	)
+	if len(replies) != 1 {
+		t.Fatalf("expected only the batch to be answered, got %d responses", len(replies))
+	}
+	var batch []response
+	if err := json.Unmarshal(replies[0], &batch); err != nil {
+		t.Fatalf("expected a batch response: %v", err)
+	}
+	if len(batch) != 2 || string(batch[0].ID) != "1" || string(batch[1].ID) != "2" {
+		t.Errorf("expected responses to ids 1 and 2, got %s", replies[0])
+	}
+}
@@ -0,0 +1,277 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Search limits of the search_images tool
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+var errUnknownTool = errors.New("unknown tool")
+
+// Tool describes a tool in tools/list
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// Tools are the warehouse operations offered to agents: search_images,
+// get_image, upload_image and list_categories
+type Tools struct {
+	client *Client
+}
+
+func NewTools(client *Client) *Tools {
+	return &Tools{
+		client: client,
+	}
+}
+
+// List returns the tool descriptions
+func (t *Tools) List() []Tool {
+	return []Tool{
+		{
+			Name:        "search_images",
+			Description: "Search the image warehouse in natural language (e.g. \"dark cat at night\"). Returns matching images ranked by relevance, with the reason each matched.",
+			InputSchema: objectSchema(map[string]interface{}{
+				"query": stringProp("What to look for"),
+				"limit": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": maxSearchLimit, "description": fmt.Sprintf("Most results to return (default %d)", defaultSearchLimit)},
+			}, "query"),
+		},
+		{
+			Name:        "get_image",
+			Description: "Get an image's metadata: title, artist, category, AI description and tags, dimensions, license and file URLs. While an upload is processing, returns its status.",
+			InputSchema: objectSchema(map[string]interface{}{
+				"id": stringProp("Image ID"),
+			}, "id"),
+		},
+		{
+			Name:        "upload_image",
+			Description: "File a 2D image in the warehouse. Give a local file path, or base64 data with a filename. The image is analyzed and categorized in the background; poll get_image with the returned id until status is completed.",
+			InputSchema: objectSchema(map[string]interface{}{
+				"path":            stringProp("Local path of the image file"),
+				"data":            stringProp("Base64-encoded image, instead of path"),
+				"filename":        stringProp("File name of the data, e.g. sketch.png"),
+				"title":           stringProp("Title"),
+				"artist":          stringProp("Artist or author"),
+				"tags":            map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "Manual tags"},
+				"project":         stringProp("Project ID whose defaults apply"),
+				"external_id":     stringProp("ID of the image in another system"),
+				"visibility":      map[string]interface{}{"type": "string", "enum": []string{"public", "private"}},
+				"idempotency_key": stringProp("Retrying with the same key returns the first upload instead of filing the image twice"),
+			}, "title", "artist"),
+		},
+		{
+			Name:        "list_categories",
+			Description: "List the categories images are filed under, with the number of images in each.",
+			InputSchema: objectSchema(map[string]interface{}{}),
+		},
+	}
+}
+
+// Call runs a tool and returns its text output
+func (t *Tools) Call(ctx context.Context, name string, arguments json.RawMessage) (string, error) {
+	if len(arguments) == 0 || string(arguments) == "null" {
+		arguments = json.RawMessage("{}")
+	}
+	switch name {
+	case "search_images":
+		return t.searchImages(ctx, arguments)
+	case "get_image":
+		return t.getImage(ctx, arguments)
+	case "upload_image":
+		return t.uploadImage(ctx, arguments)
+	case "list_categories":
+		return t.listCategories(ctx)
+	}
+	return "", errUnknownTool
+}
+
+func (t *Tools) searchImages(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if strings.TrimSpace(args.Query) == "" {
+		return "", errors.New("query is required")
+	}
+	if args.Limit <= 0 {
+		args.Limit = defaultSearchLimit
+	}
+	args.Limit = min(args.Limit, maxSearchLimit)
+
+	data, err := t.client.Search(ctx, args.Query, args.Limit)
+	if err != nil {
+		return "", err
+	}
+	return indent(data), nil
+}
+
+func (t *Tools) getImage(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if args.ID == "" {
+		return "", errors.New("id is required")
+	}
+
+	data, err := t.client.GetImage(ctx, args.ID)
+	if err != nil {
+		return "", err
+	}
+
+	// Paths are relative to /data/; agents need URLs they can open
+	var image map[string]interface{}
+	if err := json.Unmarshal(data, &image); err != nil {
+		return indent(data), nil
+	}
+	for field, urlField := range map[string]string{"file_path": "file_url", "thumbnail_path": "thumbnail_url", "model_file_path": "model_file_url"} {
+		if path, ok := image[field].(string); ok && path != "" {
+			image[urlField] = t.client.BaseURL() + "/data/" + path
+		}
+	}
+	out, _ := json.MarshalIndent(image, "", "  ")
+	return string(out), nil
+}
+
+func (t *Tools) uploadImage(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Path           string   `json:"path"`
+		Data           string   `json:"data"`
+		Filename       string   `json:"filename"`
+		Title          string   `json:"title"`
+		Artist         string   `json:"artist"`
+		Tags           []string `json:"tags"`
+		Project        string   `json:"project"`
+		ExternalID     string   `json:"external_id"`
+		Visibility     string   `json:"visibility"`
+		IdempotencyKey string   `json:"idempotency_key"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	upload := Upload{
+		Filename:       args.Filename,
+		Title:          args.Title,
+		Artist:         args.Artist,
+		Tags:           args.Tags,
+		Project:        args.Project,
+		ExternalID:     args.ExternalID,
+		Visibility:     args.Visibility,
+		IdempotencyKey: args.IdempotencyKey,
+	}
+	switch {
+	case args.Path != "" && args.Data != "":
+		return "", errors.New("give either path or data, not both")
+	case args.Path != "":
+		file, err := os.Open(args.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to open image: %w", err)
+		}
+		defer file.Close()
+		upload.Content = file
+		if upload.Filename == "" {
+			upload.Filename = filepath.Base(args.Path)
+		}
+	case args.Data != "":
+		content, err := base64.StdEncoding.DecodeString(args.Data)
+		if err != nil {
+			return "", fmt.Errorf("data is not valid base64: %w", err)
+		}
+		if upload.Filename == "" {
+			return "", errors.New("filename is required with data")
+		}
+		upload.Content = bytes.NewReader(content)
+	default:
+		return "", errors.New("path or data is required")
+	}
+
+	data, err := t.client.UploadImage(ctx, upload)
+	if err != nil {
+		return "", err
+	}
+	return indent(data), nil
+}
+
+// categoryCount is an entry of list_categories
+type categoryCount struct {
+	Category string `json:"category"`
+	Images   int    `json:"images"`
+}
+
+func (t *Tools) listCategories(ctx context.Context) (string, error) {
+	data, err := t.client.ListImages(ctx, "category")
+	if err != nil {
+		return "", err
+	}
+	var list struct {
+		Images []struct {
+			Category string `json:"category"`
+		} `json:"images"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return "", fmt.Errorf("unexpected image list: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, img := range list.Images {
+		if img.Category != "" {
+			counts[img.Category]++
+		}
+	}
+	categories := make([]categoryCount, 0, len(counts))
+	for category, n := range counts {
+		categories = append(categories, categoryCount{Category: category, Images: n})
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Images != categories[j].Images {
+			return categories[i].Images > categories[j].Images
+		}
+		return categories[i].Category < categories[j].Category
+	})
+
+	out, _ := json.MarshalIndent(map[string]interface{}{"categories": categories, "total_images": len(list.Images)}, "", "  ")
+	return string(out), nil
+}
+
+// objectSchema is the JSON schema of a tool's arguments
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringProp(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+// indent pretty-prints a JSON response for the agent
+func indent(data json.RawMessage) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return out.String()
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Aesthetics is the AI's rating of an image's appeal and composition, each
+// 1-10, with a short critique
+type Aesthetics struct {
+	Score       float64 `json:"score"`
+	Composition float64 `json:"composition"`
+	Critique    string  `json:"critique,omitempty"`
+}
+
+// String formats the ratings, without the critique
+func (a Aesthetics) String() string {
+	return fmt.Sprintf("%.1f (composition %.1f)", a.Score, a.Composition)
+}
+
+// ParseAesthetics parses ratings written by String
+func ParseAesthetics(s string) (*Aesthetics, error) {
+	var a Aesthetics
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%f (composition %f)", &a.Score, &a.Composition); err != nil {
+		return nil, fmt.Errorf("invalid aesthetic score: %s", s)
+	}
+	return &a, nil
+}
@@ -4,6 +4,7 @@ package models
 type AIAnalysis struct {
 	Type                   string              `json:"type"` // 2D or 3D
 	PrimaryCategory        string              `json:"primary_category"`
+	SubCategory            string              `json:"sub_category,omitempty"`
 	Description            string              `json:"description"`
 	Objects                []string            `json:"objects"`
 	Colors                 []string            `json:"colors"`
@@ -19,6 +20,25 @@ type AIAnalysis struct {
 	ThreeDCharacteristics  string              `json:"three_d_characteristics,omitempty"`
 	Symmetry               string              `json:"symmetry,omitempty"`
 	Complexity             string              `json:"complexity,omitempty"`
+	BestView               string              `json:"best_view,omitempty"` // view picked as the thumbnail
+
+	// Category-specific fields requested by the category prompts (e.g.
+	// brand and materials for products)
+	Extra                  map[string]string   `json:"extra,omitempty"`
+
+	// Aesthetic rating and critique, when aesthetic scoring is enabled
+	Aesthetics             *Aesthetics         `json:"aesthetics,omitempty"`
+
+	// Scene type, mood and style mapped to the controlled vocabulary, with
+	// labels in the caller's language; filled in by the API
+	Terms                  *AnalysisTerms      `json:"terms,omitempty"`
+
+	// What produced the analysis: AI provider, model and a version hash of
+	// the prompt, so analyses made with an old model or prompt can be found
+	// and re-run
+	Provider               string              `json:"provider,omitempty"`
+	Model                  string              `json:"model,omitempty"`
+	PromptVersion          string              `json:"prompt_version,omitempty"`
 
 	RawResponse            string              `json:"raw_response,omitempty"` // Full JSON from Gemini
 }
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Annotation shapes
+const (
+	AnnotationRect  = "rect"
+	AnnotationPoint = "point"
+)
+
+// Annotation is reviewer feedback pinned to a region of an image.
+// Coordinates are normalized to 0..1 of the image width and height, so they
+// hold for any rendition size.
+type Annotation struct {
+	ID        string    `json:"id"`
+	ImageID   string    `json:"image_id"`
+	Shape     string    `json:"shape"` // rect or point
+	X         float64   `json:"x"`
+	Y         float64   `json:"y"`
+	Width     float64   `json:"width,omitempty"`  // rect only
+	Height    float64   `json:"height,omitempty"` // rect only
+	Text      string    `json:"text"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks the shape, coordinates and text of an annotation
+func (a *Annotation) Validate() error {
+	if a.Text == "" {
+		return errors.New("text is required")
+	}
+
+	inRange := func(v float64) bool { return v >= 0 && v <= 1 }
+	if !inRange(a.X) || !inRange(a.Y) {
+		return errors.New("x and y must be between 0 and 1")
+	}
+
+	switch a.Shape {
+	case AnnotationPoint:
+		if a.Width != 0 || a.Height != 0 {
+			return errors.New("points have no width or height")
+		}
+	case AnnotationRect:
+		if a.Width <= 0 || a.Height <= 0 || a.X+a.Width > 1 || a.Y+a.Height > 1 {
+			return errors.New("rect must have a positive size within the image")
+		}
+	default:
+		return errors.New("shape must be rect or point")
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaxAttributes is the most custom attributes an image may have
+	MaxAttributes = 50
+	// MaxAttributeValueLength is the longest attribute value, in bytes
+	MaxAttributeValueLength = 500
+)
+
+// attributeNameRegex limits attribute names to lowercase identifiers such as
+// client_name, project-code or sku
+var attributeNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// NormalizeAttributes lowercases and trims attribute names and trims values.
+// Empty values are kept: in updates they remove the attribute.
+func NormalizeAttributes(attrs map[string]string) map[string]string {
+	if len(attrs) == 0 {
+		return nil
+	}
+	normalized := make(map[string]string, len(attrs))
+	for name, value := range attrs {
+		normalized[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(strings.ReplaceAll(value, "\n", " "))
+	}
+	return normalized
+}
+
+// ValidateAttributes checks attribute names, value lengths and count
+func ValidateAttributes(attrs map[string]string) error {
+	if len(attrs) > MaxAttributes {
+		return fmt.Errorf("too many attributes (max %d)", MaxAttributes)
+	}
+	for name, value := range attrs {
+		if !attributeNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid attribute name %q (use lowercase letters, digits, _ and -)", name)
+		}
+		if len(value) > MaxAttributeValueLength {
+			return fmt.Errorf("attribute %s is too long (max %d bytes)", name, MaxAttributeValueLength)
+		}
+	}
+	return nil
+}
+
+// MatchAttributes reports whether attrs has every wanted attribute. Values
+// match case-insensitively; an empty wanted value only requires the
+// attribute to be set.
+func MatchAttributes(attrs, want map[string]string) bool {
+	for name, value := range want {
+		have, ok := attrs[name]
+		if !ok || (value != "" && !strings.EqualFold(have, value)) {
+			return false
+		}
+	}
+	return true
+}
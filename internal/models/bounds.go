@@ -0,0 +1,145 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Bounds sources
+const (
+	BoundsSourceModel  = "model"
+	BoundsSourceManual = "manual"
+)
+
+// lengthUnits are the units real-world sizes are recorded in, with their
+// length in meters
+var lengthUnits = map[string]float64{
+	"mm": 0.001,
+	"cm": 0.01,
+	"m":  1,
+	"in": 0.0254,
+	"ft": 0.3048,
+}
+
+// lengthUnitAliases maps spelled-out unit names to their symbol
+var lengthUnitAliases = map[string]string{
+	"millimeter": "mm", "millimeters": "mm", "millimetre": "mm", "millimetres": "mm",
+	"centimeter": "cm", "centimeters": "cm", "centimetre": "cm", "centimetres": "cm",
+	"meter": "m", "meters": "m", "metre": "m", "metres": "m",
+	"inch": "in", "inches": "in", `"`: "in",
+	"foot": "ft", "feet": "ft", "'": "ft",
+}
+
+// NormalizeLengthUnit returns the symbol (mm, cm, m, in, ft) of a unit name,
+// case-insensitively
+func NormalizeLengthUnit(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if alias, ok := lengthUnitAliases[s]; ok {
+		s = alias
+	}
+	_, ok := lengthUnits[s]
+	return s, ok
+}
+
+// ParseLength parses a length such as "50cm", "1.2 m" or "18in" and returns
+// it in meters. A bare number is in meters.
+func ParseLength(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	split := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	number, unit := s, "m"
+	if split >= 0 {
+		number, unit = s[:split], s[split:]
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	symbol, ok := NormalizeLengthUnit(unit)
+	if err != nil || !ok || value < 0 {
+		return 0, fmt.Errorf("invalid length: %q (e.g. 50cm, 1.2m, 18in)", s)
+	}
+	return value * lengthUnits[symbol], nil
+}
+
+// Bounds is the real-world size of a 3D object's axis-aligned bounding box,
+// Y up: Width along X, Height along Y, Depth along Z
+type Bounds struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Depth  float64 `json:"depth"`
+	Unit   string  `json:"unit"`             // mm, cm, m, in or ft
+	Source string  `json:"source,omitempty"` // model or manual
+}
+
+// Validate checks that the unit is known and the sizes are usable
+func (b Bounds) Validate() error {
+	if _, ok := lengthUnits[b.Unit]; !ok {
+		return fmt.Errorf("unknown bounds unit %q (use mm, cm, m, in or ft)", b.Unit)
+	}
+	for _, v := range []float64{b.Width, b.Height, b.Depth} {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("bounds must be non-negative numbers")
+		}
+	}
+	if b.Width == 0 && b.Height == 0 && b.Depth == 0 {
+		return fmt.Errorf("bounds cannot all be zero")
+	}
+	return nil
+}
+
+// LongestSide returns the largest of the three sizes in meters
+func (b Bounds) LongestSide() float64 {
+	return math.Max(b.Width, math.Max(b.Height, b.Depth)) * lengthUnits[b.Unit]
+}
+
+// String formats the bounds as stored in the index
+func (b Bounds) String() string {
+	s := fmt.Sprintf("%s x %s x %s %s", formatLength(b.Width), formatLength(b.Height), formatLength(b.Depth), b.Unit)
+	if b.Source != "" {
+		s += " (" + b.Source + ")"
+	}
+	return s
+}
+
+// formatLength rounds a size to 4 decimals without trailing zeros
+func formatLength(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+}
+
+// ParseBounds parses bounds written by String, or entered by hand as
+// "120x80x45 cm"
+func ParseBounds(s string) (*Bounds, error) {
+	s = strings.TrimSpace(s)
+	var b Bounds
+	if open := strings.LastIndex(s, "("); open >= 0 && strings.HasSuffix(s, ")") {
+		b.Source = s[open+1 : len(s)-1]
+		s = strings.TrimSpace(s[:open])
+	}
+
+	// The unit follows the last digit
+	end := strings.LastIndexFunc(s, func(r rune) bool {
+		return unicode.IsDigit(r) || r == '.'
+	})
+	unit := strings.TrimSpace(s[end+1:])
+	symbol, ok := NormalizeLengthUnit(unit)
+	if !ok {
+		return nil, fmt.Errorf("invalid bounds: %q (e.g. 120x80x45 cm)", s)
+	}
+	b.Unit = symbol
+
+	sizes := strings.Split(strings.ToLower(strings.ReplaceAll(s[:end+1], " ", "")), "x")
+	if len(sizes) != 3 {
+		return nil, fmt.Errorf("invalid bounds: %q (e.g. 120x80x45 cm)", s)
+	}
+	for i, target := range []*float64{&b.Width, &b.Height, &b.Depth} {
+		v, err := strconv.ParseFloat(sizes[i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bounds: %q (e.g. 120x80x45 cm)", s)
+		}
+		*target = v
+	}
+	return &b, b.Validate()
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// DesignToolFigma is the design tool of frames exported from Figma
+const DesignToolFigma = "figma"
+
+// DesignSource links an image exported from a design tool back to the
+// document and frame (node) it was exported from
+type DesignSource struct {
+	Tool         string `json:"tool,omitempty"` // e.g. figma
+	DocumentID   string `json:"document_id"`    // Figma file key
+	DocumentName string `json:"document_name,omitempty"`
+	NodeID       string `json:"node_id,omitempty"` // frame within the document, e.g. 12:34
+	NodeName     string `json:"node_name,omitempty"`
+	Page         string `json:"page,omitempty"`
+	Version      string `json:"version,omitempty"` // document version the frame was exported at
+	URL          string `json:"url,omitempty"`     // deep link to the frame
+}
+
+// IsZero reports whether no source field is set
+func (d *DesignSource) IsZero() bool {
+	return d == nil || *d == DesignSource{}
+}
+
+// Normalize trims all fields, lowercases the tool and fills in the Figma
+// deep link when none was given
+func (d *DesignSource) Normalize() {
+	d.Tool = strings.ToLower(strings.TrimSpace(d.Tool))
+	d.DocumentID = strings.TrimSpace(d.DocumentID)
+	d.DocumentName = strings.TrimSpace(d.DocumentName)
+	d.NodeID = strings.TrimSpace(d.NodeID)
+	d.NodeName = strings.TrimSpace(d.NodeName)
+	d.Page = strings.TrimSpace(d.Page)
+	d.Version = strings.TrimSpace(d.Version)
+	d.URL = strings.TrimSpace(d.URL)
+
+	if d.URL == "" && d.Tool == DesignToolFigma && d.DocumentID != "" {
+		d.URL = FigmaURL(d.DocumentID, d.NodeID)
+	}
+}
+
+// Validate checks that the document is set and the deep link is a web URL
+func (d *DesignSource) Validate() error {
+	if d.DocumentID == "" {
+		return errors.New("document_id is required")
+	}
+	if d.URL != "" {
+		u, err := url.Parse(d.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("invalid url (use an http or https link)")
+		}
+	}
+	return nil
+}
+
+// FigmaURL returns the deep link to a node of a Figma file. Node IDs use
+// ":" in the API and "-" in links.
+func FigmaURL(fileKey, nodeID string) string {
+	link := "https://www.figma.com/design/" + url.PathEscape(fileKey)
+	if nodeID != "" {
+		link += "?node-id=" + url.QueryEscape(strings.ReplaceAll(nodeID, ":", "-"))
+	}
+	return link
+}
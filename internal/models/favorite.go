@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// Favorite is an image a user starred
+type Favorite struct {
+	ImageID   string    `json:"image_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GeoPoint is a WGS84 location in decimal degrees, as recorded by a camera's
+// GPS
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Validate checks that the point is a valid latitude and longitude
+func (p GeoPoint) Validate() error {
+	if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+		return fmt.Errorf("location out of range: %g,%g", p.Lat, p.Lon)
+	}
+	return nil
+}
+
+// String formats the point as stored in the index ("lat,lon")
+func (p GeoPoint) String() string {
+	return fmt.Sprintf("%.6f,%.6f", p.Lat, p.Lon)
+}
+
+// ParseGeoPoint parses a point written by String
+func ParseGeoPoint(s string) (*GeoPoint, error) {
+	var p GeoPoint
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%f,%f", &p.Lat, &p.Lon); err != nil {
+		return nil, fmt.Errorf("invalid location: %s", s)
+	}
+	return &p, p.Validate()
+}
+
+// BoundingBox is a map area. MinLon may be greater than MaxLon for boxes that
+// cross the antimeridian.
+type BoundingBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// ParseBoundingBox parses "minLon,minLat,maxLon,maxLat" (GeoJSON bbox order)
+func ParseBoundingBox(s string) (*BoundingBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bbox must be minLon,minLat,maxLon,maxLat")
+	}
+	var v [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bbox coordinate: %s", part)
+		}
+		v[i] = f
+	}
+
+	box := &BoundingBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	if err := (GeoPoint{Lat: box.MinLat, Lon: box.MinLon}).Validate(); err != nil {
+		return nil, err
+	}
+	if err := (GeoPoint{Lat: box.MaxLat, Lon: box.MaxLon}).Validate(); err != nil {
+		return nil, err
+	}
+	if box.MinLat > box.MaxLat {
+		return nil, fmt.Errorf("bbox minLat is greater than maxLat")
+	}
+	return box, nil
+}
+
+// Contains reports whether the point lies within the box (edges included)
+func (b BoundingBox) Contains(p GeoPoint) bool {
+	if p.Lat < b.MinLat || p.Lat > b.MaxLat {
+		return false
+	}
+	if b.MinLon <= b.MaxLon {
+		return p.Lon >= b.MinLon && p.Lon <= b.MaxLon
+	}
+	return p.Lon >= b.MinLon || p.Lon <= b.MaxLon
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Health check statuses
+const (
+	HealthOK      = "ok"
+	HealthWarning = "warning" // usable, but something is off
+	HealthError   = "error"   // broken or missing
+)
+
+// HealthCheck is the outcome of one check of an image's files or index entry
+type HealthCheck struct {
+	Name   string `json:"name"` // e.g. original, thumbnail, views, index
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Fix    string `json:"fix,omitempty"`   // rendition kind the auto-fix regenerates; empty if it can't be fixed automatically
+	Fixed  bool   `json:"fixed,omitempty"` // repaired by the auto-fix
+}
+
+// ImageHealth reports whether an image's original, derivatives and index
+// entry are intact
+type ImageHealth struct {
+	ImageID   string        `json:"image_id"`
+	Healthy   bool          `json:"healthy"` // no check has status error
+	Checks    []HealthCheck `json:"checks"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
 
 type ImageType string
 
@@ -17,28 +22,167 @@ type Image struct {
 	Type             ImageType `json:"type"`
 	UploadedAt       time.Time `json:"uploaded_at"`
 	ProcessedAt      *time.Time `json:"processed_at,omitempty"`
-	Status           string    `json:"status"` // pending, processing, completed, error
+	Status           string    `json:"status"` // pending, processing, completed, error, cancelled, interrupted
+	StatusReason     string    `json:"status_reason,omitempty"` // why processing stopped, for interrupted uploads
+	Timeline         []StageTiming `json:"timeline,omitempty"` // duration of each processing stage, in order
+	ExternalID       string    `json:"external_id,omitempty"` // caller's record ID (e.g. DAM/PIM), unique
+	Revision         int       `json:"revision,omitempty"`    // incremented on every metadata update
+	Project          string    `json:"project,omitempty"`     // project ID, see Project
+	Visibility       string    `json:"visibility,omitempty"`  // public (default) or private
 
 	// For 2D images
-	OriginalFilename string `json:"original_filename,omitempty"`
-	FilePath         string `json:"file_path,omitempty"`
-	ThumbnailPath    string `json:"thumbnail_path,omitempty"`
-	MimeType         string `json:"mime_type,omitempty"`
-	FileSize         int64  `json:"file_size,omitempty"`
-	Width            int    `json:"width,omitempty"`
-	Height           int    `json:"height,omitempty"`
+	OriginalFilename string      `json:"original_filename,omitempty"`
+	FilePath         string      `json:"file_path,omitempty"`
+	ThumbnailPath    string      `json:"thumbnail_path,omitempty"`
+	MimeType         string      `json:"mime_type,omitempty"`
+	FileSize         int64       `json:"file_size,omitempty"`
+	Width            int         `json:"width,omitempty"`
+	Height           int         `json:"height,omitempty"`
+	Megapixels       float64     `json:"megapixels,omitempty"`
+	AspectRatio      float64     `json:"aspect_ratio,omitempty"`     // width / height
+	SquareThumbnail  string      `json:"square_thumbnail,omitempty"` // subject-centered square crop
+	FocalPoint       *FocalPoint `json:"focal_point,omitempty"`
+	ColorSpace       string      `json:"color_space,omitempty"`      // from the embedded ICC profile; empty means untagged (sRGB)
+	Quality          *Quality    `json:"quality,omitempty"`          // technical quality measured at ingest
+	VisualHash       string      `json:"visual_hash,omitempty"`      // difference hash, for series detection
+	Location         *GeoPoint   `json:"location,omitempty"`         // from EXIF GPS
+	CapturedAt       *time.Time  `json:"captured_at,omitempty"`      // from EXIF DateTimeOriginal
 
 	// For 3D objects
 	FolderPath       string            `json:"folder_path,omitempty"`
 	ModelFilePath    string            `json:"model_file_path,omitempty"`    // Path to the 3D model file (.obj, .glb, .fbx, etc.)
 	ModelFilename    string            `json:"model_filename,omitempty"`     // Original filename of the 3D model
+	Bounds           *Bounds           `json:"bounds,omitempty"`             // real-world size, from the model file or entered by hand
+	Triangles        int               `json:"triangles,omitempty"`          // triangle count, from the model file or entered by hand
+	LODTags          []string          `json:"lod_tags,omitempty"`           // level-of-detail labels, e.g. lod0, game-ready
+	Materials        *MaterialSummary  `json:"materials,omitempty"`          // materials and textures read from the model file
+	ModelInfo        *ModelInfo        `json:"model_info,omitempty"`         // format and version of the model file
 	Views            map[string]string `json:"views,omitempty"`              // view name -> file path
+	PosterView       string            `json:"poster_view,omitempty"`        // view shown as the thumbnail in listings
+	TurntablePath    string            `json:"turntable_path,omitempty"`     // animated GIF of the horizontal views
+	ClipPath         string            `json:"clip_path,omitempty"`          // turntable video the views were extracted from
 	TotalFileSize    int64             `json:"total_file_size,omitempty"`
 
 	// Common fields
 	Category         string   `json:"category"`
+	StorageLayout    string   `json:"storage_layout,omitempty"` // layout the files were stored under: category, date, artist, flat-hash or cas
 	ManualTags       []string `json:"manual_tags,omitempty"`
+	License          *License `json:"license,omitempty"`
+	Attributes       map[string]string `json:"attributes,omitempty"` // custom fields (client, project code, SKU, ...)
+	DesignSource     *DesignSource `json:"design_source,omitempty"` // design tool document and frame it was exported from
 	AIAnalysis       *AIAnalysis `json:"ai_analysis,omitempty"`
+	Renditions       []Rendition `json:"renditions,omitempty"` // derivative files, filled in by the API
+	Links            *ImageLinks `json:"links,omitempty"`      // fully qualified URLs, filled in by the API
+}
+
+// SetDimensions records the pixel size and the megapixels and aspect ratio
+// derived from it. 3D objects use the size of their front view.
+func (img *Image) SetDimensions(width, height int) {
+	img.Width = width
+	img.Height = height
+	img.Megapixels, img.AspectRatio = DimensionStats(width, height)
+}
+
+// DimensionStats returns the megapixels and aspect ratio (width / height) of
+// a pixel size, rounded to the precision stored in the index
+func DimensionStats(width, height int) (megapixels, aspectRatio float64) {
+	if width <= 0 || height <= 0 {
+		return 0, 0
+	}
+	megapixels = math.Round(float64(width)*float64(height)/1e4) / 100
+	aspectRatio = math.Round(float64(width)/float64(height)*1e4) / 1e4
+	return megapixels, aspectRatio
+}
+
+// Focal point sources
+const (
+	FocalSourceSaliency = "saliency"
+	FocalSourceManual   = "manual"
+)
+
+// FocalPoint is the center of an image's subject, as fractions (0..1) of its
+// width and height; square crops are centered on it
+type FocalPoint struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Source string  `json:"source,omitempty"` // saliency or manual
+}
+
+// Validate checks that the point lies within the image
+func (f FocalPoint) Validate() error {
+	if f.X < 0 || f.X > 1 || f.Y < 0 || f.Y > 1 {
+		return fmt.Errorf("focal point must be within 0..1, got %g,%g", f.X, f.Y)
+	}
+	return nil
+}
+
+// String formats the focal point as stored in the index
+func (f FocalPoint) String() string {
+	s := fmt.Sprintf("%.3f,%.3f", f.X, f.Y)
+	if f.Source != "" {
+		s += " (" + f.Source + ")"
+	}
+	return s
+}
+
+// ParseFocalPoint parses a focal point written by String
+func ParseFocalPoint(s string) (*FocalPoint, error) {
+	var f FocalPoint
+	coords, source, _ := strings.Cut(strings.TrimSpace(s), " ")
+	if _, err := fmt.Sscanf(coords, "%f,%f", &f.X, &f.Y); err != nil {
+		return nil, fmt.Errorf("invalid focal point: %s", s)
+	}
+	f.Source = strings.Trim(source, "()")
+	return &f, f.Validate()
+}
+
+// Image visibility values
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// IsValidVisibility reports whether v is a known visibility value
+func IsValidVisibility(v string) bool {
+	return v == VisibilityPublic || v == VisibilityPrivate
+}
+
+// JobPriority controls the order in which queued jobs are processed
+type JobPriority int
+
+const (
+	PriorityLow    JobPriority = 0 // bulk ingests
+	PriorityNormal JobPriority = 1
+	PriorityHigh   JobPriority = 2 // interactive uploads
+)
+
+// ParseJobPriority parses a priority name ("low", "normal", "high").
+// An empty string yields PriorityNormal.
+func ParseJobPriority(s string) (JobPriority, error) {
+	switch s {
+	case "":
+		return PriorityNormal, nil
+	case "low":
+		return PriorityLow, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	default:
+		return PriorityNormal, fmt.Errorf("invalid priority: %s", s)
+	}
+}
+
+// String returns the priority name
+func (p JobPriority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
 }
 
 // UploadJob represents a job for the background worker
@@ -46,10 +190,32 @@ type UploadJob struct {
 	ImageID        string
 	Type           ImageType
 	FilePath       string            // For 2D
+	OriginalFilename string          // For 2D (client's filename, sent back on download)
 	FilePaths      map[string]string // For 3D (view -> path)
 	ModelFilePath  string            // For 3D (the actual 3D model file)
 	ModelFilename  string            // For 3D (original model filename)
+	Bounds         *Bounds           // For 3D entered at upload; otherwise measured from the model file
+	Triangles      int               // For 3D entered at upload; otherwise counted from the model file
+	LODTags        []string          // For 3D
+	ClipPath       string            // For 3D uploaded as a turntable clip; views are extracted from it
+	ClipFrames     int               // For 3D clips (number of frames to extract)
 	Title          string
 	Artist         string
 	ManualTags     []string
+	Priority       JobPriority
+	ExternalID     string
+	License        *License
+	Attributes     map[string]string
+	DesignSource   *DesignSource
+	Project        string
+	Visibility     string
+	Categories     []string          // allowed primary categories (from the project); the first is the fallback
+	QueuedAt       time.Time         // set by QueueJob
+	Timeline       []StageTiming     // stages done so far, starting with the handler's save
+}
+
+// RecordStage appends the timing of a stage that started at start and has
+// just ended to the job's timeline
+func (j *UploadJob) RecordStage(stage string, start time.Time) {
+	j.Timeline = append(j.Timeline, TimeStage(stage, start))
 }
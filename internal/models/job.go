@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Job states reported by the jobs API
+const (
+	JobStateQueued    = "queued"
+	JobStateRunning   = "running"
+	JobStateCompleted = "completed"
+	JobStateError     = "error"
+	JobStateCancelled = "cancelled"
+)
+
+// Job kinds reported by the jobs API
+const (
+	JobKindUpload         = "upload"
+	JobKindBackfill       = "backfill"
+	JobKindBulkEdit       = "bulk_update"
+	JobKindBulkDelete     = "bulk_delete"
+	JobKindConsolidate    = "consolidate"
+	JobKindXMPExport      = "xmp_export"
+	JobKindMetadataImport = "metadata_import"
+	JobKindErasure        = "erasure"
+)
+
+// JobProgress reports how far a long-running background job has got
+type JobProgress struct {
+	Total   int `json:"total"`
+	Done    int `json:"done"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// JobInfo describes a processing job for observability
+type JobInfo struct {
+	ID         string       `json:"id"`
+	Kind       string       `json:"kind"`
+	Type       ImageType    `json:"type,omitempty"`
+	Title      string       `json:"title"`
+	Priority   string       `json:"priority"`
+	State      string       `json:"state"`
+	Error      string       `json:"error,omitempty"`
+	QueuedAt   time.Time    `json:"queued_at"`
+	StartedAt  *time.Time   `json:"started_at,omitempty"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	WaitMs     int64        `json:"wait_ms"`               // time spent queued
+	DurationMs int64        `json:"duration_ms,omitempty"` // processing time once started
+	Progress   *JobProgress `json:"progress,omitempty"`    // background jobs only
+}
+
+// Processing stages recorded in an upload's timeline
+const (
+	StageSave      = "save"      // files written to temp by the upload handler
+	StageQueue     = "queue"     // waiting for a worker
+	StageFrames    = "frames"    // frames extracted from a turntable clip (3D)
+	StageThumbnail = "thumbnail" // thumbnails, dimensions and file sizes
+	StageModel     = "model"     // bounds, triangles, materials and format read from the model file (3D)
+	StageAnalysis  = "analysis"  // Gemini analysis
+	StageMove      = "move"      // move to the category folder
+	StageIndex     = "index"     // index append
+)
+
+// StageTiming is how long one processing stage of an upload took
+type StageTiming struct {
+	Stage      string    `json:"stage"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// TimeStage returns the timing of a stage that started at start and has
+// just ended
+func TimeStage(stage string, start time.Time) StageTiming {
+	return StageTiming{Stage: stage, StartedAt: start, DurationMs: time.Since(start).Milliseconds()}
+}
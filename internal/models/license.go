@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LicenseDateFormat is the layout of license expiry dates
+const LicenseDateFormat = "2006-01-02"
+
+// LicenseExpiryWarning is how far ahead an upcoming expiry is reported
+const LicenseExpiryWarning = 30 * 24 * time.Hour
+
+// License describes the usage rights of an image
+type License struct {
+	Type         string `json:"type,omitempty"` // e.g. CC-BY-4.0, royalty-free, editorial
+	RightsHolder string `json:"rights_holder,omitempty"`
+	ExpiresOn    string `json:"expires_on,omitempty"` // YYYY-MM-DD, empty for perpetual
+	Restrictions string `json:"restrictions,omitempty"`
+}
+
+// IsZero reports whether no license field is set
+func (l *License) IsZero() bool {
+	return l == nil || *l == License{}
+}
+
+// Normalize trims all fields
+func (l *License) Normalize() {
+	l.Type = strings.TrimSpace(l.Type)
+	l.RightsHolder = strings.TrimSpace(l.RightsHolder)
+	l.ExpiresOn = strings.TrimSpace(l.ExpiresOn)
+	l.Restrictions = strings.TrimSpace(l.Restrictions)
+}
+
+// Validate checks the expiry date format
+func (l *License) Validate() error {
+	if l.ExpiresOn == "" {
+		return nil
+	}
+	if _, err := time.Parse(LicenseDateFormat, l.ExpiresOn); err != nil {
+		return fmt.Errorf("invalid license expiry date %q (use YYYY-MM-DD)", l.ExpiresOn)
+	}
+	return nil
+}
+
+// expiry returns the first instant the license is no longer valid; the
+// expiry date itself is still covered
+func (l *License) expiry() (time.Time, bool) {
+	if l == nil || l.ExpiresOn == "" {
+		return time.Time{}, false
+	}
+	date, err := time.ParseInLocation(LicenseDateFormat, l.ExpiresOn, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date.AddDate(0, 0, 1), true
+}
+
+// Expired reports whether the license has expired at now
+func (l *License) Expired(now time.Time) bool {
+	end, ok := l.expiry()
+	return ok && !now.Before(end)
+}
+
+// Warnings returns human-readable notes about an expired or soon expiring
+// license and any usage restrictions
+func (l *License) Warnings(now time.Time) []string {
+	if l.IsZero() {
+		return nil
+	}
+
+	var warnings []string
+	if end, ok := l.expiry(); ok {
+		switch {
+		case !now.Before(end):
+			warnings = append(warnings, "license expired on "+l.ExpiresOn)
+		case end.Sub(now) <= LicenseExpiryWarning:
+			warnings = append(warnings, "license expires on "+l.ExpiresOn)
+		}
+	}
+	if l.Restrictions != "" {
+		warnings = append(warnings, "usage restricted: "+l.Restrictions)
+	}
+	return warnings
+}
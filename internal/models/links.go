@@ -0,0 +1,11 @@
+package models
+
+// ImageLinks are the fully qualified URLs of an image, built from
+// PUBLIC_BASE_URL, so clients don't assemble them from file paths
+type ImageLinks struct {
+	Self      string `json:"self"`                // the image in the API
+	File      string `json:"file,omitempty"`      // original file; for 3D objects the model
+	Thumbnail string `json:"thumbnail,omitempty"` // for 3D objects the poster view's
+	Detail    string `json:"detail"`              // the image in the web UI
+	Share     string `json:"share"`               // short link that previews in chat and mail
+}
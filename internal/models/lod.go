@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxLODTags is the most LOD tags a 3D object may have
+const MaxLODTags = 10
+
+// lodTagRegex limits LOD tags to short lowercase labels such as lod0,
+// game-ready or hero
+var lodTagRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// NormalizeLODTags lowercases and trims LOD tags, replacing spaces and
+// underscores with hyphens ("Game Ready" -> "game-ready"), and drops
+// duplicates and empty tags
+func NormalizeLODTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = NormalizeLODTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// NormalizeLODTag normalizes a single LOD tag, e.g. for filtering
+func NormalizeLODTag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	return strings.NewReplacer(" ", "-", "_", "-").Replace(tag)
+}
+
+// ValidateLODTags checks tag names and count
+func ValidateLODTags(tags []string) error {
+	if len(tags) > MaxLODTags {
+		return fmt.Errorf("too many LOD tags (max %d)", MaxLODTags)
+	}
+	for _, tag := range tags {
+		if !lodTagRegex.MatchString(tag) {
+			return fmt.Errorf("invalid LOD tag %q (use lowercase letters, digits and -, e.g. lod0 or game-ready)", tag)
+		}
+	}
+	return nil
+}
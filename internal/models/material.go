@@ -0,0 +1,204 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Material shading models
+const (
+	ShadingPBR   = "pbr"
+	ShadingPhong = "phong"
+	ShadingUnlit = "unlit"
+)
+
+// TextureSize is the pixel size of a texture; zero when it could not be
+// read (missing file or an unsupported format such as KTX2)
+type TextureSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Longest returns the longer side in pixels
+func (t TextureSize) Longest() int {
+	return max(t.Width, t.Height)
+}
+
+// Label names the resolution class of the texture: "2K" for 2048, "1K" for
+// 1024, otherwise the longest side in pixels
+func (t TextureSize) Label() string {
+	longest := t.Longest()
+	switch {
+	case longest == 0:
+		return "unknown"
+	case longest >= 1024 && longest%1024 == 0:
+		return fmt.Sprintf("%dK", longest/1024)
+	default:
+		return fmt.Sprintf("%dpx", longest)
+	}
+}
+
+func (t TextureSize) String() string {
+	if t.Longest() == 0 {
+		return "?"
+	}
+	return fmt.Sprintf("%dx%d", t.Width, t.Height)
+}
+
+// MaterialSummary describes the materials and textures of a 3D model
+type MaterialSummary struct {
+	Materials      int           `json:"materials"`
+	Shading        []string      `json:"shading,omitempty"`          // shading models in use: pbr, phong, unlit
+	Textures       []TextureSize `json:"textures,omitempty"`         // largest first
+	MaxTextureSize int           `json:"max_texture_size,omitempty"` // longest side of the largest texture, in pixels
+	Summary        string        `json:"summary"`                    // e.g. "4x 2K PBR textures"
+}
+
+// NewMaterialSummary builds a summary from the material count, the shading
+// model of each material and the texture sizes
+func NewMaterialSummary(materials int, shading []string, textures []TextureSize) *MaterialSummary {
+	m := &MaterialSummary{Materials: materials, Textures: textures}
+
+	seen := make(map[string]bool)
+	for _, s := range shading {
+		if !seen[s] {
+			seen[s] = true
+			m.Shading = append(m.Shading, s)
+		}
+	}
+	sort.Strings(m.Shading)
+
+	sort.SliceStable(m.Textures, func(i, j int) bool {
+		return m.Textures[i].Longest() > m.Textures[j].Longest()
+	})
+	if len(m.Textures) > 0 {
+		m.MaxTextureSize = m.Textures[0].Longest()
+	}
+	m.Summary = m.describe()
+	return m
+}
+
+// describe summarizes the textures by resolution class, e.g.
+// "4x 2K PBR textures" or "2x 4K, 1x 512px Phong textures"
+func (m *MaterialSummary) describe() string {
+	shading := make([]string, len(m.Shading))
+	for i, s := range m.Shading {
+		shading[i] = shadingLabel(s)
+	}
+	kind := strings.Join(shading, "/")
+
+	if len(m.Textures) == 0 {
+		noun := "materials"
+		if m.Materials == 1 {
+			noun = "material"
+		}
+		return strings.Join(strings.Fields(fmt.Sprintf("%d %s %s, untextured", m.Materials, kind, noun)), " ")
+	}
+
+	var groups []string
+	counts := make(map[string]int)
+	for _, t := range m.Textures {
+		label := t.Label()
+		if counts[label] == 0 {
+			groups = append(groups, label)
+		}
+		counts[label]++
+	}
+	parts := make([]string, len(groups))
+	for i, label := range groups {
+		parts[i] = fmt.Sprintf("%dx %s", counts[label], label)
+	}
+
+	noun := "textures"
+	if len(m.Textures) == 1 {
+		noun = "texture"
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, ", ")+" "+kind+" "+noun), " ")
+}
+
+func shadingLabel(s string) string {
+	switch s {
+	case ShadingPBR:
+		return "PBR"
+	case ShadingPhong:
+		return "Phong"
+	case ShadingUnlit:
+		return "unlit"
+	default:
+		return s
+	}
+}
+
+// MaterialsField formats the material count and shading as stored in the
+// index, e.g. "3 (pbr, unlit)"
+func (m *MaterialSummary) MaterialsField() string {
+	if len(m.Shading) == 0 {
+		return strconv.Itoa(m.Materials)
+	}
+	return fmt.Sprintf("%d (%s)", m.Materials, strings.Join(m.Shading, ", "))
+}
+
+// TexturesField formats the texture sizes as stored in the index, e.g.
+// "2048x2048, 1024x1024"
+func (m *MaterialSummary) TexturesField() string {
+	sizes := make([]string, len(m.Textures))
+	for i, t := range m.Textures {
+		sizes[i] = t.String()
+	}
+	return strings.Join(sizes, ", ")
+}
+
+// ParseMaterialSummary parses the index fields written by MaterialsField and
+// TexturesField
+func ParseMaterialSummary(materials, textures string) (*MaterialSummary, error) {
+	countText, shadingText, _ := strings.Cut(strings.TrimSpace(materials), " ")
+	count, err := strconv.Atoi(countText)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid materials: %q", materials)
+	}
+	var shading []string
+	if shadingText = strings.Trim(strings.TrimSpace(shadingText), "()"); shadingText != "" {
+		shading = strings.Split(shadingText, ", ")
+	}
+
+	var sizes []TextureSize
+	if textures = strings.TrimSpace(textures); textures != "" {
+		for _, field := range strings.Split(textures, ", ") {
+			var size TextureSize
+			if field != "?" {
+				w, h, ok := strings.Cut(field, "x")
+				size.Width, err = strconv.Atoi(w)
+				if err == nil {
+					size.Height, err = strconv.Atoi(h)
+				}
+				if !ok || err != nil {
+					return nil, fmt.Errorf("invalid textures: %q", textures)
+				}
+			}
+			sizes = append(sizes, size)
+		}
+	}
+	return NewMaterialSummary(count, shading, sizes), nil
+}
+
+// ParseTextureResolution parses a texture resolution given as a class such
+// as "2K" or in pixels ("2048"). An empty string yields zero (no filter).
+func ParseTextureResolution(s string) (int, error) {
+	number := strings.ToUpper(strings.TrimSpace(s))
+	if number == "" {
+		return 0, nil
+	}
+	multiplier := 1
+	if k, ok := strings.CutSuffix(number, "K"); ok {
+		number, multiplier = k, 1024
+	} else {
+		number = strings.TrimSuffix(number, "PX")
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid texture resolution: %s (e.g. 2K or 2048)", s)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,9 @@
+package models
+
+// ModelInfo describes the file format of a 3D model, as far as its header
+// tells
+type ModelInfo struct {
+	Format  string `json:"format"`            // file extension without the dot, e.g. usdz, blend
+	Version string `json:"version,omitempty"` // format version (USD, glTF) or Blender version that saved it
+	UpAxis  string `json:"up_axis,omitempty"` // Y or Z, when the file declares it
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// downloadWeight is how many views a download counts for in the popularity
+// score
+const downloadWeight = 3
+
+// Popularity counts how often an image's files were served
+type Popularity struct {
+	Views        int        `json:"views"`
+	Downloads    int        `json:"downloads"`
+	LastViewed   *time.Time `json:"last_viewed,omitempty"`
+	LastAccessed *time.Time `json:"last_accessed,omitempty"` // last view or download
+}
+
+// Score ranks images for sort=popular; a download weighs more than a view
+func (p Popularity) Score() int {
+	return p.Views + downloadWeight*p.Downloads
+}
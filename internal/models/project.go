@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// projectIDRegex limits project IDs to URL- and index-safe slugs such as
+// acme or acme-spring-2025
+var projectIDRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Project groups images of one client or campaign. Uploads into a project
+// get its defaults; list and search can be scoped to it.
+type Project struct {
+	ID                string    `json:"id"`
+	Name              string    `json:"name"`
+	Description       string    `json:"description,omitempty"`
+	DefaultTags       []string  `json:"default_tags,omitempty"`       // added to every upload
+	DefaultVisibility string    `json:"default_visibility,omitempty"` // public or private
+	Categories        []string  `json:"categories,omitempty"`         // allowed primary categories; the first is the fallback
+	LegalHold         bool      `json:"legal_hold,omitempty"`         // blocks deleting the project's images; set by admins only
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Normalize trims the project fields and lowercases the ID and categories
+func (p *Project) Normalize() {
+	p.ID = strings.ToLower(strings.TrimSpace(p.ID))
+	p.Name = strings.TrimSpace(p.Name)
+	p.Description = strings.TrimSpace(p.Description)
+	p.DefaultVisibility = strings.ToLower(strings.TrimSpace(p.DefaultVisibility))
+
+	tags := p.DefaultTags[:0]
+	for _, tag := range p.DefaultTags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	p.DefaultTags = tags
+
+	categories := p.Categories[:0]
+	for _, category := range p.Categories {
+		if category = strings.ToLower(strings.TrimSpace(category)); category != "" {
+			categories = append(categories, category)
+		}
+	}
+	p.Categories = categories
+}
+
+// Validate checks the ID, name and default visibility of a project
+func (p *Project) Validate() error {
+	if !projectIDRegex.MatchString(p.ID) {
+		return fmt.Errorf("invalid project id %q (use lowercase letters, digits, _ and -)", p.ID)
+	}
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if p.DefaultVisibility != "" && !IsValidVisibility(p.DefaultVisibility) {
+		return errors.New("default_visibility must be public or private")
+	}
+	return nil
+}
+
+// WithDefaultTags returns tags followed by the project's default tags that
+// are not already among them (case-insensitive)
+func (p *Project) WithDefaultTags(tags []string) []string {
+	merged := append([]string{}, tags...)
+	for _, tag := range p.DefaultTags {
+		found := false
+		for _, existing := range merged {
+			if strings.EqualFold(existing, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Quality holds technical quality metrics measured at ingest. The metrics
+// are 0-1; Score combines them into 0-100.
+type Quality struct {
+	Score      int     `json:"score"`
+	Sharpness  float64 `json:"sharpness"`  // 1 = crisp edges, 0 = blurred
+	Exposure   float64 `json:"exposure"`   // 1 = mid-toned without clipping
+	Noise      float64 `json:"noise"`      // estimated sensor noise, 0 = clean
+	Resolution float64 `json:"resolution"` // by megapixels, ~0.75 at 12 MP
+}
+
+func (q Quality) String() string {
+	return fmt.Sprintf("%d (sharpness %.2f, exposure %.2f, noise %.2f, resolution %.2f)",
+		q.Score, q.Sharpness, q.Exposure, q.Noise, q.Resolution)
+}
+
+// ParseQuality parses quality metrics written by String
+func ParseQuality(s string) (*Quality, error) {
+	var q Quality
+	_, err := fmt.Sscanf(strings.TrimSpace(s), "%d (sharpness %f, exposure %f, noise %f, resolution %f)",
+		&q.Score, &q.Sharpness, &q.Exposure, &q.Noise, &q.Resolution)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quality: %s", s)
+	}
+	return &q, nil
+}
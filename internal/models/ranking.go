@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RankingWeights weigh the signals a search result's relevance score is
+// combined from. Each signal is between 0 and 1; the score is their weighted
+// average, so only the ratios between weights matter.
+type RankingWeights struct {
+	Relevance   float64 `json:"relevance"`   // the AI's relevance score
+	Title       float64 `json:"title"`       // share of query terms in the title
+	Tags        float64 `json:"tags"`        // share of query terms in a tag
+	Description float64 `json:"description"` // share of query terms in the description
+	Embedding   float64 `json:"embedding"`   // similarity of the query and image embeddings
+	Recency     float64 `json:"recency"`     // halves every 30 days after upload
+}
+
+// DefaultRankingWeights rank by the AI's relevance score alone
+func DefaultRankingWeights() RankingWeights {
+	return RankingWeights{Relevance: 1}
+}
+
+// IsDefault reports whether the weights rank by the AI's relevance alone
+func (w RankingWeights) IsDefault() bool {
+	return w.Title == 0 && w.Tags == 0 && w.Description == 0 && w.Embedding == 0 && w.Recency == 0
+}
+
+// Validate checks that no weight is negative and at least one is positive
+func (w RankingWeights) Validate() error {
+	names := []string{"relevance", "title", "tags", "description", "embedding", "recency"}
+	total := 0.0
+	for i, weight := range []float64{w.Relevance, w.Title, w.Tags, w.Description, w.Embedding, w.Recency} {
+		if weight < 0 {
+			return fmt.Errorf("%s weight cannot be negative", names[i])
+		}
+		total += weight
+	}
+	if total == 0 {
+		return errors.New("at least one ranking weight must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Rendition kinds. 3D objects have a thumbnail per view, e.g. thumbnail-front.
+const (
+	RenditionThumbnail       = "thumbnail"
+	RenditionSquareThumbnail = "square-thumbnail"
+	RenditionCutout          = "cutout"
+	RenditionUpscale2x       = "upscale-2x"
+	RenditionUpscale4x       = "upscale-4x"
+	RenditionTurntable       = "turntable"
+)
+
+// Rendition is a derivative file generated from an image
+type Rendition struct {
+	Kind      string    `json:"kind"`
+	Path      string    `json:"path"` // data-relative
+	URL       string    `json:"url"`
+	Width     int       `json:"width,omitempty"`
+	Height    int       `json:"height,omitempty"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ViewThumbnailKind returns the rendition kind of a 3D view's thumbnail
+func ViewThumbnailKind(view string) string {
+	return RenditionThumbnail + "-" + view
+}
+
+// ViewOfThumbnailKind returns the view of a view thumbnail kind
+func ViewOfThumbnailKind(kind string) (string, bool) {
+	return strings.CutPrefix(kind, RenditionThumbnail+"-")
+}
@@ -0,0 +1,42 @@
+package models
+
+import "fmt"
+
+// Role is the access level of an API caller
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // read only
+	RoleEditor   Role = "editor"   // upload and edit metadata
+	RoleReviewer Role = "reviewer" // editor + approve/reject
+	RoleAdmin    Role = "admin"    // everything, including admin endpoints
+)
+
+var roleRank = map[Role]int{
+	RoleViewer:   0,
+	RoleEditor:   1,
+	RoleReviewer: 2,
+	RoleAdmin:    3,
+}
+
+// ParseRole parses a role name
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := roleRank[role]; !ok {
+		return "", fmt.Errorf("invalid role: %q", s)
+	}
+	return role, nil
+}
+
+// AtLeast reports whether r grants at least the access of min
+func (r Role) AtLeast(min Role) bool {
+	rank, ok := roleRank[r]
+	return ok && rank >= roleRank[min]
+}
+
+// Principal is the caller of a request
+type Principal struct {
+	Name          string `json:"name"`
+	Role          Role   `json:"role"`
+	Authenticated bool   `json:"authenticated"` // false for anonymous callers and when auth is disabled
+}
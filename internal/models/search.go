@@ -5,19 +5,114 @@ type SearchRequest struct {
 	Query  string `json:"query"`
 	Limit  int    `json:"limit"`
 	Offset int    `json:"offset"`
+
+	// Optional filters
+	Category               string `json:"category,omitempty"` // category path; a primary category includes its sub categories
+	Workflow               string `json:"workflow,omitempty"`
+	License                string `json:"license,omitempty"` // license type
+	ExcludeExpiredLicenses bool   `json:"exclude_expired_licenses,omitempty"`
+	Project                string `json:"project,omitempty"` // project ID
+
+	// Custom attributes every result must have; an empty value only requires
+	// the attribute to be set
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	// Dimension filters; aspect_ratio accepts "16:9" or a decimal
+	MinWidth        int     `json:"min_width,omitempty"`
+	MinHeight       int     `json:"min_height,omitempty"`
+	MaxWidth        int     `json:"max_width,omitempty"`
+	MaxHeight       int     `json:"max_height,omitempty"`
+	MinMegapixels   float64 `json:"min_megapixels,omitempty"`
+	AspectRatio     string  `json:"aspect_ratio,omitempty"`
+	AspectTolerance float64 `json:"aspect_tolerance,omitempty"`
+
+	// Real-world size of 3D objects (longest side), e.g. "50cm" or "1.2m"
+	MinSize string `json:"min_size,omitempty"`
+	MaxSize string `json:"max_size,omitempty"`
+
+	// Polygon budget of 3D objects: triangle count and LOD tag (e.g. "game-ready")
+	MinTris int    `json:"min_tris,omitempty"`
+	MaxTris int    `json:"max_tris,omitempty"`
+	LOD     string `json:"lod,omitempty"`
+
+	// Materials of 3D objects: largest texture ("2K" or pixels) and shading model
+	MinTexture string `json:"min_texture,omitempty"`
+	Shading    string `json:"shading,omitempty"`
+
+	// Minimum confidence (0-1) of an AI feature matching the query, or the
+	// named feature
+	MinConfidence float64 `json:"min_confidence,omitempty"`
+	Feature       string  `json:"feature,omitempty"`
+
+	// Ranking weights for this search; the configured ones when omitted
+	Weights *RankingWeights `json:"weights,omitempty"`
 }
 
 // SearchResult represents a single search result with relevance score
 type SearchResult struct {
-	ImageID        string  `json:"image_id"`
-	RelevanceScore float64 `json:"relevance_score"`
-	Reason         string  `json:"reason,omitempty"`
-	Image          *Image  `json:"image,omitempty"`
+	ImageID        string        `json:"image_id"`
+	RelevanceScore float64       `json:"relevance_score"`
+	Reason         string        `json:"reason,omitempty"`
+	Warnings       []string      `json:"warnings,omitempty"` // e.g. expired or restricted license
+	Matches        []SearchMatch `json:"matches,omitempty"`  // indexed fields that contain query terms
+	Image          *Image        `json:"image,omitempty"`
+}
+
+// Search match fields
+const (
+	MatchFieldTitle       = "title"
+	MatchFieldDescription = "description"
+	MatchFieldTags        = "tags"
+	MatchFieldObjects     = "objects"
+)
+
+// SearchMatch is a field value of a result that contains query terms: one
+// tag or object, the title, or an excerpt of the description
+type SearchMatch struct {
+	Field      string      `json:"field"`
+	Value      string      `json:"value"`
+	Highlights []Highlight `json:"highlights"`
+	Excerpt    bool        `json:"excerpt,omitempty"` // value is a window of a longer description
 }
 
-// SearchResponse represents the complete search results
+// Highlight is a matched term in a match value, as [Start, End) offsets in
+// characters (Unicode code points)
+type Highlight struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Term  string `json:"term"` // query term it matched
+}
+
+// SearchResponse represents the complete search results. Partial is set
+// when part of the index could not be searched; Warnings say which.
 type SearchResponse struct {
-	Results []SearchResult `json:"results"`
-	Total   int            `json:"total"`
-	Query   string         `json:"query"`
+	Results  []SearchResult `json:"results"`
+	Total    int            `json:"total"`
+	Query    string         `json:"query"`
+	Partial  bool           `json:"partial,omitempty"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+// BatchSearchRequest carries several searches run in one call
+type BatchSearchRequest struct {
+	Queries []SearchRequest `json:"queries"`
+}
+
+// BatchSearchResponse holds the response of each search, in query order
+type BatchSearchResponse struct {
+	Results []*SearchResponse `json:"results"`
+}
+
+// SearchRefineRequest refines the last search of a search session, e.g.
+// "only the darker ones"
+type SearchRefineRequest struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit"` // defaults to the session's limit
+}
+
+// SearchSessionResponse is the response to one turn of a search session
+type SearchSessionResponse struct {
+	SessionID string `json:"session_id"`
+	Turn      int    `json:"turn"` // 1 for the search that started the session
+	SearchResponse
 }
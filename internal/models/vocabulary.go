@@ -0,0 +1,16 @@
+package models
+
+// Term is an AI value mapped to the controlled vocabulary
+type Term struct {
+	Code  string `json:"code"`  // stable code, e.g. calm; other when nothing matched
+	Label string `json:"label"` // display name in the response language
+}
+
+// AnalysisTerms are the scene type, mood and style of an analysis as
+// vocabulary terms. The free-form values stay in the analysis.
+type AnalysisTerms struct {
+	Language  string `json:"language"` // language of the labels, e.g. de
+	SceneType *Term  `json:"scene_type,omitempty"`
+	Mood      *Term  `json:"mood,omitempty"`
+	Style     *Term  `json:"style,omitempty"`
+}
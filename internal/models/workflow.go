@@ -0,0 +1,40 @@
+package models
+
+// Workflow states track editorial sign-off, independent of processing status
+const (
+	WorkflowDraft    = "draft"
+	WorkflowInReview = "in-review"
+	WorkflowApproved = "approved"
+	WorkflowRejected = "rejected"
+)
+
+// workflowTransitions maps from -> to -> the minimum role allowed to move an
+// image between the two states
+var workflowTransitions = map[string]map[string]Role{
+	WorkflowDraft:    {WorkflowInReview: RoleEditor},
+	WorkflowInReview: {WorkflowApproved: RoleReviewer, WorkflowRejected: RoleReviewer, WorkflowDraft: RoleEditor},
+	WorkflowRejected: {WorkflowDraft: RoleEditor},
+	WorkflowApproved: {WorkflowDraft: RoleReviewer},
+}
+
+// IsValidWorkflowState reports whether s is a known workflow state
+func IsValidWorkflowState(s string) bool {
+	_, ok := workflowTransitions[s]
+	return ok
+}
+
+// WorkflowTransitionRole returns the minimum role for a transition, and false
+// if the transition is not allowed at all
+func WorkflowTransitionRole(from, to string) (Role, bool) {
+	role, ok := workflowTransitions[from][to]
+	return role, ok
+}
+
+// WorkflowEvent is sent to webhooks when an image changes workflow state
+type WorkflowEvent struct {
+	ImageID string `json:"image_id"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Actor   string `json:"actor"`
+	Comment string `json:"comment,omitempty"`
+}
@@ -4,14 +4,44 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/yourcompany/image-warehousing/internal/models"
 	"github.com/yourcompany/image-warehousing/pkg/gemini"
 )
 
+// EmbedInput is the content to embed: text, an image, or an image with
+// accompanying text. Images need image embeddings enabled.
+type EmbedInput struct {
+	Text      string
+	ImagePath string
+}
+
+// UncategorizedCategory files images whose AI category has no usable
+// characters (e.g. only punctuation or non-Latin script)
+const UncategorizedCategory = "uncategorized"
+
+// Embedder computes embedding vectors for text or images; satisfied by
+// AIService. Everything that compares content by meaning (search, similarity,
+// tag suggestions) goes through it so vectors stay comparable.
+type Embedder interface {
+	Embed(ctx context.Context, input EmbedInput) ([]float32, error)
+}
+
+// embeddingClient is the part of the Gemini client Embed calls
+type embeddingClient interface {
+	Embed(ctx context.Context, input gemini.EmbedInput) ([]float32, error)
+}
+
 type AIService struct {
-	geminiClient *gemini.Client
+	geminiClient  *gemini.Client
+	embedder      embeddingClient
+	categoryDepth int // 1 = primary only, 2 = primary/sub
+	usage         *aiUsage
+	cache         *AnalysisCache
 }
 
 func NewAIService(apiKey, model string) (*AIService, error) {
@@ -21,7 +51,10 @@ func NewAIService(apiKey, model string) (*AIService, error) {
 	}
 
 	return &AIService{
-		geminiClient: client,
+		geminiClient:  client,
+		embedder:      client,
+		categoryDepth: 1,
+		usage:         newAIUsage(),
 	}, nil
 }
 
@@ -29,9 +62,83 @@ func (s *AIService) Close() error {
 	return s.geminiClient.Close()
 }
 
+// SetAnalysisCache reuses analyses of identical content (same model and
+// prompt) instead of calling the AI again
+func (s *AIService) SetAnalysisCache(cache *AnalysisCache) {
+	s.cache = cache
+}
+
+// stamp records the provider, model and prompt version on an analysis
+func (s *AIService) stamp(analysis *models.AIAnalysis, promptVersion string) {
+	analysis.Provider = AnalysisProvider
+	analysis.Model = s.geminiClient.Model()
+	analysis.PromptVersion = promptVersion
+}
+
+// cacheKey returns the analysis cache key for content hashed by hash, or
+// false if there's no cache or the content can't be hashed
+func (s *AIService) cacheKey(hash func() (string, error), promptVersion string) (AnalysisCacheKey, bool) {
+	if s.cache == nil {
+		return AnalysisCacheKey{}, false
+	}
+	contentHash, err := hash()
+	if err != nil {
+		return AnalysisCacheKey{}, false
+	}
+	return AnalysisCacheKey{
+		ContentHash:   contentHash,
+		Provider:      AnalysisProvider,
+		Model:         s.geminiClient.Model(),
+		PromptVersion: promptVersion,
+	}, true
+}
+
+// AnalysisVersions names what new analyses are made with
+type AnalysisVersions struct {
+	Provider        string `json:"provider"`
+	Model           string `json:"model"`
+	PromptVersion2D string `json:"prompt_version_2d"`
+	PromptVersion3D string `json:"prompt_version_3d"`
+}
+
+// Versions returns the provider, model and prompt versions stamped on new
+// analyses
+func (s *AIService) Versions() AnalysisVersions {
+	if s == nil {
+		return AnalysisVersions{}
+	}
+	return AnalysisVersions{
+		Provider:        AnalysisProvider,
+		Model:           s.geminiClient.Model(),
+		PromptVersion2D: s.geminiClient.PromptVersion2D(),
+		PromptVersion3D: s.geminiClient.PromptVersion3D(),
+	}
+}
+
+// Usage returns the number of AI calls, failures and time spent per
+// operation since the server started
+func (s *AIService) Usage() map[string]AICallStats {
+	if s == nil {
+		return newAIUsage().snapshot()
+	}
+	return s.usage.snapshot()
+}
+
 // Analyze2DImage analyzes a single 2D image
 func (s *AIService) Analyze2DImage(ctx context.Context, imagePath string) (*models.AIAnalysis, error) {
+	start := time.Now()
+	promptVersion := s.geminiClient.PromptVersion2D()
+	key, cached := s.cacheKey(func() (string, error) { return hashFile(imagePath) }, promptVersion)
+	if cached {
+		if analysis, ok := s.cache.Get(key); ok {
+			s.usage.record(AIOpAnalyze2DCached, start, nil)
+			s.stamp(analysis, promptVersion)
+			return analysis, nil
+		}
+	}
+
 	resp, err := s.geminiClient.AnalyzeImage2D(ctx, imagePath)
+	s.usage.record(AIOpAnalyze2D, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -40,6 +147,7 @@ func (s *AIService) Analyze2DImage(ctx context.Context, imagePath string) (*mode
 	analysis := &models.AIAnalysis{
 		Type:            resp.Type,
 		PrimaryCategory: resp.PrimaryCategory,
+		SubCategory:     resp.SubCategory,
 		Description:     resp.Description,
 		Objects:         resp.Objects,
 		Colors:          resp.Colors,
@@ -47,18 +155,36 @@ func (s *AIService) Analyze2DImage(ctx context.Context, imagePath string) (*mode
 		Mood:            resp.Mood,
 		Style:           resp.Style,
 		Features:        s.parseFeatures(resp.Features),
+		Extra:           resp.Extra,
+		Aesthetics:      aestheticsFrom(resp.Aesthetics),
 	}
 
 	// Store raw response
 	rawJSON, _ := json.Marshal(resp)
 	analysis.RawResponse = string(rawJSON)
+	s.stamp(analysis, promptVersion)
 
+	if cached {
+		s.cache.Put(key, analysis)
+	}
 	return analysis, nil
 }
 
 // Analyze3DObject analyzes a 3D object from 6 views
 func (s *AIService) Analyze3DObject(ctx context.Context, viewPaths map[string]string) (*models.AIAnalysis, error) {
+	start := time.Now()
+	promptVersion := s.geminiClient.PromptVersion3D()
+	key, cached := s.cacheKey(func() (string, error) { return hashViews(viewPaths) }, promptVersion)
+	if cached {
+		if analysis, ok := s.cache.Get(key); ok {
+			s.usage.record(AIOpAnalyze3DCached, start, nil)
+			s.stamp(analysis, promptVersion)
+			return analysis, nil
+		}
+	}
+
 	resp, err := s.geminiClient.AnalyzeImage3D(ctx, viewPaths)
+	s.usage.record(AIOpAnalyze3D, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -67,6 +193,7 @@ func (s *AIService) Analyze3DObject(ctx context.Context, viewPaths map[string]st
 	analysis := &models.AIAnalysis{
 		Type:                  resp.Type,
 		PrimaryCategory:       resp.PrimaryCategory,
+		SubCategory:           resp.SubCategory,
 		Description:           resp.Description,
 		Objects:               resp.Objects,
 		Colors:                resp.Colors,
@@ -76,30 +203,74 @@ func (s *AIService) Analyze3DObject(ctx context.Context, viewPaths map[string]st
 		ThreeDCharacteristics: resp.ThreeDCharacteristics,
 		Symmetry:              resp.Symmetry,
 		Complexity:            resp.Complexity,
+		BestView:              resp.BestView,
 		Features:              s.parseFeatures(resp.Features),
+		Extra:                 resp.Extra,
+		Aesthetics:            aestheticsFrom(resp.Aesthetics),
 	}
 
 	// Store raw response
 	rawJSON, _ := json.Marshal(resp)
 	analysis.RawResponse = string(rawJSON)
+	s.stamp(analysis, promptVersion)
 
+	if cached {
+		s.cache.Put(key, analysis)
+	}
 	return analysis, nil
 }
 
 // SearchImages searches the index using Gemini
 func (s *AIService) SearchImages(ctx context.Context, indexContent, query string) ([]models.SearchResult, error) {
+	start := time.Now()
 	responseText, err := s.geminiClient.SearchImages(ctx, indexContent, query)
+	s.usage.record(AIOpSearch, start, err)
 	if err != nil {
 		return nil, err
 	}
+	return parseSearchResults(responseText)
+}
 
-	// Parse the JSON response
-	var results []struct {
-		ImageID        string  `json:"image_id"`
-		RelevanceScore float64 `json:"relevance_score"`
-		Reason         string  `json:"reason"`
+// RefineSearch answers a refinement of a search session in a Gemini chat,
+// replaying the earlier turns and their results as the chat history
+func (s *AIService) RefineSearch(ctx context.Context, indexContent string, history []SearchTurn, query string) ([]models.SearchResult, error) {
+	turns := make([]gemini.SearchTurn, len(history))
+	for i, turn := range history {
+		reply, err := json.Marshal(aiSearchResults(turn.Results))
+		if err != nil {
+			return nil, err
+		}
+		turns[i] = gemini.SearchTurn{Query: turn.Query, Reply: string(reply)}
 	}
 
+	start := time.Now()
+	responseText, err := s.geminiClient.RefineSearch(ctx, indexContent, turns, query)
+	s.usage.record(AIOpRefine, start, err)
+	if err != nil {
+		return nil, err
+	}
+	return parseSearchResults(responseText)
+}
+
+// aiSearchResult is a search result as the AI returns it
+type aiSearchResult struct {
+	ImageID        string  `json:"image_id"`
+	RelevanceScore float64 `json:"relevance_score"`
+	Reason         string  `json:"reason"`
+}
+
+// aiSearchResults converts results back to the form the AI returned them in
+func aiSearchResults(results []models.SearchResult) []aiSearchResult {
+	out := make([]aiSearchResult, len(results))
+	for i, r := range results {
+		out[i] = aiSearchResult{ImageID: r.ImageID, RelevanceScore: r.RelevanceScore, Reason: r.Reason}
+	}
+	return out
+}
+
+// parseSearchResults parses the JSON array of results of a search prompt
+func parseSearchResults(responseText string) ([]models.SearchResult, error) {
+	var results []aiSearchResult
 	if err := json.Unmarshal([]byte(responseText), &results); err != nil {
 		return nil, fmt.Errorf("failed to parse search results: %w", err)
 	}
@@ -117,6 +288,138 @@ func (s *AIService) SearchImages(ctx context.Context, indexContent, query string
 	return searchResults, nil
 }
 
+// SearchImagesBatch searches the index for several queries in one Gemini
+// call, returning the results of each query in order
+func (s *AIService) SearchImagesBatch(ctx context.Context, indexContent string, queries []string) ([][]models.SearchResult, error) {
+	start := time.Now()
+	responseText, err := s.geminiClient.SearchImagesBatch(ctx, indexContent, queries)
+	s.usage.record(AIOpSearchBatch, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	var batches []struct {
+		Query   int `json:"query"`
+		Results []struct {
+			ImageID        string  `json:"image_id"`
+			RelevanceScore float64 `json:"relevance_score"`
+			Reason         string  `json:"reason"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(responseText), &batches); err != nil {
+		return nil, fmt.Errorf("failed to parse batch search results: %w", err)
+	}
+
+	// Queries are numbered from 1 in the prompt; unknown numbers are dropped
+	searchResults := make([][]models.SearchResult, len(queries))
+	for _, batch := range batches {
+		if batch.Query < 1 || batch.Query > len(queries) {
+			continue
+		}
+		for _, r := range batch.Results {
+			searchResults[batch.Query-1] = append(searchResults[batch.Query-1], models.SearchResult{
+				ImageID:        r.ImageID,
+				RelevanceScore: r.RelevanceScore,
+				Reason:         r.Reason,
+			})
+		}
+	}
+
+	return searchResults, nil
+}
+
+// Embed returns the embedding vector for a piece of text or an image
+func (s *AIService) Embed(ctx context.Context, input EmbedInput) ([]float32, error) {
+	start := time.Now()
+	vector, err := s.embedder.Embed(ctx, gemini.EmbedInput{Text: input.Text, ImagePath: input.ImagePath})
+	s.usage.record(AIOpEmbed, start, err)
+	return vector, err
+}
+
+// SetEmbeddingModel overrides the text embedding model
+func (s *AIService) SetEmbeddingModel(model string) {
+	s.geminiClient.SetEmbeddingModel(model)
+}
+
+// SetMultimodalEmbeddingModel enables image embeddings with model, which
+// then embeds text as well, so queries and images stay comparable. Empty
+// embeds text only.
+func (s *AIService) SetMultimodalEmbeddingModel(model string) {
+	s.geminiClient.SetMultimodalEmbeddingModel(model)
+}
+
+// SetPrompt2D overrides the 2D analysis prompt (e.g. to evaluate a new
+// prompt version)
+func (s *AIService) SetPrompt2D(prompt string) {
+	s.geminiClient.SetPrompt2D(prompt)
+}
+
+// SetAesthetics makes analyses include an aesthetic score, composition score
+// and critique
+func (s *AIService) SetAesthetics(enabled bool) {
+	s.geminiClient.SetAesthetics(enabled)
+}
+
+// aestheticsFrom converts the AI's aesthetic rating, clamping the scores to
+// 1-10; nil without a usable rating
+func aestheticsFrom(rating *gemini.AestheticRating) *models.Aesthetics {
+	if rating == nil || rating.Score <= 0 {
+		return nil
+	}
+	clamp := func(v float64) float64 { return math.Max(1, math.Min(10, v)) }
+	a := &models.Aesthetics{Score: clamp(rating.Score), Critique: strings.TrimSpace(rating.Critique)}
+	if rating.Composition > 0 {
+		a.Composition = clamp(rating.Composition)
+	}
+	return a
+}
+
+// CategoryPrompt specializes analysis for one primary category: extra
+// instructions and structured fields to return (name -> description)
+type CategoryPrompt = gemini.CategoryPrompt
+
+// LoadCategoryPrompts reads per-category prompt specializations from a JSON
+// file keyed by primary category. An empty path loads none.
+func LoadCategoryPrompts(path string) (map[string]CategoryPrompt, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read category prompts: %w", err)
+	}
+
+	var prompts map[string]CategoryPrompt
+	if err := json.Unmarshal(data, &prompts); err != nil {
+		return nil, fmt.Errorf("failed to parse category prompts: %w", err)
+	}
+	return prompts, nil
+}
+
+// SetCategoryPrompts sets the per-category prompt specializations merged into
+// the analysis prompts
+func (s *AIService) SetCategoryPrompts(prompts map[string]CategoryPrompt) {
+	s.geminiClient.SetCategoryPrompts(prompts)
+}
+
+// SetInlineLimit sets the total image size above which images are uploaded
+// through the Gemini Files API instead of being sent inline
+func (s *AIService) SetInlineLimit(limit int64) {
+	s.geminiClient.SetInlineLimit(limit)
+}
+
+// SetCategoryDepth sets how many category levels are used for storage paths
+// (1 = primary only, 2 = primary/sub)
+func (s *AIService) SetCategoryDepth(depth int) {
+	if depth < 1 {
+		depth = 1
+	}
+	if depth > 2 {
+		depth = 2
+	}
+	s.categoryDepth = depth
+}
+
 // parseFeatures converts string features to Feature objects with confidence
 // Assumes features might be in format "tag (0.95)" or just "tag"
 func (s *AIService) parseFeatures(features []string) []models.Feature {
@@ -145,13 +448,45 @@ func (s *AIService) parseFeatures(features []string) []models.Feature {
 	return result
 }
 
-// GetCategoryPath constructs the category path from analysis
-// Now returns only primary category for flat structure
+// GetCategoryPath constructs the category path from analysis.
+// With a category depth of 2 the sub category is appended ("animals/cats");
+// otherwise only the primary category is used for a flat structure. The
+// result always passes ValidCategoryPath.
 func (s *AIService) GetCategoryPath(analysis *models.AIAnalysis) string {
-	return s.normalizeCategoryName(analysis.PrimaryCategory)
+	primary := s.normalizeCategoryName(analysis.PrimaryCategory)
+	if primary == "" {
+		primary = UncategorizedCategory
+	}
+	if s.categoryDepth < 2 {
+		return primary
+	}
+
+	if sub := s.normalizeCategoryName(analysis.SubCategory); sub != "" {
+		return primary + "/" + sub
+	}
+	return primary
 }
 
-// normalizeCategoryName converts category names to filesystem-safe paths
+// ConstrainCategory files an analysis under the first allowed primary
+// category (dropping its sub category) unless its category is allowed
+// already. No allowed categories means no constraint.
+func (s *AIService) ConstrainCategory(analysis *models.AIAnalysis, allowed []string) {
+	if len(allowed) == 0 {
+		return
+	}
+	primary := s.normalizeCategoryName(analysis.PrimaryCategory)
+	for _, category := range allowed {
+		if s.normalizeCategoryName(category) == primary {
+			return
+		}
+	}
+	analysis.PrimaryCategory = allowed[0]
+	analysis.SubCategory = ""
+}
+
+// normalizeCategoryName converts category names to a single filesystem-safe
+// path segment. Separators and dots are dropped, so names like "../x" or
+// "a/b" can't leave or nest inside the category folder.
 func (s *AIService) normalizeCategoryName(category string) string {
 	// Convert to lowercase
 	category = strings.ToLower(category)
@@ -165,5 +500,9 @@ func (s *AIService) normalizeCategoryName(category string) string {
 		return -1
 	}, category)
 
-	return category
+	// Collapse and trim the hyphens left around removed characters
+	for strings.Contains(category, "--") {
+		category = strings.ReplaceAll(category, "--", "-")
+	}
+	return strings.Trim(category, "-")
 }
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+	"github.com/yourcompany/image-warehousing/pkg/gemini"
+)
+
+func TestGetCategoryPath_Depth(t *testing.T) {
+	analysis := &models.AIAnalysis{PrimaryCategory: "Animals", SubCategory: "Big Cats"}
+
+	tests := []struct {
+		name     string
+		depth    int
+		analysis *models.AIAnalysis
+		want     string
+	}{
+		{"flat", 1, analysis, "animals"},
+		{"hierarchical", 2, analysis, "animals/big-cats"},
+		{"hierarchical without sub", 2, &models.AIAnalysis{PrimaryCategory: "Animals"}, "animals"},
+		{"depth clamped", 5, analysis, "animals/big-cats"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &AIService{}
+			svc.SetCategoryDepth(tt.depth)
+			if got := svc.GetCategoryPath(tt.analysis); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConstrainCategory(t *testing.T) {
+	svc := &AIService{}
+	svc.SetCategoryDepth(2)
+	allowed := []string{"products", "big-cats"}
+
+	analysis := &models.AIAnalysis{PrimaryCategory: "Big Cats", SubCategory: "Lions"}
+	svc.ConstrainCategory(analysis, allowed)
+	if got := svc.GetCategoryPath(analysis); got != "big-cats/lions" {
+		t.Errorf("expected an allowed category to be kept, got %q", got)
+	}
+
+	analysis = &models.AIAnalysis{PrimaryCategory: "Landscapes", SubCategory: "Hills"}
+	svc.ConstrainCategory(analysis, allowed)
+	if got := svc.GetCategoryPath(analysis); got != "products" {
+		t.Errorf("expected the first allowed category as fallback, got %q", got)
+	}
+
+	analysis = &models.AIAnalysis{PrimaryCategory: "Landscapes"}
+	svc.ConstrainCategory(analysis, nil)
+	if analysis.PrimaryCategory != "Landscapes" {
+		t.Errorf("expected no constraint without allowed categories, got %q", analysis.PrimaryCategory)
+	}
+}
+
+func TestGetCategoryPath_Adversarial(t *testing.T) {
+	svc := &AIService{}
+	svc.SetCategoryDepth(2)
+
+	tests := []struct {
+		primary, sub, want string
+	}{
+		{"../../etc", "passwd", "etc/passwd"},
+		{"Animals/Cats", "..", "animalscats"},
+		{" -- Big  Cats -- ", "", "big-cats"},
+		{"動物", "猫", UncategorizedCategory},
+		{"***", "Lions", UncategorizedCategory + "/lions"},
+	}
+	for _, tt := range tests {
+		got := svc.GetCategoryPath(&models.AIAnalysis{PrimaryCategory: tt.primary, SubCategory: tt.sub})
+		if got != tt.want {
+			t.Errorf("GetCategoryPath(%q, %q) = %q, want %q", tt.primary, tt.sub, got, tt.want)
+		}
+		if !ValidCategoryPath(got) {
+			t.Errorf("GetCategoryPath(%q, %q) = %q is not a valid category path", tt.primary, tt.sub, got)
+		}
+	}
+}
+
+func TestAIUsage(t *testing.T) {
+	usage := newAIUsage()
+	start := time.Now().Add(-time.Second)
+	usage.record(AIOpAnalyze2D, start, nil)
+	usage.record(AIOpAnalyze2D, start, errors.New("quota exceeded"))
+	usage.record(AIOpSearch, start, nil)
+
+	got := usage.snapshot()
+	if stats := got[AIOpAnalyze2D]; stats.Calls != 2 || stats.Failures != 1 || stats.TotalMs < 2000 || stats.LastCallAt == nil {
+		t.Errorf("unexpected analysis usage %+v", stats)
+	}
+	if stats := got[AIOpSearch]; stats.Calls != 1 || stats.Failures != 0 {
+		t.Errorf("unexpected search usage %+v", stats)
+	}
+
+	// Services without a client (e.g. in tests) report no usage
+	var svc *AIService
+	if len(svc.Usage()) != 0 || len((&AIService{}).Usage()) != 0 {
+		t.Error("expected no usage")
+	}
+}
+
+func TestAestheticsFrom(t *testing.T) {
+	if got := aestheticsFrom(nil); got != nil {
+		t.Errorf("expected nil without a rating, got %+v", got)
+	}
+	got := aestheticsFrom(&gemini.AestheticRating{Score: 12, Composition: 0.5, Critique: " Busy background. "})
+	want := models.Aesthetics{Score: 10, Composition: 1, Critique: "Busy background."}
+	if got == nil || *got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+type fakeEmbeddingClient struct {
+	inputs []gemini.EmbedInput
+	err    error
+}
+
+func (f *fakeEmbeddingClient) Embed(ctx context.Context, input gemini.EmbedInput) ([]float32, error) {
+	f.inputs = append(f.inputs, input)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []float32{0.1, 0.2, 0.3}, nil
+}
+
+func TestAIService_Embed(t *testing.T) {
+	client := &fakeEmbeddingClient{}
+	svc := &AIService{embedder: client, usage: newAIUsage()}
+
+	vector, err := svc.Embed(context.Background(), EmbedInput{Text: "red bicycle"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vector) != 3 || len(client.inputs) != 1 || client.inputs[0].Text != "red bicycle" {
+		t.Errorf("unexpected call %+v -> %v", client.inputs, vector)
+	}
+
+	svc.Embed(context.Background(), EmbedInput{Text: "a cat", ImagePath: "/data/cat_thumb.jpg"})
+	if got := client.inputs[1]; got.Text != "a cat" || got.ImagePath != "/data/cat_thumb.jpg" {
+		t.Errorf("expected the image to be passed on, got %+v", got)
+	}
+
+	client.err = errors.New("quota exceeded")
+	if _, err := svc.Embed(context.Background(), EmbedInput{Text: "blue car"}); !errors.Is(err, client.err) {
+		t.Errorf("expected the client's error, got %v", err)
+	}
+
+	if stats := svc.Usage()[AIOpEmbed]; stats.Calls != 3 || stats.Failures != 1 {
+		t.Errorf("unexpected embed usage %+v", stats)
+	}
+}
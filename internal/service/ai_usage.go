@@ -0,0 +1,74 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// AI operations counted in AIService.Usage
+const (
+	AIOpAnalyze2D   = "analyze_2d"
+	AIOpAnalyze3D   = "analyze_3d"
+	AIOpSearch      = "search"
+	AIOpSearchBatch = "search_batch"
+	AIOpRefine      = "refine_search"
+	AIOpEmbed       = "embed"
+
+	// Analyses answered from the analysis cache, without an AI call
+	AIOpAnalyze2DCached = "analyze_2d_cached"
+	AIOpAnalyze3DCached = "analyze_3d_cached"
+)
+
+// AICallStats counts the calls of one AI operation
+type AICallStats struct {
+	Calls      int64      `json:"calls"`
+	Failures   int64      `json:"failures"`
+	TotalMs    int64      `json:"total_ms"` // time spent waiting for the AI
+	LastCallAt *time.Time `json:"last_call_at,omitempty"`
+}
+
+// aiUsage counts AI calls per operation since the server started
+type aiUsage struct {
+	mutex sync.Mutex
+	ops   map[string]*AICallStats
+}
+
+func newAIUsage() *aiUsage {
+	return &aiUsage{ops: make(map[string]*AICallStats)}
+}
+
+// record counts a call of op that started at start and failed if err is set
+func (u *aiUsage) record(op string, start time.Time, err error) {
+	if u == nil {
+		return
+	}
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	stats, ok := u.ops[op]
+	if !ok {
+		stats = &AICallStats{}
+		u.ops[op] = stats
+	}
+	stats.Calls++
+	if err != nil {
+		stats.Failures++
+	}
+	stats.TotalMs += time.Since(start).Milliseconds()
+	stats.LastCallAt = &start
+}
+
+// snapshot copies the counts
+func (u *aiUsage) snapshot() map[string]AICallStats {
+	out := make(map[string]AICallStats)
+	if u == nil {
+		return out
+	}
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for op, stats := range u.ops {
+		out[op] = *stats
+	}
+	return out
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// AnalysisProvider names the AI provider in analysis cache keys
+const AnalysisProvider = "gemini"
+
+// DefaultAnalysisCacheSize bounds the analyses kept when no size is set
+const DefaultAnalysisCacheSize = 10000
+
+// AnalysisCacheKey identifies an analysis result: the same content
+// analyzed by the same provider, model and prompt version
+type AnalysisCacheKey struct {
+	ContentHash   string
+	Provider      string
+	Model         string
+	PromptVersion string
+}
+
+func (k AnalysisCacheKey) String() string {
+	return strings.Join([]string{k.ContentHash, k.Provider, k.Model, k.PromptVersion}, "|")
+}
+
+type cachedAnalysis struct {
+	Analysis  *models.AIAnalysis `json:"analysis"`
+	CreatedAt time.Time          `json:"created_at"`
+	UsedAt    time.Time          `json:"used_at"`
+}
+
+// AnalysisCacheStats describes the analysis cache since the server started
+type AnalysisCacheStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+// AnalysisCache keeps AI analyses by content, so re-uploads and
+// re-analyses of the same bytes with the same model and prompt don't call
+// the AI again. The least recently used analyses are dropped beyond the
+// size limit. Like PopularityStore it is saved every interval by Run (and
+// on shutdown).
+type AnalysisCache struct {
+	path       string
+	maxEntries int
+	entries    map[string]*cachedAnalysis
+	hits       int64
+	misses     int64
+	dirty      bool
+	mutex      sync.Mutex
+}
+
+func NewAnalysisCache(path string, maxEntries int) *AnalysisCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultAnalysisCacheSize
+	}
+	return &AnalysisCache{
+		path:       path,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*cachedAnalysis),
+	}
+}
+
+// Load reads saved analyses from disk, if the file exists
+func (c *AnalysisCache) Load() error {
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read analysis cache: %w", err)
+	}
+
+	var entries map[string]*cachedAnalysis
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse analysis cache: %w", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, entry := range entries {
+		if entry.Analysis != nil {
+			c.entries[key] = entry
+		}
+	}
+	c.evict()
+	return nil
+}
+
+// Get returns a copy of the cached analysis for key
+func (c *AnalysisCache) Get(key AnalysisCacheKey) (*models.AIAnalysis, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key.String()]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	analysis, err := copyAnalysis(entry.Analysis)
+	if err != nil {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	entry.UsedAt = time.Now()
+	c.dirty = true
+	return analysis, true
+}
+
+// Put caches a copy of an analysis under key
+func (c *AnalysisCache) Put(key AnalysisCacheKey, analysis *models.AIAnalysis) {
+	stored, err := copyAnalysis(analysis)
+	if err != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	c.entries[key.String()] = &cachedAnalysis{Analysis: stored, CreatedAt: now, UsedAt: now}
+	c.dirty = true
+	c.evict()
+}
+
+// evict drops the least recently used entries beyond the size limit.
+// Caller must hold the lock.
+func (c *AnalysisCache) evict() {
+	excess := len(c.entries) - c.maxEntries
+	if excess <= 0 {
+		return
+	}
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return c.entries[keys[i]].UsedAt.Before(c.entries[keys[j]].UsedAt) })
+	for _, key := range keys[:excess] {
+		delete(c.entries, key)
+	}
+	c.dirty = true
+}
+
+// Forget drops the analyses of the given content hashes, whatever model
+// and prompt made them, and returns how many were dropped
+func (c *AnalysisCache) Forget(contentHashes map[string]bool) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	dropped := 0
+	for key := range c.entries {
+		hash, _, _ := strings.Cut(key, "|")
+		if contentHashes[hash] {
+			delete(c.entries, key)
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		c.dirty = true
+	}
+	return dropped
+}
+
+// Stats returns the cache size and its hits and misses since startup
+func (c *AnalysisCache) Stats() AnalysisCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return AnalysisCacheStats{Entries: len(c.entries), Hits: c.hits, Misses: c.misses}
+}
+
+// Save writes the cache to disk atomically, if it changed
+func (c *AnalysisCache) Save() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode analysis cache: %w", err)
+	}
+	tmpPath := c.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write analysis cache: %w", err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("failed to replace analysis cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// Run saves the cache every interval until ctx is done
+func (c *AnalysisCache) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Save()
+		}
+	}
+}
+
+// copyAnalysis deep-copies an analysis, so callers can adjust theirs (e.g.
+// ConstrainCategory) without touching the cached one
+func copyAnalysis(analysis *models.AIAnalysis) (*models.AIAnalysis, error) {
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return nil, err
+	}
+	var copied models.AIAnalysis
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
+
+// hashViews returns a hex SHA-256 over the names and contents of a 3D
+// object's views, in view name order
+func hashViews(viewPaths map[string]string) (string, error) {
+	views := make([]string, 0, len(viewPaths))
+	for view := range viewPaths {
+		views = append(views, view)
+	}
+	sort.Strings(views)
+
+	h := sha256.New()
+	for _, view := range views {
+		sum, err := hashFile(viewPaths[view])
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", view, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
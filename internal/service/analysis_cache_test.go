@@ -0,0 +1,86 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestAnalysisCache_GetPutAndEviction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analysis_cache.json")
+	cache := NewAnalysisCache(path, 2)
+
+	key := func(hash string) AnalysisCacheKey {
+		return AnalysisCacheKey{ContentHash: hash, Provider: AnalysisProvider, Model: "gemini-3-flash-preview", PromptVersion: "v1"}
+	}
+	cache.Put(key("a"), &models.AIAnalysis{PrimaryCategory: "animals", Features: []models.Feature{{Name: "cat"}}})
+
+	analysis, ok := cache.Get(key("a"))
+	if !ok || analysis.PrimaryCategory != "animals" || len(analysis.Features) != 1 {
+		t.Fatalf("expected the cached analysis, got %+v", analysis)
+	}
+	// Callers get their own copy
+	analysis.PrimaryCategory = "changed"
+	analysis.Features[0].Name = "changed"
+	if again, _ := cache.Get(key("a")); again.PrimaryCategory != "animals" || again.Features[0].Name != "cat" {
+		t.Errorf("expected the cached analysis to be unchanged, got %+v", again)
+	}
+
+	other := key("a")
+	other.PromptVersion = "v2"
+	if _, ok := cache.Get(other); ok {
+		t.Error("expected a miss for another prompt version")
+	}
+
+	// a was used last, so b is evicted when c arrives
+	time.Sleep(time.Millisecond)
+	cache.Put(key("b"), &models.AIAnalysis{PrimaryCategory: "food"})
+	time.Sleep(time.Millisecond)
+	cache.Get(key("a"))
+	time.Sleep(time.Millisecond)
+	cache.Put(key("c"), &models.AIAnalysis{PrimaryCategory: "nature"})
+	if _, ok := cache.Get(key("b")); ok {
+		t.Error("expected the least recently used analysis to be evicted")
+	}
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Hits != 3 || stats.Misses != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	reloaded := NewAnalysisCache(path, 2)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if analysis, ok := reloaded.Get(key("c")); !ok || analysis.PrimaryCategory != "nature" {
+		t.Errorf("expected analyses to survive a reload, got %+v", analysis)
+	}
+}
+
+func TestHashViews(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	front, back := write("front.jpg", "front"), write("back.jpg", "back")
+
+	hash, err := hashViews(map[string]string{"front": front, "back": back})
+	if err != nil {
+		t.Fatalf("hashViews failed: %v", err)
+	}
+	swapped, _ := hashViews(map[string]string{"front": back, "back": front})
+	if hash == swapped {
+		t.Error("expected the hash to depend on which view shows what")
+	}
+	if _, err := hashViews(map[string]string{"front": filepath.Join(dir, "missing.jpg")}); err == nil {
+		t.Error("expected an error for a missing view")
+	}
+}
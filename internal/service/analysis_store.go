@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// ErrAnalysisNotFound is returned when no analysis is stored for an image
+var ErrAnalysisNotFound = errors.New("analysis not found")
+
+// AnalysisStore keeps the full AI analysis of each image, raw provider
+// response included, as one JSON file per image. The index only holds the
+// fields search needs; this keeps the rest retrievable after the status
+// store has forgotten the upload.
+type AnalysisStore struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+func NewAnalysisStore(dir string) *AnalysisStore {
+	return &AnalysisStore{dir: dir}
+}
+
+func (s *AnalysisStore) path(imageID string) string {
+	return filepath.Join(s.dir, url.PathEscape(imageID)+".json")
+}
+
+// Get returns the stored analysis of an image
+func (s *AnalysisStore) Get(imageID string) (*models.AIAnalysis, error) {
+	data, err := os.ReadFile(s.path(imageID))
+	if os.IsNotExist(err) {
+		return nil, ErrAnalysisNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analysis: %w", err)
+	}
+	var analysis models.AIAnalysis
+	if err := json.Unmarshal(data, &analysis); err != nil {
+		return nil, fmt.Errorf("failed to parse analysis: %w", err)
+	}
+	return &analysis, nil
+}
+
+// Put stores the analysis of an image, replacing any earlier one
+func (s *AnalysisStore) Put(imageID string, analysis *models.AIAnalysis) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.write(imageID, analysis)
+}
+
+// Update changes the stored analysis of an image with fn
+func (s *AnalysisStore) Update(imageID string, fn func(analysis *models.AIAnalysis)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	analysis, err := s.Get(imageID)
+	if err != nil {
+		return err
+	}
+	fn(analysis)
+	return s.write(imageID, analysis)
+}
+
+// Delete removes the stored analysis of an image, if any
+func (s *AnalysisStore) Delete(imageID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.Remove(s.path(imageID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete analysis: %w", err)
+	}
+	return nil
+}
+
+// write stores an analysis atomically. Caller must hold the lock.
+func (s *AnalysisStore) write(imageID string, analysis *models.AIAnalysis) error {
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return fmt.Errorf("failed to encode analysis: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create analysis directory: %w", err)
+	}
+	path := s.path(imageID)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write analysis: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace analysis: %w", err)
+	}
+	return nil
+}
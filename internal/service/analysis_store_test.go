@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestAnalysisStore(t *testing.T) {
+	store := NewAnalysisStore(filepath.Join(t.TempDir(), "analyses"))
+
+	if _, err := store.Get("img-1"); !errors.Is(err, ErrAnalysisNotFound) {
+		t.Fatalf("expected ErrAnalysisNotFound, got %v", err)
+	}
+	analysis := &models.AIAnalysis{Description: "A cat", Model: "gemini-3-flash-preview", RawResponse: `{"description": "A cat"}`}
+	if err := store.Put("img-1", analysis); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if got, err := store.Get("img-1"); err != nil || got.Description != "A cat" || got.RawResponse != analysis.RawResponse {
+		t.Errorf("expected the stored analysis, got %+v (%v)", got, err)
+	}
+
+	if err := store.Update("img-1", func(a *models.AIAnalysis) { a.Description = "A black cat" }); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got, _ := store.Get("img-1"); got.Description != "A black cat" || got.Model != "gemini-3-flash-preview" {
+		t.Errorf("unexpected updated analysis %+v", got)
+	}
+	if err := store.Update("missing", func(a *models.AIAnalysis) {}); !errors.Is(err, ErrAnalysisNotFound) {
+		t.Errorf("expected ErrAnalysisNotFound updating a missing analysis, got %v", err)
+	}
+
+	if err := store.Delete("img-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get("img-1"); !errors.Is(err, ErrAnalysisNotFound) {
+		t.Errorf("expected the analysis to be gone, got %v", err)
+	}
+	if err := store.Delete("img-1"); err != nil {
+		t.Errorf("expected deleting twice to succeed, got %v", err)
+	}
+}
+
+func TestImageService_GetAnalysis(t *testing.T) {
+	status := NewStatusStore("", 0, 0)
+	svc := NewImageService(nil, nil, nil, status, logrus.New())
+
+	// Without a store only tracked uploads have one
+	status.Set(&models.Image{ID: "recent", AIAnalysis: &models.AIAnalysis{Description: "recent"}})
+	if analysis, err := svc.GetAnalysis("recent"); err != nil || analysis.Description != "recent" {
+		t.Errorf("expected the tracked upload's analysis, got %+v (%v)", analysis, err)
+	}
+
+	store := NewAnalysisStore(t.TempDir())
+	svc.SetAnalysisStore(store)
+	svc.notifyIndexed(&models.Image{ID: "indexed", AIAnalysis: &models.AIAnalysis{Description: "indexed"}})
+	if analysis, err := svc.GetAnalysis("indexed"); err != nil || analysis.Description != "indexed" {
+		t.Errorf("expected the stored analysis, got %+v (%v)", analysis, err)
+	}
+	if _, err := svc.GetAnalysis("missing"); !errors.Is(err, ErrAnalysisNotFound) {
+		t.Errorf("expected ErrAnalysisNotFound, got %v", err)
+	}
+}
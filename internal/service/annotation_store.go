@@ -0,0 +1,156 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// AnnotationStore keeps reviewer annotations per image, persisted as JSON
+// next to the index
+type AnnotationStore struct {
+	file         *sharedFile
+	annotations  map[string][]models.Annotation
+	lastModified time.Time
+	mutex        sync.RWMutex
+}
+
+func NewAnnotationStore(path string) *AnnotationStore {
+	return &AnnotationStore{
+		file:        newSharedFile(path),
+		annotations: make(map[string][]models.Annotation),
+	}
+}
+
+// SetCoordinator shares the annotations with the other instances using c
+func (s *AnnotationStore) SetCoordinator(c *Coordinator) {
+	s.file.share(c)
+}
+
+// Load reads stored annotations from disk, if the file exists
+func (s *AnnotationStore) Load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.load()
+}
+
+// load replaces the annotations with the file's. Caller must hold the lock.
+func (s *AnnotationStore) load() error {
+	data, err := s.file.read()
+	if err != nil {
+		return fmt.Errorf("failed to read annotations: %w", err)
+	}
+	annotations := make(map[string][]models.Annotation)
+	if data != nil {
+		if err := json.Unmarshal(data, &annotations); err != nil {
+			return fmt.Errorf("failed to parse annotations: %w", err)
+		}
+		if info, err := os.Stat(s.file.path); err == nil {
+			s.lastModified = info.ModTime()
+		}
+	}
+	s.annotations = annotations
+	return nil
+}
+
+// refresh reloads the annotations if another instance changed them. If
+// that fails, the ones in memory are served.
+func (s *AnnotationStore) refresh() {
+	if s.file.changed() {
+		s.mutex.Lock()
+		s.load()
+		s.mutex.Unlock()
+	}
+}
+
+// Add validates and stores an annotation, assigning its ID and creation
+// time, and persists the store
+func (s *AnnotationStore) Add(a models.Annotation) (models.Annotation, error) {
+	if err := a.Validate(); err != nil {
+		return a, err
+	}
+	a.ID = uuid.New().String()
+	a.CreatedAt = time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	end, err := s.file.begin(s.load)
+	if err != nil {
+		return a, err
+	}
+	defer end()
+
+	s.annotations[a.ImageID] = append(s.annotations[a.ImageID], a)
+	if err := s.save(); err != nil {
+		list := s.annotations[a.ImageID]
+		s.annotations[a.ImageID] = list[:len(list)-1]
+		return a, err
+	}
+	s.lastModified = a.CreatedAt
+	return a, nil
+}
+
+// List returns the annotations of an image, oldest first
+func (s *AnnotationStore) List(imageID string) []models.Annotation {
+	s.refresh()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]models.Annotation{}, s.annotations[imageID]...)
+}
+
+// Count returns the number of annotations on an image
+func (s *AnnotationStore) Count(imageID string) int {
+	s.refresh()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.annotations[imageID])
+}
+
+// Forget drops the annotations of an image and persists the store
+func (s *AnnotationStore) Forget(imageID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	end, err := s.file.begin(s.load)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	list, ok := s.annotations[imageID]
+	if !ok {
+		return nil
+	}
+	delete(s.annotations, imageID)
+	if err := s.save(); err != nil {
+		s.annotations[imageID] = list
+		return err
+	}
+	s.lastModified = time.Now()
+	return nil
+}
+
+// LastModified returns when annotations last changed
+func (s *AnnotationStore) LastModified() time.Time {
+	s.refresh()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.lastModified
+}
+
+// save writes all annotations to disk atomically. Caller must hold the lock.
+func (s *AnnotationStore) save() error {
+	data, err := json.Marshal(s.annotations)
+	if err != nil {
+		return fmt.Errorf("failed to encode annotations: %w", err)
+	}
+
+	if err := s.file.write(data, 0644); err != nil {
+		return fmt.Errorf("failed to write annotations: %w", err)
+	}
+	return nil
+}
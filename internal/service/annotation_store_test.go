@@ -0,0 +1,62 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestAnnotationStore_AddAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "annotations.json")
+	store := NewAnnotationStore(path)
+
+	created, err := store.Add(models.Annotation{ImageID: "img-1", Shape: models.AnnotationRect, X: 0.1, Y: 0.2, Width: 0.3, Height: 0.4, Text: "Fix the hand"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if created.ID == "" || created.CreatedAt.IsZero() {
+		t.Errorf("expected ID and creation time to be set, got %+v", created)
+	}
+	if _, err := store.Add(models.Annotation{ImageID: "img-1", Shape: models.AnnotationPoint, X: 0.5, Y: 0.5, Text: "Nice"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	reloaded := NewAnnotationStore(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if reloaded.Count("img-1") != 2 || reloaded.Count("img-2") != 0 {
+		t.Errorf("unexpected counts after reload: %d, %d", reloaded.Count("img-1"), reloaded.Count("img-2"))
+	}
+	if list := reloaded.List("img-1"); list[0].Text != "Fix the hand" {
+		t.Errorf("expected annotations in creation order, got %+v", list)
+	}
+}
+
+func TestAnnotationStore_RejectsInvalid(t *testing.T) {
+	store := NewAnnotationStore(filepath.Join(t.TempDir(), "annotations.json"))
+
+	tests := []struct {
+		name       string
+		annotation models.Annotation
+	}{
+		{"no text", models.Annotation{Shape: models.AnnotationPoint, X: 0.5, Y: 0.5}},
+		{"out of range", models.Annotation{Shape: models.AnnotationPoint, X: 1.5, Y: 0.5, Text: "x"}},
+		{"rect overflows", models.Annotation{Shape: models.AnnotationRect, X: 0.8, Y: 0.1, Width: 0.5, Height: 0.1, Text: "x"}},
+		{"point with size", models.Annotation{Shape: models.AnnotationPoint, X: 0.5, Y: 0.5, Width: 0.1, Text: "x"}},
+		{"unknown shape", models.Annotation{Shape: "circle", X: 0.5, Y: 0.5, Text: "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.annotation.ImageID = "img-1"
+			if _, err := store.Add(tt.annotation); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+	if store.Count("img-1") != 0 {
+		t.Error("invalid annotations were stored")
+	}
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Views4 and Views6 are the views of a 3D object in 4- and 6-surface mode
+var (
+	Views4 = []string{"front", "back", "left", "right"}
+	Views6 = []string{"front", "back", "left", "right", "top", "bottom"}
+)
+
+const (
+	// ArchiveManifest is the optional file at the root of a 3D archive that
+	// names its model, views and materials
+	ArchiveManifest = "manifest.json"
+
+	maxArchiveEntries  = 512
+	maxArchiveManifest = 1 << 20
+)
+
+// ErrInvalidArchive is returned for 3D archives that cannot be unpacked into
+// an object
+var ErrInvalidArchive = errors.New("invalid 3D archive")
+
+// viewExtensions are the image formats accepted for view renders
+var viewExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true}
+
+// Archive3DManifest lays out a 3D archive: paths inside the archive of the
+// model, of each view by name and of the model's materials
+type Archive3DManifest struct {
+	Model     string            `json:"model"`
+	Views     map[string]string `json:"views"`
+	Materials []string          `json:"materials,omitempty"`
+}
+
+// Archive3D is a zip upload holding a 3D model, its view renders and its
+// materials
+type Archive3D struct {
+	Manifest Archive3DManifest
+
+	files     map[string]*zip.File
+	remaining int64 // bytes left to unpack
+}
+
+// OpenArchive3D reads the layout of a 3D archive. It comes from the
+// manifest.json at the root of the archive; without one it is inferred from
+// file names: the one model file, views named after their view (front.png,
+// renders/top.jpg) and any other material files. Folders, __MACOSX and
+// hidden files are ignored. maxUnpacked bounds the unpacked size.
+func OpenArchive3D(r io.ReaderAt, size, maxUnpacked int64) (*Archive3D, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if len(reader.File) > maxArchiveEntries {
+		return nil, fmt.Errorf("%w: more than %d entries", ErrInvalidArchive, maxArchiveEntries)
+	}
+
+	a := &Archive3D{files: make(map[string]*zip.File), remaining: maxUnpacked}
+	var unpacked uint64
+	for _, entry := range reader.File {
+		name := path.Clean(strings.ReplaceAll(entry.Name, "\\", "/"))
+		if entry.FileInfo().IsDir() || isIgnoredArchiveEntry(name) {
+			continue
+		}
+		if _, dup := a.files[name]; dup {
+			return nil, fmt.Errorf("%w: duplicate entry %s", ErrInvalidArchive, name)
+		}
+		a.files[name] = entry
+		unpacked += entry.UncompressedSize64
+	}
+	if unpacked > uint64(maxUnpacked) {
+		return nil, fmt.Errorf("%w: unpacked size exceeds %d bytes", ErrInvalidArchive, maxUnpacked)
+	}
+
+	if entry, ok := a.files[ArchiveManifest]; ok {
+		if err := a.readManifest(entry); err != nil {
+			return nil, err
+		}
+	} else if err := a.inferManifest(); err != nil {
+		return nil, err
+	}
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// isIgnoredArchiveEntry reports whether an entry is archiver metadata or a
+// hidden file
+func isIgnoredArchiveEntry(name string) bool {
+	return name == "__MACOSX" || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".")
+}
+
+func (a *Archive3D) readManifest(entry *zip.File) error {
+	r, err := entry.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer r.Close()
+
+	if err := json.NewDecoder(io.LimitReader(r, maxArchiveManifest)).Decode(&a.Manifest); err != nil {
+		return fmt.Errorf("%w: invalid %s: %v", ErrInvalidArchive, ArchiveManifest, err)
+	}
+	clean := func(name string) string {
+		return path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	}
+	a.Manifest.Model = clean(a.Manifest.Model)
+	for view, name := range a.Manifest.Views {
+		a.Manifest.Views[view] = clean(name)
+	}
+	for i, name := range a.Manifest.Materials {
+		a.Manifest.Materials[i] = clean(name)
+	}
+	return nil
+}
+
+// inferManifest lays out an archive without a manifest by its file names
+func (a *Archive3D) inferManifest() error {
+	isView := make(map[string]bool)
+	for _, view := range Views6 {
+		isView[view] = true
+	}
+
+	// In name order, so that errors name the same files every time
+	names := make([]string, 0, len(a.files))
+	for name := range a.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m := Archive3DManifest{Views: make(map[string]string)}
+	for _, name := range names {
+		ext := strings.ToLower(path.Ext(name))
+		base := strings.ToLower(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+		switch {
+		case modelFormats[ext].contentType != "":
+			if m.Model != "" {
+				return fmt.Errorf("%w: more than one model (%s, %s); add a %s", ErrInvalidArchive, m.Model, name, ArchiveManifest)
+			}
+			m.Model = name
+		case isView[base] && viewExtensions[ext]:
+			if other, dup := m.Views[base]; dup {
+				return fmt.Errorf("%w: more than one %s view (%s, %s); add a %s", ErrInvalidArchive, base, other, name, ArchiveManifest)
+			}
+			m.Views[base] = name
+		case IsMaterialFile(name):
+			m.Materials = append(m.Materials, name)
+		}
+	}
+	a.Manifest = m
+	return nil
+}
+
+// validate checks that the layout names files of the archive, with the views
+// of 4- or 6-surface mode and a model whose content matches its format
+func (a *Archive3D) validate() error {
+	m := a.Manifest
+	if m.Model == "" || m.Model == "." {
+		return fmt.Errorf("%w: no model file", ErrInvalidArchive)
+	}
+	if _, ok := modelFormats[strings.ToLower(path.Ext(m.Model))]; !ok {
+		return fmt.Errorf("%w: unsupported model file %s", ErrInvalidArchive, m.Model)
+	}
+
+	views := a.ViewNames()
+	if len(m.Views) != len(views) {
+		return fmt.Errorf("%w: needs the views %s (or %s)", ErrInvalidArchive, strings.Join(Views4, ", "), strings.Join(Views6, ", "))
+	}
+	for _, view := range views {
+		name, ok := m.Views[view]
+		if !ok {
+			return fmt.Errorf("%w: missing view %s", ErrInvalidArchive, view)
+		}
+		if !viewExtensions[strings.ToLower(path.Ext(name))] {
+			return fmt.Errorf("%w: view %s is not a PNG, JPEG or GIF image", ErrInvalidArchive, name)
+		}
+	}
+
+	materials := make(map[string]bool)
+	for _, name := range m.Materials {
+		if !IsMaterialFile(name) {
+			return fmt.Errorf("%w: unsupported material file %s (use .mtl, .bin or texture images)", ErrInvalidArchive, name)
+		}
+		// Materials are looked up by base name next to the model
+		base := strings.ToLower(path.Base(name))
+		if materials[base] {
+			return fmt.Errorf("%w: more than one material named %s", ErrInvalidArchive, path.Base(name))
+		}
+		materials[base] = true
+	}
+
+	for _, name := range a.names() {
+		if _, ok := a.files[name]; !ok {
+			return fmt.Errorf("%w: %s not found", ErrInvalidArchive, name)
+		}
+	}
+
+	r, err := a.files[m.Model].Open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer r.Close()
+	return ValidateModelFile(r, m.Model)
+}
+
+// ViewNames returns the views of the archive in upload order
+func (a *Archive3D) ViewNames() []string {
+	if len(a.Manifest.Views) == len(Views4) {
+		return Views4
+	}
+	return Views6
+}
+
+// names returns every file the layout references
+func (a *Archive3D) names() []string {
+	names := []string{a.Manifest.Model}
+	for _, view := range a.ViewNames() {
+		names = append(names, a.Manifest.Views[view])
+	}
+	return append(names, a.Manifest.Materials...)
+}
+
+// extract unpacks an entry to dest, within the remaining unpacked size
+func (a *Archive3D) extract(name, dest string) error {
+	r, err := a.files[name].Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	// Declared sizes can lie: stop at the limit rather than trusting them
+	n, err := io.Copy(out, io.LimitReader(r, a.remaining+1))
+	if err != nil {
+		out.Close()
+		return err
+	}
+	if n > a.remaining {
+		out.Close()
+		return fmt.Errorf("%w: unpacked size exceeds the limit", ErrInvalidArchive)
+	}
+	a.remaining -= n
+	return out.Close()
+}
+
+// Save3DArchiveToTemp unpacks a 3D archive into a new temp object folder,
+// laid out like Save3DObjectToTemp and SaveMaterialsToTemp
+func (s *StorageService) Save3DArchiveToTemp(a *Archive3D) (string, map[string]string, string, error) {
+	imageID := uuid.New().String()
+	objectDir := filepath.Join(s.tempDir, imageID)
+	if err := os.MkdirAll(filepath.Join(objectDir, MaterialsDir), 0755); err != nil {
+		return "", nil, "", fmt.Errorf("failed to create object directory: %w", err)
+	}
+	fail := func(format string, args ...interface{}) (string, map[string]string, string, error) {
+		os.RemoveAll(objectDir)
+		return "", nil, "", fmt.Errorf(format, args...)
+	}
+
+	modelPath := filepath.Join(objectDir, "model"+path.Ext(a.Manifest.Model))
+	if err := a.extract(a.Manifest.Model, modelPath); err != nil {
+		return fail("failed to save model file: %w", err)
+	}
+
+	paths := make(map[string]string)
+	for _, view := range a.ViewNames() {
+		name := a.Manifest.Views[view]
+		viewPath := filepath.Join(objectDir, view+path.Ext(name))
+		if err := a.extract(name, viewPath); err != nil {
+			return fail("failed to save view %s: %w", view, err)
+		}
+		paths[view] = viewPath
+	}
+
+	for _, name := range a.Manifest.Materials {
+		materialPath := companionPath(modelPath, name)
+		if materialPath == "" {
+			return fail("invalid material file name: %s", name)
+		}
+		if err := a.extract(name, materialPath); err != nil {
+			return fail("failed to save material %s: %w", name, err)
+		}
+	}
+
+	return imageID, paths, modelPath, nil
+}
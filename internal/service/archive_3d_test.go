@@ -0,0 +1,161 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildZip returns a zip archive of the given name/content entries, in order
+func buildZip(t *testing.T, entries [][2]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		w, err := zw.Create(entry[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(entry[1]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func openTestArchive(t *testing.T, entries [][2]string) (*Archive3D, error) {
+	r := buildZip(t, entries)
+	return OpenArchive3D(r, r.Size(), 1<<20)
+}
+
+func TestOpenArchive3D_InferredLayout(t *testing.T) {
+	a, err := openTestArchive(t, [][2]string{
+		{"chair/", ""},
+		{"chair/chair.obj", "v 0 0 0\n"},
+		{"chair/chair.mtl", "newmtl wood\n"},
+		{"chair/textures/wood.png", "png"},
+		{"chair/renders/Front.png", "f"},
+		{"chair/renders/back.png", "b"},
+		{"chair/renders/left.jpg", "l"},
+		{"chair/renders/right.jpg", "r"},
+		{"chair/readme.txt", "ignored"},
+		{"__MACOSX/chair/._chair.obj", "ignored"},
+		{"chair/.DS_Store", "ignored"},
+	})
+	if err != nil {
+		t.Fatalf("OpenArchive3D: %v", err)
+	}
+
+	if a.Manifest.Model != "chair/chair.obj" {
+		t.Errorf("model = %q", a.Manifest.Model)
+	}
+	if got := a.ViewNames(); len(got) != 4 {
+		t.Errorf("views = %v, want 4-surface mode", got)
+	}
+	if a.Manifest.Views["front"] != "chair/renders/Front.png" || a.Manifest.Views["right"] != "chair/renders/right.jpg" {
+		t.Errorf("views = %v", a.Manifest.Views)
+	}
+	if len(a.Manifest.Materials) != 2 {
+		t.Errorf("materials = %v, want the .mtl and the texture", a.Manifest.Materials)
+	}
+}
+
+func TestOpenArchive3D_Manifest(t *testing.T) {
+	a, err := openTestArchive(t, [][2]string{
+		{"manifest.json", `{"model": "lod0.obj", "views": {"front": "a.png", "back": "b.png", "left": "c.png", "right": "d.png", "top": "e.png", "bottom": "f.png"}, "materials": ["lod0.mtl"]}`},
+		{"lod0.obj", "v 0 0 0\n"},
+		{"lod1.obj", "v 0 0 0\n"},
+		{"lod0.mtl", "newmtl wood\n"},
+		{"a.png", "1"}, {"b.png", "2"}, {"c.png", "3"}, {"d.png", "4"}, {"e.png", "5"}, {"f.png", "6"},
+	})
+	if err != nil {
+		t.Fatalf("OpenArchive3D: %v", err)
+	}
+	if a.Manifest.Model != "lod0.obj" || len(a.ViewNames()) != 6 || a.Manifest.Views["bottom"] != "f.png" {
+		t.Errorf("manifest = %+v", a.Manifest)
+	}
+}
+
+func TestOpenArchive3D_Invalid(t *testing.T) {
+	views := [][2]string{{"front.png", "f"}, {"back.png", "b"}, {"left.png", "l"}, {"right.png", "r"}}
+	with := func(entries ...[2]string) [][2]string {
+		return append(entries, views...)
+	}
+
+	tests := []struct {
+		name    string
+		entries [][2]string
+		want    string
+	}{
+		{"no model", views, "no model"},
+		{"two models", with([2]string{"a.obj", ""}, [2]string{"b.stl", ""}), "more than one model"},
+		{"missing views", [][2]string{{"a.obj", ""}, {"front.png", "f"}}, "needs the views"},
+		{"mismatched model", with([2]string{"a.glb", "not a glb"}), "does not match"},
+		{"manifest names a missing file", with([2]string{"a.obj", ""}, [2]string{"manifest.json",
+			`{"model": "b.obj", "views": {"front": "front.png", "back": "back.png", "left": "left.png", "right": "right.png"}}`}), "b.obj not found"},
+		{"duplicate material names", with([2]string{"a.obj", ""}, [2]string{"x/wood.png", ""}, [2]string{"y/wood.png", ""}), "more than one material"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openTestArchive(t, tt.entries)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	r := bytes.NewReader([]byte("not a zip"))
+	if _, err := OpenArchive3D(r, r.Size(), 1<<20); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("err = %v, want ErrInvalidArchive", err)
+	}
+}
+
+func TestOpenArchive3D_UnpackedSizeLimit(t *testing.T) {
+	r := buildZip(t, [][2]string{
+		{"a.obj", strings.Repeat("v 0 0 0\n", 1000)},
+		{"front.png", "f"}, {"back.png", "b"}, {"left.png", "l"}, {"right.png", "r"},
+	})
+	if _, err := OpenArchive3D(r, r.Size(), 1000); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("err = %v, want the unpacked size rejected", err)
+	}
+}
+
+func TestSave3DArchiveToTemp(t *testing.T) {
+	a, err := openTestArchive(t, [][2]string{
+		{"chair.obj", "mtllib chair.mtl\n"},
+		{"textures/chair.mtl", "newmtl wood\n"},
+		{"front.png", "f"}, {"back.png", "b"}, {"left.png", "l"}, {"right.png", "r"},
+	})
+	if err != nil {
+		t.Fatalf("OpenArchive3D: %v", err)
+	}
+
+	storage := NewStorageService(t.TempDir())
+	imageID, paths, modelPath, err := storage.Save3DArchiveToTemp(a)
+	if err != nil {
+		t.Fatalf("Save3DArchiveToTemp: %v", err)
+	}
+
+	objectDir := filepath.Join(storage.tempDir, imageID)
+	if modelPath != filepath.Join(objectDir, "model.obj") {
+		t.Errorf("model path = %s", modelPath)
+	}
+	if len(paths) != 4 || paths["left"] != filepath.Join(objectDir, "left.png") {
+		t.Errorf("view paths = %v", paths)
+	}
+	for path, want := range map[string]string{
+		modelPath:     "mtllib chair.mtl\n",
+		paths["back"]: "b",
+		filepath.Join(objectDir, MaterialsDir, "chair.mtl"): "newmtl wood\n",
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", path, data, err, want)
+		}
+	}
+}
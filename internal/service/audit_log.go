@@ -0,0 +1,107 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditRetentionDelete  = "retention.delete"
+	AuditLegalHoldSet     = "legal_hold.set"
+	AuditLegalHoldCleared = "legal_hold.cleared"
+	AuditLegalHoldBlocked = "legal_hold.blocked" // a deletion refused by a hold
+	AuditArtistExport     = "artist.export"
+	AuditArtistErasure    = "artist.erasure" // an erasure request, with its outcome
+	AuditErasureDelete    = "erasure.delete" // one image removed by an erasure
+)
+
+// AuditEntry records an action that must be accounted for later, e.g. a
+// deletion by a retention rule or a change of a legal hold
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	ImageID   string    `json:"image_id,omitempty"`
+	ProjectID string    `json:"project_id,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// AuditLog is an append-only log of audit entries, one JSON line each
+type AuditLog struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// Record appends an entry, synced to disk. A zero Time is set to now. A nil
+// log records nothing.
+func (l *AuditLog) Record(entry AuditEntry) error {
+	if l == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return file.Sync()
+}
+
+// Entries returns up to limit entries, newest first; with an action, only
+// entries of that action
+func (l *AuditLog) Entries(action string, limit int) ([]AuditEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return []AuditEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // torn line from a crash
+		}
+		if action == "" || entry.Action == action {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	newest := make([]AuditEntry, 0, min(limit, len(entries)))
+	for i := len(entries) - 1; i >= 0 && len(newest) < limit; i-- {
+		newest = append(newest, entries[i])
+	}
+	return newest, nil
+}
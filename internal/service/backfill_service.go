@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// ErrBackfillRunning is returned when a backfill is already in progress
+var ErrBackfillRunning = errors.New("backfill already running")
+
+// BackfillProgress is the persisted checkpoint of an embedding backfill
+type BackfillProgress struct {
+	JobID     string    `json:"job_id"`
+	State     string    `json:"state"` // running, completed, error, cancelled, interrupted
+	Total     int       `json:"total"`
+	Done      int       `json:"done"`
+	Skipped   int       `json:"skipped"` // already had an embedding
+	Failed    int       `json:"failed"`
+	LastError string    `json:"last_error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BackfillService computes embeddings for indexed images that don't have one.
+// Progress is checkpointed to disk and reported through the jobs API; a
+// backfill interrupted by a restart is resumed by ResumeIfInterrupted, and
+// images that already have embeddings are skipped so no work is repeated.
+type BackfillService struct {
+	indexService   *IndexService
+	embedder       Embedder
+	embeddings     *EmbeddingStore
+	imageService   *ImageService
+	storage        *StorageService // set when images are embedded from their thumbnails
+	checkpointPath string
+	interval       time.Duration // minimum spacing between AI calls
+	logger         *logrus.Logger
+
+	mutex     sync.Mutex
+	running   bool
+	cancel    context.CancelFunc
+	cancelled bool // by Cancel rather than by the server stopping
+	progress  BackfillProgress
+}
+
+// NewBackfillService creates a backfill service. ratePerMinute bounds the
+// number of embedding calls; zero means unlimited.
+func NewBackfillService(index *IndexService, embedder Embedder, embeddings *EmbeddingStore, image *ImageService, checkpointPath string, ratePerMinute int, logger *logrus.Logger) *BackfillService {
+	var interval time.Duration
+	if ratePerMinute > 0 {
+		interval = time.Minute / time.Duration(ratePerMinute)
+	}
+	return &BackfillService{
+		indexService:   index,
+		embedder:       embedder,
+		embeddings:     embeddings,
+		imageService:   image,
+		checkpointPath: checkpointPath,
+		interval:       interval,
+		logger:         logger,
+	}
+}
+
+// SetImageEmbeddings embeds each image's thumbnail alongside its text, for
+// an embedder with image embeddings enabled
+func (s *BackfillService) SetImageEmbeddings(storage *StorageService) {
+	s.storage = storage
+}
+
+// Start launches a backfill in the background
+func (s *BackfillService) Start(ctx context.Context) (BackfillProgress, error) {
+	return s.start(ctx, "")
+}
+
+// ResumeIfInterrupted restarts a backfill whose checkpoint shows it was still
+// running when the server stopped, or was stopped with it
+func (s *BackfillService) ResumeIfInterrupted(ctx context.Context) error {
+	checkpoint, err := s.loadCheckpoint()
+	if err != nil || checkpoint == nil || (checkpoint.State != "running" && checkpoint.State != "interrupted") {
+		return err
+	}
+
+	s.logger.Infof("Resuming interrupted embedding backfill %s (%d/%d done)", checkpoint.JobID, checkpoint.Done, checkpoint.Total)
+	_, err = s.start(ctx, checkpoint.JobID)
+	return err
+}
+
+// Cancel stops a running backfill; progress so far is kept
+func (s *BackfillService) Cancel() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return false
+	}
+	s.cancelled = true
+	s.cancel()
+	return true
+}
+
+// Progress returns the current or last backfill progress
+func (s *BackfillService) Progress() BackfillProgress {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.progress.JobID == "" {
+		if checkpoint, err := s.loadCheckpoint(); err == nil && checkpoint != nil {
+			return *checkpoint
+		}
+	}
+	return s.progress
+}
+
+func (s *BackfillService) start(ctx context.Context, jobID string) (BackfillProgress, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running {
+		return s.progress, ErrBackfillRunning
+	}
+
+	if jobID == "" {
+		jobID = "backfill-" + uuid.New().String()
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	s.running = true
+	s.cancel = cancel
+	s.cancelled = false
+	s.progress = BackfillProgress{
+		JobID:     jobID,
+		State:     "running",
+		StartedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	s.imageService.StartBackgroundJob(jobID, models.JobKindBackfill, "Embedding backfill")
+	if err := s.saveCheckpoint(s.progress); err != nil {
+		s.logger.Warnf("Failed to save backfill checkpoint: %v", err)
+	}
+
+	go s.run(runCtx)
+
+	return s.progress, nil
+}
+
+// run walks the index and embeds every image missing a vector
+func (s *BackfillService) run(ctx context.Context) {
+	err := s.backfill(ctx)
+
+	s.mutex.Lock()
+	switch {
+	case errors.Is(err, context.Canceled) && !s.cancelled:
+		// Stopped with the server (or its leadership): resumed on the next start
+		s.progress.State = "interrupted"
+	case errors.Is(err, context.Canceled):
+		s.progress.State = "cancelled"
+	case err != nil:
+		s.progress.State = "error"
+		s.progress.LastError = err.Error()
+	default:
+		s.progress.State = "completed"
+	}
+	s.progress.UpdatedAt = time.Now()
+	progress := s.progress
+	s.running = false
+	s.cancel()
+	s.mutex.Unlock()
+
+	if err := s.embeddings.Save(); err != nil {
+		s.logger.Errorf("Failed to save embeddings: %v", err)
+	}
+	if err := s.saveCheckpoint(progress); err != nil {
+		s.logger.Errorf("Failed to save backfill checkpoint: %v", err)
+	}
+
+	s.imageService.FinishBackgroundJob(progress.JobID, err)
+	s.logger.Infof("Embedding backfill %s finished: %s (%d embedded, %d skipped, %d failed)",
+		progress.JobID, progress.State, progress.Done, progress.Skipped, progress.Failed)
+}
+
+func (s *BackfillService) backfill(ctx context.Context) error {
+	images, err := s.indexService.GetAllImages()
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	s.updateProgress(func(p *BackfillProgress) { p.Total = len(images) })
+
+	var lastCall time.Time
+	for i, img := range images {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if s.embeddings.Has(img.ID) {
+			s.updateProgress(func(p *BackfillProgress) { p.Skipped++ })
+			continue
+		}
+
+		// Respect the AI rate limit
+		if wait := s.interval - time.Since(lastCall); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		lastCall = time.Now()
+
+		vec, err := s.embedder.Embed(ctx, s.embedInput(img))
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.logger.Warnf("Failed to embed image %s: %v", img.ID, err)
+			s.updateProgress(func(p *BackfillProgress) {
+				p.Failed++
+				p.LastError = err.Error()
+			})
+			continue
+		}
+
+		s.embeddings.Set(img.ID, vec)
+		s.updateProgress(func(p *BackfillProgress) { p.Done++ })
+
+		// Checkpoint periodically so a restart loses little work
+		if (i+1)%10 == 0 {
+			if err := s.embeddings.Save(); err != nil {
+				s.logger.Warnf("Failed to save embeddings: %v", err)
+			}
+			s.saveCheckpoint(s.Progress())
+		}
+	}
+
+	return nil
+}
+
+// embedInput returns what an image is embedded from: its text, and its
+// thumbnail with image embeddings
+func (s *BackfillService) embedInput(img *ImageMetadata) EmbedInput {
+	input := EmbedInput{Text: EmbeddingText(img)}
+	if s.storage != nil && img.ThumbnailPath != "" {
+		if path, err := s.storage.StoredPath(img.ThumbnailPath); err == nil {
+			input.ImagePath = path
+		}
+	}
+	return input
+}
+
+// updateProgress mutates the progress under lock and mirrors it to the jobs API
+func (s *BackfillService) updateProgress(fn func(p *BackfillProgress)) {
+	s.mutex.Lock()
+	fn(&s.progress)
+	s.progress.UpdatedAt = time.Now()
+	progress := s.progress
+	s.mutex.Unlock()
+
+	s.imageService.UpdateJobProgress(progress.JobID, models.JobProgress{
+		Total:   progress.Total,
+		Done:    progress.Done,
+		Skipped: progress.Skipped,
+		Failed:  progress.Failed,
+	})
+}
+
+func (s *BackfillService) loadCheckpoint() (*BackfillProgress, error) {
+	data, err := os.ReadFile(s.checkpointPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backfill checkpoint: %w", err)
+	}
+
+	var progress BackfillProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, fmt.Errorf("failed to parse backfill checkpoint: %w", err)
+	}
+	return &progress, nil
+}
+
+func (s *BackfillService) saveCheckpoint(progress BackfillProgress) error {
+	data, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backfill checkpoint: %w", err)
+	}
+	return os.WriteFile(s.checkpointPath, data, 0644)
+}
+
+// EmbeddingText builds the text that represents an image for embedding
+func EmbeddingText(img *ImageMetadata) string {
+	parts := []string{img.Title}
+	if img.Category != "" {
+		parts = append(parts, "Category: "+img.Category)
+	}
+	if img.Description != "" {
+		parts = append(parts, img.Description)
+	}
+	if len(img.Tags) > 0 {
+		parts = append(parts, "Tags: "+strings.Join(img.Tags, ", "))
+	}
+	return strings.Join(parts, "\n")
+}
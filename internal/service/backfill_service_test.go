@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+type mockEmbedder struct {
+	mutex sync.Mutex
+	calls []string
+	fail  map[string]bool
+}
+
+func (m *mockEmbedder) Embed(ctx context.Context, input EmbedInput) ([]float32, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.calls = append(m.calls, input.Text)
+	if m.fail[strings.SplitN(input.Text, "\n", 2)[0]] {
+		return nil, errors.New("quota exceeded")
+	}
+	return []float32{0.1, 0.2}, nil
+}
+
+func newTestBackfill(t *testing.T, embedder Embedder) (*BackfillService, *EmbeddingStore, *ImageService) {
+	t.Helper()
+	tempDir := t.TempDir()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	indexService := NewIndexService(tempDir)
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	for _, id := range []string{"img-001", "img-002", "img-003"} {
+		img := &models.Image{ID: id, Title: "Title " + id, Type: models.ImageType2D, UploadedAt: time.Now()}
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	store := NewEmbeddingStore(filepath.Join(tempDir, "embeddings.json"))
+	imageService := NewImageService(nil, nil, indexService, nil, logger)
+	backfill := NewBackfillService(indexService, embedder, store, imageService, filepath.Join(tempDir, "backfill.json"), 0, logger)
+	return backfill, store, imageService
+}
+
+func waitForBackfill(t *testing.T, svc *BackfillService) BackfillProgress {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if p := svc.Progress(); p.State != "running" {
+			return p
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("backfill did not finish in time")
+	return BackfillProgress{}
+}
+
+func TestBackfill_EmbedsMissingOnly(t *testing.T) {
+	embedder := &mockEmbedder{}
+	svc, store, imageService := newTestBackfill(t, embedder)
+
+	// img-002 already has a vector and must be skipped
+	store.Set("img-002", []float32{1})
+
+	started, err := svc.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	progress := waitForBackfill(t, svc)
+
+	if progress.State != "completed" {
+		t.Fatalf("expected completed, got %s", progress.State)
+	}
+	if progress.Done != 2 || progress.Skipped != 1 || progress.Total != 3 {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+	if len(embedder.calls) != 2 {
+		t.Errorf("expected 2 embedding calls, got %d", len(embedder.calls))
+	}
+	if store.Len() != 3 {
+		t.Errorf("expected 3 stored embeddings, got %d", store.Len())
+	}
+
+	// Visible through the jobs API
+	job, err := imageService.GetJob(started.JobID)
+	if err != nil {
+		t.Fatalf("backfill job not tracked: %v", err)
+	}
+	if job.Kind != models.JobKindBackfill || job.Progress == nil || job.Progress.Done != 2 {
+		t.Errorf("unexpected job info %+v", job)
+	}
+}
+
+func TestBackfill_EmbedsThumbnailsWithImageEmbeddings(t *testing.T) {
+	svc, _, _ := newTestBackfill(t, &mockEmbedder{})
+	img := &ImageMetadata{ID: "img-001", Title: "Cat", ThumbnailPath: "categories/animals/img-001_thumb.jpg"}
+	if input := svc.embedInput(img); input.ImagePath != "" || input.Text == "" {
+		t.Errorf("expected text only, got %+v", input)
+	}
+
+	dataDir := t.TempDir()
+	svc.SetImageEmbeddings(NewStorageService(dataDir))
+	input := svc.embedInput(img)
+	if input.ImagePath != filepath.Join(dataDir, "categories", "animals", "img-001_thumb.jpg") || input.Text != EmbeddingText(img) {
+		t.Errorf("expected the thumbnail with the text, got %+v", input)
+	}
+}
+
+func TestBackfill_CountsFailures(t *testing.T) {
+	embedder := &mockEmbedder{fail: map[string]bool{"Title img-001": true}}
+	svc, _, _ := newTestBackfill(t, embedder)
+
+	if _, err := svc.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	progress := waitForBackfill(t, svc)
+
+	if progress.Failed != 1 || progress.Done != 2 {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+	if progress.LastError == "" {
+		t.Error("expected last error to be recorded")
+	}
+}
+
+func TestBackfill_ResumeIfInterrupted(t *testing.T) {
+	embedder := &mockEmbedder{}
+	svc, store, _ := newTestBackfill(t, embedder)
+
+	// Simulate a checkpoint left by a backfill that was running at shutdown
+	if err := svc.saveCheckpoint(BackfillProgress{JobID: "backfill-old", State: "running", Done: 1}); err != nil {
+		t.Fatalf("saveCheckpoint failed: %v", err)
+	}
+
+	if err := svc.ResumeIfInterrupted(context.Background()); err != nil {
+		t.Fatalf("ResumeIfInterrupted failed: %v", err)
+	}
+	progress := waitForBackfill(t, svc)
+
+	if progress.JobID != "backfill-old" {
+		t.Errorf("expected resumed job to keep its ID, got %s", progress.JobID)
+	}
+	if store.Len() != 3 {
+		t.Errorf("expected 3 embeddings after resume, got %d", store.Len())
+	}
+}
+
+// waitForBackfillJob waits until a backfill has saved its checkpoint and
+// reported its outcome to the jobs API
+func waitForBackfillJob(t *testing.T, imageService *ImageService, jobID string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, err := imageService.GetJob(jobID); err == nil && job.FinishedAt != nil {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("backfill did not finish in time")
+}
+
+// blockingEmbedder waits for its context, like a call stuck in flight
+type blockingEmbedder struct {
+	started chan struct{}
+	once    sync.Once
+}
+
+func (b *blockingEmbedder) Embed(ctx context.Context, input EmbedInput) ([]float32, error) {
+	b.once.Do(func() { close(b.started) })
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestBackfill_ShutdownLeavesItResumable(t *testing.T) {
+	embedder := &blockingEmbedder{started: make(chan struct{})}
+	svc, _, imageService := newTestBackfill(t, embedder)
+	if err := svc.saveCheckpoint(BackfillProgress{JobID: "backfill-old", State: "running"}); err != nil {
+		t.Fatalf("saveCheckpoint failed: %v", err)
+	}
+
+	// The server stops while the resumed backfill runs
+	ctx, stop := context.WithCancel(context.Background())
+	if err := svc.ResumeIfInterrupted(ctx); err != nil {
+		t.Fatalf("ResumeIfInterrupted failed: %v", err)
+	}
+	<-embedder.started
+	stop()
+	waitForBackfillJob(t, imageService, "backfill-old")
+	if progress := svc.Progress(); progress.State != "interrupted" {
+		t.Fatalf("expected interrupted, got %s", progress.State)
+	}
+	if checkpoint, err := svc.loadCheckpoint(); err != nil || checkpoint.State != "interrupted" {
+		t.Fatalf("expected an interrupted checkpoint, got %+v (%v)", checkpoint, err)
+	}
+	if job, _ := imageService.GetJob("backfill-old"); job.State != models.JobStateCancelled || job.Error != "" {
+		t.Errorf("expected the job to be cancelled, got %+v", job)
+	}
+
+	// The next start resumes it; cancelling it by hand is final
+	embedder.started = make(chan struct{})
+	embedder.once = sync.Once{}
+	if err := svc.ResumeIfInterrupted(context.Background()); err != nil {
+		t.Fatalf("ResumeIfInterrupted failed: %v", err)
+	}
+	<-embedder.started
+	if !svc.Cancel() {
+		t.Fatal("expected the resumed backfill to be running")
+	}
+	waitForBackfillJob(t, imageService, "backfill-old")
+	if progress := svc.Progress(); progress.State != "cancelled" || progress.JobID != "backfill-old" {
+		t.Fatalf("expected backfill-old to be cancelled, got %+v", progress)
+	}
+	if checkpoint, _ := svc.loadCheckpoint(); checkpoint.State != "cancelled" {
+		t.Errorf("expected a cancelled checkpoint, got %s", checkpoint.State)
+	}
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// backupManifestFile is the manifest of a backup set, next to its files/
+const backupManifestFile = "manifest.json"
+
+// backupSetLayout names backup sets by creation time, so they sort by age
+const backupSetLayout = "20060102-150405.000"
+
+// ErrBackupNotFound is returned for an unknown backup set
+var ErrBackupNotFound = errors.New("backup set not found")
+
+// BackupFile is one data file of a backup set
+type BackupFile struct {
+	Path    string    `json:"path"` // relative to the data directory, slash-separated
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+	Set     string    `json:"set"` // backup set holding the content; earlier sets for unchanged files
+}
+
+// BackupManifest lists every file of the data directory at backup time
+type BackupManifest struct {
+	ID        string       `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	Base      string       `json:"base,omitempty"` // previous set an incremental backup builds on
+	Files     []BackupFile `json:"files"`
+	Copied    int          `json:"copied"`       // files stored in this set
+	Bytes     int64        `json:"copied_bytes"` // bytes stored in this set
+}
+
+// BackupService snapshots the data directory (index, metadata stores and
+// image files) into dated backup sets and restores them with verification.
+// Incremental sets only store files that changed since the previous set.
+type BackupService struct {
+	dataDir   string
+	backupDir string
+	logger    *logrus.Logger
+}
+
+func NewBackupService(dataDir, backupDir string, logger *logrus.Logger) *BackupService {
+	return &BackupService{
+		dataDir:   dataDir,
+		backupDir: backupDir,
+		logger:    logger,
+	}
+}
+
+// List returns the IDs of the backup sets, oldest first
+func (s *BackupService) List() ([]string, error) {
+	entries, err := os.ReadDir(s.backupDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(s.backupDir, entry.Name(), backupManifestFile)); err == nil {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Manifest reads the manifest of a backup set
+func (s *BackupService) Manifest(id string) (*BackupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(s.backupDir, id, backupManifestFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrBackupNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// Backup creates a new backup set. With incremental, files whose size and
+// modification time match the latest set are not copied again; the manifest
+// points at the set that holds them. The manifest is written last, so an
+// interrupted backup leaves no usable (listed) set.
+func (s *BackupService) Backup(ctx context.Context, incremental bool) (*BackupManifest, error) {
+	now := time.Now()
+	manifest := &BackupManifest{ID: now.Format(backupSetLayout), CreatedAt: now}
+	setDir := filepath.Join(s.backupDir, manifest.ID)
+	if _, err := os.Stat(setDir); err == nil {
+		return nil, fmt.Errorf("backup set %s already exists", manifest.ID)
+	}
+
+	previous := make(map[string]BackupFile)
+	if incremental {
+		ids, err := s.List()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) > 0 {
+			base, err := s.Manifest(ids[len(ids)-1])
+			if err != nil {
+				return nil, err
+			}
+			manifest.Base = base.ID
+			for _, f := range base.Files {
+				previous[f.Path] = f
+			}
+		}
+	}
+
+	paths, err := s.dataFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		src := filepath.Join(s.dataDir, filepath.FromSlash(rel))
+		info, err := os.Stat(src)
+		if err != nil {
+			// Deleted since the walk (e.g. a temp file promoted); skip it
+			s.logger.Warnf("Skipping %s: %v", rel, err)
+			continue
+		}
+
+		if prev, ok := previous[rel]; ok && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime()) {
+			manifest.Files = append(manifest.Files, prev)
+			continue
+		}
+
+		hash, err := copyFileHashed(src, filepath.Join(setDir, "files", filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", rel, err)
+		}
+		manifest.Files = append(manifest.Files, BackupFile{
+			Path:    rel,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			SHA256:  hash,
+			Set:     manifest.ID,
+		})
+		manifest.Copied++
+		manifest.Bytes += info.Size()
+	}
+
+	if err := os.MkdirAll(setDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup set: %w", err)
+	}
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := os.WriteFile(filepath.Join(setDir, backupManifestFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	s.logger.Infof("Backup %s: %d files, %d copied (%.1f MB)", manifest.ID, len(manifest.Files), manifest.Copied, float64(manifest.Bytes)/(1024*1024))
+	return manifest, nil
+}
+
+// Verify checks every file of a backup set (including those held by earlier
+// sets) against its recorded hash and returns the paths that don't match
+func (s *BackupService) Verify(ctx context.Context, id string) ([]string, error) {
+	manifest, err := s.Manifest(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []string
+	for _, f := range manifest.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hash, err := hashFile(s.storedPath(f))
+		if err != nil || hash != f.SHA256 {
+			failed = append(failed, f.Path)
+		}
+	}
+	return failed, nil
+}
+
+// Restore writes a backup set into targetDir. The whole set is verified
+// first, so a damaged set leaves the target untouched, and each file is
+// checked again as it is written. targetDir must be empty (or missing)
+// unless overwrite is set; stop the server before restoring over a live data
+// directory.
+func (s *BackupService) Restore(ctx context.Context, id, targetDir string, overwrite bool) error {
+	manifest, err := s.Manifest(id)
+	if err != nil {
+		return err
+	}
+
+	if entries, err := os.ReadDir(targetDir); err == nil && len(entries) > 0 && !overwrite {
+		return fmt.Errorf("target %s is not empty", targetDir)
+	}
+
+	failed, err := s.Verify(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("backup %s failed verification (%d files, e.g. %s)", id, len(failed), failed[0])
+	}
+
+	for _, f := range manifest.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		dst := filepath.Join(targetDir, filepath.FromSlash(f.Path))
+		tmp := dst + ".restore"
+		hash, err := copyFileHashed(s.storedPath(f), tmp)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", f.Path, err)
+		}
+		if hash != f.SHA256 {
+			os.Remove(tmp)
+			return fmt.Errorf("hash mismatch for %s in set %s", f.Path, f.Set)
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", f.Path, err)
+		}
+		os.Chtimes(dst, f.ModTime, f.ModTime)
+	}
+	s.logger.Infof("Restored backup %s: %d files into %s", id, len(manifest.Files), targetDir)
+	return nil
+}
+
+// storedPath returns where a backup file's content is stored
+func (s *BackupService) storedPath(f BackupFile) string {
+	return filepath.Join(s.backupDir, f.Set, "files", filepath.FromSlash(f.Path))
+}
+
+// dataFiles lists the files to back up, relative to the data directory.
+// Uploads still in temp/, partially written *.tmp files and a backup
+// directory inside the data directory are skipped.
+func (s *BackupService) dataFiles() ([]string, error) {
+	backupDir, _ := filepath.Abs(s.backupDir)
+	var paths []string
+	err := filepath.WalkDir(s.dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dataDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if abs, _ := filepath.Abs(path); rel == "temp" || abs == backupDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(rel, ".tmp") {
+			return nil
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data files: %w", err)
+	}
+
+	// Metadata first: files copied later can only be newer than the index
+	// that references them
+	sort.SliceStable(paths, func(i, j int) bool {
+		return isMetadataFile(paths[i]) && !isMetadataFile(paths[j])
+	})
+	return paths, nil
+}
+
+// isMetadataFile reports whether a data-relative path is metadata: a file at
+// the top of the data directory, or a shard of a sharded index
+func isMetadataFile(rel string) bool {
+	return !strings.Contains(rel, "/") || strings.HasPrefix(rel, indexShardDir+"/")
+}
+
+// copyFileHashed copies src to dst, creating directories, and returns the
+// SHA-256 of the content
+func copyFileHashed(src, dst string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), in); err != nil {
+		out.Close()
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashFile returns the SHA-256 of a file
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestBackupService_IncrementalAndRestore(t *testing.T) {
+	dataDir := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(dataDir, rel)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("index.md", "# Index\n")
+	write("categories/animals/cat.jpg", "cat")
+	write("temp/upload.jpg", "in progress")
+
+	backups := NewBackupService(dataDir, t.TempDir(), logrus.New())
+	ctx := context.Background()
+
+	full, err := backups.Backup(ctx, false)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if len(full.Files) != 2 || full.Copied != 2 || full.Files[0].Path != "index.md" {
+		t.Fatalf("expected index and image (not temp), index first, got %+v", full.Files)
+	}
+
+	// Only the changed index is copied again
+	time.Sleep(5 * time.Millisecond)
+	write("index.md", "# Index\n\n## Image: cat\n")
+	incr, err := backups.Backup(ctx, true)
+	if err != nil {
+		t.Fatalf("incremental Backup failed: %v", err)
+	}
+	if incr.Base != full.ID || incr.Copied != 1 || incr.Files[1].Set != full.ID {
+		t.Errorf("expected only the index copied, image held by %s, got %+v", full.ID, incr)
+	}
+
+	target := t.TempDir()
+	if err := backups.Restore(ctx, incr.ID, target, false); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	for rel, want := range map[string]string{"index.md": "# Index\n\n## Image: cat\n", "categories/animals/cat.jpg": "cat"} {
+		if got, _ := os.ReadFile(filepath.Join(target, rel)); string(got) != want {
+			t.Errorf("restored %s = %q, want %q", rel, got, want)
+		}
+	}
+	if err := backups.Restore(ctx, incr.ID, target, false); err == nil {
+		t.Error("expected an error restoring into a non-empty directory")
+	}
+
+	// A damaged file in the base set fails verification of the incremental
+	// set and blocks its restore
+	os.WriteFile(backups.storedPath(incr.Files[1]), []byte("dog"), 0644)
+	if failed, err := backups.Verify(ctx, incr.ID); err != nil || len(failed) != 1 {
+		t.Errorf("expected one mismatch, got %v, %v", failed, err)
+	}
+	if err := backups.Restore(ctx, incr.ID, t.TempDir(), false); err == nil {
+		t.Error("expected restore of a damaged set to fail")
+	}
+}
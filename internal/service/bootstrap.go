@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// DataLayoutVersion is the data directory layout this build reads and writes
+const DataLayoutVersion = 1
+
+// ErrDataDirTooNew is returned for a data directory written by a newer build
+var ErrDataDirTooNew = errors.New("data directory was written by a newer version")
+
+// dataMigration upgrades a data directory from version-1 to version
+type dataMigration struct {
+	version int
+	name    string
+	apply   func(dataDir string) error
+}
+
+// dataMigrations run in order on startup. Directories from before layout
+// versioning are version 0; their layout is version 1, so the first step
+// only creates what is missing.
+var dataMigrations = []dataMigration{
+	{version: 1, name: "create data layout", apply: createDataLayout},
+}
+
+func createDataLayout(dataDir string) error {
+	for _, dir := range []string{"categories", "temp"} {
+		if err := os.MkdirAll(filepath.Join(dataDir, dir), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// layoutState is layout.json in the data directory
+type layoutState struct {
+	Version    int       `json:"version"`
+	MigratedAt time.Time `json:"migrated_at"`
+	SeededAt   time.Time `json:"seeded_at,omitempty"` // demo images were queued
+}
+
+// Bootstrap prepares a data directory for the server: it creates the
+// layout, runs pending migrations and optionally seeds demo images, so a
+// container can start on an empty volume
+type Bootstrap struct {
+	dataDir   string
+	statePath string
+}
+
+// NewBootstrap creates a bootstrap for a data directory
+func NewBootstrap(dataDir string) *Bootstrap {
+	return &Bootstrap{dataDir: dataDir, statePath: filepath.Join(dataDir, "layout.json")}
+}
+
+func (b *Bootstrap) loadState() (layoutState, error) {
+	var state layoutState
+	data, err := os.ReadFile(b.statePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("invalid %s: %w", b.statePath, err)
+	}
+	return state, nil
+}
+
+func (b *Bootstrap) saveState(state layoutState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := b.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.statePath)
+}
+
+// Migrate creates the data directory if needed and brings it up to
+// DataLayoutVersion, returning the names of the migrations it applied. The
+// version is saved after every step, so an interrupted run resumes.
+func (b *Bootstrap) Migrate() ([]string, error) {
+	if err := os.MkdirAll(b.dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	state, err := b.loadState()
+	if err != nil {
+		return nil, err
+	}
+	if state.Version > DataLayoutVersion {
+		return nil, fmt.Errorf("%w (layout version %d, this build supports %d)", ErrDataDirTooNew, state.Version, DataLayoutVersion)
+	}
+
+	var applied []string
+	for _, m := range dataMigrations {
+		if m.version <= state.Version {
+			continue
+		}
+		if err := m.apply(b.dataDir); err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		state.Version = m.version
+		state.MigratedAt = time.Now().UTC()
+		if err := b.saveState(state); err != nil {
+			return applied, fmt.Errorf("failed to record migration %d: %w", m.version, err)
+		}
+		applied = append(applied, m.name)
+	}
+	return applied, nil
+}
+
+// seedExtensions are the image files picked up from a seed directory
+var seedExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true}
+
+// Seed queues the images in dir (not recursive) as demo uploads, tagged
+// "demo". It runs once per data directory, and only while the index is
+// empty, so restarts and real data are left alone. Returns the number of
+// images queued.
+func (b *Bootstrap) Seed(ctx context.Context, dir string, storage *StorageService, image *ImageService, index *IndexService) (int, error) {
+	state, err := b.loadState()
+	if err != nil {
+		return 0, err
+	}
+	if !state.SeededAt.IsZero() {
+		return 0, nil
+	}
+	images, err := index.GetImages(true)
+	if err != nil {
+		return 0, err
+	}
+	if len(images) > 0 {
+		return 0, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read seed directory: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && seedExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	queued := 0
+	for _, name := range names {
+		if err := seedImage(ctx, filepath.Join(dir, name), storage, image); err != nil {
+			return queued, fmt.Errorf("failed to seed %s: %w", name, err)
+		}
+		queued++
+	}
+
+	state.SeededAt = time.Now().UTC()
+	if err := b.saveState(state); err != nil {
+		return queued, err
+	}
+	return queued, nil
+}
+
+// seedImage queues one demo image, waiting while the job queue is full
+func seedImage(ctx context.Context, path string, storage *StorageService, image *ImageService) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	name := filepath.Base(path)
+	imageID, tempPath, err := storage.SaveImageToTemp(file, name)
+	if err != nil {
+		return err
+	}
+	job := &models.UploadJob{
+		ImageID:          imageID,
+		Type:             models.ImageType2D,
+		FilePath:         tempPath,
+		OriginalFilename: CleanFilename(name),
+		Title:            seedTitle(name),
+		Artist:           "Demo",
+		ManualTags:       []string{"demo"},
+		Priority:         models.PriorityLow,
+	}
+	for {
+		err := image.QueueJob(job)
+		if !errors.Is(err, ErrQueueFull) {
+			if err != nil {
+				storage.CleanupTemp(job)
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			storage.CleanupTemp(job)
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// seedTitle turns a file name like red_fox-2.jpg into "Red fox 2"
+func seedTitle(name string) string {
+	title := strings.TrimSuffix(name, filepath.Ext(name))
+	title = strings.TrimSpace(strings.NewReplacer("_", " ", "-", " ").Replace(title))
+	if title == "" {
+		return name
+	}
+	first, size := utf8.DecodeRuneInString(title)
+	return string(unicode.ToUpper(first)) + title[size:]
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestBootstrap_Migrate(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "data")
+	bootstrap := NewBootstrap(dataDir)
+
+	applied, err := bootstrap.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if len(applied) != len(dataMigrations) {
+		t.Errorf("expected every migration on a fresh directory, got %v", applied)
+	}
+	for _, dir := range []string{"categories", "temp"} {
+		if info, err := os.Stat(filepath.Join(dataDir, dir)); err != nil || !info.IsDir() {
+			t.Errorf("expected %s to be created", dir)
+		}
+	}
+
+	// Nothing left to do on the next start
+	if applied, err := bootstrap.Migrate(); err != nil || len(applied) != 0 {
+		t.Errorf("expected no migrations on restart, got %v (%v)", applied, err)
+	}
+
+	// A data directory from a newer build is refused
+	if err := os.WriteFile(filepath.Join(dataDir, "layout.json"), []byte(`{"version": 99}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bootstrap.Migrate(); !errors.Is(err, ErrDataDirTooNew) {
+		t.Errorf("expected ErrDataDirTooNew, got %v", err)
+	}
+}
+
+func TestBootstrap_Seed(t *testing.T) {
+	dataDir := t.TempDir()
+	bootstrap := NewBootstrap(dataDir)
+	if _, err := bootstrap.Migrate(); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	indexService := NewIndexService(dataDir)
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	storage := NewStorageService(dataDir)
+	imageService := NewImageService(storage, nil, indexService, nil, logrus.New())
+
+	seedDir := t.TempDir()
+	for _, name := range []string{"red_fox-2.jpg", "hill.PNG", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(seedDir, name), []byte("image"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Workers are not started, so the jobs stay queued for inspection
+	seeded, err := bootstrap.Seed(context.Background(), seedDir, storage, imageService, indexService)
+	if err != nil || seeded != 2 {
+		t.Fatalf("expected 2 seeded images, got %d (%v)", seeded, err)
+	}
+	if n := imageService.jobQueue.Len(); n != 2 {
+		t.Fatalf("expected 2 queued jobs, got %d", n)
+	}
+	first, _ := imageService.jobQueue.Pop()
+	second, _ := imageService.jobQueue.Pop()
+	if first.Title != "Hill" || second.Title != "Red fox 2" || second.Artist != "Demo" || second.ManualTags[0] != "demo" {
+		t.Errorf("unexpected seed jobs %+v, %+v", first, second)
+	}
+
+	// Seeding runs once per data directory
+	if seeded, err := bootstrap.Seed(context.Background(), seedDir, storage, imageService, indexService); err != nil || seeded != 0 {
+		t.Errorf("expected no seeding on restart, got %d (%v)", seeded, err)
+	}
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// bulkDeleteTokenTTL is how long a dry-run preview can be confirmed
+const bulkDeleteTokenTTL = 10 * time.Minute
+
+// maxBulkDeletePreview caps how many images a preview returns in full; the
+// count and ID list always cover every match
+const maxBulkDeletePreview = 100
+
+// ErrConfirmationToken is returned when a bulk delete is confirmed with a
+// token that is unknown, expired, already used, or issued for another
+// filter or caller
+var ErrConfirmationToken = errors.New("invalid or expired confirmation token")
+
+// BulkDeleteFilter selects the images a bulk delete removes. Set fields are
+// combined (an image must match all of them) and at least one is required.
+// Dates are YYYY-MM-DD and inclusive.
+type BulkDeleteFilter struct {
+	Category     string `json:"category,omitempty"`
+	Tag          string `json:"tag,omitempty"`
+	UploadedFrom string `json:"uploaded_from,omitempty"`
+	UploadedTo   string `json:"uploaded_to,omitempty"`
+	Query        string `json:"query,omitempty"`
+}
+
+// Validate checks that the filter selects something and its dates parse
+func (f BulkDeleteFilter) Validate() error {
+	if f.Category == "" && f.Tag == "" && f.UploadedFrom == "" && f.UploadedTo == "" && f.Query == "" {
+		return errors.New("filter must specify at least one of category, tag, uploaded_from, uploaded_to or query")
+	}
+	for _, date := range []string{f.UploadedFrom, f.UploadedTo} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("invalid date %q (use YYYY-MM-DD)", date)
+		}
+	}
+	if f.UploadedFrom != "" && f.UploadedTo != "" && f.UploadedFrom > f.UploadedTo {
+		return errors.New("uploaded_from must not be after uploaded_to")
+	}
+	return nil
+}
+
+// matches reports whether an image passes the index-based parts of the filter
+func (f BulkDeleteFilter) matches(img *ImageMetadata) bool {
+	if f.Category != "" && !img.InCategory(f.Category) {
+		return false
+	}
+	if f.Tag != "" {
+		found := false
+		for _, tag := range img.Tags {
+			if strings.EqualFold(tag, f.Tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.UploadedFrom != "" || f.UploadedTo != "" {
+		if len(img.UploadedAt) < len("2006-01-02") {
+			return false
+		}
+		day := img.UploadedAt[:len("2006-01-02")]
+		if f.UploadedFrom != "" && day < f.UploadedFrom {
+			return false
+		}
+		if f.UploadedTo != "" && day > f.UploadedTo {
+			return false
+		}
+	}
+	return true
+}
+
+// BulkDeletePreview is the result of a dry run: everything the filter
+// currently matches and the token that confirms deleting exactly those images
+type BulkDeletePreview struct {
+	Count             int
+	IDs               []string
+	Held              int              // matches on legal hold, which are kept
+	Images            []*ImageMetadata // first maxBulkDeletePreview matches
+	ConfirmationToken string           // empty when nothing matched
+	ExpiresAt         time.Time
+}
+
+// pendingDelete is a preview waiting for confirmation
+type pendingDelete struct {
+	filter    BulkDeleteFilter
+	actor     string
+	ids       []string
+	expiresAt time.Time
+}
+
+// BulkDeleteService deletes every image matching a filter in two steps: a
+// dry run that lists the matches and issues a single-use confirmation token,
+// and a confirmation that deletes exactly the previewed images in the
+// background. Progress is reported through the jobs API.
+type BulkDeleteService struct {
+	indexService *IndexService
+	imageService *ImageService
+	searcher     imageSearcher
+	popularity   *PopularityStore
+	favorites    *FavoriteStore
+	logger       *logrus.Logger
+
+	pending map[string]*pendingDelete
+	mutex   sync.Mutex
+}
+
+// NewBulkDeleteService creates a bulk delete service. popularity and
+// favorites may be nil.
+func NewBulkDeleteService(index *IndexService, image *ImageService, searcher imageSearcher, popularity *PopularityStore, favorites *FavoriteStore, logger *logrus.Logger) *BulkDeleteService {
+	return &BulkDeleteService{
+		indexService: index,
+		imageService: image,
+		searcher:     searcher,
+		popularity:   popularity,
+		favorites:    favorites,
+		logger:       logger,
+		pending:      make(map[string]*pendingDelete),
+	}
+}
+
+// Preview resolves a filter without deleting anything and returns the
+// matches with a confirmation token bound to the filter and actor
+func (s *BulkDeleteService) Preview(ctx context.Context, filter BulkDeleteFilter, actor string) (*BulkDeletePreview, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	images, err := s.resolve(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &BulkDeletePreview{
+		Count: len(images),
+		IDs:   make([]string, 0, len(images)),
+	}
+	for _, img := range images {
+		preview.IDs = append(preview.IDs, img.ID)
+		if s.imageService.HeldBy(img) != "" {
+			preview.Held++
+		}
+	}
+	if len(images) > maxBulkDeletePreview {
+		images = images[:maxBulkDeletePreview]
+	}
+	preview.Images = images
+	if preview.Count == 0 {
+		return preview, nil
+	}
+
+	token, err := newConfirmationToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	preview.ConfirmationToken = token
+	preview.ExpiresAt = now.Add(bulkDeleteTokenTTL)
+
+	s.mutex.Lock()
+	for key, p := range s.pending {
+		if now.After(p.expiresAt) {
+			delete(s.pending, key)
+		}
+	}
+	s.pending[token] = &pendingDelete{
+		filter:    filter,
+		actor:     actor,
+		ids:       preview.IDs,
+		expiresAt: preview.ExpiresAt,
+	}
+	s.mutex.Unlock()
+
+	return preview, nil
+}
+
+// Confirm redeems a confirmation token and deletes the previewed images in
+// the background, returning the job ID. The filter and actor must be the
+// ones the token was issued for. Images uploaded after the preview are never
+// deleted; images already gone or on legal hold are skipped.
+func (s *BulkDeleteService) Confirm(filter BulkDeleteFilter, token, actor string) (string, int, error) {
+	s.mutex.Lock()
+	p, ok := s.pending[token]
+	if ok {
+		// Single use, even when the check below fails
+		delete(s.pending, token)
+	}
+	s.mutex.Unlock()
+
+	if !ok || time.Now().After(p.expiresAt) || p.filter != filter || p.actor != actor {
+		return "", 0, ErrConfirmationToken
+	}
+
+	jobID := "bulk-delete-" + uuid.New().String()
+	s.imageService.StartBackgroundJob(jobID, models.JobKindBulkDelete, "Bulk delete")
+
+	go func() {
+		err := s.run(jobID, p.ids, actor)
+		s.imageService.FinishBackgroundJob(jobID, err)
+		if err != nil {
+			s.logger.Errorf("Bulk delete %s failed: %v", jobID, err)
+		}
+	}()
+
+	return jobID, len(p.ids), nil
+}
+
+func (s *BulkDeleteService) run(jobID string, ids []string, actor string) error {
+	progress := models.JobProgress{Total: len(ids)}
+	s.imageService.UpdateJobProgress(jobID, progress)
+
+	for _, id := range ids {
+		if err := s.imageService.DeleteImage(id, actor); err != nil && !errors.Is(err, ErrFilesRemain) {
+			if errors.Is(err, ErrImageNotFound) || errors.Is(err, ErrLegalHold) {
+				progress.Skipped++
+			} else {
+				s.logger.Warnf("Bulk delete %s: failed to delete %s: %v", jobID, id, err)
+				progress.Failed++
+			}
+		} else {
+			progress.Done++
+			if s.popularity != nil {
+				s.popularity.Forget(id)
+			}
+			if s.favorites != nil {
+				if err := s.favorites.Forget(id); err != nil {
+					s.logger.Warnf("Bulk delete %s: failed to drop favorites of %s: %v", jobID, id, err)
+				}
+			}
+		}
+		s.imageService.UpdateJobProgress(jobID, progress)
+	}
+
+	s.logger.Infof("Bulk delete %s by %s finished: %d deleted, %d skipped, %d failed", jobID, actor, progress.Done, progress.Skipped, progress.Failed)
+	if progress.Failed > 0 {
+		return fmt.Errorf("%d of %d images failed to delete", progress.Failed, progress.Total)
+	}
+	return nil
+}
+
+// resolve returns the images matching every part of the filter, in search
+// order when a query is given and index order otherwise
+func (s *BulkDeleteService) resolve(ctx context.Context, filter BulkDeleteFilter) ([]*ImageMetadata, error) {
+	all, err := s.indexService.GetAllImages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	if filter.Query == "" {
+		var images []*ImageMetadata
+		for _, img := range all {
+			if filter.matches(img) {
+				images = append(images, img)
+			}
+		}
+		return images, nil
+	}
+
+	if s.searcher == nil {
+		return nil, errors.New("search is not available")
+	}
+	resp, err := s.searcher.Search(ctx, filter.Query, maxBulkSearchResults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve query: %w", err)
+	}
+
+	byID := make(map[string]*ImageMetadata, len(all))
+	for _, img := range all {
+		byID[img.ID] = img
+	}
+	var images []*ImageMetadata
+	for _, result := range resp.Results {
+		if img, ok := byID[result.ImageID]; ok && filter.matches(img) {
+			images = append(images, img)
+			delete(byID, result.ImageID)
+		}
+	}
+	return images, nil
+}
+
+// newConfirmationToken returns a random token for confirming a bulk delete
+func newConfirmationToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate confirmation token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func newTestBulkDelete(t *testing.T, searcher imageSearcher) (*BulkDeleteService, *IndexService, *ImageService, *FavoriteStore) {
+	t.Helper()
+	logger := logrus.New()
+	dataDir := t.TempDir()
+	indexService := NewIndexService(dataDir)
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	jan := time.Date(2025, 1, 15, 10, 0, 0, 0, time.Local)
+	mar := time.Date(2025, 3, 2, 10, 0, 0, 0, time.Local)
+	images := []*models.Image{
+		{ID: "a", Title: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: jan, ManualTags: []string{"Draft"}},
+		{ID: "b", Title: "B", Category: "animals/cats", Type: models.ImageType2D, UploadedAt: mar, ManualTags: []string{"draft"}},
+		{ID: "c", Title: "C", Category: "animals", Type: models.ImageType2D, UploadedAt: mar},
+		{ID: "d", Title: "D", Category: "landscape", Type: models.ImageType2D, UploadedAt: mar, ManualTags: []string{"draft"}},
+	}
+	for _, img := range images {
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	favorites := NewFavoriteStore(filepath.Join(dataDir, "favorites.json"))
+	imageService := NewImageService(NewStorageService(dataDir), nil, indexService, nil, logger)
+	return NewBulkDeleteService(indexService, imageService, searcher, nil, favorites, logger), indexService, imageService, favorites
+}
+
+func TestBulkDelete_PreviewThenConfirm(t *testing.T) {
+	svc, index, image, favorites := newTestBulkDelete(t, nil)
+	favorites.Add("alice", "b")
+
+	filter := BulkDeleteFilter{Category: "animals", Tag: "draft"}
+	preview, err := svc.Preview(context.Background(), filter, "alice")
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if preview.Count != 2 || len(preview.IDs) != 2 || preview.IDs[0] != "a" || preview.IDs[1] != "b" || preview.ConfirmationToken == "" {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+	if images, _ := index.GetAllImages(); len(images) != 4 {
+		t.Fatalf("dry run must not delete anything, %d images left", len(images))
+	}
+
+	// An image matching the filter after the preview is not deleted
+	index.AppendToIndex(&models.Image{ID: "e", Title: "E", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), ManualTags: []string{"draft"}})
+
+	jobID, count, err := svc.Confirm(filter, preview.ConfirmationToken, "alice")
+	if err != nil || count != 2 {
+		t.Fatalf("Confirm failed: %v (count %d)", err, count)
+	}
+	job := waitForJob(t, image, jobID)
+	if job.State != models.JobStateCompleted || job.Kind != models.JobKindBulkDelete || job.Progress == nil || job.Progress.Done != 2 {
+		t.Fatalf("unexpected job result %+v", job)
+	}
+
+	for id, want := range map[string]bool{"a": false, "b": false, "c": true, "d": true, "e": true} {
+		if _, err := index.GetImageByID(id); (err == nil) != want {
+			t.Errorf("image %s: expected present=%v, got err %v", id, want, err)
+		}
+	}
+	if len(favorites.List("alice")) != 0 {
+		t.Error("expected deleted images to leave favorites")
+	}
+
+	// Tokens are single use
+	if _, _, err := svc.Confirm(filter, preview.ConfirmationToken, "alice"); !errors.Is(err, ErrConfirmationToken) {
+		t.Errorf("expected a used token to be rejected, got %v", err)
+	}
+}
+
+func TestBulkDelete_TokenBoundToFilterAndActor(t *testing.T) {
+	svc, index, _, _ := newTestBulkDelete(t, nil)
+	filter := BulkDeleteFilter{UploadedFrom: "2025-03-01", UploadedTo: "2025-03-31"}
+
+	preview, err := svc.Preview(context.Background(), filter, "alice")
+	if err != nil || preview.Count != 3 {
+		t.Fatalf("unexpected preview %+v (%v)", preview, err)
+	}
+	if _, _, err := svc.Confirm(BulkDeleteFilter{Category: "animals"}, preview.ConfirmationToken, "alice"); !errors.Is(err, ErrConfirmationToken) {
+		t.Errorf("expected a different filter to be rejected, got %v", err)
+	}
+
+	preview, _ = svc.Preview(context.Background(), filter, "alice")
+	if _, _, err := svc.Confirm(filter, preview.ConfirmationToken, "bob"); !errors.Is(err, ErrConfirmationToken) {
+		t.Errorf("expected a different actor to be rejected, got %v", err)
+	}
+
+	preview, _ = svc.Preview(context.Background(), filter, "alice")
+	svc.pending[preview.ConfirmationToken].expiresAt = time.Now().Add(-time.Second)
+	if _, _, err := svc.Confirm(filter, preview.ConfirmationToken, "alice"); !errors.Is(err, ErrConfirmationToken) {
+		t.Errorf("expected an expired token to be rejected, got %v", err)
+	}
+
+	if images, _ := index.GetAllImages(); len(images) != 4 {
+		t.Errorf("rejected confirmations must not delete anything, %d images left", len(images))
+	}
+}
+
+func TestBulkDelete_Filters(t *testing.T) {
+	svc, _, _, _ := newTestBulkDelete(t, &mockSearcher{ids: []string{"d", "b", "missing"}})
+
+	tests := []struct {
+		name   string
+		filter BulkDeleteFilter
+		want   []string
+	}{
+		{"tag ignores case", BulkDeleteFilter{Tag: "DRAFT"}, []string{"a", "b", "d"}},
+		{"date range", BulkDeleteFilter{UploadedTo: "2025-01-31"}, []string{"a"}},
+		{"query in search order", BulkDeleteFilter{Query: "draft"}, []string{"d", "b"}},
+		{"query and category", BulkDeleteFilter{Query: "draft", Category: "animals"}, []string{"b"}},
+		{"no match", BulkDeleteFilter{Category: "portraits"}, nil},
+	}
+	for _, tt := range tests {
+		preview, err := svc.Preview(context.Background(), tt.filter, "alice")
+		if err != nil {
+			t.Errorf("%s: Preview failed: %v", tt.name, err)
+			continue
+		}
+		if len(preview.IDs) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, preview.IDs)
+			continue
+		}
+		for i := range tt.want {
+			if preview.IDs[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, preview.IDs)
+				break
+			}
+		}
+		if (preview.ConfirmationToken == "") != (len(tt.want) == 0) {
+			t.Errorf("%s: expected a token only when something matched", tt.name)
+		}
+	}
+
+	for _, filter := range []BulkDeleteFilter{
+		{},
+		{UploadedFrom: "15/01/2025"},
+		{UploadedFrom: "2025-03-01", UploadedTo: "2025-01-01"},
+	} {
+		if _, err := svc.Preview(context.Background(), filter, "alice"); err == nil {
+			t.Errorf("expected filter %+v to be rejected", filter)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// maxBulkSearchResults caps how many search hits a bulk update may target
+const maxBulkSearchResults = 1000
+
+// imageSearcher resolves a search query to matching images; satisfied by SearchService
+type imageSearcher interface {
+	Search(ctx context.Context, query string, limit int) (*models.SearchResponse, error)
+}
+
+// BulkFilter selects the images a bulk update applies to. Exactly one of
+// IDs, Category or Query must be set.
+type BulkFilter struct {
+	IDs      []string `json:"ids,omitempty"`
+	Category string   `json:"category,omitempty"`
+	Query    string   `json:"query,omitempty"`
+}
+
+// BulkOperations are the changes applied to every selected image
+type BulkOperations struct {
+	AddTags       []string `json:"add_tags,omitempty"`
+	RemoveTags    []string `json:"remove_tags,omitempty"`
+	SetArtist     *string  `json:"set_artist,omitempty"`
+	SetVisibility *string  `json:"set_visibility,omitempty"`
+}
+
+// BulkUpdateRequest is a filter plus the operations to apply
+type BulkUpdateRequest struct {
+	Filter     BulkFilter     `json:"filter"`
+	Operations BulkOperations `json:"operations"`
+}
+
+// Validate checks that the request selects images and changes something
+func (r *BulkUpdateRequest) Validate() error {
+	set := 0
+	if len(r.Filter.IDs) > 0 {
+		set++
+	}
+	if r.Filter.Category != "" {
+		set++
+	}
+	if r.Filter.Query != "" {
+		set++
+	}
+	if set != 1 {
+		return errors.New("filter must specify exactly one of ids, category or query")
+	}
+
+	ops := r.Operations
+	if len(ops.AddTags) == 0 && len(ops.RemoveTags) == 0 && ops.SetArtist == nil && ops.SetVisibility == nil {
+		return errors.New("at least one operation is required")
+	}
+	if ops.SetArtist != nil && strings.TrimSpace(*ops.SetArtist) == "" {
+		return errors.New("set_artist cannot be empty")
+	}
+	if ops.SetVisibility != nil && !models.IsValidVisibility(*ops.SetVisibility) {
+		return errors.New("set_visibility must be public or private")
+	}
+	return nil
+}
+
+// update converts the operations to a per-image update
+func (o BulkOperations) update() ImageUpdate {
+	return ImageUpdate{
+		Artist:     o.SetArtist,
+		Visibility: o.SetVisibility,
+		AddTags:    o.AddTags,
+		RemoveTags: o.RemoveTags,
+	}
+}
+
+// BulkUpdateService applies metadata operations to many images in the
+// background. Progress is reported through the jobs API.
+type BulkUpdateService struct {
+	indexService *IndexService
+	imageService *ImageService
+	searcher     imageSearcher
+	logger       *logrus.Logger
+}
+
+// NewBulkUpdateService creates a bulk update service
+func NewBulkUpdateService(index *IndexService, image *ImageService, searcher imageSearcher, logger *logrus.Logger) *BulkUpdateService {
+	return &BulkUpdateService{
+		indexService: index,
+		imageService: image,
+		searcher:     searcher,
+		logger:       logger,
+	}
+}
+
+// Start validates a bulk update and runs it in the background, returning the job ID
+func (s *BulkUpdateService) Start(req BulkUpdateRequest) (string, error) {
+	if err := req.Validate(); err != nil {
+		return "", err
+	}
+
+	jobID := "bulk-" + uuid.New().String()
+	s.imageService.StartBackgroundJob(jobID, models.JobKindBulkEdit, "Bulk metadata update")
+
+	go func() {
+		err := s.run(context.Background(), jobID, req)
+		s.imageService.FinishBackgroundJob(jobID, err)
+		if err != nil {
+			s.logger.Errorf("Bulk update %s failed: %v", jobID, err)
+		}
+	}()
+
+	return jobID, nil
+}
+
+func (s *BulkUpdateService) run(ctx context.Context, jobID string, req BulkUpdateRequest) error {
+	ids, err := s.resolve(ctx, req.Filter)
+	if err != nil {
+		return err
+	}
+
+	progress := models.JobProgress{Total: len(ids)}
+	s.imageService.UpdateJobProgress(jobID, progress)
+
+	update := req.Operations.update()
+	update.Actor = jobID
+	// One rewrite of the index for the whole batch
+	updated, skipped, err := s.imageService.UpdateImages(ids, update)
+	progress.Done = len(updated)
+	for id, reason := range skipped {
+		if errors.Is(reason, ErrImageNotFound) {
+			progress.Skipped++
+		} else {
+			s.logger.Warnf("Bulk update %s: failed to update %s: %v", jobID, id, reason)
+			progress.Failed++
+		}
+	}
+	if err != nil {
+		progress.Failed = progress.Total - progress.Done - progress.Skipped
+	}
+	s.imageService.UpdateJobProgress(jobID, progress)
+	if err != nil {
+		return fmt.Errorf("failed to update images: %w", err)
+	}
+
+	s.logger.Infof("Bulk update %s finished: %d updated, %d skipped, %d failed", jobID, progress.Done, progress.Skipped, progress.Failed)
+	if progress.Failed > 0 {
+		return fmt.Errorf("%d of %d images failed to update", progress.Failed, progress.Total)
+	}
+	return nil
+}
+
+// resolve turns a filter into the list of image IDs to update
+func (s *BulkUpdateService) resolve(ctx context.Context, filter BulkFilter) ([]string, error) {
+	switch {
+	case len(filter.IDs) > 0:
+		return filter.IDs, nil
+
+	case filter.Category != "":
+		images, err := s.indexService.GetAllImages()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read index: %w", err)
+		}
+		var ids []string
+		for _, img := range images {
+			if img.InCategory(filter.Category) {
+				ids = append(ids, img.ID)
+			}
+		}
+		return ids, nil
+
+	default:
+		if s.searcher == nil {
+			return nil, errors.New("search is not available")
+		}
+		resp, err := s.searcher.Search(ctx, filter.Query, maxBulkSearchResults)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve query: %w", err)
+		}
+		ids := make([]string, 0, len(resp.Results))
+		for _, result := range resp.Results {
+			ids = append(ids, result.ImageID)
+		}
+		return ids, nil
+	}
+}
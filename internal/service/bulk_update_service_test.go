@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+type mockSearcher struct {
+	ids []string
+}
+
+func (m *mockSearcher) Search(ctx context.Context, query string, limit int) (*models.SearchResponse, error) {
+	resp := &models.SearchResponse{Query: query}
+	for _, id := range m.ids {
+		resp.Results = append(resp.Results, models.SearchResult{ImageID: id})
+	}
+	return resp, nil
+}
+
+func newTestBulkUpdate(t *testing.T, searcher imageSearcher) (*BulkUpdateService, *IndexService, *ImageService) {
+	t.Helper()
+	logger := logrus.New()
+	indexService := NewIndexService(t.TempDir())
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	images := []*models.Image{
+		{ID: "a", Title: "A", Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), ManualTags: []string{"draft", "cat"}},
+		{ID: "b", Title: "B", Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "c", Title: "C", Artist: "Alice", Category: "landscape", Type: models.ImageType2D, UploadedAt: time.Now()},
+	}
+	for _, img := range images {
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	imageService := NewImageService(nil, nil, indexService, nil, logger)
+	return NewBulkUpdateService(indexService, imageService, searcher, logger), indexService, imageService
+}
+
+func waitForJob(t *testing.T, image *ImageService, jobID string) models.JobInfo {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, err := image.GetJob(jobID); err == nil && job.State != models.JobStateRunning {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", jobID)
+	return models.JobInfo{}
+}
+
+func TestBulkUpdate_ByCategory(t *testing.T) {
+	svc, index, image := newTestBulkUpdate(t, nil)
+
+	artist := "Studio"
+	private := models.VisibilityPrivate
+	jobID, err := svc.Start(BulkUpdateRequest{
+		Filter:     BulkFilter{Category: "animals"},
+		Operations: BulkOperations{AddTags: []string{"reviewed"}, RemoveTags: []string{"draft"}, SetArtist: &artist, SetVisibility: &private},
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	job := waitForJob(t, image, jobID)
+	if job.State != models.JobStateCompleted || job.Progress == nil || job.Progress.Done != 2 {
+		t.Fatalf("unexpected job result %+v", job)
+	}
+
+	a, _ := index.GetImageByID("a")
+	if a.Artist != "Studio" || a.Visibility != models.VisibilityPrivate || len(a.Tags) != 2 || a.Tags[0] != "cat" || a.Tags[1] != "reviewed" {
+		t.Errorf("unexpected result for a: %+v", a)
+	}
+	if a.Revision != 2 {
+		t.Errorf("expected revision 2, got %d", a.Revision)
+	}
+
+	c, _ := index.GetImageByID("c")
+	if c.Artist != "Alice" || c.Visibility != models.VisibilityPublic {
+		t.Errorf("image outside the filter was changed: %+v", c)
+	}
+}
+
+func TestBulkUpdate_ByQuerySkipsMissing(t *testing.T) {
+	svc, index, image := newTestBulkUpdate(t, &mockSearcher{ids: []string{"c", "missing"}})
+
+	jobID, err := svc.Start(BulkUpdateRequest{
+		Filter:     BulkFilter{Query: "mountains"},
+		Operations: BulkOperations{AddTags: []string{"outdoor"}},
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	job := waitForJob(t, image, jobID)
+	if job.Progress.Done != 1 || job.Progress.Skipped != 1 {
+		t.Errorf("expected 1 done and 1 skipped, got %+v", job.Progress)
+	}
+
+	c, _ := index.GetImageByID("c")
+	if len(c.Tags) != 1 || c.Tags[0] != "outdoor" {
+		t.Errorf("unexpected tags %v", c.Tags)
+	}
+}
+
+func TestBulkUpdateRequest_Validate(t *testing.T) {
+	empty := ""
+	bad := "hidden"
+	tests := []struct {
+		name string
+		req  BulkUpdateRequest
+	}{
+		{"no filter", BulkUpdateRequest{Operations: BulkOperations{AddTags: []string{"x"}}}},
+		{"two filters", BulkUpdateRequest{Filter: BulkFilter{IDs: []string{"a"}, Category: "animals"}, Operations: BulkOperations{AddTags: []string{"x"}}}},
+		{"no operations", BulkUpdateRequest{Filter: BulkFilter{IDs: []string{"a"}}}},
+		{"empty artist", BulkUpdateRequest{Filter: BulkFilter{IDs: []string{"a"}}, Operations: BulkOperations{SetArtist: &empty}}},
+		{"bad visibility", BulkUpdateRequest{Filter: BulkFilter{IDs: []string{"a"}}, Operations: BulkOperations{SetVisibility: &bad}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
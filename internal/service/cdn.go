@@ -0,0 +1,133 @@
+package service
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EventCDNPurge is posted to CDN_PURGE_URL when files a CDN may have cached
+// are replaced or deleted
+const EventCDNPurge = "cdn.purge"
+
+// Reasons of a CDN purge
+const (
+	PurgeImageDeleted     = "image_deleted"
+	PurgeRenditionChanged = "rendition_replaced"
+	PurgeRenditionDeleted = "rendition_deleted"
+)
+
+// cdnBaseURL is the CDN data files are linked at; empty links them on this
+// server. It is set once at startup.
+var cdnBaseURL string
+
+// SetCDNBaseURL links data files at a CDN serving this server's /data/
+// instead of at this server. Call it once at startup, before serving.
+func SetCDNBaseURL(baseURL string) {
+	cdnBaseURL = strings.TrimRight(baseURL, "/")
+}
+
+// CDNBaseURL returns the CDN data files are linked at, empty if none
+func CDNBaseURL() string {
+	return cdnBaseURL
+}
+
+// DataURL returns the URL a data-relative path is linked at: /data/<path>
+// on this server, or on the CDN
+func DataURL(relPath string) string {
+	return cdnBaseURL + "/data/" + relPath
+}
+
+// IsRendition reports whether a data path is a rendition derived from an
+// original (thumbnails, cutouts, upscales, turntables, sprite sheets),
+// rather than an uploaded file
+func IsRendition(relPath string) bool {
+	if IsPreviewRendition(relPath) {
+		return true
+	}
+	base := path.Base(relPath)
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	return strings.HasSuffix(base, "_cutout.png") || strings.HasSuffix(stem, "_x2") || strings.HasSuffix(stem, "_x4")
+}
+
+// CDNPurge is the data of a cdn.purge event
+type CDNPurge struct {
+	ImageID  string   `json:"image_id"`
+	Reason   string   `json:"reason"`
+	Paths    []string `json:"paths"`              // data-relative paths
+	URLs     []string `json:"urls"`               // the paths as linked
+	Prefixes []string `json:"prefixes,omitempty"` // folders whose every file goes (3D objects)
+}
+
+// CDNPurger asks a CDN to drop its cached copies of files that were
+// replaced or deleted, by posting a cdn.purge event to a webhook (e.g. a
+// small function calling the CDN's purge API). Delivery is asynchronous and
+// best effort; cached copies still expire with their max-age.
+type CDNPurger struct {
+	notifier *WebhookNotifier
+}
+
+// NewCDNPurger creates a purger posting to purgeURL; an empty URL disables
+// purging
+func NewCDNPurger(purgeURL string, logger *logrus.Logger) *CDNPurger {
+	return &CDNPurger{notifier: NewWebhookNotifier(purgeURL, logger)}
+}
+
+// Purge requests the purge of an image's files; paths ending in / are
+// folders
+func (p *CDNPurger) Purge(imageID, reason string, paths ...string) {
+	if p == nil {
+		return
+	}
+	purge := CDNPurge{ImageID: imageID, Reason: reason, Paths: []string{}, URLs: []string{}}
+	for _, relPath := range paths {
+		switch {
+		case relPath == "":
+		case strings.HasSuffix(relPath, "/"):
+			purge.Prefixes = append(purge.Prefixes, dataURLEscaped(relPath))
+		default:
+			purge.Paths = append(purge.Paths, relPath)
+			purge.URLs = append(purge.URLs, dataURLEscaped(relPath))
+		}
+	}
+	if len(purge.Paths) == 0 && len(purge.Prefixes) == 0 {
+		return
+	}
+	p.notifier.Notify(EventCDNPurge, purge)
+}
+
+// dataURLEscaped is DataURL with the path escaped, as links carry it
+func dataURLEscaped(relPath string) string {
+	return cdnBaseURL + (&url.URL{Path: "/data/" + relPath}).EscapedPath()
+}
+
+// SetCDNPurger purges the files of deleted images and replaced renditions
+// from the CDN
+func (s *ImageService) SetCDNPurger(purger *CDNPurger) {
+	s.cdnPurger = purger
+}
+
+// PurgeCDN requests the purge of an image's files from the CDN, if one is
+// set
+func (s *ImageService) PurgeCDN(imageID, reason string, paths ...string) {
+	s.cdnPurger.Purge(imageID, reason, paths...)
+}
+
+// purgeDeleted purges the deleted files of an image. A 3D object's folder
+// is purged whole, along with its files the index names.
+func (s *ImageService) purgeDeleted(deleted *ImageMetadata, paths []string) {
+	var purged []string
+	for _, p := range paths {
+		if p == deleted.FolderPath && p != "" {
+			purged = append(purged, strings.TrimSuffix(p, "/")+"/", deleted.ModelFilePath, deleted.ThumbnailPath, deleted.TurntablePath)
+			for _, view := range deleted.Views {
+				purged = append(purged, view)
+			}
+			continue
+		}
+		purged = append(purged, p)
+	}
+	s.PurgeCDN(deleted.ID, PurgeImageDeleted, purged...)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestCDNPurger_PurgesDeletedImageFiles(t *testing.T) {
+	events := make(chan WebhookEvent, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event WebhookEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer server.Close()
+
+	SetCDNBaseURL("https://cdn.example.com/")
+	defer SetCDNBaseURL("")
+
+	dataDir := t.TempDir()
+	logger := logrus.New()
+	storage := NewStorageService(dataDir)
+	index := NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	img := &models.Image{ID: "cat", Title: "Cat", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		FilePath: "categories/animals/my cat.jpg", ThumbnailPath: "categories/animals/my cat_thumb.jpg"}
+	if err := index.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	os.MkdirAll(filepath.Join(dataDir, "categories", "animals"), 0755)
+	for _, rel := range []string{img.FilePath, img.ThumbnailPath} {
+		if err := os.WriteFile(filepath.Join(dataDir, rel), []byte("jpeg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	imageService := NewImageService(storage, nil, index, nil, logger)
+	imageService.SetCDNPurger(NewCDNPurger(server.URL, logger))
+	if err := imageService.DeleteImage("cat", "test"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+
+	select {
+	case event := <-events:
+		data, _ := json.Marshal(event.Data)
+		var purge CDNPurge
+		json.Unmarshal(data, &purge)
+		if event.Event != EventCDNPurge || purge.ImageID != "cat" || purge.Reason != PurgeImageDeleted {
+			t.Errorf("unexpected event %s %+v", event.Event, purge)
+		}
+		if !strings.Contains(strings.Join(purge.Paths, ","), "categories/animals/my cat_thumb.jpg") {
+			t.Errorf("expected the thumbnail to be purged, got %v", purge.Paths)
+		}
+		if len(purge.URLs) == 0 || purge.URLs[0] != "https://cdn.example.com/data/categories/animals/my%20cat.jpg" {
+			t.Errorf("expected CDN URLs as linked, got %v", purge.URLs)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("purge webhook was not called")
+	}
+
+	if got := DataURL("categories/a/b_thumb.jpg"); got != "https://cdn.example.com/data/categories/a/b_thumb.jpg" {
+		t.Errorf("unexpected CDN data URL %s", got)
+	}
+}
+
+func TestIsRendition(t *testing.T) {
+	for path, want := range map[string]bool{
+		"categories/a/cat.jpg":           false,
+		"categories/a/cat_thumb.jpg":     true,
+		"categories/a/cat_square.jpg":    true,
+		"categories/a/cat_cutout.png":    true,
+		"categories/a/cat_x2.jpg":        true,
+		"categories/a/cat_x4.png":        true,
+		"categories/a/obj/model.glb":     false,
+		"categories/a/obj/turntable.gif": true,
+	} {
+		if got := IsRendition(path); got != want {
+			t.Errorf("IsRendition(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,524 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/disintegration/imaging"
+)
+
+// iccJPEGMarker prefixes every APP2 segment that carries an ICC profile chunk
+var iccJPEGMarker = []byte("ICC_PROFILE\x00")
+
+// maxICCChunk is the largest profile chunk that fits in one APP2 segment
+const maxICCChunk = 65535 - 2 - 14
+
+// xyzD50ToSRGB converts PCS (D50) XYZ to linear sRGB, Bradford adapted
+var xyzD50ToSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// toneCurve maps an encoded channel value (0..1) to linear light
+type toneCurve func(v float64) float64
+
+// ColorProfile is an ICC profile embedded in a JPEG or PNG file. Matrix/TRC
+// RGB profiles (sRGB, Adobe RGB, ProPhoto, Display P3, ...) can be converted
+// to sRGB; other profiles can only be carried over to derivatives.
+type ColorProfile struct {
+	Data        []byte
+	Description string
+
+	toXYZ  *[3][3]float64 // linear RGB -> XYZ (D50), nil if not convertible
+	curves [3]toneCurve
+}
+
+// ReadColorProfile returns the ICC profile embedded in a JPEG or PNG file, or
+// nil if the file has none
+func ReadColorProfile(path string) (*ColorProfile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	magic, err := r.Peek(8)
+	if err != nil {
+		return nil, nil
+	}
+
+	var data []byte
+	switch {
+	case magic[0] == 0xFF && magic[1] == 0xD8:
+		data, err = readJPEGProfile(r)
+	case bytes.Equal(magic, []byte("\x89PNG\r\n\x1a\n")):
+		data, err = readPNGProfile(r)
+	default:
+		return nil, nil
+	}
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	return parseICCProfile(data)
+}
+
+// DetectColorSpace returns the name of the color space of an image file, or
+// an empty string if it has no embedded profile (and is treated as sRGB)
+func DetectColorSpace(path string) string {
+	profile, err := ReadColorProfile(path)
+	if err != nil || profile == nil {
+		return ""
+	}
+	return profile.Name()
+}
+
+// Name returns a normalized color space name
+func (p *ColorProfile) Name() string {
+	desc := strings.ToLower(p.Description)
+	switch {
+	case strings.Contains(desc, "srgb"):
+		return "sRGB"
+	case strings.Contains(desc, "adobe rgb"), strings.Contains(desc, "adobergb"):
+		return "Adobe RGB (1998)"
+	case strings.Contains(desc, "prophoto"), strings.Contains(desc, "romm"):
+		return "ProPhoto RGB"
+	case strings.Contains(desc, "display p3"):
+		return "Display P3"
+	case p.Description != "":
+		return p.Description
+	default:
+		return "Unknown"
+	}
+}
+
+// IsSRGB reports whether the profile describes sRGB
+func (p *ColorProfile) IsSRGB() bool {
+	return p.Name() == "sRGB"
+}
+
+// CanConvert reports whether pixels in this profile can be converted to sRGB
+func (p *ColorProfile) CanConvert() bool {
+	return p.toXYZ != nil
+}
+
+// ToSRGB converts an image encoded in this profile to sRGB. Colors outside the
+// sRGB gamut are clipped.
+func (p *ColorProfile) ToSRGB(img image.Image) *image.NRGBA {
+	out := imaging.Clone(img)
+	if !p.CanConvert() {
+		return out
+	}
+
+	// Decode lookup tables per channel, one combined matrix, encode table
+	var linear [3][256]float64
+	for c := 0; c < 3; c++ {
+		for i := 0; i < 256; i++ {
+			linear[c][i] = p.curves[c](float64(i) / 255)
+		}
+	}
+	m := multiplyMatrix(xyzD50ToSRGB, *p.toXYZ)
+	var encode [4096]uint8
+	for i := range encode {
+		encode[i] = uint8(math.Round(srgbEncode(float64(i)/4095) * 255))
+	}
+
+	for i := 0; i+3 < len(out.Pix); i += 4 {
+		r, g, b := linear[0][out.Pix[i]], linear[1][out.Pix[i+1]], linear[2][out.Pix[i+2]]
+		for c := 0; c < 3; c++ {
+			v := m[c][0]*r + m[c][1]*g + m[c][2]*b
+			v = math.Max(0, math.Min(1, v))
+			out.Pix[i+c] = encode[int(v*4095+0.5)]
+		}
+	}
+	return out
+}
+
+// openSRGB opens an image and converts it to sRGB if it carries a convertible
+// wide-gamut profile; used where derivatives can't carry a profile of their own
+func openSRGB(path string) (image.Image, error) {
+	img, err := imaging.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if profile, err := ReadColorProfile(path); err == nil && profile != nil && !profile.IsSRGB() && profile.CanConvert() {
+		return profile.ToSRGB(img), nil
+	}
+	return img, nil
+}
+
+// embedJPEGProfile writes an ICC profile into a JPEG file, after its JFIF
+// header
+func embedJPEGProfile(path string, profile []byte) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read JPEG: %w", err)
+	}
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return fmt.Errorf("not a JPEG file: %s", path)
+	}
+
+	// Insert after SOI and an APP0 (JFIF) segment if present
+	insertAt := 2
+	if data[2] == 0xFF && data[3] == 0xE0 && len(data) >= 6 {
+		insertAt = 4 + int(binary.BigEndian.Uint16(data[4:6]))
+	}
+
+	count := (len(profile) + maxICCChunk - 1) / maxICCChunk
+	if count > 255 {
+		return fmt.Errorf("ICC profile too large to embed")
+	}
+
+	var segments bytes.Buffer
+	for i := 0; i < count; i++ {
+		chunk := profile[i*maxICCChunk : min((i+1)*maxICCChunk, len(profile))]
+		segments.Write([]byte{0xFF, 0xE2})
+		binary.Write(&segments, binary.BigEndian, uint16(2+len(iccJPEGMarker)+2+len(chunk)))
+		segments.Write(iccJPEGMarker)
+		segments.Write([]byte{byte(i + 1), byte(count)})
+		segments.Write(chunk)
+	}
+
+	out := make([]byte, 0, len(data)+segments.Len())
+	out = append(out, data[:insertAt]...)
+	out = append(out, segments.Bytes()...)
+	out = append(out, data[insertAt:]...)
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, out, 0644); err != nil {
+		return fmt.Errorf("failed to write JPEG: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace JPEG: %w", err)
+	}
+	return nil
+}
+
+// readJPEGProfile collects the ICC chunks from the APP2 segments of a JPEG
+func readJPEGProfile(r *bufio.Reader) ([]byte, error) {
+	chunks := make(map[byte][]byte)
+	var total byte
+	err := walkJPEGSegments(r, func(marker byte, segment []byte) bool {
+		if marker == 0xE2 && bytes.HasPrefix(segment, iccJPEGMarker) && len(segment) >= 14 {
+			chunks[segment[12]] = segment[14:]
+			total = segment[13]
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+
+	var profile []byte
+	for seq := byte(1); seq <= total; seq++ {
+		chunk, ok := chunks[seq]
+		if !ok {
+			return nil, fmt.Errorf("ICC profile chunk %d of %d missing", seq, total)
+		}
+		profile = append(profile, chunk...)
+	}
+	return profile, nil
+}
+
+// walkJPEGSegments calls fn with each metadata segment of a JPEG (after its
+// SOI marker) until the image data starts or fn returns false
+func walkJPEGSegments(r *bufio.Reader, fn func(marker byte, segment []byte) bool) error {
+	if _, err := r.Discard(2); err != nil {
+		return err
+	}
+
+	for {
+		// Markers may be preceded by any number of fill bytes
+		b, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("failed to read JPEG marker: %w", err)
+		}
+		if b != 0xFF {
+			return fmt.Errorf("invalid JPEG marker")
+		}
+		marker := byte(0xFF)
+		for marker == 0xFF {
+			if marker, err = r.ReadByte(); err != nil {
+				return fmt.Errorf("failed to read JPEG marker: %w", err)
+			}
+		}
+
+		// Image data follows: no more metadata segments
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			continue
+		}
+
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil || length < 2 {
+			return fmt.Errorf("invalid JPEG segment length")
+		}
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return fmt.Errorf("failed to read JPEG segment: %w", err)
+		}
+
+		if !fn(marker, segment) {
+			return nil
+		}
+	}
+}
+
+// readPNGProfile returns the decompressed iCCP chunk of a PNG
+func readPNGProfile(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Discard(8); err != nil {
+		return nil, err
+	}
+
+	for {
+		var header struct {
+			Length uint32
+			Type   [4]byte
+		}
+		if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+			return nil, fmt.Errorf("failed to read PNG chunk: %w", err)
+		}
+
+		switch string(header.Type[:]) {
+		case "iCCP":
+			chunk := make([]byte, header.Length)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return nil, fmt.Errorf("failed to read PNG iCCP chunk: %w", err)
+			}
+			// Profile name, NUL, compression method, zlib stream
+			nul := bytes.IndexByte(chunk, 0)
+			if nul < 0 || nul+2 > len(chunk) {
+				return nil, fmt.Errorf("invalid PNG iCCP chunk")
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(chunk[nul+2:]))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress ICC profile: %w", err)
+			}
+			defer zr.Close()
+			return io.ReadAll(zr)
+		case "IDAT", "IEND":
+			return nil, nil
+		}
+
+		// Skip the chunk data and CRC
+		if _, err := r.Discard(int(header.Length) + 4); err != nil {
+			return nil, fmt.Errorf("failed to skip PNG chunk: %w", err)
+		}
+	}
+}
+
+// parseICCProfile reads the description and, for matrix/TRC RGB profiles,
+// the colorants and tone curves of an ICC profile
+func parseICCProfile(data []byte) (*ColorProfile, error) {
+	if len(data) < 132 {
+		return nil, errors.New("ICC profile too short")
+	}
+
+	tagCount := int(binary.BigEndian.Uint32(data[128:]))
+	if tagCount > (len(data)-132)/12 {
+		return nil, errors.New("invalid ICC tag table")
+	}
+	tags := make(map[string][]byte, tagCount)
+	for i := 0; i < tagCount; i++ {
+		entry := data[132+12*i:]
+		offset := int(binary.BigEndian.Uint32(entry[4:]))
+		size := int(binary.BigEndian.Uint32(entry[8:]))
+		if offset >= 0 && size >= 0 && offset+size <= len(data) {
+			tags[string(entry[:4])] = data[offset : offset+size]
+		}
+	}
+
+	profile := &ColorProfile{
+		Data:        data,
+		Description: parseICCText(tags["desc"]),
+	}
+
+	if string(data[16:20]) != "RGB " || string(data[20:24]) != "XYZ " {
+		return profile, nil
+	}
+
+	var matrix [3][3]float64
+	for c, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz, ok := parseICCXYZ(tags[sig])
+		if !ok {
+			return profile, nil
+		}
+		for row := 0; row < 3; row++ {
+			matrix[row][c] = xyz[row]
+		}
+	}
+	for c, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		curve, ok := parseICCCurve(tags[sig])
+		if !ok {
+			return profile, nil
+		}
+		profile.curves[c] = curve
+	}
+	profile.toXYZ = &matrix
+
+	return profile, nil
+}
+
+// parseICCText decodes a desc (v2) or mluc (v4) text tag
+func parseICCText(tag []byte) string {
+	if len(tag) < 12 {
+		return ""
+	}
+
+	switch string(tag[:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(tag[8:]))
+		if n > len(tag)-12 {
+			n = len(tag) - 12
+		}
+		return strings.TrimSpace(strings.TrimRight(string(tag[12:12+n]), "\x00"))
+	case "mluc":
+		if len(tag) < 28 || binary.BigEndian.Uint32(tag[8:]) == 0 {
+			return ""
+		}
+		// First record: language, country, length, offset
+		length := int(binary.BigEndian.Uint32(tag[20:]))
+		offset := int(binary.BigEndian.Uint32(tag[24:]))
+		if offset+length > len(tag) {
+			return ""
+		}
+		units := make([]uint16, length/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(tag[offset+2*i:])
+		}
+		return strings.TrimSpace(strings.TrimRight(string(utf16.Decode(units)), "\x00"))
+	default:
+		return ""
+	}
+}
+
+// parseICCXYZ decodes an XYZ tag
+func parseICCXYZ(tag []byte) ([3]float64, bool) {
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return [3]float64{}, false
+	}
+	return [3]float64{s15Fixed16(tag[8:]), s15Fixed16(tag[12:]), s15Fixed16(tag[16:])}, true
+}
+
+// parseICCCurve decodes a curv or para tone curve tag
+func parseICCCurve(tag []byte) (toneCurve, bool) {
+	if len(tag) < 12 {
+		return nil, false
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:]))
+		switch {
+		case n == 0:
+			return func(v float64) float64 { return v }, true
+		case n == 1 && len(tag) >= 14:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256
+			return func(v float64) float64 { return math.Pow(v, gamma) }, true
+		case len(tag) >= 12+2*n:
+			table := make([]float64, n)
+			for i := range table {
+				table[i] = float64(binary.BigEndian.Uint16(tag[12+2*i:])) / 65535
+			}
+			return func(v float64) float64 {
+				pos := v * float64(n-1)
+				i := int(pos)
+				if i >= n-1 {
+					return table[n-1]
+				}
+				frac := pos - float64(i)
+				return table[i]*(1-frac) + table[i+1]*frac
+			}, true
+		}
+	case "para":
+		fn := binary.BigEndian.Uint16(tag[8:])
+		counts := map[uint16]int{0: 1, 1: 3, 2: 4, 3: 5, 4: 7}
+		count, ok := counts[fn]
+		if !ok || len(tag) < 12+4*count {
+			return nil, false
+		}
+		var p [7]float64
+		for i := 0; i < count; i++ {
+			p[i] = s15Fixed16(tag[12+4*i:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		pow := func(x float64) float64 { return math.Pow(math.Max(0, x), g) }
+		switch fn {
+		case 0:
+			return func(v float64) float64 { return pow(v) }, true
+		case 1:
+			return func(v float64) float64 {
+				if v >= -b/a {
+					return pow(a*v + b)
+				}
+				return 0
+			}, true
+		case 2:
+			return func(v float64) float64 {
+				if v >= -b/a {
+					return pow(a*v+b) + c
+				}
+				return c
+			}, true
+		case 3:
+			return func(v float64) float64 {
+				if v >= d {
+					return pow(a*v + b)
+				}
+				return c * v
+			}, true
+		case 4:
+			return func(v float64) float64 {
+				if v >= d {
+					return pow(a*v+b) + e
+				}
+				return c*v + f
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// s15Fixed16 decodes an ICC signed 15.16 fixed point number
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// srgbEncode applies the sRGB transfer function to a linear value
+func srgbEncode(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// multiplyMatrix returns a*b
+func multiplyMatrix(a, b [3][3]float64) [3][3]float64 {
+	var out [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				out[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// adobeRGBColorants are the D50-adapted Adobe RGB (1998) primaries
+var adobeRGBColorants = [3][3]float64{
+	{0.6097559, 0.3111242, 0.0194811},
+	{0.2052401, 0.6256560, 0.0608902},
+	{0.1492240, 0.0632197, 0.7448387},
+}
+
+// buildTestICC builds a minimal v2 matrix/TRC RGB profile
+func buildTestICC(description string, colorants [3][3]float64, gamma float64) []byte {
+	fixed := func(v float64) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(int32(v*65536)))
+		return b
+	}
+
+	desc := append([]byte("desc\x00\x00\x00\x00"), 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(desc[8:], uint32(len(description)+1))
+	desc = append(append(desc, description...), 0)
+
+	curve := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00")
+	binary.BigEndian.PutUint16(curve[12:], uint16(gamma*256))
+
+	type tag struct {
+		sig  string
+		data []byte
+	}
+	tags := []tag{{"desc", desc}}
+	for i, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		data := []byte("XYZ \x00\x00\x00\x00")
+		for _, v := range colorants[i] {
+			data = append(data, fixed(v)...)
+		}
+		tags = append(tags, tag{sig, data})
+	}
+	for _, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		tags = append(tags, tag{sig, curve})
+	}
+
+	header := make([]byte, 128)
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+
+	table := make([]byte, 4+12*len(tags))
+	binary.BigEndian.PutUint32(table, uint32(len(tags)))
+	var body []byte
+	offset := len(header) + len(table)
+	for i, t := range tags {
+		entry := table[4+12*i:]
+		copy(entry, t.sig)
+		binary.BigEndian.PutUint32(entry[4:], uint32(offset+len(body)))
+		binary.BigEndian.PutUint32(entry[8:], uint32(len(t.data)))
+		body = append(body, t.data...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	profile := append(append(header, table...), body...)
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+	return profile
+}
+
+func saveTestImage(t *testing.T, path string, c color.NRGBA) {
+	t.Helper()
+	if err := imaging.Save(imaging.New(64, 32, c), path); err != nil {
+		t.Fatalf("failed to save image: %v", err)
+	}
+}
+
+func TestReadColorProfile_JPEG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	saveTestImage(t, path, color.NRGBA{100, 150, 100, 255})
+
+	if profile, err := ReadColorProfile(path); err != nil || profile != nil {
+		t.Fatalf("expected no profile, got %v, %v", profile, err)
+	}
+	if cs := DetectColorSpace(path); cs != "" {
+		t.Errorf("expected empty color space for untagged image, got %q", cs)
+	}
+
+	icc := buildTestICC("Adobe RGB (1998)", adobeRGBColorants, 2.2)
+	if err := embedJPEGProfile(path, icc); err != nil {
+		t.Fatalf("embedJPEGProfile failed: %v", err)
+	}
+
+	profile, err := ReadColorProfile(path)
+	if err != nil || profile == nil {
+		t.Fatalf("ReadColorProfile failed: %v", err)
+	}
+	if !bytes.Equal(profile.Data, icc) {
+		t.Error("profile data does not round-trip")
+	}
+	if profile.Name() != "Adobe RGB (1998)" || profile.IsSRGB() || !profile.CanConvert() {
+		t.Errorf("unexpected profile %q (convertible %v)", profile.Name(), profile.CanConvert())
+	}
+
+	// The file must still decode after embedding
+	if _, err := imaging.Open(path); err != nil {
+		t.Errorf("JPEG no longer decodes: %v", err)
+	}
+}
+
+func TestReadColorProfile_PNG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "art.png")
+	saveTestImage(t, path, color.NRGBA{10, 20, 30, 255})
+
+	// Insert an iCCP chunk after IHDR (8 byte signature + 25 byte IHDR)
+	data, _ := os.ReadFile(path)
+	icc := buildTestICC("ProPhoto RGB", adobeRGBColorants, 1.8)
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(icc)
+	zw.Close()
+	payload := append([]byte("ProPhoto\x00\x00"), compressed.Bytes()...)
+
+	var chunk bytes.Buffer
+	binary.Write(&chunk, binary.BigEndian, uint32(len(payload)))
+	chunk.WriteString("iCCP")
+	chunk.Write(payload)
+	binary.Write(&chunk, binary.BigEndian, crc32.ChecksumIEEE(append([]byte("iCCP"), payload...)))
+	data = append(data[:33], append(chunk.Bytes(), data[33:]...)...)
+	os.WriteFile(path, data, 0644)
+
+	if cs := DetectColorSpace(path); cs != "ProPhoto RGB" {
+		t.Errorf("expected ProPhoto RGB, got %q", cs)
+	}
+}
+
+func TestColorProfile_ToSRGB(t *testing.T) {
+	profile, err := parseICCProfile(buildTestICC("Adobe RGB (1998)", adobeRGBColorants, 563.0/256))
+	if err != nil {
+		t.Fatalf("parseICCProfile failed: %v", err)
+	}
+
+	// A muted Adobe RGB green is more saturated once expressed in sRGB
+	out := profile.ToSRGB(imaging.New(1, 1, color.NRGBA{100, 150, 100, 255}))
+	r, g := int(out.Pix[0]), int(out.Pix[1])
+	if g-r <= 50 {
+		t.Errorf("expected converted green to be more saturated, got r=%d g=%d", r, g)
+	}
+	if out.Pix[3] != 255 {
+		t.Error("alpha was not preserved")
+	}
+
+	// Neutral grays stay neutral
+	gray := profile.ToSRGB(imaging.New(1, 1, color.NRGBA{128, 128, 128, 255}))
+	if d := int(gray.Pix[0]) - int(gray.Pix[2]); d < -2 || d > 2 {
+		t.Errorf("gray drifted: %v", gray.Pix[:3])
+	}
+}
+
+func TestGenerateThumbnail_ColorProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "wide.jpg")
+	saveTestImage(t, path, color.NRGBA{100, 150, 100, 255})
+	if err := embedJPEGProfile(path, buildTestICC("Adobe RGB (1998)", adobeRGBColorants, 2.2)); err != nil {
+		t.Fatalf("embedJPEGProfile failed: %v", err)
+	}
+
+	// By default the thumbnail keeps the source profile
+	storage := NewStorageService(dir)
+	thumbPath, err := storage.GenerateThumbnail(path)
+	if err != nil {
+		t.Fatalf("GenerateThumbnail failed: %v", err)
+	}
+	if cs := DetectColorSpace(thumbPath); cs != "Adobe RGB (1998)" {
+		t.Errorf("expected thumbnail to keep the Adobe RGB profile, got %q", cs)
+	}
+
+	// With normalization the pixels are converted and the profile dropped
+	storage.SetNormalizeSRGB(true)
+	if _, err := storage.GenerateThumbnail(path); err != nil {
+		t.Fatalf("GenerateThumbnail failed: %v", err)
+	}
+	if cs := DetectColorSpace(thumbPath); cs != "" {
+		t.Errorf("expected normalized thumbnail to be untagged sRGB, got %q", cs)
+	}
+	thumb, _ := imaging.Open(thumbPath)
+	r, g, _, _ := thumb.At(10, 10).RGBA()
+	if int(g>>8)-int(r>>8) <= 50 {
+		t.Errorf("expected normalized thumbnail to be more saturated, got r=%d g=%d", r>>8, g>>8)
+	}
+}
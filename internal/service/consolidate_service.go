@@ -0,0 +1,258 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// ErrConsolidationRunning is returned when a consolidation is already in progress
+var ErrConsolidationRunning = errors.New("consolidation already running")
+
+// DuplicateGroup is a set of stored files with identical content
+type DuplicateGroup struct {
+	Hash     string   `json:"hash"`
+	Size     int64    `json:"size"`
+	Paths    []string `json:"paths"`               // data-relative; the first is kept, the others link to it
+	ImageIDs []string `json:"image_ids,omitempty"` // images whose originals are among the paths
+}
+
+// ConsolidationReport is the outcome of a duplicate consolidation
+type ConsolidationReport struct {
+	JobID          string           `json:"job_id"`
+	State          string           `json:"state"` // running, completed, error
+	DryRun         bool             `json:"dry_run"`
+	FilesScanned   int              `json:"files_scanned"`
+	FilesLinked    int              `json:"files_linked"`    // copies replaced by hard links (or that would be, in a dry run)
+	BytesReclaimed int64            `json:"bytes_reclaimed"` // disk space freed by the links
+	Failed         int              `json:"failed"`
+	LastError      string           `json:"last_error,omitempty"`
+	Groups         []DuplicateGroup `json:"groups"`
+	StartedAt      time.Time        `json:"started_at"`
+	FinishedAt     *time.Time       `json:"finished_at,omitempty"`
+}
+
+// ConsolidateService finds byte-identical files stored under the categories
+// directory, typically the same export uploaded under several IDs, and
+// replaces the copies with hard links to one of them. Every path stays valid,
+// so the index is left alone, and deleting one image keeps the content for the
+// others. Files that are already links to each other are not counted again.
+type ConsolidateService struct {
+	storageService *StorageService
+	indexService   *IndexService
+	imageService   *ImageService
+	logger         *logrus.Logger
+
+	mutex   sync.Mutex
+	running bool
+	report  ConsolidationReport
+}
+
+// NewConsolidateService creates a consolidation service
+func NewConsolidateService(storage *StorageService, index *IndexService, image *ImageService, logger *logrus.Logger) *ConsolidateService {
+	return &ConsolidateService{
+		storageService: storage,
+		indexService:   index,
+		imageService:   image,
+		logger:         logger,
+	}
+}
+
+// Start launches a consolidation in the background. A dry run only reports
+// the duplicates and the space linking them would reclaim.
+func (s *ConsolidateService) Start(dryRun bool) (ConsolidationReport, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running {
+		return s.report, ErrConsolidationRunning
+	}
+
+	jobID := "consolidate-" + uuid.New().String()
+	s.running = true
+	s.report = ConsolidationReport{JobID: jobID, State: "running", DryRun: dryRun, StartedAt: time.Now()}
+	s.imageService.StartBackgroundJob(jobID, models.JobKindConsolidate, "Duplicate consolidation")
+
+	go s.run(jobID, dryRun)
+
+	return s.report, nil
+}
+
+// Report returns the current or last consolidation report
+func (s *ConsolidateService) Report() ConsolidationReport {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	report := s.report
+	report.Groups = append([]DuplicateGroup(nil), s.report.Groups...)
+	return report
+}
+
+func (s *ConsolidateService) run(jobID string, dryRun bool) {
+	err := s.consolidate(jobID, dryRun)
+
+	s.mutex.Lock()
+	now := time.Now()
+	s.report.FinishedAt = &now
+	s.report.State = "completed"
+	if err != nil {
+		s.report.State = "error"
+		s.report.LastError = err.Error()
+	}
+	report := s.report
+	s.running = false
+	s.mutex.Unlock()
+
+	s.imageService.FinishBackgroundJob(jobID, err)
+	s.logger.Infof("Duplicate consolidation %s finished: %s (%d scanned, %d groups, %d linked, %d bytes reclaimed, dry run: %v)",
+		jobID, report.State, report.FilesScanned, len(report.Groups), report.FilesLinked, report.BytesReclaimed, dryRun)
+}
+
+func (s *ConsolidateService) consolidate(jobID string, dryRun bool) error {
+	// Only files sharing a size can be identical, so only those are hashed
+	categoriesDir := filepath.Join(s.storageService.dataDir, "categories")
+	bySize := make(map[int64][]string)
+	scanned := 0
+	err := filepath.WalkDir(categoriesDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		scanned++
+		if info.Size() > 0 {
+			bySize[info.Size()] = append(bySize[info.Size()], path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan stored files: %w", err)
+	}
+
+	var candidates []string
+	for _, paths := range bySize {
+		if len(paths) > 1 {
+			candidates = append(candidates, paths...)
+		}
+	}
+	sort.Strings(candidates)
+
+	progress := models.JobProgress{Total: len(candidates)}
+	s.update(jobID, progress, func(r *ConsolidationReport) { r.FilesScanned = scanned })
+
+	byHash := make(map[string][]string)
+	for _, path := range candidates {
+		hash, err := hashFile(path)
+		if err != nil {
+			s.logger.Warnf("Failed to hash %s: %v", path, err)
+			progress.Failed++
+		} else {
+			byHash[hash] = append(byHash[hash], path)
+		}
+		progress.Done++
+		s.update(jobID, progress, nil)
+	}
+
+	hashes := make([]string, 0, len(byHash))
+	for hash, paths := range byHash {
+		if len(paths) > 1 {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+
+	for _, hash := range hashes {
+		group, linked, failed := s.consolidateGroup(hash, byHash[hash], dryRun)
+		if linked == 0 && failed == nil {
+			continue // already hard links of each other
+		}
+		progress.Failed += len(failed)
+		s.update(jobID, progress, func(r *ConsolidationReport) {
+			r.Groups = append(r.Groups, group)
+			r.FilesLinked += linked
+			r.BytesReclaimed += int64(linked) * group.Size
+			r.Failed += len(failed)
+			if len(failed) > 0 {
+				r.LastError = failed[len(failed)-1].Error()
+			}
+		})
+	}
+
+	return nil
+}
+
+// consolidateGroup links the copies among identical files to the first of
+// them and returns the group with the number of copies linked
+func (s *ConsolidateService) consolidateGroup(hash string, paths []string, dryRun bool) (DuplicateGroup, int, []error) {
+	keep := paths[0]
+	keepInfo, err := os.Stat(keep)
+	if err != nil {
+		return DuplicateGroup{}, 0, []error{err}
+	}
+
+	group := DuplicateGroup{Hash: hash, Size: keepInfo.Size()}
+	seen := make(map[string]bool)
+	linked := 0
+	var failed []error
+	for _, path := range paths {
+		relPath, _ := filepath.Rel(s.storageService.dataDir, path)
+		group.Paths = append(group.Paths, filepath.ToSlash(relPath))
+		if id, ok := s.indexService.ImageForOriginal(relPath); ok && !seen[id] {
+			seen[id] = true
+			group.ImageIDs = append(group.ImageIDs, id)
+		}
+
+		if path == keep {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && os.SameFile(keepInfo, info) {
+			continue
+		}
+		if !dryRun {
+			if err := replaceWithLink(keep, path); err != nil {
+				s.logger.Warnf("Failed to link %s to %s: %v", path, keep, err)
+				failed = append(failed, err)
+				continue
+			}
+		}
+		linked++
+	}
+
+	return group, linked, failed
+}
+
+// update records job progress and applies fn to the report under lock
+func (s *ConsolidateService) update(jobID string, progress models.JobProgress, fn func(r *ConsolidationReport)) {
+	if fn != nil {
+		s.mutex.Lock()
+		fn(&s.report)
+		s.mutex.Unlock()
+	}
+	s.imageService.UpdateJobProgress(jobID, progress)
+}
+
+// replaceWithLink replaces dst with a hard link to src. The link is made next
+// to dst and renamed over it, so dst never goes missing.
+func replaceWithLink(src, dst string) error {
+	tmp := dst + ".link"
+	os.Remove(tmp)
+	if err := os.Link(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
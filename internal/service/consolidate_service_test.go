@@ -0,0 +1,158 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestConsolidate_LinksDuplicates(t *testing.T) {
+	dataDir := t.TempDir()
+	storage := NewStorageService(dataDir)
+	index := NewIndexService(dataDir)
+	if err := storage.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	image := NewImageService(storage, nil, index, nil, logrus.New())
+	svc := NewConsolidateService(storage, index, image, logrus.New())
+
+	write := func(rel, content string) string {
+		path := filepath.Join(dataDir, "categories", rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", rel, err)
+		}
+		return path
+	}
+	first := write("animals/img-1.png", "same bytes")
+	second := write("landscape/img-2.png", "same bytes")
+	write("animals/img-3.png", "other bytes")
+	if err := os.Link(first, filepath.Join(dataDir, "categories", "animals", "img-4.png")); err != nil {
+		t.Fatalf("failed to link: %v", err)
+	}
+	for id, filePath := range map[string]string{"img-1": "categories/animals/img-1.png", "img-2": "categories/landscape/img-2.png"} {
+		img := &models.Image{ID: id, Title: id, Type: models.ImageType2D, UploadedAt: time.Now(), FilePath: filePath}
+		if err := index.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	run := func(dryRun bool) ConsolidationReport {
+		t.Helper()
+		started, err := svc.Start(dryRun)
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if job := waitForJob(t, image, started.JobID); job.State != models.JobStateCompleted || job.Kind != models.JobKindConsolidate {
+			t.Fatalf("unexpected job %+v", job)
+		}
+		return svc.Report()
+	}
+	sameFile := func() bool {
+		a, _ := os.Stat(first)
+		b, _ := os.Stat(second)
+		return os.SameFile(a, b)
+	}
+
+	// A dry run reports the copy but leaves it alone; the existing link is not counted
+	report := run(true)
+	if report.FilesScanned != 4 || report.FilesLinked != 1 || report.BytesReclaimed != int64(len("same bytes")) || len(report.Groups) != 1 {
+		t.Fatalf("unexpected dry run report %+v", report)
+	}
+	if ids := report.Groups[0].ImageIDs; len(ids) != 2 || ids[0] != "img-1" || ids[1] != "img-2" {
+		t.Errorf("image IDs = %v, want [img-1 img-2]", ids)
+	}
+	if sameFile() {
+		t.Fatal("dry run must not link files")
+	}
+
+	report = run(false)
+	if report.FilesLinked != 1 || !sameFile() {
+		t.Fatalf("copy not linked: %+v", report)
+	}
+	if content, err := os.ReadFile(second); err != nil || string(content) != "same bytes" {
+		t.Errorf("linked file content = %q, %v", content, err)
+	}
+
+	// Nothing left to reclaim
+	if report = run(false); report.FilesLinked != 0 || len(report.Groups) != 0 {
+		t.Errorf("second run should find nothing, got %+v", report)
+	}
+}
+
+func TestConsolidate_RecropDoesNotRewriteLinkedThumbnails(t *testing.T) {
+	dataDir := t.TempDir()
+	storage := NewStorageService(dataDir)
+	index := NewIndexService(dataDir)
+	if err := storage.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	images := NewImageService(storage, nil, index, NewStatusStore("", 0, 0), logrus.New())
+	svc := NewConsolidateService(storage, index, images, logrus.New())
+
+	// The same wide export uploaded twice, with identical square thumbnails
+	src := imaging.New(400, 100, color.White)
+	src = imaging.Paste(src, imaging.New(100, 100, color.Black), image.Pt(300, 0))
+	squares := make(map[string]string)
+	for id, category := range map[string]string{"img-1": "animals", "img-2": "landscape"} {
+		rel := "categories/" + category + "/" + id + ".png"
+		path := filepath.Join(dataDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := imaging.Save(src, path); err != nil {
+			t.Fatal(err)
+		}
+		square, _, err := storage.GenerateSquareThumbnail(path, &models.FocalPoint{X: 0.5, Y: 0.5})
+		if err != nil {
+			t.Fatalf("GenerateSquareThumbnail failed: %v", err)
+		}
+		squares[id] = square
+		squareRel, _ := filepath.Rel(dataDir, square)
+		img := &models.Image{ID: id, Title: id, Category: category, Type: models.ImageType2D, UploadedAt: time.Now(),
+			FilePath: rel, SquareThumbnail: filepath.ToSlash(squareRel)}
+		if err := index.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	started, err := svc.Start(false)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	waitForJob(t, images, started.JobID)
+	a, _ := os.Stat(squares["img-1"])
+	b, _ := os.Stat(squares["img-2"])
+	if !os.SameFile(a, b) {
+		t.Fatal("expected the square thumbnails to be linked")
+	}
+	before, _ := os.ReadFile(squares["img-2"])
+
+	// Recropping one image must not change the other's thumbnail through the link
+	if _, err := images.UpdateImage("img-1", 0, ImageUpdate{FocalPoint: &models.FocalPoint{X: 0.9, Y: 0.5, Source: models.FocalSourceManual}}); err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	recropped, _ := os.ReadFile(squares["img-1"])
+	after, _ := os.ReadFile(squares["img-2"])
+	if string(recropped) == string(before) {
+		t.Fatal("expected img-1's square thumbnail to be recropped")
+	}
+	if string(after) != string(before) {
+		t.Error("recropping img-1 rewrote img-2's square thumbnail")
+	}
+}
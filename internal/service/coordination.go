@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrLockHeld is returned by TryLock when another holder has the lock
+var ErrLockHeld = errors.New("lock is held by another instance")
+
+// ErrLeaseLost is returned when checking a lease that expired or was taken
+// over, so whatever its holder was about to write must not be written
+var ErrLeaseLost = errors.New("lock lease lost")
+
+// DefaultLeaseTTL is how long a lock outlives a crashed holder. Holders
+// renew their leases well before they expire.
+const DefaultLeaseTTL = 30 * time.Second
+
+// lockBackend stores leases: named locks held by a token until they expire
+type lockBackend interface {
+	// acquire takes the lock for token if it is free or its lease expired
+	acquire(name, token string, ttl time.Duration) (bool, error)
+	// renew extends the lease, false if token no longer holds the lock
+	renew(name, token string, ttl time.Duration) (bool, error)
+	// release frees the lock if token holds it
+	release(name, token string) error
+	// fence increments and returns the lock's fencing token. The holder
+	// calls it right after acquiring, so later holders get larger tokens.
+	fence(name string) (int64, error)
+}
+
+// Coordinator hands out named locks shared by every server instance using
+// the same coordination backend, so replicas sharing a data directory
+// don't race on the index or on singleton tasks
+type Coordinator struct {
+	backend lockBackend
+	ttl     time.Duration
+	owner   string
+}
+
+// NewCoordinator creates a coordinator for a COORDINATION_URL: "file" keeps
+// lease files in DATA_DIR/locks (for data directories on a shared volume,
+// where flock may be advisory per host), and redis://[:password@]host:port[/db]
+// keeps them in Redis. An empty URL returns nil: a single instance
+// coordinates with file locks alone.
+func NewCoordinator(rawURL, dataDir, owner string, ttl time.Duration) (*Coordinator, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	if owner == "" {
+		owner, _ = os.Hostname()
+	}
+
+	var backend lockBackend
+	if rawURL == "file" {
+		dir := filepath.Join(dataDir, "locks")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create lock directory: %w", err)
+		}
+		backend = &fileLockBackend{dir: dir, owner: owner}
+	} else {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Scheme != "redis" || u.Host == "" {
+			return nil, fmt.Errorf("invalid coordination URL %q (use file or redis://host:port)", rawURL)
+		}
+		if backend, err = newRedisLockBackend(u); err != nil {
+			return nil, err
+		}
+	}
+	return &Coordinator{backend: backend, ttl: ttl, owner: owner}, nil
+}
+
+// Owner names this instance in lock files and logs
+func (c *Coordinator) Owner() string {
+	return c.owner
+}
+
+// Lease is a held lock. It is renewed in the background until released;
+// if renewal fails (e.g. the backend is unreachable past the TTL) the lease
+// is lost and its context is cancelled.
+type Lease struct {
+	coordinator *Coordinator
+	name        string
+	token       string
+	fence       int64
+	ctx         context.Context
+	cancel      context.CancelFunc
+	once        sync.Once
+	done        chan struct{}
+}
+
+// Context is cancelled when the lease is released or lost
+func (l *Lease) Context() context.Context {
+	return l.ctx
+}
+
+// Fence returns the lease's fencing token, larger than that of every
+// earlier holder of the lock
+func (l *Lease) Fence() int64 {
+	return l.fence
+}
+
+// Check confirms with the backend that the lease is still held, or returns
+// ErrLeaseLost. A holder that was paused past its TTL calls it before
+// committing a write another holder may have overtaken.
+func (l *Lease) Check() error {
+	if l.ctx.Err() != nil {
+		return ErrLeaseLost
+	}
+	ok, err := l.coordinator.backend.renew(l.name, l.token, l.coordinator.ttl)
+	if err != nil {
+		return fmt.Errorf("failed to check lock %s: %w", l.name, err)
+	}
+	if !ok {
+		l.cancel()
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Release frees the lock
+func (l *Lease) Release() error {
+	var err error
+	l.once.Do(func() {
+		l.cancel()
+		<-l.done
+		err = l.coordinator.backend.release(l.name, l.token)
+	})
+	return err
+}
+
+func (l *Lease) keepAlive() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.coordinator.ttl / 3)
+	defer ticker.Stop()
+
+	expires := time.Now().Add(l.coordinator.ttl)
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+			ok, err := l.coordinator.backend.renew(l.name, l.token, l.coordinator.ttl)
+			switch {
+			case ok:
+				expires = time.Now().Add(l.coordinator.ttl)
+			case err == nil || time.Now().After(expires):
+				// Taken over, or unreachable for longer than the lease
+				l.cancel()
+				return
+			}
+		}
+	}
+}
+
+// TryLock takes the named lock if it is free, or returns ErrLockHeld
+func (c *Coordinator) TryLock(name string) (*Lease, error) {
+	token := c.owner + "/" + uuid.New().String()
+	ok, err := c.backend.acquire(name, token, c.ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	if !ok {
+		return nil, ErrLockHeld
+	}
+	fence, err := c.backend.fence(name)
+	if err != nil {
+		c.backend.release(name, token)
+		return nil, fmt.Errorf("failed to fence lock %s: %w", name, err)
+	}
+
+	lease := &Lease{coordinator: c, name: name, token: token, fence: fence, done: make(chan struct{})}
+	lease.ctx, lease.cancel = context.WithCancel(context.Background())
+	go lease.keepAlive()
+	return lease, nil
+}
+
+// Lock blocks until the named lock is held or ctx is done
+func (c *Coordinator) Lock(ctx context.Context, name string) (*Lease, error) {
+	wait := 10 * time.Millisecond
+	for {
+		lease, err := c.TryLock(name)
+		if !errors.Is(err, ErrLockHeld) {
+			return lease, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if wait < time.Second {
+			wait *= 2
+		}
+	}
+}
+
+// Locker returns the named lock as a Lock/Unlock pair, for code written
+// against flock. Goroutines of this instance queue on a mutex first, so
+// only one of them polls the backend.
+func (c *Coordinator) Locker(name string) *CoordinatedLock {
+	return &CoordinatedLock{coordinator: c, name: name}
+}
+
+// CoordinatedLock is a blocking lock held across instances
+type CoordinatedLock struct {
+	coordinator *Coordinator
+	name        string
+	local       sync.Mutex
+	lease       *Lease
+}
+
+// Lock blocks until the lock is held
+func (l *CoordinatedLock) Lock() error {
+	l.local.Lock()
+	lease, err := l.coordinator.Lock(context.Background(), l.name)
+	if err != nil {
+		l.local.Unlock()
+		return err
+	}
+	l.lease = lease
+	return nil
+}
+
+// Fence returns the fencing token of the held lock (0 when not held)
+func (l *CoordinatedLock) Fence() int64 {
+	if l.lease == nil {
+		return 0
+	}
+	return l.lease.Fence()
+}
+
+// Check returns ErrLeaseLost if the held lock expired or was taken over
+func (l *CoordinatedLock) Check() error {
+	if l.lease == nil {
+		return ErrLeaseLost
+	}
+	return l.lease.Check()
+}
+
+// Unlock releases the lock
+func (l *CoordinatedLock) Unlock() error {
+	lease := l.lease
+	l.lease = nil
+	l.local.Unlock()
+	if lease == nil {
+		return nil
+	}
+	return lease.Release()
+}
+
+// RunAsLeader runs fn on one instance at a time: it waits for the named
+// lock, then runs fn until it returns or the lock is lost, in which case
+// it waits again. A nil coordinator runs fn directly. Returns when ctx is
+// done or fn returns on its own.
+func RunAsLeader(ctx context.Context, c *Coordinator, name string, fn func(ctx context.Context)) {
+	if c == nil {
+		fn(ctx)
+		return
+	}
+	for ctx.Err() == nil {
+		lease, err := c.Lock(ctx, name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			time.Sleep(c.ttl / 3) // backend unreachable; try again
+			continue
+		}
+
+		runCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-lease.Context().Done():
+			case <-runCtx.Done():
+			}
+			cancel()
+		}()
+		fn(runCtx)
+		lost := lease.Context().Err() != nil && ctx.Err() == nil
+		cancel()
+		lease.Release()
+		if !lost {
+			return
+		}
+	}
+}
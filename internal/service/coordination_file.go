@@ -0,0 +1,203 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileLockBackend keeps each lease in a lock file created with O_EXCL,
+// which is atomic on local disks and NFSv3+ alike. The file records the
+// holder and when the lease expires; an expired lease is broken by
+// whoever first takes the lock's .break file. Expiry times are compared
+// across hosts, so their clocks must be roughly in sync (well within the
+// lease TTL).
+type fileLockBackend struct {
+	dir   string
+	owner string
+}
+
+// fileLease is the content of a lock file
+type fileLease struct {
+	Token   string    `json:"token"`
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+func (b *fileLockBackend) path(name string) string {
+	return filepath.Join(b.dir, url.PathEscape(name)+".lock")
+}
+
+func (b *fileLockBackend) encode(token string, ttl time.Duration) ([]byte, error) {
+	return json.Marshal(fileLease{Token: token, Owner: b.owner, Expires: time.Now().Add(ttl)})
+}
+
+// read returns the lease in a lock file. A file that can't be parsed is
+// being written, or was left half-written by a crash: it counts as held
+// until it is a TTL old.
+func (b *fileLockBackend) read(path string, ttl time.Duration) (fileLease, error) {
+	var lease fileLease
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lease, err
+	}
+	if json.Unmarshal(data, &lease) != nil {
+		info, err := os.Stat(path)
+		if err != nil {
+			return lease, err
+		}
+		lease.Expires = info.ModTime().Add(ttl)
+	}
+	return lease, nil
+}
+
+func (b *fileLockBackend) create(path, token string, ttl time.Duration) (bool, error) {
+	data, err := b.encode(token, ttl)
+	if err != nil {
+		return false, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *fileLockBackend) acquire(name, token string, ttl time.Duration) (bool, error) {
+	path := b.path(name)
+	if ok, err := b.create(path, token, ttl); ok || err != nil {
+		return ok, err
+	}
+	if !b.breakExpired(path, ttl) {
+		return false, nil
+	}
+	return b.create(path, token, ttl)
+}
+
+// breakExpired removes a lock file whose lease expired. The .break file
+// keeps two instances from both breaking the lease and then one removing
+// the other's fresh lock.
+func (b *fileLockBackend) breakExpired(path string, ttl time.Duration) bool {
+	lease, err := b.read(path, ttl)
+	if os.IsNotExist(err) {
+		return true
+	}
+	if err != nil || time.Now().Before(lease.Expires) {
+		return false
+	}
+
+	breaker := path + ".break"
+	file, err := os.OpenFile(breaker, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		// Left behind by an instance that crashed while breaking
+		if info, statErr := os.Stat(breaker); statErr == nil && time.Since(info.ModTime()) > ttl {
+			os.Remove(breaker)
+		}
+		return false
+	}
+	file.Close()
+	defer os.Remove(breaker)
+
+	// Still the expired lease now that we hold the breaker?
+	current, err := b.read(path, ttl)
+	if os.IsNotExist(err) {
+		return true
+	}
+	if err != nil || current.Token != lease.Token || time.Now().Before(current.Expires) {
+		return false
+	}
+	return os.Remove(path) == nil
+}
+
+func (b *fileLockBackend) renew(name, token string, ttl time.Duration) (bool, error) {
+	path := b.path(name)
+	lease, err := b.read(path, ttl)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if lease.Token != token {
+		return false, nil
+	}
+
+	data, err := b.encode(token, ttl)
+	if err != nil {
+		return false, err
+	}
+	tmp := path + "." + filepath.Base(token) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *fileLockBackend) release(name, token string) error {
+	path := b.path(name)
+	lease, err := b.read(path, DefaultLeaseTTL)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if lease.Token != token {
+		return nil // expired and taken over
+	}
+	return os.Remove(path)
+}
+
+// fence counts in a .fence file next to the lock file. Only the holder
+// writes it, so a read-increment-replace is enough.
+func (b *fileLockBackend) fence(name string) (int64, error) {
+	path := filepath.Join(b.dir, url.PathEscape(name)+".fence")
+	var fence int64
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if fence, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid fence file %s: %w", path, err)
+		}
+	case !os.IsNotExist(err):
+		return 0, err
+	}
+	fence++
+
+	tmp, err := os.CreateTemp(b.dir, ".fence-*")
+	if err != nil {
+		return 0, err
+	}
+	_, err = tmp.WriteString(strconv.FormatInt(fence, 10) + "\n")
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return fence, nil
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	redisKeyPrefix   = "image-warehousing:lock:"
+	redisFencePrefix = "image-warehousing:fence:"
+	redisTimeout     = 5 * time.Second
+
+	// Renew and release only touch a lock the caller's token still holds
+	redisRenewScript   = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	redisReleaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+// redisLockBackend keeps leases as Redis keys with a TTL (SET NX PX). It
+// speaks just enough of the Redis protocol for that over one connection,
+// redialled after errors.
+type redisLockBackend struct {
+	addr     string
+	password string
+	db       int
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// redisError is an error reply from Redis
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func newRedisLockBackend(u *url.URL) (*redisLockBackend, error) {
+	b := &redisLockBackend{addr: u.Host}
+	if !strings.Contains(b.addr, ":") {
+		b.addr += ":6379"
+	}
+	if u.User != nil {
+		b.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+		b.db = n
+	}
+	return b, nil
+}
+
+// do runs a command and returns its reply: a string, an int64, nil, or a
+// []interface{} of those
+func (b *redisLockBackend) do(args ...string) (interface{}, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.conn == nil {
+		if err := b.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := b.roundTrip(args)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		b.conn.Close()
+		b.conn = nil
+	}
+	return reply, err
+}
+
+func (b *redisLockBackend) connect() error {
+	conn, err := net.DialTimeout("tcp", b.addr, redisTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	b.conn, b.reader = conn, bufio.NewReader(conn)
+
+	var setup [][]string
+	if b.password != "" {
+		setup = append(setup, []string{"AUTH", b.password})
+	}
+	if b.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(b.db)})
+	}
+	for _, args := range setup {
+		if _, err := b.roundTrip(args); err != nil {
+			conn.Close()
+			b.conn = nil
+			return fmt.Errorf("failed to set up Redis connection: %w", err)
+		}
+	}
+	return nil
+}
+
+func (b *redisLockBackend) roundTrip(args []string) (interface{}, error) {
+	b.conn.SetDeadline(time.Now().Add(redisTimeout))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(b.conn, sb.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(b.reader)
+}
+
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err // -1 is a nil reply
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+func (b *redisLockBackend) acquire(name, token string, ttl time.Duration) (bool, error) {
+	reply, err := b.do("SET", redisKeyPrefix+name, token, "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return reply == "OK", err
+}
+
+func (b *redisLockBackend) renew(name, token string, ttl time.Duration) (bool, error) {
+	reply, err := b.do("EVAL", redisRenewScript, "1", redisKeyPrefix+name, token, strconv.FormatInt(ttl.Milliseconds(), 10))
+	return reply == int64(1), err
+}
+
+func (b *redisLockBackend) release(name, token string) error {
+	_, err := b.do("EVAL", redisReleaseScript, "1", redisKeyPrefix+name, token)
+	return err
+}
+
+func (b *redisLockBackend) fence(name string) (int64, error) {
+	reply, err := b.do("INCR", redisFencePrefix+name)
+	if err != nil {
+		return 0, err
+	}
+	fence, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected INCR reply %v", reply)
+	}
+	return fence, nil
+}
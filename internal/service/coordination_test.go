@@ -0,0 +1,503 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func newTestCoordinator(t *testing.T, rawURL, dataDir, owner string, ttl time.Duration) *Coordinator {
+	t.Helper()
+	c, err := NewCoordinator(rawURL, dataDir, owner, ttl)
+	if err != nil {
+		t.Fatalf("NewCoordinator failed: %v", err)
+	}
+	return c
+}
+
+// testLocking runs the lock contract against two coordinators standing in
+// for two server instances
+func testLocking(t *testing.T, a, b *Coordinator) {
+	t.Helper()
+	lease, err := a.TryLock("external-id/sku-1")
+	if err != nil {
+		t.Fatalf("TryLock failed: %v", err)
+	}
+	if _, err := b.TryLock("external-id/sku-1"); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("expected ErrLockHeld for the other instance, got %v", err)
+	}
+
+	// Renewal keeps the lease past its TTL
+	time.Sleep(3 * a.ttl)
+	if _, err := b.TryLock("external-id/sku-1"); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("expected the renewed lease to be held, got %v", err)
+	}
+
+	// Lock waits for the release
+	acquired := make(chan *Lease)
+	go func() {
+		lease, err := b.Lock(context.Background(), "external-id/sku-1")
+		if err != nil {
+			t.Errorf("Lock failed: %v", err)
+		}
+		acquired <- lease
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := lease.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if lease.Context().Err() == nil {
+		t.Error("expected a released lease's context to be cancelled")
+	}
+	select {
+	case other := <-acquired:
+		other.Release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("Lock did not acquire the released lock")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	held, _ := a.TryLock("leader")
+	defer held.Release()
+	if _, err := b.Lock(ctx, "leader"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Lock to give up with its context, got %v", err)
+	}
+}
+
+func TestCoordinator_FileLeases(t *testing.T) {
+	dataDir := t.TempDir()
+	a := newTestCoordinator(t, "file", dataDir, "a", 150*time.Millisecond)
+	b := newTestCoordinator(t, "file", dataDir, "b", 150*time.Millisecond)
+	testLocking(t, a, b)
+}
+
+func TestCoordinator_FileLeaseExpires(t *testing.T) {
+	dataDir := t.TempDir()
+	a := newTestCoordinator(t, "file", dataDir, "a", time.Minute)
+
+	// Left behind by a crashed instance
+	backend := a.backend.(*fileLockBackend)
+	stale := fileLease{Token: "crashed/1", Owner: "crashed", Expires: time.Now().Add(-time.Second)}
+	writeJSON(t, backend.path("index"), stale)
+	lease, err := a.TryLock("index")
+	if err != nil {
+		t.Fatalf("expected the expired lease to be broken, got %v", err)
+	}
+
+	// A lease replaced by another instance's (we were paused past the TTL,
+	// say) is lost at the next renewal
+	b := newTestCoordinator(t, "file", dataDir, "b", 150*time.Millisecond)
+	lease.Release()
+	lease, _ = b.TryLock("index")
+	writeJSON(t, backend.path("index"), fileLease{Token: "a/other", Owner: "a", Expires: time.Now().Add(time.Minute)})
+	select {
+	case <-lease.Context().Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the lease to be lost")
+	}
+}
+
+func writeJSON(t *testing.T, path string, lease fileLease) {
+	t.Helper()
+	data := fmt.Sprintf(`{"token": %q, "owner": %q, "expires": %q}`, lease.Token, lease.Owner, lease.Expires.Format(time.RFC3339Nano))
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeRedis answers the commands the lock backend sends
+type fakeRedis struct {
+	mutex sync.Mutex
+	keys  map[string]string
+	ttls  map[string]time.Time
+}
+
+func startFakeRedis(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	fake := &fakeRedis{keys: make(map[string]string), ttls: make(map[string]time.Time)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fake.serve(conn)
+		}
+	}()
+	return "redis://:secret@" + listener.Addr().String() + "/2"
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := readRedisReply(r)
+		if err != nil {
+			return
+		}
+		items := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i] = item.(string)
+		}
+		fmt.Fprint(conn, f.handle(args))
+	}
+}
+
+func (f *fakeRedis) handle(args []string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	get := func(key string) (string, bool) {
+		if expires, ok := f.ttls[key]; ok && time.Now().After(expires) {
+			delete(f.keys, key)
+			delete(f.ttls, key)
+		}
+		value, ok := f.keys[key]
+		return value, ok
+	}
+
+	switch {
+	case args[0] == "AUTH" && args[1] == "secret", args[0] == "SELECT" && args[1] == "2":
+		return "+OK\r\n"
+	case args[0] == "SET" && len(args) == 6 && args[3] == "NX" && args[4] == "PX":
+		if _, ok := get(args[1]); ok {
+			return "$-1\r\n"
+		}
+		ms, _ := strconv.Atoi(args[5])
+		f.keys[args[1]] = args[2]
+		f.ttls[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return "+OK\r\n"
+	case args[0] == "EVAL" && len(args) >= 5:
+		if value, ok := get(args[3]); !ok || value != args[4] {
+			return ":0\r\n"
+		}
+		if args[1] == redisRenewScript {
+			ms, _ := strconv.Atoi(args[5])
+			f.ttls[args[3]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		} else {
+			delete(f.keys, args[3])
+			delete(f.ttls, args[3])
+		}
+		return ":1\r\n"
+	case args[0] == "INCR" && len(args) == 2:
+		n, _ := strconv.ParseInt(f.keys[args[1]], 10, 64)
+		f.keys[args[1]] = strconv.FormatInt(n+1, 10)
+		return fmt.Sprintf(":%d\r\n", n+1)
+	}
+	return "-ERR unexpected command " + args[0] + "\r\n"
+}
+
+func TestCoordinator_Redis(t *testing.T) {
+	redisURL := startFakeRedis(t)
+	a := newTestCoordinator(t, redisURL, "", "a", 150*time.Millisecond)
+	b := newTestCoordinator(t, redisURL, "", "b", 150*time.Millisecond)
+	testLocking(t, a, b)
+
+	backend := a.backend.(*redisLockBackend)
+	if backend.password != "secret" || backend.db != 2 {
+		t.Errorf("unexpected Redis settings %+v", backend)
+	}
+	u, _ := url.Parse("redis://localhost")
+	if backend, _ := newRedisLockBackend(u); backend.addr != "localhost:6379" {
+		t.Errorf("expected the default port, got %s", backend.addr)
+	}
+	if _, err := NewCoordinator("postgres://db", "", "", 0); err == nil {
+		t.Error("expected an error for an unsupported coordination URL")
+	}
+}
+
+func TestCoordinator_SharedIndex(t *testing.T) {
+	// Two instances appending to one index and journal
+	dataDir := t.TempDir()
+	var services []*IndexService
+	var journals []*IndexJournal
+	for _, owner := range []string{"a", "b"} {
+		svc := NewIndexService(dataDir)
+		svc.SetCoordinator(newTestCoordinator(t, "file", dataDir, owner, time.Second))
+		if err := svc.InitializeIndex(); err != nil {
+			t.Fatalf("InitializeIndex failed: %v", err)
+		}
+		journal := NewIndexJournal(filepath.Join(dataDir, "journal.jsonl"))
+		if err := journal.Load(); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		svc.SetJournal(journal)
+		services = append(services, svc)
+		journals = append(journals, journal)
+	}
+
+	var wg sync.WaitGroup
+	for i, svc := range services {
+		wg.Add(1)
+		go func(i int, svc *IndexService) {
+			defer wg.Done()
+			for n := 0; n < 10; n++ {
+				img := &models.Image{ID: fmt.Sprintf("img-%d-%d", i, n), Title: "T", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()}
+				if err := svc.AppendToIndex(img); err != nil {
+					t.Errorf("AppendToIndex failed: %v", err)
+				}
+			}
+		}(i, svc)
+	}
+	wg.Wait()
+
+	images, err := services[0].GetAllImages()
+	if err != nil || len(images) != 20 {
+		t.Fatalf("expected 20 images, got %d (%v)", len(images), err)
+	}
+	for _, journal := range journals {
+		entries, err := journal.Since(0, 100)
+		if err != nil || len(entries) != 20 || journal.LastSeq() != 20 {
+			t.Fatalf("expected both instances to see 20 journal entries, got %d (%v)", len(entries), err)
+		}
+		for i, entry := range entries {
+			if entry.Seq != int64(i+1) {
+				t.Fatalf("expected consecutive sequence numbers, got %d at %d", entry.Seq, i)
+			}
+		}
+	}
+}
+
+func TestRunAsLeader(t *testing.T) {
+	dataDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mutex sync.Mutex
+	running, most := 0, 0
+	var wg sync.WaitGroup
+	for _, owner := range []string{"a", "b", "c"} {
+		c := newTestCoordinator(t, "file", dataDir, owner, 150*time.Millisecond)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			RunAsLeader(ctx, c, "leader", func(ctx context.Context) {
+				mutex.Lock()
+				running++
+				most = max(most, running)
+				mutex.Unlock()
+				time.Sleep(100 * time.Millisecond)
+				mutex.Lock()
+				running--
+				mutex.Unlock()
+			})
+		}()
+	}
+	wg.Wait()
+	if most != 1 {
+		t.Errorf("expected one leader at a time, got %d", most)
+	}
+
+	// Without a coordinator the task just runs
+	ran := false
+	RunAsLeader(ctx, nil, "leader", func(ctx context.Context) { ran = true })
+	if !ran {
+		t.Error("expected the task to run without a coordinator")
+	}
+}
+
+func TestQueueJob_ExternalIDClaimedAcrossInstances(t *testing.T) {
+	dataDir := t.TempDir()
+	index := NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	a := NewImageService(nil, nil, index, nil, logrus.New())
+	a.SetCoordinator(newTestCoordinator(t, "file", dataDir, "a", time.Second))
+	b := NewImageService(nil, nil, index, nil, logrus.New())
+	b.SetCoordinator(newTestCoordinator(t, "file", dataDir, "b", time.Second))
+
+	if err := a.QueueJob(&models.UploadJob{ImageID: "first", Type: models.ImageType2D, ExternalID: "sku-1"}); err != nil {
+		t.Fatalf("QueueJob failed: %v", err)
+	}
+	if err := b.QueueJob(&models.UploadJob{ImageID: "second", Type: models.ImageType2D, ExternalID: "sku-1"}); !errors.Is(err, ErrExternalIDConflict) {
+		t.Fatalf("expected ErrExternalIDConflict on the other instance, got %v", err)
+	}
+
+	a.releaseExternalID("sku-1")
+	if err := b.QueueJob(&models.UploadJob{ImageID: "second", Type: models.ImageType2D, ExternalID: "sku-1"}); err != nil {
+		t.Errorf("expected the released external ID to be free, got %v", err)
+	}
+}
+
+func TestCoordinator_Fencing(t *testing.T) {
+	for name, rawURL := range map[string]string{"file": "file", "redis": startFakeRedis(t)} {
+		t.Run(name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			a := newTestCoordinator(t, rawURL, dataDir, "a", time.Minute)
+			b := newTestCoordinator(t, rawURL, dataDir, "b", time.Minute)
+
+			first, err := a.TryLock("fenced-" + name)
+			if err != nil {
+				t.Fatalf("TryLock failed: %v", err)
+			}
+			if err := first.Check(); err != nil {
+				t.Errorf("expected the held lease to check out, got %v", err)
+			}
+			// The backend expires the lease while its holder is paused
+			first.coordinator.backend.release(first.name, first.token)
+			second, err := b.TryLock("fenced-" + name)
+			if err != nil {
+				t.Fatalf("TryLock failed: %v", err)
+			}
+			defer second.Release()
+
+			if second.Fence() <= first.Fence() {
+				t.Errorf("expected a larger fencing token, got %d after %d", second.Fence(), first.Fence())
+			}
+			if err := first.Check(); !errors.Is(err, ErrLeaseLost) {
+				t.Errorf("expected ErrLeaseLost for the overtaken lease, got %v", err)
+			}
+			if first.Context().Err() == nil {
+				t.Error("expected the lost lease's context to be cancelled")
+			}
+		})
+	}
+}
+
+func TestIndexJournal_RejectsStaleFence(t *testing.T) {
+	journal := NewIndexJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	if _, err := journal.AppendFenced(2, JournalAppend, "img-1", "\n## Image: img-1\n"); err != nil {
+		t.Fatalf("AppendFenced failed: %v", err)
+	}
+	if _, err := journal.AppendFenced(1, JournalUpdate, "img-1", "\n## Image: img-1\n"); !errors.Is(err, ErrStaleFence) {
+		t.Fatalf("expected ErrStaleFence, got %v", err)
+	}
+
+	// The fence survives a reload
+	reloaded := NewIndexJournal(journal.path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if reloaded.Fence() != 2 || reloaded.LastSeq() != 1 {
+		t.Errorf("expected fence 2 and one entry, got %d and %d", reloaded.Fence(), reloaded.LastSeq())
+	}
+	if _, err := reloaded.AppendFenced(3, JournalUpdate, "img-1", "\n## Image: img-1\n"); err != nil {
+		t.Errorf("expected a later fence to append, got %v", err)
+	}
+}
+
+func TestIndexService_LostLeaseDoesNotWrite(t *testing.T) {
+	dataDir := t.TempDir()
+	svc := NewIndexService(dataDir)
+	svc.SetCoordinator(newTestCoordinator(t, "file", dataDir, "a", time.Minute))
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	lock := svc.lock.(*CoordinatedLock)
+	if err := lock.Lock(); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	defer lock.Unlock()
+
+	// Another instance took the lock over while we were paused
+	backend := lock.coordinator.backend.(*fileLockBackend)
+	writeJSON(t, backend.path("index"), fileLease{Token: "b/1", Owner: "b", Expires: time.Now().Add(time.Minute)})
+	if err := svc.writeIndex(svc.indexPath, "overwritten"); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+	if data, _ := os.ReadFile(svc.indexPath); string(data) == "overwritten" {
+		t.Error("expected the index to be left alone")
+	}
+}
+
+func TestSharedStores_UpdatesFromAllInstances(t *testing.T) {
+	dataDir := t.TempDir()
+	a := newTestCoordinator(t, "file", dataDir, "a", time.Second)
+	b := newTestCoordinator(t, "file", dataDir, "b", time.Second)
+
+	favoritesPath := filepath.Join(dataDir, "favorites.json")
+	var favorites []*FavoriteStore
+	for _, c := range []*Coordinator{a, b} {
+		store := NewFavoriteStore(favoritesPath)
+		store.SetCoordinator(c)
+		if err := store.Load(); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		favorites = append(favorites, store)
+	}
+
+	// Interleaved writes from both instances are all kept
+	var wg sync.WaitGroup
+	for i, store := range favorites {
+		wg.Add(1)
+		go func(i int, store *FavoriteStore) {
+			defer wg.Done()
+			for n := 0; n < 10; n++ {
+				if _, err := store.Add("alice", fmt.Sprintf("img-%d-%d", i, n)); err != nil {
+					t.Errorf("Add failed: %v", err)
+				}
+			}
+		}(i, store)
+	}
+	wg.Wait()
+	for i, store := range favorites {
+		if got := len(store.Starred("alice")); got != 20 {
+			t.Errorf("instance %d: expected 20 favorites, got %d", i, got)
+		}
+	}
+	reloaded := NewFavoriteStore(favoritesPath)
+	if err := reloaded.Load(); err != nil || len(reloaded.Starred("alice")) != 20 {
+		t.Errorf("expected 20 favorites on disk, got %d (%v)", len(reloaded.Starred("alice")), err)
+	}
+
+	// A guest token revoked on one instance stops working on the other
+	tokensPath := filepath.Join(dataDir, "guest_tokens.json")
+	tokensA, tokensB := NewGuestTokenStore(tokensPath), NewGuestTokenStore(tokensPath)
+	tokensA.SetCoordinator(a)
+	tokensB.SetCoordinator(b)
+	token, secret, err := tokensA.Create(GuestToken{Name: "gallery", Project: "spring"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := tokensB.Resolve(secret, time.Now()); err != nil {
+		t.Fatalf("expected the other instance to resolve the new token, got %v", err)
+	}
+	if err := tokensB.Revoke(token.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, err := tokensA.Resolve(secret, time.Now()); !errors.Is(err, ErrGuestTokenNotFound) {
+		t.Errorf("expected the revoked token to be gone everywhere, got %v", err)
+	}
+
+	// Counts saved by each instance add up
+	popularityPath := filepath.Join(dataDir, "popularity.json")
+	popularityA, popularityB := NewPopularityStore(popularityPath), NewPopularityStore(popularityPath)
+	popularityA.SetCoordinator(a)
+	popularityB.SetCoordinator(b)
+	now := time.Now()
+	popularityA.RecordView("img-1", now)
+	popularityA.RecordView("img-1", now)
+	popularityB.RecordView("img-1", now)
+	popularityB.RecordDownload("img-1", now)
+	for _, store := range []*PopularityStore{popularityA, popularityB, popularityA} {
+		if err := store.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	for i, store := range []*PopularityStore{popularityA, popularityB} {
+		if p := store.Get("img-1"); p == nil || p.Views != 3 || p.Downloads != 1 {
+			t.Errorf("instance %d: expected 3 views and 1 download, got %+v", i, p)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// ErrNoCutoutSource is returned for images without a still to cut out, such
+// as 3D objects without a front view
+var ErrNoCutoutSource = errors.New("image has no source for a cutout")
+
+// ErrBackgroundNotUniform is returned by the built-in remover when the image
+// border isn't a plain backdrop it can key out
+var ErrBackgroundNotUniform = errors.New("background is not uniform enough to remove")
+
+// BackgroundRemover produces a copy of an image with its background made
+// transparent
+type BackgroundRemover interface {
+	RemoveBackground(ctx context.Context, imagePath string) (image.Image, error)
+}
+
+// HTTPBackgroundRemover delegates matting to an external service. The image
+// is POSTed as the raw request body; the response must be an image with
+// alpha (normally PNG).
+type HTTPBackgroundRemover struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewHTTPBackgroundRemover creates a remover calling the matting service at url
+func NewHTTPBackgroundRemover(url string, timeout time.Duration) *HTTPBackgroundRemover {
+	return &HTTPBackgroundRemover{
+		url:        url,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// RemoveBackground sends the image to the matting service
+func (r *HTTPBackgroundRemover) RemoveBackground(ctx context.Context, imagePath string) (image.Image, error) {
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create matting request: %w", err)
+	}
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(imagePath)))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "image/png")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call matting service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("matting service returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode matting result: %w", err)
+	}
+	return img, nil
+}
+
+// BorderKeyRemover is the built-in remover for studio shots on a plain
+// backdrop: it takes the dominant border color as the background and clears
+// everything connected to the border that is close to it.
+type BorderKeyRemover struct {
+	tolerance float64 // RGB distance still counted as background
+}
+
+// NewBorderKeyRemover creates a built-in remover
+func NewBorderKeyRemover() *BorderKeyRemover {
+	return &BorderKeyRemover{tolerance: 40}
+}
+
+// RemoveBackground keys out the backdrop. Images whose border isn't mostly a
+// single color are rejected with ErrBackgroundNotUniform.
+func (r *BorderKeyRemover) RemoveBackground(ctx context.Context, imagePath string) (image.Image, error) {
+	src, err := imaging.Open(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	return r.removeBackground(src)
+}
+
+func (r *BorderKeyRemover) removeBackground(src image.Image) (*image.NRGBA, error) {
+	img := imaging.Clone(src)
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w < 3 || h < 3 {
+		return nil, ErrBackgroundNotUniform
+	}
+
+	var border []image.Point
+	for x := 0; x < w; x++ {
+		border = append(border, image.Pt(x, 0), image.Pt(x, h-1))
+	}
+	for y := 1; y < h-1; y++ {
+		border = append(border, image.Pt(0, y), image.Pt(w-1, y))
+	}
+
+	background := medianColor(img, border)
+	distance := func(x, y int) float64 {
+		c := img.NRGBAAt(x, y)
+		dr, dg, db := float64(c.R)-float64(background.R), float64(c.G)-float64(background.G), float64(c.B)-float64(background.B)
+		return math.Sqrt(dr*dr + dg*dg + db*db)
+	}
+
+	// Most of the border has to be backdrop, or the subject is cropped by
+	// the frame and keying would eat into it
+	matching := 0
+	for _, p := range border {
+		if distance(p.X, p.Y) <= r.tolerance {
+			matching++
+		}
+	}
+	if float64(matching) < 0.8*float64(len(border)) {
+		return nil, ErrBackgroundNotUniform
+	}
+
+	// Flood fill the backdrop from the border
+	isBackground := make([]bool, w*h)
+	queue := make([]image.Point, 0, len(border))
+	for _, p := range border {
+		if distance(p.X, p.Y) <= r.tolerance && !isBackground[p.Y*w+p.X] {
+			isBackground[p.Y*w+p.X] = true
+			queue = append(queue, p)
+		}
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, n := range [4]image.Point{{p.X - 1, p.Y}, {p.X + 1, p.Y}, {p.X, p.Y - 1}, {p.X, p.Y + 1}} {
+			if n.X < 0 || n.Y < 0 || n.X >= w || n.Y >= h || isBackground[n.Y*w+n.X] {
+				continue
+			}
+			if distance(n.X, n.Y) <= r.tolerance {
+				isBackground[n.Y*w+n.X] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x, y)
+			if isBackground[y*w+x] {
+				img.Pix[i+3] = 0
+				continue
+			}
+			// Soften the subject's edge where it blends into the backdrop
+			if touchesBackground(isBackground, w, h, x, y) {
+				alpha := (distance(x, y) - r.tolerance) / r.tolerance
+				img.Pix[i+3] = uint8(float64(img.Pix[i+3]) * math.Max(0.25, math.Min(1, alpha)))
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// touchesBackground reports whether a pixel has a background neighbour
+func touchesBackground(isBackground []bool, w, h, x, y int) bool {
+	return (x > 0 && isBackground[y*w+x-1]) || (x < w-1 && isBackground[y*w+x+1]) ||
+		(y > 0 && isBackground[(y-1)*w+x]) || (y < h-1 && isBackground[(y+1)*w+x])
+}
+
+// medianColor returns the per-channel median color of the given pixels
+func medianColor(img *image.NRGBA, points []image.Point) color.NRGBA {
+	channels := [3][]int{}
+	for _, p := range points {
+		c := img.NRGBAAt(p.X, p.Y)
+		channels[0] = append(channels[0], int(c.R))
+		channels[1] = append(channels[1], int(c.G))
+		channels[2] = append(channels[2], int(c.B))
+	}
+	for _, ch := range channels {
+		sort.Ints(ch)
+	}
+	mid := len(points) / 2
+	return color.NRGBA{uint8(channels[0][mid]), uint8(channels[1][mid]), uint8(channels[2][mid]), 255}
+}
+
+// CutoutService maintains background-removed PNG renditions, stored next to
+// their source as <name>_cutout.png. Cutouts are made on first request, or at
+// ingest for the configured categories.
+type CutoutService struct {
+	indexService     *IndexService
+	storageService   *StorageService
+	remover          BackgroundRemover
+	ingestCategories map[string]bool
+	logger           *logrus.Logger
+	mutex            sync.Mutex
+}
+
+// NewCutoutService creates a cutout service. Images whose primary category is
+// in ingestCategories get their cutout as soon as they are indexed.
+func NewCutoutService(index *IndexService, storage *StorageService, remover BackgroundRemover, ingestCategories []string, logger *logrus.Logger) *CutoutService {
+	categories := make(map[string]bool, len(ingestCategories))
+	for _, category := range ingestCategories {
+		if category = strings.TrimSpace(category); category != "" {
+			categories[category] = true
+		}
+	}
+
+	return &CutoutService{
+		indexService:     index,
+		storageService:   storage,
+		remover:          remover,
+		ingestCategories: categories,
+		logger:           logger,
+	}
+}
+
+// cutoutSource returns the data-relative path of the still a cutout is made
+// from: the file of a 2D image, the front view of a 3D object
+func cutoutSource(img *ImageMetadata) string {
+	if img.FilePath != "" {
+		return img.FilePath
+	}
+	return img.Views["front"]
+}
+
+// cutoutPath derives the cutout path from its source image path
+func cutoutPath(sourcePath string) string {
+	ext := filepath.Ext(sourcePath)
+	return sourcePath[:len(sourcePath)-len(ext)] + "_cutout.png"
+}
+
+// Cutout returns the path of an image's cutout, creating it if it is missing
+// or older than its source
+func (s *CutoutService) Cutout(ctx context.Context, img *ImageMetadata) (string, error) {
+	source := cutoutSource(img)
+	if source == "" {
+		return "", ErrNoCutoutSource
+	}
+
+	sourcePath, err := s.storageService.StoredPath(source)
+	if err != nil {
+		return "", err
+	}
+	sourceInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat source image: %w", err)
+	}
+
+	// One cutout at a time: matting is expensive and a second request for
+	// the same image should find the first one's result
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	outPath := cutoutPath(sourcePath)
+	if info, err := os.Stat(outPath); err == nil && !info.ModTime().Before(sourceInfo.ModTime()) {
+		return outPath, nil
+	}
+
+	cutout, err := s.remover.RemoveBackground(ctx, sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to remove background: %w", err)
+	}
+	if err := replaceFile(outPath, func(tmpPath string) error { return imaging.Save(cutout, tmpPath) }); err != nil {
+		return "", fmt.Errorf("failed to save cutout: %w", err)
+	}
+
+	s.logger.Infof("Created cutout for %s", img.ID)
+	return outPath, nil
+}
+
+// HandleIndexed creates the cutout of a newly indexed image when its
+// category is configured for cutouts at ingest
+func (s *CutoutService) HandleIndexed(img *models.Image) {
+	primary, _, _ := strings.Cut(img.Category, "/")
+	if !s.ingestCategories[primary] {
+		return
+	}
+
+	metadata, err := s.indexService.GetImageByID(img.ID)
+	if err != nil {
+		return
+	}
+	if _, err := s.Cutout(context.Background(), metadata); err != nil {
+		s.logger.Warnf("Failed to create cutout for %s: %v", img.ID, err)
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// productShot returns a white backdrop with a blue box in the middle
+func productShot() *image.NRGBA {
+	img := imaging.New(60, 40, color.White)
+	draw.Draw(img, image.Rect(20, 10, 40, 30), image.NewUniform(color.NRGBA{30, 60, 200, 255}), image.Point{}, draw.Src)
+	return img
+}
+
+func TestBorderKeyRemover(t *testing.T) {
+	cutout, err := NewBorderKeyRemover().removeBackground(productShot())
+	if err != nil {
+		t.Fatalf("removeBackground failed: %v", err)
+	}
+	if a := cutout.NRGBAAt(2, 2).A; a != 0 {
+		t.Errorf("expected transparent backdrop, got alpha %d", a)
+	}
+	if a := cutout.NRGBAAt(30, 20).A; a != 255 {
+		t.Errorf("expected opaque subject, got alpha %d", a)
+	}
+
+	// A subject running off the frame is not keyed
+	busy := productShot()
+	draw.Draw(busy, image.Rect(0, 0, 60, 25), image.NewUniform(color.NRGBA{200, 30, 30, 255}), image.Point{}, draw.Src)
+	if _, err := NewBorderKeyRemover().removeBackground(busy); !errors.Is(err, ErrBackgroundNotUniform) {
+		t.Errorf("expected ErrBackgroundNotUniform, got %v", err)
+	}
+}
+
+func TestHTTPBackgroundRemover(t *testing.T) {
+	var gotType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotType = r.Header.Get("Content-Type")
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "image/png")
+		imaging.Encode(w, imaging.New(4, 4, color.Transparent), imaging.PNG)
+	}))
+	defer server.Close()
+
+	imagePath := filepath.Join(t.TempDir(), "shot.jpg")
+	if err := imaging.Save(productShot(), imagePath); err != nil {
+		t.Fatal(err)
+	}
+
+	cutout, err := NewHTTPBackgroundRemover(server.URL, 5*time.Second).RemoveBackground(context.Background(), imagePath)
+	if err != nil {
+		t.Fatalf("RemoveBackground failed: %v", err)
+	}
+	if gotType != "image/jpeg" {
+		t.Errorf("expected image/jpeg request, got %q", gotType)
+	}
+	if cutout.Bounds().Dx() != 4 {
+		t.Errorf("unexpected cutout size %v", cutout.Bounds())
+	}
+}
+
+// countingRemover counts calls and keys out the backdrop
+type countingRemover struct{ calls int }
+
+func (r *countingRemover) RemoveBackground(ctx context.Context, imagePath string) (image.Image, error) {
+	r.calls++
+	return NewBorderKeyRemover().RemoveBackground(ctx, imagePath)
+}
+
+func TestCutoutService(t *testing.T) {
+	dataDir := t.TempDir()
+	index := NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	fileRel := "categories/products/p1.png"
+	filePath := filepath.Join(dataDir, filepath.FromSlash(fileRel))
+	os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err := imaging.Save(productShot(), filePath); err != nil {
+		t.Fatal(err)
+	}
+	img := &models.Image{ID: "p1", Title: "Box", Artist: "A", Category: "products", Type: models.ImageType2D, UploadedAt: time.Now(), FilePath: fileRel}
+	if err := index.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	remover := &countingRemover{}
+	svc := NewCutoutService(index, NewStorageService(dataDir), remover, []string{"products"}, logrus.New())
+
+	// Cut out at ingest for configured categories, then served from disk
+	svc.HandleIndexed(img)
+	if remover.calls != 1 {
+		t.Fatalf("expected cutout at ingest, got %d calls", remover.calls)
+	}
+	metadata, _ := index.GetImageByID("p1")
+	path, err := svc.Cutout(context.Background(), metadata)
+	if err != nil {
+		t.Fatalf("Cutout failed: %v", err)
+	}
+	if filepath.Base(path) != "p1_cutout.png" || remover.calls != 1 {
+		t.Errorf("expected cached p1_cutout.png, got %s after %d calls", path, remover.calls)
+	}
+
+	// A newer source is cut out again
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(filePath, future, future)
+	if _, err := svc.Cutout(context.Background(), metadata); err != nil || remover.calls != 2 {
+		t.Errorf("expected regeneration for a newer source, got %d calls (%v)", remover.calls, err)
+	}
+
+	if _, err := svc.Cutout(context.Background(), &ImageMetadata{ID: "obj"}); !errors.Is(err, ErrNoCutoutSource) {
+		t.Errorf("expected ErrNoCutoutSource, got %v", err)
+	}
+}
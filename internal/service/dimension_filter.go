@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultAspectTolerance is the relative deviation from the requested aspect
+// ratio that still matches (2%)
+const DefaultAspectTolerance = 0.02
+
+// DimensionFilter restricts images by pixel size, megapixels and aspect
+// ratio; zero fields match everything
+type DimensionFilter struct {
+	MinWidth        int
+	MinHeight       int
+	MaxWidth        int
+	MaxHeight       int
+	MinMegapixels   float64
+	AspectRatio     float64 // width / height
+	AspectTolerance float64 // relative; zero means DefaultAspectTolerance
+}
+
+// IsZero reports whether the filter matches everything
+func (f DimensionFilter) IsZero() bool {
+	return f == DimensionFilter{}
+}
+
+// Matches reports whether an image passes the filter. Images without known
+// dimensions only pass an empty filter.
+func (f DimensionFilter) Matches(img *ImageMetadata) bool {
+	if f.IsZero() {
+		return true
+	}
+	if img.Width <= 0 || img.Height <= 0 {
+		return false
+	}
+
+	if img.Width < f.MinWidth || img.Height < f.MinHeight {
+		return false
+	}
+	if (f.MaxWidth > 0 && img.Width > f.MaxWidth) || (f.MaxHeight > 0 && img.Height > f.MaxHeight) {
+		return false
+	}
+	if img.Megapixels < f.MinMegapixels {
+		return false
+	}
+	if f.AspectRatio > 0 {
+		tolerance := f.AspectTolerance
+		if tolerance <= 0 {
+			tolerance = DefaultAspectTolerance
+		}
+		if math.Abs(img.AspectRatio-f.AspectRatio)/f.AspectRatio > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that the filter bounds make sense
+func (f DimensionFilter) Validate() error {
+	if f.MinWidth < 0 || f.MinHeight < 0 || f.MaxWidth < 0 || f.MaxHeight < 0 || f.MinMegapixels < 0 || f.AspectRatio < 0 || f.AspectTolerance < 0 {
+		return fmt.Errorf("dimension filters cannot be negative")
+	}
+	if (f.MaxWidth > 0 && f.MaxWidth < f.MinWidth) || (f.MaxHeight > 0 && f.MaxHeight < f.MinHeight) {
+		return fmt.Errorf("maximum dimension is smaller than the minimum")
+	}
+	return nil
+}
+
+// ParseAspectRatio parses an aspect ratio given as "16:9", "16x9" or a
+// decimal such as "1.78". An empty string yields zero (no filter).
+func ParseAspectRatio(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	if w, h, ok := strings.Cut(strings.ReplaceAll(s, "x", ":"), ":"); ok {
+		width, errW := strconv.ParseFloat(w, 64)
+		height, errH := strconv.ParseFloat(h, 64)
+		if errW != nil || errH != nil || width <= 0 || height <= 0 {
+			return 0, fmt.Errorf("invalid aspect ratio: %s", s)
+		}
+		return width / height, nil
+	}
+
+	ratio, err := strconv.ParseFloat(s, 64)
+	if err != nil || ratio <= 0 {
+		return 0, fmt.Errorf("invalid aspect ratio: %s", s)
+	}
+	return ratio, nil
+}
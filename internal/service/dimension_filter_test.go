@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func metadataWithSize(width, height int) *ImageMetadata {
+	img := &ImageMetadata{Width: width, Height: height}
+	img.Megapixels, img.AspectRatio = models.DimensionStats(width, height)
+	return img
+}
+
+func TestDimensionFilter_Matches(t *testing.T) {
+	banner4K := DimensionFilter{MinWidth: 3840, MinHeight: 2160, AspectRatio: 16.0 / 9}
+
+	cases := []struct {
+		name   string
+		img    *ImageMetadata
+		filter DimensionFilter
+		want   bool
+	}{
+		{"4K 16:9", metadataWithSize(3840, 2160), banner4K, true},
+		{"5K 16:9", metadataWithSize(5120, 2880), banner4K, true},
+		{"1080p too small", metadataWithSize(1920, 1080), banner4K, false},
+		{"4K square wrong shape", metadataWithSize(4000, 4000), banner4K, false},
+		{"unknown size", &ImageMetadata{}, banner4K, false},
+		{"unknown size, no filter", &ImageMetadata{}, DimensionFilter{}, true},
+		{"megapixels", metadataWithSize(4000, 3000), DimensionFilter{MinMegapixels: 12}, true},
+		{"too few megapixels", metadataWithSize(3000, 2000), DimensionFilter{MinMegapixels: 12}, false},
+		{"max width", metadataWithSize(3000, 2000), DimensionFilter{MaxWidth: 2048}, false},
+		{"loose tolerance", metadataWithSize(1600, 1000), DimensionFilter{AspectRatio: 16.0 / 9, AspectTolerance: 0.15}, true},
+	}
+	for _, tc := range cases {
+		if got := tc.filter.Matches(tc.img); got != tc.want {
+			t.Errorf("%s: Matches = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseAspectRatio(t *testing.T) {
+	for input, want := range map[string]float64{"16:9": 16.0 / 9, "4x3": 4.0 / 3, "1.5": 1.5, "": 0} {
+		got, err := ParseAspectRatio(input)
+		if err != nil || got != want {
+			t.Errorf("ParseAspectRatio(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	for _, bad := range []string{"wide", "16:", "-1", "0:9"} {
+		if _, err := ParseAspectRatio(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
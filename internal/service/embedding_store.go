@@ -0,0 +1,102 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// EmbeddingStore keeps one embedding vector per image, persisted as JSON
+// next to the index
+type EmbeddingStore struct {
+	path    string
+	vectors map[string][]float32
+	mutex   sync.RWMutex
+}
+
+func NewEmbeddingStore(path string) *EmbeddingStore {
+	return &EmbeddingStore{
+		path:    path,
+		vectors: make(map[string][]float32),
+	}
+}
+
+// Load reads stored embeddings from disk, if the file exists
+func (s *EmbeddingStore) Load() error {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read embeddings: %w", err)
+	}
+
+	var vectors map[string][]float32
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		return fmt.Errorf("failed to parse embeddings: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for id, vec := range vectors {
+		s.vectors[id] = vec
+	}
+	return nil
+}
+
+// Save writes all embeddings to disk atomically
+func (s *EmbeddingStore) Save() error {
+	s.mutex.RLock()
+	data, err := json.Marshal(s.vectors)
+	s.mutex.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode embeddings: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write embeddings: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace embeddings: %w", err)
+	}
+	return nil
+}
+
+// Has reports whether an image already has an embedding
+func (s *EmbeddingStore) Has(imageID string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, ok := s.vectors[imageID]
+	return ok
+}
+
+// Get returns the embedding of an image
+func (s *EmbeddingStore) Get(imageID string) ([]float32, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	vec, ok := s.vectors[imageID]
+	return vec, ok
+}
+
+// Set stores the embedding of an image
+func (s *EmbeddingStore) Set(imageID string, vec []float32) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vectors[imageID] = vec
+}
+
+// Delete drops the embedding of an image
+func (s *EmbeddingStore) Delete(imageID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.vectors, imageID)
+}
+
+// Len returns the number of stored embeddings
+func (s *EmbeddingStore) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.vectors)
+}
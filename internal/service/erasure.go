@@ -0,0 +1,461 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// erasureTokenTTL is how long an erasure preview can be confirmed
+const erasureTokenTTL = 10 * time.Minute
+
+// ErrNoArtistImages is returned for an artist without images to export or
+// erase
+var ErrNoArtistImages = errors.New("no images by this artist")
+
+// ArtistExport is the metadata file of an artist's data export
+type ArtistExport struct {
+	Artist     string              `json:"artist"`
+	ExportedAt time.Time           `json:"exported_at"`
+	Images     []ArtistExportImage `json:"images"`
+}
+
+// ArtistExportImage is one image of an export with everything kept about it;
+// Files are the paths of its files in the archive
+type ArtistExportImage struct {
+	*ImageMetadata
+	Annotations []models.Annotation `json:"annotations,omitempty"`
+	Files       []string            `json:"files"`
+}
+
+// ErasureBackupsNote tells the caller of an erasure that backup sets are
+// not erased. The server doesn't know about them: cmd/backup writes them.
+const ErasureBackupsNote = "retained: backup sets in BACKUP_DIR still hold these images until they are deleted or rotated out"
+
+// ErasurePreview lists what erasing an artist would remove, with the token
+// that confirms it
+type ErasurePreview struct {
+	Artist            string    `json:"artist"`
+	Count             int       `json:"count"`
+	IDs               []string  `json:"ids"`
+	Held              int       `json:"held,omitempty"`    // on legal hold, which are kept
+	Backups           string    `json:"backups,omitempty"` // see ErasureBackupsNote
+	ConfirmationToken string    `json:"confirmation_token,omitempty"`
+	ExpiresAt         time.Time `json:"expires_at,omitempty"`
+}
+
+// pendingErasure is a preview waiting for confirmation
+type pendingErasure struct {
+	artist    string
+	actor     string
+	ids       []string
+	expiresAt time.Time
+}
+
+// ErasureService answers a contributor's data requests: it exports every
+// image attributed to an artist with its metadata, and erases them for good.
+// An erasure deletes the images and their files, drops them from the
+// annotation, embedding, popularity and favorite stores, the analysis cache
+// and the image history, removes the sprite sheets showing them, and
+// redacts their metadata from the index journal. Images on legal hold are
+// kept. Backups are not touched (see ErasureBackupsNote).
+type ErasureService struct {
+	storageService *StorageService
+	indexService   *IndexService
+	imageService   *ImageService
+	annotations    *AnnotationStore
+	embeddings     *EmbeddingStore
+	popularity     *PopularityStore
+	favorites      *FavoriteStore
+	analysisCache  *AnalysisCache
+	sprites        *SpriteService
+	audit          *AuditLog
+	logger         *logrus.Logger
+
+	pending map[string]*pendingErasure
+	mutex   sync.Mutex
+}
+
+// NewErasureService creates an erasure service. The stores may be nil.
+func NewErasureService(storage *StorageService, index *IndexService, image *ImageService, annotations *AnnotationStore, embeddings *EmbeddingStore, popularity *PopularityStore, favorites *FavoriteStore, audit *AuditLog, logger *logrus.Logger) *ErasureService {
+	return &ErasureService{
+		storageService: storage,
+		indexService:   index,
+		imageService:   image,
+		annotations:    annotations,
+		embeddings:     embeddings,
+		popularity:     popularity,
+		favorites:      favorites,
+		audit:          audit,
+		logger:         logger,
+		pending:        make(map[string]*pendingErasure),
+	}
+}
+
+// SetAnalysisCache drops the cached analyses of erased images from cache
+func (s *ErasureService) SetAnalysisCache(cache *AnalysisCache) {
+	s.analysisCache = cache
+}
+
+// SetSprites removes the sprite sheets showing erased images
+func (s *ErasureService) SetSprites(sprites *SpriteService) {
+	s.sprites = sprites
+}
+
+// Images returns the images attributed to an artist, matched ignoring case
+// and surrounding spaces
+func (s *ErasureService) Images(artist string) ([]*ImageMetadata, error) {
+	artist = strings.TrimSpace(artist)
+	if artist == "" {
+		return nil, errors.New("artist is required")
+	}
+	all, err := s.indexService.GetAllImages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	var images []*ImageMetadata
+	for _, img := range all {
+		if strings.EqualFold(strings.TrimSpace(img.Artist), artist) {
+			images = append(images, img)
+		}
+	}
+	return images, nil
+}
+
+// Export writes a ZIP of an artist's images to w: export.json with the
+// metadata and annotations of each image, and the files under
+// files/<image ID>/ (the original of a 2D image, the whole folder of a 3D
+// object). The export is recorded in the audit log.
+func (s *ErasureService) Export(artist, actor string, w io.Writer) error {
+	images, err := s.Images(artist)
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		return ErrNoArtistImages
+	}
+
+	archive := zip.NewWriter(w)
+	export := ArtistExport{Artist: strings.TrimSpace(artist), ExportedAt: time.Now(), Images: make([]ArtistExportImage, 0, len(images))}
+	for _, img := range images {
+		files, err := s.exportFiles(archive, img)
+		if err != nil {
+			return err
+		}
+		entry := ArtistExportImage{ImageMetadata: img, Files: files}
+		if s.annotations != nil {
+			entry.Annotations = s.annotations.List(img.ID)
+		}
+		export.Images = append(export.Images, entry)
+	}
+
+	metadata, err := archive.Create("export.json")
+	if err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	encoder := json.NewEncoder(metadata)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+
+	s.record(AuditEntry{Actor: actor, Action: AuditArtistExport, Detail: fmt.Sprintf("%s: %d images", export.Artist, len(images))})
+	return nil
+}
+
+// exportFiles adds the files of an image to the archive and returns their
+// archive paths
+func (s *ErasureService) exportFiles(archive *zip.Writer, img *ImageMetadata) ([]string, error) {
+	root := img.FilePath
+	if img.Type == string(models.ImageType3D) {
+		root = img.FolderPath
+	}
+	if root == "" {
+		return []string{}, nil
+	}
+	fullRoot, err := s.storageService.StoredPath(root)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	err = filepath.WalkDir(fullRoot, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				s.logger.Warnf("Export: file of %s is missing: %s", img.ID, root)
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(fullRoot, fullPath)
+		if err != nil || rel == "." {
+			rel = filepath.Base(fullPath)
+		}
+		name := path.Join("files", img.ID, filepath.ToSlash(rel))
+		if err := copyToArchive(archive, name, fullPath); err != nil {
+			return err
+		}
+		files = append(files, name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export files of %s: %w", img.ID, err)
+	}
+	return files, nil
+}
+
+// copyToArchive adds a file to the archive under name
+func copyToArchive(archive *zip.Writer, name, fullPath string) error {
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	dst, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, file)
+	return err
+}
+
+// Preview lists the images an erasure of artist would remove and issues a
+// single-use confirmation token bound to the artist and actor
+func (s *ErasureService) Preview(artist, actor string) (*ErasurePreview, error) {
+	images, err := s.Images(artist)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &ErasurePreview{Artist: strings.TrimSpace(artist), Count: len(images), IDs: make([]string, 0, len(images))}
+	for _, img := range images {
+		preview.IDs = append(preview.IDs, img.ID)
+		if s.imageService.HeldBy(img) != "" {
+			preview.Held++
+		}
+	}
+	if preview.Count == 0 {
+		return preview, nil
+	}
+	preview.Backups = ErasureBackupsNote
+
+	token, err := newConfirmationToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	preview.ConfirmationToken = token
+	preview.ExpiresAt = now.Add(erasureTokenTTL)
+
+	s.mutex.Lock()
+	for key, p := range s.pending {
+		if now.After(p.expiresAt) {
+			delete(s.pending, key)
+		}
+	}
+	s.pending[token] = &pendingErasure{
+		artist:    strings.ToLower(preview.Artist),
+		actor:     actor,
+		ids:       preview.IDs,
+		expiresAt: preview.ExpiresAt,
+	}
+	s.mutex.Unlock()
+
+	return preview, nil
+}
+
+// Confirm redeems a confirmation token and erases the previewed images in
+// the background, returning the job ID. The artist and actor must be the
+// ones the token was issued for.
+func (s *ErasureService) Confirm(artist, token, actor string) (string, int, error) {
+	s.mutex.Lock()
+	p, ok := s.pending[token]
+	if ok {
+		// Single use, even when the check below fails
+		delete(s.pending, token)
+	}
+	s.mutex.Unlock()
+
+	artist = strings.TrimSpace(artist)
+	if !ok || time.Now().After(p.expiresAt) || p.artist != strings.ToLower(artist) || p.actor != actor {
+		return "", 0, ErrConfirmationToken
+	}
+
+	jobID := "erasure-" + uuid.New().String()
+	s.imageService.StartBackgroundJob(jobID, models.JobKindErasure, "Erasure")
+
+	go func() {
+		err := s.run(jobID, artist, p.ids, actor)
+		s.imageService.FinishBackgroundJob(jobID, err)
+		if err != nil {
+			s.logger.Errorf("Erasure %s failed: %v", jobID, err)
+		}
+	}()
+
+	return jobID, len(p.ids), nil
+}
+
+func (s *ErasureService) run(jobID, artist string, ids []string, actor string) error {
+	progress := models.JobProgress{Total: len(ids)}
+	s.imageService.UpdateJobProgress(jobID, progress)
+
+	erased := make(map[string]bool)
+	contentHashes := make(map[string]bool)
+	held := 0
+	for _, id := range ids {
+		// Hashed before the files are gone
+		hash := s.contentHash(id)
+		err := s.imageService.DeleteImage(id, actor)
+		switch {
+		case errors.Is(err, ErrLegalHold):
+			held++
+			progress.Skipped++
+		case errors.Is(err, ErrImageNotFound):
+			// Deleted since the preview; its traces are erased all the same
+			erased[id] = true
+			s.forget(jobID, id)
+			progress.Skipped++
+		case errors.Is(err, ErrFilesRemain):
+			// Out of the index, so its traces go, but it isn't erased
+			// while its files are on disk
+			erased[id] = true
+			s.forget(jobID, id)
+			s.logger.Warnf("Erasure %s: %s: %v", jobID, id, err)
+			progress.Failed++
+		case err != nil:
+			s.logger.Warnf("Erasure %s: failed to delete %s: %v", jobID, id, err)
+			progress.Failed++
+		default:
+			erased[id] = true
+			if hash != "" {
+				contentHashes[hash] = true
+			}
+			s.forget(jobID, id)
+			s.record(AuditEntry{Actor: actor, Action: AuditErasureDelete, ImageID: id, Detail: "erasure " + jobID})
+			progress.Done++
+		}
+		s.imageService.UpdateJobProgress(jobID, progress)
+	}
+
+	if s.embeddings != nil {
+		if err := s.embeddings.Save(); err != nil {
+			s.logger.Warnf("Erasure %s: failed to save embeddings: %v", jobID, err)
+			progress.Failed++
+		}
+	}
+	if s.analysisCache != nil && len(contentHashes) > 0 {
+		s.analysisCache.Forget(contentHashes)
+		if err := s.analysisCache.Save(); err != nil {
+			s.logger.Warnf("Erasure %s: failed to save the analysis cache: %v", jobID, err)
+			progress.Failed++
+		}
+	}
+	if s.sprites != nil {
+		if _, err := s.sprites.Forget(erased); err != nil {
+			s.logger.Warnf("Erasure %s: %v", jobID, err)
+			progress.Failed++
+		}
+	}
+	redacted, err := s.indexService.RedactJournal(erased)
+	if err != nil {
+		s.logger.Errorf("Erasure %s: failed to redact the index journal: %v", jobID, err)
+		progress.Failed++
+	}
+	if _, err := s.imageService.ForgetHistory(erased); err != nil {
+		s.logger.Errorf("Erasure %s: failed to drop the image history: %v", jobID, err)
+		progress.Failed++
+	}
+	s.imageService.UpdateJobProgress(jobID, progress)
+
+	outcome := fmt.Sprintf("%s: %d erased, %d on legal hold, %d failed", artist, progress.Done, held, progress.Failed)
+	s.record(AuditEntry{Actor: actor, Action: AuditArtistErasure, Detail: outcome})
+	s.logger.Infof("Erasure %s by %s finished: %s, %d journal entries redacted", jobID, actor, outcome, redacted)
+	if progress.Failed > 0 {
+		return fmt.Errorf("%d of %d images failed to erase", progress.Failed, progress.Total)
+	}
+	return nil
+}
+
+// contentHash returns the hash an image's analysis is cached under: that of
+// its original, or of its views for a 3D object. Empty without an analysis
+// cache or when the files can't be read.
+func (s *ErasureService) contentHash(imageID string) string {
+	if s.analysisCache == nil {
+		return ""
+	}
+	img, err := s.indexService.GetImageByID(imageID)
+	if err != nil {
+		return ""
+	}
+
+	var hash string
+	if img.Type == string(models.ImageType3D) {
+		views := make(map[string]string, len(img.Views))
+		for view, relPath := range img.Views {
+			if views[view], err = s.storageService.StoredPath(relPath); err != nil {
+				break
+			}
+		}
+		if err == nil && len(views) > 0 {
+			hash, err = hashViews(views)
+		}
+	} else if img.FilePath != "" {
+		var fullPath string
+		if fullPath, err = s.storageService.StoredPath(img.FilePath); err == nil {
+			hash, err = hashFile(fullPath)
+		}
+	}
+	if err != nil {
+		s.logger.Warnf("Erasure: can't hash %s to drop its cached analysis: %v", imageID, err)
+		return ""
+	}
+	return hash
+}
+
+// forget drops an erased image from the stores kept beside the index
+func (s *ErasureService) forget(jobID, imageID string) {
+	if s.annotations != nil {
+		if err := s.annotations.Forget(imageID); err != nil {
+			s.logger.Warnf("Erasure %s: failed to drop annotations of %s: %v", jobID, imageID, err)
+		}
+	}
+	if s.embeddings != nil {
+		s.embeddings.Delete(imageID)
+	}
+	if s.popularity != nil {
+		s.popularity.Forget(imageID)
+	}
+	if s.favorites != nil {
+		if err := s.favorites.Forget(imageID); err != nil {
+			s.logger.Warnf("Erasure %s: failed to drop favorites of %s: %v", jobID, imageID, err)
+		}
+	}
+}
+
+func (s *ErasureService) record(entry AuditEntry) {
+	if entry.Actor == "" {
+		entry.Actor = "anonymous"
+	}
+	if err := s.audit.Record(entry); err != nil {
+		s.logger.Errorf("Failed to record %s in the audit log: %v", entry.Action, err)
+	}
+}
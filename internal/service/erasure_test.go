@@ -0,0 +1,257 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestErasure_ExportThenErase(t *testing.T) {
+	logger := logrus.New()
+	dataDir := t.TempDir()
+	indexService := NewIndexService(dataDir)
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	journal := NewIndexJournal(filepath.Join(dataDir, "journal.jsonl"))
+	indexService.SetJournal(journal)
+
+	filePath := "categories/animals/a.jpg"
+	if err := os.MkdirAll(filepath.Join(dataDir, "categories", "animals"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, filepath.FromSlash(filePath)), []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	images := []*models.Image{
+		{ID: "a", Title: "Secret fox", Artist: "Jane Doe", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), FilePath: filePath},
+		{ID: "b", Title: "B", Artist: "jane doe", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "c", Title: "C", Artist: "John Roe", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()},
+	}
+	for _, img := range images {
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	storage := NewStorageService(dataDir)
+	imageService := NewImageService(storage, nil, indexService, nil, logger)
+	annotations := NewAnnotationStore(filepath.Join(dataDir, "annotations.json"))
+	if _, err := annotations.Add(models.Annotation{ImageID: "a", Shape: "point", X: 0.5, Y: 0.5, Text: "crop tighter"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	embeddings := NewEmbeddingStore(filepath.Join(dataDir, "embeddings.json"))
+	embeddings.Set("a", []float32{1})
+	audit := NewAuditLog(filepath.Join(dataDir, "audit.jsonl"))
+	svc := NewErasureService(storage, indexService, imageService, annotations, embeddings, nil, nil, audit, logger)
+
+	var buf bytes.Buffer
+	if err := svc.Export(" JANE DOE ", "admin", &buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("export is not a ZIP: %v", err)
+	}
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "files/a/a.jpg,export.json" {
+		t.Errorf("unexpected archive contents %v", names)
+	}
+	if err := svc.Export("Nobody", "admin", &buf); !errors.Is(err, ErrNoArtistImages) {
+		t.Errorf("expected ErrNoArtistImages, got %v", err)
+	}
+
+	preview, err := svc.Preview("jane doe", "admin")
+	if err != nil || preview.Count != 2 || preview.ConfirmationToken == "" {
+		t.Fatalf("unexpected preview %+v (%v)", preview, err)
+	}
+	if _, _, err := svc.Confirm("John Roe", preview.ConfirmationToken, "admin"); !errors.Is(err, ErrConfirmationToken) {
+		t.Fatalf("expected the token to be bound to the artist, got %v", err)
+	}
+	preview, _ = svc.Preview("jane doe", "admin")
+	jobID, _, err := svc.Confirm("Jane Doe", preview.ConfirmationToken, "admin")
+	if err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+	if job := waitForJob(t, imageService, jobID); job.State != models.JobStateCompleted || job.Kind != models.JobKindErasure || job.Progress.Done != 2 {
+		t.Fatalf("unexpected job result %+v", job)
+	}
+
+	for id, want := range map[string]bool{"a": false, "b": false, "c": true} {
+		if _, err := indexService.GetImageByID(id); (err == nil) != want {
+			t.Errorf("image %s: expected present=%v, got err %v", id, want, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, filepath.FromSlash(filePath))); !os.IsNotExist(err) {
+		t.Error("expected the file to be deleted")
+	}
+	if annotations.Count("a") != 0 || embeddings.Has("a") {
+		t.Error("expected annotations and embeddings to be dropped")
+	}
+	data, _ := os.ReadFile(filepath.Join(dataDir, "journal.jsonl"))
+	if strings.Contains(string(data), "Secret fox") || strings.Contains(string(data), "Jane") {
+		t.Error("expected the journal to be redacted")
+	}
+	if !strings.Contains(string(data), "John Roe") || journal.LastSeq() != 5 {
+		t.Errorf("expected other entries to be kept, last seq %d", journal.LastSeq())
+	}
+	if entries, _ := audit.Entries(AuditErasureDelete, 10); len(entries) != 2 {
+		t.Errorf("expected 2 erasure audit entries, got %+v", entries)
+	}
+}
+
+func TestErasure_FilesLeftCountAsFailed(t *testing.T) {
+	logger := logrus.New()
+	dataDir := t.TempDir()
+	indexService := NewIndexService(dataDir)
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	images := []*models.Image{
+		// Its thumbnail path can't be removed
+		{ID: "a", Title: "A", Artist: "Jane Doe", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), ThumbnailPath: "thumbs/a.jpg"},
+		{ID: "b", Title: "B", Artist: "Jane Doe", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()},
+	}
+	for _, img := range images {
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	storage := NewStorageService(dataDir)
+	imageService := NewImageService(storage, nil, indexService, nil, logger)
+	if err := imageService.DeleteImage("missing", "admin"); errors.Is(err, ErrFilesRemain) {
+		t.Fatal("expected ErrFilesRemain only for deleted images")
+	}
+	embeddings := NewEmbeddingStore(filepath.Join(dataDir, "embeddings.json"))
+	embeddings.Set("a", []float32{1})
+	audit := NewAuditLog(filepath.Join(dataDir, "audit.jsonl"))
+	svc := NewErasureService(storage, indexService, imageService, nil, embeddings, nil, nil, audit, logger)
+
+	preview, err := svc.Preview("Jane Doe", "admin")
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	jobID, _, err := svc.Confirm("Jane Doe", preview.ConfirmationToken, "admin")
+	if err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+	if job := waitForJob(t, imageService, jobID); job.State != models.JobStateError || job.Progress.Done != 1 || job.Progress.Failed != 1 {
+		t.Fatalf("expected the image with files left to fail, got %+v", job)
+	}
+	if _, err := indexService.GetImageByID("a"); err == nil {
+		t.Error("expected the image to be out of the index")
+	}
+	if embeddings.Has("a") {
+		t.Error("expected its embedding to be dropped all the same")
+	}
+	entries, _ := audit.Entries(AuditErasureDelete, 10)
+	if len(entries) != 1 || entries[0].ImageID != "b" {
+		t.Errorf("expected an erasure audit entry only for b, got %+v", entries)
+	}
+}
+
+func TestErasure_DropsSpritesAndCachedAnalyses(t *testing.T) {
+	logger := logrus.New()
+	dataDir := t.TempDir()
+	indexService := NewIndexService(dataDir)
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dataDir, "categories", "animals"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"a.jpg": "fox", "c.jpg": "owl"} {
+		if err := os.WriteFile(filepath.Join(dataDir, "categories", "animals", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, img := range []*models.Image{
+		{ID: "a", Title: "A", Artist: "Jane Doe", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), FilePath: "categories/animals/a.jpg"},
+		{ID: "c", Title: "C", Artist: "John Roe", Category: "animals/birds", Type: models.ImageType2D, UploadedAt: time.Now(), FilePath: "categories/animals/c.jpg"},
+	} {
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	// Cached analyses of both images' content
+	cache := NewAnalysisCache(filepath.Join(dataDir, "analysis_cache.json"), 0)
+	hashes := make(map[string]string)
+	for id, name := range map[string]string{"a": "a.jpg", "c": "c.jpg"} {
+		hash, err := hashFile(filepath.Join(dataDir, "categories", "animals", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[id] = hash
+		cache.Put(AnalysisCacheKey{ContentHash: hash, Provider: AnalysisProvider, Model: "m", PromptVersion: "1"}, &models.AIAnalysis{Description: id})
+	}
+
+	// Sheets of the erased image's category and of one without it
+	sprites := NewSpriteService(indexService, NewStorageService(dataDir), dataDir, logger)
+	for category, ids := range map[string][]string{"animals": {"a", "c"}, "animals/birds": {"c"}} {
+		manifest := &SpriteManifest{Category: category}
+		for _, id := range ids {
+			manifest.Items = append(manifest.Items, SpriteItem{ID: id})
+		}
+		if err := sprites.save(manifest, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	}
+
+	storage := NewStorageService(dataDir)
+	imageService := NewImageService(storage, nil, indexService, nil, logger)
+	svc := NewErasureService(storage, indexService, imageService, nil, nil, nil, nil, NewAuditLog(filepath.Join(dataDir, "audit.jsonl")), logger)
+	svc.SetAnalysisCache(cache)
+	svc.SetSprites(sprites)
+
+	preview, err := svc.Preview("Jane Doe", "admin")
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if preview.Backups != ErasureBackupsNote {
+		t.Errorf("expected the preview to say backups are retained, got %q", preview.Backups)
+	}
+	jobID, _, err := svc.Confirm("Jane Doe", preview.ConfirmationToken, "admin")
+	if err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+	if job := waitForJob(t, imageService, jobID); job.State != models.JobStateCompleted {
+		t.Fatalf("unexpected job result %+v", job)
+	}
+
+	key := func(id string) AnalysisCacheKey {
+		return AnalysisCacheKey{ContentHash: hashes[id], Provider: AnalysisProvider, Model: "m", PromptVersion: "1"}
+	}
+	if _, ok := cache.Get(key("a")); ok {
+		t.Error("expected the erased image's cached analysis to be dropped")
+	}
+	if _, ok := cache.Get(key("c")); !ok {
+		t.Error("expected other cached analyses to be kept")
+	}
+	reloaded := NewAnalysisCache(cache.path, 0)
+	if err := reloaded.Load(); err != nil || reloaded.Stats().Entries != 1 {
+		t.Errorf("expected the dropped analysis to be saved, got %d entries (%v)", reloaded.Stats().Entries, err)
+	}
+
+	for category, want := range map[string]bool{"animals": false, "animals/birds": true} {
+		imagePath, _ := sprites.ImagePath(category)
+		_, imageErr := os.Stat(imagePath)
+		_, manifestErr := os.Stat(sprites.manifestPath(category))
+		if (imageErr == nil) != want || (manifestErr == nil) != want {
+			t.Errorf("%s sheet: expected present=%v, got %v and %v", category, want, imageErr, manifestErr)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// EvalSample is a labeled image of an evaluation set
+type EvalSample struct {
+	Image    string   `json:"image"`    // path, relative to the sample file
+	Category string   `json:"category"` // expected primary category, or primary/sub
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// LoadEvalSamples reads a JSON array of labeled samples and resolves image
+// paths relative to the file
+func LoadEvalSamples(path string) ([]EvalSample, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read samples: %w", err)
+	}
+
+	var samples []EvalSample
+	if err := json.Unmarshal(data, &samples); err != nil {
+		return nil, fmt.Errorf("failed to parse samples: %w", err)
+	}
+	for i := range samples {
+		if samples[i].Image == "" {
+			return nil, fmt.Errorf("sample %d has no image", i+1)
+		}
+		if !filepath.IsAbs(samples[i].Image) {
+			samples[i].Image = filepath.Join(filepath.Dir(path), samples[i].Image)
+		}
+	}
+	return samples, nil
+}
+
+// imageAnalyzer analyzes a 2D image; satisfied by AIService
+type imageAnalyzer interface {
+	Analyze2DImage(ctx context.Context, imagePath string) (*models.AIAnalysis, error)
+}
+
+// EvalVariant is one side of a comparison: a provider, model or prompt version
+type EvalVariant struct {
+	Name     string
+	Analyzer imageAnalyzer
+}
+
+// EvalOutcome is one variant's analysis of a sample
+type EvalOutcome struct {
+	Analysis        *models.AIAnalysis `json:"analysis,omitempty"`
+	Error           string             `json:"error,omitempty"`
+	LatencyMs       int64              `json:"latency_ms"`
+	CategoryCorrect bool               `json:"category_correct"`
+	TagOverlap      float64            `json:"tag_overlap"` // Jaccard with the labeled tags
+}
+
+// EvalSampleResult holds every variant's outcome for one sample
+type EvalSampleResult struct {
+	Sample   EvalSample              `json:"sample"`
+	Outcomes map[string]*EvalOutcome `json:"outcomes"` // variant name -> outcome
+}
+
+// EvalVariantScore summarizes a variant over the sample set
+type EvalVariantScore struct {
+	Name             string  `json:"name"`
+	Analyzed         int     `json:"analyzed"`
+	Failed           int     `json:"failed"`
+	CategoryAccuracy float64 `json:"category_accuracy"`
+	TagOverlap       float64 `json:"tag_overlap"` // mean Jaccard with the labeled tags
+	MeanLatencyMs    int64   `json:"mean_latency_ms"`
+}
+
+// EvalAgreement compares two variants on the samples both analyzed
+type EvalAgreement struct {
+	Compared   int     `json:"compared"`
+	Category   float64 `json:"category"`    // fraction with the same category
+	TagOverlap float64 `json:"tag_overlap"` // mean Jaccard of their tags
+}
+
+// EvalReport is the result of an evaluation run
+type EvalReport struct {
+	StartedAt time.Time          `json:"started_at"`
+	Samples   int                `json:"samples"`
+	Variants  []EvalVariantScore `json:"variants"`
+	Agreement *EvalAgreement     `json:"agreement,omitempty"` // first two variants
+	Results   []EvalSampleResult `json:"results"`
+}
+
+// Evaluate runs every variant over the samples and scores them against the
+// labels and each other. Analysis failures are recorded, not fatal.
+func Evaluate(ctx context.Context, samples []EvalSample, variants []EvalVariant, logger *logrus.Logger) (*EvalReport, error) {
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("no variants to evaluate")
+	}
+
+	report := &EvalReport{StartedAt: time.Now(), Samples: len(samples)}
+	for i, sample := range samples {
+		result := EvalSampleResult{Sample: sample, Outcomes: make(map[string]*EvalOutcome)}
+		for _, variant := range variants {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			start := time.Now()
+			analysis, err := variant.Analyzer.Analyze2DImage(ctx, sample.Image)
+			outcome := &EvalOutcome{LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				logger.Warnf("[%s] sample %d (%s) failed: %v", variant.Name, i+1, filepath.Base(sample.Image), err)
+				outcome.Error = err.Error()
+			} else {
+				analysis.RawResponse = ""
+				outcome.Analysis = analysis
+				outcome.CategoryCorrect = categoryMatches(sample.Category, analysis)
+				outcome.TagOverlap = jaccard(sample.Tags, analysisTags(analysis))
+			}
+			result.Outcomes[variant.Name] = outcome
+		}
+		report.Results = append(report.Results, result)
+		logger.Infof("Evaluated sample %d/%d", i+1, len(samples))
+	}
+
+	for _, variant := range variants {
+		report.Variants = append(report.Variants, scoreVariant(variant.Name, report.Results))
+	}
+	if len(variants) >= 2 {
+		report.Agreement = agreement(variants[0].Name, variants[1].Name, report.Results)
+	}
+	return report, nil
+}
+
+// scoreVariant aggregates a variant's outcomes
+func scoreVariant(name string, results []EvalSampleResult) EvalVariantScore {
+	score := EvalVariantScore{Name: name}
+	var correct int
+	var overlap float64
+	var latency int64
+	for _, result := range results {
+		outcome := result.Outcomes[name]
+		latency += outcome.LatencyMs
+		if outcome.Analysis == nil {
+			score.Failed++
+			continue
+		}
+		score.Analyzed++
+		if outcome.CategoryCorrect {
+			correct++
+		}
+		overlap += outcome.TagOverlap
+	}
+
+	if score.Analyzed > 0 {
+		score.CategoryAccuracy = float64(correct) / float64(score.Analyzed)
+		score.TagOverlap = overlap / float64(score.Analyzed)
+	}
+	if len(results) > 0 {
+		score.MeanLatencyMs = latency / int64(len(results))
+	}
+	return score
+}
+
+// agreement compares two variants on the samples both analyzed
+func agreement(a, b string, results []EvalSampleResult) *EvalAgreement {
+	agree := &EvalAgreement{}
+	var sameCategory int
+	var overlap float64
+	for _, result := range results {
+		left, right := result.Outcomes[a].Analysis, result.Outcomes[b].Analysis
+		if left == nil || right == nil {
+			continue
+		}
+		agree.Compared++
+		if strings.EqualFold(left.PrimaryCategory, right.PrimaryCategory) {
+			sameCategory++
+		}
+		overlap += jaccard(analysisTags(left), analysisTags(right))
+	}
+
+	if agree.Compared > 0 {
+		agree.Category = float64(sameCategory) / float64(agree.Compared)
+		agree.TagOverlap = overlap / float64(agree.Compared)
+	}
+	return agree
+}
+
+// categoryMatches reports whether an analysis has the labeled category. A
+// label with a sub category ("animals/cats") must match both levels.
+func categoryMatches(label string, analysis *models.AIAnalysis) bool {
+	primary, sub, hasSub := strings.Cut(label, "/")
+	if !strings.EqualFold(strings.TrimSpace(primary), analysis.PrimaryCategory) {
+		return false
+	}
+	return !hasSub || strings.EqualFold(strings.TrimSpace(sub), analysis.SubCategory)
+}
+
+// analysisTags returns the tags (feature names) of an analysis
+func analysisTags(analysis *models.AIAnalysis) []string {
+	tags := make([]string, 0, len(analysis.Features))
+	for _, feature := range analysis.Features {
+		tags = append(tags, feature.Name)
+	}
+	return tags
+}
+
+// jaccard returns the case-insensitive Jaccard similarity of two tag sets;
+// two empty sets are identical
+func jaccard(a, b []string) float64 {
+	setA := make(map[string]bool, len(a))
+	for _, tag := range a {
+		setA[strings.ToLower(strings.TrimSpace(tag))] = true
+	}
+	setB := make(map[string]bool, len(b))
+	for _, tag := range b {
+		setB[strings.ToLower(strings.TrimSpace(tag))] = true
+	}
+	if len(setA) == 0 && len(setB) == 0 {
+		return 1
+	}
+
+	var shared int
+	for tag := range setA {
+		if setB[tag] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(setA)+len(setB)-shared)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// stubAnalyzer returns a canned analysis per image name
+type stubAnalyzer map[string]*models.AIAnalysis
+
+func (s stubAnalyzer) Analyze2DImage(ctx context.Context, imagePath string) (*models.AIAnalysis, error) {
+	if analysis, ok := s[filepath.Base(imagePath)]; ok {
+		copied := *analysis
+		return &copied, nil
+	}
+	return nil, errors.New("model refused")
+}
+
+func analysisWith(category, sub string, tags ...string) *models.AIAnalysis {
+	analysis := &models.AIAnalysis{PrimaryCategory: category, SubCategory: sub}
+	for _, tag := range tags {
+		analysis.Features = append(analysis.Features, models.Feature{Name: tag, Confidence: 1})
+	}
+	return analysis
+}
+
+func TestLoadEvalSamples(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "samples.json")
+	os.WriteFile(path, []byte(`[{"image": "cat.jpg", "category": "animals/cats", "tags": ["cat"]}]`), 0644)
+
+	samples, err := LoadEvalSamples(path)
+	if err != nil {
+		t.Fatalf("LoadEvalSamples failed: %v", err)
+	}
+	if len(samples) != 1 || samples[0].Image != filepath.Join(dir, "cat.jpg") {
+		t.Errorf("expected image path resolved against the sample file, got %+v", samples)
+	}
+
+	os.WriteFile(path, []byte(`[{"category": "animals"}]`), 0644)
+	if _, err := LoadEvalSamples(path); err == nil {
+		t.Error("expected an error for a sample without an image")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	samples := []EvalSample{
+		{Image: "cat.jpg", Category: "animals/cats", Tags: []string{"cat", "night"}},
+		{Image: "hill.jpg", Category: "landscapes", Tags: []string{"hill", "sky"}},
+	}
+	a := stubAnalyzer{
+		"cat.jpg":  analysisWith("animals", "cats", "Cat", "night"),
+		"hill.jpg": analysisWith("landscapes", "", "hill", "grass"),
+	}
+	b := stubAnalyzer{
+		"cat.jpg": analysisWith("animals", "dogs", "cat", "dark"),
+	}
+
+	logger := logrus.New()
+	report, err := Evaluate(context.Background(), samples, []EvalVariant{{"A", a}, {"B", b}}, logger)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+
+	scoreA, scoreB := report.Variants[0], report.Variants[1]
+	if scoreA.Analyzed != 2 || scoreA.CategoryAccuracy != 1 {
+		t.Errorf("unexpected score for A: %+v", scoreA)
+	}
+	// Tag overlap: cat sample 2/2, hill sample 1/3
+	if want := (1.0 + 1.0/3) / 2; scoreA.TagOverlap < want-1e-9 || scoreA.TagOverlap > want+1e-9 {
+		t.Errorf("expected tag overlap %.3f for A, got %.3f", want, scoreA.TagOverlap)
+	}
+	if scoreB.Analyzed != 1 || scoreB.Failed != 1 || scoreB.CategoryAccuracy != 0 {
+		t.Errorf("unexpected score for B: %+v", scoreB)
+	}
+
+	// Only the cat sample is compared: same primary category, tags {cat,night} vs {cat,dark}
+	if report.Agreement.Compared != 1 || report.Agreement.Category != 1 || report.Agreement.TagOverlap != 1.0/3 {
+		t.Errorf("unexpected agreement %+v", report.Agreement)
+	}
+	if report.Results[1].Outcomes["B"].Error == "" || report.Results[0].Outcomes["B"].Analysis == nil {
+		t.Errorf("expected both analyses and failures stored, got %+v", report.Results)
+	}
+}
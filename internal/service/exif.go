@@ -0,0 +1,264 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// exifJPEGMarker prefixes the APP1 segment that carries EXIF data
+var exifJPEGMarker = []byte("Exif\x00\x00")
+
+// EXIF tags needed to find the GPS position and capture time
+const (
+	exifTagExifIFD          = 0x8769
+	exifTagDateTimeOriginal = 0x9003
+	exifTagGPSIFD           = 0x8825
+	exifTagGPSLatRef        = 0x0001
+	exifTagGPSLatitude      = 0x0002
+	exifTagGPSLonRef        = 0x0003
+	exifTagGPSLongitude     = 0x0004
+)
+
+// errNoGPS means the EXIF data has no usable GPS position
+var errNoGPS = errors.New("no GPS position")
+
+// readJPEGEXIF returns the EXIF (TIFF) data of a JPEG, or nil if the file
+// is not a JPEG or has none
+func readJPEGEXIF(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	magic, err := r.Peek(2)
+	if err != nil || magic[0] != 0xFF || magic[1] != 0xD8 {
+		return nil, nil
+	}
+
+	var exif []byte
+	err = walkJPEGSegments(r, func(marker byte, segment []byte) bool {
+		if marker == 0xE1 && bytes.HasPrefix(segment, exifJPEGMarker) {
+			exif = segment[len(exifJPEGMarker):]
+			return false
+		}
+		return true
+	})
+	return exif, err
+}
+
+// ReadGPSLocation returns the GPS position in a JPEG's EXIF data, or nil if
+// the file has none. A 0,0 position is treated as no fix.
+func ReadGPSLocation(path string) (*models.GeoPoint, error) {
+	exif, err := readJPEGEXIF(path)
+	if err != nil || exif == nil {
+		return nil, err
+	}
+
+	point, err := parseEXIFGPS(exif)
+	if errors.Is(err, errNoGPS) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return point, nil
+}
+
+// ReadCaptureTime returns when a JPEG was taken (EXIF DateTimeOriginal), or
+// nil if it isn't recorded. EXIF has no time zone; the camera's local time is
+// read as local time.
+func ReadCaptureTime(path string) (*time.Time, error) {
+	exif, err := readJPEGEXIF(path)
+	if err != nil || exif == nil {
+		return nil, err
+	}
+	return parseEXIFCaptureTime(exif)
+}
+
+// DetectCaptureTime returns when an image file was taken, or nil if it isn't
+// recorded or can't be read
+func DetectCaptureTime(path string) *time.Time {
+	captured, err := ReadCaptureTime(path)
+	if err != nil {
+		return nil
+	}
+	return captured
+}
+
+// DetectLocation returns the GPS position of an image file, or nil if it has
+// none or it can't be read
+func DetectLocation(path string) *models.GeoPoint {
+	point, err := ReadGPSLocation(path)
+	if err != nil {
+		return nil
+	}
+	return point
+}
+
+// tiffReader reads values from the TIFF structure of EXIF data
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry is one tag of an image file directory
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte // the 4-byte value/offset field
+}
+
+// newTIFFReader checks the TIFF header of EXIF data and reads its first
+// directory
+func newTIFFReader(data []byte) (*tiffReader, map[uint16]ifdEntry, error) {
+	if len(data) < 8 {
+		return nil, nil, fmt.Errorf("EXIF data too short")
+	}
+	t := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, nil, fmt.Errorf("invalid EXIF byte order")
+	}
+
+	ifd0, err := t.readIFD(t.order.Uint32(data[4:8]))
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, ifd0, nil
+}
+
+// parseEXIFCaptureTime reads DateTimeOriginal from EXIF (TIFF) data
+func parseEXIFCaptureTime(data []byte) (*time.Time, error) {
+	t, ifd0, err := newTIFFReader(data)
+	if err != nil {
+		return nil, err
+	}
+	exifPointer, ok := ifd0[exifTagExifIFD]
+	if !ok {
+		return nil, nil
+	}
+	exifIFD, err := t.readIFD(t.order.Uint32(exifPointer.value))
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := exifIFD[exifTagDateTimeOriginal]
+	if !ok {
+		return nil, nil
+	}
+
+	// "2006:01:02 15:04:05" plus a NUL, stored at an offset
+	const asciiType = 2
+	offset := int(t.order.Uint32(entry.value))
+	if entry.typ != asciiType || entry.count < 19 || offset < 0 || offset+19 > len(t.data) {
+		return nil, fmt.Errorf("invalid DateTimeOriginal")
+	}
+	raw := strings.TrimRight(string(t.data[offset:offset+19]), "\x00 ")
+	captured, err := time.ParseInLocation("2006:01:02 15:04:05", raw, time.Local)
+	if err != nil {
+		// Cameras without a clock write blanks or zeros
+		return nil, nil
+	}
+	return &captured, nil
+}
+
+// parseEXIFGPS reads the GPS latitude and longitude from EXIF (TIFF) data
+func parseEXIFGPS(data []byte) (*models.GeoPoint, error) {
+	t, ifd0, err := newTIFFReader(data)
+	if err != nil {
+		return nil, err
+	}
+	gpsPointer, ok := ifd0[exifTagGPSIFD]
+	if !ok {
+		return nil, errNoGPS
+	}
+	gps, err := t.readIFD(t.order.Uint32(gpsPointer.value))
+	if err != nil {
+		return nil, err
+	}
+
+	lat, err := t.degrees(gps, exifTagGPSLatitude, exifTagGPSLatRef, 'S')
+	if err != nil {
+		return nil, err
+	}
+	lon, err := t.degrees(gps, exifTagGPSLongitude, exifTagGPSLonRef, 'W')
+	if err != nil {
+		return nil, err
+	}
+	if lat == 0 && lon == 0 {
+		return nil, errNoGPS
+	}
+
+	point := &models.GeoPoint{Lat: lat, Lon: lon}
+	return point, point.Validate()
+}
+
+// readIFD reads the entries of the directory at offset, keyed by tag
+func (t *tiffReader) readIFD(offset uint32) (map[uint16]ifdEntry, error) {
+	if uint64(offset)+2 > uint64(len(t.data)) {
+		return nil, fmt.Errorf("EXIF directory out of range")
+	}
+	count := int(t.order.Uint16(t.data[offset:]))
+	start := int(offset) + 2
+	if start+count*12 > len(t.data) {
+		return nil, fmt.Errorf("EXIF directory truncated")
+	}
+
+	entries := make(map[uint16]ifdEntry, count)
+	for i := 0; i < count; i++ {
+		e := t.data[start+i*12 : start+(i+1)*12]
+		entries[t.order.Uint16(e[0:2])] = ifdEntry{
+			typ:   t.order.Uint16(e[2:4]),
+			count: t.order.Uint32(e[4:8]),
+			value: e[8:12],
+		}
+	}
+	return entries, nil
+}
+
+// degrees reads a degrees/minutes/seconds coordinate and its hemisphere
+// reference, negating it for the negative hemisphere
+func (t *tiffReader) degrees(gps map[uint16]ifdEntry, tag, refTag uint16, negative byte) (float64, error) {
+	entry, ok := gps[tag]
+	if !ok {
+		return 0, errNoGPS
+	}
+	const rationalType = 5
+	if entry.typ != rationalType || entry.count != 3 {
+		return 0, fmt.Errorf("invalid GPS coordinate")
+	}
+	offset := int(t.order.Uint32(entry.value))
+	if offset < 0 || offset+24 > len(t.data) {
+		return 0, fmt.Errorf("GPS coordinate out of range")
+	}
+
+	var dms [3]float64
+	for i := range dms {
+		num := t.order.Uint32(t.data[offset+i*8:])
+		den := t.order.Uint32(t.data[offset+i*8+4:])
+		if den == 0 {
+			return 0, fmt.Errorf("invalid GPS coordinate")
+		}
+		dms[i] = float64(num) / float64(den)
+	}
+	value := dms[0] + dms[1]/60 + dms[2]/3600
+
+	// The reference is a short ASCII string stored inline ("N", "S", "E", "W")
+	if ref, ok := gps[refTag]; ok && ref.value[0] == negative {
+		value = -value
+	}
+	return value, nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildTestEXIF builds little-endian EXIF data with a GPS directory holding
+// the given degrees/minutes/seconds and hemisphere references
+func buildTestEXIF(latRef string, lat [3]uint32, lonRef string, lon [3]uint32) []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	b.WriteString("II")
+	binary.Write(&b, le, uint16(42))
+	binary.Write(&b, le, uint32(8)) // IFD0 offset
+
+	// IFD0: one entry pointing at the GPS directory (offset 26)
+	binary.Write(&b, le, uint16(1))
+	binary.Write(&b, le, []uint16{exifTagGPSIFD, 4})
+	binary.Write(&b, le, []uint32{1, 26})
+	binary.Write(&b, le, uint32(0))
+
+	// GPS IFD: 4 entries, rationals after the directory
+	const dataOffset = 26 + 2 + 4*12 + 4
+	entry := func(tag, typ uint16, count, value uint32) {
+		binary.Write(&b, le, []uint16{tag, typ})
+		binary.Write(&b, le, []uint32{count, value})
+	}
+	ascii := func(ref string) uint32 { return uint32(ref[0]) }
+	binary.Write(&b, le, uint16(4))
+	entry(exifTagGPSLatRef, 2, 2, ascii(latRef))
+	entry(exifTagGPSLatitude, 5, 3, dataOffset)
+	entry(exifTagGPSLonRef, 2, 2, ascii(lonRef))
+	entry(exifTagGPSLongitude, 5, 3, dataOffset+24)
+	binary.Write(&b, le, uint32(0))
+
+	for _, v := range append(lat[:], lon[:]...) {
+		binary.Write(&b, le, []uint32{v, 1})
+	}
+	return b.Bytes()
+}
+
+// embedTestEXIF inserts an EXIF APP1 segment right after a JPEG's SOI marker
+func embedTestEXIF(t *testing.T, path string, exif []byte) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segment := append(append([]byte{}, exifJPEGMarker...), exif...)
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(data[2:])
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadGPSLocation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.jpg")
+	saveTestImage(t, path, color.NRGBA{100, 150, 100, 255})
+
+	if point, err := ReadGPSLocation(path); err != nil || point != nil {
+		t.Fatalf("expected no location, got %v, %v", point, err)
+	}
+
+	// 33°51'54"S 151°12'36"E (Sydney)
+	embedTestEXIF(t, path, buildTestEXIF("S", [3]uint32{33, 51, 54}, "E", [3]uint32{151, 12, 36}))
+	point, err := ReadGPSLocation(path)
+	if err != nil || point == nil {
+		t.Fatalf("ReadGPSLocation failed: %v", err)
+	}
+	if math.Abs(point.Lat-(-33.865)) > 1e-6 || math.Abs(point.Lon-151.21) > 1e-6 {
+		t.Errorf("expected -33.865,151.21, got %s", point)
+	}
+
+	// A 0,0 position is no fix
+	nullIsland := filepath.Join(dir, "null.jpg")
+	saveTestImage(t, nullIsland, color.NRGBA{100, 150, 100, 255})
+	embedTestEXIF(t, nullIsland, buildTestEXIF("N", [3]uint32{}, "E", [3]uint32{}))
+	if point, err := ReadGPSLocation(nullIsland); err != nil || point != nil {
+		t.Errorf("expected no location for 0,0, got %v, %v", point, err)
+	}
+}
+
+func TestParseEXIFCaptureTime(t *testing.T) {
+	build := func(value string) []byte {
+		var b bytes.Buffer
+		be := binary.BigEndian
+		b.WriteString("MM")
+		binary.Write(&b, be, uint16(42))
+		binary.Write(&b, be, uint32(8))
+
+		// IFD0 -> Exif IFD at 26 -> DateTimeOriginal at 44
+		binary.Write(&b, be, uint16(1))
+		binary.Write(&b, be, []uint16{exifTagExifIFD, 4})
+		binary.Write(&b, be, []uint32{1, 26})
+		binary.Write(&b, be, uint32(0))
+		binary.Write(&b, be, uint16(1))
+		binary.Write(&b, be, []uint16{exifTagDateTimeOriginal, 2})
+		binary.Write(&b, be, []uint32{20, 44})
+		binary.Write(&b, be, uint32(0))
+		b.WriteString(value + "\x00")
+		return b.Bytes()
+	}
+
+	captured, err := parseEXIFCaptureTime(build("2024:12:24 18:05:09"))
+	if err != nil || captured == nil {
+		t.Fatalf("parseEXIFCaptureTime failed: %v", err)
+	}
+	if got := captured.Format("2006-01-02 15:04:05"); got != "2024-12-24 18:05:09" {
+		t.Errorf("expected 2024-12-24 18:05:09, got %s", got)
+	}
+
+	if captured, err := parseEXIFCaptureTime(build("0000:00:00 00:00:00")); err != nil || captured != nil {
+		t.Errorf("expected no capture time for a zero date, got %v, %v", captured, err)
+	}
+}
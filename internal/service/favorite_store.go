@@ -0,0 +1,195 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// FavoriteStore keeps the images each user starred, persisted as JSON next
+// to the index. Users are principal names (API token names).
+type FavoriteStore struct {
+	file      *sharedFile
+	favorites map[string]map[string]time.Time // user -> image ID -> starred at
+	modified  time.Time
+	mutex     sync.RWMutex
+}
+
+func NewFavoriteStore(path string) *FavoriteStore {
+	return &FavoriteStore{
+		file:      newSharedFile(path),
+		favorites: make(map[string]map[string]time.Time),
+	}
+}
+
+// SetCoordinator shares the favorites with the other instances using c
+func (s *FavoriteStore) SetCoordinator(c *Coordinator) {
+	s.file.share(c)
+}
+
+// Load reads stored favorites from disk, if the file exists
+func (s *FavoriteStore) Load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.load()
+}
+
+// load replaces the favorites with the file's. Caller must hold the lock.
+func (s *FavoriteStore) load() error {
+	data, err := s.file.read()
+	if err != nil {
+		return fmt.Errorf("failed to read favorites: %w", err)
+	}
+	favorites := make(map[string]map[string]time.Time)
+	if data != nil {
+		if err := json.Unmarshal(data, &favorites); err != nil {
+			return fmt.Errorf("failed to parse favorites: %w", err)
+		}
+		if info, err := os.Stat(s.file.path); err == nil {
+			s.modified = info.ModTime()
+		}
+	}
+	s.favorites = favorites
+	return nil
+}
+
+// refresh reloads the favorites if another instance changed them. If that
+// fails, the ones in memory are served.
+func (s *FavoriteStore) refresh() {
+	if s.file.changed() {
+		s.mutex.Lock()
+		s.load()
+		s.mutex.Unlock()
+	}
+}
+
+// Add stars an image for a user and returns the favorite. Starring an image
+// twice keeps the original time.
+func (s *FavoriteStore) Add(user, imageID string) (models.Favorite, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	end, err := s.file.begin(s.load)
+	if err != nil {
+		return models.Favorite{}, err
+	}
+	defer end()
+
+	images := s.favorites[user]
+	if starred, ok := images[imageID]; ok {
+		return models.Favorite{ImageID: imageID, CreatedAt: starred}, nil
+	}
+	if images == nil {
+		images = make(map[string]time.Time)
+		s.favorites[user] = images
+	}
+
+	now := time.Now()
+	images[imageID] = now
+	if err := s.save(); err != nil {
+		delete(images, imageID)
+		return models.Favorite{}, err
+	}
+	return models.Favorite{ImageID: imageID, CreatedAt: now}, nil
+}
+
+// Remove unstars an image for a user and reports whether it was starred
+func (s *FavoriteStore) Remove(user, imageID string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	end, err := s.file.begin(s.load)
+	if err != nil {
+		return false, err
+	}
+	defer end()
+
+	starred, ok := s.favorites[user][imageID]
+	if !ok {
+		return false, nil
+	}
+	delete(s.favorites[user], imageID)
+	if err := s.save(); err != nil {
+		s.favorites[user][imageID] = starred
+		return false, err
+	}
+	return true, nil
+}
+
+// List returns a user's favorites, most recently starred first
+func (s *FavoriteStore) List(user string) []models.Favorite {
+	s.refresh()
+	s.mutex.RLock()
+	favorites := make([]models.Favorite, 0, len(s.favorites[user]))
+	for id, starred := range s.favorites[user] {
+		favorites = append(favorites, models.Favorite{ImageID: id, CreatedAt: starred})
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(favorites, func(i, j int) bool {
+		if !favorites[i].CreatedAt.Equal(favorites[j].CreatedAt) {
+			return favorites[i].CreatedAt.After(favorites[j].CreatedAt)
+		}
+		return favorites[i].ImageID < favorites[j].ImageID
+	})
+	return favorites
+}
+
+// Starred returns the set of image IDs a user starred
+func (s *FavoriteStore) Starred(user string) map[string]bool {
+	s.refresh()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	starred := make(map[string]bool, len(s.favorites[user]))
+	for id := range s.favorites[user] {
+		starred[id] = true
+	}
+	return starred
+}
+
+// Forget unstars a deleted image for every user
+func (s *FavoriteStore) Forget(imageID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	end, err := s.file.begin(s.load)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	changed := false
+	for _, images := range s.favorites {
+		if _, ok := images[imageID]; ok {
+			delete(images, imageID)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// LastModified returns when a favorite was last added or removed
+func (s *FavoriteStore) LastModified() time.Time {
+	s.refresh()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.modified
+}
+
+// save writes all favorites to disk atomically. Caller must hold the lock.
+func (s *FavoriteStore) save() error {
+	s.modified = time.Now()
+	data, err := json.Marshal(s.favorites)
+	if err != nil {
+		return fmt.Errorf("failed to encode favorites: %w", err)
+	}
+	if err := s.file.write(data, 0644); err != nil {
+		return fmt.Errorf("failed to write favorites: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFavoriteStore_PerUser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "favorites.json")
+	store := NewFavoriteStore(path)
+
+	first, err := store.Add("alice", "img-1")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := store.Add("alice", "img-2"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := store.Add("bob", "img-2"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	again, _ := store.Add("alice", "img-1")
+	if !again.CreatedAt.Equal(first.CreatedAt) {
+		t.Error("starring twice should keep the original time")
+	}
+
+	if list := store.List("alice"); len(list) != 2 || list[0].ImageID != "img-2" || list[1].ImageID != "img-1" {
+		t.Errorf("expected alice's favorites newest first, got %+v", list)
+	}
+	if starred := store.Starred("bob"); len(starred) != 1 || !starred["img-2"] {
+		t.Errorf("unexpected favorites for bob: %v", starred)
+	}
+	if list := store.List("carol"); len(list) != 0 {
+		t.Errorf("expected no favorites for carol, got %+v", list)
+	}
+
+	if removed, err := store.Remove("alice", "img-1"); err != nil || !removed {
+		t.Fatalf("expected img-1 to be removed, got %v (%v)", removed, err)
+	}
+	if removed, _ := store.Remove("alice", "img-1"); removed {
+		t.Error("removing twice should report nothing removed")
+	}
+
+	// A deleted image leaves every list
+	if err := store.Forget("img-2"); err != nil {
+		t.Fatalf("Forget failed: %v", err)
+	}
+
+	reloaded := NewFavoriteStore(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(reloaded.List("alice")) != 0 || len(reloaded.List("bob")) != 0 {
+		t.Errorf("expected no favorites after reload, got %+v %+v", reloaded.List("alice"), reloaded.List("bob"))
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// featureBoost is how far a matching AI feature moves a search result's
+// relevance towards 1, at full confidence
+const featureBoost = 0.25
+
+// FeatureFilter restricts images by the confidence of their AI features;
+// the zero filter matches everything
+type FeatureFilter struct {
+	Feature       string  // feature name; empty uses the search query's terms
+	MinConfidence float64 // 0-1
+}
+
+// IsZero reports whether the filter matches everything
+func (f FeatureFilter) IsZero() bool {
+	return f == FeatureFilter{}
+}
+
+// Matches reports whether img has a feature matching the filter's feature,
+// or else a term of query, with at least the minimum confidence. Without
+// either there is nothing to match and every image passes.
+func (f FeatureFilter) Matches(img *ImageMetadata, query string) bool {
+	terms := queryTerms(query)
+	if f.Feature != "" {
+		terms = queryTerms(f.Feature)
+	}
+	if f.IsZero() || len(terms) == 0 {
+		return true
+	}
+	confidence, ok := featureConfidence(img, terms)
+	return ok && confidence >= f.MinConfidence
+}
+
+// Validate checks that the minimum confidence is a probability
+func (f FeatureFilter) Validate() error {
+	if f.MinConfidence < 0 || f.MinConfidence > 1 {
+		return fmt.Errorf("min_confidence must be between 0 and 1")
+	}
+	return nil
+}
+
+// featureConfidence returns the highest confidence among img's features
+// that contain one of terms, and whether any does
+func featureConfidence(img *ImageMetadata, terms []string) (float64, bool) {
+	best, found := 0.0, false
+	for _, feature := range img.Features {
+		if len(highlightTerms(feature.Name, terms)) > 0 && (!found || feature.Confidence > best) {
+			best, found = feature.Confidence, true
+		}
+	}
+	return best, found
+}
+
+// boostByFeatures raises a result's relevance by the confidence of the
+// image's features matching the query, never past 1
+func boostByFeatures(score float64, img *ImageMetadata, terms []string) float64 {
+	confidence, ok := featureConfidence(img, terms)
+	if !ok || score >= 1 {
+		return score
+	}
+	return score + featureBoost*confidence*(1-score)
+}
+
+// parseFeatures parses the AI Features field of the index, e.g.
+// "cat (0.98), window (0.80)"
+func parseFeatures(value string) []models.Feature {
+	var features []models.Feature
+	for _, item := range splitIndexList(value, ", ") {
+		feature := models.Feature{Name: item}
+		if open := strings.LastIndex(item, " ("); open > 0 && strings.HasSuffix(item, ")") {
+			if confidence, err := strconv.ParseFloat(item[open+2:len(item)-1], 64); err == nil {
+				feature = models.Feature{Name: item[:open], Confidence: confidence}
+			}
+		}
+		if feature.Name != "" {
+			features = append(features, feature)
+		}
+	}
+	return features
+}
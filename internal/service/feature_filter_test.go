@@ -0,0 +1,79 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestFeatureFilter_Matches(t *testing.T) {
+	cat := &ImageMetadata{Features: []models.Feature{{Name: "black cat", Confidence: 0.95}, {Name: "window", Confidence: 0.4}}}
+	unsure := &ImageMetadata{Features: []models.Feature{{Name: "cat", Confidence: 0.3}}}
+	none := &ImageMetadata{}
+
+	cases := []struct {
+		name   string
+		img    *ImageMetadata
+		filter FeatureFilter
+		query  string
+		want   bool
+	}{
+		{"query term, confident", cat, FeatureFilter{MinConfidence: 0.8}, "cats at night", true},
+		{"query term, unsure", unsure, FeatureFilter{MinConfidence: 0.8}, "cats at night", false},
+		{"no matching feature", none, FeatureFilter{MinConfidence: 0.8}, "cats", false},
+		{"named feature", cat, FeatureFilter{Feature: "window", MinConfidence: 0.3}, "cats", true},
+		{"named feature below minimum", cat, FeatureFilter{Feature: "window", MinConfidence: 0.5}, "cats", false},
+		{"named feature, any confidence", unsure, FeatureFilter{Feature: "cat"}, "", true},
+		{"no terms", unsure, FeatureFilter{MinConfidence: 0.8}, "", true},
+		{"no filter", none, FeatureFilter{}, "cats", true},
+	}
+	for _, tc := range cases {
+		if got := tc.filter.Matches(tc.img, tc.query); got != tc.want {
+			t.Errorf("%s: Matches = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	if err := (FeatureFilter{MinConfidence: 1.5}).Validate(); err == nil {
+		t.Error("expected an error for a confidence above 1")
+	}
+}
+
+func TestParseFeatures(t *testing.T) {
+	got := parseFeatures("cat (0.98), window (sill) (0.80), plain")
+	want := []models.Feature{{Name: "cat", Confidence: 0.98}, {Name: "window (sill)", Confidence: 0.8}, {Name: "plain"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFeatures = %+v, want %+v", got, want)
+	}
+}
+
+func TestFilterIndexed_BoostsByFeatureConfidence(t *testing.T) {
+	byID := imagesByID([]*ImageMetadata{
+		{ID: "plain"},
+		{ID: "cat", Features: []models.Feature{{Name: "cat", Confidence: 0.9}}},
+		{ID: "maybe-cat", Features: []models.Feature{{Name: "cat", Confidence: 0.2}}},
+	})
+	results := []models.SearchResult{
+		{ImageID: "plain", RelevanceScore: 0.8},
+		{ImageID: "maybe-cat", RelevanceScore: 0.78},
+		{ImageID: "cat", RelevanceScore: 0.75},
+	}
+
+	ranked := filterIndexed(results, "cats", SearchFilter{}, byID, nil)
+	var ids []string
+	for _, r := range ranked {
+		ids = append(ids, r.ImageID)
+	}
+	if !reflect.DeepEqual(ids, []string{"cat", "plain", "maybe-cat"}) {
+		t.Errorf("expected the confident cat first, got %v", ids)
+	}
+	if ranked[0].RelevanceScore > 1 || ranked[1].RelevanceScore != 0.8 {
+		t.Errorf("unexpected scores %+v", ranked)
+	}
+
+	filtered := filterIndexed([]models.SearchResult{{ImageID: "cat"}, {ImageID: "maybe-cat"}, {ImageID: "plain"}}, "cats",
+		SearchFilter{Features: FeatureFilter{MinConfidence: 0.5}}, byID, nil)
+	if len(filtered) != 1 || filtered[0].ImageID != "cat" {
+		t.Errorf("expected only the confident cat, got %+v", filtered)
+	}
+}
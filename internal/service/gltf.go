@@ -0,0 +1,271 @@
+package service
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// glTF binary container constants (glTF 2.0, section 4.4)
+const (
+	glbMagic     = 0x46546C67 // "glTF"
+	glbChunkJSON = 0x4E4F534A // "JSON"
+	glbChunkBIN  = 0x004E4942 // "BIN"
+	maxGLTFJSON  = 64 << 20
+)
+
+// gltfDocument is the part of a glTF 2.0 document the warehouse reads
+type gltfDocument struct {
+	Asset struct {
+		Version string `json:"version"`
+	} `json:"asset"`
+	Scene  *int `json:"scene"`
+	Scenes []struct {
+		Nodes []int `json:"nodes"`
+	} `json:"scenes"`
+	Nodes     []gltfNode `json:"nodes"`
+	Meshes    []gltfMesh `json:"meshes"`
+	Accessors []struct {
+		Count int       `json:"count"`
+		Min   []float64 `json:"min"`
+		Max   []float64 `json:"max"`
+	} `json:"accessors"`
+	Materials []struct {
+		PBR        *struct{}                  `json:"pbrMetallicRoughness"`
+		Extensions map[string]json.RawMessage `json:"extensions"`
+	} `json:"materials"`
+	Images []struct {
+		URI        string `json:"uri"`
+		BufferView *int   `json:"bufferView"`
+	} `json:"images"`
+	BufferViews []struct {
+		Buffer     int   `json:"buffer"`
+		ByteOffset int64 `json:"byteOffset"`
+		ByteLength int64 `json:"byteLength"`
+	} `json:"bufferViews"`
+
+	// binOffset is the file offset of a GLB's binary chunk (buffer 0), or
+	// -1 for .gltf files and GLBs without one
+	binOffset int64
+}
+
+type gltfNode struct {
+	Children    []int     `json:"children"`
+	Mesh        *int      `json:"mesh"`
+	Matrix      []float64 `json:"matrix"`      // column-major 4x4
+	Translation []float64 `json:"translation"` // x, y, z
+	Rotation    []float64 `json:"rotation"`    // quaternion x, y, z, w
+	Scale       []float64 `json:"scale"`       // x, y, z
+}
+
+type gltfMesh struct {
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Mode       *int           `json:"mode"` // topology; triangles (4) when absent
+}
+
+// glTF primitive topologies that form triangles
+const (
+	gltfTriangles     = 4
+	gltfTriangleStrip = 5
+	gltfTriangleFan   = 6
+)
+
+// triangles returns the number of triangles the primitive draws
+func (d *gltfDocument) triangles(p *gltfPrimitive) int {
+	index, ok := p.Attributes["POSITION"]
+	if p.Indices != nil {
+		index, ok = *p.Indices, true
+	}
+	if !ok || index < 0 || index >= len(d.Accessors) {
+		return 0
+	}
+	count := d.Accessors[index].Count
+
+	mode := gltfTriangles
+	if p.Mode != nil {
+		mode = *p.Mode
+	}
+	switch {
+	case mode == gltfTriangles:
+		return count / 3
+	case (mode == gltfTriangleStrip || mode == gltfTriangleFan) && count >= 3:
+		return count - 2
+	default:
+		return 0
+	}
+}
+
+// readGLTF reads the JSON document of a .gltf file or .glb container.
+// Buffers are not loaded.
+func readGLTF(path string) (*gltfDocument, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model: %w", err)
+	}
+	defer file.Close()
+
+	var header [12]byte
+	n, err := io.ReadFull(file, header[:])
+	var data []byte
+	binOffset := int64(-1)
+	if err == nil && binary.LittleEndian.Uint32(header[0:4]) == glbMagic {
+		data, err = readGLBJSON(file)
+		if err != nil {
+			return nil, err
+		}
+		binOffset = glbBINOffset(file, int64(len(data)))
+	} else {
+		// Plain .gltf: the whole file is JSON
+		rest, err := io.ReadAll(io.LimitReader(file, maxGLTFJSON))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read model: %w", err)
+		}
+		data = append(header[:n], rest...)
+	}
+
+	doc := gltfDocument{binOffset: binOffset}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid glTF document: %w", err)
+	}
+	return &doc, nil
+}
+
+// glbBINOffset returns the offset of the binary chunk data that follows a
+// JSON chunk of jsonLength bytes, or -1 if there is none
+func glbBINOffset(r io.Reader, jsonLength int64) int64 {
+	var chunk [8]byte
+	if _, err := io.ReadFull(r, chunk[:]); err != nil || binary.LittleEndian.Uint32(chunk[4:8]) != glbChunkBIN {
+		return -1
+	}
+	return 12 + 8 + jsonLength + 8
+}
+
+// readGLBJSON reads the JSON chunk that follows the GLB header
+func readGLBJSON(r io.Reader) ([]byte, error) {
+	var chunk [8]byte
+	if _, err := io.ReadFull(r, chunk[:]); err != nil {
+		return nil, fmt.Errorf("truncated GLB: %w", err)
+	}
+	length := binary.LittleEndian.Uint32(chunk[0:4])
+	if binary.LittleEndian.Uint32(chunk[4:8]) != glbChunkJSON {
+		return nil, errors.New("GLB does not start with a JSON chunk")
+	}
+	if length > maxGLTFJSON {
+		return nil, fmt.Errorf("GLB JSON chunk too large (%d bytes)", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("truncated GLB: %w", err)
+	}
+	return data, nil
+}
+
+// walkMeshes calls fn for every mesh instance of the default scene with its
+// world transform. Documents without scenes use every root node.
+func (d *gltfDocument) walkMeshes(fn func(mesh *gltfMesh, world mat4)) {
+	var roots []int
+	switch {
+	case d.Scene != nil && *d.Scene >= 0 && *d.Scene < len(d.Scenes):
+		roots = d.Scenes[*d.Scene].Nodes
+	case len(d.Scenes) > 0:
+		roots = d.Scenes[0].Nodes
+	default:
+		child := make(map[int]bool)
+		for _, node := range d.Nodes {
+			for _, c := range node.Children {
+				child[c] = true
+			}
+		}
+		for i := range d.Nodes {
+			if !child[i] {
+				roots = append(roots, i)
+			}
+		}
+	}
+
+	// Depth is bounded by the node count, so cyclic documents terminate
+	var visit func(index int, parent mat4, depth int)
+	visit = func(index int, parent mat4, depth int) {
+		if index < 0 || index >= len(d.Nodes) || depth > len(d.Nodes) {
+			return
+		}
+		node := &d.Nodes[index]
+		world := parent.mul(node.transform())
+		if node.Mesh != nil && *node.Mesh >= 0 && *node.Mesh < len(d.Meshes) {
+			fn(&d.Meshes[*node.Mesh], world)
+		}
+		for _, c := range node.Children {
+			visit(c, world, depth+1)
+		}
+	}
+	for _, root := range roots {
+		visit(root, identity4(), 0)
+	}
+}
+
+// mat4 is a column-major 4x4 matrix, as in glTF
+type mat4 [16]float64
+
+func identity4() mat4 {
+	return mat4{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
+}
+
+// mul returns m * o
+func (m mat4) mul(o mat4) mat4 {
+	var r mat4
+	for col := 0; col < 4; col++ {
+		for row := 0; row < 4; row++ {
+			var sum float64
+			for k := 0; k < 4; k++ {
+				sum += m[k*4+row] * o[col*4+k]
+			}
+			r[col*4+row] = sum
+		}
+	}
+	return r
+}
+
+// apply transforms a point
+func (m mat4) apply(x, y, z float64) (float64, float64, float64) {
+	return m[0]*x + m[4]*y + m[8]*z + m[12],
+		m[1]*x + m[5]*y + m[9]*z + m[13],
+		m[2]*x + m[6]*y + m[10]*z + m[14]
+}
+
+// transform returns the node's local transform: its matrix, or T * R * S
+func (n *gltfNode) transform() mat4 {
+	if len(n.Matrix) == 16 {
+		var m mat4
+		copy(m[:], n.Matrix)
+		return m
+	}
+
+	t := [3]float64{0, 0, 0}
+	q := [4]float64{0, 0, 0, 1}
+	s := [3]float64{1, 1, 1}
+	if len(n.Translation) == 3 {
+		copy(t[:], n.Translation)
+	}
+	if len(n.Rotation) == 4 {
+		copy(q[:], n.Rotation)
+	}
+	if len(n.Scale) == 3 {
+		copy(s[:], n.Scale)
+	}
+
+	x, y, z, w := q[0], q[1], q[2], q[3]
+	return mat4{
+		(1 - 2*(y*y+z*z)) * s[0], 2 * (x*y + z*w) * s[0], 2 * (x*z - y*w) * s[0], 0,
+		2 * (x*y - z*w) * s[1], (1 - 2*(x*x+z*z)) * s[1], 2 * (y*z + x*w) * s[1], 0,
+		2 * (x*z + y*w) * s[2], 2 * (y*z - x*w) * s[2], (1 - 2*(x*x+y*y)) * s[2], 0,
+		t[0], t[1], t[2], 1,
+	}
+}
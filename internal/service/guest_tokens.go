@@ -0,0 +1,237 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+var (
+	// ErrGuestTokenNotFound is returned for an unknown or revoked guest token
+	ErrGuestTokenNotFound = errors.New("guest token not found")
+	// ErrGuestTokenExpired is returned for a guest token past its expiry
+	ErrGuestTokenExpired = errors.New("guest token expired")
+)
+
+// guestTokenPrefix marks guest tokens, so they are told apart from API tokens
+const guestTokenPrefix = "gt_"
+
+// GuestToken grants read-only access to the images of one project or one
+// category, e.g. for a gallery embedded on another site. The token itself is
+// only returned when it is created; the store keeps a hash of it.
+type GuestToken struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Project   string     `json:"project,omitempty"`
+	Category  string     `json:"category,omitempty"` // a primary category covers its sub categories
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil never expires
+}
+
+// Normalize trims the name and lowercases the scope
+func (t *GuestToken) Normalize() {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Project = strings.ToLower(strings.TrimSpace(t.Project))
+	t.Category = strings.Trim(strings.ToLower(strings.TrimSpace(t.Category)), "/")
+}
+
+// Validate checks that the token has a name and exactly one scope
+func (t *GuestToken) Validate() error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	if (t.Project == "") == (t.Category == "") {
+		return errors.New("exactly one of project or category is required")
+	}
+	return nil
+}
+
+// Allows reports whether the token grants access to an image. Private images
+// are never shared with guests, in either scope: a project token covers the
+// project's public images only, even when the project defaults to private.
+func (t *GuestToken) Allows(img *ImageMetadata) bool {
+	if img.Deleted || img.Visibility == models.VisibilityPrivate {
+		return false
+	}
+	if t.Project != "" {
+		return img.Project == t.Project
+	}
+	return img.InCategory(t.Category)
+}
+
+// storedGuestToken is a guest token as persisted
+type storedGuestToken struct {
+	GuestToken
+	Hash string `json:"hash"` // SHA-256 of the token
+}
+
+// GuestTokenStore keeps the guest tokens, persisted as JSON next to the index
+type GuestTokenStore struct {
+	file   *sharedFile
+	tokens map[string]storedGuestToken // by ID
+	mutex  sync.RWMutex
+}
+
+func NewGuestTokenStore(path string) *GuestTokenStore {
+	return &GuestTokenStore{
+		file:   newSharedFile(path),
+		tokens: make(map[string]storedGuestToken),
+	}
+}
+
+// SetCoordinator shares the guest tokens with the other instances using c,
+// so a token created or revoked on one is honored by all
+func (s *GuestTokenStore) SetCoordinator(c *Coordinator) {
+	s.file.share(c)
+}
+
+// Load reads stored guest tokens from disk, if the file exists
+func (s *GuestTokenStore) Load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.load()
+}
+
+// load replaces the tokens with the file's. Caller must hold the lock.
+func (s *GuestTokenStore) load() error {
+	data, err := s.file.read()
+	if err != nil {
+		return fmt.Errorf("failed to read guest tokens: %w", err)
+	}
+	tokens := make(map[string]storedGuestToken)
+	if data != nil {
+		if err := json.Unmarshal(data, &tokens); err != nil {
+			return fmt.Errorf("failed to parse guest tokens: %w", err)
+		}
+	}
+	s.tokens = tokens
+	return nil
+}
+
+// refresh reloads the tokens if another instance changed them. If that
+// fails, the ones in memory are used.
+func (s *GuestTokenStore) refresh() {
+	if s.file.changed() {
+		s.mutex.Lock()
+		s.load()
+		s.mutex.Unlock()
+	}
+}
+
+// Create stores a new guest token and returns it with its secret, which is
+// not kept
+func (s *GuestTokenStore) Create(t GuestToken) (GuestToken, string, error) {
+	t.Normalize()
+	if err := t.Validate(); err != nil {
+		return t, "", err
+	}
+
+	// The ID is listed, so it shares no bytes with the secret
+	buf := make([]byte, 30)
+	if _, err := rand.Read(buf); err != nil {
+		return t, "", fmt.Errorf("failed to generate guest token: %w", err)
+	}
+	secret := guestTokenPrefix + hex.EncodeToString(buf[:24])
+	t.ID = hex.EncodeToString(buf[24:])
+	t.CreatedAt = time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	end, err := s.file.begin(s.load)
+	if err != nil {
+		return t, "", err
+	}
+	defer end()
+	s.tokens[t.ID] = storedGuestToken{GuestToken: t, Hash: hashGuestToken(secret)}
+	if err := s.save(); err != nil {
+		delete(s.tokens, t.ID)
+		return t, "", err
+	}
+	return t, secret, nil
+}
+
+// List returns all guest tokens, oldest first
+func (s *GuestTokenStore) List() []GuestToken {
+	s.refresh()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tokens := make([]GuestToken, 0, len(s.tokens))
+	for _, stored := range s.tokens {
+		tokens = append(tokens, stored.GuestToken)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens
+}
+
+// Revoke deletes a guest token; it stops working at once
+func (s *GuestTokenStore) Revoke(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	end, err := s.file.begin(s.load)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	previous, ok := s.tokens[id]
+	if !ok {
+		return ErrGuestTokenNotFound
+	}
+	delete(s.tokens, id)
+	if err := s.save(); err != nil {
+		s.tokens[id] = previous
+		return err
+	}
+	return nil
+}
+
+// Resolve returns the guest token for a secret. A nil store resolves none.
+func (s *GuestTokenStore) Resolve(secret string, now time.Time) (GuestToken, error) {
+	if s == nil || !strings.HasPrefix(secret, guestTokenPrefix) {
+		return GuestToken{}, ErrGuestTokenNotFound
+	}
+	hash := hashGuestToken(secret)
+
+	s.refresh()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, stored := range s.tokens {
+		if stored.Hash != hash {
+			continue
+		}
+		if stored.ExpiresAt != nil && now.After(*stored.ExpiresAt) {
+			return GuestToken{}, ErrGuestTokenExpired
+		}
+		return stored.GuestToken, nil
+	}
+	return GuestToken{}, ErrGuestTokenNotFound
+}
+
+// save writes all guest tokens to disk atomically. Caller must hold the lock.
+func (s *GuestTokenStore) save() error {
+	data, err := json.MarshalIndent(s.tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode guest tokens: %w", err)
+	}
+
+	if err := s.file.write(data, 0600); err != nil {
+		return fmt.Errorf("failed to write guest tokens: %w", err)
+	}
+	return nil
+}
+
+func hashGuestToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestGuestTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guest_tokens.json")
+	store := NewGuestTokenStore(path)
+
+	if _, _, err := store.Create(GuestToken{Name: "Both", Project: "acme", Category: "animals"}); err == nil {
+		t.Error("expected a token with two scopes to be rejected")
+	}
+	created, secret, err := store.Create(GuestToken{Name: "Cats on acme.com", Category: " Animals/"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.Category != "animals" || created.ID == "" || secret == "" {
+		t.Fatalf("unexpected token %+v", created)
+	}
+
+	// Tokens survive a restart; only their hash is stored
+	reloaded := NewGuestTokenStore(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	guest, err := reloaded.Resolve(secret, time.Now())
+	if err != nil || guest.ID != created.ID {
+		t.Fatalf("Resolve = %+v, %v", guest, err)
+	}
+	if _, err := reloaded.Resolve(secret+"0", time.Now()); !errors.Is(err, ErrGuestTokenNotFound) {
+		t.Errorf("expected an unknown token to be rejected, got %v", err)
+	}
+
+	for img, want := range map[*ImageMetadata]bool{
+		{ID: "a", Category: "animals/cats"}:                                  true,
+		{ID: "b", Category: "landscape"}:                                     false,
+		{ID: "c", Category: "animals", Deleted: true}:                        false,
+		{ID: "d", Category: "animals", Visibility: models.VisibilityPrivate}: false,
+	} {
+		if guest.Allows(img) != want {
+			t.Errorf("Allows(%s) = %v", img.ID, !want)
+		}
+	}
+
+	expires := time.Now().Add(time.Hour)
+	_, expiring, _ := reloaded.Create(GuestToken{Name: "Campaign", Project: "acme", ExpiresAt: &expires})
+	if _, err := reloaded.Resolve(expiring, expires.Add(time.Second)); !errors.Is(err, ErrGuestTokenExpired) {
+		t.Errorf("expected an expired token to be rejected, got %v", err)
+	}
+
+	// Project tokens don't share private images either
+	project, err := reloaded.Resolve(expiring, time.Now())
+	if err != nil {
+		t.Fatalf("Resolve = %+v, %v", project, err)
+	}
+	if !project.Allows(&ImageMetadata{ID: "e", Project: "acme"}) || project.Allows(&ImageMetadata{ID: "f", Project: "acme", Visibility: models.VisibilityPrivate}) {
+		t.Error("expected a project token to allow the project's public images only")
+	}
+
+	if err := reloaded.Revoke(created.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, err := reloaded.Resolve(secret, time.Now()); !errors.Is(err, ErrGuestTokenNotFound) {
+		t.Errorf("expected a revoked token to be rejected, got %v", err)
+	}
+	if tokens := reloaded.List(); len(tokens) != 1 || tokens[0].Name != "Campaign" {
+		t.Errorf("unexpected tokens %+v", tokens)
+	}
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// Image event types
+const (
+	EventUploaded    = "uploaded"
+	EventAnalyzed    = "analyzed"    // by the AI, with the provider and model
+	EventCategorized = "categorized" // filed under a category by the analysis
+	EventEdited      = "edited"      // metadata changed, with the changed fields
+	EventDeleted     = "deleted"
+)
+
+// FieldChange is one field changed by an edit, with its values before and
+// after formatted as text
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// ImageEvent records one thing that happened to an image
+type ImageEvent struct {
+	Time    time.Time     `json:"time"`
+	ImageID string        `json:"image_id"`
+	Type    string        `json:"type"`
+	Actor   string        `json:"actor,omitempty"` // empty for the warehouse's own processing
+	Detail  string        `json:"detail,omitempty"`
+	Changes []FieldChange `json:"changes,omitempty"` // of an edit
+}
+
+// ImageHistory is an append-only log of image events, one JSON line each,
+// giving curators the provenance of every image. Entries are never changed;
+// only an erasure removes those of the erased images.
+type ImageHistory struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewImageHistory(path string) *ImageHistory {
+	return &ImageHistory{path: path}
+}
+
+// Record appends events. A zero Time is set to now. A nil history records
+// nothing.
+func (h *ImageHistory) Record(events ...ImageEvent) error {
+	if h == nil || len(events) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, event := range events {
+		if event.Time.IsZero() {
+			event.Time = time.Now()
+		}
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode image event: %w", err)
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open image history: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write image history: %w", err)
+	}
+	return file.Sync()
+}
+
+// Events returns the events of an image, oldest first
+func (h *ImageHistory) Events(imageID string) ([]ImageEvent, error) {
+	events := []ImageEvent{}
+	err := h.scan(func(event ImageEvent, _ []byte) {
+		if event.ImageID == imageID {
+			events = append(events, event)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events, nil
+}
+
+// Forget removes the events of the given images, returning how many were
+// removed. It is only meant for erasures.
+func (h *ImageHistory) Forget(imageIDs map[string]bool) (int, error) {
+	if h == nil || len(imageIDs) == 0 {
+		return 0, nil
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var kept bytes.Buffer
+	removed := 0
+	err := h.read(func(event ImageEvent, line []byte) {
+		if imageIDs[event.ImageID] {
+			removed++
+			return
+		}
+		kept.Write(append(line, '\n'))
+	})
+	if err != nil || removed == 0 {
+		return 0, err
+	}
+
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, kept.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write image history: %w", err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return 0, fmt.Errorf("failed to replace image history: %w", err)
+	}
+	return removed, nil
+}
+
+// scan calls fn with every event of the log and its line, oldest first
+func (h *ImageHistory) scan(fn func(ImageEvent, []byte)) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.read(fn)
+}
+
+// read is scan for callers holding the mutex
+func (h *ImageHistory) read(fn func(ImageEvent, []byte)) error {
+	file, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open image history: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event ImageEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue // torn line from a crash
+		}
+		fn(event, scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read image history: %w", err)
+	}
+	return nil
+}
+
+// historyFields are the fields an edit is compared on, with how each is
+// formatted
+var historyFields = []struct {
+	name   string
+	format func(*ImageMetadata) string
+}{
+	{"title", func(m *ImageMetadata) string { return m.Title }},
+	{"artist", func(m *ImageMetadata) string { return m.Artist }},
+	{"description", func(m *ImageMetadata) string { return m.Description }},
+	{"tags", func(m *ImageMetadata) string { return strings.Join(m.Tags, ", ") }},
+	{"visibility", func(m *ImageMetadata) string { return m.Visibility }},
+	{"project", func(m *ImageMetadata) string { return m.Project }},
+	{"workflow", func(m *ImageMetadata) string { return m.Workflow }},
+	{"legal_hold", func(m *ImageMetadata) string { return strconv.FormatBool(m.LegalHold) }},
+	{"license", func(m *ImageMetadata) string { return historyJSON(m.License) }},
+	{"attributes", func(m *ImageMetadata) string { return historyJSON(m.Attributes) }},
+	{"focal_point", func(m *ImageMetadata) string { return historyJSON(m.FocalPoint) }},
+	{"bounds", func(m *ImageMetadata) string { return historyJSON(m.Bounds) }},
+	{"triangles", func(m *ImageMetadata) string { return strconv.Itoa(m.Triangles) }},
+	{"lod_tags", func(m *ImageMetadata) string { return strings.Join(m.LODTags, ", ") }},
+	{"poster_view", func(m *ImageMetadata) string { return m.PosterView }},
+	{"upscales", func(m *ImageMetadata) string { return historyJSON(m.Upscales) }},
+}
+
+// historyJSON formats a structured field for a change, empty for nil
+func historyJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" || string(data) == "{}" {
+		return ""
+	}
+	return string(data)
+}
+
+// diffImages returns the fields that differ between two versions of an image
+func diffImages(before, after *ImageMetadata) []FieldChange {
+	var changes []FieldChange
+	for _, field := range historyFields {
+		from, to := field.format(before), field.format(after)
+		if from != to {
+			changes = append(changes, FieldChange{Field: field.name, From: from, To: to})
+		}
+	}
+	return changes
+}
+
+// changedFields returns the names of the changed fields
+func changedFields(changes []FieldChange) string {
+	names := make([]string, len(changes))
+	for i, c := range changes {
+		names[i] = c.Field
+	}
+	return strings.Join(names, ", ")
+}
+
+// SetHistory records the events of every image from now on in history
+func (s *ImageService) SetHistory(history *ImageHistory) {
+	s.history = history
+}
+
+// History returns the recorded events of an image, oldest first; empty
+// without a history
+func (s *ImageService) History(imageID string) ([]ImageEvent, error) {
+	if s.history == nil {
+		return []ImageEvent{}, nil
+	}
+	return s.history.Events(imageID)
+}
+
+// ForgetHistory drops the events of erased images
+func (s *ImageService) ForgetHistory(imageIDs map[string]bool) (int, error) {
+	return s.history.Forget(imageIDs)
+}
+
+// recordIndexed records the upload, analysis and category of a newly
+// indexed image
+func (s *ImageService) recordIndexed(img *models.Image) {
+	if s.history == nil {
+		return
+	}
+	uploaded := ImageEvent{Time: img.UploadedAt, ImageID: img.ID, Type: EventUploaded, Detail: img.OriginalFilename}
+	if img.Type == models.ImageType3D {
+		uploaded.Detail = img.ModelFilename
+	}
+	processed := time.Now()
+	if img.ProcessedAt != nil {
+		processed = *img.ProcessedAt
+	}
+	events := []ImageEvent{uploaded}
+	if ai := img.AIAnalysis; ai != nil {
+		detail := strings.TrimSpace(ai.Provider + " " + ai.Model)
+		if ai.PromptVersion != "" {
+			detail += " (prompt " + ai.PromptVersion + ")"
+		}
+		events = append(events, ImageEvent{Time: processed, ImageID: img.ID, Type: EventAnalyzed, Detail: detail})
+	}
+	if img.Category != "" {
+		events = append(events, ImageEvent{Time: processed, ImageID: img.ID, Type: EventCategorized, Detail: img.Category})
+	}
+	s.recordEvent(events...)
+}
+
+// recordEdited records the fields an update changed; before is the image
+// as it was, nil if unknown
+func (s *ImageService) recordEdited(before, after *ImageMetadata, actor string) {
+	if s.history == nil {
+		return
+	}
+	event := ImageEvent{ImageID: after.ID, Type: EventEdited, Actor: actor}
+	if before != nil && before.Revision+1 == after.Revision {
+		event.Changes = diffImages(before, after)
+		if len(event.Changes) == 0 {
+			return
+		}
+		event.Detail = changedFields(event.Changes)
+	}
+	s.recordEvent(event)
+}
+
+func (s *ImageService) recordEvent(events ...ImageEvent) {
+	if err := s.history.Record(events...); err != nil {
+		s.logger.Warnf("Failed to record image history: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestImageHistory_RecordsLifecycle(t *testing.T) {
+	dataDir := t.TempDir()
+	indexService := NewIndexService(dataDir)
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	imageService := NewImageService(NewStorageService(dataDir), nil, indexService, nil, logrus.New())
+	history := NewImageHistory(filepath.Join(dataDir, "history.jsonl"))
+	imageService.SetHistory(history)
+
+	img := &models.Image{
+		ID: "a", Title: "Harbor", Category: "nature", Type: models.ImageType2D,
+		UploadedAt: time.Now().Add(-time.Minute), OriginalFilename: "harbor.jpg",
+		AIAnalysis: &models.AIAnalysis{Provider: "gemini", Model: "gemini-2.5-flash", PromptVersion: "abc"},
+	}
+	if err := indexService.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	imageService.notifyIndexed(img)
+
+	tags := []string{"harbor", "boats"}
+	if _, err := imageService.UpdateImage("a", 0, ImageUpdate{Tags: &tags, Actor: "alice"}); err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	// Nothing changed, nothing recorded
+	if _, err := imageService.UpdateImage("a", 0, ImageUpdate{Tags: &tags, Actor: "alice"}); err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if err := imageService.DeleteImage("a", "bob"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+
+	events, err := imageService.History("a")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []string{EventUploaded, EventAnalyzed, EventCategorized, EventEdited, EventDeleted}
+	if len(types) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, types)
+		}
+	}
+	if events[0].Detail != "harbor.jpg" || events[1].Detail != "gemini gemini-2.5-flash (prompt abc)" || events[2].Detail != "nature" {
+		t.Errorf("unexpected ingest events %+v", events[:3])
+	}
+	edit := events[3]
+	if edit.Actor != "alice" || len(edit.Changes) != 1 || edit.Changes[0] != (FieldChange{Field: "tags", To: "harbor, boats"}) {
+		t.Errorf("unexpected edit event %+v", edit)
+	}
+	if events[4].Actor != "bob" {
+		t.Errorf("expected the deletion by bob, got %+v", events[4])
+	}
+
+	if removed, err := history.Forget(map[string]bool{"a": true}); err != nil || removed != 5 {
+		t.Fatalf("expected 5 events forgotten, got %d (%v)", removed, err)
+	}
+	if events, _ := imageService.History("a"); len(events) != 0 {
+		t.Errorf("expected no events after Forget, got %+v", events)
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// IdempotencyStore remembers which image ID an Idempotency-Key produced so
+// that retried uploads return the original image instead of creating a new one.
+// Keys expire after the configured TTL. They are kept in memory: after a
+// restart, a retry of an earlier request is treated as a new upload.
+type IdempotencyStore struct {
+	keys  map[IdempotencyKey]idempotencyEntry
+	mutex sync.Mutex
+	ttl   time.Duration
+}
+
+// IdempotencyKey is a client's Idempotency-Key scoped to the caller (the API
+// token's name) and the route, so two callers or two endpoints sending the
+// same key never get each other's images
+type IdempotencyKey struct {
+	Caller string
+	Route  string
+	Key    string
+}
+
+type idempotencyEntry struct {
+	imageID   string
+	createdAt time.Time
+}
+
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		keys: make(map[IdempotencyKey]idempotencyEntry),
+		ttl:  ttl,
+	}
+}
+
+// Lookup returns the image ID previously recorded for key
+func (s *IdempotencyStore) Lookup(key IdempotencyKey) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.keys[key]
+	if !ok || s.expired(entry) {
+		return "", false
+	}
+	return entry.imageID, true
+}
+
+// Remember records imageID for key unless another request already claimed the
+// key, in which case the original image ID is returned with false
+func (s *IdempotencyStore) Remember(key IdempotencyKey, imageID string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if entry, ok := s.keys[key]; ok && !s.expired(entry) {
+		return entry.imageID, false
+	}
+	s.keys[key] = idempotencyEntry{imageID: imageID, createdAt: time.Now()}
+	return imageID, true
+}
+
+// Forget drops a key, e.g. when the upload it guarded failed to queue
+func (s *IdempotencyStore) Forget(key IdempotencyKey) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.keys, key)
+}
+
+// EvictExpired removes expired keys and returns how many were removed
+func (s *IdempotencyStore) EvictExpired() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	evicted := 0
+	for key, entry := range s.keys {
+		if s.expired(entry) {
+			delete(s.keys, key)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Run evicts expired keys every interval until ctx is done
+func (s *IdempotencyStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.EvictExpired()
+		}
+	}
+}
+
+// expired reports whether an entry is past the TTL. Caller must hold the lock.
+func (s *IdempotencyStore) expired(entry idempotencyEntry) bool {
+	return s.ttl > 0 && time.Since(entry.createdAt) > s.ttl
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+var key1 = IdempotencyKey{Caller: "ci", Route: "/api/v1/images/upload", Key: "key-1"}
+
+func TestIdempotencyStore_RememberAndLookup(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+
+	if _, ok := store.Lookup(key1); ok {
+		t.Fatal("unknown key should not be found")
+	}
+
+	if id, ok := store.Remember(key1, "img-001"); !ok || id != "img-001" {
+		t.Fatalf("expected first Remember to succeed, got %s %v", id, ok)
+	}
+
+	// A retry with the same key gets the original image
+	if id, ok := store.Remember(key1, "img-002"); ok || id != "img-001" {
+		t.Errorf("expected original image img-001, got %s %v", id, ok)
+	}
+	if id, ok := store.Lookup(key1); !ok || id != "img-001" {
+		t.Errorf("expected lookup to return img-001, got %s %v", id, ok)
+	}
+
+	// The same key from another caller or on another route is another request
+	for _, other := range []IdempotencyKey{
+		{Caller: "other", Route: key1.Route, Key: key1.Key},
+		{Caller: key1.Caller, Route: "/api/v1/images/upload-3d", Key: key1.Key},
+	} {
+		if _, ok := store.Lookup(other); ok {
+			t.Errorf("expected %+v not to share the key", other)
+		}
+	}
+}
+
+func TestIdempotencyStore_Expiry(t *testing.T) {
+	store := NewIdempotencyStore(time.Minute)
+	store.Remember(key1, "img-001")
+	store.keys[key1] = idempotencyEntry{imageID: "img-001", createdAt: time.Now().Add(-time.Hour)}
+
+	if _, ok := store.Lookup(key1); ok {
+		t.Error("expired key should not be found")
+	}
+	if evicted := store.EvictExpired(); evicted != 1 {
+		t.Errorf("expected 1 eviction, got %d", evicted)
+	}
+	if id, ok := store.Remember(key1, "img-002"); !ok || id != "img-002" {
+		t.Errorf("expired key should be reusable, got %s %v", id, ok)
+	}
+}
+
+func TestIdempotencyStore_Forget(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour)
+	store.Remember(key1, "img-001")
+	store.Forget(key1)
+
+	if _, ok := store.Lookup(key1); ok {
+		t.Error("forgotten key should not be found")
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrURLNotAllowed is returned for URLs the downloader refuses to fetch:
+// other schemes than http(s), or hosts on loopback, private or link-local
+// networks
+var ErrURLNotAllowed = errors.New("URL not allowed")
+
+// ErrDownloadTooLarge is returned when a download exceeds the upload size limit
+var ErrDownloadTooLarge = errors.New("download exceeds the upload size limit")
+
+// ErrDownloadNotImage is returned when the URL serves a web page or other text
+var ErrDownloadNotImage = errors.New("URL does not serve an image")
+
+// imageExtensions are the file extensions used for downloads named without one
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg", "image/png": ".png", "image/gif": ".gif", "image/webp": ".webp",
+	"image/bmp": ".bmp", "image/tiff": ".tif", "image/heic": ".heic", "image/heif": ".heif",
+}
+
+// ImageDownloader fetches images by URL for JSON ingest. Unless private
+// addresses are allowed, it refuses to connect to loopback, private and
+// link-local addresses (checked on every connection, redirects included), so
+// callers can't use the server to reach internal services.
+type ImageDownloader struct {
+	httpClient *http.Client
+	maxSize    int64
+}
+
+// NewImageDownloader creates a downloader with a timeout per download and
+// the largest download accepted
+func NewImageDownloader(timeout time.Duration, maxSize int64, allowPrivate bool) *ImageDownloader {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = refusePrivateAddress
+	}
+	return &ImageDownloader{
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+		},
+		maxSize: maxSize,
+	}
+}
+
+// Download is an image being downloaded
+type Download struct {
+	// Body fails with ErrDownloadTooLarge past the size limit; the caller
+	// closes it
+	Body        io.ReadCloser
+	Filename    string
+	ContentType string
+}
+
+// Download starts fetching rawURL. The file name comes from the
+// Content-Disposition header or the URL path, with an extension matching the
+// content type if it has none.
+func (d *ImageDownloader) Download(ctx context.Context, rawURL string) (*Download, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: use an http or https URL", ErrURLNotAllowed)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrURLNotAllowed) {
+			return nil, fmt.Errorf("%w: %s resolves to a private address", ErrURLNotAllowed, u.Hostname())
+		}
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download image: %s returned %s", u.Host, resp.Status)
+	}
+	if resp.ContentLength > d.maxSize {
+		resp.Body.Close()
+		return nil, ErrDownloadTooLarge
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if strings.HasPrefix(contentType, "text/") || contentType == "application/json" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w (got %s)", ErrDownloadNotImage, contentType)
+	}
+
+	return &Download{
+		Body:        &limitedBody{body: resp.Body, remaining: d.maxSize},
+		Filename:    downloadFilename(resp, contentType),
+		ContentType: contentType,
+	}, nil
+}
+
+// downloadFilename names a download after its Content-Disposition or the
+// path of the URL it was finally served from
+func downloadFilename(resp *http.Response, contentType string) string {
+	var name string
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		name = CleanFilename(params["filename"])
+	}
+	if name == "" {
+		name = CleanFilename(path.Base(resp.Request.URL.Path))
+	}
+	if name == "" {
+		name = "image"
+	}
+	return WithImageExtension(name, contentType)
+}
+
+// WithImageExtension adds the extension of an image content type to a file
+// name without one
+func WithImageExtension(name, contentType string) string {
+	if path.Ext(name) != "" {
+		return name
+	}
+	if ext, ok := imageExtensions[contentType]; ok {
+		return name + ext
+	}
+	return name
+}
+
+// limitedBody reads up to remaining bytes and fails after that, so an
+// oversized download is rejected instead of silently truncated
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrDownloadTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return 0, ErrDownloadTooLarge
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
+
+// refusePrivateAddress is a dialer hook rejecting connections to addresses
+// that aren't public
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ErrURLNotAllowed
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return ErrURLNotAllowed
+	}
+	return nil
+}
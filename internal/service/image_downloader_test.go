@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImageDownloader_Download(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/photos/cat.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			io.WriteString(w, "jpeg-bytes")
+		case "/files/abc123":
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, "png-bytes")
+		case "/attachment":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", `attachment; filename="sketch.webp"`)
+			io.WriteString(w, "webp-bytes")
+		case "/redirect":
+			http.Redirect(w, r, "/photos/cat.jpg", http.StatusFound)
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, "<html></html>")
+		case "/big":
+			w.Header().Set("Content-Type", "image/png")
+			w.(http.Flusher).Flush() // no Content-Length
+			io.WriteString(w, strings.Repeat("x", 100))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	downloader := NewImageDownloader(time.Minute, 64, true)
+
+	tests := []struct {
+		path     string
+		filename string
+		content  string
+	}{
+		{"/photos/cat.jpg", "cat.jpg", "jpeg-bytes"},
+		{"/files/abc123", "abc123.png", "png-bytes"},
+		{"/attachment", "sketch.webp", "webp-bytes"},
+		{"/redirect", "cat.jpg", "jpeg-bytes"},
+	}
+	for _, tt := range tests {
+		download, err := downloader.Download(context.Background(), server.URL+tt.path)
+		if err != nil {
+			t.Fatalf("%s: Download failed: %v", tt.path, err)
+		}
+		content, err := io.ReadAll(download.Body)
+		download.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: read failed: %v", tt.path, err)
+		}
+		if download.Filename != tt.filename || string(content) != tt.content {
+			t.Errorf("%s: expected %s with %q, got %s with %q", tt.path, tt.filename, tt.content, download.Filename, content)
+		}
+	}
+
+	if _, err := downloader.Download(context.Background(), server.URL+"/page"); !errors.Is(err, ErrDownloadNotImage) {
+		t.Errorf("expected ErrDownloadNotImage for a web page, got %v", err)
+	}
+	if _, err := downloader.Download(context.Background(), server.URL+"/missing"); err == nil {
+		t.Error("expected an error for a 404")
+	}
+
+	// Oversized bodies fail while reading instead of being truncated
+	download, err := downloader.Download(context.Background(), server.URL+"/big")
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	defer download.Body.Close()
+	if _, err := io.ReadAll(download.Body); !errors.Is(err, ErrDownloadTooLarge) {
+		t.Errorf("expected ErrDownloadTooLarge, got %v", err)
+	}
+}
+
+func TestImageDownloader_RefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, "png-bytes")
+	}))
+	defer server.Close()
+
+	downloader := NewImageDownloader(time.Minute, 1024, false)
+	for _, rawURL := range []string{
+		server.URL + "/image.png", // loopback
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/image.png",
+		"file:///etc/passwd",
+		"ftp://example.com/image.png",
+		"not a url",
+	} {
+		if _, err := downloader.Download(context.Background(), rawURL); !errors.Is(err, ErrURLNotAllowed) {
+			t.Errorf("%s: expected ErrURLNotAllowed, got %v", rawURL, err)
+		}
+	}
+}
+
+func TestSaveImageToTemp_RemovesPartialFile(t *testing.T) {
+	dataDir := t.TempDir()
+	storage := NewStorageService(dataDir)
+	if err := storage.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	body := &limitedBody{body: io.NopCloser(strings.NewReader(strings.Repeat("x", 100))), remaining: 10}
+	if _, _, err := storage.SaveImageToTemp(body, "big.png"); !errors.Is(err, ErrDownloadTooLarge) {
+		t.Fatalf("expected ErrDownloadTooLarge, got %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dataDir, "temp"))
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected the partial file to be removed, found %d temp files", len(entries))
+	}
+}
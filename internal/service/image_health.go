@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// Health checks an image's original, derivatives and index entry: that the
+// original decodes, thumbnails and views exist, and indexed fields agree with
+// the files. Missing derivatives name the rendition that would fix them.
+func (s *RenditionService) Health(img *ImageMetadata) *models.ImageHealth {
+	var checks []models.HealthCheck
+	checks = append(checks, s.checkIndex(img))
+	if img.Type == string(models.ImageType3D) {
+		checks = append(checks, s.checkModel(img), s.checkViews(img))
+		checks = append(checks, s.checkViewThumbnails(img)...)
+		if img.TurntablePath != "" {
+			checks = append(checks, s.checkRendition("turntable", img.TurntablePath, models.RenditionTurntable))
+		}
+	} else {
+		checks = append(checks, s.checkOriginal(img))
+		checks = append(checks, s.checkRendition("thumbnail", img.ThumbnailPath, models.RenditionThumbnail))
+		if img.SquareThumbnail != "" {
+			checks = append(checks, s.checkRendition("square-thumbnail", img.SquareThumbnail, models.RenditionSquareThumbnail))
+		}
+	}
+
+	health := &models.ImageHealth{ImageID: img.ID, Healthy: true, Checks: checks, CheckedAt: time.Now()}
+	for _, check := range checks {
+		if check.Status == models.HealthError {
+			health.Healthy = false
+		}
+	}
+	return health
+}
+
+// Heal regenerates the derivatives the health check found broken, then
+// checks the image again. Checks that were repaired are marked fixed; the
+// others keep their status, with the reason a fix failed.
+func (s *RenditionService) Heal(ctx context.Context, img *ImageMetadata) *models.ImageHealth {
+	fixed := make(map[string]bool)
+	failed := make(map[string]string)
+	for _, check := range s.Health(img).Checks {
+		if check.Status != models.HealthError || check.Fix == "" {
+			continue
+		}
+		if _, err := s.Regenerate(ctx, img, check.Fix); err != nil {
+			s.logger.Warnf("Failed to fix %s of %s: %v", check.Name, img.ID, err)
+			failed[check.Name] = err.Error()
+			continue
+		}
+		fixed[check.Name] = true
+	}
+
+	health := s.Health(img)
+	for i := range health.Checks {
+		check := &health.Checks[i]
+		if fixed[check.Name] && check.Status == models.HealthOK {
+			check.Fixed = true
+		}
+		if reason, ok := failed[check.Name]; ok {
+			check.Detail += "; fix failed: " + reason
+		}
+	}
+	return health
+}
+
+// checkIndex checks that the entry's fields are present and agree with each
+// other
+func (s *RenditionService) checkIndex(img *ImageMetadata) models.HealthCheck {
+	var problems, warnings []string
+	if img.Title == "" {
+		problems = append(problems, "no title")
+	}
+	if img.Category == "" {
+		problems = append(problems, "no category")
+	}
+	if _, err := img.UploadedTime(); err != nil {
+		problems = append(problems, "invalid upload time")
+	}
+
+	stored := img.FilePath
+	if img.Type == string(models.ImageType3D) {
+		stored = img.FolderPath
+		if img.FolderPath == "" || img.ModelFilePath == "" {
+			problems = append(problems, "no folder or model path")
+		} else if !strings.HasPrefix(img.ModelFilePath, img.FolderPath+"/") {
+			problems = append(problems, "model file outside the object folder")
+		}
+		for _, view := range sortedViews(img.Views) {
+			if img.FolderPath != "" && !strings.HasPrefix(img.Views[view], img.FolderPath+"/") {
+				problems = append(problems, fmt.Sprintf("view %s outside the object folder", view))
+			}
+		}
+	} else {
+		if img.FilePath == "" {
+			problems = append(problems, "no file path")
+		}
+		if img.ThumbnailPath == "" {
+			problems = append(problems, "no thumbnail path")
+		} else if path.Dir(img.ThumbnailPath) != path.Dir(img.FilePath) {
+			problems = append(problems, "thumbnail outside the image's folder")
+		}
+	}
+
+	// The category layout files images under their category
+	if (img.StorageLayout == "" || img.StorageLayout == LayoutCategory) && img.Category != "" && stored != "" &&
+		!strings.HasPrefix(stored, "categories/"+img.Category+"/") {
+		warnings = append(warnings, "stored outside the folder of category "+img.Category)
+	}
+
+	check := models.HealthCheck{Name: "index", Status: models.HealthOK}
+	switch {
+	case len(problems) > 0:
+		check.Status, check.Detail = models.HealthError, strings.Join(append(problems, warnings...), "; ")
+	case len(warnings) > 0:
+		check.Status, check.Detail = models.HealthWarning, strings.Join(warnings, "; ")
+	}
+	return check
+}
+
+// checkOriginal checks that a 2D original decodes and matches its indexed
+// dimensions
+func (s *RenditionService) checkOriginal(img *ImageMetadata) models.HealthCheck {
+	check := models.HealthCheck{Name: "original", Status: models.HealthOK}
+	fullPath, err := s.storageService.StoredPath(img.FilePath)
+	if err != nil {
+		check.Status, check.Detail = models.HealthError, err.Error()
+		return check
+	}
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		check.Status, check.Detail = models.HealthError, "missing: "+img.FilePath
+		return check
+	}
+	defer file.Close()
+
+	decoded, _, err := image.Decode(file)
+	switch {
+	case errors.Is(err, image.ErrFormat):
+		check.Status, check.Detail = models.HealthWarning, "format can't be decoded by the server"
+	case err != nil:
+		check.Status, check.Detail = models.HealthError, "does not decode: "+err.Error()
+	case img.Width > 0 && (decoded.Bounds().Dx() != img.Width || decoded.Bounds().Dy() != img.Height):
+		check.Status = models.HealthWarning
+		check.Detail = fmt.Sprintf("indexed as %dx%d but is %dx%d", img.Width, img.Height, decoded.Bounds().Dx(), decoded.Bounds().Dy())
+	}
+	return check
+}
+
+// checkModel checks that a 3D object's model file exists
+func (s *RenditionService) checkModel(img *ImageMetadata) models.HealthCheck {
+	check := models.HealthCheck{Name: "model", Status: models.HealthOK}
+	if err := s.checkFile(img.ModelFilePath, false); err != nil {
+		check.Status, check.Detail = models.HealthError, err.Error()
+	}
+	return check
+}
+
+// checkViews checks that a 3D object has the views of its mode and that they
+// decode. Objects sampled from a clip only need a front view.
+func (s *RenditionService) checkViews(img *ImageMetadata) models.HealthCheck {
+	check := models.HealthCheck{Name: "views", Status: models.HealthOK}
+
+	required := Views4
+	if _, ok := img.Views["top"]; ok {
+		required = Views6
+	} else if _, ok := img.Views["bottom"]; ok {
+		required = Views6
+	}
+	if img.ClipPath != "" {
+		required = []string{"front"}
+	}
+
+	var problems []string
+	for _, view := range required {
+		if _, ok := img.Views[view]; !ok {
+			problems = append(problems, "missing view "+view)
+		}
+	}
+	for _, view := range sortedViews(img.Views) {
+		if err := s.checkFile(img.Views[view], true); err != nil {
+			problems = append(problems, fmt.Sprintf("view %s: %v", view, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		check.Status, check.Detail = models.HealthError, strings.Join(problems, "; ")
+	}
+	return check
+}
+
+// checkViewThumbnails checks the thumbnail of every view of a 3D object
+func (s *RenditionService) checkViewThumbnails(img *ImageMetadata) []models.HealthCheck {
+	candidates := s.candidates(img)
+	var checks []models.HealthCheck
+	for _, view := range sortedViews(img.Views) {
+		kind := models.ViewThumbnailKind(view)
+		checks = append(checks, s.checkRendition(kind, candidates[kind], kind))
+	}
+	return checks
+}
+
+// checkRendition checks that a derivative exists and decodes; the fix
+// regenerates it
+func (s *RenditionService) checkRendition(name, relPath, kind string) models.HealthCheck {
+	check := models.HealthCheck{Name: name, Status: models.HealthOK}
+	if relPath == "" {
+		check.Status, check.Detail = models.HealthError, "not indexed"
+		return check
+	}
+	if err := s.checkFile(relPath, true); err != nil {
+		check.Status, check.Detail, check.Fix = models.HealthError, err.Error(), kind
+	}
+	return check
+}
+
+// checkFile checks that a stored file exists and, for images, that its
+// header decodes
+func (s *RenditionService) checkFile(relPath string, isImage bool) error {
+	fullPath, err := s.storageService.StoredPath(relPath)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return fmt.Errorf("missing: %s", relPath)
+	}
+	defer file.Close()
+
+	if isImage {
+		if _, _, err := image.DecodeConfig(file); err != nil && !errors.Is(err, image.ErrFormat) {
+			return fmt.Errorf("does not decode: %s", relPath)
+		}
+	}
+	return nil
+}
+
+// sortedViews returns the names of an object's views in order
+func sortedViews(views map[string]string) []string {
+	names := make([]string, 0, len(views))
+	for view := range views {
+		names = append(names, view)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func healthCheck(health *models.ImageHealth, name string) models.HealthCheck {
+	for _, check := range health.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	return models.HealthCheck{}
+}
+
+func TestImageHealth_2D(t *testing.T) {
+	dataDir := t.TempDir()
+	logger := logrus.New()
+	index := NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	storage := NewStorageService(dataDir)
+
+	fileRel := "categories/products/p1.png"
+	filePath := filepath.Join(dataDir, filepath.FromSlash(fileRel))
+	os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err := imaging.Save(productShot(), filePath); err != nil {
+		t.Fatal(err)
+	}
+	thumbPath, err := storage.GenerateThumbnail(filePath)
+	if err != nil {
+		t.Fatalf("GenerateThumbnail failed: %v", err)
+	}
+	img := &models.Image{
+		ID: "p1", Title: "Box", Artist: "A", Category: "products", Type: models.ImageType2D, UploadedAt: time.Now(),
+		FilePath: fileRel, ThumbnailPath: "categories/products/p1_thumb.jpg",
+	}
+	img.SetDimensions(60, 40)
+	if err := index.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	svc := NewRenditionService(storage, NewImageService(storage, nil, index, nil, logger), nil, nil, logger)
+
+	metadata, _ := index.GetImageByID("p1")
+	health := svc.Health(metadata)
+	if !health.Healthy {
+		t.Fatalf("expected a healthy image, got %+v", health.Checks)
+	}
+
+	// A lost thumbnail is reported with its fix and regenerated by Heal
+	os.Remove(thumbPath)
+	health = svc.Health(metadata)
+	thumb := healthCheck(health, "thumbnail")
+	if health.Healthy || thumb.Status != models.HealthError || thumb.Fix != models.RenditionThumbnail {
+		t.Fatalf("expected a fixable thumbnail error, got %+v", health.Checks)
+	}
+	health = svc.Heal(context.Background(), metadata)
+	if thumb := healthCheck(health, "thumbnail"); !health.Healthy || !thumb.Fixed {
+		t.Errorf("expected the thumbnail to be fixed, got %+v", health.Checks)
+	}
+
+	// A truncated original can't be fixed
+	os.WriteFile(filePath, []byte("\x89PNG\r\n\x1a\n"), 0644)
+	health = svc.Heal(context.Background(), metadata)
+	if original := healthCheck(health, "original"); health.Healthy || original.Status != models.HealthError || original.Fix != "" {
+		t.Errorf("expected an unfixable original, got %+v", health.Checks)
+	}
+}
+
+func TestImageHealth_3DViews(t *testing.T) {
+	dataDir := t.TempDir()
+	logger := logrus.New()
+	storage := NewStorageService(dataDir)
+	svc := NewRenditionService(storage, nil, nil, nil, logger)
+
+	folder := "categories/sculptures/s1"
+	os.MkdirAll(filepath.Join(dataDir, filepath.FromSlash(folder)), 0755)
+	os.WriteFile(filepath.Join(dataDir, filepath.FromSlash(folder), "model.glb"), []byte("glTF"), 0644)
+	views := map[string]string{}
+	for _, view := range []string{"front", "back", "left"} {
+		rel := folder + "/" + view + ".png"
+		if err := imaging.Save(productShot(), filepath.Join(dataDir, filepath.FromSlash(rel))); err != nil {
+			t.Fatal(err)
+		}
+		views[view] = rel
+	}
+
+	metadata := &ImageMetadata{
+		ID: "s1", Title: "Bust", Category: "sculptures", Type: string(models.ImageType3D), UploadedAt: time.Now().Format("2006-01-02 15:04:05"),
+		FolderPath: folder, ModelFilePath: folder + "/model.glb", Views: views,
+	}
+	health := svc.Health(metadata)
+	if check := healthCheck(health, "views"); check.Status != models.HealthError || check.Detail != "missing view right" {
+		t.Errorf("expected the right view to be missing, got %+v", check)
+	}
+	if check := healthCheck(health, "thumbnail-front"); check.Status != models.HealthError || check.Fix != "thumbnail-front" {
+		t.Errorf("expected a fixable front thumbnail, got %+v", check)
+	}
+
+	health = svc.Heal(context.Background(), metadata)
+	for _, view := range []string{"front", "back", "left"} {
+		if check := healthCheck(health, models.ViewThumbnailKind(view)); !check.Fixed {
+			t.Errorf("expected the %s thumbnail to be fixed, got %+v", view, check)
+		}
+	}
+	if health.Healthy {
+		t.Error("expected the missing view to keep the object unhealthy")
+	}
+}
@@ -2,8 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -14,128 +18,1009 @@ type ImageService struct {
 	storageService *StorageService
 	aiService      *AIService
 	indexService   *IndexService
-	jobQueue       chan *models.UploadJob
-	statusMap      map[string]*models.Image
-	statusMutex    sync.RWMutex
+	jobQueue       *jobQueue
+	statusStore    *StatusStore
+	analyses       *AnalysisStore   // full analyses of indexed images; nil keeps none
+	retention      *RetentionPolicy // when images are deleted by category; nil for none
+	vocabulary     *Vocabulary      // controlled vocabulary of scene type, mood and style
+	projects       *ProjectStore    // project legal holds; nil for none
+	audit          *AuditLog        // legal hold changes and refused deletions; nil records none
+	history        *ImageHistory    // per-image event history; nil records none
 	logger         *logrus.Logger
+
+	numWorkers     atomic.Int64
+	nextWorkerID   int
+	workersMutex   sync.Mutex
+	processedCount atomic.Int64
+	failedCount    atomic.Int64
+	busyNanos      atomic.Int64 // total processing time of finished jobs
+
+	timeouts      StageTimeouts
+	inflight      map[string]*inflightJob
+	inflightMutex sync.Mutex
+	jobs          *jobTracker
+
+	failedJobs  map[string]*models.UploadJob // failed uploads that may be retried
+	failedOrder []string                     // failed job IDs, oldest first
+	failedMutex sync.Mutex
+
+	frameExtractor FrameExtractor // turntable clips; nil rejects them
+
+	pendingExternalIDs map[string]string // external ID -> image ID for unfinished jobs
+	externalIDLeases   map[string]*Lease // claims on them shared with other instances
+	externalIDMutex    sync.Mutex
+	coordinator        *Coordinator
+
+	cdnPurger *CDNPurger // purges replaced and deleted files; nil without a CDN
+
+	indexedHooks []func(img *models.Image)
+	updatedHooks []func(img *ImageMetadata, update ImageUpdate)
+}
+
+// ErrJobNotFound is returned when a job is neither queued nor in flight
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrExternalIDConflict is returned when an external ID is already in use
+var ErrExternalIDConflict = errors.New("external ID already in use")
+
+// ErrJobNotCancellable is returned when cancelling a job that is already
+// moving its files into place and will run to completion
+var ErrJobNotCancellable = errors.New("job can no longer be cancelled")
+
+// ErrJobNotRetryable is returned when a failed job's uploaded files are gone
+var ErrJobNotRetryable = errors.New("job files no longer available")
+
+// ErrFilesRemain is returned by DeleteImage when the image is out of the
+// index but some of its files could not be removed
+var ErrFilesRemain = errors.New("image deleted but files remain")
+
+// MaxWorkers bounds the worker count SetWorkers accepts
+const MaxWorkers = 64
+
+// maxRetainedFailures is how many failed jobs are kept for retrying
+const maxRetainedFailures = 100
+
+// StageTimeouts bounds how long each processing stage may run.
+// A zero value disables the timeout for that stage. Image work can't be
+// interrupted, so the thumbnail timeout is only checked between steps: a
+// slow step runs to its end before the stage fails.
+type StageTimeouts struct {
+	Thumbnail time.Duration // thumbnail generation, dimensions, file sizes
+	Analysis  time.Duration // Gemini analysis
 }
 
-func NewImageService(storage *StorageService, ai *AIService, index *IndexService, logger *logrus.Logger) *ImageService {
+// DefaultStageTimeouts returns the timeouts used when none are configured
+func DefaultStageTimeouts() StageTimeouts {
+	return StageTimeouts{
+		Thumbnail: 30 * time.Second,
+		Analysis:  2 * time.Minute,
+	}
+}
+
+// QueueMetrics is a point-in-time view of the processing pipeline
+type QueueMetrics struct {
+	QueueLength   int            `json:"queue_length"`
+	QueueCapacity int            `json:"queue_capacity"`
+	Workers       int            `json:"workers"`
+	Running       map[string]int `json:"running"`               // jobs in flight per type (2D, 3D)
+	TypeLimits    map[string]int `json:"type_limits,omitempty"` // most jobs of a type in flight at once
+	Processed     int64          `json:"processed"`
+	Failed        int64          `json:"failed"`
+	TrackedStatus int            `json:"tracked_statuses"`
+	StatusCounts  map[string]int `json:"status_counts"`
+}
+
+func NewImageService(storage *StorageService, ai *AIService, index *IndexService, status *StatusStore, logger *logrus.Logger) *ImageService {
+	if status == nil {
+		status = NewStatusStore("", 0, 0)
+	}
 	return &ImageService{
 		storageService: storage,
 		aiService:      ai,
 		indexService:   index,
-		jobQueue:       make(chan *models.UploadJob, 100),
-		statusMap:      make(map[string]*models.Image),
+		jobQueue:       newJobQueue(100),
+		statusStore:    status,
 		logger:         logger,
+		timeouts:       DefaultStageTimeouts(),
+		vocabulary:     DefaultVocabulary(),
+		inflight:       make(map[string]*inflightJob),
+		jobs:           newJobTracker(500),
+		failedJobs:     make(map[string]*models.UploadJob),
+
+		pendingExternalIDs: make(map[string]string),
+		externalIDLeases:   make(map[string]*Lease),
 	}
 }
 
+// OnIndexed registers fn to be called after an image has been added to the
+// index. Hooks must be registered before workers are started.
+func (s *ImageService) OnIndexed(fn func(img *models.Image)) {
+	s.indexedHooks = append(s.indexedHooks, fn)
+}
+
+// OnUpdated registers fn to be called after an image's metadata has been
+// updated. Hooks must be registered before the server starts.
+func (s *ImageService) OnUpdated(fn func(img *ImageMetadata, update ImageUpdate)) {
+	s.updatedHooks = append(s.updatedHooks, fn)
+}
+
+// notifyIndexed keeps the full analysis of a newly indexed image and runs
+// the indexed hooks
+func (s *ImageService) notifyIndexed(img *models.Image) {
+	if s.analyses != nil && img.AIAnalysis != nil {
+		if err := s.analyses.Put(img.ID, img.AIAnalysis); err != nil {
+			s.logger.Warnf("Failed to store analysis of %s: %v", img.ID, err)
+		}
+	}
+	s.recordIndexed(img)
+	for _, fn := range s.indexedHooks {
+		fn(img)
+	}
+}
+
+// SetStageTimeouts overrides the per-stage processing timeouts
+func (s *ImageService) SetStageTimeouts(timeouts StageTimeouts) {
+	s.timeouts = timeouts
+}
+
+// SetCoordinator claims external IDs of unfinished jobs through c, so
+// instances sharing the index can't accept the same one at once
+func (s *ImageService) SetCoordinator(c *Coordinator) {
+	s.coordinator = c
+}
+
+// SetAnalysisStore keeps the full analysis of every image indexed from now
+// on in store
+func (s *ImageService) SetAnalysisStore(store *AnalysisStore) {
+	s.analyses = store
+}
+
+// SetRetentionPolicy sets the retention rules reported on listed images
+func (s *ImageService) SetRetentionPolicy(policy *RetentionPolicy) {
+	s.retention = policy
+}
+
+// RetentionPolicy returns the retention rules, nil if there are none
+func (s *ImageService) RetentionPolicy() *RetentionPolicy {
+	return s.retention
+}
+
+// SetVocabulary sets the vocabulary analysis values are reported with
+func (s *ImageService) SetVocabulary(vocabulary *Vocabulary) {
+	s.vocabulary = vocabulary
+}
+
+// Vocabulary returns the vocabulary analysis values are reported with
+func (s *ImageService) Vocabulary() *Vocabulary {
+	return s.vocabulary
+}
+
+// SetFrameExtractor sets how frames are pulled out of turntable clips
+func (s *ImageService) SetFrameExtractor(extractor FrameExtractor) {
+	s.frameExtractor = extractor
+}
+
+// extractClipFrames turns a 3D object's turntable clip into views
+func (s *ImageService) extractClipFrames(ctx context.Context, job *models.UploadJob) (map[string]string, error) {
+	if s.frameExtractor == nil {
+		return nil, errors.New("turntable clips are not supported: no frame extractor configured")
+	}
+	count := job.ClipFrames
+	if count == 0 {
+		count = DefaultClipFrames
+	}
+
+	frames, err := s.frameExtractor.ExtractFrames(ctx, job.ClipPath, count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract frames: %w", err)
+	}
+	return s.storageService.SaveClipFrames(job.ClipPath, frames)
+}
+
 // StartWorkers starts the background workers
 func (s *ImageService) StartWorkers(numWorkers int) {
-	for i := 0; i < numWorkers; i++ {
-		go s.worker(i)
+	s.workersMutex.Lock()
+	defer s.workersMutex.Unlock()
+	s.startWorkers(numWorkers)
+}
+
+// SetWorkers scales the worker pool to n workers (1 to MaxWorkers). Extra
+// workers stop once they finish their current job.
+func (s *ImageService) SetWorkers(n int) error {
+	if n < 1 || n > MaxWorkers {
+		return fmt.Errorf("worker count must be between 1 and %d", MaxWorkers)
+	}
+	s.workersMutex.Lock()
+	defer s.workersMutex.Unlock()
+
+	current := int(s.numWorkers.Load())
+	switch {
+	case n > current:
+		s.startWorkers(n - current)
+	case n < current:
+		s.numWorkers.Add(int64(n - current))
+		s.jobQueue.Retire(current - n)
+		s.logger.Infof("Stopping %d worker goroutines", current-n)
+	}
+	return nil
+}
+
+// startWorkers starts n more workers. Caller must hold workersMutex.
+func (s *ImageService) startWorkers(n int) {
+	s.numWorkers.Add(int64(n))
+	for i := 0; i < n; i++ {
+		go s.worker(s.nextWorkerID)
+		s.nextWorkerID++
+	}
+	s.logger.Infof("Started %d worker goroutines", n)
+}
+
+// SetQueueCapacity sets how many jobs may wait for a worker; uploads beyond
+// it are refused with ErrQueueFull. Non-positive values are ignored.
+func (s *ImageService) SetQueueCapacity(capacity int) {
+	if capacity > 0 {
+		s.jobQueue.SetCapacity(capacity)
 	}
-	s.logger.Infof("Started %d worker goroutines", numWorkers)
+}
+
+// SetTypeConcurrency caps how many jobs of a type the workers process at
+// once (0 = no cap). With 3D capped below the worker count, a burst of 3D
+// uploads always leaves workers for 2D jobs.
+func (s *ImageService) SetTypeConcurrency(jobType models.ImageType, limit int) {
+	s.jobQueue.SetLimit(jobType, limit)
 }
 
 // QueueJob adds a job to the processing queue
 func (s *ImageService) QueueJob(job *models.UploadJob) error {
+	// Claim the external ID so concurrent uploads cannot reuse it
+	if job.ExternalID != "" {
+		if err := s.reserveExternalID(job.ExternalID, job.ImageID); err != nil {
+			return err
+		}
+	}
+
 	// Initialize status
-	s.statusMutex.Lock()
-	s.statusMap[job.ImageID] = &models.Image{
-		ID:         job.ImageID,
-		Title:      job.Title,
-		Artist:     job.Artist,
-		Type:       job.Type,
-		Status:     "processing",
-		UploadedAt: time.Now(),
-		ManualTags: job.ManualTags,
-	}
-	s.statusMutex.Unlock()
-
-	// Add to queue
-	select {
-	case s.jobQueue <- job:
-		return nil
-	default:
-		return fmt.Errorf("job queue is full")
+	s.statusStore.Set(&models.Image{
+		ID:           job.ImageID,
+		Title:        job.Title,
+		Artist:       job.Artist,
+		Type:         job.Type,
+		Status:       "processing",
+		UploadedAt:   time.Now(),
+		ManualTags:   job.ManualTags,
+		License:      job.License,
+		Attributes:   job.Attributes,
+		DesignSource: job.DesignSource,
+		ExternalID:   job.ExternalID,
+		Project:      job.Project,
+		Visibility:   job.Visibility,
+	})
+
+	// Add to queue (ordered by priority). The job is tracked first, since a
+	// worker may pick it up as soon as it is pushed.
+	job.QueuedAt = time.Now()
+	previous := s.jobs.queued(job)
+	if err := s.jobQueue.Push(job); err != nil {
+		s.jobs.unqueued(job.ImageID, previous)
+		s.releaseExternalID(job.ExternalID)
+		s.statusStore.Delete(job.ImageID)
+		return err
 	}
+	return nil
 }
 
 // GetStatus returns the current status of an image
 func (s *ImageService) GetStatus(imageID string) (*models.Image, error) {
-	s.statusMutex.RLock()
-	defer s.statusMutex.RUnlock()
-
-	if img, ok := s.statusMap[imageID]; ok {
+	if img, ok := s.statusStore.Get(imageID); ok {
 		return img, nil
 	}
 	return nil, fmt.Errorf("image not found")
 }
 
+// GetAnalysis returns the full AI analysis of an image: the stored one, or
+// that of an upload the status store still tracks
+func (s *ImageService) GetAnalysis(imageID string) (*models.AIAnalysis, error) {
+	if s.analyses != nil {
+		analysis, err := s.analyses.Get(imageID)
+		if !errors.Is(err, ErrAnalysisNotFound) {
+			return analysis, err
+		}
+	}
+	if img, ok := s.statusStore.Get(imageID); ok && img.AIAnalysis != nil {
+		return img.AIAnalysis, nil
+	}
+	return nil, ErrAnalysisNotFound
+}
+
+// LookupPendingExternalID returns the image ID of an unfinished job that
+// claimed externalID
+func (s *ImageService) LookupPendingExternalID(externalID string) (string, bool) {
+	s.externalIDMutex.Lock()
+	defer s.externalIDMutex.Unlock()
+
+	imageID, ok := s.pendingExternalIDs[externalID]
+	return imageID, ok
+}
+
+// reserveExternalID claims externalID for imageID, failing if an indexed image
+// or another unfinished job already uses it
+func (s *ImageService) reserveExternalID(externalID, imageID string) error {
+	s.externalIDMutex.Lock()
+	defer s.externalIDMutex.Unlock()
+
+	if _, ok := s.pendingExternalIDs[externalID]; ok {
+		return ErrExternalIDConflict
+	}
+
+	// Claim it from other instances before checking the index, which they
+	// only add it to while holding the claim
+	var lease *Lease
+	if s.coordinator != nil {
+		var err error
+		if lease, err = s.coordinator.TryLock("external-id/" + externalID); errors.Is(err, ErrLockHeld) {
+			return ErrExternalIDConflict
+		} else if err != nil {
+			return err
+		}
+	}
+	// Only a confirmed miss frees the ID; an unreadable index is an error
+	if _, err := s.indexService.GetImageByExternalID(externalID); !errors.Is(err, ErrImageNotFound) {
+		if lease != nil {
+			lease.Release()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check external ID: %w", err)
+		}
+		return ErrExternalIDConflict
+	}
+
+	s.pendingExternalIDs[externalID] = imageID
+	if lease != nil {
+		s.externalIDLeases[externalID] = lease
+	}
+	return nil
+}
+
+// releaseExternalID drops a pending claim once the job has finished
+func (s *ImageService) releaseExternalID(externalID string) {
+	if externalID == "" {
+		return
+	}
+	s.externalIDMutex.Lock()
+	lease := s.externalIDLeases[externalID]
+	delete(s.pendingExternalIDs, externalID)
+	delete(s.externalIDLeases, externalID)
+	s.externalIDMutex.Unlock()
+	if lease != nil {
+		lease.Release()
+	}
+}
+
+// ListJobs returns queued, running and recently finished jobs, newest first.
+// An empty state returns all jobs.
+func (s *ImageService) ListJobs(state string) []models.JobInfo {
+	return s.jobs.list(state)
+}
+
+// GetJob returns a single tracked job
+func (s *ImageService) GetJob(imageID string) (models.JobInfo, error) {
+	if info, ok := s.jobs.get(imageID); ok {
+		return info, nil
+	}
+	return models.JobInfo{}, ErrJobNotFound
+}
+
+// StartBackgroundJob registers a non-upload job (e.g. an embedding backfill)
+// so it shows up in the jobs API
+func (s *ImageService) StartBackgroundJob(id, kind, title string) {
+	s.jobs.startedBackground(id, kind, title)
+}
+
+// UpdateJobProgress reports progress for a background job
+func (s *ImageService) UpdateJobProgress(id string, progress models.JobProgress) {
+	s.jobs.progress(id, progress)
+}
+
+// FinishBackgroundJob records the outcome of a background job. A job stopped
+// through its context counts as cancelled.
+func (s *ImageService) FinishBackgroundJob(id string, err error) {
+	if errors.Is(err, context.Canceled) {
+		s.jobs.finish(id, models.JobStateCancelled, nil)
+		return
+	}
+	if err != nil {
+		s.jobs.finish(id, models.JobStateError, err)
+		return
+	}
+	s.jobs.finish(id, models.JobStateCompleted, nil)
+}
+
+// RetryAfter estimates how long a refused upload should wait before trying
+// again: the average job duration divided among the workers, at least a
+// second. Before any job has finished it assumes five seconds per job.
+func (s *ImageService) RetryAfter() time.Duration {
+	perJob := 5 * time.Second
+	if finished := s.processedCount.Load() + s.failedCount.Load(); finished > 0 {
+		perJob = time.Duration(s.busyNanos.Load() / finished)
+	}
+	wait := perJob
+	if workers := s.numWorkers.Load(); workers > 1 {
+		wait /= time.Duration(workers)
+	}
+	return max(wait, time.Second)
+}
+
+// Metrics returns queue depth, worker count and status counters
+func (s *ImageService) Metrics() QueueMetrics {
+	return QueueMetrics{
+		QueueLength:   s.jobQueue.Len(),
+		QueueCapacity: s.jobQueue.Cap(),
+		Workers:       int(s.numWorkers.Load()),
+		Running:       s.jobQueue.Running(),
+		TypeLimits:    s.jobQueue.Limits(),
+		Processed:     s.processedCount.Load(),
+		Failed:        s.failedCount.Load(),
+		TrackedStatus: s.statusStore.Len(),
+		StatusCounts:  s.statusStore.CountByStatus(),
+	}
+}
+
+// inflightJob is a job a worker is processing
+type inflightJob struct {
+	cancel    context.CancelFunc
+	committed bool // past the last point it can be cancelled (see commitJob)
+}
+
+// CancelJob cancels a queued or in-flight job. Queued jobs are removed and
+// their temp files deleted; in-flight jobs are cancelled before they are moved
+// into a category folder. Once moving, a job runs to completion and
+// CancelJob returns ErrJobNotCancellable.
+func (s *ImageService) CancelJob(imageID string) error {
+	if job, ok := s.jobQueue.Remove(imageID); ok {
+		s.storageService.CleanupTemp(job)
+		s.releaseExternalID(job.ExternalID)
+		s.updateStatus(imageID, "cancelled")
+		s.jobs.finish(imageID, models.JobStateCancelled, nil)
+		s.logger.Infof("Cancelled queued job %s", imageID)
+		return nil
+	}
+
+	s.inflightMutex.Lock()
+	defer s.inflightMutex.Unlock()
+	inflight, ok := s.inflight[imageID]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if inflight.committed {
+		return ErrJobNotCancellable
+	}
+
+	inflight.cancel()
+	s.logger.Infof("Cancellation requested for in-flight job %s", imageID)
+	return nil
+}
+
+// commitJob is the last point an in-flight job can be cancelled: it returns
+// the job context's error if it was, and otherwise makes later cancellations
+// fail with ErrJobNotCancellable, as the job then runs to completion
+func (s *ImageService) commitJob(ctx context.Context, imageID string) error {
+	s.inflightMutex.Lock()
+	defer s.inflightMutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if inflight, ok := s.inflight[imageID]; ok {
+		inflight.committed = true
+	}
+	return nil
+}
+
+// RetryJob queues a failed upload again, from the files it was uploaded
+// with. Failures after the files were moved into a category folder can't be
+// retried (ErrJobNotRetryable).
+func (s *ImageService) RetryJob(imageID string) error {
+	s.failedMutex.Lock()
+	job, ok := s.failedJobs[imageID]
+	s.failedMutex.Unlock()
+	if !ok {
+		return ErrJobNotFound
+	}
+	if !s.storageService.HasTemp(job) {
+		s.forgetFailed(imageID)
+		return ErrJobNotRetryable
+	}
+
+	// Start over with the handler's save, the only stage not redone
+	var timeline []models.StageTiming
+	for _, stage := range job.Timeline {
+		if stage.Stage == models.StageSave {
+			timeline = append(timeline, stage)
+		}
+	}
+	retry := *job
+	retry.Timeline = timeline
+	if retry.ClipPath != "" {
+		retry.FilePaths = nil
+	}
+	if err := s.QueueJob(&retry); err != nil {
+		return err
+	}
+	s.forgetFailed(imageID)
+	s.logger.Infof("Retrying job %s", imageID)
+	return nil
+}
+
+// rememberFailed keeps a failed job so it can be retried; only the most
+// recent maxRetainedFailures are kept, and the temp files of the jobs
+// dropped are removed since they can no longer be retried
+func (s *ImageService) rememberFailed(job *models.UploadJob) {
+	s.failedMutex.Lock()
+	if _, ok := s.failedJobs[job.ImageID]; !ok {
+		s.failedOrder = append(s.failedOrder, job.ImageID)
+	}
+	s.failedJobs[job.ImageID] = job
+	var evicted []*models.UploadJob
+	for len(s.failedOrder) > maxRetainedFailures {
+		evicted = append(evicted, s.failedJobs[s.failedOrder[0]])
+		delete(s.failedJobs, s.failedOrder[0])
+		s.failedOrder = s.failedOrder[1:]
+	}
+	s.failedMutex.Unlock()
+
+	for _, job := range evicted {
+		s.storageService.CleanupTemp(job)
+	}
+}
+
+// forgetFailed drops a failed job once it has been retried
+func (s *ImageService) forgetFailed(imageID string) {
+	s.failedMutex.Lock()
+	defer s.failedMutex.Unlock()
+
+	delete(s.failedJobs, imageID)
+	for i, id := range s.failedOrder {
+		if id == imageID {
+			s.failedOrder = append(s.failedOrder[:i], s.failedOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// AIUsage returns counts of the AI calls made since the server started
+func (s *ImageService) AIUsage() map[string]AICallStats {
+	return s.aiService.Usage()
+}
+
+// AIVersions returns what new analyses are made with
+func (s *ImageService) AIVersions() AnalysisVersions {
+	return s.aiService.Versions()
+}
+
+// AnalysisPreview is the outcome of a dry-run analysis: what an upload would
+// be categorized and tagged as, without anything being stored
+type AnalysisPreview struct {
+	Category        string             `json:"category"`
+	PrimaryCategory string             `json:"primary_category"`
+	SubCategory     string             `json:"sub_category,omitempty"`
+	Description     string             `json:"description"`
+	Tags            []string           `json:"tags"`
+	Analysis        *models.AIAnalysis `json:"analysis"`
+}
+
+// PreviewAnalysis runs the AI analysis on an image without ingesting it
+func (s *ImageService) PreviewAnalysis(ctx context.Context, imagePath string) (*AnalysisPreview, error) {
+	analysis, err := s.analyzeOnly(ctx, imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AnalysisPreview{
+		Category:        s.aiService.GetCategoryPath(analysis),
+		PrimaryCategory: analysis.PrimaryCategory,
+		SubCategory:     analysis.SubCategory,
+		Description:     analysis.Description,
+		Tags:            featureTags(analysis),
+		Analysis:        analysis,
+	}, nil
+}
+
+// CategoryPrediction is the category and tags an image would be filed under
+type CategoryPrediction struct {
+	Category        string   `json:"category"`
+	PrimaryCategory string   `json:"primary_category"`
+	SubCategory     string   `json:"sub_category,omitempty"`
+	Tags            []string `json:"tags"`
+}
+
+// PredictCategory categorizes an image with the warehouse's prompts and
+// taxonomy, within the allowed primary categories if any, without storing
+// anything. Other tools use it to categorize their own images.
+func (s *ImageService) PredictCategory(ctx context.Context, imagePath string, allowed []string) (*CategoryPrediction, error) {
+	analysis, err := s.analyzeOnly(ctx, imagePath)
+	if err != nil {
+		return nil, err
+	}
+	s.aiService.ConstrainCategory(analysis, allowed)
+
+	return &CategoryPrediction{
+		Category:        s.aiService.GetCategoryPath(analysis),
+		PrimaryCategory: analysis.PrimaryCategory,
+		SubCategory:     analysis.SubCategory,
+		Tags:            featureTags(analysis),
+	}, nil
+}
+
+// analyzeOnly runs the 2D analysis of an image outside of an upload job
+func (s *ImageService) analyzeOnly(ctx context.Context, imagePath string) (*models.AIAnalysis, error) {
+	var analysis *models.AIAnalysis
+	err := runStage(ctx, "analysis", s.timeouts.Analysis, func(ctx context.Context) error {
+		var err error
+		analysis, err = s.aiService.Analyze2DImage(ctx, imagePath)
+		if err != nil {
+			return fmt.Errorf("failed to analyze image: %w", err)
+		}
+		return nil
+	})
+	return analysis, err
+}
+
+// featureTags returns the names of the features detected by an analysis
+func featureTags(analysis *models.AIAnalysis) []string {
+	tags := make([]string, 0, len(analysis.Features))
+	for _, f := range analysis.Features {
+		tags = append(tags, f.Name)
+	}
+	return tags
+}
+
+// UpdateImage applies a metadata update to an indexed image, guarded by the
+// expected revision, and keeps the cached status in sync
+func (s *ImageService) UpdateImage(imageID string, expectedRevision int, update ImageUpdate) (*ImageMetadata, error) {
+	var before *ImageMetadata
+	if s.history != nil {
+		before, _ = s.indexService.GetImageByID(imageID)
+	}
+	updated, err := s.indexService.UpdateImage(imageID, expectedRevision, update)
+	if err != nil {
+		return nil, err
+	}
+	s.applyUpdated(before, updated, update)
+	return updated, nil
+}
+
+// UpdateImages applies one metadata update to many images in a single
+// rewrite of the index (see IndexService.UpdateImages), then does for each
+// updated image what UpdateImage does
+func (s *ImageService) UpdateImages(imageIDs []string, update ImageUpdate) ([]*ImageMetadata, map[string]error, error) {
+	changes, skipped, err := s.indexService.UpdateImages(imageIDs, update)
+	updated := make([]*ImageMetadata, 0, len(changes))
+	for _, change := range changes {
+		s.applyUpdated(change.Before, change.After, update)
+		updated = append(updated, change.After)
+	}
+	return updated, skipped, err
+}
+
+// applyUpdated records an index update in the history and carries it over to
+// the cached status, the stored analysis, the square thumbnail and the update
+// hooks
+func (s *ImageService) applyUpdated(before, updated *ImageMetadata, update ImageUpdate) {
+	imageID := updated.ID
+	s.recordEdited(before, updated, update.Actor)
+
+	s.statusStore.Update(imageID, func(img *models.Image) {
+		img.Title = updated.Title
+		img.Artist = updated.Artist
+		img.ManualTags = updated.Tags
+		img.Revision = updated.Revision
+		img.License = updated.License
+		img.Attributes = updated.Attributes
+		img.Project = updated.Project
+		img.Visibility = updated.Visibility
+		img.FocalPoint = updated.FocalPoint
+		img.Bounds = updated.Bounds
+		img.Triangles = updated.Triangles
+		img.LODTags = updated.LODTags
+		img.SquareThumbnail = updated.SquareThumbnail
+		if img.AIAnalysis != nil && update.Description != nil {
+			img.AIAnalysis.Description = updated.Description
+		}
+	})
+	if s.analyses != nil && update.Description != nil {
+		err := s.analyses.Update(imageID, func(analysis *models.AIAnalysis) {
+			analysis.Description = updated.Description
+		})
+		if err != nil && !errors.Is(err, ErrAnalysisNotFound) {
+			s.logger.Warnf("Failed to update stored analysis of %s: %v", imageID, err)
+		}
+	}
+
+	// The focal point is recorded either way; a failed crop keeps the old one
+	if update.FocalPoint != nil {
+		if err := s.regenerateSquareThumbnail(updated); err != nil {
+			s.logger.Warnf("Failed to regenerate square thumbnail for %s: %v", imageID, err)
+		} else {
+			s.PurgeCDN(imageID, PurgeRenditionChanged, updated.SquareThumbnail)
+		}
+	}
+
+	for _, fn := range s.updatedHooks {
+		fn(updated, update)
+	}
+}
+
+// regenerateSquareThumbnail recrops an image's square thumbnail around its
+// recorded focal point
+func (s *ImageService) regenerateSquareThumbnail(img *ImageMetadata) error {
+	imagePath, err := s.storageService.StoredPath(img.FilePath)
+	if err != nil {
+		return err
+	}
+	squarePath, err := s.storageService.StoredPath(img.SquareThumbnail)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.storageService.GenerateSquareThumbnailAt(imagePath, squarePath, img.FocalPoint)
+	return err
+}
+
+// DeleteImage removes an indexed image and its files. The index keeps a
+// tombstone recording the deletion time and actor. Images on legal hold are
+// kept, and the refused attempt is recorded in the audit log (ErrLegalHold).
+// When files can't be removed, the image stays deleted and ErrFilesRemain is
+// returned.
+func (s *ImageService) DeleteImage(imageID, actor string) error {
+	if img, err := s.indexService.GetImageByID(imageID); err == nil {
+		if heldBy := s.HeldBy(img); heldBy != "" {
+			return s.refuseDeletion(imageID, actor, heldBy)
+		}
+	}
+	deleted, err := s.indexService.DeleteImage(imageID, actor)
+	if errors.Is(err, ErrLegalHold) {
+		// Held since the check above
+		return s.refuseDeletion(imageID, actor, "image")
+	}
+	if err != nil {
+		return err
+	}
+	s.statusStore.Delete(imageID)
+	s.recordEvent(ImageEvent{ImageID: imageID, Type: EventDeleted, Actor: actor})
+	if s.analyses != nil {
+		if err := s.analyses.Delete(imageID); err != nil {
+			s.logger.Warnf("Failed to delete analysis of %s: %v", imageID, err)
+		}
+	}
+
+	paths := []string{deleted.FilePath, deleted.ThumbnailPath, deleted.SquareThumbnail}
+	if deleted.FilePath != "" {
+		paths = append(paths, cutoutPath(deleted.FilePath), xmpSidecarPath(deleted.FilePath))
+	}
+	for _, upscale := range deleted.Upscales {
+		paths = append(paths, upscale)
+	}
+	if deleted.Type == string(models.ImageType3D) {
+		paths = []string{deleted.FolderPath}
+	}
+	paths = s.unsharedPaths(paths)
+	if ownsSquareThumbnail(deleted) && !slices.Contains(paths, deleted.SquareThumbnail) {
+		// No other image uses it, even when they share the content
+		paths = append(paths, deleted.SquareThumbnail)
+	}
+	filesErr := s.storageService.DeleteStoredFiles(paths...)
+	if filesErr != nil {
+		s.logger.Warnf("Image %s deleted from index but files remain: %v", imageID, filesErr)
+	}
+	s.purgeDeleted(deleted, paths)
+
+	s.logger.Infof("Deleted image %s (by %s)", imageID, actor)
+	if filesErr != nil {
+		return fmt.Errorf("%w: %v", ErrFilesRemain, filesErr)
+	}
+	return nil
+}
+
+// unsharedPaths drops the content-addressed paths whose content other images
+// still use, so deleting one of several identical uploads keeps the files.
+// The deleted image must already be out of the index.
+func (s *ImageService) unsharedPaths(paths []string) []string {
+	shared := false
+	for _, path := range paths {
+		shared = shared || IsContentAddressed(path)
+	}
+	if !shared {
+		return paths
+	}
+
+	images, err := s.indexService.GetAllImages()
+	if err != nil {
+		s.logger.Warnf("Keeping content-addressed files, index unreadable: %v", err)
+		return nil
+	}
+	inUse := make(map[string]bool)
+	for _, img := range images {
+		for _, path := range []string{img.FilePath, img.FolderPath} {
+			if key := contentKey(path); key != "" {
+				inUse[key] = true
+			}
+		}
+	}
+
+	var unshared []string
+	for _, path := range paths {
+		if key := contentKey(path); key == "" || !inUse[key] {
+			unshared = append(unshared, path)
+		}
+	}
+	return unshared
+}
+
 // worker processes jobs from the queue
 func (s *ImageService) worker(id int) {
 	s.logger.Infof("Worker %d started", id)
 
-	for job := range s.jobQueue {
-		s.logger.Infof("Worker %d processing job for image %s (type: %s)", id, job.ImageID, job.Type)
+	for {
+		job, ok := s.jobQueue.Pop()
+		if !ok {
+			s.logger.Infof("Worker %d stopped", id)
+			return
+		}
+		s.logger.Infof("Worker %d processing job for image %s (type: %s, priority: %s)", id, job.ImageID, job.Type, job.Priority)
+
+		s.jobs.started(job.ImageID)
+		started := time.Now()
+		if !job.QueuedAt.IsZero() {
+			job.RecordStage(models.StageQueue, job.QueuedAt)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		s.inflightMutex.Lock()
+		s.inflight[job.ImageID] = &inflightJob{cancel: cancel}
+		s.inflightMutex.Unlock()
 
 		var err error
 		if job.Type == models.ImageType2D {
-			err = s.process2DJob(job)
+			err = s.process2DJob(ctx, job)
 		} else if job.Type == models.ImageType3D {
-			err = s.process3DJob(job)
+			err = s.process3DJob(ctx, job)
 		} else {
 			err = fmt.Errorf("unknown job type: %s", job.Type)
 		}
 
-		if err != nil {
+		s.inflightMutex.Lock()
+		delete(s.inflight, job.ImageID)
+		s.inflightMutex.Unlock()
+		cancel()
+		s.jobQueue.Done(job)
+		elapsed := int64(time.Since(started))
+
+		// Completed jobs are now in the index; failed ones free the ID
+		s.releaseExternalID(job.ExternalID)
+		s.logTimeline(job, err)
+
+		if errors.Is(err, context.Canceled) {
+			s.logger.Infof("Worker %d cancelled job %s", id, job.ImageID)
+			s.storageService.CleanupTemp(job)
+			s.updateStatus(job.ImageID, "cancelled")
+			s.jobs.finish(job.ImageID, models.JobStateCancelled, nil)
+		} else if err != nil {
 			s.logger.Errorf("Worker %d failed to process job %s: %v", id, job.ImageID, err)
+			s.failedCount.Add(1)
+			s.busyNanos.Add(elapsed)
 			s.updateStatus(job.ImageID, "error")
+			s.jobs.finish(job.ImageID, models.JobStateError, err)
+			s.rememberFailed(job)
 		} else {
 			s.logger.Infof("Worker %d completed job %s", id, job.ImageID)
+			s.processedCount.Add(1)
+			s.busyNanos.Add(elapsed)
 			s.updateStatus(job.ImageID, "completed")
+			s.jobs.finish(job.ImageID, models.JobStateCompleted, nil)
 		}
 	}
 }
 
-// process2DJob processes a 2D image job
-func (s *ImageService) process2DJob(job *models.UploadJob) error {
-	ctx := context.Background()
-
-	// 1. Generate thumbnail
-	s.logger.Infof("Generating thumbnail for %s", job.ImageID)
-	_, err := s.storageService.GenerateThumbnail(job.FilePath)
-	if err != nil {
-		return fmt.Errorf("failed to generate thumbnail: %w", err)
+// logTimeline logs how long each stage of a finished job took, one field per
+// stage (e.g. analysis_ms), and keeps the timeline on the image's status
+func (s *ImageService) logTimeline(job *models.UploadJob, err error) {
+	fields := logrus.Fields{"image_id": job.ImageID, "type": job.Type}
+	var total int64
+	for _, stage := range job.Timeline {
+		fields[stage.Stage+"_ms"] = stage.DurationMs
+		total += stage.DurationMs
 	}
+	fields["total_ms"] = total
 
-	// 2. Get image dimensions
-	width, height, err := s.storageService.GetImageDimensions(job.FilePath)
+	entry := s.logger.WithFields(fields)
 	if err != nil {
-		return fmt.Errorf("failed to get dimensions: %w", err)
+		entry.WithError(err).Info("Processing timeline (failed)")
+	} else {
+		entry.Info("Processing timeline")
 	}
 
-	// 3. Get file size
-	fileSize, err := s.storageService.GetFileSize(job.FilePath)
+	s.statusStore.Update(job.ImageID, func(img *models.Image) {
+		img.Timeline = job.Timeline
+	})
+}
+
+// process2DJob processes a 2D image job
+func (s *ImageService) process2DJob(ctx context.Context, job *models.UploadJob) error {
+	// 1-3. Generate thumbnail, get dimensions and file size
+	s.logger.Infof("Generating thumbnail for %s", job.ImageID)
+	var width, height int
+	var fileSize int64
+	var colorSpace, mimeType, visualHash string
+	var quality *models.Quality
+	var location *models.GeoPoint
+	var capturedAt *time.Time
+	var focal models.FocalPoint
+	err := timedStage(ctx, job, models.StageThumbnail, s.timeouts.Thumbnail, func(ctx context.Context) error {
+		if _, err := s.storageService.GenerateThumbnail(job.FilePath); err != nil {
+			return fmt.Errorf("failed to generate thumbnail: %w", err)
+		}
+		// Image work can't be interrupted, so the stage stops between steps
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var err error
+		if _, focal, err = s.storageService.GenerateSquareThumbnail(job.FilePath, nil); err != nil {
+			return fmt.Errorf("failed to generate square thumbnail: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		colorSpace = DetectColorSpace(job.FilePath)
+		if quality, visualHash, err = MeasureImageFile(job.FilePath); err != nil {
+			s.logger.Warnf("Failed to measure quality of %s: %v", job.ImageID, err)
+		}
+		mimeType = DetectMimeType(job.FilePath)
+		location = DetectLocation(job.FilePath)
+		capturedAt = DetectCaptureTime(job.FilePath)
+
+		width, height, err = s.storageService.GetImageDimensions(job.FilePath)
+		if err != nil {
+			return fmt.Errorf("failed to get dimensions: %w", err)
+		}
+
+		fileSize, err = s.storageService.GetFileSize(job.FilePath)
+		if err != nil {
+			return fmt.Errorf("failed to get file size: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get file size: %w", err)
+		return err
 	}
 
 	// 4. Analyze with AI
 	s.logger.Infof("Analyzing 2D image %s with Gemini", job.ImageID)
-	analysis, err := s.aiService.Analyze2DImage(ctx, job.FilePath)
+	var analysis *models.AIAnalysis
+	err = timedStage(ctx, job, models.StageAnalysis, s.timeouts.Analysis, func(ctx context.Context) error {
+		var err error
+		analysis, err = s.aiService.Analyze2DImage(ctx, job.FilePath)
+		if err != nil {
+			return fmt.Errorf("failed to analyze image: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to analyze image: %w", err)
+		return err
 	}
 
-	// 5. Determine category path
+	// 5. Determine category path (within the project's categories, if any)
+	s.aiService.ConstrainCategory(analysis, job.Categories)
 	categoryPath := s.aiService.GetCategoryPath(analysis)
 	s.logger.Infof("Image %s categorized as: %s", job.ImageID, categoryPath)
 
+	// Last chance to cancel: past this point the job runs to completion
+	if err := s.commitJob(ctx, job.ImageID); err != nil {
+		return err
+	}
+
 	// 6. Move to category folder
-	filePath, thumbPathFinal, err := s.storageService.MoveToCategory(job.ImageID, job.FilePath, categoryPath)
+	start := time.Now()
+	filePath, thumbPathFinal, err := s.storageService.MoveToCategory(job.ImageID, job.FilePath, Placement{Category: categoryPath, Artist: job.Artist, Time: start})
+	job.RecordStage(models.StageMove, start)
 	if err != nil {
 		return fmt.Errorf("failed to move to category: %w", err)
 	}
@@ -143,83 +1028,221 @@ func (s *ImageService) process2DJob(job *models.UploadJob) error {
 	// 7. Update image metadata
 	now := time.Now()
 	image := &models.Image{
-		ID:            job.ImageID,
-		Title:         job.Title,
-		Artist:        job.Artist,
-		Type:          models.ImageType2D,
-		UploadedAt:    time.Now(),
-		ProcessedAt:   &now,
-		Status:        "completed",
-		FilePath:      filePath,
-		ThumbnailPath: thumbPathFinal,
-		FileSize:      fileSize,
-		Width:         width,
-		Height:        height,
-		Category:      categoryPath,
-		ManualTags:    job.ManualTags,
-		AIAnalysis:    analysis,
+		ID:               job.ImageID,
+		Title:            job.Title,
+		Artist:           job.Artist,
+		Type:             models.ImageType2D,
+		UploadedAt:       time.Now(),
+		ProcessedAt:      &now,
+		Status:           "completed",
+		OriginalFilename: job.OriginalFilename,
+		MimeType:         mimeType,
+		FilePath:         filePath,
+		ThumbnailPath:    thumbPathFinal,
+		SquareThumbnail:  s.storageService.getSquareThumbnailPath(filePath),
+		FocalPoint:       &focal,
+		FileSize:         fileSize,
+		ColorSpace:       colorSpace,
+		Quality:          quality,
+		VisualHash:       visualHash,
+		Location:         location,
+		CapturedAt:       capturedAt,
+		Category:         categoryPath,
+		StorageLayout:    s.storageService.Layout(),
+		ManualTags:       job.ManualTags,
+		License:          job.License,
+		Attributes:       job.Attributes,
+		DesignSource:     job.DesignSource,
+		ExternalID:       job.ExternalID,
+		Project:          job.Project,
+		Visibility:       job.Visibility,
+		Revision:         1,
+		AIAnalysis:       analysis,
 	}
+	image.SetDimensions(width, height)
 
 	// 8. Append to index
 	s.logger.Infof("Adding image %s to index", job.ImageID)
-	if err := s.indexService.AppendToIndex(image); err != nil {
+	start = time.Now()
+	err = s.indexService.AppendToIndex(image)
+	job.RecordStage(models.StageIndex, start)
+	if err != nil {
 		return fmt.Errorf("failed to append to index: %w", err)
 	}
+	image.Timeline = job.Timeline
 
 	// 9. Update in-memory status
-	s.statusMutex.Lock()
-	s.statusMap[job.ImageID] = image
-	s.statusMutex.Unlock()
+	s.statusStore.Set(image)
+	s.notifyIndexed(image)
 
 	return nil
 }
 
 // process3DJob processes a 3D object job
-func (s *ImageService) process3DJob(job *models.UploadJob) error {
-	ctx := context.Background()
+func (s *ImageService) process3DJob(ctx context.Context, job *models.UploadJob) error {
+	// 0. Turntable clips are turned into views first
+	if job.ClipPath != "" {
+		s.logger.Infof("Extracting frames from turntable clip of 3D object %s", job.ImageID)
+		err := timedStage(ctx, job, models.StageFrames, s.timeouts.Thumbnail, func(ctx context.Context) error {
+			views, err := s.extractClipFrames(ctx, job)
+			if err != nil {
+				return err
+			}
+			job.FilePaths = views
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
 
-	// 1. Generate thumbnails for all views (4 or 6)
+	// 1-2. Generate thumbnails for all views (4 or 6) and calculate total
+	// file size (including model file)
 	s.logger.Infof("Generating thumbnails for 3D object %s", job.ImageID)
-	_, err := s.storageService.GenerateThumbnails3D(job.FilePaths)
+	var totalSize int64
+	var colorSpace string
+	var quality *models.Quality
+	var width, height int
+	hasTurntable := false
+	err := timedStage(ctx, job, models.StageThumbnail, s.timeouts.Thumbnail, func(ctx context.Context) error {
+		if _, err := s.storageService.GenerateThumbnails3D(job.FilePaths); err != nil {
+			return fmt.Errorf("failed to generate thumbnails: %w", err)
+		}
+		// Image work can't be interrupted, so the stage stops between steps
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if front, ok := job.FilePaths["front"]; ok {
+			colorSpace = DetectColorSpace(front)
+			var err error
+			if quality, _, err = MeasureImageFile(front); err != nil {
+				s.logger.Warnf("Failed to measure quality of %s: %v", job.ImageID, err)
+			}
+			w, h, err := s.storageService.GetImageDimensions(front)
+			if err != nil {
+				s.logger.Warnf("Failed to get front view dimensions for %s: %v", job.ImageID, err)
+			}
+			width, height = w, h
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Animated preview for full view sets; optional, so failures only warn
+		if HasTurntableViews(job.FilePaths) {
+			if _, err := s.storageService.GenerateTurntable(job.FilePaths); err != nil {
+				s.logger.Warnf("Failed to generate turntable for %s: %v", job.ImageID, err)
+			} else {
+				hasTurntable = true
+			}
+		}
+
+		for _, path := range job.FilePaths {
+			size, err := s.storageService.GetFileSize(path)
+			if err != nil {
+				return fmt.Errorf("failed to get file size: %w", err)
+			}
+			totalSize += size
+		}
+		// Add clip and model file sizes
+		if job.ClipPath != "" {
+			clipSize, err := s.storageService.GetFileSize(job.ClipPath)
+			if err != nil {
+				return fmt.Errorf("failed to get clip file size: %w", err)
+			}
+			totalSize += clipSize
+		}
+		if job.ModelFilePath != "" {
+			modelSize, err := s.storageService.GetFileSize(job.ModelFilePath)
+			if err != nil {
+				return fmt.Errorf("failed to get model file size: %w", err)
+			}
+			totalSize += modelSize
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to generate thumbnails: %w", err)
+		return err
 	}
 
-	// 2. Calculate total file size (including model file)
-	var totalSize int64
-	for _, path := range job.FilePaths {
-		size, err := s.storageService.GetFileSize(path)
-		if err != nil {
-			return fmt.Errorf("failed to get file size: %w", err)
+	// Real-world size and triangle count: entered at upload, or read from
+	// the model file, as are its materials and format
+	start := time.Now()
+	bounds := job.Bounds
+	if bounds == nil && job.ModelFilePath != "" {
+		measured, err := ReadModelBounds(job.ModelFilePath)
+		if err != nil && !errors.Is(err, errModelFormat) {
+			s.logger.Warnf("Failed to read bounds of 3D object %s: %v", job.ImageID, err)
+		}
+		bounds = measured
+	}
+	triangles := job.Triangles
+	if triangles == 0 && job.ModelFilePath != "" {
+		counted, err := CountModelTriangles(job.ModelFilePath)
+		if err != nil && !errors.Is(err, errModelFormat) {
+			s.logger.Warnf("Failed to count triangles of 3D object %s: %v", job.ImageID, err)
+		}
+		triangles = counted
+	}
+	var materials *models.MaterialSummary
+	if job.ModelFilePath != "" {
+		summary, err := ReadModelMaterials(job.ModelFilePath)
+		if err != nil && !errors.Is(err, errModelFormat) {
+			s.logger.Warnf("Failed to read materials of 3D object %s: %v", job.ImageID, err)
 		}
-		totalSize += size
+		materials = summary
 	}
-	// Add model file size
+	var modelInfo *models.ModelInfo
 	if job.ModelFilePath != "" {
-		modelSize, err := s.storageService.GetFileSize(job.ModelFilePath)
+		info, err := ReadModelInfo(job.ModelFilePath)
 		if err != nil {
-			return fmt.Errorf("failed to get model file size: %w", err)
+			s.logger.Warnf("Failed to read model info of 3D object %s: %v", job.ImageID, err)
 		}
-		totalSize += modelSize
+		modelInfo = info
 	}
+	job.RecordStage(models.StageModel, start)
 
 	// 3. Analyze with AI (all surface views together)
 	viewCount := len(job.FilePaths)
 	s.logger.Infof("Analyzing 3D object %s with Gemini (%d views)", job.ImageID, viewCount)
-	analysis, err := s.aiService.Analyze3DObject(ctx, job.FilePaths)
+	var analysis *models.AIAnalysis
+	err = timedStage(ctx, job, models.StageAnalysis, s.timeouts.Analysis, func(ctx context.Context) error {
+		var err error
+		analysis, err = s.aiService.Analyze3DObject(ctx, job.FilePaths)
+		if err != nil {
+			return fmt.Errorf("failed to analyze 3D object: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to analyze 3D object: %w", err)
+		return err
 	}
 
-	// 4. Determine category path
+	// 4. Determine category path (within the project's categories, if any)
+	s.aiService.ConstrainCategory(analysis, job.Categories)
 	categoryPath := s.aiService.GetCategoryPath(analysis)
 	s.logger.Infof("3D object %s categorized as: %s", job.ImageID, categoryPath)
 
+	// Last chance to cancel: past this point the job runs to completion
+	if err := s.commitJob(ctx, job.ImageID); err != nil {
+		return err
+	}
+
 	// 5. Move to category folder
-	folderPath, modelPath, views, err := s.storageService.Move3DToCategory(job.ImageID, "", categoryPath)
+	start = time.Now()
+	folderPath, modelPath, views, err := s.storageService.Move3DToCategory(job.ImageID, "", Placement{Category: categoryPath, Artist: job.Artist, Time: start})
+	job.RecordStage(models.StageMove, start)
 	if err != nil {
 		return fmt.Errorf("failed to move to category: %w", err)
 	}
+	var turntablePath, clipPath string
+	if hasTurntable {
+		turntablePath = filepath.Join(folderPath, TurntableFilename)
+	}
+	if job.ClipPath != "" {
+		clipPath = filepath.Join(folderPath, filepath.Base(job.ClipPath))
+	}
 
 	// 6. Update image metadata
 	now := time.Now()
@@ -234,37 +1257,113 @@ func (s *ImageService) process3DJob(job *models.UploadJob) error {
 		FolderPath:    folderPath,
 		ModelFilePath: modelPath,
 		ModelFilename: job.ModelFilename,
+		Bounds:        bounds,
+		Triangles:     triangles,
+		LODTags:       job.LODTags,
+		Materials:     materials,
+		ModelInfo:     modelInfo,
 		Views:         views,
+		PosterView:    SelectPosterView(views, analysis.BestView),
+		TurntablePath: turntablePath,
+		ClipPath:      clipPath,
+		ColorSpace:    colorSpace,
+		Quality:       quality,
 		TotalFileSize: totalSize,
 		Category:      categoryPath,
+		StorageLayout: s.storageService.Layout(),
 		ManualTags:    job.ManualTags,
+		License:       job.License,
+		Attributes:    job.Attributes,
+		ExternalID:    job.ExternalID,
+		Project:       job.Project,
+		Visibility:    job.Visibility,
+		Revision:      1,
 		AIAnalysis:    analysis,
 	}
+	image.SetDimensions(width, height)
 
-	// 7. Append to index
+	// 7. Append to index (rolls back the move on failure)
 	s.logger.Infof("Adding 3D object %s to index", job.ImageID)
-	if err := s.indexService.AppendToIndex(image); err != nil {
-		return fmt.Errorf("failed to append to index: %w", err)
+	start = time.Now()
+	err = s.indexService.AppendToIndex(image)
+	job.RecordStage(models.StageIndex, start)
+	if err != nil {
+		return s.rollback3DMove(job.ImageID, image, err)
 	}
+	image.Timeline = job.Timeline
 
 	// 8. Update in-memory status
-	s.statusMutex.Lock()
-	s.statusMap[job.ImageID] = image
-	s.statusMutex.Unlock()
+	s.statusStore.Set(image)
+	s.notifyIndexed(image)
+
+	return nil
+}
+
+// rollback3DMove compensates for a failed index append after a 3D object was
+// moved into its category folder. The folder is moved back to temp so the job
+// can be retried; if that fails too, indexing is retried once so the asset is
+// not left orphaned in its category folder without an index entry.
+func (s *ImageService) rollback3DMove(imageID string, image *models.Image, cause error) error {
+	restoreErr := s.storageService.Restore3DToTemp(imageID, image.FolderPath)
+	if restoreErr == nil {
+		s.logger.Warnf("Rolled back 3D object %s to temp after index failure", imageID)
+		return fmt.Errorf("failed to append to index: %w", cause)
+	}
+
+	s.logger.Errorf("Failed to roll back 3D object %s: %v; forcing index entry", imageID, restoreErr)
+	if err := s.indexService.AppendToIndex(image); err != nil {
+		return fmt.Errorf("failed to append to index (rollback failed: %v): %w", restoreErr, err)
+	}
+
+	s.statusStore.Set(image)
+	s.notifyIndexed(image)
 
 	return nil
 }
 
+// timedStage runs a stage with runStage and records its duration in the
+// job's timeline, whether or not it succeeded
+func timedStage(ctx context.Context, job *models.UploadJob, stage string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	err := runStage(ctx, stage, timeout, fn)
+	job.RecordStage(stage, start)
+	return err
+}
+
+// runStage runs fn with a stage-scoped timeout derived from the job context.
+// fn runs on the caller's goroutine, so a stage never writes to the job's
+// files after the worker moved on (e.g. to CleanupTemp); it has to watch its
+// context to stop in time. A stage that fails with the deadline reports the
+// timeout, and one that succeeds late still succeeds.
+func runStage(ctx context.Context, name string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	var stageCtx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		stageCtx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		stageCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	err := fn(stageCtx)
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case errors.Is(err, context.DeadlineExceeded) && stageCtx.Err() != nil:
+		return fmt.Errorf("%s stage timed out after %s: %w", name, timeout, err)
+	}
+	return err
+}
+
 // updateStatus updates the status of an image
 func (s *ImageService) updateStatus(imageID, status string) {
-	s.statusMutex.Lock()
-	defer s.statusMutex.Unlock()
-
-	if img, ok := s.statusMap[imageID]; ok {
+	s.statusStore.Update(imageID, func(img *models.Image) {
 		img.Status = status
 		if status == "completed" {
 			now := time.Now()
 			img.ProcessedAt = &now
 		}
-	}
+	})
 }
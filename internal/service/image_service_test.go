@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestRunStage_Success(t *testing.T) {
+	err := runStage(context.Background(), "test", time.Second, func(ctx context.Context) error {
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+}
+
+func TestRunStage_Timeout(t *testing.T) {
+	var exited atomic.Bool
+	err := runStage(context.Background(), "analysis", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond) // finishing a write, say
+		exited.Store(true)
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "analysis stage timed out") {
+		t.Errorf("error should name the stage, got %v", err)
+	}
+	if !exited.Load() {
+		t.Error("expected runStage to wait for the stage to return")
+	}
+}
+
+func TestCancelJob_AfterCommit(t *testing.T) {
+	svc := NewImageService(nil, nil, nil, nil, logrus.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc.inflight["img-1"] = &inflightJob{cancel: cancel}
+
+	if err := svc.commitJob(ctx, "img-1"); err != nil {
+		t.Fatalf("commitJob failed: %v", err)
+	}
+	if err := svc.CancelJob("img-1"); !errors.Is(err, ErrJobNotCancellable) {
+		t.Fatalf("expected ErrJobNotCancellable, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("expected the committed job to keep running")
+	}
+
+	// Cancelled before the commit point, the job stops there
+	ctx, cancel = context.WithCancel(context.Background())
+	svc.inflight["img-2"] = &inflightJob{cancel: cancel}
+	if err := svc.CancelJob("img-2"); err != nil {
+		t.Fatalf("CancelJob failed: %v", err)
+	}
+	if err := svc.commitJob(ctx, "img-2"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled at the commit point, got %v", err)
+	}
+}
+
+func TestRunStage_LateSuccess(t *testing.T) {
+	err := runStage(context.Background(), "thumbnail", 10*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(20 * time.Millisecond) // a step that can't be interrupted
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expected a stage that succeeded to succeed, got %v", err)
+	}
+}
+
+func TestRunStage_JobCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := runStage(ctx, "thumbnail", time.Second, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTimedStage_RecordsTimeline(t *testing.T) {
+	job := &models.UploadJob{ImageID: "img-1"}
+	job.RecordStage(models.StageQueue, time.Now().Add(-50*time.Millisecond))
+
+	err := timedStage(context.Background(), job, models.StageAnalysis, time.Second, func(ctx context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return errors.New("quota exceeded")
+	})
+	if err == nil {
+		t.Fatal("expected the stage error")
+	}
+
+	// Failed stages are timed too
+	if len(job.Timeline) != 2 {
+		t.Fatalf("expected 2 stages, got %+v", job.Timeline)
+	}
+	if stage := job.Timeline[0]; stage.Stage != models.StageQueue || stage.DurationMs < 50 {
+		t.Errorf("unexpected queue stage %+v", stage)
+	}
+	if stage := job.Timeline[1]; stage.Stage != models.StageAnalysis || stage.DurationMs < 20 || stage.StartedAt.IsZero() {
+		t.Errorf("unexpected analysis stage %+v", stage)
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ImageChange is an image's metadata before and after an update
+type ImageChange struct {
+	Before *ImageMetadata
+	After  *ImageMetadata
+}
+
+// batchFile is an index file rewritten by a batch update
+type batchFile struct {
+	path    string
+	content string
+	changes []ImageChange
+	entries []JournalEntry
+	before  []string // sections the entries replace
+}
+
+// UpdateImages applies one update to many images in a single
+// read-modify-write of the index: each index file involved is read and
+// replaced once under its lock, and the changes are journaled as one
+// batch. Images the update can't apply to (missing, deleted or rejecting it)
+// are returned in skipped with the reason and left unchanged. If writing a
+// file fails, the changes of the files already written are returned with
+// the error.
+func (s *IndexService) UpdateImages(imageIDs []string, update ImageUpdate) ([]ImageChange, map[string]error, error) {
+	paths, err := s.filesOf(imageIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	var locked []string
+	for _, path := range paths {
+		if !slices.Contains(locked, path) {
+			locked = append(locked, path)
+		}
+	}
+	unlock, err := s.lockFiles(locked...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	skipped := make(map[string]error)
+	files := make(map[string]*batchFile)
+	var order []*batchFile
+	seen := make(map[string]bool)
+	for _, id := range imageIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		path, ok := paths[id]
+		if !ok {
+			skipped[id] = fmt.Errorf("%w: %s", ErrImageNotFound, id)
+			continue
+		}
+		file := files[path]
+		if file == nil {
+			content, err := readIndexFile(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			file = &batchFile{path: path, content: content}
+			files[path] = file
+			order = append(order, file)
+		}
+
+		start, end, found := findImageSection(file.content, id)
+		if !found {
+			skipped[id] = fmt.Errorf("%w: %s", ErrImageNotFound, id)
+			continue
+		}
+		section := file.content[start:end]
+		replacement, err := applyImageUpdate(id, section, 0, update)
+		if err != nil {
+			skipped[id] = err
+			continue
+		}
+		file.content = file.content[:start] + replacement + file.content[end:]
+		file.changes = append(file.changes, ImageChange{Before: parseImageSection(id, section), After: parseImageSection(id, replacement)})
+		file.entries = append(file.entries, JournalEntry{Op: JournalUpdate, ImageID: id, Section: replacement})
+		file.before = append(file.before, section)
+	}
+
+	// Journaled file by file, so a failed write discards only the entries of
+	// the files not written
+	var entries []JournalEntry
+	var before []string
+	for _, file := range order {
+		entries = append(entries, file.entries...)
+		before = append(before, file.before...)
+	}
+	if len(entries) == 0 {
+		return nil, skipped, nil
+	}
+	journaled, err := s.appendJournal(true, entries...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to journal batch of %d updates: %w", len(entries), err)
+	}
+
+	var changes []ImageChange
+	for _, file := range order {
+		if len(file.changes) == 0 {
+			continue
+		}
+		if err := s.writeIndex(file.path, file.content); err != nil {
+			if journaled != nil {
+				s.undoJournal(journaled[len(changes):], before[len(changes):])
+			}
+			return changes, skipped, err
+		}
+		changes = append(changes, file.changes...)
+	}
+	return changes, skipped, nil
+}
+
+// filesOf returns the file holding each of the images, as recorded in a
+// sharded index's manifest. Images no file holds are left out.
+func (s *IndexService) filesOf(imageIDs []string) (map[string]string, error) {
+	paths := make(map[string]string, len(imageIDs))
+	for _, id := range imageIDs {
+		path, err := s.fileOf(id)
+		if errors.Is(err, ErrImageNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		paths[id] = path
+	}
+	return paths, nil
+}
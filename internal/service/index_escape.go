@@ -0,0 +1,185 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// escapeIndexValue makes a free-text value safe to write on one index line.
+// Line breaks and tabs become spaces and other control characters are
+// dropped, so a value can't start a new field or image section; '*' and '\'
+// are backslash-escaped, so "**" in a value can't be mistaken for a field
+// name. parseFieldLine undoes the escaping.
+func escapeIndexValue(value string) string {
+	var sb strings.Builder
+	sb.Grow(len(value))
+	for _, r := range value {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t' || r == '\u0085' || r == '\u2028' || r == '\u2029':
+			sb.WriteByte(' ')
+		case unicode.IsControl(r):
+		case r == '*' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// unescapeIndexValue reverses escapeIndexValue. Backslashes not followed by
+// '*' or '\' are kept, so entries written before escaping read unchanged.
+func unescapeIndexValue(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var sb strings.Builder
+	sb.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) && (value[i+1] == '*' || value[i+1] == '\\') {
+			i++
+		}
+		sb.WriteByte(value[i])
+	}
+	return sb.String()
+}
+
+// listItemEscaper backslash-escapes the separators of list fields, ", "
+// between items and "; " between Extra Fields, and the backslash itself
+var listItemEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`)
+
+// escapeListItem escapes a list item so its commas and semicolons can't
+// split it. It applies before escapeIndexValue, which escapes the whole line;
+// splitIndexList undoes it.
+func escapeListItem(item string) string {
+	return listItemEscaper.Replace(item)
+}
+
+// joinIndexList joins the items of a list field, escaping each with
+// escapeListItem
+func joinIndexList(items []string, sep string) string {
+	escaped := make([]string, len(items))
+	for i, item := range items {
+		escaped[i] = escapeListItem(item)
+	}
+	return strings.Join(escaped, sep)
+}
+
+// splitIndexList splits an unescaped list field at the separators that are
+// not backslash-escaped and unescapes the items. Backslashes not followed by
+// '\', ',' or ';' are kept, so lists written before escaping read unchanged.
+func splitIndexList(value, sep string) []string {
+	var items []string
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && strings.IndexByte(`\,;`, value[i+1]) >= 0:
+			i++
+			sb.WriteByte(value[i])
+		case strings.HasPrefix(value[i:], sep):
+			items = append(items, sb.String())
+			sb.Reset()
+			i += len(sep) - 1
+		default:
+			sb.WriteByte(value[i])
+		}
+	}
+	return append(items, sb.String())
+}
+
+// escapeIndexValues escapes each value of a list field, the item with
+// escapeListItem and then for the index line
+func escapeIndexValues(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = escapeIndexValue(escapeListItem(v))
+	}
+	return out
+}
+
+// escapedForIndex returns a copy of img with every free-text field (user
+// input, AI output and metadata read from files) escaped for the index.
+// Paths and generated values are left as they are.
+func escapedForIndex(img *models.Image) *models.Image {
+	c := *img
+	c.Title = escapeIndexValue(img.Title)
+	c.Artist = escapeIndexValue(img.Artist)
+	c.Category = escapeIndexValue(img.Category)
+	c.ExternalID = escapeIndexValue(img.ExternalID)
+	c.Project = escapeIndexValue(img.Project)
+	c.OriginalFilename = escapeIndexValue(img.OriginalFilename)
+	c.MimeType = escapeIndexValue(img.MimeType)
+	c.ModelFilename = escapeIndexValue(img.ModelFilename)
+	c.ColorSpace = escapeIndexValue(img.ColorSpace)
+	c.ManualTags = escapeIndexValues(img.ManualTags)
+	c.LODTags = escapeIndexValues(img.LODTags)
+
+	if img.License != nil {
+		c.License = &models.License{
+			Type:         escapeIndexValue(img.License.Type),
+			RightsHolder: escapeIndexValue(img.License.RightsHolder),
+			ExpiresOn:    escapeIndexValue(img.License.ExpiresOn),
+			Restrictions: escapeIndexValue(img.License.Restrictions),
+		}
+	}
+	if d := img.DesignSource; d != nil {
+		c.DesignSource = &models.DesignSource{
+			Tool:         escapeIndexValue(d.Tool),
+			DocumentID:   escapeIndexValue(d.DocumentID),
+			DocumentName: escapeIndexValue(d.DocumentName),
+			NodeID:       escapeIndexValue(d.NodeID),
+			NodeName:     escapeIndexValue(d.NodeName),
+			Page:         escapeIndexValue(d.Page),
+			Version:      escapeIndexValue(d.Version),
+			URL:          escapeIndexValue(d.URL),
+		}
+	}
+	if img.Attributes != nil {
+		c.Attributes = make(map[string]string, len(img.Attributes))
+		for name, value := range img.Attributes {
+			c.Attributes[name] = escapeIndexValue(value)
+		}
+	}
+
+	if ai := img.AIAnalysis; ai != nil {
+		escaped := *ai
+		escaped.PrimaryCategory = escapeIndexValue(ai.PrimaryCategory)
+		escaped.SubCategory = escapeIndexValue(ai.SubCategory)
+		escaped.Description = escapeIndexValue(ai.Description)
+		escaped.Objects = escapeIndexValues(ai.Objects)
+		escaped.Colors = escapeIndexValues(ai.Colors)
+		escaped.SceneType = escapeIndexValue(ai.SceneType)
+		escaped.Mood = escapeIndexValue(ai.Mood)
+		escaped.Style = escapeIndexValue(ai.Style)
+		escaped.Lighting = escapeIndexValue(ai.Lighting)
+		escaped.ThreeDCharacteristics = escapeIndexValue(ai.ThreeDCharacteristics)
+		escaped.Provider = escapeIndexValue(ai.Provider)
+		escaped.Model = escapeIndexValue(ai.Model)
+		escaped.PromptVersion = escapeIndexValue(ai.PromptVersion)
+		if ai.Features != nil {
+			escaped.Features = make([]models.Feature, len(ai.Features))
+			for i, f := range ai.Features {
+				escaped.Features[i] = models.Feature{Name: escapeIndexValue(escapeListItem(f.Name)), Confidence: f.Confidence}
+			}
+		}
+		if ai.Aesthetics != nil {
+			aesthetics := *ai.Aesthetics
+			aesthetics.Critique = escapeIndexValue(ai.Aesthetics.Critique)
+			escaped.Aesthetics = &aesthetics
+		}
+		if ai.Extra != nil {
+			escaped.Extra = make(map[string]string, len(ai.Extra))
+			for name, value := range ai.Extra {
+				escaped.Extra[escapeIndexValue(escapeListItem(name))] = escapeIndexValue(escapeListItem(value))
+			}
+		}
+		c.AIAnalysis = &escaped
+	}
+	return &c
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// adversarialValues are values that would corrupt the index if written raw
+var adversarialValues = []string{
+	"**Category:** hacked",
+	"Sunset ** at ** the pier",
+	"a:** b",
+	`C:\photos\*.jpg`,
+	`trailing backslash \`,
+	"日本の風景 — Ünïcödé ✓",
+	"emoji 🐈‍⬛ and zero\u200bwidth",
+	"red, white, blue",
+	"key: value; other: value",
+	`escaped\, comma`,
+}
+
+func TestIndexEscape_RoundTrip(t *testing.T) {
+	for _, value := range adversarialValues {
+		escaped := escapeIndexValue(value)
+		if strings.Contains(escaped, "**") {
+			t.Errorf("escaped %q still contains **: %q", value, escaped)
+		}
+		if got := unescapeIndexValue(escaped); got != value {
+			t.Errorf("round trip of %q gave %q", value, got)
+		}
+	}
+
+	// Line breaks can't start a new line; other control characters are dropped
+	if got := escapeIndexValue("one\ntwo\r\nthree\u2028four\x00\x1b[31m"); got != "one two  three four[31m" {
+		t.Errorf("unexpected escaping of control characters: %q", got)
+	}
+	// Entries written before escaping read unchanged
+	if got := unescapeIndexValue(`C:\photos\new`); got != `C:\photos\new` {
+		t.Errorf("expected unescaped backslashes to be kept, got %q", got)
+	}
+}
+
+func TestAppendToIndex_AdversarialFields(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	injected := "Evil\n\n## Image: forged\n\n**Category:** hacked"
+	img := &models.Image{
+		ID: "img-1", Title: injected, Artist: "**Artist:** someone", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		FilePath: "categories/animals/img-1.jpg", ThumbnailPath: "categories/animals/img-1_thumb.jpg",
+		ManualTags: []string{"**bold**", `back\slash`},
+		Attributes: map[string]string{"client": "**Title:** other"},
+		License:    &models.License{Type: "cc-by-4.0", Restrictions: "no\n**Visibility:** private"},
+		AIAnalysis: &models.AIAnalysis{Description: "A *very* good cat\n- **Primary Category:** hacked", PrimaryCategory: "animals"},
+	}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	images, err := svc.GetAllImages()
+	if err != nil {
+		t.Fatalf("GetAllImages failed: %v", err)
+	}
+	if len(images) != 1 {
+		t.Fatalf("expected 1 image, got %d", len(images))
+	}
+	got := images[0]
+	if got.Title != "Evil  ## Image: forged  **Category:** hacked" || got.Category != "animals" {
+		t.Errorf("title injected fields: title %q, category %q", got.Title, got.Category)
+	}
+	if got.Artist != "**Artist:** someone" {
+		t.Errorf("unexpected artist %q", got.Artist)
+	}
+	if strings.Join(got.Tags, "|") != `**bold**|back\slash` {
+		t.Errorf("unexpected tags %q", got.Tags)
+	}
+	if got.Attributes["client"] != "**Title:** other" {
+		t.Errorf("unexpected attribute %q", got.Attributes["client"])
+	}
+	if got.Visibility != models.VisibilityPublic || got.License.Restrictions != "no **Visibility:** private" {
+		t.Errorf("license injected visibility %q: %+v", got.Visibility, got.License)
+	}
+	if got.Description != "A *very* good cat - **Primary Category:** hacked" {
+		t.Errorf("unexpected description %q", got.Description)
+	}
+
+	// Updates go through the same escaping, and don't match fields named in
+	// other values
+	for _, value := range adversarialValues {
+		title := value
+		updated, err := svc.UpdateImage("img-1", 0, ImageUpdate{Title: &title})
+		if err != nil {
+			t.Fatalf("UpdateImage failed: %v", err)
+		}
+		if updated.Title != value || updated.Category != "animals" {
+			t.Errorf("update to %q gave title %q, category %q", value, updated.Title, updated.Category)
+		}
+	}
+}
+
+func TestAppendToIndex_ListSeparatorsInValues(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	features := make([]models.Feature, len(adversarialValues))
+	for i, value := range adversarialValues {
+		features[i] = models.Feature{Name: value, Confidence: 0.5}
+	}
+	img := &models.Image{
+		ID: "img-1", Title: "T", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		ManualTags: adversarialValues,
+		AIAnalysis: &models.AIAnalysis{Description: "d", PrimaryCategory: "animals", Objects: adversarialValues, Features: features},
+	}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	got, err := svc.GetImageByID("img-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	want := strings.Join(adversarialValues, "|")
+	if strings.Join(got.Tags, "|") != want {
+		t.Errorf("tags split apart: %q", got.Tags)
+	}
+	if strings.Join(got.Objects, "|") != want {
+		t.Errorf("objects split apart: %q", got.Objects)
+	}
+	if len(got.Features) != len(features) || got.Features[7].Name != "red, white, blue" || got.Features[7].Confidence != 0.5 {
+		t.Errorf("features split apart: %+v", got.Features)
+	}
+
+	// Updates escape the same way
+	tags := []string{"a, b", "c; d", `e\`}
+	if _, err := svc.UpdateImage("img-1", 0, ImageUpdate{Tags: &tags}); err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	got, _ = svc.GetImageByID("img-1")
+	if strings.Join(got.Tags, "|") != strings.Join(tags, "|") {
+		t.Errorf("updated tags split apart: %q", got.Tags)
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// originalFiles maps the data-relative paths of original files (2D files, 3D
+// models and views) to their image, along with every other file the index
+// accounts for. It is rebuilt when the index changes.
+type originalFiles struct {
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	owners  map[string]string // originals
+	files   map[string]string // originals and their renditions
+	folders map[string]string // 3D object folders; all their files belong to the object
+}
+
+// ImageForOriginal returns the ID of the image whose original file is at the
+// data-relative path, so file requests can be attributed to images.
+// Thumbnails and other renditions are not originals.
+func (s *IndexService) ImageForOriginal(path string) (string, bool) {
+	if !s.loadFiles() {
+		return "", false
+	}
+	defer s.originals.mutex.Unlock()
+
+	id, ok := s.originals.owners[normalizePath(path)]
+	return id, ok
+}
+
+// ImageForFile returns the ID of the image a data-relative path belongs to:
+// an original, a rendition derived from it, or any file in a 3D object's
+// folder (materials, clip). Paths the index doesn't account for (the index
+// itself, metadata stores, temp uploads) belong to no image.
+func (s *IndexService) ImageForFile(file string) (string, bool) {
+	if !s.loadFiles() {
+		return "", false
+	}
+	defer s.originals.mutex.Unlock()
+
+	file = normalizePath(file)
+	if id, ok := s.originals.files[file]; ok {
+		return id, true
+	}
+	for dir := path.Dir(file); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if id, ok := s.originals.folders[dir]; ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// loadFiles locks the file maps, rebuilding them if the index changed. On
+// success the caller must unlock s.originals.mutex.
+func (s *IndexService) loadFiles() bool {
+	modTime, size, err := s.indexStamp()
+	if err != nil {
+		return false
+	}
+
+	s.originals.mutex.Lock()
+	if s.originals.owners != nil && modTime.Equal(s.originals.modTime) && size == s.originals.size {
+		return true
+	}
+
+	images, err := s.GetAllImages()
+	if err != nil {
+		s.originals.mutex.Unlock()
+		return false
+	}
+	owners := make(map[string]string, len(images))
+	files := make(map[string]string, 4*len(images))
+	folders := make(map[string]string)
+	for _, img := range images {
+		originals := []string{img.FilePath, img.ModelFilePath}
+		for _, view := range img.Views {
+			originals = append(originals, view)
+		}
+		for _, file := range originals {
+			if file != "" {
+				owners[file] = img.ID
+				files[file] = img.ID
+			}
+		}
+		for _, file := range renditionFiles(img) {
+			files[file] = img.ID
+		}
+		if img.FolderPath != "" {
+			folders[strings.TrimSuffix(img.FolderPath, "/")] = img.ID
+		}
+	}
+	s.originals.owners = owners
+	s.originals.files = files
+	s.originals.folders = folders
+	s.originals.modTime = modTime
+	s.originals.size = size
+	return true
+}
+
+// renditionFiles returns the data-relative paths of the derivatives an image
+// may have, whether or not they exist yet
+func renditionFiles(img *ImageMetadata) []string {
+	var files []string
+	for _, file := range []string{img.ThumbnailPath, img.SquareThumbnail, img.TurntablePath, img.ClipPath} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	if img.FilePath != "" {
+		files = append(files, squareThumbnailPath(img.FilePath), cutoutPath(img.FilePath),
+			upscalePath(img.FilePath, 2), upscalePath(img.FilePath, 4), xmpSidecarPath(img.FilePath))
+	}
+	for _, file := range img.Upscales {
+		files = append(files, file)
+	}
+	for _, view := range img.Views {
+		files = append(files, path.Join(path.Dir(view), viewBaseName(view)+"_thumb.jpg"), cutoutPath(view))
+	}
+	return files
+}
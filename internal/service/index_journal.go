@@ -0,0 +1,378 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Journal operations
+const (
+	JournalAppend = "append"
+	JournalUpdate = "update"
+	JournalDelete = "delete"
+	// JournalRevert takes back an entry whose mutation failed after other
+	// entries were journaled. Section is the image's section before it,
+	// empty for an append.
+	JournalRevert = "revert"
+)
+
+// ErrStaleFence is returned when appending with a fencing token older than
+// the journal's latest, i.e. from a writer whose index lock expired and was
+// taken over
+var ErrStaleFence = errors.New("stale fencing token")
+
+// JournalEntry is one index mutation. Section is the image's index section
+// after the mutation (a tombstone for deletes), so an entry can be replayed
+// and replicas need nothing else.
+type JournalEntry struct {
+	Seq     int64     `json:"seq"`
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"`
+	ImageID string    `json:"image_id"`
+	Section string    `json:"section"`
+	Fence   int64     `json:"fence,omitempty"` // of the index lock it was written under
+	Batch   int64     `json:"batch,omitempty"` // seq of the first entry of a batch written together
+}
+
+// Image parses the entry's section into image metadata
+func (e JournalEntry) Image() *ImageMetadata {
+	return parseImageSection(e.ImageID, e.Section)
+}
+
+// IndexJournal is an append-only log of index mutations, one JSON line per
+// entry, numbered from 1. The index service writes an entry, synced to disk,
+// before changing the index.
+type IndexJournal struct {
+	path    string
+	offsets []int64 // file offset of entry seq at offsets[seq-1]
+	size    int64
+	fence   int64 // largest fencing token in the journal
+	mutex   sync.Mutex
+}
+
+func NewIndexJournal(path string) *IndexJournal {
+	return &IndexJournal{path: path}
+}
+
+// Load indexes the entries of an existing journal. A torn last line (from a
+// crash mid-write) is truncated away.
+func (j *IndexJournal) Load() error {
+	file, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.offsets = nil
+	var offset int64
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read journal: %w", err)
+		}
+		var entry JournalEntry
+		if json.Unmarshal(line, &entry) != nil || entry.Seq != int64(len(j.offsets)+1) {
+			break
+		}
+		j.fence = max(j.fence, entry.Fence)
+		j.offsets = append(j.offsets, offset)
+		offset += int64(len(line))
+	}
+
+	j.size = offset
+	if info, err := file.Stat(); err == nil && info.Size() > offset {
+		if err := os.Truncate(j.path, offset); err != nil {
+			return fmt.Errorf("failed to truncate journal: %w", err)
+		}
+	}
+	return nil
+}
+
+// catchUp indexes entries appended since the last read by other server
+// instances sharing the journal. A journal that shrank (an entry discarded
+// elsewhere) is indexed again from the start. Caller must hold the write
+// lock.
+func (j *IndexJournal) catchUp() error {
+	info, err := os.Stat(j.path)
+	if os.IsNotExist(err) || (err == nil && info.Size() < j.size) {
+		j.offsets, j.size = nil, 0
+	}
+	if err != nil || info.Size() == j.size {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	file, err := os.Open(j.path)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(j.size, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek journal: %w", err)
+	}
+
+	// A line without its newline is still being written
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return nil
+		}
+		var entry JournalEntry
+		if json.Unmarshal(line, &entry) != nil || entry.Seq != int64(len(j.offsets)+1) {
+			return nil
+		}
+		j.fence = max(j.fence, entry.Fence)
+		j.offsets = append(j.offsets, j.size)
+		j.size += int64(len(line))
+	}
+}
+
+// LastSeq returns the sequence number of the latest entry (0 if none)
+func (j *IndexJournal) LastSeq() int64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.catchUp()
+	return int64(len(j.offsets))
+}
+
+// Append records a mutation and syncs it to disk
+func (j *IndexJournal) Append(op, imageID, section string) (JournalEntry, error) {
+	return j.AppendFenced(0, op, imageID, section)
+}
+
+// AppendFenced records a mutation made under an index lock with fencing
+// token fence, or returns ErrStaleFence if a later holder of the lock has
+// already written. A zero fence (a flock) is not checked.
+func (j *IndexJournal) AppendFenced(fence int64, op, imageID, section string) (JournalEntry, error) {
+	entries, err := j.append(fence, []JournalEntry{{Op: op, ImageID: imageID, Section: section}}, false)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	return entries[0], nil
+}
+
+// AppendBatch records mutations applied to the index together, with one
+// sync. Each entry's Batch is the seq of the first, so recovery replays them
+// as one. Op, ImageID and Section are taken from entries.
+func (j *IndexJournal) AppendBatch(fence int64, entries []JournalEntry) ([]JournalEntry, error) {
+	return j.append(fence, entries, true)
+}
+
+func (j *IndexJournal) append(fence int64, entries []JournalEntry, batch bool) ([]JournalEntry, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if err := j.catchUp(); err != nil {
+		return nil, err
+	}
+	if fence != 0 && fence < j.fence {
+		return nil, ErrStaleFence
+	}
+
+	now := time.Now()
+	first := int64(len(j.offsets) + 1)
+	written := make([]JournalEntry, len(entries))
+	offsets := make([]int64, len(entries))
+	var buf bytes.Buffer
+	for i, entry := range entries {
+		entry.Seq = first + int64(i)
+		entry.Time = now
+		entry.Fence = fence
+		if batch {
+			entry.Batch = first
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode journal entry: %w", err)
+		}
+		written[i] = entry
+		offsets[i] = j.size + int64(buf.Len())
+		buf.Write(append(line, '\n'))
+	}
+
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		// Drop a partial line so later entries stay readable
+		file.Truncate(j.size)
+		return nil, fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Truncate(j.size)
+		return nil, fmt.Errorf("failed to sync journal: %w", err)
+	}
+
+	j.offsets = append(j.offsets, offsets...)
+	j.size += int64(buf.Len())
+	j.fence = max(j.fence, fence)
+	return written, nil
+}
+
+// Fence returns the largest fencing token written to the journal, by any
+// instance
+func (j *IndexJournal) Fence() int64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.catchUp()
+	return j.fence
+}
+
+// Discard removes entry seq if it is still the latest, for a mutation that
+// failed before reaching the index
+func (j *IndexJournal) Discard(seq int64) error {
+	return j.DiscardFrom(seq, seq)
+}
+
+// DiscardFrom removes entries seq to last if last is still the latest, for
+// the tail of a batch that failed before reaching the index
+func (j *IndexJournal) DiscardFrom(seq, last int64) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if last != int64(len(j.offsets)) || seq < 1 || seq > last {
+		return fmt.Errorf("journal entry %d is not the latest", last)
+	}
+	offset := j.offsets[seq-1]
+	if err := os.Truncate(j.path, offset); err != nil {
+		return fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	j.offsets = j.offsets[:seq-1]
+	j.size = offset
+	return nil
+}
+
+// Last returns the latest entry, if any
+func (j *IndexJournal) Last() (*JournalEntry, error) {
+	seq := j.LastSeq()
+	if seq == 0 {
+		return nil, nil
+	}
+	entries, err := j.Since(seq-1, 1)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// Since returns up to limit entries after seq, oldest first
+func (j *IndexJournal) Since(seq int64, limit int) ([]JournalEntry, error) {
+	j.mutex.Lock()
+	j.catchUp()
+	if seq < 0 {
+		seq = 0
+	}
+	if seq >= int64(len(j.offsets)) || limit <= 0 {
+		j.mutex.Unlock()
+		return nil, nil
+	}
+	start := j.offsets[seq]
+	count := min(limit, len(j.offsets)-int(seq))
+	j.mutex.Unlock()
+
+	file, err := os.Open(j.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek journal: %w", err)
+	}
+
+	entries := make([]JournalEntry, 0, count)
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for len(entries) < count {
+		var entry JournalEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("failed to read journal: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Redact rewrites the entries of images so each carries the section of the
+// image's latest entry (its tombstone, once deleted), leaving no earlier
+// metadata of them in the journal. Sequence numbers are kept. It returns how
+// many entries changed.
+func (j *IndexJournal) Redact(imageIDs map[string]bool) (int, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if err := j.catchUp(); err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	entries := make([]JournalEntry, 0, len(j.offsets))
+	latest := make(map[string]string)
+	decoder := json.NewDecoder(bytes.NewReader(data[:j.size]))
+	for range j.offsets {
+		var entry JournalEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return 0, fmt.Errorf("failed to read journal: %w", err)
+		}
+		if imageIDs[entry.ImageID] {
+			latest[entry.ImageID] = entry.Section
+		}
+		entries = append(entries, entry)
+	}
+
+	redacted := 0
+	var buf bytes.Buffer
+	offsets := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		if section, ok := latest[entry.ImageID]; ok && entry.Section != section {
+			entry.Section = section
+			redacted++
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode journal entry: %w", err)
+		}
+		offsets = append(offsets, int64(buf.Len()))
+		buf.Write(append(line, '\n'))
+	}
+	if redacted == 0 {
+		return 0, nil
+	}
+
+	tmpPath := j.path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return 0, fmt.Errorf("failed to replace journal: %w", err)
+	}
+	j.offsets = offsets
+	j.size = int64(buf.Len())
+	return redacted, nil
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func newJournaledIndex(t *testing.T) (*IndexService, *IndexJournal) {
+	t.Helper()
+	dir := t.TempDir()
+	svc := NewIndexService(dir)
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	journal := NewIndexJournal(filepath.Join(dir, "journal.jsonl"))
+	if err := journal.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	svc.SetJournal(journal)
+	return svc, journal
+}
+
+func TestIndexJournal_RecordsMutations(t *testing.T) {
+	svc, journal := newJournaledIndex(t)
+
+	img := &models.Image{ID: "img-1", Title: "Old", Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	title := "New"
+	if _, err := svc.UpdateImage("img-1", 1, ImageUpdate{Title: &title}); err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	// A rejected update never reaches the journal
+	if _, err := svc.UpdateImage("img-1", 1, ImageUpdate{Title: &title}); err == nil {
+		t.Fatal("expected revision mismatch")
+	}
+	if _, err := svc.DeleteImage("img-1", "curator"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+
+	entries, err := journal.Since(0, 10)
+	if err != nil {
+		t.Fatalf("Since failed: %v", err)
+	}
+	ops := []string{JournalAppend, JournalUpdate, JournalDelete}
+	if len(entries) != len(ops) {
+		t.Fatalf("expected %d entries, got %d", len(ops), len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Seq != int64(i+1) || entry.Op != ops[i] || entry.ImageID != "img-1" {
+			t.Errorf("entry %d: unexpected %+v", i, entry)
+		}
+	}
+	if got := entries[1].Image(); got.Title != "New" || got.Revision != 2 {
+		t.Errorf("update entry should carry the new state, got %+v", got)
+	}
+	if !entries[2].Image().Deleted {
+		t.Error("delete entry should carry the tombstone")
+	}
+
+	page, _ := journal.Since(1, 1)
+	if len(page) != 1 || page[0].Seq != 2 {
+		t.Errorf("expected only entry 2, got %+v", page)
+	}
+	if rest, _ := journal.Since(3, 10); len(rest) != 0 {
+		t.Errorf("expected nothing after the latest entry, got %d", len(rest))
+	}
+
+	reloaded := NewIndexJournal(journal.path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if reloaded.LastSeq() != 3 {
+		t.Errorf("expected last seq 3 after reload, got %d", reloaded.LastSeq())
+	}
+}
+
+func TestIndexJournal_TruncatesTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal := NewIndexJournal(path)
+	if _, err := journal.Append(JournalAppend, "img-1", "\n## Image: One\n"); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"seq":2,"op":"upd`)
+	f.Close()
+
+	reloaded := NewIndexJournal(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if reloaded.LastSeq() != 1 {
+		t.Fatalf("expected the torn entry to be dropped, got last seq %d", reloaded.LastSeq())
+	}
+	entry, err := reloaded.Append(JournalUpdate, "img-1", "\n## Image: Two\n")
+	if err != nil || entry.Seq != 2 {
+		t.Fatalf("expected seq 2 after truncation, got %d (%v)", entry.Seq, err)
+	}
+	if entries, err := reloaded.Since(0, 10); err != nil || len(entries) != 2 {
+		t.Errorf("expected 2 readable entries, got %d (%v)", len(entries), err)
+	}
+}
+
+func TestRecoverJournal_AppliesInterruptedMutation(t *testing.T) {
+	svc, journal := newJournaledIndex(t)
+
+	img := &models.Image{ID: "img-1", Title: "Old", Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	if recovered, err := svc.RecoverJournal(); err != nil || recovered {
+		t.Fatalf("nothing to recover, got %v (%v)", recovered, err)
+	}
+
+	// Simulate a crash between journaling an update and rewriting the index
+	content, _ := svc.ReadIndex()
+	start, end, _ := findImageSection(content, "img-1")
+	section := setField(content[start:end], "Title", "Recovered")
+	if _, err := journal.Append(JournalUpdate, "img-1", section); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	recovered, err := svc.RecoverJournal()
+	if err != nil || !recovered {
+		t.Fatalf("expected recovery, got %v (%v)", recovered, err)
+	}
+	stored, err := svc.GetImageByID("img-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if stored.Title != "Recovered" {
+		t.Errorf("expected the journaled title, got %q", stored.Title)
+	}
+	if recovered, _ := svc.RecoverJournal(); recovered {
+		t.Error("recovery should be idempotent")
+	}
+}
+
+func TestUpdateImages_OneBatch(t *testing.T) {
+	svc, journal := newJournaledIndex(t)
+	for _, id := range []string{"img-1", "img-2", "img-3"} {
+		img := &models.Image{ID: id, Title: id, Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()}
+		if err := svc.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+	before := journal.LastSeq()
+
+	artist := "Bob"
+	changes, skipped, err := svc.UpdateImages([]string{"img-1", "missing", "img-3", "img-1"}, ImageUpdate{Artist: &artist, AddTags: []string{"sale"}})
+	if err != nil {
+		t.Fatalf("UpdateImages failed: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Before.Artist != "Alice" || changes[0].After.Artist != "Bob" || changes[1].After.Revision != 2 {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+	if len(skipped) != 1 || !errors.Is(skipped["missing"], ErrImageNotFound) {
+		t.Errorf("expected the missing image to be skipped, got %v", skipped)
+	}
+	if stored, _ := svc.GetImageByID("img-2"); stored.Artist != "Alice" {
+		t.Errorf("img-2 should be untouched, got %q", stored.Artist)
+	}
+
+	entries, err := journal.Since(before, 10)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected 2 journal entries, got %d (%v)", len(entries), err)
+	}
+	for _, entry := range entries {
+		if entry.Batch != before+1 {
+			t.Errorf("expected entry %d in batch %d, got %d", entry.Seq, before+1, entry.Batch)
+		}
+	}
+}
+
+func TestRecoverJournal_AppliesInterruptedBatch(t *testing.T) {
+	svc, journal := newJournaledIndex(t)
+	for _, id := range []string{"img-1", "img-2"} {
+		img := &models.Image{ID: id, Title: id, Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()}
+		if err := svc.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	// Simulate a crash between journaling a batch and rewriting the index
+	content, _ := svc.ReadIndex()
+	var entries []JournalEntry
+	for _, id := range []string{"img-1", "img-2"} {
+		start, end, _ := findImageSection(content, id)
+		entries = append(entries, JournalEntry{Op: JournalUpdate, ImageID: id, Section: setField(content[start:end], "Artist", "Recovered")})
+	}
+	if _, err := journal.AppendBatch(0, entries); err != nil {
+		t.Fatalf("AppendBatch failed: %v", err)
+	}
+
+	recovered, err := svc.RecoverJournal()
+	if err != nil || !recovered {
+		t.Fatalf("expected recovery, got %v (%v)", recovered, err)
+	}
+	for _, id := range []string{"img-1", "img-2"} {
+		if stored, _ := svc.GetImageByID(id); stored.Artist != "Recovered" {
+			t.Errorf("expected %s to get the journaled artist, got %q", id, stored.Artist)
+		}
+	}
+	if recovered, _ := svc.RecoverJournal(); recovered {
+		t.Error("recovery should be idempotent")
+	}
+}
@@ -0,0 +1,411 @@
+package service
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// imageHeader starts the section of an image in the index
+const imageHeader = "## Image: "
+
+// maxIndexLine bounds a single index line (long AI descriptions) when
+// streaming the index
+const maxIndexLine = 16 * 1024 * 1024
+
+// scanIndex streams the index from r and calls fn with the metadata of each
+// image section in order, including tombstones.
+func scanIndex(r io.Reader, fn func(*ImageMetadata)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxIndexLine)
+
+	var parser *sectionParser
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) > len(imageHeader) && string(line[:len(imageHeader)]) == imageHeader {
+			if parser != nil {
+				fn(parser.finish())
+			}
+			parser = newSectionParser(string(line[len(imageHeader):]))
+			continue
+		}
+		// Only field and list lines carry data; skip blanks, rules and text
+		// without converting them
+		if parser == nil || len(line) < 3 || (line[0] != '*' && line[0] != '-') {
+			continue
+		}
+		parser.line(string(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if parser != nil {
+		fn(parser.finish())
+	}
+	return nil
+}
+
+// parseImageSection parses the markdown section of a single image
+func parseImageSection(imageID, section string) *ImageMetadata {
+	parser := newSectionParser(imageID)
+	for section != "" {
+		var line string
+		line, section, _ = strings.Cut(section, "\n")
+		parser.line(strings.TrimSuffix(line, "\r"))
+	}
+	return parser.finish()
+}
+
+// sectionParser builds the metadata of one image from its section, a line
+// at a time. Field lines are "**Name:** value", or "- **Name:** value" in the
+// AI analysis; the first occurrence of a field wins. Fields that need
+// parsing or defaults are kept raw until finish.
+type sectionParser struct {
+	img     *ImageMetadata
+	inViews bool
+
+	deleted, deletedBy             string
+	focalPoint, location, bounds   string
+	quality, legalHold             string
+	dimensions, megapixels, aspect string
+	revision, tags, objects        string
+	features                       string
+	aesthetics, critique           string
+	triangles, lodTags             string
+	materials, textures            string
+	modelInfo                      models.ModelInfo
+	license                        models.License
+	design                         models.DesignSource
+}
+
+func newSectionParser(imageID string) *sectionParser {
+	return &sectionParser{img: &ImageMetadata{ID: imageID}}
+}
+
+// line consumes one line of the section
+func (p *sectionParser) line(line string) {
+	if p.inViews {
+		if name, path, ok := parseViewLine(line); ok {
+			if _, seen := p.img.Views[name]; !seen {
+				p.img.Views[name] = normalizePath(path)
+			}
+			return
+		}
+		p.inViews = false
+	}
+
+	name, value, listItem, ok := parseFieldLine(line)
+	if !ok {
+		return
+	}
+
+	img := p.img
+	switch name {
+	case "Title":
+		setOnce(&img.Title, value)
+	case "Artist":
+		setOnce(&img.Artist, value)
+	case "Category":
+		setOnce(&img.Category, value)
+	case "Storage Layout":
+		setOnce(&img.StorageLayout, value)
+	case "Type":
+		setOnce(&img.Type, value)
+	case "External ID":
+		setOnce(&img.ExternalID, value)
+	case "Project":
+		setOnce(&img.Project, value)
+	case "Thumbnail":
+		setOnce(&img.ThumbnailPath, normalizePath(value))
+	case "Square Thumbnail":
+		setOnce(&img.SquareThumbnail, normalizePath(value))
+	case "File Path":
+		setOnce(&img.FilePath, normalizePath(value))
+	case "Original Filename":
+		setOnce(&img.OriginalFilename, value)
+	case "MIME Type":
+		setOnce(&img.MimeType, value)
+	case "Model File":
+		setOnce(&img.ModelFilePath, normalizePath(value))
+	case "Model Filename":
+		setOnce(&img.ModelFilename, value)
+	case "Model Format":
+		setOnce(&p.modelInfo.Format, value)
+	case "Model Version":
+		setOnce(&p.modelInfo.Version, value)
+	case "Up Axis":
+		setOnce(&p.modelInfo.UpAxis, value)
+	case "Folder Path":
+		setOnce(&img.FolderPath, normalizePath(value))
+	case "Turntable":
+		setOnce(&img.TurntablePath, normalizePath(value))
+	case "Poster View":
+		setOnce(&img.PosterView, value)
+	case "Clip":
+		setOnce(&img.ClipPath, normalizePath(value))
+	case "Color Space":
+		setOnce(&img.ColorSpace, value)
+	case "Quality":
+		setOnce(&p.quality, value)
+	case "Visual Hash":
+		setOnce(&img.VisualHash, value)
+	case "Description":
+		setOnce(&img.Description, value)
+	case "Uploaded":
+		setOnce(&img.UploadedAt, value)
+	case "Captured":
+		setOnce(&img.CapturedAt, value)
+	case "Visibility":
+		setOnce(&img.Visibility, value)
+	case "Workflow":
+		setOnce(&img.Workflow, value)
+	case "Legal Hold":
+		setOnce(&p.legalHold, value)
+	case "Upscale 2x", "Upscale 4x":
+		factor := strings.TrimPrefix(name, "Upscale ")
+		if _, seen := img.Upscales[factor]; !seen && value != "" {
+			if img.Upscales == nil {
+				img.Upscales = make(map[string]string)
+			}
+			img.Upscales[factor] = normalizePath(value)
+		}
+	case "License":
+		setOnce(&p.license.Type, value)
+	case "Rights Holder":
+		setOnce(&p.license.RightsHolder, value)
+	case "License Expires":
+		setOnce(&p.license.ExpiresOn, value)
+	case "Usage Restrictions":
+		setOnce(&p.license.Restrictions, value)
+	case "Design Tool":
+		setOnce(&p.design.Tool, value)
+	case "Design Document":
+		setOnce(&p.design.DocumentID, value)
+	case "Design Document Name":
+		setOnce(&p.design.DocumentName, value)
+	case "Design Node":
+		setOnce(&p.design.NodeID, value)
+	case "Design Node Name":
+		setOnce(&p.design.NodeName, value)
+	case "Design Page":
+		setOnce(&p.design.Page, value)
+	case "Design Version":
+		setOnce(&p.design.Version, value)
+	case "Design URL":
+		setOnce(&p.design.URL, value)
+	case "Deleted":
+		setOnce(&p.deleted, value)
+	case "Deleted By":
+		setOnce(&p.deletedBy, value)
+	case "Focal Point":
+		setOnce(&p.focalPoint, value)
+	case "Location":
+		setOnce(&p.location, value)
+	case "Bounds":
+		setOnce(&p.bounds, value)
+	case "Triangles":
+		setOnce(&p.triangles, value)
+	case "LOD Tags":
+		setOnce(&p.lodTags, value)
+	case "Materials":
+		setOnce(&p.materials, value)
+	case "Textures":
+		setOnce(&p.textures, value)
+	case "Dimensions":
+		setOnce(&p.dimensions, value)
+	case "Megapixels":
+		setOnce(&p.megapixels, value)
+	case "Aspect Ratio":
+		setOnce(&p.aspect, value)
+	case "Revision":
+		setOnce(&p.revision, value)
+	case "Manual Tags":
+		setOnce(&p.tags, value)
+	case "Objects Detected":
+		setOnce(&p.objects, value)
+	case "AI Features":
+		setOnce(&p.features, value)
+	case "Aesthetic Score":
+		setOnce(&p.aesthetics, value)
+	case "Critique":
+		setOnce(&p.critique, value)
+	case "AI Provider":
+		setOnce(&img.AIProvider, value)
+	case "AI Model":
+		setOnce(&img.AIModel, value)
+	case "Prompt Version":
+		setOnce(&img.PromptVersion, value)
+	case "Views":
+		if value == "" {
+			p.inViews = true
+			if img.Views == nil {
+				img.Views = make(map[string]string)
+			}
+		}
+	default:
+		if attr, found := strings.CutPrefix(name, "Attribute "); found && !listItem && value != "" && isFieldKey(attr, false) {
+			if img.Attributes == nil {
+				img.Attributes = make(map[string]string)
+			}
+			img.Attributes[attr] = value
+		}
+	}
+}
+
+// finish applies defaults and parses the raw fields
+func (p *sectionParser) finish() *ImageMetadata {
+	img := p.img
+
+	// Tombstones only carry the deletion record
+	if p.deleted != "" {
+		return &ImageMetadata{ID: img.ID, Deleted: true, DeletedAt: p.deleted, DeletedBy: p.deletedBy}
+	}
+
+	if _, sub, ok := strings.Cut(img.Category, "/"); ok {
+		img.SubCategory = sub
+	}
+	if focal, err := models.ParseFocalPoint(p.focalPoint); err == nil {
+		img.FocalPoint = focal
+	}
+	if location, err := models.ParseGeoPoint(p.location); err == nil {
+		img.Location = location
+	}
+	if bounds, err := models.ParseBounds(p.bounds); err == nil {
+		img.Bounds = bounds
+	}
+	if quality, err := models.ParseQuality(p.quality); err == nil {
+		img.Quality = quality
+	}
+	if p.modelInfo.Format != "" {
+		info := p.modelInfo
+		img.ModelInfo = &info
+	}
+	if p.materials != "" {
+		if materials, err := models.ParseMaterialSummary(p.materials, p.textures); err == nil {
+			img.Materials = materials
+		}
+	}
+	p.parseDimensions()
+
+	if img.Visibility == "" {
+		img.Visibility = models.VisibilityPublic
+	}
+	if img.Workflow == "" {
+		img.Workflow = models.WorkflowDraft
+	}
+	img.LegalHold = p.legalHold == "yes"
+	if !p.license.IsZero() {
+		license := p.license
+		img.License = &license
+	}
+	if !p.design.IsZero() {
+		design := p.design
+		img.DesignSource = &design
+	}
+
+	// Entries written before revisions were tracked are at revision 1
+	img.Revision = 1
+	if rev, err := strconv.Atoi(p.revision); err == nil && rev > 0 {
+		img.Revision = rev
+	}
+
+	if p.tags != "" {
+		img.Tags = splitIndexList(p.tags, ", ")
+	}
+	if p.objects != "" {
+		img.Objects = splitIndexList(p.objects, ", ")
+	}
+	if p.features != "" {
+		img.Features = parseFeatures(p.features)
+	}
+	if aesthetics, err := models.ParseAesthetics(p.aesthetics); err == nil {
+		aesthetics.Critique = p.critique
+		img.Aesthetics = aesthetics
+	}
+	if n, err := strconv.Atoi(p.triangles); err == nil && n > 0 {
+		img.Triangles = n
+	}
+	if p.lodTags != "" {
+		img.LODTags = splitIndexList(p.lodTags, ", ")
+	}
+
+	// Views only apply to 3D objects, which always have the map
+	if img.Type == "3D" {
+		if img.Views == nil {
+			img.Views = make(map[string]string)
+		}
+	} else {
+		img.Views = nil
+	}
+
+	return img
+}
+
+// parseDimensions reads the pixel size and its stats. Entries written before
+// the stats were stored get them derived from the dimensions.
+func (p *sectionParser) parseDimensions() {
+	img := p.img
+	w, h, ok := strings.Cut(p.dimensions, "x")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if !ok || errW != nil || errH != nil {
+		return
+	}
+	img.Width, img.Height = width, height
+
+	megapixels, errMP := strconv.ParseFloat(p.megapixels, 64)
+	aspectRatio, errAR := strconv.ParseFloat(p.aspect, 64)
+	if errMP != nil || errAR != nil {
+		megapixels, aspectRatio = models.DimensionStats(width, height)
+	}
+	img.Megapixels = megapixels
+	img.AspectRatio = aspectRatio
+}
+
+// parseFieldLine splits "**Name:** value" (optionally a "- " list item) into
+// its name and trimmed, unescaped value
+func parseFieldLine(line string) (name, value string, listItem, ok bool) {
+	if rest, found := strings.CutPrefix(line, "- "); found {
+		line, listItem = rest, true
+	}
+	rest, found := strings.CutPrefix(line, "**")
+	if !found {
+		return "", "", false, false
+	}
+	name, value, ok = strings.Cut(rest, ":**")
+	return name, unescapeIndexValue(strings.TrimSpace(value)), listItem, ok
+}
+
+// parseViewLine splits a "- name: path" line of the Views list
+func parseViewLine(line string) (name, path string, ok bool) {
+	rest, found := strings.CutPrefix(line, "- ")
+	if !found {
+		return "", "", false
+	}
+	name, path, ok = strings.Cut(rest, ": ")
+	if !ok || !isFieldKey(name, true) || strings.TrimSpace(path) == "" {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(path), true
+}
+
+// isFieldKey reports whether s is a non-empty view or attribute name:
+// lowercase letters, digits, '-' and '_', plus uppercase with upper
+func isFieldKey(s string, upper bool) bool {
+	for _, r := range s {
+		if !('a' <= r && r <= 'z') && !('0' <= r && r <= '9') && r != '-' && r != '_' && !(upper && 'A' <= r && r <= 'Z') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// setOnce sets dst to value unless an earlier line already set it
+func setOnce(dst *string, value string) {
+	if *dst == "" {
+		*dst = value
+	}
+}
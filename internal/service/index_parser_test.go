@@ -0,0 +1,215 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// writeBenchIndex writes an index of n analyzed 2D images and 3D objects
+func writeBenchIndex(tb testing.TB, n int) *IndexService {
+	tb.Helper()
+	dir := tb.TempDir()
+	svc := NewIndexService(dir)
+
+	var sb strings.Builder
+	sb.WriteString("# Image Warehouse Index\n")
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		img := &models.Image{
+			ID:         fmt.Sprintf("img-%06d", i),
+			Title:      fmt.Sprintf("Image %d", i),
+			Artist:     "Bencher",
+			Category:   "animals/cats",
+			UploadedAt: now,
+			Type:       models.ImageType2D,
+			ManualTags: []string{"cat", "night"},
+			Attributes: map[string]string{"client": "Acme"},
+			AIAnalysis: &models.AIAnalysis{
+				Description:     "A cat sitting on a windowsill at night",
+				PrimaryCategory: "animals",
+				SubCategory:     "cats",
+				Objects:         []string{"cat", "window"},
+				Colors:          []string{"black", "blue"},
+				Features:        []models.Feature{{Name: "cat", Confidence: 0.98}},
+			},
+		}
+		if i%10 == 0 {
+			img.Type = models.ImageType3D
+			img.FolderPath = "categories/animals/" + img.ID
+			img.Views = map[string]string{"front": img.FolderPath + "/front.png", "back": img.FolderPath + "/back.png"}
+		} else {
+			img.FilePath = "categories/animals/cats/" + img.ID + ".jpg"
+			img.ThumbnailPath = "thumbnails/" + img.ID + "_thumb.jpg"
+			img.Width, img.Height = 4000, 3000
+			img.Megapixels, img.AspectRatio = models.DimensionStats(4000, 3000)
+			img.FileSize = 3 << 20
+		}
+		sb.WriteString(svc.buildMarkdownEntry(img))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.md"), []byte(sb.String()), 0644); err != nil {
+		tb.Fatalf("failed to write index: %v", err)
+	}
+	return svc
+}
+
+func TestScanIndex_MatchesSectionParser(t *testing.T) {
+	svc := writeBenchIndex(t, 20)
+	if _, err := svc.DeleteImage("img-000003", "curator"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+
+	images, err := svc.GetImages(true)
+	if err != nil {
+		t.Fatalf("GetImages failed: %v", err)
+	}
+	if len(images) != 20 {
+		t.Fatalf("expected 20 entries, got %d", len(images))
+	}
+
+	content, _ := svc.ReadIndex()
+	for _, img := range images {
+		start, end, _ := findImageSection(content, img.ID)
+		want := parseImageSection(img.ID, content[start:end])
+		if !reflect.DeepEqual(img, want) {
+			t.Errorf("%s: streamed %+v, section parsed %+v", img.ID, img, want)
+		}
+	}
+
+	if !images[3].Deleted || images[3].DeletedBy != "curator" || images[3].Title != "" {
+		t.Errorf("unexpected tombstone %+v", images[3])
+	}
+	obj := images[0]
+	if obj.Type != "3D" || len(obj.Views) != 2 || obj.Views["front"] != "categories/animals/img-000000/front.png" {
+		t.Errorf("unexpected 3D entry %+v", obj)
+	}
+	photo := images[1]
+	if photo.Width != 4000 || photo.Megapixels != 12 || photo.SubCategory != "cats" || len(photo.Tags) != 2 ||
+		photo.Attributes["client"] != "Acme" || photo.Description != "A cat sitting on a windowsill at night" || photo.Views != nil {
+		t.Errorf("unexpected 2D entry %+v", photo)
+	}
+}
+
+func TestParseImageSection_Lines(t *testing.T) {
+	long := strings.Repeat("very ", 30000) + "long"
+	section := "## Image: img-1\r\n\r\n" +
+		"**Title:** First\r\n" +
+		"**Type:** 2D\r\n" +
+		"**Dimensions:** 640x480\r\n" +
+		"**Thumbnail:** thumbnails\\img-1_thumb.jpg\r\n" +
+		"**Attribute sku:** A-1\r\n" +
+		"- **Attribute fake:** ignored in lists\r\n" +
+		"\r\n**AI Analysis:**\r\n" +
+		"- **Description:** " + long + "\r\n" +
+		"- **Title:** not the title\r\n"
+
+	img := parseImageSection("img-1", section)
+	if img.Title != "First" || img.Description != long || img.ThumbnailPath != "thumbnails/img-1_thumb.jpg" {
+		t.Errorf("unexpected fields %+v", img)
+	}
+	if img.Width != 640 || img.Height != 480 || img.Megapixels == 0 {
+		t.Errorf("expected dimension stats derived from 640x480, got %+v", img)
+	}
+	if len(img.Attributes) != 1 || img.Attributes["sku"] != "A-1" {
+		t.Errorf("unexpected attributes %v", img.Attributes)
+	}
+	if img.Revision != 1 || img.Visibility != models.VisibilityPublic || img.Workflow != models.WorkflowDraft {
+		t.Errorf("expected defaults, got %+v", img)
+	}
+
+	// The streaming parser handles lines beyond the scanner's initial buffer
+	var streamed []*ImageMetadata
+	if err := scanIndex(strings.NewReader("# Index\n"+section), func(img *ImageMetadata) {
+		streamed = append(streamed, img)
+	}); err != nil {
+		t.Fatalf("scanIndex failed: %v", err)
+	}
+	if len(streamed) != 1 || streamed[0].ID != "img-1" || streamed[0].Description != long {
+		t.Errorf("unexpected streamed entries %+v", streamed)
+	}
+}
+
+func TestParseImageSection_AnalysisStamp(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	img := &models.Image{
+		ID:         "img-1",
+		Title:      "Cat",
+		Category:   "animals",
+		Type:       models.ImageType2D,
+		UploadedAt: time.Now(),
+		AIAnalysis: &models.AIAnalysis{
+			Description:     "A cat",
+			PrimaryCategory: "animals",
+			Provider:        "gemini",
+			Model:           "gemini-3-flash-preview",
+			PromptVersion:   "3f2a9c01b7de",
+		},
+	}
+	parsed := parseImageSection("img-1", svc.buildMarkdownEntry(img))
+	if parsed.AIProvider != "gemini" || parsed.AIModel != "gemini-3-flash-preview" || parsed.PromptVersion != "3f2a9c01b7de" {
+		t.Errorf("expected the analysis stamp, got %+v", parsed)
+	}
+
+	// Analyses made before stamping have none
+	img.AIAnalysis.Provider, img.AIAnalysis.Model, img.AIAnalysis.PromptVersion = "", "", ""
+	if parsed := parseImageSection("img-1", svc.buildMarkdownEntry(img)); parsed.AIModel != "" || parsed.PromptVersion != "" {
+		t.Errorf("expected no stamp, got %+v", parsed)
+	}
+}
+
+func TestParseImageSection_Aesthetics(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	img := &models.Image{
+		ID:         "img-1",
+		Title:      "Harbor",
+		Category:   "landscapes",
+		Type:       models.ImageType2D,
+		UploadedAt: time.Now(),
+		AIAnalysis: &models.AIAnalysis{
+			Description:     "A harbor at dusk",
+			PrimaryCategory: "landscapes",
+			Aesthetics:      &models.Aesthetics{Score: 7.5, Composition: 8, Critique: "Strong leading lines; the horizon is slightly tilted."},
+		},
+	}
+	parsed := parseImageSection("img-1", svc.buildMarkdownEntry(escapedForIndex(img)))
+	if parsed.Aesthetics == nil || *parsed.Aesthetics != *img.AIAnalysis.Aesthetics {
+		t.Errorf("expected %+v, got %+v", img.AIAnalysis.Aesthetics, parsed.Aesthetics)
+	}
+
+	img.AIAnalysis.Aesthetics = nil
+	if parsed := parseImageSection("img-1", svc.buildMarkdownEntry(img)); parsed.Aesthetics != nil {
+		t.Errorf("expected no aesthetics, got %+v", parsed.Aesthetics)
+	}
+}
+
+func BenchmarkGetAllImages(b *testing.B) {
+	for _, n := range []int{1000, 50000} {
+		svc := writeBenchIndex(b, n)
+		b.Run(fmt.Sprintf("entries=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := svc.GetAllImages(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseImageSection(b *testing.B) {
+	svc := writeBenchIndex(b, 2)
+	content, _ := svc.ReadIndex()
+	start, end, _ := findImageSection(content, "img-000001")
+	section := content[start:end]
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parseImageSection("img-000001", section)
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IndexSchemaVersion is the version of the index format this build writes.
+// Bump it with a migration in indexMigrations whenever the format changes.
+const IndexSchemaVersion = 1
+
+// schemaVersionField stamps the schema version in the index header
+const schemaVersionField = "Schema Version:"
+
+// ErrIndexSchemaTooNew is returned for an index written by a newer build,
+// which this one can't read safely
+var ErrIndexSchemaTooNew = errors.New("index schema is newer than this build")
+
+// indexMigration upgrades the index to version from the one before it
+type indexMigration struct {
+	version     int
+	description string
+	// section rewrites the section of one image. It must be idempotent: it
+	// is also applied to journaled sections, whatever version wrote them.
+	section func(section string) string
+}
+
+// indexMigrations are applied in order to indexes of an older version
+var indexMigrations = []indexMigration{
+	{
+		version:     1,
+		description: "record the revision of entries written before revisions were tracked",
+		section: func(section string) string {
+			if strings.Contains(section, "**Revision:**") {
+				return section
+			}
+			return setField(section, "Revision", "1")
+		},
+	},
+}
+
+var (
+	schemaVersionRegex = regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(schemaVersionField) + `[ \t]*(\d+)[ \t]*\r?\n`)
+	sectionStartRegex  = regexp.MustCompile(`(?m)^` + imageHeader)
+)
+
+// indexHeader returns the part of the index before the first image section
+func indexHeader(content string) string {
+	if loc := sectionStartRegex.FindStringIndex(content); loc != nil {
+		return content[:loc[0]]
+	}
+	return content
+}
+
+// indexSchemaVersion returns the schema version stamped in the index header;
+// indexes from before versioning are version 0
+func indexSchemaVersion(content string) int {
+	m := schemaVersionRegex.FindStringSubmatch(indexHeader(content))
+	if m == nil {
+		return 0
+	}
+	version, _ := strconv.Atoi(m[1])
+	return version
+}
+
+// stampSchemaVersion sets the schema version in the index header, after the
+// Last Updated line if there is one
+func stampSchemaVersion(content string, version int) string {
+	header := indexHeader(content)
+	body := content[len(header):]
+	line := fmt.Sprintf("%s %d\n", schemaVersionField, version)
+
+	if loc := schemaVersionRegex.FindStringIndex(header); loc != nil {
+		return header[:loc[0]] + line + header[loc[1]:] + body
+	}
+	if loc := regexp.MustCompile(`(?m)^Last Updated:.*\n`).FindStringIndex(header); loc != nil {
+		return header[:loc[1]] + line + header[loc[1]:] + body
+	}
+	return line + header + body
+}
+
+// migrateSections applies fn to every image section of the index
+func migrateSections(content string, fn func(section string) string) string {
+	locs := sectionStartRegex.FindAllStringIndex(content, -1)
+	if len(locs) == 0 {
+		return content
+	}
+	var sb strings.Builder
+	sb.WriteString(content[:locs[0][0]])
+	for i, loc := range locs {
+		end := len(content)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		sb.WriteString(fn(content[loc[0]:end]))
+	}
+	return sb.String()
+}
+
+// migrateSection brings a single section, e.g. a journaled one, up to the
+// current schema
+func migrateSection(section string) string {
+	for _, m := range indexMigrations {
+		if m.section != nil {
+			section = m.section(section)
+		}
+	}
+	return section
+}
+
+// MigrateIndex upgrades an index written by an older build to the current
+// schema, after copying it to index.md.v<version>-<time>.bak beside it. The
+// shards of a sharded index are migrated one by one. It returns the oldest
+// version found. An index of a newer schema is left alone
+// (ErrIndexSchemaTooNew).
+func (s *IndexService) MigrateIndex() (int, error) {
+	files, err := s.files()
+	if err != nil {
+		return 0, err
+	}
+	oldest := IndexSchemaVersion
+	for _, f := range files {
+		unlock, err := s.lockFiles(f.path)
+		if err != nil {
+			return 0, err
+		}
+		from, err := s.migrateFile(f.path)
+		unlock()
+		if err != nil {
+			return from, err
+		}
+		if from < oldest {
+			oldest = from
+		}
+	}
+	return oldest, nil
+}
+
+// migrateFile upgrades one index file. Caller must hold the file lock.
+func (s *IndexService) migrateFile(path string) (int, error) {
+	content, err := readIndexFile(path)
+	if err != nil {
+		return 0, err
+	}
+	from := indexSchemaVersion(content)
+	if from > IndexSchemaVersion {
+		return from, fmt.Errorf("%w: version %d, this build reads up to %d", ErrIndexSchemaTooNew, from, IndexSchemaVersion)
+	}
+	if from == IndexSchemaVersion {
+		return from, nil
+	}
+
+	backupPath := fmt.Sprintf("%s.v%d-%s.bak", path, from, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(backupPath, []byte(content), 0644); err != nil {
+		return from, fmt.Errorf("failed to back up index before migrating: %w", err)
+	}
+
+	for _, m := range indexMigrations {
+		if m.version <= from {
+			continue
+		}
+		if m.section != nil {
+			content = migrateSections(content, m.section)
+		}
+	}
+	content = stampSchemaVersion(content, IndexSchemaVersion)
+	if err := s.writeIndex(path, content); err != nil {
+		return from, fmt.Errorf("failed to migrate index (backup kept at %s): %w", backupPath, err)
+	}
+	return from, nil
+}
+
+// IndexMigrations describes the migrations applied to an index of version
+// from, in order
+func IndexMigrations(from int) []string {
+	var descriptions []string
+	for _, m := range indexMigrations {
+		if m.version > from {
+			descriptions = append(descriptions, fmt.Sprintf("v%d: %s", m.version, m.description))
+		}
+	}
+	return descriptions
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestMigrateIndex_UpgradesUnversionedIndex(t *testing.T) {
+	dataDir := t.TempDir()
+	indexService := NewIndexService(dataDir)
+
+	// An index from before versioning, with an entry from before revisions
+	entry := indexService.buildMarkdownEntry(&models.Image{ID: "a", Title: "Old", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()})
+	entry = regexp.MustCompile(`(?m)^\*\*Revision:\*\*.*\n`).ReplaceAllString(entry, "")
+	old := "# Image Warehouse Index\nLast Updated: 2024-01-01 00:00:00\n\n---\n" + entry
+	indexPath := filepath.Join(dataDir, "index.md")
+	if err := os.WriteFile(indexPath, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := indexService.MigrateIndex()
+	if err != nil || from != 0 {
+		t.Fatalf("expected a migration from version 0, got %d (%v)", from, err)
+	}
+	content, _ := indexService.ReadIndex()
+	if indexSchemaVersion(content) != IndexSchemaVersion {
+		t.Errorf("expected the index to be stamped with version %d:\n%s", IndexSchemaVersion, content)
+	}
+	if !strings.Contains(content, "Last Updated: 2024-01-01 00:00:00\nSchema Version: 1\n") || !strings.Contains(content, "**Revision:** 1\n") {
+		t.Errorf("unexpected migrated index:\n%s", content)
+	}
+	if img, err := indexService.GetImageByID("a"); err != nil || img.Title != "Old" || img.Revision != 1 {
+		t.Errorf("unexpected migrated image %+v (%v)", img, err)
+	}
+
+	backups, _ := filepath.Glob(indexPath + ".v0-*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("expected one backup, got %v", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != old {
+		t.Errorf("expected the backup to hold the old index")
+	}
+
+	// Already current: nothing to do
+	if from, err := indexService.MigrateIndex(); err != nil || from != IndexSchemaVersion {
+		t.Errorf("expected no migration, got %d (%v)", from, err)
+	}
+	if backups, _ := filepath.Glob(indexPath + ".v*.bak"); len(backups) != 1 {
+		t.Errorf("expected no further backup, got %v", backups)
+	}
+
+	newer := stampSchemaVersion(content, IndexSchemaVersion+1)
+	if err := os.WriteFile(indexPath, []byte(newer), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := indexService.MigrateIndex(); !errors.Is(err, ErrIndexSchemaTooNew) {
+		t.Errorf("expected ErrIndexSchemaTooNew, got %v", err)
+	}
+}
+
+func TestInitializeIndex_StampsSchemaVersion(t *testing.T) {
+	indexService := NewIndexService(t.TempDir())
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	if from, err := indexService.MigrateIndex(); err != nil || from != IndexSchemaVersion {
+		t.Errorf("expected a new index to need no migration, got %d (%v)", from, err)
+	}
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SectionSelection picks the image sections of a partial index read. A
+// section is selected when it matches every set field; a zero selection
+// selects all. Tombstones are never selected.
+type SectionSelection struct {
+	Categories []string        // category paths; a primary category includes its sub categories
+	IDs        map[string]bool // image IDs
+}
+
+// IsZero reports whether the selection selects every image
+func (sel SectionSelection) IsZero() bool {
+	return len(sel.Categories) == 0 && sel.IDs == nil
+}
+
+func (sel SectionSelection) matches(section sectionRange) bool {
+	if section.deleted {
+		return false
+	}
+	if sel.IDs != nil && !sel.IDs[section.id] {
+		return false
+	}
+	if len(sel.Categories) == 0 {
+		return true
+	}
+	img := ImageMetadata{Category: section.category}
+	for _, category := range sel.Categories {
+		if img.InCategory(category) {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionRange is the byte range of one image section in the index file
+type sectionRange struct {
+	id, category string
+	deleted      bool
+	start, end   int64
+}
+
+// coversShard reports whether the shard of a primary category may hold
+// selected sections; index.md (no category) always may
+func (sel SectionSelection) coversShard(category string) bool {
+	if category == "" || len(sel.Categories) == 0 {
+		return true
+	}
+	for _, c := range sel.Categories {
+		if shardCategory(c) == category {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionOffsets locates every image section of an index file, so partial
+// reads only read the selected byte ranges. It is rebuilt when the file
+// changes.
+type sectionOffsets struct {
+	mutex    sync.Mutex
+	modTime  time.Time
+	size     int64
+	sections []sectionRange
+}
+
+// ReadSections returns the index sections of the selected images, in index
+// order, without the index header. Searches use it to hand the AI a fraction
+// of the index.
+func (s *IndexService) ReadSections(sel SectionSelection) (string, error) {
+	var sb strings.Builder
+	err := s.readSections(sel, func(_ string, section []byte) {
+		sb.Write(section)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read index: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// SelectImages returns the metadata of the selected images, parsing only
+// their sections
+func (s *IndexService) SelectImages(sel SectionSelection) ([]*ImageMetadata, error) {
+	var images []*ImageMetadata
+	err := s.readSections(sel, func(id string, section []byte) {
+		images = append(images, parseImageSection(id, string(section)))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	return images, nil
+}
+
+// readSections calls fn with the ID and content of each selected section.
+// A sharded index only reads the shards of the selected categories.
+func (s *IndexService) readSections(sel SectionSelection, fn func(id string, section []byte)) error {
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if !sel.coversShard(f.category) {
+			continue
+		}
+		if err := s.readFileSections(f.path, sel, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// offsets returns the section offsets of an index file
+func (s *IndexService) offsets(path string) *sectionOffsets {
+	s.sectionsMutex.Lock()
+	defer s.sectionsMutex.Unlock()
+	if s.sections == nil {
+		s.sections = make(map[string]*sectionOffsets)
+	}
+	o, ok := s.sections[path]
+	if !ok {
+		o = &sectionOffsets{}
+		s.sections[path] = o
+	}
+	return o
+}
+
+// fileSections returns the sections of an index file
+func (s *IndexService) fileSections(path string) ([]sectionRange, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return s.offsets(path).load(file, info, false)
+}
+
+// readFileSections reads the selected sections of one index file. The
+// offsets are those of the open file, so a file replaced meanwhile is still
+// read consistently.
+func (s *IndexService) readFileSections(path string, sel SectionSelection, fn func(id string, section []byte)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		sections, err := s.offsets(path).load(file, info, attempt > 0)
+		if err != nil {
+			return err
+		}
+		selected, ok, err := readSelected(file, sections, sel)
+		if err != nil {
+			return err
+		}
+		if !ok && attempt == 0 {
+			// Rewritten within the same modification time and size
+			continue
+		}
+		if !ok {
+			return errors.New("index sections moved while reading")
+		}
+		for _, section := range selected {
+			fn(section.id, section.content)
+		}
+		return nil
+	}
+}
+
+// selectedSection is the content of a section read by readSelected
+type selectedSection struct {
+	id      string
+	content []byte
+}
+
+// readSelected reads the selected sections of file. It reports false if a
+// range doesn't start with its section header, i.e. the offsets are stale.
+func readSelected(file *os.File, sections []sectionRange, sel SectionSelection) ([]selectedSection, bool, error) {
+	var selected []selectedSection
+	for _, section := range sections {
+		if !sel.matches(section) {
+			continue
+		}
+		content := make([]byte, section.end-section.start)
+		if _, err := file.ReadAt(content, section.start); err != nil {
+			return nil, false, err
+		}
+		if !bytes.HasPrefix(content, []byte(imageHeader+section.id)) {
+			return nil, false, nil
+		}
+		selected = append(selected, selectedSection{id: section.id, content: content})
+	}
+	return selected, true, nil
+}
+
+// load returns the section offsets of the index open as file, rebuilding
+// them if the index changed or rebuild is set
+func (o *sectionOffsets) load(file *os.File, info os.FileInfo, rebuild bool) ([]sectionRange, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if rebuild || o.sections == nil || !info.ModTime().Equal(o.modTime) || info.Size() != o.size {
+		sections, err := scanSectionRanges(io.NewSectionReader(file, 0, info.Size()))
+		if err != nil {
+			return nil, err
+		}
+		o.sections, o.modTime, o.size = sections, info.ModTime(), info.Size()
+	}
+	return o.sections, nil
+}
+
+// scanSectionRanges streams the index and records the byte range, category
+// and tombstone state of every image section
+func scanSectionRanges(r io.Reader) ([]sectionRange, error) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	sections := []sectionRange{}
+	var offset int64
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			trimmed := strings.TrimRight(line, "\r\n")
+			switch {
+			case strings.HasPrefix(trimmed, imageHeader):
+				if n := len(sections); n > 0 {
+					sections[n-1].end = offset
+				}
+				sections = append(sections, sectionRange{id: trimmed[len(imageHeader):], start: offset})
+			case len(sections) > 0:
+				current := &sections[len(sections)-1]
+				if name, value, _, ok := parseFieldLine(trimmed); ok {
+					switch name {
+					case "Category":
+						if current.category == "" {
+							current.category = value
+						}
+					case "Deleted":
+						current.deleted = true
+					}
+				}
+			}
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if n := len(sections); n > 0 {
+		sections[n-1].end = offset
+	}
+	return sections, nil
+}
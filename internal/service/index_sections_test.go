@@ -0,0 +1,74 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestReadSections_SelectsByCategoryAndID(t *testing.T) {
+	indexService := NewIndexService(t.TempDir())
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	for _, img := range []*models.Image{
+		{ID: "cat", Title: "Cat", Category: "animals/cats", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "dog", Title: "Dog", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "tower", Title: "Tower", Category: "architecture", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "gone", Title: "Gone", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()},
+	} {
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+	if _, err := indexService.DeleteImage("gone", "test"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+
+	ids := func(sel SectionSelection) string {
+		images, err := indexService.SelectImages(sel)
+		if err != nil {
+			t.Fatalf("SelectImages failed: %v", err)
+		}
+		var names []string
+		for _, img := range images {
+			names = append(names, img.ID+":"+img.Title)
+		}
+		return strings.Join(names, ",")
+	}
+
+	if got := ids(SectionSelection{}); got != "cat:Cat,dog:Dog,tower:Tower" {
+		t.Errorf("expected every live image, got %s", got)
+	}
+	if got := ids(SectionSelection{Categories: []string{"animals"}}); got != "cat:Cat,dog:Dog" {
+		t.Errorf("expected the animals and their sub categories, got %s", got)
+	}
+	if got := ids(SectionSelection{Categories: []string{"animals/cats", "architecture"}}); got != "cat:Cat,tower:Tower" {
+		t.Errorf("expected the union of categories, got %s", got)
+	}
+	if got := ids(SectionSelection{IDs: map[string]bool{"tower": true, "gone": true, "missing": true}}); got != "tower:Tower" {
+		t.Errorf("expected the selected IDs, got %s", got)
+	}
+	if got := ids(SectionSelection{IDs: map[string]bool{}}); got != "" {
+		t.Errorf("expected an empty ID set to select nothing, got %s", got)
+	}
+
+	// Offsets follow rewrites of the index
+	title := "Big Dog"
+	if _, err := indexService.UpdateImage("dog", 0, ImageUpdate{Title: &title}); err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if got := ids(SectionSelection{Categories: []string{"animals"}}); got != "cat:Cat,dog:Big Dog" {
+		t.Errorf("expected the updated entry, got %s", got)
+	}
+
+	content, err := indexService.ReadSections(SectionSelection{Categories: []string{"architecture"}})
+	if err != nil {
+		t.Fatalf("ReadSections failed: %v", err)
+	}
+	if !strings.HasPrefix(content, "## Image: tower\n") || strings.Contains(content, "Image Warehouse Index") || strings.Contains(content, "## Image: cat") {
+		t.Errorf("expected only the tower's section:\n%s", content)
+	}
+}
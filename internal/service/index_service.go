@@ -1,38 +1,372 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofrs/flock"
 	"github.com/yourcompany/image-warehousing/internal/models"
 )
 
+// ErrImageNotFound is returned when an image is not in the index (or has been deleted)
+var ErrImageNotFound = errors.New("image not found")
+
+// ErrRevisionMismatch is returned when an update was based on a stale revision
+var ErrRevisionMismatch = errors.New("revision mismatch")
+
+// ErrFocalPointUnsupported is returned when setting a focal point on an image
+// without a square thumbnail, i.e. a 3D object
+var ErrFocalPointUnsupported = errors.New("focal points only apply to 2D images")
+
+// ErrBoundsUnsupported is returned when setting real-world bounds on a 2D image
+var ErrBoundsUnsupported = errors.New("bounds only apply to 3D objects")
+
+// ErrPolygonBudgetUnsupported is returned when setting a triangle count or
+// LOD tags on a 2D image
+var ErrPolygonBudgetUnsupported = errors.New("triangle counts and LOD tags only apply to 3D objects")
+
+// ErrPosterViewUnsupported is returned when choosing a poster view for a 2D
+// image
+var ErrPosterViewUnsupported = errors.New("poster views only apply to 3D objects")
+
+// ErrUnknownPosterView is returned when the chosen poster view is not one of
+// the object's views
+var ErrUnknownPosterView = errors.New("no such view")
+
+// indexLock serializes index writes: a flock by default, or a
+// CoordinatedLock when several instances share the data directory. A
+// sharded index has one per shard besides the index lock.
+type indexLock interface {
+	Lock() error
+	Unlock() error
+}
+
+// fileLock is an indexLock on a flock. A flock doesn't exclude the other
+// goroutines of the process holding it, so they queue on a mutex first.
+type fileLock struct {
+	local sync.Mutex
+	file  *flock.Flock
+}
+
+func newFileLock(path string) *fileLock {
+	return &fileLock{file: flock.New(path)}
+}
+
+func (l *fileLock) Lock() error {
+	l.local.Lock()
+	if err := l.file.Lock(); err != nil {
+		l.local.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (l *fileLock) Unlock() error {
+	defer l.local.Unlock()
+	return l.file.Unlock()
+}
+
+// fencedLock is an indexLock whose holder can lose it while holding it (a
+// lease), so writes carry its fencing token and check it is still held
+type fencedLock interface {
+	Fence() int64
+	Check() error
+}
+
 type IndexService struct {
-	indexPath string
-	lock      *flock.Flock
+	indexPath   string
+	shardDir    string    // set when the index is sharded by category (see EnableSharding)
+	lock        indexLock // the file lock, or only the journal's when sharded
+	coordinator *Coordinator
+	journal     *IndexJournal
+	originals   originalFiles
+
+	shardLocks      map[string]indexLock // by shard file
+	shardLocksMutex sync.Mutex
+
+	manifest      *ShardManifest // as last read or written, see loadManifest
+	manifestInfo  os.FileInfo
+	manifestMutex sync.Mutex
+
+	sections      map[string]*sectionOffsets // by index file
+	sectionsMutex sync.Mutex
 }
 
 func NewIndexService(dataDir string) *IndexService {
 	indexPath := filepath.Join(dataDir, "index.md")
 	return &IndexService{
 		indexPath: indexPath,
-		lock:      flock.New(indexPath + ".lock"),
+		lock:      newFileLock(indexPath + ".lock"),
+	}
+}
+
+// SetCoordinator locks the index through c instead of a flock, for
+// instances sharing the data directory on a volume where flock isn't
+// reliable across hosts
+func (s *IndexService) SetCoordinator(c *Coordinator) {
+	s.lock = c.Locker("index")
+	s.coordinator = c
+}
+
+// SetJournal records every index mutation in journal before it is applied
+func (s *IndexService) SetJournal(journal *IndexJournal) {
+	s.journal = journal
+}
+
+// record writes a mutation to the journal, if one is set, then applies it
+// with apply. before is the image's section before the mutation, empty for
+// an append. Caller must hold the file lock.
+func (s *IndexService) record(op, imageID, before, section string, apply func() error) error {
+	journaled, err := s.appendJournal(false, JournalEntry{Op: op, ImageID: imageID, Section: section})
+	if err != nil {
+		return fmt.Errorf("failed to journal %s of %s: %w", op, imageID, err)
+	}
+	if err := apply(); err != nil {
+		s.undoJournal(journaled, []string{before})
+		return err
+	}
+	return nil
+}
+
+// appendJournal journals entries, as one batch if batch is set, and records
+// the shards of appended images in a sharded index's manifest. Unsharded,
+// the caller holds the index lock as its file lock; sharded, the index lock
+// is only held here, so writers of different shards journal in turn but
+// write their shards in parallel.
+func (s *IndexService) appendJournal(batch bool, entries ...JournalEntry) ([]JournalEntry, error) {
+	if s.Sharded() {
+		if err := s.lock.Lock(); err != nil {
+			return nil, fmt.Errorf("failed to acquire lock: %w", err)
+		}
+		defer s.lock.Unlock()
+		for _, entry := range entries {
+			if entry.Op != JournalAppend {
+				continue
+			}
+			if err := s.addToShard(entry.ImageID, parseImageSection(entry.ImageID, entry.Section).Category); err != nil {
+				return nil, err
+			}
+		}
 	}
+	if s.journal == nil {
+		return nil, nil
+	}
+
+	var fence int64
+	if fenced, ok := s.lock.(fencedLock); ok {
+		fence = fenced.Fence()
+	}
+	if batch {
+		return s.journal.AppendBatch(fence, entries)
+	}
+	entry, err := s.journal.AppendFenced(fence, entries[0].Op, entries[0].ImageID, entries[0].Section)
+	if err != nil {
+		return nil, err
+	}
+	return []JournalEntry{entry}, nil
 }
 
-// InitializeIndex creates the index file if it doesn't exist
+// undoJournal takes back journaled entries whose mutations failed to reach
+// the index: they are discarded if they are still the latest, and else
+// followed by revert entries restoring the sections before them, since
+// other writers journaled meanwhile. Caller must hold the file lock.
+func (s *IndexService) undoJournal(journaled []JournalEntry, before []string) {
+	if len(journaled) == 0 {
+		return
+	}
+	if s.Sharded() {
+		if err := s.lock.Lock(); err != nil {
+			return
+		}
+		defer s.lock.Unlock()
+	}
+	if s.journal.DiscardFrom(journaled[0].Seq, journaled[len(journaled)-1].Seq) == nil {
+		return
+	}
+
+	var fence int64
+	if fenced, ok := s.lock.(fencedLock); ok {
+		fence = fenced.Fence()
+	}
+	reverts := make([]JournalEntry, len(journaled))
+	for i, entry := range journaled {
+		reverts[i] = JournalEntry{Op: JournalRevert, ImageID: entry.ImageID, Section: before[i]}
+	}
+	s.journal.AppendBatch(fence, reverts)
+}
+
+// RecoverJournal applies the journal entries a crash stopped from reaching
+// the index. Each index file is written under its lock right after its
+// entries are journaled, so only the latest entry of each file, or its
+// entries of the latest batch, can be missing.
+func (s *IndexService) RecoverJournal() (bool, error) {
+	if s.journal == nil {
+		return false, nil
+	}
+	files, err := s.files()
+	if err != nil {
+		return false, err
+	}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	unlock, err := s.lockFiles(paths...)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	entries, err := s.pendingEntries(len(files))
+	if err != nil {
+		return false, err
+	}
+	if s.Sharded() {
+		if err := s.lock.Lock(); err != nil {
+			return false, fmt.Errorf("failed to acquire lock: %w", err)
+		}
+		defer s.lock.Unlock()
+	}
+
+	contents := make(map[string]string)
+	var changed []string
+	for _, entry := range entries {
+		path := s.fileForSection(entry.ImageID, entry.Section)
+		if s.Sharded() && entry.Op == JournalAppend {
+			if err := s.addToShard(entry.ImageID, parseImageSection(entry.ImageID, entry.Section).Category); err != nil {
+				return false, err
+			}
+		}
+		content, ok := contents[path]
+		if !ok {
+			if content, err = readIndexFile(path); err != nil {
+				return false, err
+			}
+		}
+
+		// The entry may predate a migration of the index
+		section := migrateSection(entry.Section)
+		start, end, found := findImageSection(content, entry.ImageID)
+		switch {
+		case entry.Op == JournalAppend && found:
+			continue
+		case entry.Op == JournalAppend:
+			content += section
+		case entry.Op == JournalRevert && entry.Section == "":
+			// An append that never reached the index
+			if !found {
+				continue
+			}
+			content = content[:start] + content[end:]
+		case !found:
+			return false, fmt.Errorf("journaled %s of %s, which is not in the index", entry.Op, entry.ImageID)
+		case content[start:end] == section:
+			continue
+		default:
+			content = content[:start] + section + content[end:]
+		}
+		contents[path] = content
+		if !slices.Contains(changed, path) {
+			changed = append(changed, path)
+		}
+	}
+
+	for _, path := range changed {
+		if err := s.writeIndex(path, contents[path]); err != nil {
+			return false, err
+		}
+	}
+	return len(changed) > 0, nil
+}
+
+// pendingEntries returns, oldest first, the latest journal entry of each of
+// the index's files, or its entries of the latest batch. The journal is
+// read backwards until every file's are found.
+func (s *IndexService) pendingEntries(files int) ([]JournalEntry, error) {
+	const page = 256
+	type tail struct {
+		batch   int64
+		entries []JournalEntry
+	}
+	tails := make(map[string]*tail)
+	complete := func(lo int64) bool {
+		if len(tails) < files {
+			return false
+		}
+		for _, t := range tails {
+			if t.batch > 0 && t.batch <= lo {
+				return false
+			}
+		}
+		return true
+	}
+
+	for hi := s.journal.LastSeq(); hi > 0; hi -= page {
+		lo := max(hi-page, 0)
+		entries, err := s.journal.Since(lo, int(hi-lo))
+		if err != nil {
+			return nil, err
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			entry := entries[i]
+			path := s.fileForSection(entry.ImageID, entry.Section)
+			t, ok := tails[path]
+			switch {
+			case !ok:
+				tails[path] = &tail{batch: entry.Batch, entries: []JournalEntry{entry}}
+			case t.batch > 0 && entry.Batch == t.batch:
+				t.entries = append(t.entries, entry)
+			}
+		}
+		if complete(lo) {
+			break
+		}
+	}
+
+	var pending []JournalEntry
+	for _, t := range tails {
+		pending = append(pending, t.entries...)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Seq < pending[j].Seq })
+	return pending, nil
+}
+
+// RedactJournal removes the earlier metadata of deleted images from the
+// journal, under the index lock so no mutation is journaled meanwhile
+func (s *IndexService) RedactJournal(imageIDs map[string]bool) (int, error) {
+	if s.journal == nil || len(imageIDs) == 0 {
+		return 0, nil
+	}
+	if err := s.lock.Lock(); err != nil {
+		return 0, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer s.lock.Unlock()
+	return s.journal.Redact(imageIDs)
+}
+
+// InitializeIndex creates the index file, at the current schema version, if
+// it doesn't exist. A sharded index gets its manifest, splitting an existing
+// index.md.
 func (s *IndexService) InitializeIndex() error {
+	if s.Sharded() {
+		return s.shardIndex()
+	}
 	if _, err := os.Stat(s.indexPath); os.IsNotExist(err) {
 		initialContent := fmt.Sprintf(`# Image Warehouse Index
 Last Updated: %s
+%s %d
 
 ---
-`, time.Now().Format("2006-01-02 15:04:05"))
+`, time.Now().Format("2006-01-02 15:04:05"), schemaVersionField, IndexSchemaVersion)
 
 		return os.WriteFile(s.indexPath, []byte(initialContent), 0644)
 	}
@@ -41,40 +375,375 @@ Last Updated: %s
 
 // AppendToIndex adds a new image entry to the index
 func (s *IndexService) AppendToIndex(image *models.Image) error {
-	// Acquire file lock
-	if err := s.lock.Lock(); err != nil {
-		return fmt.Errorf("failed to acquire lock: %w", err)
+	path := s.indexPath
+	if s.Sharded() {
+		path = s.shardFile(image.Category)
 	}
-	defer s.lock.Unlock()
+	unlock, err := s.lockFiles(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Build the markdown entry
 	entry := s.buildMarkdownEntry(image)
+	return s.record(JournalAppend, image.ID, "", entry, func() error {
+		// Append to file
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open index: %w", err)
+		}
+		defer f.Close()
+
+		if _, err := f.WriteString(entry); err != nil {
+			return fmt.Errorf("failed to write to index: %w", err)
+		}
+		return nil
+	})
+}
 
-	// Append to file
-	f, err := os.OpenFile(s.indexPath, os.O_APPEND|os.O_WRONLY, 0644)
+// LastModified returns the modification time of the index, the latest of
+// its shards when sharded
+func (s *IndexService) LastModified() (time.Time, error) {
+	modTime, _, err := s.indexStamp()
+	return modTime, err
+}
+
+// ReadIndex returns the entire index content; the shards of a sharded index
+// one after another
+func (s *IndexService) ReadIndex() (string, error) {
+	files, err := s.files()
 	if err != nil {
-		return fmt.Errorf("failed to open index: %w", err)
+		return "", err
 	}
-	defer f.Close()
-
-	if _, err := f.WriteString(entry); err != nil {
-		return fmt.Errorf("failed to write to index: %w", err)
+	var sb strings.Builder
+	for _, f := range files {
+		content, err := readIndexFile(f.path)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(content)
 	}
-
-	return nil
+	return sb.String(), nil
 }
 
-// ReadIndex returns the entire index content
-func (s *IndexService) ReadIndex() (string, error) {
-	content, err := os.ReadFile(s.indexPath)
+// readIndexFile returns the content of one index file
+func readIndexFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read index: %w", err)
 	}
 	return string(content), nil
 }
 
+// DeleteImage replaces an image's entry with a tombstone recording when and by
+// whom it was deleted, and returns the metadata the entry had before. Images
+// on legal hold are not deleted (ErrLegalHold).
+func (s *IndexService) DeleteImage(imageID, actor string) (*ImageMetadata, error) {
+	if actor == "" {
+		actor = "anonymous"
+	}
+
+	var deleted *ImageMetadata
+	err := s.rewriteEntry(imageID, JournalDelete, func(section string) (string, error) {
+		deleted = parseImageSection(imageID, section)
+		if deleted.Deleted {
+			return "", fmt.Errorf("%w: %s", ErrImageNotFound, imageID)
+		}
+		if deleted.LegalHold {
+			return "", fmt.Errorf("%w: %s", ErrLegalHold, imageID)
+		}
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("## Image: %s\n\n", imageID))
+		sb.WriteString(fmt.Sprintf("**Deleted:** %s\n", time.Now().Format("2006-01-02 15:04:05")))
+		sb.WriteString(fmt.Sprintf("**Deleted By:** %s\n", escapeIndexValue(actor)))
+		sb.WriteString("\n---\n")
+		return sb.String(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// ImageUpdate is a partial metadata update; nil fields are left unchanged.
+// AddTags and RemoveTags are applied after Tags.
+type ImageUpdate struct {
+	Title       *string            `json:"title,omitempty"`
+	Artist      *string            `json:"artist,omitempty"`
+	Description *string            `json:"description,omitempty"`
+	Visibility  *string            `json:"visibility,omitempty"`
+	Project     *string            `json:"project,omitempty"`     // project ID; empty removes the image from its project
+	License     *models.License    `json:"license,omitempty"`     // replaces the whole license; empty fields are cleared
+	Attributes  map[string]string  `json:"attributes,omitempty"`  // merged into the current attributes; empty values remove
+	FocalPoint  *models.FocalPoint `json:"focal_point,omitempty"` // manual override; regenerates the square thumbnail
+	Bounds      *models.Bounds     `json:"bounds,omitempty"`      // manual override of a 3D object's real-world size
+	Triangles   *int               `json:"triangles,omitempty"`   // manual override of a 3D object's triangle count
+	LODTags     *[]string          `json:"lod_tags,omitempty"`    // replaces a 3D object's LOD tags
+	PosterView  *string            `json:"poster_view,omitempty"` // manual choice of a 3D object's thumbnail view; empty resets it
+	Workflow    *string            `json:"-"`                     // only via WorkflowService, which checks transitions
+	LegalHold   *bool              `json:"-"`                     // only via ImageService.SetLegalHold, for admins
+	Upscales    map[string]string  `json:"-"`                     // factor ("2x") -> rendition path, set by UpscaleService
+	Actor       string             `json:"-"`                     // who made the update, for the image history; empty for the warehouse itself
+	Tags        *[]string          `json:"tags,omitempty"`
+	AddTags     []string           `json:"add_tags,omitempty"`
+	RemoveTags  []string           `json:"remove_tags,omitempty"`
+}
+
+// applyTags returns current tags with the update's tag operations applied
+func (u ImageUpdate) applyTags(current []string) []string {
+	tags := current
+	if u.Tags != nil {
+		tags = *u.Tags
+	}
+
+	removed := make(map[string]bool, len(u.RemoveTags))
+	for _, tag := range u.RemoveTags {
+		removed[tag] = true
+	}
+
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, tag := range append(append([]string{}, tags...), u.AddTags...) {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || removed[tag] || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// changesTags reports whether the update touches tags
+func (u ImageUpdate) changesTags() bool {
+	return u.Tags != nil || len(u.AddTags) > 0 || len(u.RemoveTags) > 0
+}
+
+// UpdateImage applies an update to an image's index entry and increments its
+// revision. If expectedRevision is positive and differs from the stored
+// revision, ErrRevisionMismatch is returned and nothing is written.
+func (s *IndexService) UpdateImage(imageID string, expectedRevision int, update ImageUpdate) (*ImageMetadata, error) {
+	var updated *ImageMetadata
+	err := s.rewriteEntry(imageID, JournalUpdate, func(section string) (string, error) {
+		section, err := applyImageUpdate(imageID, section, expectedRevision, update)
+		if err != nil {
+			return "", err
+		}
+		updated = parseImageSection(imageID, section)
+		return section, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// applyImageUpdate returns an image's index section with an update applied
+// and its revision incremented
+func applyImageUpdate(imageID, section string, expectedRevision int, update ImageUpdate) (string, error) {
+	current := parseImageSection(imageID, section)
+	if current.Deleted {
+		return "", fmt.Errorf("%w: %s", ErrImageNotFound, imageID)
+	}
+	if expectedRevision > 0 && expectedRevision != current.Revision {
+		return "", fmt.Errorf("%w: expected %d, current %d", ErrRevisionMismatch, expectedRevision, current.Revision)
+	}
+
+	if update.Title != nil {
+		section = setField(section, "Title", *update.Title)
+	}
+	if update.Artist != nil {
+		section = setField(section, "Artist", *update.Artist)
+	}
+	if update.Description != nil {
+		section = setField(section, "Description", *update.Description)
+	}
+	if update.Visibility != nil {
+		section = setField(section, "Visibility", *update.Visibility)
+	}
+	if update.Project != nil {
+		section = setField(section, "Project", *update.Project)
+	}
+	if update.Workflow != nil {
+		section = setField(section, "Workflow", *update.Workflow)
+	}
+	if update.LegalHold != nil {
+		hold := ""
+		if *update.LegalHold {
+			hold = "yes"
+		}
+		section = setField(section, "Legal Hold", hold)
+	}
+	if update.License != nil {
+		section = setField(section, "License", update.License.Type)
+		section = setField(section, "Rights Holder", update.License.RightsHolder)
+		section = setField(section, "License Expires", update.License.ExpiresOn)
+		section = setField(section, "Usage Restrictions", update.License.Restrictions)
+	}
+	for name, value := range update.Attributes {
+		section = setField(section, attributeField(name), value)
+	}
+	if update.FocalPoint != nil {
+		if current.Type != string(models.ImageType2D) {
+			return "", ErrFocalPointUnsupported
+		}
+		section = setField(section, "Focal Point", update.FocalPoint.String())
+		// Entries indexed before square thumbnails get one now, and
+		// shared content a copy of its own
+		section = setField(section, "Square Thumbnail", imageSquareThumbnailPath(current))
+	}
+	if update.Bounds != nil {
+		if current.Type != string(models.ImageType3D) {
+			return "", ErrBoundsUnsupported
+		}
+		section = setField(section, "Bounds", update.Bounds.String())
+	}
+	if update.Triangles != nil || update.LODTags != nil {
+		if current.Type != string(models.ImageType3D) {
+			return "", ErrPolygonBudgetUnsupported
+		}
+		if update.Triangles != nil {
+			// Zero clears the count
+			triangles := ""
+			if *update.Triangles > 0 {
+				triangles = strconv.Itoa(*update.Triangles)
+			}
+			section = setField(section, "Triangles", triangles)
+		}
+		if update.LODTags != nil {
+			section = setField(section, "LOD Tags", joinIndexList(*update.LODTags, ", "))
+		}
+	}
+	if update.PosterView != nil {
+		if current.Type != string(models.ImageType3D) {
+			return "", ErrPosterViewUnsupported
+		}
+		if _, ok := current.Views[*update.PosterView]; *update.PosterView != "" && !ok {
+			return "", fmt.Errorf("%w: %s", ErrUnknownPosterView, *update.PosterView)
+		}
+		section = setField(section, "Poster View", *update.PosterView)
+	}
+	for factor, path := range update.Upscales {
+		section = setField(section, "Upscale "+factor, path)
+	}
+	if update.changesTags() {
+		section = setField(section, "Manual Tags", joinIndexList(update.applyTags(current.Tags), ", "))
+	}
+	section = setField(section, "Revision", strconv.Itoa(current.Revision+1))
+	return section, nil
+}
+
+// setField replaces the value of a field in an image section. Missing fields
+// are added after the Category line; an empty value removes the field. The
+// value is escaped (see escapeIndexValue).
+func setField(section, fieldName, value string) string {
+	value = escapeIndexValue(value)
+
+	lineRegex := regexp.MustCompile(`(?m)^(.*\*\*` + regexp.QuoteMeta(fieldName) + `:\*\*)[ \t]*.*\n`)
+	if loc := lineRegex.FindStringSubmatchIndex(section); loc != nil {
+		if value == "" {
+			return section[:loc[0]] + section[loc[1]:]
+		}
+		return section[:loc[0]] + section[loc[2]:loc[3]] + " " + value + "\n" + section[loc[1]:]
+	}
+
+	if value == "" {
+		return section
+	}
+
+	line := fmt.Sprintf("**%s:** %s\n", fieldName, value)
+	categoryRegex := regexp.MustCompile(`(?m)^\*\*Category:\*\*.*\n`)
+	if loc := categoryRegex.FindStringIndex(section); loc != nil {
+		return section[:loc[1]] + line + section[loc[1]:]
+	}
+	return section + line
+}
+
+// rewriteEntry replaces the index section of one image with the output of fn,
+// journaled as op. The index file holding the image, its shard when
+// sharded, is rewritten atomically under its lock.
+func (s *IndexService) rewriteEntry(imageID, op string, fn func(section string) (string, error)) error {
+	path, err := s.fileOf(imageID)
+	if err != nil {
+		return err
+	}
+	unlock, err := s.lockFiles(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content, err := readIndexFile(path)
+	if err != nil {
+		return err
+	}
+
+	start, end, ok := findImageSection(content, imageID)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrImageNotFound, imageID)
+	}
+
+	replacement, err := fn(content[start:end])
+	if err != nil {
+		return err
+	}
+
+	return s.record(op, imageID, content[start:end], replacement, func() error {
+		return s.writeIndex(path, content[:start]+replacement+content[end:])
+	})
+}
+
+// writeIndex replaces an index file atomically. Caller must hold the file
+// lock. Under a lease it is only replaced while the lease is still held and,
+// unsharded, no later holder has journaled.
+func (s *IndexService) writeIndex(path, content string) error {
+	lock := s.lockFor(path)
+	err := replaceFile(path, func(tmpPath string) error {
+		if err := os.WriteFile(tmpPath, []byte(content), 0644); err != nil {
+			return err
+		}
+		fenced, ok := lock.(fencedLock)
+		if !ok {
+			return nil
+		}
+		if err := fenced.Check(); err != nil {
+			return err
+		}
+		if lock == s.lock && s.journal != nil && s.journal.Fence() > fenced.Fence() {
+			return ErrStaleFence
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// findImageSection returns the byte range of an image's section in the index
+func findImageSection(content, imageID string) (int, int, bool) {
+	headerRegex := regexp.MustCompile(`(?m)^## Image: ` + regexp.QuoteMeta(imageID) + `$`)
+	loc := headerRegex.FindStringIndex(content)
+	if loc == nil {
+		return 0, 0, false
+	}
+
+	end := len(content)
+	nextRegex := regexp.MustCompile(`(?m)^## Image: `)
+	if next := nextRegex.FindStringIndex(content[loc[1]:]); next != nil {
+		end = loc[1] + next[0]
+	}
+
+	return loc[0], end, true
+}
+
 // buildMarkdownEntry creates a markdown entry for an image
 func (s *IndexService) buildMarkdownEntry(img *models.Image) string {
+	img = escapedForIndex(img)
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("\n## Image: %s\n\n", img.ID))
@@ -83,11 +752,62 @@ func (s *IndexService) buildMarkdownEntry(img *models.Image) string {
 	sb.WriteString(fmt.Sprintf("**Uploaded:** %s\n", img.UploadedAt.Format("2006-01-02 15:04:05")))
 	sb.WriteString(fmt.Sprintf("**Type:** %s\n", img.Type))
 	sb.WriteString(fmt.Sprintf("**Category:** %s\n", img.Category))
+	if img.StorageLayout != "" {
+		sb.WriteString(fmt.Sprintf("**Storage Layout:** %s\n", img.StorageLayout))
+	}
+	if img.ExternalID != "" {
+		sb.WriteString(fmt.Sprintf("**External ID:** %s\n", img.ExternalID))
+	}
+	if img.Project != "" {
+		sb.WriteString(fmt.Sprintf("**Project:** %s\n", img.Project))
+	}
+	if img.Visibility != "" {
+		sb.WriteString(fmt.Sprintf("**Visibility:** %s\n", img.Visibility))
+	}
+	revision := img.Revision
+	if revision < 1 {
+		revision = 1
+	}
+	sb.WriteString(fmt.Sprintf("**Revision:** %d\n", revision))
+	if !img.License.IsZero() {
+		writeLicense(&sb, img.License)
+	}
+	writeAttributes(&sb, img.Attributes)
+	if !img.DesignSource.IsZero() {
+		writeDesignSource(&sb, img.DesignSource)
+	}
 
 	if img.Type == models.ImageType2D {
 		sb.WriteString(fmt.Sprintf("**File Path:** %s\n", img.FilePath))
+		if img.OriginalFilename != "" {
+			sb.WriteString(fmt.Sprintf("**Original Filename:** %s\n", img.OriginalFilename))
+		}
+		if img.MimeType != "" {
+			sb.WriteString(fmt.Sprintf("**MIME Type:** %s\n", img.MimeType))
+		}
 		sb.WriteString(fmt.Sprintf("**Thumbnail:** %s\n", img.ThumbnailPath))
-		sb.WriteString(fmt.Sprintf("**Dimensions:** %dx%d\n", img.Width, img.Height))
+		if img.SquareThumbnail != "" {
+			sb.WriteString(fmt.Sprintf("**Square Thumbnail:** %s\n", img.SquareThumbnail))
+		}
+		if img.FocalPoint != nil {
+			sb.WriteString(fmt.Sprintf("**Focal Point:** %s\n", img.FocalPoint))
+		}
+		writeDimensions(&sb, img)
+		if img.ColorSpace != "" {
+			sb.WriteString(fmt.Sprintf("**Color Space:** %s\n", img.ColorSpace))
+		}
+		if img.Quality != nil {
+			sb.WriteString(fmt.Sprintf("**Quality:** %s\n", img.Quality))
+		}
+		if img.VisualHash != "" {
+			sb.WriteString(fmt.Sprintf("**Visual Hash:** %s\n", img.VisualHash))
+		}
+		if img.Location != nil {
+			sb.WriteString(fmt.Sprintf("**Location:** %s\n", img.Location))
+		}
+		if img.CapturedAt != nil {
+			sb.WriteString(fmt.Sprintf("**Captured:** %s\n", img.CapturedAt.Format("2006-01-02 15:04:05")))
+		}
 		sb.WriteString(fmt.Sprintf("**File Size:** %.1f MB\n", float64(img.FileSize)/(1024*1024)))
 	} else if img.Type == models.ImageType3D {
 		sb.WriteString(fmt.Sprintf("**Folder Path:** %s\n", img.FolderPath))
@@ -95,6 +815,48 @@ func (s *IndexService) buildMarkdownEntry(img *models.Image) string {
 			sb.WriteString(fmt.Sprintf("**Model File:** %s\n", img.ModelFilePath))
 			sb.WriteString(fmt.Sprintf("**Model Filename:** %s\n", img.ModelFilename))
 		}
+		if img.ModelInfo != nil {
+			sb.WriteString(fmt.Sprintf("**Model Format:** %s\n", img.ModelInfo.Format))
+			if img.ModelInfo.Version != "" {
+				sb.WriteString(fmt.Sprintf("**Model Version:** %s\n", img.ModelInfo.Version))
+			}
+			if img.ModelInfo.UpAxis != "" {
+				sb.WriteString(fmt.Sprintf("**Up Axis:** %s\n", img.ModelInfo.UpAxis))
+			}
+		}
+		if img.Bounds != nil {
+			sb.WriteString(fmt.Sprintf("**Bounds:** %s\n", img.Bounds))
+		}
+		if img.Triangles > 0 {
+			sb.WriteString(fmt.Sprintf("**Triangles:** %d\n", img.Triangles))
+		}
+		if len(img.LODTags) > 0 {
+			sb.WriteString(fmt.Sprintf("**LOD Tags:** %s\n", strings.Join(img.LODTags, ", ")))
+		}
+		if img.Materials != nil {
+			sb.WriteString(fmt.Sprintf("**Materials:** %s\n", img.Materials.MaterialsField()))
+			if len(img.Materials.Textures) > 0 {
+				sb.WriteString(fmt.Sprintf("**Textures:** %s\n", img.Materials.TexturesField()))
+			}
+		}
+		if img.TurntablePath != "" {
+			sb.WriteString(fmt.Sprintf("**Turntable:** %s\n", img.TurntablePath))
+		}
+		if img.ClipPath != "" {
+			sb.WriteString(fmt.Sprintf("**Clip:** %s\n", img.ClipPath))
+		}
+		if img.Width > 0 {
+			writeDimensions(&sb, img)
+		}
+		if img.ColorSpace != "" {
+			sb.WriteString(fmt.Sprintf("**Color Space:** %s\n", img.ColorSpace))
+		}
+		if img.Quality != nil {
+			sb.WriteString(fmt.Sprintf("**Quality:** %s\n", img.Quality))
+		}
+		if img.PosterView != "" {
+			sb.WriteString(fmt.Sprintf("**Poster View:** %s\n", img.PosterView))
+		}
 		sb.WriteString("**Views:**\n")
 		for view, path := range img.Views {
 			sb.WriteString(fmt.Sprintf("- %s: %s\n", view, path))
@@ -116,11 +878,76 @@ func (s *IndexService) buildMarkdownEntry(img *models.Image) string {
 	return sb.String()
 }
 
+// writeDimensions writes the pixel size and the stats derived from it
+func writeDimensions(sb *strings.Builder, img *models.Image) {
+	sb.WriteString(fmt.Sprintf("**Dimensions:** %dx%d\n", img.Width, img.Height))
+	if img.Megapixels > 0 {
+		sb.WriteString(fmt.Sprintf("**Megapixels:** %.2f\n", img.Megapixels))
+		sb.WriteString(fmt.Sprintf("**Aspect Ratio:** %.4f\n", img.AspectRatio))
+	}
+}
+
+// writeLicense writes the non-empty license fields
+func writeLicense(sb *strings.Builder, license *models.License) {
+	fields := []struct{ name, value string }{
+		{"License", license.Type},
+		{"Rights Holder", license.RightsHolder},
+		{"License Expires", license.ExpiresOn},
+		{"Usage Restrictions", license.Restrictions},
+	}
+	for _, f := range fields {
+		if f.value != "" {
+			sb.WriteString(fmt.Sprintf("**%s:** %s\n", f.name, f.value))
+		}
+	}
+}
+
+// writeDesignSource writes the design tool document and frame of an export
+func writeDesignSource(sb *strings.Builder, source *models.DesignSource) {
+	fields := []struct{ name, value string }{
+		{"Design Tool", source.Tool},
+		{"Design Document", source.DocumentID},
+		{"Design Document Name", source.DocumentName},
+		{"Design Node", source.NodeID},
+		{"Design Node Name", source.NodeName},
+		{"Design Page", source.Page},
+		{"Design Version", source.Version},
+		{"Design URL", source.URL},
+	}
+	for _, f := range fields {
+		if f.value != "" {
+			sb.WriteString(fmt.Sprintf("**%s:** %s\n", f.name, f.value))
+		}
+	}
+}
+
+// attributeField returns the index field name of a custom attribute
+func attributeField(name string) string {
+	return "Attribute " + name
+}
+
+// writeAttributes writes the custom attributes in name order
+func writeAttributes(sb *strings.Builder, attrs map[string]string) {
+	names := make([]string, 0, len(attrs))
+	for name, value := range attrs {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("**%s:** %s\n", attributeField(name), attrs[name]))
+	}
+}
+
 // writeAIAnalysis writes the AI analysis section
 func (s *IndexService) writeAIAnalysis(sb *strings.Builder, ai *models.AIAnalysis) {
 	sb.WriteString("\n**AI Analysis:**\n")
 	sb.WriteString(fmt.Sprintf("- **Description:** %s\n", ai.Description))
 	sb.WriteString(fmt.Sprintf("- **Primary Category:** %s\n", ai.PrimaryCategory))
+	if ai.SubCategory != "" {
+		sb.WriteString(fmt.Sprintf("- **Sub Category:** %s\n", ai.SubCategory))
+	}
 
 	if len(ai.Objects) > 0 {
 		sb.WriteString(fmt.Sprintf("- **Objects Detected:** %s\n", strings.Join(ai.Objects, ", ")))
@@ -157,85 +984,189 @@ func (s *IndexService) writeAIAnalysis(sb *strings.Builder, ai *models.AIAnalysi
 	if ai.ThreeDCharacteristics != "" {
 		sb.WriteString(fmt.Sprintf("- **3D Characteristics:** %s\n", ai.ThreeDCharacteristics))
 	}
+
+	if len(ai.Extra) > 0 {
+		names := make([]string, 0, len(ai.Extra))
+		for name := range ai.Extra {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fields := make([]string, len(names))
+		for i, name := range names {
+			fields[i] = fmt.Sprintf("%s: %s", name, ai.Extra[name])
+		}
+		sb.WriteString(fmt.Sprintf("- **Extra Fields:** %s\n", strings.Join(fields, "; ")))
+	}
+
+	if ai.Aesthetics != nil {
+		sb.WriteString(fmt.Sprintf("- **Aesthetic Score:** %s\n", ai.Aesthetics))
+		if ai.Aesthetics.Critique != "" {
+			sb.WriteString(fmt.Sprintf("- **Critique:** %s\n", ai.Aesthetics.Critique))
+		}
+	}
+
+	if ai.Provider != "" {
+		sb.WriteString(fmt.Sprintf("- **AI Provider:** %s\n", ai.Provider))
+	}
+	if ai.Model != "" {
+		sb.WriteString(fmt.Sprintf("- **AI Model:** %s\n", ai.Model))
+	}
+	if ai.PromptVersion != "" {
+		sb.WriteString(fmt.Sprintf("- **Prompt Version:** %s\n", ai.PromptVersion))
+	}
 }
 
 // ImageMetadata represents simplified image metadata for listing
 type ImageMetadata struct {
-	ID              string            `json:"id"`
-	Title           string            `json:"title"`
-	Artist          string            `json:"artist"`
-	Category        string            `json:"category"`
-	Type            string            `json:"type,omitempty"`
+	ID              string             `json:"id"`
+	Title           string             `json:"title"`
+	Artist          string             `json:"artist"`
+	Category        string             `json:"category"`           // category path, "primary" or "primary/sub"
+	SubCategory     string             `json:"sub_category,omitempty"`
+	StorageLayout   string             `json:"storage_layout,omitempty"` // where the files live: category, date, artist, flat-hash or cas
+	Type            string             `json:"type,omitempty"`
+	ExternalID      string             `json:"external_id,omitempty"`
 	// 2D fields
-	ThumbnailPath   string            `json:"thumbnail_path,omitempty"`
-	FilePath        string            `json:"file_path,omitempty"`
+	ThumbnailPath   string             `json:"thumbnail_path,omitempty"`
+	SquareThumbnail string             `json:"square_thumbnail,omitempty"`
+	FocalPoint      *models.FocalPoint `json:"focal_point,omitempty"`
+	FilePath        string             `json:"file_path,omitempty"`
+	OriginalFilename string            `json:"original_filename,omitempty"` // client's filename at upload
+	MimeType        string             `json:"mime_type,omitempty"`
+	ColorSpace      string             `json:"color_space,omitempty"`
+	Quality         *models.Quality    `json:"quality,omitempty"`
+	VisualHash      string             `json:"visual_hash,omitempty"`
+	Location        *models.GeoPoint   `json:"location,omitempty"`
+	Width           int                `json:"width,omitempty"`
+	Height          int                `json:"height,omitempty"`
+	Megapixels      float64            `json:"megapixels,omitempty"`
+	AspectRatio     float64            `json:"aspect_ratio,omitempty"`
+	Upscales        map[string]string  `json:"upscales,omitempty"` // factor ("2x") -> rendition path
 	// 3D fields
-	ModelFilePath   string            `json:"model_file_path,omitempty"`
-	ModelFilename   string            `json:"model_filename,omitempty"`
-	Views           map[string]string `json:"views,omitempty"`
+	ModelFilePath   string             `json:"model_file_path,omitempty"`
+	ModelFilename   string             `json:"model_filename,omitempty"`
+	Bounds          *models.Bounds     `json:"bounds,omitempty"` // real-world size
+	Triangles       int                `json:"triangles,omitempty"`
+	LODTags         []string           `json:"lod_tags,omitempty"` // e.g. lod0, game-ready
+	Materials       *models.MaterialSummary `json:"materials,omitempty"` // e.g. "4x 2K PBR textures"
+	ModelInfo       *models.ModelInfo  `json:"model_info,omitempty"` // format, version and up axis
+	FolderPath      string             `json:"folder_path,omitempty"`
+	TurntablePath   string             `json:"turntable_path,omitempty"`
+	ClipPath        string             `json:"clip_path,omitempty"`
+	Views           map[string]string  `json:"views,omitempty"`
+	PosterView      string             `json:"poster_view,omitempty"` // view shown as the thumbnail
 	// Common fields
-	Description     string            `json:"description,omitempty"`
-	Tags            []string          `json:"tags,omitempty"`
-	UploadedAt      string            `json:"uploaded_at"`
+	Description     string             `json:"description,omitempty"`
+	Tags            []string           `json:"tags,omitempty"`
+	Objects         []string           `json:"objects,omitempty"` // detected by the AI analysis
+	Features        []models.Feature   `json:"features,omitempty"` // AI features with their confidence
+	Aesthetics      *models.Aesthetics `json:"aesthetics,omitempty"` // AI aesthetic rating, when enabled
+	AIProvider      string             `json:"ai_provider,omitempty"` // what made the AI analysis
+	AIModel         string             `json:"ai_model,omitempty"`
+	PromptVersion   string             `json:"prompt_version,omitempty"`
+	UploadedAt      string             `json:"uploaded_at"`
+	CapturedAt      string             `json:"captured_at,omitempty"` // from EXIF, 2D only
+	Revision        int                `json:"revision,omitempty"`
+	Visibility      string             `json:"visibility,omitempty"`
+	Project         string             `json:"project,omitempty"`
+	Workflow        string             `json:"workflow,omitempty"` // draft, in-review, approved, rejected
+	LegalHold       bool               `json:"legal_hold,omitempty"` // the image itself is on hold (see ImageService.HeldBy)
+	License         *models.License    `json:"license,omitempty"`
+	Attributes      map[string]string  `json:"attributes,omitempty"`
+	DesignSource    *models.DesignSource `json:"design_source,omitempty"` // design tool document and frame
+	AnnotationCount int                `json:"annotation_count"` // filled in by the API from the annotation store
+	Popularity      *models.Popularity `json:"popularity,omitempty"` // view/download counts, filled in by the API
+	Favorite        bool               `json:"favorite,omitempty"`   // starred by the caller, filled in by the API
+	Series          *SeriesInfo        `json:"series,omitempty"`     // detected burst or sequence, filled in by the API
+	Retention       *RetentionInfo     `json:"retention,omitempty"`  // scheduled deletion, filled in by the API
+	HeldBy          string             `json:"held_by,omitempty"`    // legal hold keeping the image, filled in by the API
+	Renditions      []models.Rendition `json:"renditions,omitempty"` // derivative files, filled in by the API
+	Links           *models.ImageLinks `json:"links,omitempty"`      // fully qualified URLs, filled in by the API
+	AIAnalysis      *models.AIAnalysis `json:"ai_analysis,omitempty"` // full analysis, filled in by the API on request
+	// Tombstone fields, only set when listing with deleted entries included
+	Deleted         bool               `json:"deleted,omitempty"`
+	DeletedAt       string             `json:"deleted_at,omitempty"`
+	DeletedBy       string             `json:"deleted_by,omitempty"`
 }
 
-// GetAllImages parses the index and returns all images
-func (s *IndexService) GetAllImages() ([]*ImageMetadata, error) {
-	content, err := s.ReadIndex()
-	if err != nil {
-		return nil, err
+// PreviewThumbnail returns the data-relative path of the thumbnail that best
+// represents the image. 3D objects use their poster view (see
+// SelectPosterView).
+func (m *ImageMetadata) PreviewThumbnail() string {
+	if m.ThumbnailPath != "" || len(m.Views) == 0 {
+		return m.ThumbnailPath
 	}
 
-	var images []*ImageMetadata
+	view := m.Views[SelectPosterView(m.Views, m.PosterView)]
 
-	// Split by image entries
-	imageRegex := regexp.MustCompile(`(?m)^## Image: (.+)$`)
-	matches := imageRegex.FindAllStringSubmatchIndex(content, -1)
+	dot := strings.LastIndex(view, ".")
+	slash := strings.LastIndex(view, "/")
+	if dot <= slash {
+		return view + "_thumb.jpg"
+	}
+	return view[:dot] + "_thumb.jpg"
+}
 
-	for i, match := range matches {
-		start := match[0]
-		var end int
-		if i < len(matches)-1 {
-			end = matches[i+1][0]
-		} else {
-			end = len(content)
-		}
+// InCategory reports whether the image is filed under category. A primary
+// category also matches all of its sub categories.
+func (m *ImageMetadata) InCategory(category string) bool {
+	return m.Category == category || strings.HasPrefix(m.Category, category+"/")
+}
 
-		section := content[start:end]
-		imageID := content[match[2]:match[3]]
+// HasAttributes reports whether the image has every wanted custom attribute
+// (see models.MatchAttributes)
+func (m *ImageMetadata) HasAttributes(want map[string]string) bool {
+	return models.MatchAttributes(m.Attributes, want)
+}
 
-		img := &ImageMetadata{
-			ID: imageID,
-		}
+// UploadedTime parses the Uploaded timestamp written to the index
+func (m *ImageMetadata) UploadedTime() (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04:05", m.UploadedAt, time.Local)
+}
 
-		// Parse fields
-		img.Title = extractField(section, "Title")
-		img.Artist = extractField(section, "Artist")
-		img.Category = extractField(section, "Category")
-		img.Type = extractField(section, "Type")
-		img.ThumbnailPath = normalizePath(extractField(section, "Thumbnail"))
-		img.FilePath = normalizePath(extractField(section, "File Path"))
-		img.ModelFilePath = normalizePath(extractField(section, "Model File"))
-		img.ModelFilename = extractField(section, "Model Filename")
-		img.Description = extractField(section, "Description")
-		img.UploadedAt = extractField(section, "Uploaded")
+// CapturedTime parses the Captured timestamp written to the index
+func (m *ImageMetadata) CapturedTime() (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04:05", m.CapturedAt, time.Local)
+}
 
-		// Extract tags
-		if tagsStr := extractField(section, "Manual Tags"); tagsStr != "" {
-			img.Tags = strings.Split(tagsStr, ", ")
-		}
+// GetAllImages parses the index and returns all images that have not been deleted
+func (s *IndexService) GetAllImages() ([]*ImageMetadata, error) {
+	return s.GetImages(false)
+}
 
-		// Extract views for 3D objects
-		if img.Type == "3D" {
-			img.Views = extractViews(section)
-		}
+// GetImages streams the index and returns all images. With includeDeleted
+// the tombstones of deleted images are returned as well, for audit and sync.
+func (s *IndexService) GetImages(includeDeleted bool) ([]*ImageMetadata, error) {
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
 
-		images = append(images, img)
+	var images []*ImageMetadata
+	for _, f := range files {
+		err := scanIndexFile(f.path, func(img *ImageMetadata) {
+			if !img.Deleted || includeDeleted {
+				images = append(images, img)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read index: %w", err)
+		}
 	}
 
 	return images, nil
 }
 
+// scanIndexFile streams one index file (see scanIndex)
+func scanIndexFile(path string, fn func(*ImageMetadata)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return scanIndex(file, fn)
+}
+
 // GetImageByID finds a specific image in the index
 func (s *IndexService) GetImageByID(imageID string) (*ImageMetadata, error) {
 	images, err := s.GetAllImages()
@@ -249,44 +1180,26 @@ func (s *IndexService) GetImageByID(imageID string) (*ImageMetadata, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("image not found: %s", imageID)
+	return nil, fmt.Errorf("%w: %s", ErrImageNotFound, imageID)
 }
 
-// extractField extracts a field value from markdown content
-func extractField(content, fieldName string) string {
-	pattern := fmt.Sprintf(`\*\*%s:\*\*\s*(.+)`, regexp.QuoteMeta(fieldName))
-	re := regexp.MustCompile(pattern)
-	if matches := re.FindStringSubmatch(content); len(matches) > 1 {
-		return strings.TrimSpace(matches[1])
+// GetImageByExternalID finds an image by its client-supplied external ID
+func (s *IndexService) GetImageByExternalID(externalID string) (*ImageMetadata, error) {
+	images, err := s.GetAllImages()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, img := range images {
+		if img.ExternalID == externalID {
+			return img, nil
+		}
 	}
-	return ""
+
+	return nil, fmt.Errorf("%w for external ID: %s", ErrImageNotFound, externalID)
 }
 
 // normalizePath converts Windows backslashes to forward slashes for web URLs
 func normalizePath(path string) string {
 	return strings.ReplaceAll(path, "\\", "/")
 }
-
-// extractViews extracts the Views section from 3D object markdown
-func extractViews(content string) map[string]string {
-	views := make(map[string]string)
-
-	// Find the Views section
-	viewsRegex := regexp.MustCompile(`(?s)\*\*Views:\*\*\n((?:- .+\n)+)`)
-	if matches := viewsRegex.FindStringSubmatch(content); len(matches) > 1 {
-		viewsSection := matches[1]
-
-		// Parse each view line (e.g., "- front: categories\...")
-		lineRegex := regexp.MustCompile(`- (\w+): (.+)`)
-		lines := strings.Split(viewsSection, "\n")
-		for _, line := range lines {
-			if lineMatches := lineRegex.FindStringSubmatch(line); len(lineMatches) > 2 {
-				viewName := lineMatches[1]
-				viewPath := normalizePath(strings.TrimSpace(lineMatches[2]))
-				views[viewName] = viewPath
-			}
-		}
-	}
-
-	return views
-}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -355,3 +356,423 @@ func TestBuildMarkdownEntry_NoManualTags(t *testing.T) {
 		t.Error("entry should not contain Manual Tags section when ManualTags is empty")
 	}
 }
+
+func TestGetImageByExternalID(t *testing.T) {
+	tempDir := t.TempDir()
+	svc := NewIndexService(tempDir)
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	images := []*models.Image{
+		{ID: "img-001", Title: "First", Type: models.ImageType2D, ExternalID: "PIM-42", UploadedAt: time.Now()},
+		{ID: "img-002", Title: "Second", Type: models.ImageType2D, UploadedAt: time.Now()},
+	}
+	for _, img := range images {
+		if err := svc.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	found, err := svc.GetImageByExternalID("PIM-42")
+	if err != nil {
+		t.Fatalf("GetImageByExternalID failed: %v", err)
+	}
+	if found.ID != "img-001" {
+		t.Errorf("expected img-001, got %s", found.ID)
+	}
+	if found.ExternalID != "PIM-42" {
+		t.Errorf("expected external ID to be parsed, got %q", found.ExternalID)
+	}
+
+	if _, err := svc.GetImageByExternalID("missing"); err == nil {
+		t.Error("expected error for unknown external ID, got nil")
+	}
+}
+
+func TestDeleteImage_Tombstone(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	for _, id := range []string{"keep", "gone"} {
+		img := &models.Image{ID: id, Title: id, Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), FilePath: "categories/animals/" + id + ".jpg"}
+		if err := svc.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	deleted, err := svc.DeleteImage("gone", "curator")
+	if err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+	if deleted.FilePath != "categories/animals/gone.jpg" {
+		t.Errorf("expected previous metadata, got %+v", deleted)
+	}
+
+	images, err := svc.GetAllImages()
+	if err != nil {
+		t.Fatalf("GetAllImages failed: %v", err)
+	}
+	if len(images) != 1 || images[0].ID != "keep" {
+		t.Errorf("expected only the kept image, got %d images", len(images))
+	}
+
+	all, err := svc.GetImages(true)
+	if err != nil {
+		t.Fatalf("GetImages failed: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected 2 entries including the tombstone, got %d", len(all))
+	}
+	if tomb := all[1]; !tomb.Deleted || tomb.DeletedBy != "curator" || tomb.DeletedAt == "" || tomb.Title != "" {
+		t.Errorf("unexpected tombstone %+v", tomb)
+	}
+
+	if _, err := svc.GetImageByID("gone"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("expected ErrImageNotFound for deleted image, got %v", err)
+	}
+	if _, err := svc.DeleteImage("gone", "curator"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("expected ErrImageNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestUpdateImage_Revision(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	img := &models.Image{ID: "img-1", Title: "Old", Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		AIAnalysis: &models.AIAnalysis{Description: "A cat", PrimaryCategory: "animals"}}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	title := "New"
+	tags := []string{"cat", "cute"}
+	updated, err := svc.UpdateImage("img-1", 1, ImageUpdate{Title: &title, Tags: &tags})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if updated.Revision != 2 || updated.Title != "New" || len(updated.Tags) != 2 {
+		t.Errorf("unexpected update result %+v", updated)
+	}
+	if updated.Artist != "Alice" || updated.Description != "A cat" {
+		t.Errorf("untouched fields changed: %+v", updated)
+	}
+
+	// A writer still holding revision 1 must not clobber the update
+	artist := "Bob"
+	if _, err := svc.UpdateImage("img-1", 1, ImageUpdate{Artist: &artist}); !errors.Is(err, ErrRevisionMismatch) {
+		t.Fatalf("expected ErrRevisionMismatch, got %v", err)
+	}
+
+	stored, err := svc.GetImageByID("img-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if stored.Artist != "Alice" || stored.Revision != 2 {
+		t.Errorf("stale update was applied: %+v", stored)
+	}
+
+	content, _ := svc.ReadIndex()
+	if !strings.Contains(content, "- **Primary Category:** animals") {
+		t.Error("AI analysis section was not preserved")
+	}
+}
+
+func TestGetAllImages_SubCategory(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	img := &models.Image{ID: "img-1", Title: "Lion", Artist: "Alice", Category: "animals/big-cats", Type: models.ImageType2D, UploadedAt: time.Now(),
+		AIAnalysis: &models.AIAnalysis{PrimaryCategory: "animals", SubCategory: "big-cats"}}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	stored, err := svc.GetImageByID("img-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if stored.Category != "animals/big-cats" || stored.SubCategory != "big-cats" {
+		t.Errorf("unexpected categories %q / %q", stored.Category, stored.SubCategory)
+	}
+	if !stored.InCategory("animals") || !stored.InCategory("animals/big-cats") || stored.InCategory("animal") {
+		t.Error("InCategory did not match the category hierarchy")
+	}
+}
+
+func TestLicense_RoundTripAndClear(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	license := &models.License{Type: "editorial", RightsHolder: "Acme", ExpiresOn: "2030-01-31", Restrictions: "No print"}
+	img := &models.Image{ID: "img-1", Title: "T", Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), License: license}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	stored, err := svc.GetImageByID("img-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if stored.License == nil || *stored.License != *license {
+		t.Fatalf("expected license %+v, got %+v", license, stored.License)
+	}
+
+	// Replacing the license clears fields left empty
+	updated, err := svc.UpdateImage("img-1", 0, ImageUpdate{License: &models.License{Type: "CC-BY-4.0"}})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if updated.License == nil || *updated.License != (models.License{Type: "CC-BY-4.0"}) {
+		t.Errorf("unexpected license after update: %+v", updated.License)
+	}
+
+	updated, err = svc.UpdateImage("img-1", 0, ImageUpdate{License: &models.License{}})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if updated.License != nil {
+		t.Errorf("expected license to be removed, got %+v", updated.License)
+	}
+}
+
+func TestDesignSource_RoundTrip(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	source := &models.DesignSource{Tool: " Figma ", DocumentID: "AbC123", DocumentName: "Checkout *v2*", NodeID: "12:34", NodeName: "Login", Page: "Screens", Version: "v7"}
+	source.Normalize()
+	if source.URL != "https://www.figma.com/design/AbC123?node-id=12-34" {
+		t.Errorf("unexpected deep link %s", source.URL)
+	}
+	img := &models.Image{ID: "img-1", Title: "Login", Artist: "A", Category: "ui", Type: models.ImageType2D, UploadedAt: time.Now(), DesignSource: source}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	stored, err := svc.GetImageByID("img-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if stored.DesignSource == nil || *stored.DesignSource != *source {
+		t.Fatalf("expected design source %+v, got %+v", source, stored.DesignSource)
+	}
+
+	// Metadata updates keep the source
+	title := "Login (final)"
+	updated, err := svc.UpdateImage("img-1", 0, ImageUpdate{Title: &title})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if updated.DesignSource == nil || updated.DesignSource.NodeID != "12:34" {
+		t.Errorf("expected the design source to survive an update, got %+v", updated.DesignSource)
+	}
+}
+
+func TestGetAllImages_Dimensions(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	img := &models.Image{ID: "wide", Title: "T", Artist: "A", Category: "landscape", Type: models.ImageType2D, UploadedAt: time.Now()}
+	img.SetDimensions(3840, 2160)
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	stored, err := svc.GetImageByID("wide")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if stored.Width != 3840 || stored.Height != 2160 || stored.Megapixels != 8.29 || stored.AspectRatio != 1.7778 {
+		t.Errorf("unexpected dimensions %dx%d, %v MP, ratio %v", stored.Width, stored.Height, stored.Megapixels, stored.AspectRatio)
+	}
+
+	// Entries without stored stats derive them from the dimensions
+	section := "## Image: old\n\n**Dimensions:** 1000x500\n"
+	old := parseImageSection("old", section)
+	if old.Megapixels != 0.5 || old.AspectRatio != 2 {
+		t.Errorf("expected derived stats, got %v MP, ratio %v", old.Megapixels, old.AspectRatio)
+	}
+}
+
+func TestFocalPoint_RoundTripAndOverride(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	img := &models.Image{
+		ID: "img-1", Title: "T", Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		FilePath: "categories/animals/img-1.png", ThumbnailPath: "categories/animals/img-1_thumb.jpg",
+		SquareThumbnail: "categories/animals/img-1_square.jpg",
+		FocalPoint:      &models.FocalPoint{X: 0.25, Y: 0.5, Source: models.FocalSourceSaliency},
+	}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	stored, err := svc.GetImageByID("img-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if stored.SquareThumbnail != img.SquareThumbnail {
+		t.Errorf("expected square thumbnail %s, got %s", img.SquareThumbnail, stored.SquareThumbnail)
+	}
+	if stored.FocalPoint == nil || *stored.FocalPoint != *img.FocalPoint {
+		t.Fatalf("expected focal point %+v, got %+v", img.FocalPoint, stored.FocalPoint)
+	}
+
+	manual := &models.FocalPoint{X: 0.8, Y: 0.1, Source: models.FocalSourceManual}
+	updated, err := svc.UpdateImage("img-1", 0, ImageUpdate{FocalPoint: manual})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if updated.FocalPoint == nil || *updated.FocalPoint != *manual {
+		t.Errorf("expected focal point %+v, got %+v", manual, updated.FocalPoint)
+	}
+
+	obj := &models.Image{ID: "obj-1", Title: "T", Artist: "A", Category: "animals", Type: models.ImageType3D, UploadedAt: time.Now()}
+	if err := svc.AppendToIndex(obj); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	if _, err := svc.UpdateImage("obj-1", 0, ImageUpdate{FocalPoint: manual}); !errors.Is(err, ErrFocalPointUnsupported) {
+		t.Errorf("expected ErrFocalPointUnsupported for a 3D object, got %v", err)
+	}
+}
+
+func TestAttributes_RoundTripAndMerge(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	img := &models.Image{
+		ID: "img-1", Title: "T", Artist: "A", Category: "products", Type: models.ImageType2D, UploadedAt: time.Now(),
+		Attributes: map[string]string{"client": "Acme", "sku": "A-100"},
+	}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	stored, err := svc.GetImageByID("img-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if len(stored.Attributes) != 2 || stored.Attributes["sku"] != "A-100" {
+		t.Fatalf("unexpected attributes %v", stored.Attributes)
+	}
+	if !stored.HasAttributes(map[string]string{"client": "acme", "sku": ""}) || stored.HasAttributes(map[string]string{"project": ""}) {
+		t.Error("unexpected attribute matching")
+	}
+
+	// Updates merge: set a new attribute, change one and remove another
+	updated, err := svc.UpdateImage("img-1", 0, ImageUpdate{Attributes: map[string]string{"project": "P-7", "client": "Globex", "sku": ""}})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	want := map[string]string{"client": "Globex", "project": "P-7"}
+	if len(updated.Attributes) != len(want) || !models.MatchAttributes(updated.Attributes, want) {
+		t.Errorf("expected attributes %v, got %v", want, updated.Attributes)
+	}
+}
+
+func TestImageForOriginal(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	photo := &models.Image{ID: "img-1", Title: "T", Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		FilePath: "categories/animals/img-1.jpg", ThumbnailPath: "categories/animals/img-1_thumb.jpg"}
+	if err := svc.AppendToIndex(photo); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	if id, ok := svc.ImageForOriginal("categories/animals/img-1.jpg"); !ok || id != "img-1" {
+		t.Errorf("expected img-1 for its original, got %q %v", id, ok)
+	}
+	if _, ok := svc.ImageForOriginal("categories/animals/img-1_thumb.jpg"); ok {
+		t.Error("thumbnails are not originals")
+	}
+
+	// The lookup follows index changes
+	object := &models.Image{ID: "obj-1", Title: "O", Artist: "A", Category: "products", Type: models.ImageType3D, UploadedAt: time.Now(),
+		FolderPath: "categories/products/obj-1", ModelFilePath: "categories/products/obj-1/model.glb", ModelFilename: "model.glb",
+		Views: map[string]string{"front": "categories/products/obj-1/front.png"}}
+	if err := svc.AppendToIndex(object); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	for _, path := range []string{"categories/products/obj-1/model.glb", "categories/products/obj-1/front.png"} {
+		if id, ok := svc.ImageForOriginal(path); !ok || id != "obj-1" {
+			t.Errorf("expected obj-1 for %s, got %q %v", path, id, ok)
+		}
+	}
+}
+
+func TestImageForFile(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	photo := &models.Image{ID: "img-1", Title: "T", Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		FilePath: "categories/animals/img-1.jpg", ThumbnailPath: "categories/animals/img-1_thumb.jpg"}
+	object := &models.Image{ID: "obj-1", Title: "O", Artist: "A", Category: "products", Type: models.ImageType3D, UploadedAt: time.Now(),
+		FolderPath: "categories/products/obj-1", ModelFilePath: "categories/products/obj-1/model.glb",
+		Views: map[string]string{"front": "categories/products/obj-1/front.png"}}
+	for _, img := range []*models.Image{photo, object} {
+		if err := svc.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	for path, want := range map[string]string{
+		"categories/animals/img-1.jpg":                   "img-1",
+		"categories/animals/img-1_thumb.jpg":             "img-1",
+		"categories/animals/img-1_square.jpg":            "img-1",
+		"categories/animals/img-1_x2.jpg":                "img-1",
+		"categories/products/obj-1/front_thumb.jpg":      "obj-1",
+		"categories/products/obj-1/materials/albedo.png": "obj-1",
+	} {
+		if id, ok := svc.ImageForFile(path); !ok || id != want {
+			t.Errorf("ImageForFile(%s) = %q %v, want %s", path, id, ok, want)
+		}
+	}
+	for _, path := range []string{"index.md", "categories/animals/img-2.jpg", "categories/products", "temp/img-1.jpg"} {
+		if id, ok := svc.ImageForFile(path); ok {
+			t.Errorf("expected %s to belong to no image, got %s", path, id)
+		}
+	}
+}
+
+func TestAppendToIndex_OriginalFilenameAndMimeType(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	img := &models.Image{ID: "img-1", Title: "T", Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+		FilePath: "categories/animals/img-1.jpg", OriginalFilename: "Sunset at the pier.jpg", MimeType: "image/jpeg"}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	metadata, err := svc.GetImageByID("img-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if metadata.OriginalFilename != "Sunset at the pier.jpg" || metadata.MimeType != "image/jpeg" {
+		t.Errorf("expected the original filename and MIME type, got %q and %q", metadata.OriginalFilename, metadata.MimeType)
+	}
+}
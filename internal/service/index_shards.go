@@ -0,0 +1,438 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// indexShardDir holds the shards and manifest of a sharded index, inside the
+// data directory
+const indexShardDir = "index"
+
+// shardManifestFile lists the shards of a sharded index
+const shardManifestFile = "manifest.json"
+
+// uncategorizedShard holds images without a category, and the tombstones of
+// images deleted before the index was sharded
+const uncategorizedShard = "uncategorized"
+
+// IndexShard is one file of a sharded index, holding the images of one
+// primary category
+type IndexShard struct {
+	Category  string    `json:"category"` // primary category
+	File      string    `json:"file"`     // relative to the shard directory
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ShardManifest lists the shards of a sharded index and the shard each
+// image is in, so writes go straight to the image's shard
+type ShardManifest struct {
+	Shards []IndexShard      `json:"shards"`
+	Images map[string]string `json:"images"` // image ID -> shard category
+}
+
+// shardPath returns the file of a shard
+func (m *ShardManifest) shardPath(shardDir, category string) (string, bool) {
+	for _, shard := range m.Shards {
+		if shard.Category == category {
+			return filepath.Join(shardDir, shard.File), true
+		}
+	}
+	return "", false
+}
+
+// indexFile is a file of the index: index.md, or a shard
+type indexFile struct {
+	path     string
+	category string // primary category of a shard; empty for index.md
+}
+
+// EnableSharding splits the index into a file per primary category under
+// DATA_DIR/index, listed in a manifest. Appends, rewrites and migrations
+// then touch one shard under that shard's lock, so writers of different
+// shards run in parallel; only the journal append and manifest updates take
+// the index lock, briefly. Searches limited to a category read only its
+// shard. It must be called before InitializeIndex, which splits an existing
+// index.md.
+func (s *IndexService) EnableSharding() {
+	s.shardDir = filepath.Join(filepath.Dir(s.indexPath), indexShardDir)
+}
+
+// Sharded reports whether the index is split into shards
+func (s *IndexService) Sharded() bool {
+	return s.shardDir != ""
+}
+
+// Shards returns the shards of a sharded index, by category
+func (s *IndexService) Shards() ([]IndexShard, error) {
+	manifest, err := s.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	return append([]IndexShard(nil), manifest.Shards...), nil
+}
+
+// shardCategory returns the shard an image of category belongs to: its
+// primary category
+func shardCategory(category string) string {
+	primary, _, _ := strings.Cut(strings.TrimSpace(category), "/")
+	if primary == "" {
+		return uncategorizedShard
+	}
+	return primary
+}
+
+// shardFileName returns the file name of a category's shard; categories
+// that aren't safe file names are hashed
+func shardFileName(category string) string {
+	if ValidCategoryPath(category) && !strings.Contains(category, "/") {
+		return category + ".md"
+	}
+	sum := sha1.Sum([]byte(category))
+	return "category-" + hex.EncodeToString(sum[:6]) + ".md"
+}
+
+// indexHeaderContent returns the header a new index file starts with
+func indexHeaderContent(title string, version int) string {
+	return fmt.Sprintf(`# %s
+Last Updated: %s
+%s %d
+
+---
+`, title, time.Now().Format("2006-01-02 15:04:05"), schemaVersionField, version)
+}
+
+func (s *IndexService) manifestPath() string {
+	return filepath.Join(s.shardDir, shardManifestFile)
+}
+
+// loadManifest returns the shard manifest, read again whenever the file
+// changed, so shards and images added by other instances are seen. The
+// manifest is shared and must not be modified; see updateManifest.
+func (s *IndexService) loadManifest() (*ShardManifest, error) {
+	info, err := os.Stat(s.manifestPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shard manifest: %w", err)
+	}
+	s.manifestMutex.Lock()
+	defer s.manifestMutex.Unlock()
+	if s.manifest != nil && os.SameFile(info, s.manifestInfo) &&
+		info.Size() == s.manifestInfo.Size() && info.ModTime().Equal(s.manifestInfo.ModTime()) {
+		return s.manifest, nil
+	}
+
+	manifest, info, err := s.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	s.manifest, s.manifestInfo = manifest, info
+	return manifest, nil
+}
+
+// readManifest reads and parses the shard manifest file
+func (s *IndexService) readManifest() (*ShardManifest, os.FileInfo, error) {
+	file, err := os.Open(s.manifestPath())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read shard manifest: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read shard manifest: %w", err)
+	}
+	var manifest ShardManifest
+	if err := json.NewDecoder(file).Decode(&manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse shard manifest: %w", err)
+	}
+	return &manifest, info, nil
+}
+
+// updateManifest applies fn to a fresh copy of the shard manifest and saves
+// it if fn reports a change. Caller must hold the index lock.
+func (s *IndexService) updateManifest(fn func(manifest *ShardManifest) (bool, error)) error {
+	manifest, _, err := s.readManifest()
+	if err != nil {
+		return err
+	}
+	changed, err := fn(manifest)
+	if err != nil || !changed {
+		return err
+	}
+	return s.saveManifest(manifest)
+}
+
+// saveManifest replaces the shard manifest atomically. Caller must hold the
+// index lock.
+func (s *IndexService) saveManifest(manifest *ShardManifest) error {
+	sort.Slice(manifest.Shards, func(i, j int) bool { return manifest.Shards[i].Category < manifest.Shards[j].Category })
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := s.manifestPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write shard manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.manifestPath()); err != nil {
+		return fmt.Errorf("failed to replace shard manifest: %w", err)
+	}
+	if info, err := os.Stat(s.manifestPath()); err == nil {
+		s.manifestMutex.Lock()
+		s.manifest, s.manifestInfo = manifest, info
+		s.manifestMutex.Unlock()
+	}
+	return nil
+}
+
+// files returns the files of the index: index.md, or every shard
+func (s *IndexService) files() ([]indexFile, error) {
+	if !s.Sharded() {
+		return []indexFile{{path: s.indexPath}}, nil
+	}
+	manifest, err := s.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]indexFile, len(manifest.Shards))
+	for i, shard := range manifest.Shards {
+		files[i] = indexFile{path: filepath.Join(s.shardDir, shard.File), category: shard.Category}
+	}
+	return files, nil
+}
+
+// shardFile returns the file of the shard images of category go to, which
+// may not exist yet (see addToShard)
+func (s *IndexService) shardFile(category string) string {
+	return filepath.Join(s.shardDir, shardFileName(shardCategory(category)))
+}
+
+// addToShard records that an image is in the shard of category, creating
+// the shard if there is none yet. Caller must hold the index lock.
+func (s *IndexService) addToShard(imageID, category string) error {
+	category = shardCategory(category)
+	return s.updateManifest(func(manifest *ShardManifest) (bool, error) {
+		changed := false
+		if _, ok := manifest.shardPath(s.shardDir, category); !ok {
+			shard := IndexShard{Category: category, File: shardFileName(category), CreatedAt: time.Now()}
+			path := filepath.Join(s.shardDir, shard.File)
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				if err := os.WriteFile(path, []byte(indexHeaderContent("Image Warehouse Index: "+category, IndexSchemaVersion)), 0644); err != nil {
+					return false, fmt.Errorf("failed to create index shard: %w", err)
+				}
+			}
+			manifest.Shards = append(manifest.Shards, shard)
+			changed = true
+		}
+		if manifest.Images == nil {
+			manifest.Images = make(map[string]string)
+		}
+		if manifest.Images[imageID] != category {
+			manifest.Images[imageID] = category
+			changed = true
+		}
+		return changed, nil
+	})
+}
+
+// fileOf returns the file holding an image's section, ErrImageNotFound if no
+// file does. A sharded index looks the image up in the manifest.
+func (s *IndexService) fileOf(imageID string) (string, error) {
+	if !s.Sharded() {
+		return s.indexPath, nil
+	}
+	manifest, err := s.loadManifest()
+	if err != nil {
+		return "", err
+	}
+	if category, ok := manifest.Images[imageID]; ok {
+		if path, ok := manifest.shardPath(s.shardDir, category); ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrImageNotFound, imageID)
+}
+
+// fileForSection returns the file a journaled section belongs in: the file
+// holding the image, or else the shard of its category
+func (s *IndexService) fileForSection(imageID, section string) string {
+	if path, err := s.fileOf(imageID); err == nil {
+		return path
+	}
+	return s.shardFile(parseImageSection(imageID, section).Category)
+}
+
+// lockFor returns the lock guarding writes to an index file: its shard's
+// lock, or the index lock when unsharded
+func (s *IndexService) lockFor(path string) indexLock {
+	if !s.Sharded() {
+		return s.lock
+	}
+	s.shardLocksMutex.Lock()
+	defer s.shardLocksMutex.Unlock()
+	if s.shardLocks == nil {
+		s.shardLocks = make(map[string]indexLock)
+	}
+	lock, ok := s.shardLocks[path]
+	if !ok {
+		if s.coordinator != nil {
+			lock = s.coordinator.Locker("index/" + filepath.Base(path))
+		} else {
+			lock = newFileLock(path + ".lock")
+		}
+		s.shardLocks[path] = lock
+	}
+	return lock
+}
+
+// lockFiles takes the write locks of index files, in path order so writers
+// locking several shards can't deadlock, and returns the function that
+// releases them
+func (s *IndexService) lockFiles(paths ...string) (func(), error) {
+	locks := make(map[indexLock]bool)
+	var ordered []indexLock
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	for _, path := range sorted {
+		if lock := s.lockFor(path); !locks[lock] {
+			locks[lock] = true
+			ordered = append(ordered, lock)
+		}
+	}
+
+	unlock := func(held []indexLock) {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].Unlock()
+		}
+	}
+	for i, lock := range ordered {
+		if err := lock.Lock(); err != nil {
+			unlock(ordered[:i])
+			return nil, fmt.Errorf("failed to acquire lock: %w", err)
+		}
+	}
+	return func() { unlock(ordered) }, nil
+}
+
+// shardIndex creates the shards of a newly sharded index, splitting index.md
+// if there is one and keeping it as index.md.unsharded-<time>.bak. A
+// manifest written before it recorded the images' shards gets them.
+func (s *IndexService) shardIndex() error {
+	if err := s.lock.Lock(); err != nil {
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer s.lock.Unlock()
+
+	if _, err := os.Stat(s.manifestPath()); err == nil {
+		return s.updateManifest(func(manifest *ShardManifest) (bool, error) {
+			if manifest.Images != nil {
+				return false, nil
+			}
+			manifest.Images = make(map[string]string)
+			for _, shard := range manifest.Shards {
+				sections, err := s.fileSections(filepath.Join(s.shardDir, shard.File))
+				if err != nil {
+					return false, fmt.Errorf("failed to read index: %w", err)
+				}
+				for _, section := range sections {
+					manifest.Images[section.id] = shard.Category
+				}
+			}
+			return true, nil
+		})
+	}
+	if err := os.MkdirAll(s.shardDir, 0755); err != nil {
+		return fmt.Errorf("failed to create shard directory: %w", err)
+	}
+
+	content, err := os.ReadFile(s.indexPath)
+	if os.IsNotExist(err) {
+		return s.saveManifest(&ShardManifest{Shards: []IndexShard{}, Images: map[string]string{}})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	// Shards keep the schema version of the index, so MigrateIndex still
+	// upgrades them
+	version := indexSchemaVersion(string(content))
+	var order []string
+	shards := make(map[string]*strings.Builder)
+	images := make(map[string]string)
+	migrateSections(string(content), func(section string) string {
+		id, _, _ := strings.Cut(strings.TrimPrefix(section, imageHeader), "\n")
+		id = strings.TrimSpace(id)
+		category := shardCategory(parseImageSection(id, section).Category)
+		images[id] = category
+		sb, ok := shards[category]
+		if !ok {
+			sb = &strings.Builder{}
+			sb.WriteString(indexHeaderContent("Image Warehouse Index: "+category, version))
+			shards[category] = sb
+			order = append(order, category)
+		}
+		sb.WriteString(section)
+		return section
+	})
+
+	manifest := &ShardManifest{Shards: []IndexShard{}, Images: images}
+	for _, category := range order {
+		shard := IndexShard{Category: category, File: shardFileName(category), CreatedAt: time.Now()}
+		if err := s.writeShard(filepath.Join(s.shardDir, shard.File), shards[category].String()); err != nil {
+			return err
+		}
+		manifest.Shards = append(manifest.Shards, shard)
+	}
+	if err := s.saveManifest(manifest); err != nil {
+		return err
+	}
+
+	backupPath := fmt.Sprintf("%s.unsharded-%s.bak", s.indexPath, time.Now().Format("20060102-150405"))
+	if err := os.Rename(s.indexPath, backupPath); err != nil {
+		return fmt.Errorf("failed to set the unsharded index aside: %w", err)
+	}
+	return nil
+}
+
+// writeShard writes a new shard under its lock
+func (s *IndexService) writeShard(path, content string) error {
+	unlock, err := s.lockFiles(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.writeIndex(path, content)
+}
+
+// indexStamp returns the latest modification time and total size of the
+// index files, which change with every write
+func (s *IndexService) indexStamp() (time.Time, int64, error) {
+	files, err := s.files()
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	var latest time.Time
+	var size int64
+	for _, f := range files {
+		info, err := os.Stat(f.path)
+		if err != nil {
+			return time.Time{}, 0, fmt.Errorf("failed to stat index: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		size += info.Size()
+	}
+	if s.Sharded() {
+		if info, err := os.Stat(s.manifestPath()); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, size, nil
+}
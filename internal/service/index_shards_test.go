@@ -0,0 +1,328 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestShardedIndex_AppendsUpdatesAndDeletesPerShard(t *testing.T) {
+	dataDir := t.TempDir()
+	indexService := NewIndexService(dataDir)
+	indexService.EnableSharding()
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	for _, img := range []*models.Image{
+		{ID: "cat", Title: "Cat", Category: "animals/cats", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "dog", Title: "Dog", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "tower", Title: "Tower", Category: "architecture", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "blank", Title: "Blank", Type: models.ImageType2D, UploadedAt: time.Now()},
+	} {
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dataDir, "index.md")); !os.IsNotExist(err) {
+		t.Errorf("expected no index.md in a sharded index, got %v", err)
+	}
+	shards, err := indexService.Shards()
+	if err != nil {
+		t.Fatalf("Shards failed: %v", err)
+	}
+	var names []string
+	for _, shard := range shards {
+		names = append(names, shard.Category+"="+shard.File)
+	}
+	if got := strings.Join(names, ","); got != "animals=animals.md,architecture=architecture.md,uncategorized=uncategorized.md" {
+		t.Errorf("unexpected shards %s", got)
+	}
+	animals, _ := os.ReadFile(filepath.Join(dataDir, "index", "animals.md"))
+	if !strings.Contains(string(animals), "## Image: cat\n") || !strings.Contains(string(animals), "## Image: dog\n") || strings.Contains(string(animals), "## Image: tower") {
+		t.Errorf("unexpected animals shard:\n%s", animals)
+	}
+
+	// Edits and deletes rewrite only the shard holding the image
+	before, _ := os.ReadFile(filepath.Join(dataDir, "index", "architecture.md"))
+	title := "Big Dog"
+	if _, err := indexService.UpdateImage("dog", 0, ImageUpdate{Title: &title}); err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if _, err := indexService.DeleteImage("cat", "test"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+	if after, _ := os.ReadFile(filepath.Join(dataDir, "index", "architecture.md")); string(after) != string(before) {
+		t.Errorf("expected the architecture shard to be untouched")
+	}
+	if img, err := indexService.GetImageByID("dog"); err != nil || img.Title != "Big Dog" {
+		t.Errorf("unexpected updated image %+v (%v)", img, err)
+	}
+	images, err := indexService.GetImages(false)
+	if err != nil || len(images) != 3 {
+		t.Errorf("expected 3 live images across shards, got %d (%v)", len(images), err)
+	}
+
+	content, err := indexService.ReadSections(SectionSelection{Categories: []string{"architecture"}})
+	if err != nil {
+		t.Fatalf("ReadSections failed: %v", err)
+	}
+	if content != strings.TrimPrefix(string(before), indexHeader(string(before))) {
+		t.Errorf("expected only the architecture shard's sections:\n%s", content)
+	}
+}
+
+func TestShardedIndex_SplitsExistingIndex(t *testing.T) {
+	dataDir := t.TempDir()
+	unsharded := NewIndexService(dataDir)
+	if err := unsharded.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	for _, img := range []*models.Image{
+		{ID: "cat", Title: "Cat", Category: "animals/cats", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "tower", Title: "Tower", Category: "architecture", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "gone", Title: "Gone", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()},
+	} {
+		if err := unsharded.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+	if _, err := unsharded.DeleteImage("gone", "test"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+	old, _ := os.ReadFile(filepath.Join(dataDir, "index.md"))
+
+	indexService := NewIndexService(dataDir)
+	indexService.EnableSharding()
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dataDir, "index.md.unsharded-*.bak"))
+	if len(backups) != 1 {
+		t.Fatalf("expected the unsharded index to be kept, got %v", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != string(old) {
+		t.Errorf("expected the backup to hold the unsharded index")
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "index.md")); !os.IsNotExist(err) {
+		t.Errorf("expected index.md to be set aside, got %v", err)
+	}
+
+	images, err := indexService.GetImages(false)
+	if err != nil || len(images) != 2 {
+		t.Fatalf("expected the 2 live images, got %d (%v)", len(images), err)
+	}
+	if _, err := indexService.GetImageByID("gone"); err == nil {
+		t.Errorf("expected the deleted image to stay deleted")
+	}
+	uncategorized, _ := os.ReadFile(filepath.Join(dataDir, "index", "uncategorized.md"))
+	if !strings.Contains(string(uncategorized), "## Image: gone\n") {
+		t.Errorf("expected the tombstone in the uncategorized shard:\n%s", uncategorized)
+	}
+
+	// Initializing again leaves the shards alone
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	if images, _ := indexService.GetImages(false); len(images) != 2 {
+		t.Errorf("expected the shards to survive a restart, got %d images", len(images))
+	}
+}
+
+func TestShardedIndex_MigratesEachShard(t *testing.T) {
+	dataDir := t.TempDir()
+
+	// An unversioned index from before revisions, split into shards
+	indexService := NewIndexService(dataDir)
+	var entries string
+	for _, img := range []*models.Image{
+		{ID: "cat", Title: "Cat", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "tower", Title: "Tower", Category: "architecture", Type: models.ImageType2D, UploadedAt: time.Now()},
+	} {
+		entries += indexService.buildMarkdownEntry(img)
+	}
+	entries = regexp.MustCompile(`(?m)^\*\*Revision:\*\*.*\n`).ReplaceAllString(entries, "")
+	old := "# Image Warehouse Index\nLast Updated: 2024-01-01 00:00:00\n\n---\n" + entries
+	if err := os.WriteFile(filepath.Join(dataDir, "index.md"), []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	indexService.EnableSharding()
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	from, err := indexService.MigrateIndex()
+	if err != nil || from != 0 {
+		t.Fatalf("expected a migration from version 0, got %d (%v)", from, err)
+	}
+	for _, name := range []string{"animals.md", "architecture.md"} {
+		path := filepath.Join(dataDir, "index", name)
+		content, _ := os.ReadFile(path)
+		if indexSchemaVersion(string(content)) != IndexSchemaVersion || !strings.Contains(string(content), "**Revision:** 1\n") {
+			t.Errorf("expected %s to be migrated:\n%s", name, content)
+		}
+		if backups, _ := filepath.Glob(path + ".v0-*.bak"); len(backups) != 1 {
+			t.Errorf("expected one backup of %s, got %v", name, backups)
+		}
+	}
+	if from, err := indexService.MigrateIndex(); err != nil || from != IndexSchemaVersion {
+		t.Errorf("expected no migration, got %d (%v)", from, err)
+	}
+}
+
+func newJournaledShardedIndex(t *testing.T) (*IndexService, *IndexJournal) {
+	t.Helper()
+	dataDir := t.TempDir()
+	indexService := NewIndexService(dataDir)
+	indexService.EnableSharding()
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	journal := NewIndexJournal(filepath.Join(dataDir, "journal.jsonl"))
+	indexService.SetJournal(journal)
+	for _, img := range []*models.Image{
+		{ID: "cat", Title: "Cat", Category: "animals/cats", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "tower", Title: "Tower", Category: "architecture", Type: models.ImageType2D, UploadedAt: time.Now()},
+	} {
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+	return indexService, journal
+}
+
+func TestShardedIndex_ManifestRecordsImageShards(t *testing.T) {
+	indexService, _ := newJournaledShardedIndex(t)
+	manifest, err := indexService.loadManifest()
+	if err != nil {
+		t.Fatalf("loadManifest failed: %v", err)
+	}
+	if manifest.Images["cat"] != "animals" || manifest.Images["tower"] != "architecture" {
+		t.Errorf("unexpected image shards %v", manifest.Images)
+	}
+
+	// A manifest from before image shards were recorded gets them
+	// when the index is initialized
+	manifest.Images = nil
+	data, _ := json.Marshal(manifest)
+	if err := os.WriteFile(indexService.manifestPath(), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	reopened := NewIndexService(filepath.Dir(indexService.indexPath))
+	reopened.EnableSharding()
+	if err := reopened.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	title := "Tall Tower"
+	if _, err := reopened.UpdateImage("tower", 0, ImageUpdate{Title: &title}); err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if manifest, _ := reopened.loadManifest(); len(manifest.Images) != 2 {
+		t.Errorf("expected the image shards to be rebuilt, got %v", manifest.Images)
+	}
+}
+
+func TestShardedIndex_WritersOfOtherShardsRunInParallel(t *testing.T) {
+	indexService, _ := newJournaledShardedIndex(t)
+	unlock, err := indexService.lockFiles(indexService.shardFile("animals"))
+	if err != nil {
+		t.Fatalf("lockFiles failed: %v", err)
+	}
+
+	update := func(id string) chan error {
+		done := make(chan error, 1)
+		go func() {
+			title := "New " + id
+			_, err := indexService.UpdateImage(id, 0, ImageUpdate{Title: &title})
+			done <- err
+		}()
+		return done
+	}
+
+	// The architecture shard isn't held up by the animals shard's writer
+	select {
+	case err := <-update("tower"):
+		if err != nil {
+			t.Fatalf("UpdateImage failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a write to another shard to go ahead")
+	}
+
+	cat := update("cat")
+	select {
+	case <-cat:
+		t.Fatal("expected a write to the locked shard to wait")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	if err := <-cat; err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+}
+
+func TestShardedIndex_RecoversEachShard(t *testing.T) {
+	indexService, journal := newJournaledShardedIndex(t)
+
+	// Simulate a crash while writers of both shards were between journaling
+	// and rewriting their shard
+	for _, id := range []string{"cat", "tower"} {
+		path, _ := indexService.fileOf(id)
+		content, _ := readIndexFile(path)
+		start, end, _ := findImageSection(content, id)
+		if _, err := journal.Append(JournalUpdate, id, setField(content[start:end], "Title", "Recovered")); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	recovered, err := indexService.RecoverJournal()
+	if err != nil || !recovered {
+		t.Fatalf("expected recovery, got %v (%v)", recovered, err)
+	}
+	for _, id := range []string{"cat", "tower"} {
+		if stored, _ := indexService.GetImageByID(id); stored.Title != "Recovered" {
+			t.Errorf("expected %s to get the journaled title, got %q", id, stored.Title)
+		}
+	}
+	if recovered, _ := indexService.RecoverJournal(); recovered {
+		t.Error("recovery should be idempotent")
+	}
+}
+
+func TestShardedIndex_FailedWriteIsRevertedInJournal(t *testing.T) {
+	indexService, journal := newJournaledShardedIndex(t)
+	path, _ := indexService.fileOf("cat")
+	content, _ := readIndexFile(path)
+	start, end, _ := findImageSection(content, "cat")
+	before := content[start:end]
+
+	// The cat's update is journaled, then the tower's, then writing the
+	// cat's shard fails
+	failed, err := indexService.appendJournal(false, JournalEntry{Op: JournalUpdate, ImageID: "cat", Section: setField(before, "Title", "Lost")})
+	if err != nil {
+		t.Fatalf("appendJournal failed: %v", err)
+	}
+	title := "New Tower"
+	if _, err := indexService.UpdateImage("tower", 0, ImageUpdate{Title: &title}); err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	indexService.undoJournal(failed, []string{before})
+
+	last, err := journal.Last()
+	if err != nil || last.Op != JournalRevert || last.ImageID != "cat" || last.Section != before {
+		t.Fatalf("expected a revert of the cat's update, got %+v (%v)", last, err)
+	}
+	if recovered, err := indexService.RecoverJournal(); err != nil || recovered {
+		t.Errorf("expected nothing to recover, got %v (%v)", recovered, err)
+	}
+	if stored, _ := indexService.GetImageByID("cat"); stored.Title != "Cat" {
+		t.Errorf("expected the cat's title to be kept, got %q", stored.Title)
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+var (
+	// ErrQueueFull is returned when the job queue is at capacity
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrQueueClosed is returned once the job queue has been closed
+	ErrQueueClosed = errors.New("job queue is closed")
+)
+
+// jobQueue is a bounded, blocking priority queue of upload jobs.
+// Higher priority jobs are popped first; jobs of equal priority are FIFO.
+// A job type can be limited to a number of jobs in flight, so that a burst
+// of heavy 3D jobs leaves workers free for 2D ones: Pop then skips jobs of
+// a type at its limit until Done is called for one of them.
+type jobQueue struct {
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	items    jobHeap
+	capacity int
+	seq      uint64
+	closed   bool
+	retiring int // workers asked to stop
+
+	limits  map[models.ImageType]int // 0 or absent = no limit
+	running map[models.ImageType]int
+}
+
+type queuedJob struct {
+	job *models.UploadJob
+	seq uint64
+}
+
+func newJobQueue(capacity int) *jobQueue {
+	q := &jobQueue{
+		capacity: capacity,
+		limits:   make(map[models.ImageType]int),
+		running:  make(map[models.ImageType]int),
+	}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+// Push adds a job, failing if the queue is full or closed
+func (q *jobQueue) Push(job *models.UploadJob) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if len(q.items) >= q.capacity {
+		return ErrQueueFull
+	}
+
+	q.seq++
+	heap.Push(&q.items, &queuedJob{job: job, seq: q.seq})
+	q.cond.Signal()
+	return nil
+}
+
+// Pop blocks until a job is available whose type is below its limit. It
+// returns false once the queue is closed and drained, or when the calling
+// worker is retired.
+func (q *jobQueue) Pop() (*models.UploadJob, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for {
+		if q.retiring > 0 {
+			q.retiring--
+			return nil, false
+		}
+		if i := q.next(); i >= 0 {
+			item := heap.Remove(&q.items, i).(*queuedJob)
+			q.running[item.job.Type]++
+			return item.job, true
+		}
+		if len(q.items) == 0 && q.closed {
+			return nil, false
+		}
+		q.cond.Wait()
+	}
+}
+
+// next returns the position of the highest priority job whose type is below
+// its limit, or -1. Caller must hold the lock.
+func (q *jobQueue) next() int {
+	if len(q.items) == 0 {
+		return -1
+	}
+	if q.available(q.items[0].job.Type) {
+		return 0
+	}
+	best := -1
+	for i, item := range q.items {
+		if q.available(item.job.Type) && (best < 0 || q.items.Less(i, best)) {
+			best = i
+		}
+	}
+	return best
+}
+
+// available reports whether another job of a type may start. Caller must
+// hold the lock.
+func (q *jobQueue) available(jobType models.ImageType) bool {
+	limit := q.limits[jobType]
+	return limit <= 0 || q.running[jobType] < limit
+}
+
+// Done records that a popped job finished, freeing its type's slot
+func (q *jobQueue) Done(job *models.UploadJob) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.running[job.Type] > 0 {
+		q.running[job.Type]--
+	}
+	q.cond.Broadcast()
+}
+
+// SetLimit caps the jobs of a type in flight at once; 0 removes the cap
+func (q *jobQueue) SetLimit(jobType models.ImageType, limit int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.limits[jobType] = limit
+	q.cond.Broadcast()
+}
+
+// Running returns the number of jobs in flight per type
+func (q *jobQueue) Running() map[string]int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	running := make(map[string]int, len(q.running))
+	for jobType, n := range q.running {
+		running[string(jobType)] = n
+	}
+	return running
+}
+
+// Limits returns the configured per-type limits
+func (q *jobQueue) Limits() map[string]int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	limits := make(map[string]int, len(q.limits))
+	for jobType, n := range q.limits {
+		if n > 0 {
+			limits[string(jobType)] = n
+		}
+	}
+	return limits
+}
+
+// Remove takes a queued job out of the queue by image ID
+func (q *jobQueue) Remove(imageID string) (*models.UploadJob, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, item := range q.items {
+		if item.job.ImageID == imageID {
+			heap.Remove(&q.items, i)
+			return item.job, true
+		}
+	}
+	return nil, false
+}
+
+// Len returns the number of queued jobs
+func (q *jobQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.items)
+}
+
+// Cap returns the maximum number of queued jobs
+func (q *jobQueue) Cap() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.capacity
+}
+
+// SetCapacity changes the maximum number of queued jobs. Jobs already queued
+// beyond a lower capacity stay queued.
+func (q *jobQueue) SetCapacity(capacity int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.capacity = capacity
+}
+
+// Retire makes the next n calls to Pop return false, so that many workers
+// stop once they finish their current job
+func (q *jobQueue) Retire(n int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.retiring += n
+	q.cond.Broadcast()
+}
+
+// Close wakes all waiting workers; queued jobs are still drained
+func (q *jobQueue) Close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// jobHeap implements heap.Interface ordered by priority, then arrival
+type jobHeap []*queuedJob
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].job.Priority != h[j].job.Priority {
+		return h[i].job.Priority > h[j].job.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x interface{}) {
+	*h = append(*h, x.(*queuedJob))
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestJobQueue_PriorityOrder(t *testing.T) {
+	q := newJobQueue(10)
+
+	jobs := []*models.UploadJob{
+		{ImageID: "bulk-1", Priority: models.PriorityLow},
+		{ImageID: "normal-1", Priority: models.PriorityNormal},
+		{ImageID: "bulk-2", Priority: models.PriorityLow},
+		{ImageID: "designer", Priority: models.PriorityHigh},
+		{ImageID: "normal-2", Priority: models.PriorityNormal},
+	}
+	for _, job := range jobs {
+		if err := q.Push(job); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+
+	expected := []string{"designer", "normal-1", "normal-2", "bulk-1", "bulk-2"}
+	for _, want := range expected {
+		job, ok := q.Pop()
+		if !ok {
+			t.Fatal("Pop returned false on non-empty queue")
+		}
+		if job.ImageID != want {
+			t.Errorf("expected %s, got %s", want, job.ImageID)
+		}
+	}
+}
+
+func TestJobQueue_Full(t *testing.T) {
+	q := newJobQueue(1)
+
+	if err := q.Push(&models.UploadJob{ImageID: "a"}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if err := q.Push(&models.UploadJob{ImageID: "b"}); err == nil {
+		t.Error("expected error when queue is full, got nil")
+	}
+}
+
+func TestJobQueue_PopBlocksUntilPush(t *testing.T) {
+	q := newJobQueue(1)
+
+	done := make(chan string)
+	go func() {
+		job, _ := q.Pop()
+		done <- job.ImageID
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	q.Push(&models.UploadJob{ImageID: "late"})
+
+	select {
+	case id := <-done:
+		if id != "late" {
+			t.Errorf("expected late, got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pop did not wake up after Push")
+	}
+}
+
+func TestJobQueue_Close(t *testing.T) {
+	q := newJobQueue(1)
+	q.Close()
+
+	if _, ok := q.Pop(); ok {
+		t.Error("expected Pop on closed empty queue to return false")
+	}
+	if err := q.Push(&models.UploadJob{ImageID: "a"}); err == nil {
+		t.Error("expected Push on closed queue to fail")
+	}
+}
+
+func TestJobQueue_Remove(t *testing.T) {
+	q := newJobQueue(10)
+	q.Push(&models.UploadJob{ImageID: "a", Priority: models.PriorityHigh})
+	q.Push(&models.UploadJob{ImageID: "b"})
+	q.Push(&models.UploadJob{ImageID: "c", Priority: models.PriorityLow})
+
+	job, ok := q.Remove("b")
+	if !ok || job.ImageID != "b" {
+		t.Fatalf("expected to remove b, got %v %v", job, ok)
+	}
+	if _, ok := q.Remove("b"); ok {
+		t.Error("removing twice should fail")
+	}
+
+	// Remaining order is preserved
+	first, _ := q.Pop()
+	second, _ := q.Pop()
+	if first.ImageID != "a" || second.ImageID != "c" {
+		t.Errorf("unexpected order after remove: %s, %s", first.ImageID, second.ImageID)
+	}
+}
+
+func TestJobQueue_TypeLimit(t *testing.T) {
+	q := newJobQueue(10)
+	q.SetLimit(models.ImageType3D, 1)
+
+	for _, job := range []*models.UploadJob{
+		{ImageID: "3d-1", Type: models.ImageType3D, Priority: models.PriorityHigh},
+		{ImageID: "3d-2", Type: models.ImageType3D, Priority: models.PriorityHigh},
+		{ImageID: "2d-1", Type: models.ImageType2D},
+	} {
+		if err := q.Push(job); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+
+	// The second 3D job waits for the first even though it ranks higher
+	first, _ := q.Pop()
+	second, _ := q.Pop()
+	if first.ImageID != "3d-1" || second.ImageID != "2d-1" {
+		t.Fatalf("expected 3d-1 then 2d-1, got %s then %s", first.ImageID, second.ImageID)
+	}
+	if running := q.Running(); running["3D"] != 1 || running["2D"] != 1 {
+		t.Errorf("unexpected running counts %v", running)
+	}
+
+	done := make(chan string)
+	go func() {
+		job, _ := q.Pop()
+		done <- job.ImageID
+	}()
+
+	select {
+	case id := <-done:
+		t.Fatalf("expected Pop to wait for the 3D slot, got %s", id)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	q.Done(first)
+	select {
+	case id := <-done:
+		if id != "3d-2" {
+			t.Errorf("expected 3d-2, got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pop did not return after the 3D slot was freed")
+	}
+}
+
+func TestJobQueue_Retire(t *testing.T) {
+	q := newJobQueue(10)
+
+	done := make(chan bool)
+	go func() {
+		_, ok := q.Pop()
+		done <- ok
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	q.Retire(1)
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("expected the retired worker's Pop to return false")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pop did not wake up after Retire")
+	}
+
+	// Only one worker was retired
+	q.Push(&models.UploadJob{ImageID: "a"})
+	if job, ok := q.Pop(); !ok || job.ImageID != "a" {
+		t.Errorf("expected a, got %v %v", job, ok)
+	}
+}
+
+func TestSetWorkers(t *testing.T) {
+	image := NewImageService(nil, nil, nil, nil, logrus.New())
+	image.StartWorkers(2)
+	defer image.jobQueue.Close()
+
+	for _, n := range []int{0, MaxWorkers + 1} {
+		if err := image.SetWorkers(n); err == nil {
+			t.Errorf("expected %d workers to be refused", n)
+		}
+	}
+	for _, n := range []int{5, 1} {
+		if err := image.SetWorkers(n); err != nil {
+			t.Fatalf("SetWorkers(%d) failed: %v", n, err)
+		}
+		if got := image.Metrics().Workers; got != n {
+			t.Errorf("expected %d workers, got %d", n, got)
+		}
+	}
+}
+
+func TestQueueJob_FullQueue(t *testing.T) {
+	image := NewImageService(nil, nil, nil, nil, logrus.New())
+	image.SetQueueCapacity(1)
+
+	if err := image.QueueJob(&models.UploadJob{ImageID: "first", Type: models.ImageType2D}); err != nil {
+		t.Fatalf("QueueJob failed: %v", err)
+	}
+	err := image.QueueJob(&models.UploadJob{ImageID: "second", Type: models.ImageType2D})
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	// The refused upload leaves no status behind
+	if _, err := image.GetStatus("second"); err == nil {
+		t.Error("expected no status for the refused upload")
+	}
+	if metrics := image.Metrics(); metrics.QueueLength != 1 || metrics.QueueCapacity != 1 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+	if wait := image.RetryAfter(); wait < time.Second {
+		t.Errorf("expected at least a second, got %v", wait)
+	}
+}
+
+func TestRetryJob(t *testing.T) {
+	dataDir := t.TempDir()
+	storage := NewStorageService(dataDir)
+	if err := storage.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	image := NewImageService(storage, nil, NewIndexService(dataDir), nil, logrus.New())
+	image.StartWorkers(1)
+	defer image.jobQueue.Close()
+
+	// Not an image: the thumbnail stage fails and the upload stays in temp
+	upload := filepath.Join(dataDir, "temp", "broken.jpg")
+	if err := os.WriteFile(upload, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFailed := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if job, err := image.GetJob("broken"); err == nil && job.State == models.JobStateError {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatal("job did not fail")
+	}
+
+	if err := image.QueueJob(&models.UploadJob{ImageID: "broken", Type: models.ImageType2D, FilePath: upload}); err != nil {
+		t.Fatalf("QueueJob failed: %v", err)
+	}
+	waitFailed()
+
+	if err := image.RetryJob("unknown"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+	if err := image.RetryJob("broken"); err != nil {
+		t.Fatalf("RetryJob failed: %v", err)
+	}
+	waitFailed()
+	if metrics := image.Metrics(); metrics.Failed != 2 {
+		t.Errorf("expected the retry to run, got %d failures", metrics.Failed)
+	}
+	if jobs := image.ListJobs(models.JobStateError); len(jobs) != 1 {
+		t.Errorf("expected one tracked job, got %d", len(jobs))
+	}
+
+	// Once the upload is gone there is nothing to retry
+	os.Remove(upload)
+	if err := image.RetryJob("broken"); !errors.Is(err, ErrJobNotRetryable) {
+		t.Errorf("expected ErrJobNotRetryable, got %v", err)
+	}
+}
+
+func TestRememberFailed_CleansUpEvictedJobs(t *testing.T) {
+	dataDir := t.TempDir()
+	storage := NewStorageService(dataDir)
+	if err := storage.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	image := NewImageService(storage, nil, NewIndexService(dataDir), nil, logrus.New())
+
+	var uploads []string
+	for i := 0; i <= maxRetainedFailures; i++ {
+		upload := filepath.Join(dataDir, "temp", fmt.Sprintf("failed-%d.jpg", i))
+		if err := os.WriteFile(upload, []byte("not an image"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		uploads = append(uploads, upload)
+		image.rememberFailed(&models.UploadJob{ImageID: fmt.Sprintf("failed-%d", i), Type: models.ImageType2D, FilePath: upload})
+	}
+
+	if _, err := os.Stat(uploads[0]); !os.IsNotExist(err) {
+		t.Error("expected the evicted job's upload to be removed")
+	}
+	if err := image.RetryJob("failed-0"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected the evicted job to be forgotten, got %v", err)
+	}
+	for _, upload := range uploads[1:] {
+		if _, err := os.Stat(upload); err != nil {
+			t.Errorf("expected %s to be kept for retrying: %v", filepath.Base(upload), err)
+		}
+	}
+}
+
+func TestQueueJob_ExternalIDIndexUnreadable(t *testing.T) {
+	dataDir := t.TempDir()
+	// A directory where the index file should be: reading it fails
+	if err := os.Mkdir(filepath.Join(dataDir, "index.md"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	image := NewImageService(nil, nil, NewIndexService(dataDir), nil, logrus.New())
+
+	err := image.QueueJob(&models.UploadJob{ImageID: "first", Type: models.ImageType2D, ExternalID: "sku-1"})
+	if err == nil || errors.Is(err, ErrExternalIDConflict) {
+		t.Fatalf("expected the index error, got %v", err)
+	}
+	if _, pending := image.pendingExternalIDs["sku-1"]; pending {
+		t.Error("expected the external ID not to be claimed")
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// jobTracker records the lifecycle of processing jobs so operators can see
+// queued, running and recently finished jobs. Only the most recent
+// maxHistory finished jobs are kept.
+type jobTracker struct {
+	mutex      sync.RWMutex
+	jobs       map[string]*models.JobInfo
+	finished   []string // finished job IDs, oldest first
+	maxHistory int
+}
+
+func newJobTracker(maxHistory int) *jobTracker {
+	return &jobTracker{
+		jobs:       make(map[string]*models.JobInfo),
+		maxHistory: maxHistory,
+	}
+}
+
+// queued records a newly queued job. A retried job starts a new record; the
+// one it replaces is returned for unqueued.
+func (t *jobTracker) queued(job *models.UploadJob) *models.JobInfo {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	previous := t.jobs[job.ImageID]
+	if previous != nil && previous.FinishedAt != nil {
+		for i, id := range t.finished {
+			if id == job.ImageID {
+				t.finished = append(t.finished[:i], t.finished[i+1:]...)
+				break
+			}
+		}
+	}
+
+	t.jobs[job.ImageID] = &models.JobInfo{
+		ID:       job.ImageID,
+		Kind:     models.JobKindUpload,
+		Type:     job.Type,
+		Title:    job.Title,
+		Priority: job.Priority.String(),
+		State:    models.JobStateQueued,
+		QueuedAt: time.Now(),
+	}
+	return previous
+}
+
+// unqueued undoes queued for a job that could not be queued after all,
+// restoring the record it replaced
+func (t *jobTracker) unqueued(imageID string, previous *models.JobInfo) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if info, ok := t.jobs[imageID]; !ok || info.State != models.JobStateQueued {
+		return
+	}
+	if previous == nil {
+		delete(t.jobs, imageID)
+		return
+	}
+	t.jobs[imageID] = previous
+	if previous.FinishedAt != nil {
+		t.finished = append(t.finished, imageID)
+	}
+}
+
+// startedBackground records a non-upload job that starts running immediately
+func (t *jobTracker) startedBackground(id, kind, title string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	t.jobs[id] = &models.JobInfo{
+		ID:        id,
+		Kind:      kind,
+		Title:     title,
+		Priority:  models.PriorityLow.String(),
+		State:     models.JobStateRunning,
+		QueuedAt:  now,
+		StartedAt: &now,
+		Progress:  &models.JobProgress{},
+	}
+}
+
+// progress updates the progress of a background job
+func (t *jobTracker) progress(id string, progress models.JobProgress) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if info, ok := t.jobs[id]; ok {
+		info.Progress = &progress
+	}
+}
+
+// started marks a job as picked up by a worker
+func (t *jobTracker) started(imageID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if info, ok := t.jobs[imageID]; ok {
+		now := time.Now()
+		info.State = models.JobStateRunning
+		info.StartedAt = &now
+		info.WaitMs = now.Sub(info.QueuedAt).Milliseconds()
+	}
+}
+
+// finish records the final state of a job
+func (t *jobTracker) finish(imageID, state string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	info, ok := t.jobs[imageID]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	info.State = state
+	info.FinishedAt = &now
+	if info.StartedAt != nil {
+		info.DurationMs = now.Sub(*info.StartedAt).Milliseconds()
+	} else {
+		info.WaitMs = now.Sub(info.QueuedAt).Milliseconds()
+	}
+	if err != nil {
+		info.Error = err.Error()
+	}
+
+	t.finished = append(t.finished, imageID)
+	for len(t.finished) > t.maxHistory {
+		delete(t.jobs, t.finished[0])
+		t.finished = t.finished[1:]
+	}
+}
+
+// get returns a copy of a tracked job
+func (t *jobTracker) get(imageID string) (models.JobInfo, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	info, ok := t.jobs[imageID]
+	if !ok {
+		return models.JobInfo{}, false
+	}
+	return t.snapshot(info), true
+}
+
+// list returns tracked jobs, newest first, optionally filtered by state
+func (t *jobTracker) list(state string) []models.JobInfo {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	jobs := make([]models.JobInfo, 0, len(t.jobs))
+	for _, info := range t.jobs {
+		if state != "" && info.State != state {
+			continue
+		}
+		jobs = append(jobs, t.snapshot(info))
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].QueuedAt.After(jobs[j].QueuedAt)
+	})
+	return jobs
+}
+
+// snapshot copies a job and fills in live wait/duration for unfinished jobs.
+// Caller must hold the lock.
+func (t *jobTracker) snapshot(info *models.JobInfo) models.JobInfo {
+	job := *info
+	if info.Progress != nil {
+		progress := *info.Progress
+		job.Progress = &progress
+	}
+	now := time.Now()
+	switch job.State {
+	case models.JobStateQueued:
+		job.WaitMs = now.Sub(job.QueuedAt).Milliseconds()
+	case models.JobStateRunning:
+		job.DurationMs = now.Sub(*job.StartedAt).Milliseconds()
+	}
+	return job
+}
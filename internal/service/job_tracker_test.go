@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestJobTracker_Lifecycle(t *testing.T) {
+	tracker := newJobTracker(10)
+	tracker.queued(&models.UploadJob{ImageID: "img-001", Type: models.ImageType2D, Priority: models.PriorityHigh})
+
+	info, ok := tracker.get("img-001")
+	if !ok {
+		t.Fatal("expected job to be tracked")
+	}
+	if info.State != models.JobStateQueued || info.Priority != "high" {
+		t.Errorf("unexpected queued job: %+v", info)
+	}
+
+	tracker.started("img-001")
+	info, _ = tracker.get("img-001")
+	if info.State != models.JobStateRunning || info.StartedAt == nil {
+		t.Errorf("expected running job with start time, got %+v", info)
+	}
+
+	tracker.finish("img-001", models.JobStateError, errors.New("gemini API error"))
+	info, _ = tracker.get("img-001")
+	if info.State != models.JobStateError {
+		t.Errorf("expected error state, got %s", info.State)
+	}
+	if info.Error != "gemini API error" {
+		t.Errorf("expected error message to be recorded, got %q", info.Error)
+	}
+	if info.FinishedAt == nil {
+		t.Error("expected finish time to be recorded")
+	}
+}
+
+func TestJobTracker_ListFilter(t *testing.T) {
+	tracker := newJobTracker(10)
+	tracker.queued(&models.UploadJob{ImageID: "a"})
+	tracker.queued(&models.UploadJob{ImageID: "b"})
+	tracker.started("b")
+
+	if got := len(tracker.list("")); got != 2 {
+		t.Errorf("expected 2 jobs, got %d", got)
+	}
+	running := tracker.list(models.JobStateRunning)
+	if len(running) != 1 || running[0].ID != "b" {
+		t.Errorf("expected only b running, got %+v", running)
+	}
+}
+
+func TestJobTracker_HistoryBounded(t *testing.T) {
+	tracker := newJobTracker(2)
+	for i := 0; i < 4; i++ {
+		id := fmt.Sprintf("img-%d", i)
+		tracker.queued(&models.UploadJob{ImageID: id})
+		tracker.finish(id, models.JobStateCompleted, nil)
+	}
+
+	if got := len(tracker.list("")); got != 2 {
+		t.Errorf("expected history capped at 2, got %d", got)
+	}
+	if _, ok := tracker.get("img-0"); ok {
+		t.Error("oldest finished job should have been dropped")
+	}
+}
+
+func TestJobTracker_Unqueued(t *testing.T) {
+	tracker := newJobTracker(10)
+
+	previous := tracker.queued(&models.UploadJob{ImageID: "img-001"})
+	tracker.unqueued("img-001", previous)
+	if _, ok := tracker.get("img-001"); ok {
+		t.Error("expected a job that could not be queued to be forgotten")
+	}
+
+	// A failed retry keeps the record of the previous attempt
+	tracker.queued(&models.UploadJob{ImageID: "img-002"})
+	tracker.finish("img-002", models.JobStateError, errors.New("timeout"))
+	previous = tracker.queued(&models.UploadJob{ImageID: "img-002"})
+	tracker.unqueued("img-002", previous)
+	if info, ok := tracker.get("img-002"); !ok || info.State != models.JobStateError || info.Error != "timeout" {
+		t.Errorf("expected the failed attempt to be restored, got %+v", info)
+	}
+	if len(tracker.finished) != 1 {
+		t.Errorf("expected one finished job, got %v", tracker.finished)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestLegalHold(t *testing.T) {
+	logger := logrus.New()
+	dataDir := t.TempDir()
+	indexService := NewIndexService(dataDir)
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	uploaded := time.Now().AddDate(0, 0, -100)
+	images := []*models.Image{
+		{ID: "held", Title: "Held", Category: "temp-references", Type: models.ImageType2D, UploadedAt: uploaded},
+		{ID: "in-project", Title: "In project", Category: "temp-references", Type: models.ImageType2D, UploadedAt: uploaded, Project: "acme"},
+		{ID: "free", Title: "Free", Category: "temp-references", Type: models.ImageType2D, UploadedAt: uploaded},
+	}
+	for _, img := range images {
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	projects := NewProjectStore(filepath.Join(dataDir, "projects.json"))
+	if _, _, err := projects.Put(models.Project{ID: "acme", Name: "Acme"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	audit := NewAuditLog(filepath.Join(dataDir, "audit.jsonl"))
+	imageService := NewImageService(NewStorageService(dataDir), nil, indexService, nil, logger)
+	imageService.SetLegalHolds(projects, audit)
+
+	updated, err := imageService.SetLegalHold("held", true, "admin")
+	if err != nil || !updated.LegalHold {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	if _, err := imageService.SetProjectLegalHold("acme", true, "admin"); err != nil {
+		t.Fatalf("SetProjectLegalHold failed: %v", err)
+	}
+
+	// Replacing the project keeps its hold, and a held project can't be deleted
+	if project, _, err := projects.Put(models.Project{ID: "acme", Name: "Acme Corp"}); err != nil || !project.LegalHold {
+		t.Errorf("expected the hold to survive a replace, got %+v (%v)", project, err)
+	}
+	if err := projects.Delete("acme"); !errors.Is(err, ErrLegalHold) {
+		t.Errorf("expected a held project to be kept, got %v", err)
+	}
+
+	for id, heldBy := range map[string]string{"held": "image", "in-project": "project acme"} {
+		if err := imageService.DeleteImage(id, "alice"); !errors.Is(err, ErrLegalHold) {
+			t.Errorf("expected deleting %s to be refused, got %v", id, err)
+		}
+		img, err := indexService.GetImageByID(id)
+		if err != nil {
+			t.Fatalf("expected %s to be kept: %v", id, err)
+		}
+		if got := imageService.HeldBy(img); got != heldBy {
+			t.Errorf("expected %s held by %q, got %q", id, heldBy, got)
+		}
+	}
+
+	// Retention deletes only the free image and records each refusal once
+	policy, _ := NewRetentionPolicy(map[string]string{"temp-references": "90d"}, 0)
+	retention := NewRetentionService(policy, indexService, imageService, nil, nil, audit, logger)
+	for run := 0; run < 2; run++ {
+		if _, err := retention.Enforce(context.Background(), time.Now()); err != nil {
+			t.Fatalf("Enforce failed: %v", err)
+		}
+	}
+	if _, err := indexService.GetImageByID("free"); err == nil {
+		t.Error("expected the free image to be deleted")
+	}
+	if blocked, _ := audit.Entries(AuditLegalHoldBlocked, 100); len(blocked) != 4 || blocked[0].Actor != RetentionActor || blocked[3].Actor != "alice" {
+		t.Errorf("expected two refusals by alice and one per held image by retention, got %+v", blocked)
+	}
+	if set, _ := audit.Entries(AuditLegalHoldSet, 100); len(set) != 2 || set[0].ProjectID != "acme" || set[1].ImageID != "held" {
+		t.Errorf("unexpected hold entries %+v", set)
+	}
+
+	// Once cleared, the image can be deleted
+	if _, err := imageService.SetLegalHold("held", false, "admin"); err != nil {
+		t.Fatalf("SetLegalHold failed: %v", err)
+	}
+	if err := imageService.DeleteImage("held", "alice"); err != nil {
+		t.Errorf("expected the released image to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Log formats
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Log components, whose level can be set apart from LOG_LEVEL
+const (
+	LogComponentHTTP        = "http"        // request logging and handlers
+	LogComponentImages      = "images"      // upload processing and the worker queue
+	LogComponentRenditions  = "renditions"  // sprites, cutouts, upscales, XMP sidecars
+	LogComponentSearch      = "search"      // search and embedding backfill
+	LogComponentMaintenance = "maintenance" // consolidation, retention, erasure, bulk jobs, reports
+	LogComponentWebhooks    = "webhooks"    // event, workflow and CDN purge webhooks
+)
+
+var logComponents = []string{
+	LogComponentHTTP, LogComponentImages, LogComponentRenditions,
+	LogComponentSearch, LogComponentMaintenance, LogComponentWebhooks,
+}
+
+// LogOptions configures the application log
+type LogOptions struct {
+	Level           string // default level
+	Format          string // text or json
+	ComponentLevels string // comma-separated component=level overrides
+	File            string // file written instead of stderr; empty keeps stderr
+	MaxSize         int64  // bytes the file is rotated at, rounded up to whole megabytes; 0 is 100 MB
+	MaxFiles        int    // rotated files kept; 0 keeps them all
+}
+
+// Logging hands out the loggers of the server's components. They share the
+// base logger's output and format, each at its own level.
+type Logging struct {
+	base       *logrus.Logger
+	components map[string]*logrus.Logger
+	file       *lumberjack.Logger
+}
+
+// ConfigureLogging applies options to the base logger and prepares the
+// components' loggers
+func ConfigureLogging(base *logrus.Logger, opts LogOptions) (*Logging, error) {
+	level, err := logrus.ParseLevel(opts.Level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level %q", opts.Level)
+	}
+	levels, err := parseComponentLevels(opts.ComponentLevels)
+	if err != nil {
+		return nil, err
+	}
+
+	var formatter logrus.Formatter
+	switch opts.Format {
+	case LogFormatText:
+		formatter = &logrus.TextFormatter{FullTimestamp: true}
+	case LogFormatJSON:
+		formatter = &logrus.JSONFormatter{}
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want text or json)", opts.Format)
+	}
+
+	logging := &Logging{base: base, components: make(map[string]*logrus.Logger)}
+	var out io.Writer = os.Stderr
+	if opts.File != "" {
+		logging.file = &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    megabytes(opts.MaxSize),
+			MaxBackups: opts.MaxFiles,
+		}
+		// lumberjack opens the file on the first write; open it now so a
+		// bad path fails at startup
+		if _, err := logging.file.Write(nil); err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", opts.File, err)
+		}
+		out = logging.file
+	}
+
+	base.SetOutput(out)
+	base.SetFormatter(formatter)
+	base.SetLevel(level)
+	for component, level := range levels {
+		logger := logrus.New()
+		logger.SetOutput(out)
+		logger.SetFormatter(formatter)
+		logger.SetLevel(level)
+		logger.Hooks = base.Hooks
+		logger.ExitFunc = base.ExitFunc
+		logging.components[component] = logger
+	}
+	return logging, nil
+}
+
+// megabytes rounds a size up to lumberjack's unit
+func megabytes(size int64) int {
+	return int((size + 1<<20 - 1) >> 20)
+}
+
+// parseComponentLevels parses "http=warn,search=debug"
+func parseComponentLevels(spec string) (map[string]logrus.Level, error) {
+	levels := make(map[string]logrus.Level)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		component, name, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid component log level %q (want component=level)", part)
+		}
+		component = strings.TrimSpace(component)
+		if !isLogComponent(component) {
+			return nil, fmt.Errorf("unknown log component %q (want one of %s)", component, strings.Join(logComponents, ", "))
+		}
+		level, err := logrus.ParseLevel(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level %q for %s", name, component)
+		}
+		levels[component] = level
+	}
+	return levels, nil
+}
+
+func isLogComponent(name string) bool {
+	for _, component := range logComponents {
+		if component == name {
+			return true
+		}
+	}
+	return false
+}
+
+// For returns the logger of a component: its own if its level is set apart,
+// the base logger otherwise
+func (l *Logging) For(component string) *logrus.Logger {
+	if logger, ok := l.components[component]; ok {
+		return logger
+	}
+	return l.base
+}
+
+// Close closes the log file, if any. Later entries go to stderr.
+func (l *Logging) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	l.base.SetOutput(os.Stderr)
+	for _, logger := range l.components {
+		logger.SetOutput(os.Stderr)
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestConfigureLogging_ComponentLevelsAndFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	base := logrus.New()
+	logging, err := ConfigureLogging(base, LogOptions{
+		Level:           "info",
+		Format:          LogFormatJSON,
+		ComponentLevels: "http=warn, search=debug",
+		File:            path,
+	})
+	if err != nil {
+		t.Fatalf("ConfigureLogging failed: %v", err)
+	}
+
+	logging.For(LogComponentHTTP).Info("dropped request line")
+	logging.For(LogComponentHTTP).Warn("slow request")
+	logging.For(LogComponentSearch).Debug("search chunk")
+	logging.For(LogComponentImages).Debug("dropped worker detail")
+	logging.For(LogComponentImages).Info("image processed")
+	if logging.For(LogComponentImages) != base {
+		t.Errorf("expected components without a level to use the base logger")
+	}
+	if err := logging.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("expected JSON lines, got %q", line)
+		}
+		messages = append(messages, entry["msg"].(string))
+	}
+	if got := strings.Join(messages, ","); got != "slow request,search chunk,image processed" {
+		t.Errorf("unexpected messages %s", got)
+	}
+}
+
+func TestConfigureLogging_RejectsInvalidOptions(t *testing.T) {
+	for _, opts := range []LogOptions{
+		{Level: "loud", Format: LogFormatText},
+		{Level: "info", Format: "xml"},
+		{Level: "info", Format: LogFormatText, ComponentLevels: "gpu=debug"},
+		{Level: "info", Format: LogFormatText, ComponentLevels: "http"},
+		{Level: "info", Format: LogFormatText, ComponentLevels: "http=loud"},
+		{Level: "info", Format: LogFormatText, File: "/dev/null/server.log"},
+	} {
+		if _, err := ConfigureLogging(logrus.New(), opts); err == nil {
+			t.Errorf("expected %+v to be rejected", opts)
+		}
+	}
+}
+
+func TestConfigureLogging_RotatesFile(t *testing.T) {
+	dir := t.TempDir()
+	logging, err := ConfigureLogging(logrus.New(), LogOptions{
+		Level:    "info",
+		Format:   LogFormatText,
+		File:     filepath.Join(dir, "server.log"),
+		MaxSize:  1, // rounded up to 1 MB
+		MaxFiles: 2,
+	})
+	if err != nil {
+		t.Fatalf("ConfigureLogging failed: %v", err)
+	}
+	defer logging.Close()
+
+	line := strings.Repeat("x", 1024)
+	for i := 0; i < 1500; i++ {
+		logging.For(LogComponentImages).Info(line)
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "server-*.log"))
+	if len(rotated) != 1 {
+		t.Errorf("expected one rotated file after 1.5 MB, got %v", rotated)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "server.log")); err != nil || info.Size() >= 1<<20 {
+		t.Errorf("expected a fresh file below 1 MB, got %v %v", info, err)
+	}
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// maxImportRows caps the rows of one metadata import
+const maxImportRows = 10000
+
+// importTagSeparator separates tags within a CSV cell, as commas separate cells
+const importTagSeparator = ";"
+
+// MetadataImportRow sets the metadata of one image, found by ID or external
+// ID. Nil fields are left unchanged; attributes are merged like in a PATCH.
+type MetadataImportRow struct {
+	Line       int               `json:"-"` // CSV line or JSON array position, for errors
+	ID         string            `json:"id,omitempty"`
+	ExternalID string            `json:"external_id,omitempty"`
+	Title      *string           `json:"title,omitempty"`
+	Artist     *string           `json:"artist,omitempty"`
+	Tags       *[]string         `json:"tags,omitempty"` // replaces the manual tags
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// MetadataImportError is a row that can't be imported
+type MetadataImportError struct {
+	Line  int    `json:"line"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// MetadataImport is a validated import: the report, plus the updates to
+// apply when it has no errors
+type MetadataImport struct {
+	Total   int                   `json:"total"`
+	Valid   int                   `json:"valid"`
+	Errors  []MetadataImportError `json:"errors,omitempty"`
+	updates []importUpdate
+}
+
+// importUpdate is the update of one image in an import
+type importUpdate struct {
+	imageID string
+	update  ImageUpdate
+}
+
+// ParseMetadataCSV reads import rows from a CSV with a header row. The
+// columns id, external_id, title, artist and tags (separated by ";") are
+// known; every other column is a custom attribute. Empty cells are left
+// unchanged.
+func ParseMetadataCSV(r io.Reader) ([]MetadataImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+
+	var rows []MetadataImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("too many rows (max %d)", maxImportRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := MetadataImportRow{Line: line}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			switch header[i] {
+			case "id":
+				row.ID = value
+			case "external_id":
+				row.ExternalID = value
+			case "title":
+				row.Title = &value
+			case "artist":
+				row.Artist = &value
+			case "tags":
+				tags := strings.Split(value, importTagSeparator)
+				row.Tags = &tags
+			default:
+				if row.Attributes == nil {
+					row.Attributes = make(map[string]string)
+				}
+				row.Attributes[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ParseMetadataJSON reads import rows from a JSON array
+func ParseMetadataJSON(r io.Reader) ([]MetadataImportRow, error) {
+	var rows []MetadataImportRow
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if len(rows) > maxImportRows {
+		return nil, fmt.Errorf("too many rows (max %d)", maxImportRows)
+	}
+	for i := range rows {
+		rows[i].Line = i + 1
+	}
+	return rows, nil
+}
+
+// PlanImport validates import rows against the index without changing
+// anything. Each row must match exactly one image, and no image may be
+// updated twice.
+func (s *BulkUpdateService) PlanImport(rows []MetadataImportRow) (*MetadataImport, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("no rows to import")
+	}
+	images, err := s.indexService.GetAllImages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	byID := make(map[string]*ImageMetadata, len(images))
+	byExternalID := make(map[string]*ImageMetadata)
+	for _, img := range images {
+		byID[img.ID] = img
+		if img.ExternalID != "" {
+			byExternalID[img.ExternalID] = img
+		}
+	}
+
+	plan := &MetadataImport{Total: len(rows)}
+	seen := make(map[string]int) // image ID -> line
+	for _, row := range rows {
+		img, update, err := planImportRow(row, byID, byExternalID)
+		if err == nil {
+			if line, ok := seen[img.ID]; ok {
+				err = fmt.Errorf("image already updated on line %d", line)
+			}
+		}
+		if err != nil {
+			id := row.ID
+			if id == "" {
+				id = row.ExternalID
+			}
+			plan.Errors = append(plan.Errors, MetadataImportError{Line: row.Line, ID: id, Error: err.Error()})
+			continue
+		}
+		seen[img.ID] = row.Line
+		plan.updates = append(plan.updates, importUpdate{imageID: img.ID, update: update})
+	}
+	plan.Valid = len(plan.updates)
+	return plan, nil
+}
+
+// planImportRow finds the image of a row and converts the row to an update
+func planImportRow(row MetadataImportRow, byID, byExternalID map[string]*ImageMetadata) (*ImageMetadata, ImageUpdate, error) {
+	var update ImageUpdate
+	var img *ImageMetadata
+	switch {
+	case row.ID != "":
+		img = byID[row.ID]
+		if img != nil && row.ExternalID != "" && img.ExternalID != row.ExternalID {
+			return nil, update, fmt.Errorf("external ID %s does not match image %s", row.ExternalID, row.ID)
+		}
+	case row.ExternalID != "":
+		img = byExternalID[row.ExternalID]
+	default:
+		return nil, update, errors.New("id or external_id is required")
+	}
+	if img == nil {
+		return nil, update, ErrImageNotFound
+	}
+
+	if row.Title != nil {
+		title := strings.TrimSpace(*row.Title)
+		if title == "" {
+			return nil, update, errors.New("title cannot be empty")
+		}
+		update.Title = &title
+	}
+	if row.Artist != nil {
+		artist := strings.TrimSpace(*row.Artist)
+		if artist == "" {
+			return nil, update, errors.New("artist cannot be empty")
+		}
+		update.Artist = &artist
+	}
+	update.Tags = row.Tags
+	if row.Attributes != nil {
+		update.Attributes = models.NormalizeAttributes(row.Attributes)
+		if err := models.ValidateAttributes(update.Attributes); err != nil {
+			return nil, update, err
+		}
+	}
+	if update.Title == nil && update.Artist == nil && update.Tags == nil && len(update.Attributes) == 0 {
+		return nil, update, errors.New("nothing to update")
+	}
+	return img, update, nil
+}
+
+// StartImport applies a validated import in the background, returning the
+// job ID. An import with errors is refused as a whole.
+func (s *BulkUpdateService) StartImport(plan *MetadataImport) (string, error) {
+	if len(plan.Errors) > 0 {
+		return "", fmt.Errorf("%d of %d rows are invalid", len(plan.Errors), plan.Total)
+	}
+
+	jobID := "import-" + uuid.New().String()
+	s.imageService.StartBackgroundJob(jobID, models.JobKindMetadataImport, "Metadata import")
+
+	go func() {
+		err := s.runImport(jobID, plan.updates)
+		s.imageService.FinishBackgroundJob(jobID, err)
+		if err != nil {
+			s.logger.Errorf("Metadata import %s failed: %v", jobID, err)
+		}
+	}()
+
+	return jobID, nil
+}
+
+func (s *BulkUpdateService) runImport(jobID string, updates []importUpdate) error {
+	progress := models.JobProgress{Total: len(updates)}
+	s.imageService.UpdateJobProgress(jobID, progress)
+
+	for _, u := range updates {
+		u.update.Actor = jobID
+		if _, err := s.imageService.UpdateImage(u.imageID, 0, u.update); err != nil {
+			if errors.Is(err, ErrImageNotFound) {
+				progress.Skipped++
+			} else {
+				s.logger.Warnf("Metadata import %s: failed to update %s: %v", jobID, u.imageID, err)
+				progress.Failed++
+			}
+		} else {
+			progress.Done++
+		}
+		s.imageService.UpdateJobProgress(jobID, progress)
+	}
+
+	s.logger.Infof("Metadata import %s finished: %d updated, %d skipped, %d failed", jobID, progress.Done, progress.Skipped, progress.Failed)
+	if progress.Failed > 0 {
+		return fmt.Errorf("%d of %d images failed to update", progress.Failed, progress.Total)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestParseMetadataCSV(t *testing.T) {
+	csv := "\ufeffID,External_ID,Title,Tags,Client_Name\n" +
+		"a,,New A,sketch; wip,Acme\n" +
+		",\"ext,1\",,,\n"
+	rows, err := ParseMetadataCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("ParseMetadataCSV failed: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+
+	a := rows[0]
+	if a.Line != 2 || a.ID != "a" || a.Title == nil || *a.Title != "New A" || a.Artist != nil {
+		t.Errorf("unexpected first row %+v", a)
+	}
+	if a.Tags == nil || len(*a.Tags) != 2 || a.Attributes["client_name"] != "Acme" {
+		t.Errorf("unexpected tags or attributes %+v", a)
+	}
+	if b := rows[1]; b.ExternalID != "ext,1" || b.Title != nil || b.Tags != nil || b.Attributes != nil {
+		t.Errorf("expected empty cells to be left unchanged, got %+v", b)
+	}
+}
+
+func TestBulkUpdate_MetadataImport(t *testing.T) {
+	svc, index, image := newTestBulkUpdate(t, nil)
+	if err := index.AppendToIndex(&models.Image{ID: "d", Title: "D", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), ExternalID: "sku-9"}); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	title, empty := "Renamed", " "
+	tags := []string{"catalog"}
+	rows := []MetadataImportRow{
+		{Line: 1, ID: "a", Title: &title, Tags: &tags, Attributes: map[string]string{"SKU": "123"}},
+		{Line: 2, ID: "missing", Title: &title},
+		{Line: 3, ID: "c", Artist: &empty},
+		{Line: 4, ID: "a", Title: &title},
+		{Line: 5, ID: "c", Attributes: map[string]string{"bad name": "x"}},
+		{Line: 6, ExternalID: "sku-9", Title: &title},
+	}
+
+	plan, err := svc.PlanImport(rows)
+	if err != nil {
+		t.Fatalf("PlanImport failed: %v", err)
+	}
+	if plan.Valid != 2 || len(plan.Errors) != 4 {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	for i, line := range []int{2, 3, 4, 5} {
+		if plan.Errors[i].Line != line {
+			t.Errorf("expected an error on line %d, got %+v", line, plan.Errors[i])
+		}
+	}
+	if _, err := svc.StartImport(plan); err == nil {
+		t.Fatal("expected an import with invalid rows to be refused")
+	}
+	if a, _ := index.GetImageByID("a"); a.Title != "A" {
+		t.Errorf("expected nothing to change, got title %q", a.Title)
+	}
+
+	plan, err = svc.PlanImport([]MetadataImportRow{rows[0], rows[5]})
+	if err != nil || len(plan.Errors) != 0 {
+		t.Fatalf("unexpected plan %+v (%v)", plan, err)
+	}
+	jobID, err := svc.StartImport(plan)
+	if err != nil {
+		t.Fatalf("StartImport failed: %v", err)
+	}
+	if job := waitForJob(t, image, jobID); job.State != models.JobStateCompleted || job.Kind != models.JobKindMetadataImport {
+		t.Fatalf("unexpected job result %+v", job)
+	}
+	a, _ := index.GetImageByID("a")
+	if a.Title != "Renamed" || len(a.Tags) != 1 || a.Tags[0] != "catalog" || a.Attributes["sku"] != "123" {
+		t.Errorf("unexpected result for a: %+v", a)
+	}
+	if d, _ := index.GetImageByID("d"); d.Title != "Renamed" {
+		t.Errorf("expected d to be found by external ID, got title %q", d.Title)
+	}
+}
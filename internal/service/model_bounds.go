@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// errModelFormat is returned for model formats whose geometry cannot be read
+var errModelFormat = errors.New("geometry cannot be read from this model format")
+
+// objUnitsComment finds a unit declaration in an OBJ comment, such as
+// "# units: cm" or "# File units = centimeters" (3ds Max)
+var objUnitsComment = regexp.MustCompile(`(?i)\bunits?\s*[:=]?\s*([a-z"']+)`)
+
+// ReadModelBounds measures the bounding box of a 3D model file. glTF (.gltf,
+// .glb) is in meters by specification and node transforms (including scale)
+// apply. OBJ is in the unit named by a comment, meters if there is none.
+func ReadModelBounds(path string) (*models.Bounds, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gltf", ".glb":
+		return readGLTFBounds(path)
+	case ".obj":
+		return readOBJBounds(path)
+	default:
+		return nil, errModelFormat
+	}
+}
+
+// box accumulates an axis-aligned bounding box
+type box struct {
+	min, max [3]float64
+	empty    bool
+}
+
+func newBox() box {
+	return box{
+		min:   [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)},
+		max:   [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)},
+		empty: true,
+	}
+}
+
+func (b *box) add(x, y, z float64) {
+	for i, v := range [3]float64{x, y, z} {
+		b.min[i] = math.Min(b.min[i], v)
+		b.max[i] = math.Max(b.max[i], v)
+	}
+	b.empty = false
+}
+
+// bounds returns the box size in unit
+func (b *box) bounds(unit string) (*models.Bounds, error) {
+	if b.empty {
+		return nil, errors.New("model has no geometry")
+	}
+	bounds := &models.Bounds{
+		Width:  b.max[0] - b.min[0],
+		Height: b.max[1] - b.min[1],
+		Depth:  b.max[2] - b.min[2],
+		Unit:   unit,
+		Source: models.BoundsSourceModel,
+	}
+	return bounds, bounds.Validate()
+}
+
+// readGLTFBounds transforms the corners of each mesh primitive's position
+// bounds (which glTF requires accessors to declare) into world space
+func readGLTFBounds(path string) (*models.Bounds, error) {
+	doc, err := readGLTF(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b := newBox()
+	doc.walkMeshes(func(mesh *gltfMesh, world mat4) {
+		for _, primitive := range mesh.Primitives {
+			index, ok := primitive.Attributes["POSITION"]
+			if !ok || index < 0 || index >= len(doc.Accessors) {
+				continue
+			}
+			accessor := doc.Accessors[index]
+			if len(accessor.Min) != 3 || len(accessor.Max) != 3 {
+				continue
+			}
+			for corner := 0; corner < 8; corner++ {
+				var p [3]float64
+				for axis := 0; axis < 3; axis++ {
+					p[axis] = accessor.Min[axis]
+					if corner&(1<<axis) != 0 {
+						p[axis] = accessor.Max[axis]
+					}
+				}
+				b.add(world.apply(p[0], p[1], p[2]))
+			}
+		}
+	})
+	return b.bounds("m")
+}
+
+// readOBJBounds scans the vertex positions of an OBJ file
+func readOBJBounds(path string) (*models.Bounds, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model: %w", err)
+	}
+	defer file.Close()
+
+	unit := "m"
+	b := newBox()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxIndexLine)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if comment, ok := strings.CutPrefix(line, "#"); ok {
+			if m := objUnitsComment.FindStringSubmatch(comment); m != nil {
+				if symbol, ok := models.NormalizeLengthUnit(m[1]); ok {
+					unit = symbol
+				}
+			}
+			continue
+		}
+
+		rest, ok := strings.CutPrefix(line, "v ")
+		if !ok {
+			continue
+		}
+		coords := strings.Fields(rest)
+		if len(coords) < 3 {
+			continue
+		}
+		x, errX := strconv.ParseFloat(coords[0], 64)
+		y, errY := strconv.ParseFloat(coords[1], 64)
+		z, errZ := strconv.ParseFloat(coords[2], 64)
+		if errX != nil || errY != nil || errZ != nil {
+			continue
+		}
+		b.add(x, y, z)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read model: %w", err)
+	}
+	return b.bounds(unit)
+}
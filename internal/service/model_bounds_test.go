@@ -0,0 +1,145 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// sceneGLTF is a unit cube scaled to 0.4 x 1.2 x 0.4 m by its node, placed
+// under a translated parent
+const sceneGLTF = `{
+	"scene": 0,
+	"scenes": [{"nodes": [0]}],
+	"nodes": [
+		{"translation": [5, 0, 0], "children": [1]},
+		{"mesh": 0, "scale": [0.4, 1.2, 0.4]}
+	],
+	"meshes": [{"primitives": [{"attributes": {"POSITION": 0}}]}],
+	"accessors": [{"min": [-0.5, 0, -0.5], "max": [0.5, 1, 0.5]}]
+}`
+
+func assertBounds(t *testing.T, got *models.Bounds, want models.Bounds) {
+	t.Helper()
+	if got == nil {
+		t.Fatalf("expected bounds %v, got nil", want)
+	}
+	close := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if !close(got.Width, want.Width) || !close(got.Height, want.Height) || !close(got.Depth, want.Depth) ||
+		got.Unit != want.Unit || got.Source != want.Source {
+		t.Errorf("expected bounds %v, got %v", want, got)
+	}
+}
+
+func TestReadModelBounds_GLTF(t *testing.T) {
+	dir := t.TempDir()
+	want := models.Bounds{Width: 0.4, Height: 1.2, Depth: 0.4, Unit: "m", Source: models.BoundsSourceModel}
+
+	path := filepath.Join(dir, "chair.gltf")
+	if err := os.WriteFile(path, []byte(sceneGLTF), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bounds, err := ReadModelBounds(path)
+	if err != nil {
+		t.Fatalf("ReadModelBounds failed: %v", err)
+	}
+	assertBounds(t, bounds, want)
+
+	// The same document in a GLB container (JSON chunk padded to 4 bytes)
+	json := []byte(sceneGLTF)
+	for len(json)%4 != 0 {
+		json = append(json, ' ')
+	}
+	glb := make([]byte, 20, 20+len(json))
+	binary.LittleEndian.PutUint32(glb[0:], glbMagic)
+	binary.LittleEndian.PutUint32(glb[4:], 2)
+	binary.LittleEndian.PutUint32(glb[8:], uint32(20+len(json)))
+	binary.LittleEndian.PutUint32(glb[12:], uint32(len(json)))
+	binary.LittleEndian.PutUint32(glb[16:], glbChunkJSON)
+	glb = append(glb, json...)
+	path = filepath.Join(dir, "chair.glb")
+	if err := os.WriteFile(path, glb, 0644); err != nil {
+		t.Fatal(err)
+	}
+	bounds, err = ReadModelBounds(path)
+	if err != nil {
+		t.Fatalf("ReadModelBounds (GLB) failed: %v", err)
+	}
+	assertBounds(t, bounds, want)
+}
+
+func TestReadModelBounds_OBJ(t *testing.T) {
+	dir := t.TempDir()
+	obj := "# Exported prop\n# File units = centimeters\n" +
+		"v -10 0 -5\nv 10 45 5\nvt 0.5 0.5\nvn 0 1 0\nf 1 2 1\n"
+	path := filepath.Join(dir, "lamp.obj")
+	if err := os.WriteFile(path, []byte(obj), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bounds, err := ReadModelBounds(path)
+	if err != nil {
+		t.Fatalf("ReadModelBounds failed: %v", err)
+	}
+	assertBounds(t, bounds, models.Bounds{Width: 20, Height: 45, Depth: 10, Unit: "cm", Source: models.BoundsSourceModel})
+
+	// Without a units comment OBJ is read in meters
+	path = filepath.Join(dir, "plain.obj")
+	if err := os.WriteFile(path, []byte("v 0 0 0\nv 1 2 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bounds, err = ReadModelBounds(path)
+	if err != nil {
+		t.Fatalf("ReadModelBounds failed: %v", err)
+	}
+	assertBounds(t, bounds, models.Bounds{Width: 1, Height: 2, Depth: 3, Unit: "m", Source: models.BoundsSourceModel})
+
+	// Formats without readable geometry are reported as such
+	if _, err := ReadModelBounds(filepath.Join(dir, "scene.fbx")); !errors.Is(err, errModelFormat) {
+		t.Errorf("expected errModelFormat for FBX, got %v", err)
+	}
+}
+
+func TestBounds_RoundTripAndOverride(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	obj := &models.Image{
+		ID: "obj-1", Title: "T", Artist: "A", Category: "props", Type: models.ImageType3D, UploadedAt: time.Now(),
+		Bounds: &models.Bounds{Width: 0.4, Height: 1.2, Depth: 0.4, Unit: "m", Source: models.BoundsSourceModel},
+	}
+	if err := svc.AppendToIndex(obj); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	stored, err := svc.GetImageByID("obj-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	assertBounds(t, stored.Bounds, *obj.Bounds)
+
+	manual, err := models.ParseBounds("35 x 48 x 20 cm")
+	if err != nil {
+		t.Fatalf("ParseBounds failed: %v", err)
+	}
+	manual.Source = models.BoundsSourceManual
+	updated, err := svc.UpdateImage("obj-1", 0, ImageUpdate{Bounds: manual})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	assertBounds(t, updated.Bounds, *manual)
+
+	img := &models.Image{ID: "img-1", Title: "T", Artist: "A", Category: "props", Type: models.ImageType2D, UploadedAt: time.Now()}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	if _, err := svc.UpdateImage("img-1", 0, ImageUpdate{Bounds: manual}); !errors.Is(err, ErrBoundsUnsupported) {
+		t.Errorf("expected ErrBoundsUnsupported for a 2D image, got %v", err)
+	}
+}
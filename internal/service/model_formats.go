@@ -0,0 +1,223 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// ErrInvalidModelFile is returned for model files whose content does not
+// match their extension
+var ErrInvalidModelFile = errors.New("model file does not match its format")
+
+// modelFormat is a 3D model format the warehouse recognizes
+type modelFormat struct {
+	contentType string
+	// valid checks the start of the file; nil accepts any content
+	valid func(head []byte) bool
+}
+
+// modelFormats maps model file extensions to their formats. USDZ is served
+// as model/vnd.usdz+zip so iOS opens it in AR Quick Look.
+var modelFormats = map[string]modelFormat{
+	".glb":   {"model/gltf-binary", isGLB},
+	".gltf":  {"model/gltf+json", isGLTFJSON},
+	".obj":   {"model/obj", nil},
+	".stl":   {"model/stl", nil},
+	".fbx":   {"application/octet-stream", nil},
+	".dae":   {"model/vnd.collada+xml", nil},
+	".blend": {"application/x-blender", isBlend},
+	".usd":   {"model/vnd.usd", func(head []byte) bool { return isUSDA(head) || isUSDC(head) }},
+	".usda":  {"model/vnd.usda", isUSDA},
+	".usdc":  {"model/vnd.usd", isUSDC},
+	".usdz":  {"model/vnd.usdz+zip", isUSDZ},
+}
+
+// RegisterModelContentTypes registers the content types of the model
+// formats for serving files by extension
+func RegisterModelContentTypes() {
+	for ext, format := range modelFormats {
+		mime.AddExtensionType(ext, format.contentType)
+	}
+}
+
+// ValidateModelFile checks that a model file's content matches the format
+// its name claims. Unknown extensions are accepted as they are.
+func ValidateModelFile(r io.Reader, filename string) error {
+	format, ok := modelFormats[strings.ToLower(filepath.Ext(filename))]
+	if !ok || format.valid == nil {
+		return nil
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read model: %w", err)
+	}
+	if !format.valid(head[:n]) {
+		return fmt.Errorf("%w: %s", ErrInvalidModelFile, filename)
+	}
+	return nil
+}
+
+func isGLB(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("glTF"))
+}
+
+func isGLTFJSON(head []byte) bool {
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	return bytes.HasPrefix(bytes.TrimSpace(head), []byte("{"))
+}
+
+func isUSDA(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("#usda "))
+}
+
+func isUSDC(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("PXR-USDC"))
+}
+
+// isUSDZ checks for a zip archive whose first entry is the root USD layer,
+// as the USDZ specification requires
+func isUSDZ(head []byte) bool {
+	if len(head) < 30 || !bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+		return false
+	}
+	nameLength := int(head[26]) | int(head[27])<<8
+	if len(head) < 30+nameLength {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(string(head[30 : 30+nameLength]))) {
+	case ".usd", ".usda", ".usdc":
+		return true
+	default:
+		return false
+	}
+}
+
+// isBlend accepts .blend files, which Blender may save gzip or zstd
+// compressed
+func isBlend(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("BLENDER")) ||
+		bytes.HasPrefix(head, []byte{0x1f, 0x8b}) ||
+		bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd})
+}
+
+// blendVersion matches the version in a .blend header: "BLENDER_v293"
+// before Blender 5, "BLENDER17-01v0500" after
+var blendVersion = regexp.MustCompile(`^BLENDER(?:[_-][vV](\d)(\d\d)|\d\d-\d\d[vV](\d\d)(\d\d))`)
+
+// usdaUpAxis matches the upAxis layer metadata of a USDA layer
+var usdaUpAxis = regexp.MustCompile(`upAxis\s*=\s*"([YZ])"`)
+
+// ReadModelInfo reads the format of a 3D model file and what its header
+// tells about it: the USD or glTF version, the Blender version that saved a
+// .blend, and the up axis a USD layer declares
+func ReadModelInfo(path string) (*models.ModelInfo, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	info := &models.ModelInfo{Format: strings.TrimPrefix(ext, ".")}
+
+	var err error
+	switch ext {
+	case ".gltf", ".glb":
+		var doc *gltfDocument
+		if doc, err = readGLTF(path); err == nil {
+			info.Version = doc.Asset.Version
+			info.UpAxis = "Y"
+		}
+	case ".blend":
+		info.Version, err = readBlendVersion(path)
+	case ".usd", ".usda", ".usdc":
+		var file *os.File
+		if file, err = os.Open(path); err == nil {
+			defer file.Close()
+			info.Version, info.UpAxis = readUSDLayerInfo(file)
+		}
+	case ".usdz":
+		info.Version, info.UpAxis, err = readUSDZInfo(path)
+	}
+	return info, err
+}
+
+// readBlendVersion returns the Blender version that saved a .blend file,
+// such as "2.93" or "4.1"
+func readBlendVersion(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open model: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = bufio.NewReader(file)
+	if magic, _ := r.(*bufio.Reader).Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		if r, err = gzip.NewReader(r); err != nil {
+			return "", fmt.Errorf("invalid compressed .blend: %w", err)
+		}
+	}
+	head := make([]byte, 17)
+	n, _ := io.ReadFull(r, head)
+
+	// The header of zstd-compressed files is not read; their version stays
+	// unknown
+	m := blendVersion.FindSubmatch(head[:n])
+	if m == nil {
+		return "", nil
+	}
+	major, _ := strconv.Atoi(string(m[1]) + string(m[3]))
+	minor, _ := strconv.Atoi(string(m[2]) + string(m[4]))
+	return fmt.Sprintf("%d.%d", major, minor), nil
+}
+
+// readUSDLayerInfo reads the version and up axis of a USD layer: USDC
+// (crate) headers carry the version; USDA layers name it in their first
+// line and declare the up axis in the layer metadata that follows
+func readUSDLayerInfo(r io.Reader) (version, upAxis string) {
+	head := make([]byte, 64*1024)
+	n, _ := io.ReadFull(r, head)
+	head = head[:n]
+
+	switch {
+	case isUSDC(head) && len(head) >= 11:
+		return fmt.Sprintf("%d.%d.%d", head[8], head[9], head[10]), ""
+	case isUSDA(head):
+		line, _, _ := bytes.Cut(head, []byte("\n"))
+		version = strings.TrimSpace(strings.TrimPrefix(string(line), "#usda"))
+		if m := usdaUpAxis.FindSubmatch(head); m != nil {
+			upAxis = string(m[1])
+		}
+		return version, upAxis
+	default:
+		return "", ""
+	}
+}
+
+// readUSDZInfo reads the root layer of a USDZ archive, its first entry
+func readUSDZInfo(path string) (version, upAxis string, err error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid USDZ archive: %w", err)
+	}
+	defer archive.Close()
+	if len(archive.File) == 0 {
+		return "", "", fmt.Errorf("%w: empty USDZ archive", ErrInvalidModelFile)
+	}
+
+	layer, err := archive.File[0].Open()
+	if err != nil {
+		return "", "", fmt.Errorf("invalid USDZ archive: %w", err)
+	}
+	defer layer.Close()
+	version, upAxis = readUSDLayerInfo(layer)
+	return version, upAxis, nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"mime"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// writeUSDZ writes an uncompressed zip with the given entries in order
+func writeUSDZ(t *testing.T, path string, entries [][2]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: entry[0], Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(entry[1]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const usdaLayer = `#usda 1.0
+(
+    defaultPrim = "Chair"
+    metersPerUnit = 0.01
+    upAxis = "Z"
+)
+
+def Xform "Chair"
+{
+    def Material "Wood"
+    {
+    }
+    def Material "Fabric"
+    {
+    }
+}
+`
+
+func TestValidateModelFile(t *testing.T) {
+	dir := t.TempDir()
+	usdz := filepath.Join(dir, "chair.usdz")
+	writeUSDZ(t, usdz, [][2]string{{"chair.usda", usdaLayer}, {"0/wood.png", "png"}})
+	usdzData, _ := os.ReadFile(usdz)
+
+	notUSDZ := filepath.Join(dir, "notes.usdz")
+	writeUSDZ(t, notUSDZ, [][2]string{{"readme.txt", "hello"}})
+	notUSDZData, _ := os.ReadFile(notUSDZ)
+
+	cases := []struct {
+		name  string
+		data  []byte
+		valid bool
+	}{
+		{"chair.usdz", usdzData, true},
+		{"notes.usdz", notUSDZData, false},
+		{"chair.USDA", []byte(usdaLayer), true},
+		{"chair.usdc", []byte("PXR-USDC\x00\x08\x00"), true},
+		{"chair.usd", []byte("PXR-USDC\x00\x08\x00"), true},
+		{"chair.usd", []byte("solid chair"), false},
+		{"scene.blend", []byte("BLENDER-v401REND"), true},
+		{"scene.blend", []byte{0x28, 0xb5, 0x2f, 0xfd, 0}, true},
+		{"scene.blend", []byte("PK\x03\x04"), false},
+		{"chair.glb", []byte("glTF\x02\x00\x00\x00"), true},
+		{"chair.gltf", []byte("\n  {\"asset\": {}}"), true},
+		{"chair.gltf", []byte("<html>"), false},
+		{"chair.fbx", []byte("anything"), true},
+		{"chair.ply", []byte("ply"), true},
+	}
+	for _, tc := range cases {
+		err := ValidateModelFile(bytes.NewReader(tc.data), tc.name)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if !tc.valid && !errors.Is(err, ErrInvalidModelFile) {
+			t.Errorf("%s: expected ErrInvalidModelFile, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestRegisterModelContentTypes(t *testing.T) {
+	RegisterModelContentTypes()
+	if got := mime.TypeByExtension(".usdz"); got != "model/vnd.usdz+zip" {
+		t.Errorf("expected the Quick Look content type for .usdz, got %q", got)
+	}
+	if got := mime.TypeByExtension(".glb"); got != "model/gltf-binary" {
+		t.Errorf("unexpected content type for .glb: %q", got)
+	}
+}
+
+func TestReadModelInfo(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("BLENDER_v293RENDH"))
+	zw.Close()
+
+	usdz := filepath.Join(dir, "chair.usdz")
+	writeUSDZ(t, usdz, [][2]string{{"chair.usda", usdaLayer}})
+
+	cases := []struct {
+		path string
+		want models.ModelInfo
+	}{
+		{write("old.blend", []byte("BLENDER-v401REND")), models.ModelInfo{Format: "blend", Version: "4.1"}},
+		{write("new.blend", []byte("BLENDER17-01v0500")), models.ModelInfo{Format: "blend", Version: "5.0"}},
+		{write("packed.blend", gz.Bytes()), models.ModelInfo{Format: "blend", Version: "2.93"}},
+		{write("chair.usdc", []byte("PXR-USDC\x00\x08\x00\x00\x00\x00\x00\x00")), models.ModelInfo{Format: "usdc", Version: "0.8.0"}},
+		{usdz, models.ModelInfo{Format: "usdz", Version: "1.0", UpAxis: "Z"}},
+		{write("chair.gltf", []byte(`{"asset": {"version": "2.0"}}`)), models.ModelInfo{Format: "gltf", Version: "2.0", UpAxis: "Y"}},
+		{write("chair.fbx", []byte("Kaydara FBX Binary")), models.ModelInfo{Format: "fbx"}},
+	}
+	for _, tc := range cases {
+		info, err := ReadModelInfo(tc.path)
+		if err != nil || *info != tc.want {
+			t.Errorf("%s: ReadModelInfo = %+v, %v; want %+v", filepath.Base(tc.path), info, err, tc.want)
+		}
+	}
+}
+
+func TestReadModelMaterials_USDZ(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chair.usdz")
+	writeUSDZ(t, path, [][2]string{
+		{"chair.usda", usdaLayer},
+		{"0/wood.png", string(encodePNG(t, 2048, 2048))},
+		{"0/fabric.png", string(encodePNG(t, 2048, 2048))},
+	})
+
+	summary, err := ReadModelMaterials(path)
+	if err != nil {
+		t.Fatalf("ReadModelMaterials failed: %v", err)
+	}
+	if summary.Materials != 2 || summary.Summary != "2x 2K PBR textures" {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
+
+func TestModelInfo_RoundTrip(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	obj := &models.Image{
+		ID: "obj-1", Title: "T", Artist: "A", Category: "props", Type: models.ImageType3D, UploadedAt: time.Now(),
+		ModelFilePath: "categories/props/obj-1/model.usdz", ModelFilename: "chair.usdz",
+		ModelInfo: &models.ModelInfo{Format: "usdz", Version: "0.8.0", UpAxis: "Z"},
+	}
+	if err := svc.AppendToIndex(obj); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	stored, err := svc.GetImageByID("obj-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if stored.ModelInfo == nil || *stored.ModelInfo != *obj.ModelInfo {
+		t.Errorf("expected model info %+v, got %+v", obj.ModelInfo, stored.ModelInfo)
+	}
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// MaterialsDir is the subfolder of a 3D object that holds the companion
+// files of its model: an OBJ's .mtl libraries and the textures they or a
+// .gltf reference. Files are looked up by base name.
+const MaterialsDir = "materials"
+
+// ReadModelMaterials summarizes the materials and textures of a 3D model
+// file. glTF (.gltf, .glb) materials are PBR unless unlit; embedded and data
+// URI textures are measured, external ones are looked up in MaterialsDir
+// next to the model. OBJ materials come from the .mtl libraries it names,
+// which must be in MaterialsDir. USDZ textures are the images in the
+// archive. A nil summary means the model declares no materials or its
+// libraries are missing.
+func ReadModelMaterials(path string) (*models.MaterialSummary, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gltf", ".glb":
+		return readGLTFMaterials(path)
+	case ".obj":
+		return readOBJMaterials(path)
+	case ".usdz":
+		return readUSDZMaterials(path)
+	default:
+		return nil, errModelFormat
+	}
+}
+
+// companionPath returns the path of a companion file in the materials
+// folder next to modelPath, or "" for names that are not plain files
+func companionPath(modelPath, name string) string {
+	base := filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if base == "." || base == ".." || base == "/" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(modelPath), MaterialsDir, base)
+}
+
+// textureSize reads the pixel size from an image header; zero if the format
+// is not supported
+func textureSize(r io.Reader) models.TextureSize {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return models.TextureSize{}
+	}
+	return models.TextureSize{Width: config.Width, Height: config.Height}
+}
+
+// companionTextureSize measures a texture in the materials folder
+func companionTextureSize(modelPath, name string) models.TextureSize {
+	path := companionPath(modelPath, name)
+	if path == "" {
+		return models.TextureSize{}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return models.TextureSize{}
+	}
+	defer file.Close()
+	return textureSize(file)
+}
+
+func readGLTFMaterials(path string) (*models.MaterialSummary, error) {
+	doc, err := readGLTF(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Materials) == 0 && len(doc.Images) == 0 {
+		return nil, nil
+	}
+
+	shading := make([]string, len(doc.Materials))
+	for i, material := range doc.Materials {
+		shading[i] = models.ShadingPBR
+		if _, unlit := material.Extensions["KHR_materials_unlit"]; unlit {
+			shading[i] = models.ShadingUnlit
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model: %w", err)
+	}
+	defer file.Close()
+
+	textures := make([]models.TextureSize, len(doc.Images))
+	for i, img := range doc.Images {
+		switch {
+		case img.BufferView != nil:
+			// Embedded in the GLB binary chunk
+			index := *img.BufferView
+			if index < 0 || index >= len(doc.BufferViews) || doc.BufferViews[index].Buffer != 0 || doc.binOffset < 0 {
+				continue
+			}
+			view := doc.BufferViews[index]
+			textures[i] = textureSize(io.NewSectionReader(file, doc.binOffset+view.ByteOffset, view.ByteLength))
+		case strings.HasPrefix(img.URI, "data:"):
+			header, payload, ok := strings.Cut(img.URI, ",")
+			if !ok || !strings.HasSuffix(header, ";base64") {
+				continue
+			}
+			textures[i] = textureSize(base64.NewDecoder(base64.StdEncoding, strings.NewReader(payload)))
+		case img.URI != "":
+			name, err := url.PathUnescape(img.URI)
+			if err != nil {
+				name = img.URI
+			}
+			textures[i] = companionTextureSize(path, name)
+		}
+	}
+	return models.NewMaterialSummary(len(doc.Materials), shading, textures), nil
+}
+
+// mtlTextureStatements are the MTL statements that reference a texture map
+// besides map_*
+var mtlTextureStatements = map[string]bool{"bump": true, "disp": true, "decal": true, "norm": true, "refl": true}
+
+// mtlPBRStatements are the PBR extension statements of MTL
+var mtlPBRStatements = map[string]bool{"Pr": true, "Pm": true, "Ps": true, "Pc": true, "Pcr": true, "aniso": true, "map_Pr": true, "map_Pm": true, "map_Ps": true}
+
+func readOBJMaterials(path string) (*models.MaterialSummary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model: %w", err)
+	}
+	defer file.Close()
+
+	var libraries []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxIndexLine)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "mtllib "); ok {
+			libraries = append(libraries, strings.Fields(rest)...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read model: %w", err)
+	}
+
+	var shading []string
+	var textures []models.TextureSize
+	seenTextures := make(map[string]bool)
+	found := false
+	for _, library := range libraries {
+		data, err := os.ReadFile(companionPath(path, library))
+		if err != nil {
+			continue
+		}
+		found = true
+
+		materials, maps := parseMTL(data)
+		shading = append(shading, materials...)
+		for _, name := range maps {
+			key := strings.ToLower(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+			if seenTextures[key] {
+				continue
+			}
+			seenTextures[key] = true
+			textures = append(textures, companionTextureSize(path, name))
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	return models.NewMaterialSummary(len(shading), shading, textures), nil
+}
+
+// parseMTL returns the shading model of each material in an MTL library and
+// the texture files it references. Materials with PBR statements are PBR,
+// illumination model 0 is unlit and anything else Phong.
+func parseMTL(data []byte) (shading []string, textures []string) {
+	var pbr, unlit bool
+	flush := func() {
+		switch {
+		case pbr:
+			shading = append(shading, models.ShadingPBR)
+		case unlit:
+			shading = append(shading, models.ShadingUnlit)
+		default:
+			shading = append(shading, models.ShadingPhong)
+		}
+	}
+
+	inMaterial := false
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		statement := fields[0]
+		switch {
+		case statement == "newmtl":
+			if inMaterial {
+				flush()
+			}
+			inMaterial, pbr, unlit = true, false, false
+		case !inMaterial:
+			// Statements before the first newmtl have no material
+		case statement == "illum" && len(fields) > 1:
+			unlit = fields[1] == "0"
+		case mtlPBRStatements[statement]:
+			pbr = true
+		}
+
+		// The file name is the last field; options (-s 1 1 1, ...) come first
+		if (strings.HasPrefix(statement, "map_") || mtlTextureStatements[statement]) && len(fields) > 1 {
+			textures = append(textures, fields[len(fields)-1])
+		}
+	}
+	if inMaterial {
+		flush()
+	}
+	return shading, textures
+}
+
+// usdaMaterial matches a material definition in a USDA layer
+var usdaMaterial = regexp.MustCompile(`(?m)^\s*def\s+Material\s+"`)
+
+// readUSDZMaterials measures the images packaged in a USDZ archive. USDZ
+// materials are UsdPreviewSurface, a PBR model; they are counted when the
+// root layer is USDA.
+func readUSDZMaterials(path string) (*models.MaterialSummary, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid USDZ archive: %w", err)
+	}
+	defer archive.Close()
+
+	materials := 0
+	var textures []models.TextureSize
+	for i, entry := range archive.File {
+		r, err := entry.Open()
+		if err != nil {
+			continue
+		}
+		switch ext := strings.ToLower(filepath.Ext(entry.Name)); {
+		case i == 0 && (ext == ".usda" || ext == ".usd"):
+			data, _ := io.ReadAll(io.LimitReader(r, maxGLTFJSON))
+			materials = len(usdaMaterial.FindAll(data, -1))
+		case ext == ".png" || ext == ".jpg" || ext == ".jpeg":
+			textures = append(textures, textureSize(r))
+		}
+		r.Close()
+	}
+	if materials == 0 && len(textures) == 0 {
+		return nil, nil
+	}
+
+	shading := make([]string, materials)
+	for i := range shading {
+		shading[i] = models.ShadingPBR
+	}
+	if materials == 0 {
+		shading = []string{models.ShadingPBR}
+	}
+	return models.NewMaterialSummary(materials, shading, textures), nil
+}
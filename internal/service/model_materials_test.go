@@ -0,0 +1,212 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// encodePNG returns a blank PNG of the given size
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeGLB writes a GLB with the given JSON document and binary chunk
+func writeGLB(t *testing.T, path string, doc map[string]interface{}, bin []byte) {
+	t.Helper()
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(data)%4 != 0 {
+		data = append(data, ' ')
+	}
+	for len(bin)%4 != 0 {
+		bin = append(bin, 0)
+	}
+
+	var buf bytes.Buffer
+	write := func(v uint32) { binary.Write(&buf, binary.LittleEndian, v) }
+	write(glbMagic)
+	write(2)
+	write(uint32(12 + 8 + len(data) + 8 + len(bin)))
+	write(uint32(len(data)))
+	write(glbChunkJSON)
+	buf.Write(data)
+	write(uint32(len(bin)))
+	write(glbChunkBIN)
+	buf.Write(bin)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadModelMaterials_GLB(t *testing.T) {
+	dir := t.TempDir()
+	baseColor, normal := encodePNG(t, 2048, 2048), encodePNG(t, 1024, 1024)
+	bin := append(append([]byte{}, baseColor...), normal...)
+
+	doc := map[string]interface{}{
+		"materials": []interface{}{
+			map[string]interface{}{"pbrMetallicRoughness": map[string]interface{}{}},
+			map[string]interface{}{"extensions": map[string]interface{}{"KHR_materials_unlit": map[string]interface{}{}}},
+		},
+		"images": []interface{}{
+			map[string]interface{}{"bufferView": 0, "mimeType": "image/png"},
+			map[string]interface{}{"bufferView": 1, "mimeType": "image/png"},
+			map[string]interface{}{"uri": "data:image/png;base64," + base64.StdEncoding.EncodeToString(encodePNG(t, 512, 256))},
+			map[string]interface{}{"uri": "missing.ktx2"},
+		},
+		"bufferViews": []interface{}{
+			map[string]interface{}{"buffer": 0, "byteLength": len(baseColor)},
+			map[string]interface{}{"buffer": 0, "byteOffset": len(baseColor), "byteLength": len(normal)},
+		},
+	}
+	path := filepath.Join(dir, "model.glb")
+	writeGLB(t, path, doc, bin)
+
+	summary, err := ReadModelMaterials(path)
+	if err != nil {
+		t.Fatalf("ReadModelMaterials failed: %v", err)
+	}
+	want := []models.TextureSize{{Width: 2048, Height: 2048}, {Width: 1024, Height: 1024}, {Width: 512, Height: 256}, {}}
+	if summary.Materials != 2 || !reflect.DeepEqual(summary.Shading, []string{"pbr", "unlit"}) || !reflect.DeepEqual(summary.Textures, want) {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if summary.MaxTextureSize != 2048 || summary.Summary != "1x 2K, 1x 1K, 1x 512px, 1x unknown PBR/unlit textures" {
+		t.Errorf("unexpected summary %q (max %d)", summary.Summary, summary.MaxTextureSize)
+	}
+}
+
+func TestReadModelMaterials_OBJ(t *testing.T) {
+	dir := t.TempDir()
+	materials := filepath.Join(dir, MaterialsDir)
+	if err := os.MkdirAll(materials, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	mtl := "# Exported\nnewmtl wood\nKd 0.8 0.6 0.4\nmap_Kd -s 1 1 1 textures\\wood_albedo.png\nbump wood_normal.png\n" +
+		"newmtl metal\nPm 1.0\nPr 0.3\nmap_Kd metal.png\nmap_Pr metal_rough.png\n" +
+		"newmtl glow\nillum 0\nmap_Kd WOOD_ALBEDO.png\n"
+	files := map[string][]byte{
+		"crate.mtl":       []byte(mtl),
+		"wood_albedo.png": encodePNG(t, 2048, 2048),
+		"wood_normal.png": encodePNG(t, 2048, 2048),
+		"metal.png":       encodePNG(t, 2048, 1024),
+		"metal_rough.png": encodePNG(t, 2048, 2048),
+		"../escape.png":   nil,
+	}
+	for name, data := range files {
+		if data == nil {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(materials, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "model.obj")
+	if err := os.WriteFile(path, []byte("mtllib crate.mtl\nv 0 0 0\nusemtl wood\nf 1 1 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := ReadModelMaterials(path)
+	if err != nil {
+		t.Fatalf("ReadModelMaterials failed: %v", err)
+	}
+	if summary.Materials != 3 || !reflect.DeepEqual(summary.Shading, []string{"pbr", "phong", "unlit"}) {
+		t.Errorf("unexpected materials %+v", summary)
+	}
+	if summary.Summary != "4x 2K PBR/Phong/unlit textures" || summary.MaxTextureSize != 2048 {
+		t.Errorf("unexpected summary %q", summary.Summary)
+	}
+
+	// Without its library the materials are unknown
+	if err := os.WriteFile(path, []byte("mtllib other.mtl\nv 0 0 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if summary, err := ReadModelMaterials(path); err != nil || summary != nil {
+		t.Errorf("expected no summary without the library, got %+v, %v", summary, err)
+	}
+}
+
+func TestTextureFilter_Matches(t *testing.T) {
+	hero := &ImageMetadata{Materials: models.NewMaterialSummary(1, []string{"pbr"}, []models.TextureSize{{Width: 4096, Height: 4096}})}
+	mobile := &ImageMetadata{Materials: models.NewMaterialSummary(1, []string{"phong"}, []models.TextureSize{{Width: 512, Height: 512}})}
+	twoK, err := ParseTextureFilter("2K", "")
+	if err != nil || twoK.MinResolution != 2048 {
+		t.Fatalf("ParseTextureFilter = %+v, %v", twoK, err)
+	}
+
+	cases := []struct {
+		name   string
+		img    *ImageMetadata
+		filter TextureFilter
+		want   bool
+	}{
+		{"4K passes 2K", hero, twoK, true},
+		{"512 fails 2K", mobile, twoK, false},
+		{"PBR", hero, TextureFilter{Shading: "pbr"}, true},
+		{"not PBR", mobile, TextureFilter{Shading: "pbr"}, false},
+		{"unknown materials", &ImageMetadata{}, twoK, false},
+		{"no filter", &ImageMetadata{}, TextureFilter{}, true},
+	}
+	for _, tc := range cases {
+		if got := tc.filter.Matches(tc.img); got != tc.want {
+			t.Errorf("%s: Matches = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"big", ""}, {"", "toon"}} {
+		if _, err := ParseTextureFilter(bad[0], bad[1]); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestMaterials_RoundTrip(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	summary := models.NewMaterialSummary(2, []string{"pbr"}, []models.TextureSize{
+		{Width: 2048, Height: 2048}, {Width: 2048, Height: 2048}, {Width: 2048, Height: 2048}, {Width: 2048, Height: 2048},
+	})
+	if summary.Summary != "4x 2K PBR textures" {
+		t.Errorf("unexpected summary %q", summary.Summary)
+	}
+	obj := &models.Image{
+		ID: "obj-1", Title: "T", Artist: "A", Category: "props", Type: models.ImageType3D, UploadedAt: time.Now(),
+		Materials: summary,
+	}
+	if err := svc.AppendToIndex(obj); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	stored, err := svc.GetImageByID("obj-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if !reflect.DeepEqual(stored.Materials, summary) {
+		t.Errorf("expected materials %+v, got %+v", summary, stored.Materials)
+	}
+
+	untextured := models.NewMaterialSummary(1, []string{"phong"}, nil)
+	if untextured.Summary != "1 Phong material, untextured" {
+		t.Errorf("unexpected summary %q", untextured.Summary)
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// STL binary layout: 80-byte header, triangle count, 50 bytes per triangle
+const (
+	stlHeaderSize   = 84
+	stlTriangleSize = 50
+)
+
+// CountModelTriangles counts the triangles a 3D model file draws. glTF
+// (.gltf, .glb) counts every mesh instance of the default scene; OBJ faces
+// with n vertices count as n-2 triangles.
+func CountModelTriangles(path string) (int, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gltf", ".glb":
+		return countGLTFTriangles(path)
+	case ".obj":
+		return countOBJTriangles(path)
+	case ".stl":
+		return countSTLTriangles(path)
+	default:
+		return 0, errModelFormat
+	}
+}
+
+func countGLTFTriangles(path string) (int, error) {
+	doc, err := readGLTF(path)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	doc.walkMeshes(func(mesh *gltfMesh, _ mat4) {
+		for i := range mesh.Primitives {
+			total += doc.triangles(&mesh.Primitives[i])
+		}
+	})
+	return total, nil
+}
+
+func countOBJTriangles(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open model: %w", err)
+	}
+	defer file.Close()
+
+	total := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxIndexLine)
+	for scanner.Scan() {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "f ")
+		if !ok {
+			continue
+		}
+		if n := len(strings.Fields(rest)); n >= 3 {
+			total += n - 2
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read model: %w", err)
+	}
+	return total, nil
+}
+
+// countSTLTriangles reads the count of a binary STL, or counts the facets of
+// an ASCII one. Binary files may also start with "solid", so the size
+// decides.
+func countSTLTriangles(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open model: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read model: %w", err)
+	}
+	var header [stlHeaderSize]byte
+	if _, err := io.ReadFull(file, header[:]); err == nil {
+		count := binary.LittleEndian.Uint32(header[80:])
+		if info.Size() == stlHeaderSize+int64(count)*stlTriangleSize {
+			return int(count), nil
+		}
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(header[:]), []byte("solid")) {
+		return 0, fmt.Errorf("invalid STL file")
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to read model: %w", err)
+	}
+	total := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxIndexLine)
+	for scanner.Scan() {
+		if strings.HasPrefix(strings.TrimSpace(scanner.Text()), "facet") {
+			total++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read model: %w", err)
+	}
+	return total, nil
+}
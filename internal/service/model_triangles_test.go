@@ -0,0 +1,64 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCountModelTriangles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// A mesh of 12 indexed triangles plus a 4-vertex strip, instanced twice
+	gltf := `{
+		"scenes": [{"nodes": [0, 1]}],
+		"nodes": [{"mesh": 0}, {"mesh": 0, "translation": [2, 0, 0]}],
+		"meshes": [{"primitives": [
+			{"attributes": {"POSITION": 0}, "indices": 1},
+			{"attributes": {"POSITION": 2}, "mode": 5},
+			{"attributes": {"POSITION": 2}, "mode": 1}
+		]}],
+		"accessors": [{"count": 8}, {"count": 36}, {"count": 4}]
+	}`
+
+	// Binary STL whose 80-byte header happens to start with "solid"
+	stl := make([]byte, stlHeaderSize+3*stlTriangleSize)
+	copy(stl, "solid exported")
+	binary.LittleEndian.PutUint32(stl[80:], 3)
+
+	asciiSTL := "solid cube\n  facet normal 0 0 1\n    outer loop\n    endloop\n  endfacet\n" +
+		"  facet normal 0 1 0\n  endfacet\nendsolid cube\n"
+
+	cases := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"glTF", write("crate.gltf", []byte(gltf)), 2 * (12 + 2)},
+		{"OBJ triangles and quads", write("crate.obj", []byte("v 0 0 0\nf 1 2 3\nf 1/1 2/2 3/3 4/4\nf 1 2\n")), 3},
+		{"binary STL", write("crate.stl", stl), 3},
+		{"ASCII STL", write("cube.stl", []byte(asciiSTL)), 2},
+	}
+	for _, tc := range cases {
+		got, err := CountModelTriangles(tc.path)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: CountModelTriangles = %d, %v; want %d", tc.name, got, err, tc.want)
+		}
+	}
+
+	if _, err := CountModelTriangles(write("scene.blend", []byte("BLENDER"))); !errors.Is(err, errModelFormat) {
+		t.Errorf("expected errModelFormat for .blend, got %v", err)
+	}
+	if _, err := CountModelTriangles(write("broken.stl", []byte("not a mesh"))); err == nil {
+		t.Error("expected an error for an invalid STL")
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+)
+
+// PolygonFilter restricts 3D objects by triangle count and LOD tag; zero
+// fields match everything
+type PolygonFilter struct {
+	MinTriangles int
+	MaxTriangles int
+	LOD          string // normalized LOD tag (see models.NormalizeLODTag)
+}
+
+// IsZero reports whether the filter matches everything
+func (f PolygonFilter) IsZero() bool {
+	return f == PolygonFilter{}
+}
+
+// Matches reports whether an image passes the filter. Images without a
+// known triangle count fail triangle limits.
+func (f PolygonFilter) Matches(img *ImageMetadata) bool {
+	if f.LOD != "" && !slices.Contains(img.LODTags, f.LOD) {
+		return false
+	}
+	if f.MinTriangles == 0 && f.MaxTriangles == 0 {
+		return true
+	}
+	if img.Triangles <= 0 || img.Triangles < f.MinTriangles {
+		return false
+	}
+	return f.MaxTriangles <= 0 || img.Triangles <= f.MaxTriangles
+}
+
+// Validate checks that the filter bounds make sense
+func (f PolygonFilter) Validate() error {
+	if f.MinTriangles < 0 || f.MaxTriangles < 0 {
+		return fmt.Errorf("triangle filters cannot be negative")
+	}
+	if f.MaxTriangles > 0 && f.MaxTriangles < f.MinTriangles {
+		return fmt.Errorf("maximum triangles is smaller than the minimum")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestPolygonFilter_Matches(t *testing.T) {
+	crate := &ImageMetadata{Triangles: 1200, LODTags: []string{"lod0", "game-ready"}}
+	statue := &ImageMetadata{Triangles: 2_500_000, LODTags: []string{"hero"}}
+	unknown := &ImageMetadata{LODTags: []string{"game-ready"}}
+
+	cases := []struct {
+		name   string
+		img    *ImageMetadata
+		filter PolygonFilter
+		want   bool
+	}{
+		{"within budget", crate, PolygonFilter{MaxTriangles: 20000}, true},
+		{"over budget", statue, PolygonFilter{MaxTriangles: 20000}, false},
+		{"game-ready", crate, PolygonFilter{LOD: "game-ready"}, true},
+		{"not game-ready", statue, PolygonFilter{LOD: "game-ready"}, false},
+		{"unknown count, LOD only", unknown, PolygonFilter{LOD: "game-ready"}, true},
+		{"unknown count, budget", unknown, PolygonFilter{MaxTriangles: 20000}, false},
+		{"minimum", statue, PolygonFilter{MinTriangles: 1_000_000}, true},
+		{"no filter", &ImageMetadata{}, PolygonFilter{}, true},
+	}
+	for _, tc := range cases {
+		if got := tc.filter.Matches(tc.img); got != tc.want {
+			t.Errorf("%s: Matches = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	if err := (PolygonFilter{MinTriangles: 500, MaxTriangles: 100}).Validate(); err == nil {
+		t.Error("expected an error for max below min")
+	}
+}
+
+func TestPolygonBudget_RoundTripAndUpdate(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	obj := &models.Image{
+		ID: "obj-1", Title: "T", Artist: "A", Category: "props", Type: models.ImageType3D, UploadedAt: time.Now(),
+		Triangles: 1200, LODTags: []string{"lod0", "game-ready"},
+	}
+	if err := svc.AppendToIndex(obj); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	stored, err := svc.GetImageByID("obj-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if stored.Triangles != 1200 || !reflect.DeepEqual(stored.LODTags, obj.LODTags) {
+		t.Fatalf("unexpected polygon budget %d %v", stored.Triangles, stored.LODTags)
+	}
+
+	triangles, lods := 900, []string{"lod1"}
+	updated, err := svc.UpdateImage("obj-1", 0, ImageUpdate{Triangles: &triangles, LODTags: &lods})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if updated.Triangles != 900 || !reflect.DeepEqual(updated.LODTags, lods) {
+		t.Errorf("unexpected polygon budget %d %v", updated.Triangles, updated.LODTags)
+	}
+
+	// Empty values clear
+	zero, none := 0, []string{}
+	updated, err = svc.UpdateImage("obj-1", 0, ImageUpdate{Triangles: &zero, LODTags: &none})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if updated.Triangles != 0 || updated.LODTags != nil {
+		t.Errorf("expected a cleared polygon budget, got %d %v", updated.Triangles, updated.LODTags)
+	}
+
+	img := &models.Image{ID: "img-1", Title: "T", Artist: "A", Category: "props", Type: models.ImageType2D, UploadedAt: time.Now()}
+	if err := svc.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	if _, err := svc.UpdateImage("img-1", 0, ImageUpdate{LODTags: &lods}); !errors.Is(err, ErrPolygonBudgetUnsupported) {
+		t.Errorf("expected ErrPolygonBudgetUnsupported for a 2D image, got %v", err)
+	}
+}
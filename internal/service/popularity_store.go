@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// PopularityStore counts views and downloads per image. Counts are kept in
+// memory and saved every interval by Run (and on shutdown), so serving a file
+// never waits on disk. Shared with other instances, a save adds the counts
+// since the last one to the file's.
+type PopularityStore struct {
+	file         *sharedFile
+	stats        map[string]*models.Popularity
+	pending      map[string]*models.Popularity // counted since the last save
+	forgotten    map[string]bool               // forgotten since the last save
+	dirty        bool
+	lastModified time.Time
+	mutex        sync.RWMutex
+}
+
+func NewPopularityStore(path string) *PopularityStore {
+	return &PopularityStore{
+		file:      newSharedFile(path),
+		stats:     make(map[string]*models.Popularity),
+		pending:   make(map[string]*models.Popularity),
+		forgotten: make(map[string]bool),
+	}
+}
+
+// SetCoordinator shares the counts with the other instances using c
+func (s *PopularityStore) SetCoordinator(c *Coordinator) {
+	s.file.share(c)
+}
+
+// Load reads saved counts from disk, if the file exists
+func (s *PopularityStore) Load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.load()
+}
+
+// load replaces the counts with the file's plus those since the last save.
+// Caller must hold the lock.
+func (s *PopularityStore) load() error {
+	data, err := s.file.read()
+	if err != nil {
+		return fmt.Errorf("failed to read popularity: %w", err)
+	}
+	stats := make(map[string]*models.Popularity)
+	if data != nil {
+		if err := json.Unmarshal(data, &stats); err != nil {
+			return fmt.Errorf("failed to parse popularity: %w", err)
+		}
+		if info, err := os.Stat(s.file.path); err == nil && info.ModTime().After(s.lastModified) {
+			s.lastModified = info.ModTime()
+		}
+	}
+
+	for id := range s.forgotten {
+		delete(stats, id)
+	}
+	for id, delta := range s.pending {
+		p, ok := stats[id]
+		if !ok {
+			p = &models.Popularity{}
+			stats[id] = p
+		}
+		p.Views += delta.Views
+		p.Downloads += delta.Downloads
+		p.LastViewed = laterTime(p.LastViewed, delta.LastViewed)
+		p.LastAccessed = laterTime(p.LastAccessed, delta.LastAccessed)
+	}
+	s.stats = stats
+	return nil
+}
+
+func laterTime(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
+
+// refresh reloads the counts if another instance saved them. If that
+// fails, the ones in memory are served.
+func (s *PopularityStore) refresh() {
+	if s.file.changed() {
+		s.mutex.Lock()
+		s.load()
+		s.mutex.Unlock()
+	}
+}
+
+// RecordView counts a view of an image at now
+func (s *PopularityStore) RecordView(imageID string, now time.Time) {
+	s.record(imageID, now, false)
+}
+
+// RecordDownload counts a download of an image at now
+func (s *PopularityStore) RecordDownload(imageID string, now time.Time) {
+	s.record(imageID, now, true)
+}
+
+func (s *PopularityStore) record(imageID string, now time.Time, download bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, counts := range []map[string]*models.Popularity{s.stats, s.pending} {
+		p, ok := counts[imageID]
+		if !ok {
+			p = &models.Popularity{}
+			counts[imageID] = p
+		}
+		if download {
+			p.Downloads++
+		} else {
+			p.Views++
+			p.LastViewed = &now
+		}
+		p.LastAccessed = &now
+	}
+	s.dirty = true
+	s.lastModified = now
+}
+
+// Get returns the counts of an image, nil if it was never served
+func (s *PopularityStore) Get(imageID string) *models.Popularity {
+	s.refresh()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	p, ok := s.stats[imageID]
+	if !ok {
+		return nil
+	}
+	copied := *p
+	return &copied
+}
+
+// RecentlyViewed returns the IDs of the most recently viewed images, most
+// recent first
+func (s *PopularityStore) RecentlyViewed(limit int) []string {
+	s.refresh()
+	s.mutex.RLock()
+	type viewed struct {
+		id string
+		at time.Time
+	}
+	var all []viewed
+	for id, p := range s.stats {
+		if p.LastViewed != nil {
+			all = append(all, viewed{id, *p.LastViewed})
+		}
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].at.Equal(all[j].at) {
+			return all[i].at.After(all[j].at)
+		}
+		return all[i].id < all[j].id
+	})
+	if len(all) > limit {
+		all = all[:limit]
+	}
+	ids := make([]string, len(all))
+	for i, v := range all {
+		ids[i] = v.id
+	}
+	return ids
+}
+
+// Forget drops the counts of a deleted image
+func (s *PopularityStore) Forget(imageID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.stats, imageID)
+	delete(s.pending, imageID)
+	s.forgotten[imageID] = true
+	s.dirty = true
+}
+
+// LastModified returns when a count last changed
+func (s *PopularityStore) LastModified() time.Time {
+	s.refresh()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.lastModified
+}
+
+// Save writes the counts to disk atomically if they changed since the last save
+func (s *PopularityStore) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.dirty {
+		return nil
+	}
+
+	end, err := s.file.begin(s.load)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	data, err := json.Marshal(s.stats)
+	if err != nil {
+		return fmt.Errorf("failed to encode popularity: %w", err)
+	}
+	if err := s.file.write(data, 0644); err != nil {
+		return fmt.Errorf("failed to write popularity: %w", err)
+	}
+	s.pending = make(map[string]*models.Popularity)
+	s.forgotten = make(map[string]bool)
+	s.dirty = false
+	return nil
+}
+
+// Run saves the counts every interval until ctx is done
+func (s *PopularityStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Save()
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPopularityStore_CountsAndRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "popularity.json")
+	store := NewPopularityStore(path)
+
+	base := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+	store.RecordView("a", base)
+	store.RecordView("b", base.Add(time.Minute))
+	store.RecordView("a", base.Add(2*time.Minute))
+	store.RecordDownload("c", base.Add(3*time.Minute))
+
+	a := store.Get("a")
+	if a == nil || a.Views != 2 || a.Downloads != 0 || !a.LastViewed.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("unexpected counts for a: %+v", a)
+	}
+	if c := store.Get("c"); c == nil || c.Downloads != 1 || c.LastViewed != nil || c.Score() != 3 {
+		t.Errorf("unexpected counts for c: %+v", c)
+	}
+	if store.Get("never") != nil {
+		t.Error("expected nil for an image that was never served")
+	}
+
+	// Downloads are not views
+	if recent := store.RecentlyViewed(10); len(recent) != 2 || recent[0] != "a" || recent[1] != "b" {
+		t.Errorf("expected a, b most recent first, got %v", recent)
+	}
+	if recent := store.RecentlyViewed(1); len(recent) != 1 || recent[0] != "a" {
+		t.Errorf("expected the limit to apply, got %v", recent)
+	}
+
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	reloaded := NewPopularityStore(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if a := reloaded.Get("a"); a == nil || a.Views != 2 {
+		t.Errorf("expected counts to survive a reload, got %+v", a)
+	}
+
+	reloaded.Forget("a")
+	if reloaded.Get("a") != nil {
+		t.Error("expected forgotten image to have no counts")
+	}
+}
+
+func TestPopularityStore_SaveOnlyWhenChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "popularity.json")
+	store := NewPopularityStore(path)
+
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected no file before anything was counted")
+	}
+
+	store.RecordView("a", time.Now())
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected counts to be saved: %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package service
+
+import "sort"
+
+// SelectPosterView picks the view that represents a 3D object in listings:
+// the suggested view (the AI's pick or a manual choice) if the object has
+// it, otherwise the front view, the other standard views in order and
+// finally the first view by name (e.g. a turntable frame). It returns ""
+// for an object without views.
+func SelectPosterView(views map[string]string, suggested string) string {
+	if _, ok := views[suggested]; ok && suggested != "" {
+		return suggested
+	}
+	for _, view := range Views6 {
+		if _, ok := views[view]; ok {
+			return view
+		}
+	}
+
+	names := make([]string, 0, len(views))
+	for view := range views {
+		names = append(names, view)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
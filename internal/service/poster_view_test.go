@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestSelectPosterView(t *testing.T) {
+	six := map[string]string{"front": "a", "back": "b", "left": "c", "right": "d", "top": "e", "bottom": "f"}
+	tests := []struct {
+		name      string
+		views     map[string]string
+		suggested string
+		want      string
+	}{
+		{"suggested", six, "left", "left"},
+		{"unknown suggestion", six, "side", "front"},
+		{"no suggestion", six, "", "front"},
+		{"no front", map[string]string{"top": "e", "back": "b"}, "", "back"},
+		{"turntable frames", map[string]string{"angle-090": "x", "angle-045": "y"}, "", "angle-045"},
+		{"no views", nil, "front", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SelectPosterView(tt.views, tt.suggested); got != tt.want {
+				t.Errorf("SelectPosterView = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPosterView_Update(t *testing.T) {
+	svc := NewIndexService(t.TempDir())
+	if err := svc.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	obj := &models.Image{
+		ID: "obj-1", Title: "T", Artist: "A", Category: "props", Type: models.ImageType3D, UploadedAt: time.Now(),
+		FolderPath: "props/obj-1",
+		Views:      map[string]string{"front": "props/obj-1/front.png", "back": "props/obj-1/back.png", "left": "props/obj-1/left.png", "right": "props/obj-1/right.png"},
+		PosterView: "left",
+	}
+	img := &models.Image{ID: "img-1", Title: "T", Artist: "A", Category: "props", Type: models.ImageType2D, UploadedAt: time.Now()}
+	for _, image := range []*models.Image{obj, img} {
+		if err := svc.AppendToIndex(image); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	stored, err := svc.GetImageByID("obj-1")
+	if err != nil {
+		t.Fatalf("GetImageByID failed: %v", err)
+	}
+	if stored.PosterView != "left" || stored.PreviewThumbnail() != "props/obj-1/left_thumb.jpg" {
+		t.Errorf("poster = %q (%s), want left", stored.PosterView, stored.PreviewThumbnail())
+	}
+
+	back, top, reset := "back", "top", ""
+	updated, err := svc.UpdateImage("obj-1", 0, ImageUpdate{PosterView: &back})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if updated.PreviewThumbnail() != "props/obj-1/back_thumb.jpg" {
+		t.Errorf("thumbnail = %s, want the back view's", updated.PreviewThumbnail())
+	}
+	if _, err := svc.UpdateImage("obj-1", 0, ImageUpdate{PosterView: &top}); !errors.Is(err, ErrUnknownPosterView) {
+		t.Errorf("err = %v, want ErrUnknownPosterView", err)
+	}
+	if _, err := svc.UpdateImage("img-1", 0, ImageUpdate{PosterView: &back}); !errors.Is(err, ErrPosterViewUnsupported) {
+		t.Errorf("err = %v, want ErrPosterViewUnsupported", err)
+	}
+
+	// Resetting falls back to the front view
+	updated, err = svc.UpdateImage("obj-1", 0, ImageUpdate{PosterView: &reset})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if updated.PosterView != "" || updated.PreviewThumbnail() != "props/obj-1/front_thumb.jpg" {
+		t.Errorf("poster = %q (%s), want the front view", updated.PosterView, updated.PreviewThumbnail())
+	}
+}
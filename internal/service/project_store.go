@@ -0,0 +1,195 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// ErrProjectNotFound is returned for an unknown project ID
+var ErrProjectNotFound = errors.New("project not found")
+
+// ProjectStore keeps the projects, persisted as JSON next to the index
+type ProjectStore struct {
+	file     *sharedFile
+	projects map[string]models.Project
+	mutex    sync.RWMutex
+}
+
+func NewProjectStore(path string) *ProjectStore {
+	return &ProjectStore{
+		file:     newSharedFile(path),
+		projects: make(map[string]models.Project),
+	}
+}
+
+// SetCoordinator shares the projects with the other instances using c
+func (s *ProjectStore) SetCoordinator(c *Coordinator) {
+	s.file.share(c)
+}
+
+// Load reads stored projects from disk, if the file exists
+func (s *ProjectStore) Load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.load()
+}
+
+// load replaces the projects with the file's. Caller must hold the lock.
+func (s *ProjectStore) load() error {
+	data, err := s.file.read()
+	if err != nil {
+		return fmt.Errorf("failed to read projects: %w", err)
+	}
+	projects := make(map[string]models.Project)
+	if data != nil {
+		if err := json.Unmarshal(data, &projects); err != nil {
+			return fmt.Errorf("failed to parse projects: %w", err)
+		}
+	}
+	s.projects = projects
+	return nil
+}
+
+// refresh reloads the projects if another instance changed them. If that
+// fails, the ones in memory are served.
+func (s *ProjectStore) refresh() {
+	if s.file.changed() {
+		s.mutex.Lock()
+		s.load()
+		s.mutex.Unlock()
+	}
+}
+
+// Get returns a project by ID
+func (s *ProjectStore) Get(id string) (models.Project, error) {
+	s.refresh()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	project, ok := s.projects[id]
+	if !ok {
+		return models.Project{}, ErrProjectNotFound
+	}
+	return project, nil
+}
+
+// List returns all projects ordered by ID
+func (s *ProjectStore) List() []models.Project {
+	s.refresh()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	projects := make([]models.Project, 0, len(s.projects))
+	for _, project := range s.projects {
+		projects = append(projects, project)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].ID < projects[j].ID })
+	return projects
+}
+
+// Put validates and stores a project, creating it or replacing the existing
+// one with the same ID, and persists the store. created reports whether the
+// project is new. The legal hold is kept (see SetLegalHold).
+func (s *ProjectStore) Put(p models.Project) (project models.Project, created bool, err error) {
+	p.Normalize()
+	if err := p.Validate(); err != nil {
+		return p, false, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	end, err := s.file.begin(s.load)
+	if err != nil {
+		return p, false, err
+	}
+	defer end()
+
+	previous, exists := s.projects[p.ID]
+	p.UpdatedAt = time.Now()
+	p.CreatedAt = p.UpdatedAt
+	p.LegalHold = false
+	if exists {
+		p.CreatedAt = previous.CreatedAt
+		p.LegalHold = previous.LegalHold
+	}
+
+	s.projects[p.ID] = p
+	if err := s.save(); err != nil {
+		if exists {
+			s.projects[p.ID] = previous
+		} else {
+			delete(s.projects, p.ID)
+		}
+		return p, false, err
+	}
+	return p, !exists, nil
+}
+
+// SetLegalHold places or clears the legal hold of a project
+func (s *ProjectStore) SetLegalHold(id string, hold bool) (models.Project, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	end, err := s.file.begin(s.load)
+	if err != nil {
+		return models.Project{}, err
+	}
+	defer end()
+
+	previous, ok := s.projects[id]
+	if !ok {
+		return models.Project{}, ErrProjectNotFound
+	}
+	p := previous
+	p.LegalHold = hold
+	p.UpdatedAt = time.Now()
+	s.projects[id] = p
+	if err := s.save(); err != nil {
+		s.projects[id] = previous
+		return previous, err
+	}
+	return p, nil
+}
+
+// Delete removes a project. Images keep their project field. A project on
+// legal hold can't be deleted.
+func (s *ProjectStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	end, err := s.file.begin(s.load)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	previous, ok := s.projects[id]
+	if !ok {
+		return ErrProjectNotFound
+	}
+	if previous.LegalHold {
+		return ErrLegalHold
+	}
+	delete(s.projects, id)
+	if err := s.save(); err != nil {
+		s.projects[id] = previous
+		return err
+	}
+	return nil
+}
+
+// save writes all projects to disk atomically. Caller must hold the lock.
+func (s *ProjectStore) save() error {
+	data, err := json.MarshalIndent(s.projects, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode projects: %w", err)
+	}
+
+	if err := s.file.write(data, 0644); err != nil {
+		return fmt.Errorf("failed to write projects: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestProjectStore_PutAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "projects.json")
+	store := NewProjectStore(path)
+
+	created, isNew, err := store.Put(models.Project{ID: " Acme ", Name: "Acme Corp", DefaultTags: []string{"acme", " "}, Categories: []string{"Products"}})
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if !isNew || created.ID != "acme" || len(created.DefaultTags) != 1 || created.Categories[0] != "products" {
+		t.Errorf("expected a new normalized project, got %+v (new: %v)", created, isNew)
+	}
+
+	updated, isNew, err := store.Put(models.Project{ID: "acme", Name: "Acme", DefaultVisibility: models.VisibilityPrivate})
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if isNew || !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("expected replacement to keep the creation time, got %+v (new: %v)", updated, isNew)
+	}
+
+	if _, _, err := store.Put(models.Project{ID: "bad id", Name: "x"}); err == nil {
+		t.Error("expected an error for an invalid ID")
+	}
+
+	reloaded := NewProjectStore(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	project, err := reloaded.Get("acme")
+	if err != nil || project.Name != "Acme" || project.DefaultVisibility != models.VisibilityPrivate {
+		t.Errorf("unexpected project after reload: %+v, %v", project, err)
+	}
+
+	if err := reloaded.Delete("acme"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := reloaded.Get("acme"); !errors.Is(err, ErrProjectNotFound) {
+		t.Errorf("expected ErrProjectNotFound after delete, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+const (
+	// sharpnessSize is the longest side images are scaled to before
+	// measuring sharpness, so it is judged at a common viewing size
+	sharpnessSize = 1024
+	// exposureSize is the longest side images are scaled to before
+	// measuring exposure
+	exposureSize = 256
+	// noiseCrop is the side of the center crop noise is estimated on, at full
+	// resolution (scaling down would average the noise away)
+	noiseCrop = 512
+)
+
+// Weights of the metrics in the quality score
+const (
+	sharpnessWeight  = 0.4
+	exposureWeight   = 0.25
+	noiseWeight      = 0.15
+	resolutionWeight = 0.2
+)
+
+// MeasureImageFile measures the technical quality of an image file and
+// computes its visual hash, decoding it once
+func MeasureImageFile(path string) (*models.Quality, string, error) {
+	img, err := imaging.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open image: %w", err)
+	}
+	quality := MeasureQuality(img)
+	return &quality, VisualHash(img), nil
+}
+
+// MeasureQuality scores an image's sharpness (variance of the Laplacian),
+// exposure (mean brightness and clipped highlights and shadows), noise
+// (Immerkær's fast noise estimate) and resolution
+func MeasureQuality(img image.Image) models.Quality {
+	bounds := img.Bounds()
+	q := models.Quality{
+		Sharpness:  sharpness(grayscale(imaging.Fit(img, sharpnessSize, sharpnessSize, imaging.Box))),
+		Resolution: resolutionScore(bounds.Dx(), bounds.Dy()),
+	}
+
+	q.Noise = noiseLevel(grayscale(imaging.CropCenter(img, min(noiseCrop, bounds.Dx()), min(noiseCrop, bounds.Dy()))))
+	q.Exposure = exposure(grayscale(imaging.Fit(img, exposureSize, exposureSize, imaging.Box)))
+
+	score := sharpnessWeight*q.Sharpness + exposureWeight*q.Exposure + noiseWeight*(1-q.Noise) + resolutionWeight*q.Resolution
+	q.Score = int(math.Round(100 * score))
+	return q
+}
+
+// lumaImage is an image's luma, row by row
+type lumaImage struct {
+	pix  []float64
+	w, h int
+}
+
+func grayscale(img *image.NRGBA) lumaImage {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	luma := lumaImage{pix: make([]float64, w*h), w: w, h: h}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x+img.Bounds().Min.X, y+img.Bounds().Min.Y)
+			luma.pix[y*w+x] = 0.299*float64(img.Pix[i]) + 0.587*float64(img.Pix[i+1]) + 0.114*float64(img.Pix[i+2])
+		}
+	}
+	return luma
+}
+
+// sharpness maps the variance of the Laplacian to 0-1; blurred photos
+// typically vary by less than 50, sharp ones by several hundred
+func sharpness(luma lumaImage) float64 {
+	if luma.w < 3 || luma.h < 3 {
+		return 0
+	}
+	var sum, sumSq float64
+	n := 0
+	for y := 1; y < luma.h-1; y++ {
+		for x := 1; x < luma.w-1; x++ {
+			i := y*luma.w + x
+			lap := luma.pix[i-1] + luma.pix[i+1] + luma.pix[i-luma.w] + luma.pix[i+luma.w] - 4*luma.pix[i]
+			sum += lap
+			sumSq += lap * lap
+			n++
+		}
+	}
+	mean := sum / float64(n)
+	variance := sumSq/float64(n) - mean*mean
+	return variance / (variance + 100)
+}
+
+// exposure is 1 for a mid-toned image without clipping, less the further
+// the mean brightness is from middle grey and the more pixels are clipped
+func exposure(luma lumaImage) float64 {
+	if len(luma.pix) == 0 {
+		return 0
+	}
+	var sum float64
+	clipped := 0
+	for _, v := range luma.pix {
+		sum += v
+		if v <= 4 || v >= 251 {
+			clipped++
+		}
+	}
+	mean := sum / float64(len(luma.pix))
+	balance := 1 - math.Abs(mean-118)/118
+	clipping := 1 - math.Min(1, 5*float64(clipped)/float64(len(luma.pix)))
+	return math.Max(0, balance) * clipping
+}
+
+// noiseLevel estimates the noise standard deviation (J. Immerkær, "Fast
+// Noise Variance Estimation", 1996) and maps 0-20 levels to 0-1
+func noiseLevel(luma lumaImage) float64 {
+	if luma.w < 3 || luma.h < 3 {
+		return 0
+	}
+	var sum float64
+	for y := 1; y < luma.h-1; y++ {
+		for x := 1; x < luma.w-1; x++ {
+			i := y*luma.w + x
+			p := luma.pix
+			v := p[i-luma.w-1] - 2*p[i-luma.w] + p[i-luma.w+1] -
+				2*p[i-1] + 4*p[i] - 2*p[i+1] +
+				p[i+luma.w-1] - 2*p[i+luma.w] + p[i+luma.w+1]
+			sum += math.Abs(v)
+		}
+	}
+	sigma := math.Sqrt(math.Pi/2) * sum / (6 * float64((luma.w-2)*(luma.h-2)))
+	return math.Min(1, sigma/20)
+}
+
+// resolutionScore maps megapixels to 0-1: 0.33 at 2 MP, 0.75 at 12 MP
+func resolutionScore(width, height int) float64 {
+	mp := float64(width*height) / 1e6
+	return mp / (mp + 4)
+}
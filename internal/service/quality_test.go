@@ -0,0 +1,68 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// checkerboard draws 8px squares of lo and hi grey
+func checkerboard(size int, lo, hi uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			v := lo
+			if (x/8+y/8)%2 == 0 {
+				v = hi
+			}
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestMeasureQuality(t *testing.T) {
+	sharp := checkerboard(512, 60, 180)
+	blurred := imaging.Blur(sharp, 6)
+	dark := checkerboard(512, 0, 20)
+
+	noisy := imaging.Clone(sharp)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < len(noisy.Pix); i += 4 {
+		v := int(noisy.Pix[i]) + rng.Intn(61) - 30
+		v = max(0, min(255, v))
+		noisy.Pix[i], noisy.Pix[i+1], noisy.Pix[i+2] = uint8(v), uint8(v), uint8(v)
+	}
+
+	sharpQ, blurredQ := MeasureQuality(sharp), MeasureQuality(blurred)
+	if sharpQ.Sharpness <= blurredQ.Sharpness || sharpQ.Score <= blurredQ.Score {
+		t.Errorf("expected the sharp image to score higher, got %v and %v", sharpQ, blurredQ)
+	}
+	if darkQ := MeasureQuality(dark); darkQ.Exposure >= sharpQ.Exposure {
+		t.Errorf("expected the dark image to be worse exposed, got %.2f and %.2f", darkQ.Exposure, sharpQ.Exposure)
+	}
+	if noisyQ := MeasureQuality(noisy); noisyQ.Noise <= blurredQ.Noise {
+		t.Errorf("expected the noisy image to be noisier, got %.2f and %.2f", noisyQ.Noise, blurredQ.Noise)
+	}
+	if sharpQ.Score < 0 || sharpQ.Score > 100 {
+		t.Errorf("expected a 0-100 score, got %d", sharpQ.Score)
+	}
+}
+
+func TestParseQuality(t *testing.T) {
+	q := models.Quality{Score: 72, Sharpness: 0.91, Exposure: 0.8, Noise: 0.12, Resolution: 0.75}
+	parsed, err := models.ParseQuality(q.String())
+	if err != nil {
+		t.Fatalf("ParseQuality failed: %v", err)
+	}
+	if *parsed != q {
+		t.Errorf("expected %v, got %v", q, *parsed)
+	}
+	if _, err := models.ParseQuality("excellent"); err == nil {
+		t.Error("expected an error for unparseable quality")
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// recencyHalfLife is how long after upload the recency signal halves
+const recencyHalfLife = 30 * 24 * time.Hour
+
+// ranker combines a result's AI relevance with the other signals of a set of
+// ranking weights
+type ranker struct {
+	weights    models.RankingWeights
+	embeddings *EmbeddingStore
+	query      []float32 // query embedding; nil leaves the embedding signal out
+	now        time.Time
+}
+
+// SetRanking configures the default ranking weights, and the embeddings and
+// embedder the embedding signal compares. Without embeddings the embedding
+// weight is ignored.
+func (s *SearchService) SetRanking(weights models.RankingWeights, embeddings *EmbeddingStore, embedder Embedder) {
+	s.weights = weights
+	s.embeddings = embeddings
+	s.embedder = embedder
+}
+
+// RankingWeights returns the default ranking weights
+func (s *SearchService) RankingWeights() models.RankingWeights {
+	return s.weights
+}
+
+// ranker returns the ranker of a search: the filter's weights, or the
+// configured ones. It returns nil when only the AI's relevance counts.
+func (s *SearchService) ranker(ctx context.Context, query string, filter SearchFilter) *ranker {
+	weights := s.weights
+	if filter.Weights != nil {
+		weights = *filter.Weights
+	}
+	if weights.IsDefault() {
+		return nil
+	}
+
+	r := &ranker{weights: weights, embeddings: s.embeddings, now: time.Now()}
+	if weights.Embedding > 0 && s.embeddings != nil && s.embedder != nil {
+		vec, err := s.embedder.Embed(ctx, EmbedInput{Text: query})
+		if err != nil {
+			s.logger.Warnf("Failed to embed search query, ranking without embeddings: %v", err)
+		} else {
+			r.query = vec
+		}
+	}
+	return r
+}
+
+// score returns the weighted average of a result's signals; a nil ranker
+// keeps the relevance as is
+func (r *ranker) score(relevance float64, img *ImageMetadata, terms []string) float64 {
+	if r == nil {
+		return relevance
+	}
+	w := r.weights
+	score, total := 0.0, 0.0
+	add := func(weight, signal float64) {
+		score += weight * signal
+		total += weight
+	}
+	add(w.Relevance, relevance)
+	add(w.Title, termShare(terms, img.Title))
+	add(w.Tags, termShare(terms, img.Tags...))
+	add(w.Description, termShare(terms, img.Description))
+	if uploaded, err := img.UploadedTime(); err == nil {
+		add(w.Recency, math.Pow(0.5, float64(r.now.Sub(uploaded))/float64(recencyHalfLife)))
+	} else {
+		add(w.Recency, 0)
+	}
+	if r.query != nil {
+		vec, _ := r.embeddings.Get(img.ID)
+		add(w.Embedding, cosineSimilarity(r.query, vec))
+	}
+	if total == 0 {
+		return relevance
+	}
+	return math.Min(score/total, 1)
+}
+
+// termShare returns the share of terms found in any of texts
+func termShare(terms []string, texts ...string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	found := make(map[string]bool)
+	for _, text := range texts {
+		for _, h := range highlightTerms(text, terms) {
+			found[h.Term] = true
+		}
+	}
+	return float64(len(found)) / float64(len(terms))
+}
+
+// cosineSimilarity returns the cosine similarity of two vectors, 0 if they
+// differ in length or point away from each other
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return math.Max(dot/math.Sqrt(normA*normB), 0)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"io"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestFilterIndexed_RankingWeights(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	embeddings := NewEmbeddingStore(filepath.Join(t.TempDir(), "embeddings.json"))
+	embeddings.Set("old", []float32{0.2, -0.1})
+	embeddings.Set("new", []float32{0.1, 0.2}) // what mockEmbedder returns for every query
+	svc := NewSearchService(nil, nil, logger)
+	svc.SetRanking(models.DefaultRankingWeights(), embeddings, &mockEmbedder{})
+
+	byID := imagesByID([]*ImageMetadata{
+		{ID: "old", Title: "Harbor", Tags: []string{"boats", "harbor"}, UploadedAt: time.Now().Add(-60 * 24 * time.Hour).Format("2006-01-02 15:04:05")},
+		{ID: "new", Title: "Sunset over the harbor", UploadedAt: time.Now().Format("2006-01-02 15:04:05")},
+	})
+	results := func() []models.SearchResult {
+		return []models.SearchResult{{ImageID: "old", RelevanceScore: 0.9}, {ImageID: "new", RelevanceScore: 0.6}}
+	}
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-6 }
+	rank := func(weights *models.RankingWeights) []models.SearchResult {
+		filter := SearchFilter{Weights: weights}
+		return filterIndexed(results(), "harbor sunset", filter, byID, svc.ranker(context.Background(), "harbor sunset", filter))
+	}
+
+	if ranked := rank(nil); ranked[0].ImageID != "old" || ranked[0].RelevanceScore != 0.9 {
+		t.Errorf("expected the default weights to keep the AI ranking, got %+v", ranked)
+	}
+
+	ranked := rank(&models.RankingWeights{Relevance: 1, Title: 1})
+	if ranked[0].ImageID != "new" || !near(ranked[0].RelevanceScore, 0.8) || !near(ranked[1].RelevanceScore, 0.7) {
+		t.Errorf("expected title matches to rank new first, got %+v", ranked)
+	}
+
+	ranked = rank(&models.RankingWeights{Recency: 1})
+	if ranked[0].ImageID != "new" || math.Abs(ranked[1].RelevanceScore-0.25) > 0.01 {
+		t.Errorf("expected recency to halve every 30 days, got %+v", ranked)
+	}
+
+	ranked = rank(&models.RankingWeights{Embedding: 1})
+	if ranked[0].ImageID != "new" || !near(ranked[0].RelevanceScore, 1) || ranked[1].RelevanceScore != 0 {
+		t.Errorf("expected embedding similarity to rank new first, got %+v", ranked)
+	}
+
+	svc.SetRanking(models.RankingWeights{Relevance: 1, Tags: 1}, nil, nil)
+	if ranked := rank(nil); ranked[0].ImageID != "old" || !near(ranked[0].RelevanceScore, 0.7) {
+		t.Errorf("expected the configured weights to apply, got %+v", ranked)
+	}
+
+	if err := (models.RankingWeights{Title: -1, Relevance: 1}).Validate(); err == nil {
+		t.Error("expected an error for a negative weight")
+	}
+	if err := (models.RankingWeights{}).Validate(); err == nil {
+		t.Error("expected an error when no weight is positive")
+	}
+}
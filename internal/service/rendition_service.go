@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+var (
+	// ErrUnknownRendition is returned for rendition kinds an image can't have
+	ErrUnknownRendition = errors.New("unknown rendition")
+	// ErrRenditionRequired is returned when deleting a rendition the image
+	// can't do without, such as its thumbnail
+	ErrRenditionRequired = errors.New("rendition is required and cannot be deleted")
+)
+
+// RenditionService tracks the derivative files of images (thumbnails,
+// cutouts, upscales, turntables) and regenerates or deletes them
+type RenditionService struct {
+	storageService *StorageService
+	imageService   *ImageService
+	cutoutService  *CutoutService
+	upscaleService *UpscaleService
+	logger         *logrus.Logger
+}
+
+// NewRenditionService creates a rendition service
+func NewRenditionService(storage *StorageService, image *ImageService, cutouts *CutoutService, upscales *UpscaleService, logger *logrus.Logger) *RenditionService {
+	return &RenditionService{
+		storageService: storage,
+		imageService:   image,
+		cutoutService:  cutouts,
+		upscaleService: upscales,
+		logger:         logger,
+	}
+}
+
+// candidates returns the data-relative path of every rendition kind an image
+// can have, whether or not it has been generated
+func (s *RenditionService) candidates(img *ImageMetadata) map[string]string {
+	paths := make(map[string]string)
+
+	if img.FilePath != "" {
+		paths[models.RenditionThumbnail] = img.ThumbnailPath
+		paths[models.RenditionSquareThumbnail] = squareThumbnailPath(img.FilePath)
+		if img.SquareThumbnail != "" {
+			paths[models.RenditionSquareThumbnail] = img.SquareThumbnail
+		}
+		paths[models.RenditionCutout] = cutoutPath(img.FilePath)
+		paths[models.RenditionUpscale2x] = upscalePath(img.FilePath, 2)
+		paths[models.RenditionUpscale4x] = upscalePath(img.FilePath, 4)
+		return paths
+	}
+
+	for view, viewPath := range img.Views {
+		paths[models.ViewThumbnailKind(view)] = path.Join(path.Dir(viewPath), viewBaseName(viewPath)+"_thumb.jpg")
+	}
+	if front, ok := img.Views["front"]; ok {
+		paths[models.RenditionCutout] = cutoutPath(front)
+	}
+	if img.TurntablePath != "" {
+		paths[models.RenditionTurntable] = img.TurntablePath
+	}
+	return paths
+}
+
+// viewBaseName returns a view file's name without extension
+func viewBaseName(viewPath string) string {
+	base := path.Base(viewPath)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// List returns the renditions of an image that exist on disk, by kind
+func (s *RenditionService) List(img *ImageMetadata) []models.Rendition {
+	renditions := []models.Rendition{}
+	for kind, relPath := range s.candidates(img) {
+		if rendition, err := s.stat(kind, relPath); err == nil {
+			renditions = append(renditions, *rendition)
+		}
+	}
+	sort.Slice(renditions, func(i, j int) bool { return renditions[i].Kind < renditions[j].Kind })
+	return renditions
+}
+
+// stat describes a rendition file; the creation time is its modification time
+func (s *RenditionService) stat(kind, relPath string) (*models.Rendition, error) {
+	fullPath, err := s.storageService.StoredPath(relPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rendition := &models.Rendition{
+		Kind:      kind,
+		Path:      relPath,
+		URL:       fmt.Sprintf("%s?v=%d", DataURL(relPath), info.ModTime().Unix()),
+		Size:      info.Size(),
+		CreatedAt: info.ModTime(),
+	}
+	if file, err := os.Open(fullPath); err == nil {
+		if config, _, err := image.DecodeConfig(file); err == nil {
+			rendition.Width, rendition.Height = config.Width, config.Height
+		}
+		file.Close()
+	}
+	return rendition, nil
+}
+
+// Regenerate recreates one rendition of an image from its source
+func (s *RenditionService) Regenerate(ctx context.Context, img *ImageMetadata, kind string) (*models.Rendition, error) {
+	relPath, ok := s.candidates(img)[kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRendition, kind)
+	}
+
+	var err error
+	switch {
+	case kind == models.RenditionThumbnail:
+		err = s.regenerateThumbnail(img.FilePath)
+
+	case strings.HasPrefix(kind, models.RenditionThumbnail+"-"):
+		view, _ := models.ViewOfThumbnailKind(kind)
+		err = s.regenerateThumbnail(img.Views[view])
+
+	case kind == models.RenditionSquareThumbnail:
+		err = s.regenerateSquareThumbnail(img)
+
+	case kind == models.RenditionCutout:
+		if err = s.removeFile(relPath); err == nil {
+			_, err = s.cutoutService.Cutout(ctx, img)
+		}
+
+	case kind == models.RenditionUpscale2x || kind == models.RenditionUpscale4x:
+		if err = s.removeFile(relPath); err == nil {
+			_, err = s.upscaleService.Upscale(ctx, img, upscaleFactor(kind))
+		}
+
+	case kind == models.RenditionTurntable:
+		err = s.regenerateTurntable(img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to regenerate %s: %w", kind, err)
+	}
+
+	s.logger.Infof("Regenerated %s rendition of %s", kind, img.ID)
+	s.purge(img.ID, PurgeRenditionChanged, relPath)
+	return s.stat(kind, relPath)
+}
+
+// regenerateThumbnail recreates the thumbnail of a 2D image or a 3D view
+func (s *RenditionService) regenerateThumbnail(source string) error {
+	sourcePath, err := s.storageService.StoredPath(source)
+	if err != nil {
+		return err
+	}
+	_, err = s.storageService.GenerateThumbnail(sourcePath)
+	return err
+}
+
+// regenerateSquareThumbnail recrops the square thumbnail around the recorded
+// focal point, or a freshly detected one
+func (s *RenditionService) regenerateSquareThumbnail(img *ImageMetadata) error {
+	sourcePath, err := s.storageService.StoredPath(img.FilePath)
+	if err != nil {
+		return err
+	}
+	if img.SquareThumbnail != "" {
+		squarePath, err := s.storageService.StoredPath(img.SquareThumbnail)
+		if err != nil {
+			return err
+		}
+		_, _, err = s.storageService.GenerateSquareThumbnailAt(sourcePath, squarePath, img.FocalPoint)
+		return err
+	}
+	_, focal, err := s.storageService.GenerateSquareThumbnail(sourcePath, img.FocalPoint)
+	if err != nil {
+		return err
+	}
+
+	// Link it for entries indexed before square thumbnails
+	_, err = s.imageService.UpdateImage(img.ID, 0, ImageUpdate{FocalPoint: &focal})
+	return err
+}
+
+// regenerateTurntable re-renders the turntable of a 3D object
+func (s *RenditionService) regenerateTurntable(img *ImageMetadata) error {
+	views := make(map[string]string, len(img.Views))
+	for view, viewPath := range img.Views {
+		fullPath, err := s.storageService.StoredPath(viewPath)
+		if err != nil {
+			return err
+		}
+		views[view] = fullPath
+	}
+	_, err := s.storageService.GenerateTurntable(views)
+	return err
+}
+
+// Delete removes an optional rendition (cutout or upscale) of an image
+func (s *RenditionService) Delete(img *ImageMetadata, kind string) error {
+	relPath, ok := s.candidates(img)[kind]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRendition, kind)
+	}
+
+	switch kind {
+	case models.RenditionCutout:
+		if err := s.removeFile(relPath); err != nil {
+			return err
+		}
+		s.purge(img.ID, PurgeRenditionDeleted, relPath)
+		return nil
+	case models.RenditionUpscale2x, models.RenditionUpscale4x:
+		if err := s.removeFile(relPath); err != nil {
+			return err
+		}
+		s.purge(img.ID, PurgeRenditionDeleted, relPath)
+		factor := fmt.Sprintf("%dx", upscaleFactor(kind))
+		if img.Upscales[factor] == "" {
+			return nil
+		}
+		_, err := s.imageService.UpdateImage(img.ID, 0, ImageUpdate{Upscales: map[string]string{factor: ""}})
+		return err
+	default:
+		return fmt.Errorf("%w: %s", ErrRenditionRequired, kind)
+	}
+}
+
+// purge requests the purge of a replaced or deleted rendition from the CDN
+func (s *RenditionService) purge(imageID, reason, relPath string) {
+	if s.imageService != nil {
+		s.imageService.PurgeCDN(imageID, reason, relPath)
+	}
+}
+
+// removeFile deletes a rendition file; a missing file is not an error
+func (s *RenditionService) removeFile(relPath string) error {
+	fullPath, err := s.storageService.StoredPath(relPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete rendition: %w", err)
+	}
+	return nil
+}
+
+// upscaleFactor returns the factor of an upscale rendition kind
+func upscaleFactor(kind string) int {
+	if kind == models.RenditionUpscale4x {
+		return 4
+	}
+	return 2
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestRenditionService(t *testing.T) {
+	dataDir := t.TempDir()
+	logger := logrus.New()
+	index := NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	storage := NewStorageService(dataDir)
+
+	fileRel := "categories/products/p1.png"
+	filePath := filepath.Join(dataDir, filepath.FromSlash(fileRel))
+	os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err := imaging.Save(productShot(), filePath); err != nil {
+		t.Fatal(err)
+	}
+	thumbPath, err := storage.GenerateThumbnail(filePath)
+	if err != nil {
+		t.Fatalf("GenerateThumbnail failed: %v", err)
+	}
+	img := &models.Image{
+		ID: "p1", Title: "Box", Artist: "A", Category: "products", Type: models.ImageType2D, UploadedAt: time.Now(),
+		FilePath: fileRel, ThumbnailPath: "categories/products/p1_thumb.jpg",
+	}
+	img.SetDimensions(60, 40)
+	if err := index.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	imageService := NewImageService(storage, nil, index, nil, logger)
+	cutouts := NewCutoutService(index, storage, NewBorderKeyRemover(), nil, logger)
+	upscales := NewUpscaleService(imageService, storage, ResampleUpscaler{}, 100, logger)
+	svc := NewRenditionService(storage, imageService, cutouts, upscales, logger)
+
+	metadata, _ := index.GetImageByID("p1")
+	renditions := svc.List(metadata)
+	if len(renditions) != 1 || renditions[0].Kind != models.RenditionThumbnail {
+		t.Fatalf("expected only the thumbnail, got %+v", renditions)
+	}
+	if renditions[0].Width != 60 || renditions[0].Size == 0 || renditions[0].URL != fmt.Sprintf("/data/categories/products/p1_thumb.jpg?v=%d", renditions[0].CreatedAt.Unix()) {
+		t.Errorf("unexpected thumbnail rendition %+v", renditions[0])
+	}
+
+	// Regenerating a thumbnail rewrites the file
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(thumbPath, old, old)
+	thumb, err := svc.Regenerate(context.Background(), metadata, models.RenditionThumbnail)
+	if err != nil {
+		t.Fatalf("Regenerate failed: %v", err)
+	}
+	if !thumb.CreatedAt.After(old) {
+		t.Errorf("expected a fresh thumbnail, created %v", thumb.CreatedAt)
+	}
+
+	// Optional renditions are created, listed and deleted
+	if _, err := svc.Regenerate(context.Background(), metadata, models.RenditionCutout); err != nil {
+		t.Fatalf("Regenerate cutout failed: %v", err)
+	}
+	if _, err := svc.Regenerate(context.Background(), metadata, models.RenditionUpscale2x); err != nil {
+		t.Fatalf("Regenerate upscale failed: %v", err)
+	}
+	metadata, _ = index.GetImageByID("p1")
+	if got := len(svc.List(metadata)); got != 3 {
+		t.Errorf("expected 3 renditions, got %d", got)
+	}
+
+	if err := svc.Delete(metadata, models.RenditionUpscale2x); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	metadata, _ = index.GetImageByID("p1")
+	if metadata.Upscales != nil {
+		t.Errorf("expected upscale link removed, got %v", metadata.Upscales)
+	}
+	if got := len(svc.List(metadata)); got != 2 {
+		t.Errorf("expected 2 renditions after delete, got %d", got)
+	}
+
+	if err := svc.Delete(metadata, models.RenditionThumbnail); !errors.Is(err, ErrRenditionRequired) {
+		t.Errorf("expected ErrRenditionRequired, got %v", err)
+	}
+	if _, err := svc.Regenerate(context.Background(), metadata, models.RenditionTurntable); !errors.Is(err, ErrUnknownRendition) {
+		t.Errorf("expected ErrUnknownRendition for a 2D turntable, got %v", err)
+	}
+}
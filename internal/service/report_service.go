@@ -0,0 +1,269 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Report summarizes the images processed during a period
+type Report struct {
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Total       int            `json:"total"`
+	ByCategory  map[string]int `json:"by_category"`
+	ByType      map[string]int `json:"by_type"`
+	Notable     []ReportItem   `json:"notable"`
+	GeneratedAt time.Time      `json:"generated_at"`
+}
+
+// ReportItem is a highlighted image in a report
+type ReportItem struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	Artist        string `json:"artist"`
+	Category      string `json:"category"`
+	ThumbnailPath string `json:"thumbnail_path,omitempty"`
+	UploadedAt    string `json:"uploaded_at"`
+}
+
+// maxNotableItems caps the number of highlighted images in a report
+const maxNotableItems = 5
+
+// reportRetryDelay is how long a failed scheduled report waits before it is
+// tried again
+const reportRetryDelay = 5 * time.Minute
+
+// reportSchedule is the persisted state of scheduled delivery
+type reportSchedule struct {
+	LastSent time.Time `json:"last_sent"` // end of the period the last report covered
+}
+
+// ReportService compiles digests of newly processed images and can deliver
+// them on a schedule to a Slack-compatible webhook
+type ReportService struct {
+	indexService *IndexService
+	webhookURL   string
+	baseURL      string
+	httpClient   *http.Client
+	statePath    string // where the schedule is kept; empty keeps it in memory
+	logger       *logrus.Logger
+}
+
+// NewReportService creates a report service. An empty webhookURL disables
+// scheduled delivery; reports remain available on demand.
+func NewReportService(index *IndexService, webhookURL, baseURL string, logger *logrus.Logger) *ReportService {
+	return &ReportService{
+		indexService: index,
+		webhookURL:   webhookURL,
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		httpClient:   &http.Client{Timeout: 15 * time.Second},
+		logger:       logger,
+	}
+}
+
+// SetStatePath keeps the time of the last scheduled report in path, so the
+// schedule survives restarts
+func (s *ReportService) SetStatePath(path string) {
+	s.statePath = path
+}
+
+// Generate builds a report of images uploaded in [from, to)
+func (s *ReportService) Generate(from, to time.Time) (*Report, error) {
+	images, err := s.indexService.GetAllImages()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		From:        from,
+		To:          to,
+		ByCategory:  make(map[string]int),
+		ByType:      make(map[string]int),
+		Notable:     []ReportItem{},
+		GeneratedAt: time.Now(),
+	}
+
+	var recent []*ImageMetadata
+	for _, img := range images {
+		uploaded, err := img.UploadedTime()
+		if err != nil || uploaded.Before(from) || !uploaded.Before(to) {
+			continue
+		}
+		recent = append(recent, img)
+		report.ByCategory[img.Category]++
+		report.ByType[img.Type]++
+	}
+	report.Total = len(recent)
+
+	// Newest first; the latest uploads are the notable ones
+	sort.SliceStable(recent, func(i, j int) bool {
+		return recent[i].UploadedAt > recent[j].UploadedAt
+	})
+	for i, img := range recent {
+		if i >= maxNotableItems {
+			break
+		}
+		report.Notable = append(report.Notable, ReportItem{
+			ID:            img.ID,
+			Title:         img.Title,
+			Artist:        img.Artist,
+			Category:      img.Category,
+			ThumbnailPath: img.PreviewThumbnail(),
+			UploadedAt:    img.UploadedAt,
+		})
+	}
+
+	return report, nil
+}
+
+// Run delivers a report every interval until ctx is done, each covering the
+// time since the last one. The schedule is kept from the time the last
+// report was sent, so restarts neither delay nor repeat a report; one missed
+// while the server was down is sent on start. It does nothing without a
+// webhook URL.
+func (s *ReportService) Run(ctx context.Context, interval time.Duration) {
+	if s.webhookURL == "" || interval <= 0 {
+		return
+	}
+
+	last, err := s.lastSent()
+	if err != nil {
+		s.logger.Warnf("Failed to read digest report schedule, starting it now: %v", err)
+	}
+	if last.IsZero() {
+		// Never sent: the first report covers the first interval from now
+		last = time.Now()
+		if err := s.saveLastSent(last); err != nil {
+			s.logger.Warnf("Failed to save digest report schedule: %v", err)
+		}
+	}
+
+	next := last.Add(interval)
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		report, err := s.Generate(last, now)
+		if err == nil {
+			err = s.Send(ctx, report)
+		}
+		if err != nil {
+			s.logger.Errorf("Failed to send digest report: %v", err)
+			retry := reportRetryDelay
+			if interval < retry {
+				retry = interval
+			}
+			next = now.Add(retry)
+			continue
+		}
+		s.logger.Infof("Sent digest report (%d new images)", report.Total)
+
+		last, next = now, now.Add(interval)
+		if err := s.saveLastSent(last); err != nil {
+			s.logger.Warnf("Failed to save digest report schedule: %v", err)
+		}
+	}
+}
+
+// lastSent returns the end of the period the last scheduled report covered,
+// zero if none was sent
+func (s *ReportService) lastSent() (time.Time, error) {
+	if s.statePath == "" {
+		return time.Time{}, nil
+	}
+	data, err := os.ReadFile(s.statePath)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	var schedule reportSchedule
+	if err := json.Unmarshal(data, &schedule); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse %s: %w", s.statePath, err)
+	}
+	return schedule.LastSent, nil
+}
+
+func (s *ReportService) saveLastSent(t time.Time) error {
+	if s.statePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(reportSchedule{LastSent: t}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.statePath, data, 0644)
+}
+
+// Send posts a report to the webhook as a Slack-compatible text message
+func (s *ReportService) Send(ctx context.Context, report *Report) error {
+	payload, err := json.Marshal(map[string]string{"text": s.FormatText(report)})
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// FormatText renders a report as a short plain-text digest
+func (s *ReportService) FormatText(report *Report) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Image Warehouse digest: %d new images (%s to %s)\n",
+		report.Total, report.From.Format("2006-01-02"), report.To.Format("2006-01-02")))
+
+	categories := make([]string, 0, len(report.ByCategory))
+	for category := range report.ByCategory {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		sb.WriteString(fmt.Sprintf("- %s: %d\n", category, report.ByCategory[category]))
+	}
+
+	if len(report.Notable) > 0 {
+		sb.WriteString("\nLatest:\n")
+		for _, item := range report.Notable {
+			line := fmt.Sprintf("- %s by %s (%s)", item.Title, item.Artist, item.Category)
+			if item.ThumbnailPath != "" && CDNBaseURL() != "" {
+				line += " " + DataURL(item.ThumbnailPath)
+			} else if item.ThumbnailPath != "" && s.baseURL != "" {
+				line += " " + s.baseURL + "/data/" + item.ThumbnailPath
+			}
+			sb.WriteString(line + "\n")
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func newTestReportIndex(t *testing.T) *IndexService {
+	t.Helper()
+	indexService := NewIndexService(t.TempDir())
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	now := time.Now()
+	images := []*models.Image{
+		{ID: "old", Title: "Old", Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: now.AddDate(0, 0, -30)},
+		{ID: "new-1", Title: "Cat", Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: now.Add(-48 * time.Hour), ThumbnailPath: "categories/animals/new-1_thumb.jpg"},
+		{ID: "new-2", Title: "Robot", Artist: "Bob", Category: "sculpture", Type: models.ImageType3D, UploadedAt: now.Add(-time.Hour)},
+	}
+	for _, img := range images {
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+	return indexService
+}
+
+func TestReportService_Generate(t *testing.T) {
+	svc := NewReportService(newTestReportIndex(t), "", "", logrus.New())
+
+	now := time.Now()
+	report, err := svc.Generate(now.AddDate(0, 0, -7), now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if report.Total != 2 {
+		t.Errorf("expected 2 new images, got %d", report.Total)
+	}
+	if report.ByCategory["animals"] != 1 || report.ByCategory["sculpture"] != 1 {
+		t.Errorf("unexpected category counts %v", report.ByCategory)
+	}
+	if len(report.Notable) != 2 || report.Notable[0].ID != "new-2" {
+		t.Errorf("expected newest image first in notable items, got %+v", report.Notable)
+	}
+}
+
+func TestReportService_Send(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	svc := NewReportService(newTestReportIndex(t), server.URL, "https://warehouse.example.com", logrus.New())
+	now := time.Now()
+	report, err := svc.Generate(now.AddDate(0, 0, -7), now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if err := svc.Send(context.Background(), report); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	text := received["text"]
+	if !strings.Contains(text, "2 new images") {
+		t.Errorf("digest text missing total: %q", text)
+	}
+	if !strings.Contains(text, "https://warehouse.example.com/data/categories/animals/new-1_thumb.jpg") {
+		t.Errorf("digest text missing thumbnail link: %q", text)
+	}
+}
+
+func TestReportService_RunFollowsPersistedSchedule(t *testing.T) {
+	sent := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		sent <- body["text"]
+	}))
+	defer server.Close()
+
+	svc := NewReportService(newTestReportIndex(t), server.URL, "", logrus.New())
+	svc.SetStatePath(filepath.Join(t.TempDir(), "report_schedule.json"))
+	run := func(lastSent time.Time) bool {
+		t.Helper()
+		if err := svc.saveLastSent(lastSent); err != nil {
+			t.Fatalf("saveLastSent failed: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			svc.Run(ctx, 72*time.Hour)
+			close(done)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+
+		select {
+		case text := <-sent:
+			// Overdue: sent at once, covering the time since the last report
+			if !strings.Contains(text, "2 new images") {
+				t.Errorf("expected the report to cover both new images: %q", text)
+			}
+			for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+				if last, _ := svc.lastSent(); time.Since(last) < time.Minute {
+					break
+				}
+			}
+			return true
+		case <-time.After(200 * time.Millisecond):
+			return false
+		}
+	}
+
+	// Sent an hour ago: a restart doesn't send again
+	if run(time.Now().Add(-time.Hour)) {
+		t.Error("expected no report an hour after the last one")
+	}
+	if last, _ := svc.lastSent(); time.Since(last) < time.Hour-time.Minute {
+		t.Fatalf("expected the schedule to be kept, last sent %v", last)
+	}
+
+	// Due while the server was down
+	if !run(time.Now().Add(-73 * time.Hour)) {
+		t.Fatal("expected the overdue report to be sent on start")
+	}
+	if last, _ := svc.lastSent(); time.Since(last) > time.Minute {
+		t.Errorf("expected the sent report to be recorded, last sent %v", last)
+	}
+}
@@ -0,0 +1,402 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RetentionForever keeps a category's images, even when a rule for its
+// primary category would delete them
+const RetentionForever = "forever"
+
+// RetentionActor is the actor recorded for deletions by retention rules
+const RetentionActor = "retention"
+
+// RetentionRule deletes the images of a category (a primary category and
+// its sub categories, or one sub category) some time after their upload
+type RetentionRule struct {
+	Category string        `json:"category"`
+	KeepFor  time.Duration `json:"-"` // zero keeps them forever
+	Period   string        `json:"keep_for"`
+}
+
+// ParseRetentionPeriod parses a retention period: "forever", days ("90d"),
+// weeks ("12w") or a Go duration ("36h")
+func ParseRetentionPeriod(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == RetentionForever {
+		return 0, nil
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			if count, err := strconv.Atoi(n); err == nil && count > 0 {
+				return time.Duration(count) * unit, nil
+			}
+			return 0, fmt.Errorf("invalid retention period %q", s)
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid retention period %q (use forever, 90d, 12w or a duration)", s)
+	}
+	return d, nil
+}
+
+// RetentionPolicy holds the retention rules, most specific category first,
+// and when each image was first flagged for deletion. An image is deleted no
+// sooner than the warning period after it was flagged, so one already past
+// its period when a rule is added is still warned about first.
+type RetentionPolicy struct {
+	rules   []RetentionRule
+	warning time.Duration
+
+	flagged     map[string]time.Time // image ID -> first flagged
+	flaggedPath string               // where flagged is kept; empty for memory only
+	flagMutex   sync.RWMutex
+}
+
+// NewRetentionPolicy builds a policy from category -> period rules (see
+// ParseRetentionPeriod). Images are flagged warning before their deletion.
+func NewRetentionPolicy(periods map[string]string, warning time.Duration) (*RetentionPolicy, error) {
+	policy := &RetentionPolicy{warning: warning, flagged: make(map[string]time.Time)}
+	for category, period := range periods {
+		keepFor, err := ParseRetentionPeriod(period)
+		if err != nil {
+			return nil, fmt.Errorf("retention rule for %s: %w", category, err)
+		}
+		policy.rules = append(policy.rules, RetentionRule{Category: strings.Trim(category, "/"), KeepFor: keepFor, Period: period})
+	}
+	sort.Slice(policy.rules, func(i, j int) bool {
+		if len(policy.rules[i].Category) != len(policy.rules[j].Category) {
+			return len(policy.rules[i].Category) > len(policy.rules[j].Category)
+		}
+		return policy.rules[i].Category < policy.rules[j].Category
+	})
+	return policy, nil
+}
+
+// LoadRetentionPolicy reads retention rules from a JSON file of category ->
+// period, e.g. {"temp-references": "90d", "client-final": "forever"}. An
+// empty path loads no rules.
+func LoadRetentionPolicy(path string, warning time.Duration) (*RetentionPolicy, error) {
+	if path == "" {
+		return NewRetentionPolicy(nil, warning)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retention rules: %w", err)
+	}
+	var periods map[string]string
+	if err := json.Unmarshal(data, &periods); err != nil {
+		return nil, fmt.Errorf("failed to parse retention rules: %w", err)
+	}
+	return NewRetentionPolicy(periods, warning)
+}
+
+// SetFlagFile keeps the times images were first flagged in path, a JSON file
+// of image ID -> time, loading those already recorded
+func (p *RetentionPolicy) SetFlagFile(path string) error {
+	flagged := make(map[string]time.Time)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read retention flags: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &flagged); err != nil {
+			return fmt.Errorf("failed to parse retention flags: %w", err)
+		}
+	}
+
+	p.flagMutex.Lock()
+	defer p.flagMutex.Unlock()
+	p.flagged = flagged
+	p.flaggedPath = path
+	return nil
+}
+
+// flagTimes records now as the first flagged time of the images in ids not
+// flagged yet and forgets the images not in ids. The file is written if
+// anything changed.
+func (p *RetentionPolicy) flagTimes(ids map[string]bool, now time.Time) error {
+	p.flagMutex.Lock()
+	defer p.flagMutex.Unlock()
+
+	changed := false
+	for id := range p.flagged {
+		if !ids[id] {
+			delete(p.flagged, id)
+			changed = true
+		}
+	}
+	for id := range ids {
+		if _, ok := p.flagged[id]; !ok {
+			p.flagged[id] = now
+			changed = true
+		}
+	}
+	if !changed || p.flaggedPath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(p.flagged)
+	if err != nil {
+		return fmt.Errorf("failed to encode retention flags: %w", err)
+	}
+	tmpPath := p.flaggedPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write retention flags: %w", err)
+	}
+	if err := os.Rename(tmpPath, p.flaggedPath); err != nil {
+		return fmt.Errorf("failed to replace retention flags: %w", err)
+	}
+	return nil
+}
+
+// flaggedAt returns when an image was first flagged
+func (p *RetentionPolicy) flaggedAt(imageID string) (time.Time, bool) {
+	p.flagMutex.RLock()
+	defer p.flagMutex.RUnlock()
+	t, ok := p.flagged[imageID]
+	return t, ok
+}
+
+// Rules returns the retention rules, most specific first
+func (p *RetentionPolicy) Rules() []RetentionRule {
+	if p == nil {
+		return nil
+	}
+	return p.rules
+}
+
+// Warning returns how long before their deletion images are flagged
+func (p *RetentionPolicy) Warning() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return p.warning
+}
+
+// RetentionInfo is when a retention rule deletes an image
+type RetentionInfo struct {
+	Rule     string    `json:"rule"`     // category of the rule
+	KeepFor  string    `json:"keep_for"` // the rule's period
+	DeleteAt time.Time `json:"delete_at"`
+	Warning  string    `json:"warning,omitempty"` // set once the deletion is near
+}
+
+// Info returns when the image is deleted, or nil if no rule deletes it. Once
+// flagged, an image is deleted at the end of its period or of the warning
+// period, whichever is later; an image not flagged yet counts as flagged now.
+func (p *RetentionPolicy) Info(img *ImageMetadata, now time.Time) *RetentionInfo {
+	if p == nil || img.Deleted {
+		return nil
+	}
+	for _, rule := range p.rules {
+		if !img.InCategory(rule.Category) {
+			continue
+		}
+		uploaded, err := img.UploadedTime()
+		if rule.KeepFor == 0 || err != nil {
+			return nil
+		}
+		info := &RetentionInfo{Rule: rule.Category, KeepFor: rule.Period, DeleteAt: uploaded.Add(rule.KeepFor)}
+		if !now.Before(info.DeleteAt.Add(-p.warning)) {
+			flagged, ok := p.flaggedAt(img.ID)
+			if !ok {
+				flagged = now
+			}
+			if warned := flagged.Add(p.warning); warned.After(info.DeleteAt) {
+				info.DeleteAt = warned
+			}
+			info.Warning = fmt.Sprintf("scheduled for deletion on %s (%s retention of %s)", info.DeleteAt.Format("2006-01-02"), rule.Period, rule.Category)
+		}
+		return info
+	}
+	return nil
+}
+
+// RetentionNotice is an image due for deletion within the warning period
+type RetentionNotice struct {
+	ImageID   string    `json:"image_id"`
+	Title     string    `json:"title"`
+	Category  string    `json:"category"`
+	Rule      string    `json:"rule"`
+	DeleteAt  time.Time `json:"delete_at"`
+	LegalHold string    `json:"legal_hold,omitempty"` // what keeps it, see ImageService.HeldBy
+}
+
+// RetentionService deletes images their category's retention rule no longer
+// keeps, recording each deletion in the audit log. The server runs it on the
+// leader instance.
+type RetentionService struct {
+	policy       *RetentionPolicy
+	indexService *IndexService
+	imageService *ImageService
+	popularity   *PopularityStore
+	favorites    *FavoriteStore
+	audit        *AuditLog
+	logger       *logrus.Logger
+
+	held      map[string]bool // due images whose deletion a legal hold refused
+	heldMutex sync.Mutex
+}
+
+// NewRetentionService creates a retention service. popularity and favorites
+// may be nil.
+func NewRetentionService(policy *RetentionPolicy, index *IndexService, image *ImageService, popularity *PopularityStore, favorites *FavoriteStore, audit *AuditLog, logger *logrus.Logger) *RetentionService {
+	return &RetentionService{
+		policy:       policy,
+		indexService: index,
+		imageService: image,
+		popularity:   popularity,
+		favorites:    favorites,
+		audit:        audit,
+		logger:       logger,
+		held:         make(map[string]bool),
+	}
+}
+
+// Policy returns the retention rules in effect
+func (s *RetentionService) Policy() *RetentionPolicy {
+	return s.policy
+}
+
+// Pending returns the images deleted within the warning period (or overdue),
+// soonest first
+func (s *RetentionService) Pending(now time.Time) ([]RetentionNotice, error) {
+	images, err := s.indexService.GetAllImages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	notices := []RetentionNotice{}
+	for _, img := range images {
+		if info := s.policy.Info(img, now); info != nil && info.Warning != "" {
+			notices = append(notices, RetentionNotice{ImageID: img.ID, Title: img.Title, Category: img.Category, Rule: info.Rule, DeleteAt: info.DeleteAt, LegalHold: s.imageService.HeldBy(img)})
+		}
+	}
+	sort.SliceStable(notices, func(i, j int) bool { return notices[i].DeleteAt.Before(notices[j].DeleteAt) })
+	return notices, nil
+}
+
+// Enforce deletes the images whose retention and warning period have run
+// out and returns how many were deleted. Images flagged for the first time
+// are recorded as flagged now. Images on legal hold are kept; the audit log
+// records the first refused attempt for each hold rather than one every run.
+func (s *RetentionService) Enforce(ctx context.Context, now time.Time) (int, error) {
+	images, err := s.indexService.GetAllImages()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	flagged := make(map[string]bool)
+	defer func() {
+		if ctx.Err() != nil {
+			// A partial run would forget the flags of the images it didn't reach
+			return
+		}
+		if err := s.policy.flagTimes(flagged, now); err != nil {
+			s.logger.Errorf("Retention: %v", err)
+		}
+	}()
+
+	deleted, held, failed := 0, 0, 0
+	for _, img := range images {
+		if ctx.Err() != nil {
+			return deleted, ctx.Err()
+		}
+		info := s.policy.Info(img, now)
+		if info == nil || info.Warning == "" {
+			continue
+		}
+		flagged[img.ID] = true
+		if now.Before(info.DeleteAt) {
+			continue
+		}
+		if s.seenHold(img.ID, s.imageService.HeldBy(img) != "") {
+			held++
+			continue
+		}
+		if err := s.imageService.DeleteImage(img.ID, RetentionActor); err != nil && !errors.Is(err, ErrFilesRemain) {
+			if errors.Is(err, ErrLegalHold) {
+				held++
+			} else if !errors.Is(err, ErrImageNotFound) {
+				s.logger.Warnf("Retention: failed to delete %s: %v", img.ID, err)
+				failed++
+			}
+			continue
+		}
+		delete(flagged, img.ID)
+		deleted++
+		if s.popularity != nil {
+			s.popularity.Forget(img.ID)
+		}
+		if s.favorites != nil {
+			if err := s.favorites.Forget(img.ID); err != nil {
+				s.logger.Warnf("Retention: failed to drop favorites of %s: %v", img.ID, err)
+			}
+		}
+		entry := AuditEntry{
+			Time:    now,
+			Actor:   RetentionActor,
+			Action:  AuditRetentionDelete,
+			ImageID: img.ID,
+			Detail:  fmt.Sprintf("%s (%s) uploaded %s, past the %s retention of %s", img.Title, img.Category, img.UploadedAt, info.KeepFor, info.Rule),
+		}
+		if err := s.audit.Record(entry); err != nil {
+			s.logger.Errorf("Retention: failed to record deletion of %s: %v", img.ID, err)
+		}
+	}
+
+	if deleted > 0 || failed > 0 {
+		s.logger.Infof("Retention: deleted %d images, %d on legal hold, %d failed", deleted, held, failed)
+	}
+	if failed > 0 {
+		return deleted, fmt.Errorf("%d images failed to delete", failed)
+	}
+	return deleted, nil
+}
+
+// seenHold tracks the due images on legal hold and reports whether the
+// image's hold was seen by an earlier run
+func (s *RetentionService) seenHold(imageID string, held bool) bool {
+	s.heldMutex.Lock()
+	defer s.heldMutex.Unlock()
+	seen := s.held[imageID]
+	if held {
+		s.held[imageID] = true
+	} else {
+		delete(s.held, imageID)
+	}
+	return held && seen
+}
+
+// Run enforces the retention rules every interval until ctx is done
+func (s *RetentionService) Run(ctx context.Context, interval time.Duration) {
+	if len(s.policy.Rules()) == 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Enforce(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			s.logger.Errorf("Retention enforcement failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
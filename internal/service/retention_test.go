@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestParseRetentionPeriod(t *testing.T) {
+	tests := []struct {
+		period string
+		want   time.Duration
+		ok     bool
+	}{
+		{"forever", 0, true},
+		{"90d", 90 * 24 * time.Hour, true},
+		{"12w", 12 * 7 * 24 * time.Hour, true},
+		{"36h", 36 * time.Hour, true},
+		{"0d", 0, false},
+		{"-1h", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseRetentionPeriod(tt.period)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseRetentionPeriod(%q) = %v, %v", tt.period, got, err)
+		}
+	}
+}
+
+func TestRetentionPolicy_Info(t *testing.T) {
+	policy, err := NewRetentionPolicy(map[string]string{
+		"temp-references":        "90d",
+		"temp-references/client": "forever",
+	}, 14*24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewRetentionPolicy failed: %v", err)
+	}
+
+	uploaded := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	img := &ImageMetadata{ID: "a", Category: "temp-references/moodboards", UploadedAt: uploaded.Format("2006-01-02 15:04:05")}
+
+	info := policy.Info(img, uploaded.Add(30*24*time.Hour))
+	if info == nil || info.Rule != "temp-references" || !info.DeleteAt.Equal(uploaded.Add(90*24*time.Hour)) || info.Warning != "" {
+		t.Fatalf("unexpected retention %+v", info)
+	}
+	if info := policy.Info(img, uploaded.Add(80*24*time.Hour)); info == nil || info.Warning == "" || !info.DeleteAt.Equal(uploaded.Add(94*24*time.Hour)) {
+		t.Errorf("expected a warning two weeks before deletion, got %+v", info)
+	}
+
+	// The more specific rule keeps images forever
+	kept := &ImageMetadata{ID: "b", Category: "temp-references/client", UploadedAt: img.UploadedAt}
+	if info := policy.Info(kept, uploaded.Add(365*24*time.Hour)); info != nil {
+		t.Errorf("expected a kept image, got %+v", info)
+	}
+	if info := policy.Info(&ImageMetadata{ID: "c", Category: "landscape", UploadedAt: img.UploadedAt}, uploaded); info != nil {
+		t.Errorf("expected no rule for landscape, got %+v", info)
+	}
+}
+
+func TestRetentionService_Enforce(t *testing.T) {
+	logger := logrus.New()
+	dataDir := t.TempDir()
+	indexService := NewIndexService(dataDir)
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.Local)
+	images := []*models.Image{
+		{ID: "old", Title: "Old", Category: "temp-references", Type: models.ImageType2D, UploadedAt: now.AddDate(0, 0, -100)},
+		{ID: "new", Title: "New", Category: "temp-references", Type: models.ImageType2D, UploadedAt: now.AddDate(0, 0, -10)},
+		{ID: "final", Title: "Final", Category: "client-final", Type: models.ImageType2D, UploadedAt: now.AddDate(-2, 0, 0)},
+	}
+	for _, img := range images {
+		if err := indexService.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	policy, err := NewRetentionPolicy(map[string]string{"temp-references": "90d", "client-final": "forever"}, 14*24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewRetentionPolicy failed: %v", err)
+	}
+	favorites := NewFavoriteStore(filepath.Join(dataDir, "favorites.json"))
+	favorites.Add("alice", "old")
+	audit := NewAuditLog(filepath.Join(dataDir, "audit.jsonl"))
+	imageService := NewImageService(NewStorageService(dataDir), nil, indexService, nil, logger)
+	flagFile := filepath.Join(dataDir, "retention_flags.json")
+	if err := policy.SetFlagFile(flagFile); err != nil {
+		t.Fatalf("SetFlagFile failed: %v", err)
+	}
+	svc := NewRetentionService(policy, indexService, imageService, nil, favorites, audit, logger)
+
+	// Past its period but never warned about: flagged, not deleted
+	if deleted, err := svc.Enforce(context.Background(), now); err != nil || deleted != 0 {
+		t.Fatalf("expected the first run only to flag, got %d, %v", deleted, err)
+	}
+	notices, err := svc.Pending(now)
+	if err != nil || len(notices) != 1 || notices[0].ImageID != "old" || !notices[0].DeleteAt.Equal(now.Add(14*24*time.Hour)) {
+		t.Fatalf("expected old to be deleted after the warning period, got %+v (%v)", notices, err)
+	}
+
+	// The flag survives a restart
+	policy, _ = NewRetentionPolicy(map[string]string{"temp-references": "90d", "client-final": "forever"}, 14*24*time.Hour)
+	if err := policy.SetFlagFile(flagFile); err != nil {
+		t.Fatalf("SetFlagFile failed: %v", err)
+	}
+	svc = NewRetentionService(policy, indexService, imageService, nil, favorites, audit, logger)
+	if deleted, err := svc.Enforce(context.Background(), now.Add(13*24*time.Hour)); err != nil || deleted != 0 {
+		t.Fatalf("expected nothing deleted within the warning period, got %d, %v", deleted, err)
+	}
+	now = now.Add(14 * 24 * time.Hour)
+
+	deleted, err := svc.Enforce(context.Background(), now)
+	if err != nil || deleted != 1 {
+		t.Fatalf("Enforce = %d, %v", deleted, err)
+	}
+	for id, want := range map[string]bool{"old": false, "new": true, "final": true} {
+		if _, err := indexService.GetImageByID(id); (err == nil) != want {
+			t.Errorf("image %s: expected present=%v, got err %v", id, want, err)
+		}
+	}
+	if len(favorites.List("alice")) != 0 {
+		t.Error("expected the deleted image to leave favorites")
+	}
+
+	entries, err := audit.Entries(AuditRetentionDelete, 10)
+	if err != nil || len(entries) != 1 || entries[0].ImageID != "old" || entries[0].Actor != RetentionActor {
+		t.Fatalf("unexpected audit entries %+v (%v)", entries, err)
+	}
+
+	// Nothing else is due yet
+	if deleted, err := svc.Enforce(context.Background(), now); err != nil || deleted != 0 {
+		t.Errorf("expected nothing left to delete, got %d, %v", deleted, err)
+	}
+}
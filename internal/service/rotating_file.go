@@ -0,0 +1,86 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an append-only log file that rotates once it reaches a
+// size: path becomes path.1, path.1 becomes path.2 and so on, keeping at
+// most maxFiles rotated files.
+type RotatingFile struct {
+	path     string
+	maxSize  int64 // 0 never rotates
+	maxFiles int
+	file     *os.File
+	size     int64
+	mutex    sync.Mutex
+}
+
+// OpenRotatingFile opens path for appending, creating it if needed
+func OpenRotatingFile(path string, maxSize int64, maxFiles int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if it would take the file past its size.
+// Writes are never split across files.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files up, dropping the oldest, and starts a new
+// file. Caller must hold the lock.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	if f.maxFiles < 1 {
+		os.Remove(f.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxFiles))
+		for i := f.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	}
+	return f.open()
+}
+
+// Close closes the current file
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile_RotatesAndKeepsMaxFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := OpenRotatingFile(path, 8, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The existing file is appended to; a write that doesn't fit rotates, and
+	// "old\none\n" was dropped as the third rotated file
+	for name, want := range map[string]string{
+		"access.log":   "four\n",
+		"access.log.1": "three\n",
+		"access.log.2": "two\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil || string(got) != want {
+			t.Errorf("expected %s to hold %q, got %q (%v)", name, want, got, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 rotated files, got %v", err)
+	}
+}
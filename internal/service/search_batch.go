@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// MaxBatchQueries is the most queries one batch search may carry
+const MaxBatchQueries = 20
+
+// batchQueriesPerCall is how many queries share one AI call per index chunk
+const batchQueriesPerCall = 8
+
+// ErrTooManyQueries is returned for a batch search over MaxBatchQueries
+var ErrTooManyQueries = fmt.Errorf("at most %d queries per batch", MaxBatchQueries)
+
+// batchIndexSearcher ranks the images of an index excerpt against several
+// queries in one AI call, returning results in query order; satisfied by
+// AIService. Searchers without it are called once per query.
+type batchIndexSearcher interface {
+	SearchImagesBatch(ctx context.Context, indexContent string, queries []string) ([][]models.SearchResult, error)
+}
+
+// BatchQuery is one search of a batch, with its own limit and filter
+type BatchQuery struct {
+	Query  string
+	Limit  int
+	Filter SearchFilter
+}
+
+// SearchBatch runs several searches over a single read of the index, e.g. to
+// fill the themed shelves of a dashboard at once. Each index chunk is searched
+// for up to batchQueriesPerCall queries per AI call. Responses are returned
+// in query order.
+func (s *SearchService) SearchBatch(ctx context.Context, queries []BatchQuery) ([]*models.SearchResponse, error) {
+	if len(queries) > MaxBatchQueries {
+		return nil, ErrTooManyQueries
+	}
+	start := time.Now()
+	responses, err := s.searchBatch(ctx, queries)
+	for i, q := range queries {
+		results := 0
+		if err == nil {
+			results = responses[i].Total
+		}
+		s.logSearch(SearchKindBatch, q.Query, start, results, err)
+	}
+	return responses, err
+}
+
+func (s *SearchService) searchBatch(ctx context.Context, queries []BatchQuery) ([]*models.SearchResponse, error) {
+	texts := make([]string, len(queries))
+	for i, q := range queries {
+		texts[i] = q.Query
+	}
+	s.logger.Infof("Batch searching %d queries: %s", len(queries), strings.Join(texts, " | "))
+
+	// 1. Read the index once, for the AI and for the metadata filters. When
+	// every query is limited to a category, only those categories are read.
+	indexContent, err := s.indexService.ReadSections(batchSelection(queries))
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*ImageMetadata)
+	err = scanIndex(strings.NewReader(indexContent), func(img *ImageMetadata) {
+		if !img.Deleted {
+			byID[img.ID] = img
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	// 2. Rank every chunk against the queries, several queries per AI call
+	chunks := chunkIndex(indexContent, s.chunkSize)
+	results, failed, err := s.searchChunksBatch(ctx, chunks, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search with AI: %w", err)
+	}
+
+	// 3. Filter and limit each query's results
+	responses := make([]*models.SearchResponse, len(queries))
+	for i, q := range queries {
+		filtered := filterIndexed(results[i], q.Query, q.Filter, byID, s.ranker(ctx, q.Query, q.Filter))
+		if len(filtered) > q.Limit {
+			filtered = filtered[:q.Limit]
+		}
+		responses[i] = &models.SearchResponse{Results: filtered, Total: len(filtered), Query: q.Query}
+		markPartial(responses[i], failed[i], len(chunks))
+	}
+
+	return responses, nil
+}
+
+// batchSelection returns the index sections a batch can match: the union of
+// the queries' categories, or the whole index if a query has none
+func batchSelection(queries []BatchQuery) SectionSelection {
+	var categories []string
+	for _, q := range queries {
+		if q.Filter.Category == "" {
+			return SectionSelection{}
+		}
+		categories = append(categories, q.Filter.Category)
+	}
+	return SectionSelection{Categories: categories}
+}
+
+// searchChunksBatch searches each index chunk for all queries, in groups of
+// batchQueriesPerCall, and merges the results per query like searchChunks.
+// Failed calls are logged and skipped, and counted per query as the chunks
+// it missed; the search only fails if every call does.
+func (s *SearchService) searchChunksBatch(ctx context.Context, chunks []string, queries []string) ([][]models.SearchResult, []int, error) {
+	var (
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+		best     = make([]map[string]models.SearchResult, len(queries))
+		missed   = make([]int, len(queries))
+		calls    int
+		failures int
+		lastErr  error
+	)
+	for i := range best {
+		best[i] = make(map[string]models.SearchResult)
+	}
+
+	sem := make(chan struct{}, s.concurrency)
+	for c, chunk := range chunks {
+		for start := 0; start < len(queries); start += batchQueriesPerCall {
+			end := min(start+batchQueriesPerCall, len(queries))
+			calls++
+			wg.Add(1)
+			go func(c int, chunk string, start, end int) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				results, err := s.searchGroup(ctx, chunk, queries[start:end])
+
+				mutex.Lock()
+				defer mutex.Unlock()
+				if err != nil {
+					s.logger.Warnf("Batch search of index chunk %d/%d failed: %v", c+1, len(chunks), err)
+					failures++
+					lastErr = err
+					for i := start; i < end; i++ {
+						missed[i]++
+					}
+					return
+				}
+				for i, queryResults := range results {
+					keepBest(best[start+i], queryResults)
+				}
+			}(c, chunk, start, end)
+		}
+	}
+	wg.Wait()
+
+	if calls > 0 && failures == calls {
+		return nil, nil, lastErr
+	}
+
+	merged := make([][]models.SearchResult, len(queries))
+	for i := range best {
+		merged[i] = rankResults(best[i])
+	}
+	return merged, missed, nil
+}
+
+// searchGroup ranks one chunk against a group of queries, in one AI call when
+// the searcher supports batches
+func (s *SearchService) searchGroup(ctx context.Context, chunk string, queries []string) ([][]models.SearchResult, error) {
+	if batcher, ok := s.aiService.(batchIndexSearcher); ok {
+		if err := s.throttle(ctx); err != nil {
+			return nil, err
+		}
+		results, err := batcher.SearchImagesBatch(ctx, chunk, queries)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) != len(queries) {
+			return nil, fmt.Errorf("batch search returned %d result sets for %d queries", len(results), len(queries))
+		}
+		return results, nil
+	}
+
+	results := make([][]models.SearchResult, len(queries))
+	for i, query := range queries {
+		if err := s.throttle(ctx); err != nil {
+			return nil, err
+		}
+		queryResults, err := s.aiService.SearchImages(ctx, chunk, query)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = queryResults
+	}
+	return results, nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// MockBatchAIService answers several queries per call, matching each query
+// against image titles in the index excerpt
+type MockBatchAIService struct {
+	MockAIService
+	mutex sync.Mutex
+	calls int
+}
+
+func (m *MockBatchAIService) SearchImagesBatch(ctx context.Context, indexContent string, queries []string) ([][]models.SearchResult, error) {
+	m.mutex.Lock()
+	m.calls++
+	m.mutex.Unlock()
+
+	results := make([][]models.SearchResult, len(queries))
+	for i, query := range queries {
+		for _, id := range []string{"cat-1", "cat-2", "dog-1"} {
+			if strings.HasPrefix(id, query) && strings.Contains(indexContent, "## Image: "+id) {
+				results[i] = append(results[i], models.SearchResult{ImageID: id, RelevanceScore: 0.9})
+			}
+		}
+	}
+	return results, nil
+}
+
+func newBatchSearchService(t *testing.T, ai indexSearcher) *SearchService {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+
+	index := NewIndexService(t.TempDir())
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	for _, id := range []string{"cat-1", "cat-2", "dog-1"} {
+		img := &models.Image{ID: id, Title: id, Type: models.ImageType2D, UploadedAt: time.Now(), Category: "animals"}
+		if strings.HasSuffix(id, "2") {
+			img.License = &models.License{Type: "cc-by-4.0"}
+		}
+		if err := index.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+	return &SearchService{indexService: index, aiService: ai, logger: logger, chunkSize: DefaultSearchChunkSize, concurrency: 2}
+}
+
+func TestSearchBatch_OneCallPerChunk(t *testing.T) {
+	mock := &MockBatchAIService{}
+	svc := newBatchSearchService(t, mock)
+
+	responses, err := svc.SearchBatch(context.Background(), []BatchQuery{
+		{Query: "cat", Limit: 10},
+		{Query: "cat", Limit: 10, Filter: SearchFilter{License: "cc-by-4.0"}},
+		{Query: "dog", Limit: 10},
+		{Query: "cat", Limit: 1},
+	})
+	if err != nil {
+		t.Fatalf("SearchBatch failed: %v", err)
+	}
+	if mock.calls != 1 {
+		t.Errorf("expected 1 AI call for the whole batch, got %d", mock.calls)
+	}
+
+	want := []string{"cat-1,cat-2", "cat-2", "dog-1", "cat-1"}
+	for i, response := range responses {
+		var ids []string
+		for _, r := range response.Results {
+			ids = append(ids, r.ImageID)
+		}
+		if strings.Join(ids, ",") != want[i] || response.Total != len(ids) {
+			t.Errorf("query %d (%s): got %v, want %s", i+1, response.Query, ids, want[i])
+		}
+	}
+
+	// Chunks multiply the calls; groups of queries too
+	mock.calls = 0
+	svc.chunkSize = 1
+	queries := make([]BatchQuery, batchQueriesPerCall+1)
+	for i := range queries {
+		queries[i] = BatchQuery{Query: "dog", Limit: 10}
+	}
+	responses, err = svc.SearchBatch(context.Background(), queries)
+	if err != nil {
+		t.Fatalf("SearchBatch failed: %v", err)
+	}
+	if mock.calls != 3*2 {
+		t.Errorf("expected 6 AI calls (3 chunks x 2 query groups), got %d", mock.calls)
+	}
+	if last := responses[len(responses)-1]; len(last.Results) != 1 || last.Results[0].ImageID != "dog-1" {
+		t.Errorf("unexpected results for the last query: %+v", last.Results)
+	}
+}
+
+func TestSearchBatch_FallsBackToSingleQueries(t *testing.T) {
+	var mutex sync.Mutex
+	var queries []string
+	mock := &MockAIService{SearchImagesFunc: func(ctx context.Context, indexContent, query string) ([]models.SearchResult, error) {
+		mutex.Lock()
+		queries = append(queries, query)
+		mutex.Unlock()
+		return []models.SearchResult{{ImageID: query + "-1", RelevanceScore: 0.8}}, nil
+	}}
+	svc := newBatchSearchService(t, mock)
+
+	responses, err := svc.SearchBatch(context.Background(), []BatchQuery{{Query: "cat", Limit: 5}, {Query: "dog", Limit: 5}})
+	if err != nil {
+		t.Fatalf("SearchBatch failed: %v", err)
+	}
+	if len(queries) != 2 || responses[0].Results[0].ImageID != "cat-1" || responses[1].Results[0].ImageID != "dog-1" {
+		t.Errorf("unexpected fallback results %v / %+v", queries, responses)
+	}
+
+	if _, err := svc.SearchBatch(context.Background(), make([]BatchQuery, MaxBatchQueries+1)); err != ErrTooManyQueries {
+		t.Errorf("err = %v, want ErrTooManyQueries", err)
+	}
+}
+
+func TestSearchBatch_MarksPartialResponses(t *testing.T) {
+	mock := &MockAIService{SearchImagesFunc: func(ctx context.Context, indexContent, query string) ([]models.SearchResult, error) {
+		if strings.Contains(indexContent, "## Image: dog-1") {
+			return nil, errors.New("quota exceeded")
+		}
+		return []models.SearchResult{{ImageID: "cat-1", RelevanceScore: 0.8}}, nil
+	}}
+	svc := newBatchSearchService(t, mock)
+	svc.chunkSize = 1
+
+	responses, err := svc.SearchBatch(context.Background(), []BatchQuery{{Query: "cat", Limit: 5}, {Query: "dog", Limit: 5}})
+	if err != nil {
+		t.Fatalf("SearchBatch failed: %v", err)
+	}
+	for _, response := range responses {
+		if !response.Partial || len(response.Warnings) != 1 || !strings.Contains(response.Warnings[0], "1 of 3 index chunks") {
+			t.Errorf("expected %s to be partial, got %+v", response.Query, response)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Search kinds in the search log
+const (
+	SearchKindSearch = "search"
+	SearchKindBatch  = "batch"
+	SearchKindRefine = "refine" // a turn of a search session after the first
+)
+
+// SearchLogEntry records one search: what was asked, how long it took and
+// how many results it returned
+type SearchLogEntry struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Query     string    `json:"query"`
+	Results   int       `json:"results"`
+	LatencyMs int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"` // the search failed
+}
+
+// QueryStats aggregates the searches of one query, compared ignoring case
+// and surrounding spaces
+type QueryStats struct {
+	Query        string    `json:"query"`
+	Count        int       `json:"count"`
+	ZeroResults  int       `json:"zero_results"` // searches that found nothing
+	AvgResults   float64   `json:"avg_results"`
+	LastSearched time.Time `json:"last_searched"`
+}
+
+// SearchStats summarizes the search log over a period
+type SearchStats struct {
+	From         time.Time    `json:"from"`
+	To           time.Time    `json:"to"`
+	Searches     int          `json:"searches"`
+	Failed       int          `json:"failed"`
+	ZeroResults  int          `json:"zero_results"`
+	AvgLatencyMs int64        `json:"avg_latency_ms"`
+	P95LatencyMs int64        `json:"p95_latency_ms"`
+	TopQueries   []QueryStats `json:"top_queries"`
+	ZeroResult   []QueryStats `json:"zero_result_queries"` // queries that found nothing, most often first
+}
+
+// SearchLog is an append-only log of searches, one JSON line each, that
+// curators read to learn what users look for
+type SearchLog struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewSearchLog(path string) *SearchLog {
+	return &SearchLog{path: path}
+}
+
+// Record appends an entry. A zero Time is set to now. A nil log records
+// nothing.
+func (l *SearchLog) Record(entry SearchLogEntry) error {
+	if l == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode search log entry: %w", err)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open search log: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write search log: %w", err)
+	}
+	return nil
+}
+
+// Stats summarizes the searches between from and to, listing up to limit
+// top and zero-result queries
+func (l *SearchLog) Stats(from, to time.Time, limit int) (*SearchStats, error) {
+	stats := &SearchStats{From: from, To: to, TopQueries: []QueryStats{}, ZeroResult: []QueryStats{}}
+	byQuery := make(map[string]*QueryStats)
+	var latencies []int64
+	totalResults := make(map[string]int)
+
+	err := l.scan(func(entry SearchLogEntry) {
+		if entry.Time.Before(from) || entry.Time.After(to) {
+			return
+		}
+		stats.Searches++
+		latencies = append(latencies, entry.LatencyMs)
+		if entry.Error != "" {
+			stats.Failed++
+			return
+		}
+
+		key := strings.ToLower(strings.TrimSpace(entry.Query))
+		q, ok := byQuery[key]
+		if !ok {
+			q = &QueryStats{Query: strings.TrimSpace(entry.Query)}
+			byQuery[key] = q
+		}
+		q.Count++
+		totalResults[key] += entry.Results
+		if entry.Results == 0 {
+			q.ZeroResults++
+			stats.ZeroResults++
+		}
+		if entry.Time.After(q.LastSearched) {
+			q.LastSearched = entry.Time
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(latencies) > 0 {
+		var sum int64
+		for _, ms := range latencies {
+			sum += ms
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats.AvgLatencyMs = sum / int64(len(latencies))
+		stats.P95LatencyMs = latencies[(len(latencies)*95+99)/100-1]
+	}
+
+	queries := make([]QueryStats, 0, len(byQuery))
+	for key, q := range byQuery {
+		q.AvgResults = float64(totalResults[key]) / float64(q.Count)
+		queries = append(queries, *q)
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		if queries[i].Count != queries[j].Count {
+			return queries[i].Count > queries[j].Count
+		}
+		return queries[i].Query < queries[j].Query
+	})
+	var zero []QueryStats
+	for _, q := range queries {
+		if q.ZeroResults > 0 {
+			zero = append(zero, q)
+		}
+	}
+	sort.SliceStable(zero, func(i, j int) bool { return zero[i].ZeroResults > zero[j].ZeroResults })
+
+	stats.TopQueries = append(stats.TopQueries, queries[:min(limit, len(queries))]...)
+	stats.ZeroResult = append(stats.ZeroResult, zero[:min(limit, len(zero))]...)
+	return stats, nil
+}
+
+// scan calls fn with every entry of the log, oldest first
+func (l *SearchLog) scan(fn func(SearchLogEntry)) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open search log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry SearchLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // torn line from a crash
+		}
+		fn(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read search log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSearchLog_Stats(t *testing.T) {
+	log := NewSearchLog(filepath.Join(t.TempDir(), "searches.jsonl"))
+	now := time.Now()
+	entries := []SearchLogEntry{
+		{Time: now.AddDate(0, 0, -40), Kind: SearchKindSearch, Query: "sunset", Results: 5, LatencyMs: 100},
+		{Time: now.Add(-3 * time.Hour), Kind: SearchKindSearch, Query: "Sunset ", Results: 8, LatencyMs: 100},
+		{Time: now.Add(-2 * time.Hour), Kind: SearchKindBatch, Query: "sunset", Results: 4, LatencyMs: 300},
+		{Time: now.Add(-time.Hour), Kind: SearchKindSearch, Query: "forklift", Results: 0, LatencyMs: 200},
+		{Time: now.Add(-time.Minute), Kind: SearchKindRefine, Query: "forklift", Results: 0, LatencyMs: 200},
+		{Time: now, Kind: SearchKindSearch, Query: "cats", Results: 0, LatencyMs: 1000, Error: "quota exceeded"},
+	}
+	for _, entry := range entries {
+		if err := log.Record(entry); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	stats, err := log.Stats(now.AddDate(0, 0, -30), now, 10)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Searches != 5 || stats.Failed != 1 || stats.ZeroResults != 2 {
+		t.Errorf("unexpected totals %+v", stats)
+	}
+	if stats.AvgLatencyMs != 360 || stats.P95LatencyMs != 1000 {
+		t.Errorf("unexpected latencies avg %d, p95 %d", stats.AvgLatencyMs, stats.P95LatencyMs)
+	}
+	if len(stats.TopQueries) != 2 || stats.TopQueries[0].Query != "Sunset" || stats.TopQueries[0].Count != 2 || stats.TopQueries[0].AvgResults != 6 {
+		t.Errorf("unexpected top queries %+v", stats.TopQueries)
+	}
+	if len(stats.ZeroResult) != 1 || stats.ZeroResult[0].Query != "forklift" || stats.ZeroResult[0].ZeroResults != 2 {
+		t.Errorf("unexpected zero-result queries %+v", stats.ZeroResult)
+	}
+
+	if stats, _ := log.Stats(now.AddDate(0, 0, -30), now, 1); len(stats.TopQueries) != 1 {
+		t.Errorf("expected the limit to cut the lists, got %+v", stats.TopQueries)
+	}
+}
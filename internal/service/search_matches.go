@@ -0,0 +1,148 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// Description excerpts: at most excerptLength characters, starting up to
+// excerptLead characters before the first match
+const (
+	excerptLength = 160
+	excerptLead   = 40
+)
+
+// queryStopWords are not worth highlighting
+var queryStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "in": true, "on": true,
+	"with": true, "for": true, "to": true, "at": true, "or": true, "by": true, "is": true,
+	"image": true, "images": true, "photo": true, "photos": true, "picture": true, "pictures": true,
+}
+
+// word is a run of letters and digits, with rune offsets into its text
+type word struct {
+	text       string // lowercased
+	start, end int
+}
+
+// splitWords returns the words of s
+func splitWords(s string) []word {
+	var words []word
+	start := -1
+	var current strings.Builder
+	i := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			current.WriteRune(unicode.ToLower(r))
+		} else if start >= 0 {
+			words = append(words, word{text: current.String(), start: start, end: i})
+			current.Reset()
+			start = -1
+		}
+		i++
+	}
+	if start >= 0 {
+		words = append(words, word{text: current.String(), start: start, end: i})
+	}
+	return words
+}
+
+// queryTerms returns the distinct words of a query worth matching
+func queryTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, w := range splitWords(query) {
+		if len(w.text) < 2 || queryStopWords[w.text] || seen[w.text] {
+			continue
+		}
+		seen[w.text] = true
+		terms = append(terms, w.text)
+	}
+	return terms
+}
+
+// stem folds simple English plurals ("cats", "boxes") onto the singular
+func stem(w string) string {
+	switch {
+	case len(w) > 4 && strings.HasSuffix(w, "ies"):
+		return w[:len(w)-3] + "y"
+	case len(w) > 4 && (strings.HasSuffix(w, "ches") || strings.HasSuffix(w, "shes") || strings.HasSuffix(w, "xes")):
+		return w[:len(w)-2]
+	case len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// highlightTerms returns the words of text that match a term
+func highlightTerms(text string, terms []string) []models.Highlight {
+	var highlights []models.Highlight
+	for _, w := range splitWords(text) {
+		for _, term := range terms {
+			if w.text == term || stem(w.text) == stem(term) {
+				highlights = append(highlights, models.Highlight{Start: w.start, End: w.end, Term: term})
+				break
+			}
+		}
+	}
+	return highlights
+}
+
+// explainMatches lists the indexed fields of img that contain terms of the
+// query, with highlight offsets, so UIs can show why an image was returned
+func explainMatches(query string, img *ImageMetadata) []models.SearchMatch {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var matches []models.SearchMatch
+	if highlights := highlightTerms(img.Title, terms); len(highlights) > 0 {
+		matches = append(matches, models.SearchMatch{Field: models.MatchFieldTitle, Value: img.Title, Highlights: highlights})
+	}
+	if highlights := highlightTerms(img.Description, terms); len(highlights) > 0 {
+		matches = append(matches, descriptionMatch(img.Description, highlights))
+	}
+	for _, tag := range img.Tags {
+		if highlights := highlightTerms(tag, terms); len(highlights) > 0 {
+			matches = append(matches, models.SearchMatch{Field: models.MatchFieldTags, Value: tag, Highlights: highlights})
+		}
+	}
+	for _, object := range img.Objects {
+		if highlights := highlightTerms(object, terms); len(highlights) > 0 {
+			matches = append(matches, models.SearchMatch{Field: models.MatchFieldObjects, Value: object, Highlights: highlights})
+		}
+	}
+	return matches
+}
+
+// descriptionMatch returns the description match, cut to an excerpt around
+// the first highlight if the description is long
+func descriptionMatch(description string, highlights []models.Highlight) models.SearchMatch {
+	runes := []rune(description)
+	if len(runes) <= excerptLength {
+		return models.SearchMatch{Field: models.MatchFieldDescription, Value: description, Highlights: highlights}
+	}
+
+	start := max(0, highlights[0].Start-excerptLead)
+	end := min(len(runes), start+excerptLength)
+	start = max(0, end-excerptLength)
+
+	var shifted []models.Highlight
+	for _, h := range highlights {
+		if h.Start >= start && h.End <= end {
+			shifted = append(shifted, models.Highlight{Start: h.Start - start, End: h.End - start, Term: h.Term})
+		}
+	}
+	return models.SearchMatch{
+		Field:      models.MatchFieldDescription,
+		Value:      string(runes[start:end]),
+		Highlights: shifted,
+		Excerpt:    true,
+	}
+}
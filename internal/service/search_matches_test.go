@@ -0,0 +1,76 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestExplainMatches(t *testing.T) {
+	img := &ImageMetadata{
+		Title:       "Black Cat at Night",
+		Description: "A black cat sitting on a windowsill, its eyes reflecting the street lights.",
+		Tags:        []string{"cats", "night sky", "urban"},
+		Objects:     []string{"cat", "window", "street lamp"},
+	}
+
+	matches := explainMatches("dark cats on a night street", img)
+	byField := make(map[string][]models.SearchMatch)
+	for _, m := range matches {
+		byField[m.Field] = append(byField[m.Field], m)
+	}
+
+	title := byField[models.MatchFieldTitle]
+	if len(title) != 1 || len(title[0].Highlights) != 2 {
+		t.Fatalf("expected cat and night highlighted in the title, got %+v", title)
+	}
+	if h := title[0].Highlights[0]; h.Start != 6 || h.End != 9 || h.Term != "cats" {
+		t.Errorf("unexpected title highlight %+v", h)
+	}
+
+	if tags := byField[models.MatchFieldTags]; len(tags) != 2 || tags[0].Value != "cats" || tags[1].Value != "night sky" {
+		t.Errorf("unexpected tag matches %+v", tags)
+	}
+	if objects := byField[models.MatchFieldObjects]; len(objects) != 2 || objects[0].Value != "cat" || objects[1].Value != "street lamp" {
+		t.Errorf("unexpected object matches %+v", objects)
+	}
+
+	desc := byField[models.MatchFieldDescription]
+	if len(desc) != 1 || desc[0].Excerpt {
+		t.Fatalf("expected the whole short description, got %+v", desc)
+	}
+	for _, h := range desc[0].Highlights {
+		if got := desc[0].Value[h.Start:h.End]; got != "cat" && got != "street" {
+			t.Errorf("highlight %+v covers %q", h, got)
+		}
+	}
+
+	if matches := explainMatches("the image", img); matches != nil {
+		t.Errorf("expected no matches for stop words, got %+v", matches)
+	}
+}
+
+func TestExplainMatches_DescriptionExcerpt(t *testing.T) {
+	description := strings.Repeat("Lorem ipsum dolor sit amet. ", 20) + "A red fox in the snow. " + strings.Repeat("Consectetur adipiscing elit. ", 20)
+	matches := explainMatches("fox", &ImageMetadata{Description: description})
+	if len(matches) != 1 {
+		t.Fatalf("expected one description match, got %+v", matches)
+	}
+
+	m := matches[0]
+	runes := []rune(m.Value)
+	if !m.Excerpt || len(runes) != excerptLength || len(m.Highlights) != 1 {
+		t.Fatalf("unexpected excerpt %+v", m)
+	}
+	if h := m.Highlights[0]; string(runes[h.Start:h.End]) != "fox" || h.Start != excerptLead {
+		t.Errorf("highlight %+v does not cover fox in %q", h, m.Value)
+	}
+}
+
+func TestHighlightTerms_UnicodeOffsets(t *testing.T) {
+	highlights := highlightTerms("Café Crème brûlée", queryTerms("brûlée"))
+	if len(highlights) != 1 || highlights[0].Start != 11 || highlights[0].End != 17 {
+		t.Errorf("expected character offsets 11-17, got %+v", highlights)
+	}
+}
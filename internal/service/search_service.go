@@ -3,15 +3,58 @@ package service
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/yourcompany/image-warehousing/internal/models"
 )
 
+const (
+	// DefaultSearchChunkSize is the largest slice of the index sent to the
+	// AI in one search prompt
+	DefaultSearchChunkSize = 1 << 20
+
+	// DefaultSearchConcurrency is how many index chunks are searched at once
+	DefaultSearchConcurrency = 4
+)
+
+// sectionRegex matches the start of an image entry in the index
+var sectionRegex = regexp.MustCompile(`(?m)^## Image: `)
+
+// indexSearcher ranks the images of an index excerpt against a query;
+// satisfied by AIService
+type indexSearcher interface {
+	SearchImages(ctx context.Context, indexContent, query string) ([]models.SearchResult, error)
+}
+
 type SearchService struct {
 	indexService *IndexService
-	aiService    *AIService
+	aiService    indexSearcher
 	logger       *logrus.Logger
+
+	// Large indexes are split into chunks searched in parallel
+	chunkSize   int
+	concurrency int
+
+	// AI calls are spaced at least interval apart across all searches
+	interval time.Duration
+	rateMu   sync.Mutex
+	nextCall time.Time
+
+	// Conversational searches refined over several turns
+	sessions *SearchSessionStore
+
+	// Default ranking weights, and the embeddings the embedding signal uses
+	weights    models.RankingWeights
+	embeddings *EmbeddingStore
+	embedder   Embedder
+
+	// Every search, for query analytics; nil logs nothing
+	searchLog *SearchLog
 }
 
 func NewSearchService(index *IndexService, ai *AIService, logger *logrus.Logger) *SearchService {
@@ -19,26 +62,147 @@ func NewSearchService(index *IndexService, ai *AIService, logger *logrus.Logger)
 		indexService: index,
 		aiService:    ai,
 		logger:       logger,
+		chunkSize:    DefaultSearchChunkSize,
+		concurrency:  DefaultSearchConcurrency,
+		sessions:     NewSearchSessionStore(DefaultSearchSessionTTL),
+		weights:      models.DefaultRankingWeights(),
+	}
+}
+
+// SetChunking configures how large indexes are searched: the maximum chunk
+// size in bytes, how many chunks are searched at once, and the maximum AI
+// calls per minute (0 = unlimited). Non-positive sizes keep the defaults.
+func (s *SearchService) SetChunking(chunkSize, concurrency, ratePerMinute int) {
+	if chunkSize > 0 {
+		s.chunkSize = chunkSize
+	}
+	if concurrency > 0 {
+		s.concurrency = concurrency
+	}
+	s.interval = 0
+	if ratePerMinute > 0 {
+		s.interval = time.Minute / time.Duration(ratePerMinute)
+	}
+}
+
+// SetSearchLog records every search in log
+func (s *SearchService) SetSearchLog(log *SearchLog) {
+	s.searchLog = log
+}
+
+// SearchLog returns the search log, nil if searches aren't recorded
+func (s *SearchService) SearchLog() *SearchLog {
+	return s.searchLog
+}
+
+// logSearch records a search that started at start, failed if err is set
+func (s *SearchService) logSearch(kind, query string, start time.Time, results int, err error) {
+	entry := SearchLogEntry{Time: start, Kind: kind, Query: query, Results: results, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if err := s.searchLog.Record(entry); err != nil {
+		s.logger.Warnf("Failed to record search: %v", err)
 	}
 }
 
+// SearchFilter restricts search results by indexed metadata; empty fields match everything
+type SearchFilter struct {
+	Category       string // category path; a primary category includes its sub categories
+	Workflow       string
+	License        string // license type, case-insensitive
+	ExcludeExpired bool   // drop images whose license has expired
+	Dimensions     DimensionFilter
+	Size           SizeFilter        // real-world size of 3D objects
+	Polygons       PolygonFilter     // triangle budget and LOD tag of 3D objects
+	Textures       TextureFilter     // texture resolution and shading of 3D objects
+	Attributes     map[string]string // custom attributes (see models.MatchAttributes)
+	Project        string            // project ID
+	Features       FeatureFilter     // confidence of AI features matching the query
+
+	// Ranking weights overriding the configured ones; they order results
+	// but don't drop any
+	Weights *models.RankingWeights
+}
+
+// IsZero reports whether the filter matches everything
+func (f SearchFilter) IsZero() bool {
+	return f.Category == "" && f.Workflow == "" && f.License == "" && !f.ExcludeExpired && f.Dimensions.IsZero() && f.Size.IsZero() && f.Polygons.IsZero() && f.Textures.IsZero() && len(f.Attributes) == 0 && f.Project == "" && f.Features.IsZero()
+}
+
+// matches reports whether an image found for query passes the filter at now
+func (f SearchFilter) matches(img *ImageMetadata, query string, now time.Time) bool {
+	if f.Category != "" && !img.InCategory(f.Category) {
+		return false
+	}
+	if f.Workflow != "" && img.Workflow != f.Workflow {
+		return false
+	}
+	if f.License != "" && (img.License == nil || !strings.EqualFold(img.License.Type, f.License)) {
+		return false
+	}
+	if f.ExcludeExpired && img.License.Expired(now) {
+		return false
+	}
+	if f.Project != "" && img.Project != f.Project {
+		return false
+	}
+	if !img.HasAttributes(f.Attributes) {
+		return false
+	}
+	return f.Dimensions.Matches(img) && f.Size.Matches(img) && f.Polygons.Matches(img) && f.Textures.Matches(img) && f.Features.Matches(img, query)
+}
+
+// selection returns the index sections the filter can match, so searches
+// only hand the AI those
+func (f SearchFilter) selection() SectionSelection {
+	if f.Category == "" {
+		return SectionSelection{}
+	}
+	return SectionSelection{Categories: []string{f.Category}}
+}
+
 // Search performs a semantic search using Gemini
 func (s *SearchService) Search(ctx context.Context, query string, limit int) (*models.SearchResponse, error) {
+	return s.SearchWithFilter(ctx, query, limit, SearchFilter{})
+}
+
+// SearchWithFilter performs a semantic search and drops results that don't
+// match the filter before applying the limit
+func (s *SearchService) SearchWithFilter(ctx context.Context, query string, limit int, filter SearchFilter) (*models.SearchResponse, error) {
+	start := time.Now()
+	response, err := s.searchWithFilter(ctx, query, limit, filter)
+	results := 0
+	if response != nil {
+		results = response.Total
+	}
+	s.logSearch(SearchKindSearch, query, start, results, err)
+	return response, err
+}
+
+func (s *SearchService) searchWithFilter(ctx context.Context, query string, limit int, filter SearchFilter) (*models.SearchResponse, error) {
 	s.logger.Infof("Searching for: %s (limit: %d)", query, limit)
 
-	// 1. Read the entire index
-	indexContent, err := s.indexService.ReadIndex()
+	// 1. Read the index, only the filter's category if it has one
+	indexContent, err := s.indexService.ReadSections(filter.selection())
 	if err != nil {
-		return nil, fmt.Errorf("failed to read index: %w", err)
+		return nil, err
 	}
 
-	// 2. Use Gemini to search and rank results
-	results, err := s.aiService.SearchImages(ctx, indexContent, query)
+	// 2. Use Gemini to search and rank results, a chunk of the index at a time
+	chunks := chunkIndex(indexContent, s.chunkSize)
+	results, failed, err := s.searchChunks(ctx, chunks, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search with AI: %w", err)
 	}
 
-	// 3. Apply limit
+	// 3. Apply metadata filters and attach license warnings and match info
+	results, err = s.filterResults(results, query, filter, s.ranker(ctx, query, filter))
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Apply limit
 	if len(results) > limit {
 		results = results[:limit]
 	}
@@ -48,8 +212,201 @@ func (s *SearchService) Search(ctx context.Context, query string, limit int) (*m
 		Total:   len(results),
 		Query:   query,
 	}
+	markPartial(response, failed, len(chunks))
 
 	s.logger.Infof("Found %d results for query: %s", len(results), query)
 
 	return response, nil
 }
+
+// filterResults keeps the results whose indexed image matches the filter and
+// attaches license warnings and the fields that matched the query. Results
+// unknown to the index are only kept by an empty filter. Images whose AI
+// features match the query rank higher, by the features' confidence, before
+// rank combines the relevance with the other ranking signals.
+func (s *SearchService) filterResults(results []models.SearchResult, query string, filter SearchFilter, rank *ranker) ([]models.SearchResult, error) {
+	ids := make(map[string]bool, len(results))
+	for _, result := range results {
+		ids[result.ImageID] = true
+	}
+	images, err := s.indexService.SelectImages(SectionSelection{IDs: ids})
+	if err != nil {
+		return nil, err
+	}
+	return filterIndexed(results, query, filter, imagesByID(images), rank), nil
+}
+
+// imagesByID maps image IDs to their metadata
+func imagesByID(images []*ImageMetadata) map[string]*ImageMetadata {
+	byID := make(map[string]*ImageMetadata, len(images))
+	for _, img := range images {
+		byID[img.ID] = img
+	}
+	return byID
+}
+
+// filterIndexed is filterResults against images already read from the index
+func filterIndexed(results []models.SearchResult, query string, filter SearchFilter, byID map[string]*ImageMetadata, rank *ranker) []models.SearchResult {
+	now := time.Now()
+	terms := queryTerms(query)
+	filtered := results[:0]
+	for _, result := range results {
+		img, ok := byID[result.ImageID]
+		if !ok {
+			if filter.IsZero() {
+				filtered = append(filtered, result)
+			}
+			continue
+		}
+		if !filter.matches(img, query, now) {
+			continue
+		}
+		result.RelevanceScore = rank.score(boostByFeatures(result.RelevanceScore, img, terms), img, terms)
+		result.Warnings = img.License.Warnings(now)
+		result.Matches = explainMatches(query, img)
+		filtered = append(filtered, result)
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].RelevanceScore > filtered[j].RelevanceScore
+	})
+	return filtered
+}
+
+// chunkIndex splits index content into chunks of whole image entries, each at
+// most maxBytes long unless a single entry is larger. An index that fits is
+// returned as is.
+func chunkIndex(content string, maxBytes int) []string {
+	if len(content) <= maxBytes {
+		return []string{content}
+	}
+
+	starts := sectionRegex.FindAllStringIndex(content, -1)
+	var chunks []string
+	var current strings.Builder
+	for i, match := range starts {
+		end := len(content)
+		if i < len(starts)-1 {
+			end = starts[i+1][0]
+		}
+		section := content[match[0]:end]
+
+		if current.Len() > 0 && current.Len()+len(section) > maxBytes {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		current.WriteString(section)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// searchChunks searches each index chunk in parallel and merges the results,
+// keeping the best score per image, ranked by relevance. Failed chunks are
+// logged, skipped and counted; the search only fails if every chunk does.
+func (s *SearchService) searchChunks(ctx context.Context, chunks []string, query string) ([]models.SearchResult, int, error) {
+	if len(chunks) == 1 {
+		if err := s.throttle(ctx); err != nil {
+			return nil, 0, err
+		}
+		results, err := s.aiService.SearchImages(ctx, chunks[0], query)
+		return results, 0, err
+	}
+	s.logger.Infof("Searching %d index chunks", len(chunks))
+
+	var (
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+		best     = make(map[string]models.SearchResult)
+		failures int
+		lastErr  error
+	)
+	sem := make(chan struct{}, s.concurrency)
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			err := s.throttle(ctx)
+			var results []models.SearchResult
+			if err == nil {
+				results, err = s.aiService.SearchImages(ctx, chunk, query)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				s.logger.Warnf("Search of index chunk %d/%d failed: %v", i+1, len(chunks), err)
+				failures++
+				lastErr = err
+				return
+			}
+			keepBest(best, results)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	if failures == len(chunks) {
+		return nil, 0, lastErr
+	}
+	return rankResults(best), failures, nil
+}
+
+// markPartial flags a response when failed of its chunks could not be
+// searched
+func markPartial(response *models.SearchResponse, failed, chunks int) {
+	if failed == 0 {
+		return
+	}
+	response.Partial = true
+	response.Warnings = append(response.Warnings, fmt.Sprintf("%d of %d index chunks could not be searched; results may be missing", failed, chunks))
+}
+
+// keepBest records results in best, keeping the higher score per image
+func keepBest(best map[string]models.SearchResult, results []models.SearchResult) {
+	for _, result := range results {
+		if existing, ok := best[result.ImageID]; !ok || result.RelevanceScore > existing.RelevanceScore {
+			best[result.ImageID] = result
+		}
+	}
+}
+
+// rankResults orders merged results by relevance, then image ID
+func rankResults(best map[string]models.SearchResult) []models.SearchResult {
+	merged := make([]models.SearchResult, 0, len(best))
+	for _, result := range best {
+		merged = append(merged, result)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].RelevanceScore != merged[j].RelevanceScore {
+			return merged[i].RelevanceScore > merged[j].RelevanceScore
+		}
+		return merged[i].ImageID < merged[j].ImageID
+	})
+	return merged
+}
+
+// throttle waits for the next free AI call slot under the rate limit
+func (s *SearchService) throttle(ctx context.Context) error {
+	if s.interval <= 0 {
+		return nil
+	}
+
+	s.rateMu.Lock()
+	slot := time.Now()
+	if s.nextCall.After(slot) {
+		slot = s.nextCall
+	}
+	s.nextCall = slot.Add(s.interval)
+	s.rateMu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(slot)):
+		return nil
+	}
+}
@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -234,3 +236,137 @@ func TestSearchResponse_Structure(t *testing.T) {
 		t.Errorf("expected 2 results, got %d", len(response.Results))
 	}
 }
+
+func TestFilterResults_License(t *testing.T) {
+	indexSvc := NewIndexService(t.TempDir())
+	if err := indexSvc.InitializeIndex(); err != nil {
+		t.Fatalf("failed to initialize index: %v", err)
+	}
+
+	expired := &models.License{Type: "editorial", ExpiresOn: "2000-01-01"}
+	valid := &models.License{Type: "CC-BY-4.0", Restrictions: "Credit required"}
+	for id, license := range map[string]*models.License{"old": expired, "ok": valid, "none": nil} {
+		img := &models.Image{ID: id, Title: id, Artist: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), License: license}
+		if err := indexSvc.AppendToIndex(img); err != nil {
+			t.Fatalf("failed to append to index: %v", err)
+		}
+	}
+
+	searchSvc := &SearchService{indexService: indexSvc, logger: logrus.New()}
+	results := func() []models.SearchResult {
+		return []models.SearchResult{{ImageID: "old"}, {ImageID: "ok"}, {ImageID: "none"}}
+	}
+
+	all, err := searchSvc.filterResults(results(), "", SearchFilter{}, nil)
+	if err != nil {
+		t.Fatalf("filterResults failed: %v", err)
+	}
+	if len(all) != 3 || len(all[0].Warnings) != 1 || len(all[1].Warnings) != 1 || len(all[2].Warnings) != 0 {
+		t.Errorf("unexpected warnings: %+v", all)
+	}
+
+	current, _ := searchSvc.filterResults(results(), "", SearchFilter{ExcludeExpired: true}, nil)
+	if len(current) != 2 || current[0].ImageID != "ok" || current[1].ImageID != "none" {
+		t.Errorf("expected expired license to be excluded, got %+v", current)
+	}
+
+	byType, _ := searchSvc.filterResults(results(), "", SearchFilter{License: "cc-by-4.0"}, nil)
+	if len(byType) != 1 || byType[0].ImageID != "ok" {
+		t.Errorf("expected license type filter to match ok, got %+v", byType)
+	}
+}
+
+func TestChunkIndex(t *testing.T) {
+	content := "# Image Index\n\n## Image: a\n\naaaa\n---\n## Image: b\n\nbbbb\n---\n## Image: c\n\ncccc\n---\n"
+	if chunks := chunkIndex(content, len(content)); len(chunks) != 1 || chunks[0] != content {
+		t.Errorf("expected an index that fits to be returned as is, got %q", chunks)
+	}
+
+	chunks := chunkIndex(content, 45)
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d: %q", len(chunks), chunks)
+	}
+	if !strings.HasPrefix(chunks[0], "## Image: a") || !strings.Contains(chunks[0], "## Image: b") || !strings.HasPrefix(chunks[1], "## Image: c") {
+		t.Errorf("expected chunks of whole entries, got %q", chunks)
+	}
+}
+
+func TestSearch_PartialWhenChunksFail(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+	indexSvc := NewIndexService(t.TempDir())
+	if err := indexSvc.InitializeIndex(); err != nil {
+		t.Fatalf("failed to initialize index: %v", err)
+	}
+	for _, id := range []string{"img1", "img2"} {
+		if err := indexSvc.AppendToIndex(&models.Image{ID: id, Title: id, Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()}); err != nil {
+			t.Fatalf("failed to append to index: %v", err)
+		}
+	}
+
+	failing := false
+	mock := &MockAIService{SearchImagesFunc: func(ctx context.Context, indexContent, query string) ([]models.SearchResult, error) {
+		if failing && strings.Contains(indexContent, "## Image: img2") {
+			return nil, errors.New("quota exceeded")
+		}
+		var results []models.SearchResult
+		for _, id := range []string{"img1", "img2"} {
+			if strings.Contains(indexContent, "## Image: "+id) {
+				results = append(results, models.SearchResult{ImageID: id, RelevanceScore: 0.5})
+			}
+		}
+		return results, nil
+	}}
+	svc := NewSearchService(indexSvc, nil, logger)
+	svc.aiService = mock
+	svc.SetChunking(1, 1, 0) // a chunk per image
+
+	resp, err := svc.Search(context.Background(), "q", 10)
+	if err != nil || resp.Total != 2 || resp.Partial || len(resp.Warnings) != 0 {
+		t.Fatalf("expected a complete search, got %+v (%v)", resp, err)
+	}
+
+	failing = true
+	resp, err = svc.Search(context.Background(), "q", 10)
+	if err != nil || resp.Total != 1 || !resp.Partial || len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0], "1 of 2 index chunks") {
+		t.Errorf("expected a partial search with a warning, got %+v (%v)", resp, err)
+	}
+}
+
+func TestSearchChunks_MergesAndReranks(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+
+	mock := &MockAIService{SearchImagesFunc: func(ctx context.Context, indexContent, query string) ([]models.SearchResult, error) {
+		switch {
+		case strings.Contains(indexContent, "## Image: a"):
+			return []models.SearchResult{{ImageID: "a", RelevanceScore: 0.5}, {ImageID: "shared", RelevanceScore: 0.2}}, nil
+		case strings.Contains(indexContent, "## Image: b"):
+			return []models.SearchResult{{ImageID: "b", RelevanceScore: 0.9}, {ImageID: "shared", RelevanceScore: 0.7}}, nil
+		default:
+			return nil, errors.New("quota exceeded")
+		}
+	}}
+	svc := &SearchService{aiService: mock, logger: logger, concurrency: 2}
+	svc.SetChunking(0, 0, 6000) // 10ms apart
+
+	results, failed, err := svc.searchChunks(context.Background(), []string{"## Image: a\n", "## Image: b\n", "## Image: c\n"}, "q")
+	if err != nil {
+		t.Fatalf("searchChunks failed: %v", err)
+	}
+	if failed != 1 {
+		t.Errorf("expected the failed chunk to be counted, got %d", failed)
+	}
+	var ids []string
+	for _, r := range results {
+		ids = append(ids, r.ImageID)
+	}
+	if strings.Join(ids, ",") != "b,shared,a" || results[1].RelevanceScore != 0.7 {
+		t.Errorf("expected merged results ranked b,shared,a with the best shared score, got %+v", results)
+	}
+
+	// Every chunk failing fails the search
+	if _, _, err := svc.searchChunks(context.Background(), []string{"## Image: c\n", "## Image: d\n"}, "q"); err == nil {
+		t.Error("expected an error when every chunk fails")
+	}
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// DefaultSearchSessionTTL is how long an idle search session is kept
+const DefaultSearchSessionTTL = 30 * time.Minute
+
+// maxChatTurns is how many earlier turns of a session are replayed to the AI
+const maxChatTurns = 10
+
+// ErrSessionNotFound is returned for an unknown or expired search session
+var ErrSessionNotFound = errors.New("search session not found")
+
+// chatSearcher refines a search in a chat, with the earlier turns of the
+// session as history; satisfied by AIService. Searchers without it get the
+// queries of the session joined into one.
+type chatSearcher interface {
+	RefineSearch(ctx context.Context, indexContent string, history []SearchTurn, query string) ([]models.SearchResult, error)
+}
+
+// SearchTurn is one query of a search session and the results it returned
+type SearchTurn struct {
+	Query   string                `json:"query"`
+	Results []models.SearchResult `json:"results"`
+}
+
+// SearchSession is a search refined over several turns. The metadata filter
+// and limit of the first search apply to every turn.
+type SearchSession struct {
+	ID        string       `json:"session_id"`
+	Filter    SearchFilter `json:"-"`
+	Limit     int          `json:"limit"`
+	Turns     []SearchTurn `json:"turns"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// SearchSessionStore keeps search sessions in memory. Sessions expire after
+// the configured TTL without a new turn.
+type SearchSessionStore struct {
+	sessions map[string]*SearchSession
+	mutex    sync.Mutex
+	ttl      time.Duration
+}
+
+func NewSearchSessionStore(ttl time.Duration) *SearchSessionStore {
+	return &SearchSessionStore{
+		sessions: make(map[string]*SearchSession),
+		ttl:      ttl,
+	}
+}
+
+// Create opens a session with its first turn
+func (s *SearchSessionStore) Create(filter SearchFilter, limit int, first SearchTurn) *SearchSession {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	session := &SearchSession{
+		ID:        uuid.New().String(),
+		Filter:    filter,
+		Limit:     limit,
+		Turns:     []SearchTurn{first},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.sessions[session.ID] = session
+	return session.copy()
+}
+
+// Get returns a copy of a session
+func (s *SearchSessionStore) Get(id string) (*SearchSession, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || s.expired(session) {
+		return nil, false
+	}
+	return session.copy(), true
+}
+
+// AddTurn appends a turn to a session and returns the updated session
+func (s *SearchSessionStore) AddTurn(id string, turn SearchTurn) (*SearchSession, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || s.expired(session) {
+		return nil, ErrSessionNotFound
+	}
+	session.Turns = append(session.Turns, turn)
+	session.UpdatedAt = time.Now()
+	return session.copy(), nil
+}
+
+// Delete drops a session, reporting whether it existed
+func (s *SearchSessionStore) Delete(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, ok := s.sessions[id]
+	delete(s.sessions, id)
+	return ok && !s.expired(session)
+}
+
+// EvictExpired removes expired sessions and returns how many were removed
+func (s *SearchSessionStore) EvictExpired() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	evicted := 0
+	for id, session := range s.sessions {
+		if s.expired(session) {
+			delete(s.sessions, id)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Run evicts expired sessions every interval until ctx is done
+func (s *SearchSessionStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.EvictExpired()
+		}
+	}
+}
+
+// expired reports whether a session has been idle past the TTL. Caller must
+// hold the lock.
+func (s *SearchSessionStore) expired(session *SearchSession) bool {
+	return s.ttl > 0 && time.Since(session.UpdatedAt) > s.ttl
+}
+
+func (session *SearchSession) copy() *SearchSession {
+	c := *session
+	c.Turns = append([]SearchTurn(nil), session.Turns...)
+	return &c
+}
+
+// SetSessions replaces the store search sessions are kept in
+func (s *SearchService) SetSessions(sessions *SearchSessionStore) {
+	s.sessions = sessions
+}
+
+// Session returns a search session
+func (s *SearchService) Session(id string) (*SearchSession, error) {
+	session, ok := s.sessions.Get(id)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// EndSession drops a search session
+func (s *SearchService) EndSession(id string) error {
+	if !s.sessions.Delete(id) {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// StartSession runs a search like SearchWithFilter and opens a session its
+// results can be refined in
+func (s *SearchService) StartSession(ctx context.Context, query string, limit int, filter SearchFilter) (*models.SearchSessionResponse, error) {
+	response, err := s.SearchWithFilter(ctx, query, limit, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	session := s.sessions.Create(filter, limit, SearchTurn{Query: query, Results: response.Results})
+	return &models.SearchSessionResponse{SessionID: session.ID, Turn: 1, SearchResponse: *response}, nil
+}
+
+// RefineSession refines the last search of a session ("only the darker
+// ones", "exclude AI-generated"). The AI sees the earlier queries and their
+// results as a chat, so constraints carry over from turn to turn. A limit of
+// 0 keeps the session's limit.
+func (s *SearchService) RefineSession(ctx context.Context, sessionID, query string, limit int) (*models.SearchSessionResponse, error) {
+	start := time.Now()
+	response, err := s.refineSession(ctx, sessionID, query, limit)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, err
+	}
+	results := 0
+	if response != nil {
+		results = response.Total
+	}
+	s.logSearch(SearchKindRefine, query, start, results, err)
+	return response, err
+}
+
+func (s *SearchService) refineSession(ctx context.Context, sessionID, query string, limit int) (*models.SearchSessionResponse, error) {
+	session, ok := s.sessions.Get(sessionID)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	if limit <= 0 {
+		limit = session.Limit
+	}
+	s.logger.Infof("Refining search session %s (turn %d): %s", sessionID, len(session.Turns)+1, query)
+
+	// 1. Read the index the session's filter can match, for the AI and for
+	// the filter
+	indexContent, err := s.indexService.ReadSections(session.Filter.selection())
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*ImageMetadata)
+	err = scanIndex(strings.NewReader(indexContent), func(img *ImageMetadata) {
+		if !img.Deleted {
+			byID[img.ID] = img
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	// 2. Continue the conversation with the AI
+	history := session.Turns
+	if len(history) > maxChatTurns {
+		history = history[len(history)-maxChatTurns:]
+	}
+	previous := session.Turns[len(session.Turns)-1].Results
+	if err := s.throttle(ctx); err != nil {
+		return nil, err
+	}
+	results, err := s.refine(ctx, sessionIndex(indexContent, s.chunkSize, previous), history, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search with AI: %w", err)
+	}
+
+	// 3. Apply the session's filter and the limit
+	results = filterIndexed(results, query, session.Filter, byID, s.ranker(ctx, query, session.Filter))
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	session, err = s.sessions.AddTurn(sessionID, SearchTurn{Query: query, Results: results})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("Found %d results refining session %s", len(results), sessionID)
+
+	return &models.SearchSessionResponse{
+		SessionID:      sessionID,
+		Turn:           len(session.Turns),
+		SearchResponse: models.SearchResponse{Results: results, Total: len(results), Query: query},
+	}, nil
+}
+
+// refine asks the AI for the results of a refinement, in a chat when the
+// searcher supports one
+func (s *SearchService) refine(ctx context.Context, indexContent string, history []SearchTurn, query string) ([]models.SearchResult, error) {
+	if chat, ok := s.aiService.(chatSearcher); ok {
+		return chat.RefineSearch(ctx, indexContent, history, query)
+	}
+
+	queries := make([]string, 0, len(history)+1)
+	for _, turn := range history {
+		queries = append(queries, turn.Query)
+	}
+	return s.aiService.SearchImages(ctx, indexContent, strings.Join(append(queries, query), "; "))
+}
+
+// sessionIndex returns the index excerpt a refinement is answered against:
+// the whole index when it fits one search chunk, otherwise the entries of the
+// previous turn's results
+func sessionIndex(indexContent string, chunkSize int, previous []models.SearchResult) string {
+	if len(indexContent) <= chunkSize {
+		return indexContent
+	}
+
+	var sb strings.Builder
+	for _, result := range previous {
+		if start, end, ok := findImageSection(indexContent, result.ImageID); ok {
+			sb.WriteString(indexContent[start:end])
+		}
+	}
+	return sb.String()
+}
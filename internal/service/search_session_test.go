@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// MockChatAIService refines searches by dropping the images a refinement
+// names from the previous turn's results
+type MockChatAIService struct {
+	MockAIService
+	histories [][]SearchTurn
+	indexes   []string
+}
+
+func (m *MockChatAIService) RefineSearch(ctx context.Context, indexContent string, history []SearchTurn, query string) ([]models.SearchResult, error) {
+	m.histories = append(m.histories, history)
+	m.indexes = append(m.indexes, indexContent)
+
+	var results []models.SearchResult
+	for _, r := range history[len(history)-1].Results {
+		if !strings.Contains(query, r.ImageID) {
+			results = append(results, models.SearchResult{ImageID: r.ImageID, RelevanceScore: r.RelevanceScore})
+		}
+	}
+	return results, nil
+}
+
+func newSessionSearchService(t *testing.T, ai indexSearcher) *SearchService {
+	t.Helper()
+	svc := newBatchSearchService(t, ai)
+	svc.sessions = NewSearchSessionStore(time.Minute)
+	return svc
+}
+
+func catsSearch(ctx context.Context, indexContent, query string) ([]models.SearchResult, error) {
+	return []models.SearchResult{
+		{ImageID: "cat-1", RelevanceScore: 0.9},
+		{ImageID: "cat-2", RelevanceScore: 0.8},
+		{ImageID: "dog-1", RelevanceScore: 0.1},
+	}, nil
+}
+
+func TestSearchSession_RefinesPreviousResults(t *testing.T) {
+	mock := &MockChatAIService{MockAIService: MockAIService{SearchImagesFunc: catsSearch}}
+	svc := newSessionSearchService(t, mock)
+	ctx := context.Background()
+
+	first, err := svc.StartSession(ctx, "cats", 10, SearchFilter{})
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	if first.SessionID == "" || first.Turn != 1 || first.Total != 3 {
+		t.Fatalf("unexpected first turn: %+v", first)
+	}
+
+	second, err := svc.RefineSession(ctx, first.SessionID, "not dog-1", 0)
+	if err != nil {
+		t.Fatalf("RefineSession failed: %v", err)
+	}
+	if second.Turn != 2 || second.Total != 2 || second.Query != "not dog-1" {
+		t.Fatalf("unexpected second turn: %+v", second)
+	}
+
+	third, err := svc.RefineSession(ctx, first.SessionID, "not cat-2", 0)
+	if err != nil {
+		t.Fatalf("RefineSession failed: %v", err)
+	}
+	if third.Turn != 3 || third.Total != 1 || third.Results[0].ImageID != "cat-1" {
+		t.Fatalf("unexpected third turn: %+v", third)
+	}
+
+	// Each refinement sees every earlier turn as chat history
+	if len(mock.histories) != 2 || len(mock.histories[1]) != 2 || mock.histories[1][1].Query != "not dog-1" {
+		t.Errorf("unexpected chat histories: %+v", mock.histories)
+	}
+
+	session, err := svc.Session(first.SessionID)
+	if err != nil {
+		t.Fatalf("Session failed: %v", err)
+	}
+	if len(session.Turns) != 3 {
+		t.Errorf("expected 3 turns, got %d", len(session.Turns))
+	}
+}
+
+func TestSearchSession_KeepsFilterAndLimit(t *testing.T) {
+	mock := &MockChatAIService{MockAIService: MockAIService{SearchImagesFunc: catsSearch}}
+	svc := newSessionSearchService(t, mock)
+	ctx := context.Background()
+
+	first, err := svc.StartSession(ctx, "cats", 10, SearchFilter{License: "cc-by-4.0"})
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	if first.Total != 1 || first.Results[0].ImageID != "cat-2" {
+		t.Fatalf("expected the filter to keep cat-2, got %+v", first.Results)
+	}
+
+	// The filter applies to refinements even if the AI returns other images
+	svc.aiService = &MockAIService{SearchImagesFunc: catsSearch}
+	refined, err := svc.RefineSession(ctx, first.SessionID, "any", 0)
+	if err != nil {
+		t.Fatalf("RefineSession failed: %v", err)
+	}
+	if refined.Total != 1 || refined.Results[0].ImageID != "cat-2" {
+		t.Errorf("expected the session filter to apply, got %+v", refined.Results)
+	}
+
+	limited, err := svc.StartSession(ctx, "cats", 1, SearchFilter{})
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	refined, err = svc.RefineSession(ctx, limited.SessionID, "any", 0)
+	if err != nil {
+		t.Fatalf("RefineSession failed: %v", err)
+	}
+	if refined.Total != 1 {
+		t.Errorf("expected the session limit of 1, got %d", refined.Total)
+	}
+}
+
+func TestSearchSession_LargeIndexUsesPreviousResults(t *testing.T) {
+	mock := &MockChatAIService{MockAIService: MockAIService{SearchImagesFunc: catsSearch}}
+	svc := newSessionSearchService(t, mock)
+	svc.chunkSize = 100
+	ctx := context.Background()
+
+	first, err := svc.StartSession(ctx, "cats", 2, SearchFilter{})
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	if _, err := svc.RefineSession(ctx, first.SessionID, "darker", 0); err != nil {
+		t.Fatalf("RefineSession failed: %v", err)
+	}
+
+	index := mock.indexes[0]
+	if !strings.Contains(index, "## Image: cat-1") || !strings.Contains(index, "## Image: cat-2") {
+		t.Errorf("expected the previous results in the excerpt, got %q", index)
+	}
+	if strings.Contains(index, "## Image: dog-1") {
+		t.Error("expected images outside the previous results to be left out")
+	}
+}
+
+func TestSearchSession_Unknown(t *testing.T) {
+	svc := newSessionSearchService(t, &MockChatAIService{})
+
+	if _, err := svc.RefineSession(context.Background(), "missing", "darker", 0); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	if err := svc.EndSession("missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestSearchSessionStore_Expiry(t *testing.T) {
+	store := NewSearchSessionStore(time.Minute)
+	session := store.Create(SearchFilter{}, 10, SearchTurn{Query: "cats"})
+
+	store.sessions[session.ID].UpdatedAt = time.Now().Add(-2 * time.Minute)
+	if _, ok := store.Get(session.ID); ok {
+		t.Error("expected an idle session to expire")
+	}
+	if _, err := store.AddTurn(session.ID, SearchTurn{Query: "darker"}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	if evicted := store.EvictExpired(); evicted != 1 {
+		t.Errorf("expected 1 eviction, got %d", evicted)
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// Series detection thresholds
+const (
+	// SeriesWindow is the longest time between two shots of a series
+	SeriesWindow = 10 * time.Minute
+	// burstGap is the longest time between two captures of a burst
+	burstGap = 2 * time.Second
+	// sequenceGap is the largest step between the numbers of two filenames
+	// in a numbered sequence (e.g. IMG_0231.jpg, IMG_0233.jpg)
+	sequenceGap = 3
+	// similarHashDistance is the most bits in which the visual hashes of
+	// near-identical shots differ; differentHashDistance the least bits in
+	// which unrelated shots do
+	similarHashDistance   = 10
+	differentHashDistance = 24
+)
+
+// SeriesInfo places an image in a detected series: a burst, numbered
+// sequence or run of near-identical shots
+type SeriesInfo struct {
+	ID             string `json:"id"` // ID of the series' first image
+	Size           int    `json:"size"`
+	Representative string `json:"representative"` // image shown for the collapsed series
+}
+
+// VisualHash returns a 64-bit difference hash of an image as 16 hex digits:
+// one bit per neighboring pixel pair of a 9x8 grayscale thumbnail, set where
+// brightness falls. Near-identical images differ in few bits.
+func VisualHash(img image.Image) string {
+	luma := grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if luma.pix[y*9+x] > luma.pix[y*9+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", hash)
+}
+
+// hashDistance is the number of bits in which two visual hashes differ, or
+// -1 if either is missing
+func hashDistance(a, b string) int {
+	x, errA := strconv.ParseUint(a, 16, 64)
+	y, errB := strconv.ParseUint(b, 16, 64)
+	if errA != nil || errB != nil {
+		return -1
+	}
+	return bits.OnesCount64(x ^ y)
+}
+
+var sequenceNumber = regexp.MustCompile(`^(.*?\D)(\d+)(?: \(\d+\))?$`)
+
+// filenameSequence splits a filename such as "IMG_0231.jpg" into its stem
+// and number; ok is false for names without a trailing number
+func filenameSequence(filename string) (stem string, n int, ok bool) {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	m := sequenceNumber.FindStringSubmatch(base)
+	if m == nil {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return strings.ToLower(m[1]), n, true
+}
+
+// seriesShot is an image considered for a series
+type seriesShot struct {
+	img      *ImageMetadata
+	at       time.Time
+	captured bool // at is the capture time, not the upload time
+	stem     string
+	number   int
+	numbered bool
+}
+
+// sameSeries reports whether two shots, taken within SeriesWindow of each
+// other, belong to one series: a burst, consecutive numbers of one filename
+// sequence, or near-identical pictures. Clearly different pictures never do.
+func sameSeries(a, b *seriesShot) bool {
+	distance := hashDistance(a.img.VisualHash, b.img.VisualHash)
+	if distance > differentHashDistance {
+		return false
+	}
+	if a.captured && b.captured && b.at.Sub(a.at) <= burstGap {
+		return true
+	}
+	if a.numbered && b.numbered && a.stem == b.stem {
+		if step := a.number - b.number; step != 0 && step >= -sequenceGap && step <= sequenceGap {
+			return true
+		}
+	}
+	return distance >= 0 && distance <= similarHashDistance
+}
+
+// DetectSeries groups 2D images into series and sets their Series; images
+// outside any series get none. Each series is represented by its best image:
+// highest quality score, then aesthetic score, then the earliest shot.
+func DetectSeries(images []*ImageMetadata) {
+	var shots []*seriesShot
+	for _, img := range images {
+		img.Series = nil
+		if img.Deleted || img.Type != "2D" {
+			continue
+		}
+		shot := &seriesShot{img: img}
+		if at, err := img.CapturedTime(); err == nil {
+			shot.at, shot.captured = at, true
+		} else if at, err := img.UploadedTime(); err == nil {
+			shot.at = at
+		} else {
+			continue
+		}
+		shot.stem, shot.number, shot.numbered = filenameSequence(img.OriginalFilename)
+		shots = append(shots, shot)
+	}
+	sort.SliceStable(shots, func(i, j int) bool { return shots[i].at.Before(shots[j].at) })
+
+	// Union the shots related to a later one within the window
+	parent := make([]int, len(shots))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range shots {
+		for j := i + 1; j < len(shots) && shots[j].at.Sub(shots[i].at) <= SeriesWindow; j++ {
+			if !sameSeries(shots[i], shots[j]) {
+				continue
+			}
+			ri, rj := find(i), find(j)
+			if rj < ri {
+				ri, rj = rj, ri
+			}
+			parent[rj] = ri
+		}
+	}
+
+	// Roots have the lowest index of their group, i.e. the earliest shot
+	groups := make(map[int][]*seriesShot)
+	for i, shot := range shots {
+		root := find(i)
+		groups[root] = append(groups[root], shot)
+	}
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		best := group[0]
+		for _, shot := range group[1:] {
+			if betterShot(shot.img, best.img) {
+				best = shot
+			}
+		}
+		for _, shot := range group {
+			shot.img.Series = &SeriesInfo{ID: group[0].img.ID, Size: len(group), Representative: best.img.ID}
+		}
+	}
+}
+
+// betterShot reports whether a has a higher quality score than b, or an
+// equal one and a higher aesthetic score
+func betterShot(a, b *ImageMetadata) bool {
+	qa, qb := -1, -1
+	if a.Quality != nil {
+		qa = a.Quality.Score
+	}
+	if b.Quality != nil {
+		qb = b.Quality.Score
+	}
+	if qa != qb {
+		return qa > qb
+	}
+	var aa, ab float64
+	if a.Aesthetics != nil {
+		aa = a.Aesthetics.Score
+	}
+	if b.Aesthetics != nil {
+		ab = b.Aesthetics.Score
+	}
+	return aa > ab
+}
+
+// CollapseSeries keeps one image per series: its representative, or the
+// first of its images listed if the representative isn't
+func CollapseSeries(images []*ImageMetadata) []*ImageMetadata {
+	listed := make(map[string]bool)
+	for _, img := range images {
+		listed[img.ID] = true
+	}
+
+	shown := make(map[string]bool)
+	var collapsed []*ImageMetadata
+	for _, img := range images {
+		if s := img.Series; s != nil {
+			if shown[s.ID] || (listed[s.Representative] && img.ID != s.Representative) {
+				continue
+			}
+			shown[s.ID] = true
+		}
+		collapsed = append(collapsed, img)
+	}
+	return collapsed
+}
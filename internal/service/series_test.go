@@ -0,0 +1,103 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestFilenameSequence(t *testing.T) {
+	tests := []struct {
+		filename string
+		stem     string
+		n        int
+		ok       bool
+	}{
+		{"IMG_0231.jpg", "img_", 231, true},
+		{"Shoot-12 (2).png", "shoot-", 12, true},
+		{"cat.jpg", "", 0, false},
+		{"2024.jpg", "", 0, false},
+	}
+	for _, tt := range tests {
+		stem, n, ok := filenameSequence(tt.filename)
+		if stem != tt.stem || n != tt.n || ok != tt.ok {
+			t.Errorf("filenameSequence(%q) = %q, %d, %v", tt.filename, stem, n, ok)
+		}
+	}
+}
+
+func TestVisualHash(t *testing.T) {
+	// A left to right gradient
+	gradient := image.NewNRGBA(image.Rect(0, 0, 256, 128))
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 256; x++ {
+			gradient.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(x), uint8(x), 255})
+		}
+	}
+	if d := hashDistance(VisualHash(gradient), VisualHash(imaging.AdjustBrightness(gradient, 10))); d > similarHashDistance {
+		t.Errorf("expected a brighter copy to hash alike, got %d bits apart", d)
+	}
+	if d := hashDistance(VisualHash(gradient), VisualHash(imaging.FlipH(gradient))); d <= similarHashDistance {
+		t.Errorf("expected a mirrored image to hash differently, got %d bits apart", d)
+	}
+	if hashDistance("", "00ff00ff00ff00ff") != -1 {
+		t.Error("expected no distance without both hashes")
+	}
+}
+
+func TestDetectSeries(t *testing.T) {
+	images := []*ImageMetadata{
+		// A burst: captured a second apart
+		{ID: "burst-1", Type: "2D", CapturedAt: "2025-03-14 10:00:00", UploadedAt: "2025-03-20 09:00:00", Quality: &models.Quality{Score: 60}},
+		{ID: "burst-2", Type: "2D", CapturedAt: "2025-03-14 10:00:01", UploadedAt: "2025-03-20 09:00:00", Quality: &models.Quality{Score: 80}},
+		{ID: "burst-3", Type: "2D", CapturedAt: "2025-03-14 10:00:02", UploadedAt: "2025-03-20 09:00:00", Quality: &models.Quality{Score: 70}},
+		// A numbered sequence uploaded together
+		{ID: "seq-1", Type: "2D", OriginalFilename: "DSC_0101.jpg", UploadedAt: "2025-03-20 12:00:00", VisualHash: "ffffffffffff0000"},
+		{ID: "seq-2", Type: "2D", OriginalFilename: "DSC_0102.jpg", UploadedAt: "2025-03-20 12:00:05", VisualHash: "ffffffffffffffff"},
+		// Same stem, but the picture is clearly different
+		{ID: "other", Type: "2D", OriginalFilename: "DSC_0103.jpg", UploadedAt: "2025-03-20 12:00:06", VisualHash: "0000000000000000"},
+		// Near-identical shots with unrelated names
+		{ID: "same-1", Type: "2D", OriginalFilename: "a.jpg", UploadedAt: "2025-03-20 15:00:00", VisualHash: "0f0f0f0f0f0f0f0f"},
+		{ID: "same-2", Type: "2D", OriginalFilename: "b.jpg", UploadedAt: "2025-03-20 15:05:00", VisualHash: "0f0f0f0f0f0f0f0e"},
+		// Near-identical, but hours apart
+		{ID: "later", Type: "2D", UploadedAt: "2025-03-20 20:00:00", VisualHash: "0f0f0f0f0f0f0f0f"},
+		{ID: "model", Type: "3D", OriginalFilename: "DSC_0104.jpg", UploadedAt: "2025-03-20 12:00:07"},
+	}
+	DetectSeries(images)
+
+	want := map[string]SeriesInfo{
+		"burst-1": {ID: "burst-1", Size: 3, Representative: "burst-2"},
+		"burst-2": {ID: "burst-1", Size: 3, Representative: "burst-2"},
+		"burst-3": {ID: "burst-1", Size: 3, Representative: "burst-2"},
+		"seq-1":   {ID: "seq-1", Size: 2, Representative: "seq-1"},
+		"seq-2":   {ID: "seq-1", Size: 2, Representative: "seq-1"},
+		"same-1":  {ID: "same-1", Size: 2, Representative: "same-1"},
+		"same-2":  {ID: "same-1", Size: 2, Representative: "same-1"},
+	}
+	for _, img := range images {
+		expected, inSeries := want[img.ID]
+		switch {
+		case !inSeries && img.Series != nil:
+			t.Errorf("expected %s in no series, got %+v", img.ID, img.Series)
+		case inSeries && (img.Series == nil || *img.Series != expected):
+			t.Errorf("expected %s in %+v, got %+v", img.ID, expected, img.Series)
+		}
+	}
+
+	collapsed := CollapseSeries(images)
+	var ids []string
+	for _, img := range collapsed {
+		ids = append(ids, img.ID)
+	}
+	if len(ids) != 6 || ids[0] != "burst-2" || ids[1] != "seq-1" || ids[3] != "same-1" {
+		t.Errorf("expected one image per series, got %v", ids)
+	}
+
+	// Without the representative listed, the first listed image stands in
+	if collapsed := CollapseSeries([]*ImageMetadata{images[0], images[2]}); len(collapsed) != 1 || collapsed[0].ID != "burst-1" {
+		t.Errorf("expected burst-1 to stand in, got %d images", len(collapsed))
+	}
+}
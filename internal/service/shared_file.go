@@ -0,0 +1,137 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// sharedFile is the JSON file behind a side store (favorites, projects,
+// guest tokens and so on). Once shared through a coordinator, every
+// instance updates it read-modify-write under the store's coordinated lock,
+// reloading it first if another instance replaced it, and readers reload it
+// when it changed; unshared, the store's own mutex is all it takes.
+type sharedFile struct {
+	path  string
+	lock  *CoordinatedLock // nil unless shared
+	seen  os.FileInfo      // the file as last read or written, nil if missing
+	mutex sync.Mutex
+}
+
+func newSharedFile(path string) *sharedFile {
+	return &sharedFile{path: path}
+}
+
+// share locks updates through c
+func (f *sharedFile) share(c *Coordinator) {
+	if c != nil {
+		f.lock = c.Locker("store/" + filepath.Base(f.path))
+	}
+}
+
+// changed reports whether another instance replaced the file since this one
+// last read or wrote it. An unshared file never changes behind the store.
+func (f *sharedFile) changed() bool {
+	if f.lock == nil {
+		return false
+	}
+	info, err := os.Stat(f.path)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err != nil {
+		return f.seen != nil
+	}
+	return f.seen == nil || !os.SameFile(info, f.seen) ||
+		info.Size() != f.seen.Size() || !info.ModTime().Equal(f.seen.ModTime())
+}
+
+func (f *sharedFile) remember(info os.FileInfo) {
+	f.mutex.Lock()
+	f.seen = info
+	f.mutex.Unlock()
+}
+
+// read returns the file's content, nil if it doesn't exist
+func (f *sharedFile) read() ([]byte, error) {
+	file, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		f.remember(nil)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	f.remember(info)
+	return data, nil
+}
+
+// begin takes the coordinated lock for a read-modify-write, calling reload
+// first if another instance changed the file. The returned func releases
+// the lock.
+func (f *sharedFile) begin(reload func() error) (func(), error) {
+	if f.lock == nil {
+		return func() {}, nil
+	}
+	if err := f.lock.Lock(); err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", filepath.Base(f.path), err)
+	}
+	if f.changed() {
+		if err := reload(); err != nil {
+			f.lock.Unlock()
+			return nil, err
+		}
+	}
+	return func() { f.lock.Unlock() }, nil
+}
+
+// write replaces the file with data atomically. A shared file is only
+// replaced while its lock is still held.
+func (f *sharedFile) write(data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), "."+filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// Stat after closing: NFS sets the modification time when writes flush
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	if f.lock != nil {
+		if err := f.lock.Check(); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmpPath, f.path); err != nil {
+		return err
+	}
+	// The renamed file keeps the temp file's identity and modification time
+	f.remember(info)
+	return nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// SizeFilter restricts 3D objects by the longest side of their real-world
+// bounds, in meters; zero fields match everything
+type SizeFilter struct {
+	MinSize float64
+	MaxSize float64
+}
+
+// ParseSizeFilter parses lengths such as "50cm" or "1.2m"; empty strings
+// leave that end open
+func ParseSizeFilter(minSize, maxSize string) (SizeFilter, error) {
+	var f SizeFilter
+	for _, p := range []struct {
+		value  string
+		target *float64
+	}{{minSize, &f.MinSize}, {maxSize, &f.MaxSize}} {
+		if p.value == "" {
+			continue
+		}
+		length, err := models.ParseLength(p.value)
+		if err != nil {
+			return f, err
+		}
+		*p.target = length
+	}
+	return f, f.Validate()
+}
+
+// IsZero reports whether the filter matches everything
+func (f SizeFilter) IsZero() bool {
+	return f == SizeFilter{}
+}
+
+// Matches reports whether an image passes the filter. Images without known
+// bounds only pass an empty filter.
+func (f SizeFilter) Matches(img *ImageMetadata) bool {
+	if f.IsZero() {
+		return true
+	}
+	if img.Bounds == nil {
+		return false
+	}
+	longest := img.Bounds.LongestSide()
+	if longest < f.MinSize {
+		return false
+	}
+	return f.MaxSize <= 0 || longest <= f.MaxSize
+}
+
+// Validate checks that the filter bounds make sense
+func (f SizeFilter) Validate() error {
+	if f.MinSize < 0 || f.MaxSize < 0 {
+		return fmt.Errorf("size filters cannot be negative")
+	}
+	if f.MaxSize > 0 && f.MaxSize < f.MinSize {
+		return fmt.Errorf("maximum size is smaller than the minimum")
+	}
+	return nil
+}
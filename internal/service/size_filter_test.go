@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestSizeFilter_Matches(t *testing.T) {
+	props, err := ParseSizeFilter("", "50cm")
+	if err != nil {
+		t.Fatalf("ParseSizeFilter failed: %v", err)
+	}
+
+	cup := &ImageMetadata{Bounds: &models.Bounds{Width: 8, Height: 10, Depth: 8, Unit: "cm"}}
+	chair := &ImageMetadata{Bounds: &models.Bounds{Width: 0.45, Height: 0.9, Depth: 0.5, Unit: "m"}}
+	book := &ImageMetadata{Bounds: &models.Bounds{Width: 6, Height: 9, Depth: 1, Unit: "in"}}
+	cases := []struct {
+		name   string
+		img    *ImageMetadata
+		filter SizeFilter
+		want   bool
+	}{
+		{"cup under 50cm", cup, props, true},
+		{"book under 50cm", book, props, true},
+		{"chair over 50cm", chair, props, false},
+		{"unknown size", &ImageMetadata{}, props, false},
+		{"unknown size, no filter", &ImageMetadata{}, SizeFilter{}, true},
+		{"at least 1ft", book, SizeFilter{MinSize: 0.3048}, false},
+		{"between", chair, SizeFilter{MinSize: 0.5, MaxSize: 1}, true},
+	}
+	for _, tc := range cases {
+		if got := tc.filter.Matches(tc.img); got != tc.want {
+			t.Errorf("%s: Matches = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseSizeFilter_Invalid(t *testing.T) {
+	for _, bad := range [][2]string{{"big", ""}, {"", "-5cm"}, {"2m", "1m"}, {"10 parsecs", ""}} {
+		if _, err := ParseSizeFilter(bad[0], bad[1]); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// saliencySize is the longest side of the downscaled copy used to find the subject
+const saliencySize = 64
+
+// FindFocalPoint estimates the center of an image's subject. Each pixel is
+// scored by edge strength, saturation and contrast against the average color,
+// so detailed, colorful regions outweigh flat backgrounds; the focal point is
+// the score-weighted centroid.
+func FindFocalPoint(img image.Image) models.FocalPoint {
+	small := imaging.Fit(img, saliencySize, saliencySize, imaging.Box)
+	w, h := small.Bounds().Dx(), small.Bounds().Dy()
+	center := models.FocalPoint{X: 0.5, Y: 0.5, Source: models.FocalSourceSaliency}
+	if w < 3 || h < 3 {
+		return center
+	}
+
+	luma := make([]float64, w*h)
+	saturation := make([]float64, w*h)
+	var meanLuma float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := small.PixOffset(x, y)
+			r, g, b := float64(small.Pix[i]), float64(small.Pix[i+1]), float64(small.Pix[i+2])
+			luma[y*w+x] = 0.299*r + 0.587*g + 0.114*b
+			if hi := math.Max(r, math.Max(g, b)); hi > 0 {
+				saturation[y*w+x] = (hi - math.Min(r, math.Min(g, b))) / hi
+			}
+			meanLuma += luma[y*w+x]
+		}
+	}
+	meanLuma /= float64(w * h)
+
+	var sumW, sumX, sumY float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			edge := math.Abs(luma[i+1]-luma[i-1]) + math.Abs(luma[i+w]-luma[i-w])
+			score := edge + 128*saturation[i] + 0.5*math.Abs(luma[i]-meanLuma)
+			weight := score * score
+			sumW += weight
+			sumX += weight * (float64(x) + 0.5)
+			sumY += weight * (float64(y) + 0.5)
+		}
+	}
+	if sumW == 0 {
+		return center
+	}
+
+	return models.FocalPoint{
+		X:      sumX / sumW / float64(w),
+		Y:      sumY / sumW / float64(h),
+		Source: models.FocalSourceSaliency,
+	}
+}
+
+// SquareCrop cuts the largest square centered as closely as possible on the
+// focal point and scales it to size x size
+func SquareCrop(img image.Image, size int, focal models.FocalPoint) *image.NRGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	side := min(w, h)
+
+	x0 := clampInt(int(math.Round(focal.X*float64(w)))-side/2, 0, w-side)
+	y0 := clampInt(int(math.Round(focal.Y*float64(h)))-side/2, 0, h-side)
+
+	crop := imaging.Crop(img, image.Rect(bounds.Min.X+x0, bounds.Min.Y+y0, bounds.Min.X+x0+side, bounds.Min.Y+y0+side))
+	return imaging.Resize(crop, size, size, imaging.Lanczos)
+}
+
+func clampInt(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// offCenterSubject returns a wide gray canvas with a red square near its
+// right edge
+func offCenterSubject() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 100))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{200, 200, 200, 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(320, 30, 360, 70), image.NewUniform(color.NRGBA{220, 20, 20, 255}), image.Point{}, draw.Src)
+	return img
+}
+
+func TestFindFocalPoint(t *testing.T) {
+	focal := FindFocalPoint(offCenterSubject())
+	if focal.Source != models.FocalSourceSaliency {
+		t.Errorf("expected saliency source, got %q", focal.Source)
+	}
+	// The subject is centered at (0.85, 0.5)
+	if focal.X < 0.75 || focal.X > 0.95 || focal.Y < 0.4 || focal.Y > 0.6 {
+		t.Errorf("expected focal point near the subject, got %.3f,%.3f", focal.X, focal.Y)
+	}
+
+	flat := image.NewNRGBA(image.Rect(0, 0, 50, 50))
+	if focal := FindFocalPoint(flat); focal.X != 0.5 || focal.Y != 0.5 {
+		t.Errorf("expected center for a flat image, got %.3f,%.3f", focal.X, focal.Y)
+	}
+}
+
+func TestSquareCrop(t *testing.T) {
+	img := offCenterSubject()
+
+	crop := SquareCrop(img, 50, models.FocalPoint{X: 0.85, Y: 0.5})
+	if crop.Bounds().Dx() != 50 || crop.Bounds().Dy() != 50 {
+		t.Fatalf("expected 50x50 crop, got %v", crop.Bounds())
+	}
+	// The crop is clamped to the right edge and keeps the subject
+	if c := crop.NRGBAAt(20, 25); c.R < 150 || c.G > 100 {
+		t.Errorf("expected subject in crop, got %v", c)
+	}
+
+	centered := SquareCrop(img, 50, models.FocalPoint{X: 0.5, Y: 0.5})
+	if c := centered.NRGBAAt(20, 25); c.R != c.G {
+		t.Errorf("expected a centered crop to miss the subject, got %v", c)
+	}
+}
+
+func TestGenerateSquareThumbnail(t *testing.T) {
+	storage := NewStorageService(t.TempDir())
+	imagePath := filepath.Join(t.TempDir(), "wide.png")
+	if err := imaging.Save(offCenterSubject(), imagePath); err != nil {
+		t.Fatal(err)
+	}
+
+	squarePath, focal, err := storage.GenerateSquareThumbnail(imagePath, nil)
+	if err != nil {
+		t.Fatalf("GenerateSquareThumbnail failed: %v", err)
+	}
+	if filepath.Base(squarePath) != "wide_square.jpg" {
+		t.Errorf("unexpected square thumbnail path %s", squarePath)
+	}
+	if focal.Source != models.FocalSourceSaliency {
+		t.Errorf("expected saliency focal point, got %+v", focal)
+	}
+
+	manual := &models.FocalPoint{X: 0.1, Y: 0.5, Source: models.FocalSourceManual}
+	if _, focal, err = storage.GenerateSquareThumbnail(imagePath, manual); err != nil || focal != *manual {
+		t.Errorf("expected manual focal point to be used, got %+v (%v)", focal, err)
+	}
+
+	thumb, err := imaging.Open(squarePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumb.Bounds().Dx() != ThumbnailSize || thumb.Bounds().Dy() != ThumbnailSize {
+		t.Errorf("expected %dx%d square thumbnail, got %v", ThumbnailSize, ThumbnailSize, thumb.Bounds())
+	}
+}
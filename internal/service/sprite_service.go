@@ -0,0 +1,393 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+const (
+	// SpriteCellSize is the width and height of each thumbnail in a sprite sheet
+	SpriteCellSize = 128
+	// SpriteColumns is the number of thumbnails per sprite sheet row
+	SpriteColumns = 10
+)
+
+// categoryPathRegex matches category paths produced by GetCategoryPath
+var categoryPathRegex = regexp.MustCompile(`^[a-z0-9-]+(/[a-z0-9-]+)?$`)
+
+// SpriteManifest describes a category sprite sheet: where each image's
+// thumbnail sits in the packed image
+type SpriteManifest struct {
+	Category  string       `json:"category"`
+	CellSize  int          `json:"cell_size"`
+	Columns   int          `json:"columns"`
+	Width     int          `json:"width"`
+	Height    int          `json:"height"`
+	Version   int64        `json:"version"`
+	ImageURL  string       `json:"image_url"`
+	Items     []SpriteItem `json:"items"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// SpriteItem is the position of one thumbnail in a sprite sheet
+type SpriteItem struct {
+	ID string `json:"id"`
+	X  int    `json:"x"`
+	Y  int    `json:"y"`
+	W  int    `json:"w"`
+	H  int    `json:"h"`
+}
+
+// SpriteService maintains per-category sprite sheets under <dataDir>/sprites.
+// Sheets are built on first request and then extended as images are indexed;
+// a sheet that no longer matches the index (e.g. after a delete) is rebuilt.
+type SpriteService struct {
+	indexService   *IndexService
+	storageService *StorageService
+	spriteDir      string
+	logger         *logrus.Logger
+	mutex          sync.Mutex
+}
+
+// NewSpriteService creates a sprite service storing sheets under dataDir/sprites
+func NewSpriteService(index *IndexService, storage *StorageService, dataDir string, logger *logrus.Logger) *SpriteService {
+	return &SpriteService{
+		indexService:   index,
+		storageService: storage,
+		spriteDir:      filepath.Join(dataDir, "sprites"),
+		logger:         logger,
+	}
+}
+
+// ValidCategoryPath reports whether category is a well-formed category path
+func ValidCategoryPath(category string) bool {
+	return categoryPathRegex.MatchString(category)
+}
+
+// Get returns the sprite manifest for a category, building or rebuilding the
+// sheet if it is missing or out of date
+func (s *SpriteService) Get(category string) (*SpriteManifest, error) {
+	if !ValidCategoryPath(category) {
+		return nil, fmt.Errorf("invalid category: %s", category)
+	}
+
+	images, err := s.categoryImages(category)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	manifest, err := s.loadManifest(category)
+	if err == nil && manifestMatches(manifest, images) {
+		return manifest, nil
+	}
+
+	return s.rebuild(category, images)
+}
+
+// ImagePath returns the path of a category's sprite sheet image
+func (s *SpriteService) ImagePath(category string) (string, error) {
+	if !ValidCategoryPath(category) {
+		return "", fmt.Errorf("invalid category: %s", category)
+	}
+	return filepath.Join(s.spriteDir, filepath.FromSlash(category), "sprite.jpg"), nil
+}
+
+// HandleIndexed appends a newly indexed image to the existing sheets of its
+// category and parent category. Sheets that don't exist yet are left to be
+// built on first request.
+func (s *SpriteService) HandleIndexed(img *models.Image) {
+	categories := []string{img.Category}
+	if primary, _, ok := strings.Cut(img.Category, "/"); ok {
+		categories = append(categories, primary)
+	}
+
+	metadata, err := s.indexService.GetImageByID(img.ID)
+	if err != nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, category := range categories {
+		if !ValidCategoryPath(category) {
+			continue
+		}
+		manifest, err := s.loadManifest(category)
+		if err != nil {
+			continue
+		}
+		if err := s.appendTile(manifest, metadata); err != nil {
+			s.logger.Warnf("Failed to add %s to %s sprite: %v", img.ID, category, err)
+		}
+	}
+}
+
+// HandleUpdated drops the sheets of an image's categories when its focal
+// point changed, so they are rebuilt with the new crop on next request
+func (s *SpriteService) HandleUpdated(img *ImageMetadata, update ImageUpdate) {
+	if update.FocalPoint == nil {
+		return
+	}
+
+	categories := []string{img.Category}
+	if primary, _, ok := strings.Cut(img.Category, "/"); ok {
+		categories = append(categories, primary)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, category := range categories {
+		if !ValidCategoryPath(category) {
+			continue
+		}
+		if err := os.Remove(s.manifestPath(category)); err != nil && !os.IsNotExist(err) {
+			s.logger.Warnf("Failed to invalidate %s sprite: %v", category, err)
+		}
+	}
+}
+
+// Forget removes the sheets that show any of the given images, wherever
+// they are filed, so none of their pixels stay on disk. The sheets are
+// rebuilt on next request. It returns how many sheets were removed.
+func (s *SpriteService) Forget(imageIDs map[string]bool) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	removed := 0
+	err := filepath.WalkDir(s.spriteDir, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || d.IsDir() || d.Name() != "sprite.json" {
+			return err
+		}
+		category, _ := filepath.Rel(s.spriteDir, filepath.Dir(path))
+		manifest, err := s.loadManifest(filepath.ToSlash(category))
+		if err != nil {
+			return err
+		}
+		for _, item := range manifest.Items {
+			if !imageIDs[item.ID] {
+				continue
+			}
+			// The manifest goes last, so a failure leaves a sheet that
+			// is still found and rebuilt
+			imagePath := filepath.Join(filepath.Dir(path), "sprite.jpg")
+			if err := os.Remove(imagePath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			removed++
+			break
+		}
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to remove sprite sheets: %w", err)
+	}
+	return removed, nil
+}
+
+// categoryImages returns the indexed images filed under a category that have a thumbnail
+func (s *SpriteService) categoryImages(category string) ([]*ImageMetadata, error) {
+	all, err := s.indexService.GetAllImages()
+	if err != nil {
+		return nil, err
+	}
+
+	var images []*ImageMetadata
+	for _, img := range all {
+		if img.InCategory(category) && img.PreviewThumbnail() != "" {
+			images = append(images, img)
+		}
+	}
+	return images, nil
+}
+
+// rebuild renders a category's sheet from scratch. Caller must hold the mutex.
+func (s *SpriteService) rebuild(category string, images []*ImageMetadata) (*SpriteManifest, error) {
+	manifest := &SpriteManifest{
+		Category: category,
+		CellSize: SpriteCellSize,
+		Columns:  SpriteColumns,
+		Items:    []SpriteItem{},
+	}
+
+	var tiles []image.Image
+	for _, img := range images {
+		tile, err := s.loadTile(img)
+		if err != nil {
+			s.logger.Warnf("Skipping %s in %s sprite: %v", img.ID, category, err)
+			continue
+		}
+		tiles = append(tiles, tile)
+		manifest.Items = append(manifest.Items, cellFor(img.ID, len(manifest.Items)))
+	}
+
+	sheet := imaging.New(sheetWidth(len(tiles)), sheetHeight(len(tiles)), color.White)
+	for i, tile := range tiles {
+		item := manifest.Items[i]
+		sheet = imaging.Paste(sheet, tile, image.Pt(item.X, item.Y))
+	}
+
+	if err := s.save(manifest, sheet); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// appendTile adds one image to an existing sheet. Caller must hold the mutex.
+func (s *SpriteService) appendTile(manifest *SpriteManifest, img *ImageMetadata) error {
+	for _, item := range manifest.Items {
+		if item.ID == img.ID {
+			return nil
+		}
+	}
+
+	tile, err := s.loadTile(img)
+	if err != nil {
+		return err
+	}
+
+	imagePath, _ := s.ImagePath(manifest.Category)
+	current, err := imaging.Open(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to open sprite sheet: %w", err)
+	}
+
+	count := len(manifest.Items) + 1
+	sheet := imaging.New(sheetWidth(count), sheetHeight(count), color.White)
+	sheet = imaging.Paste(sheet, current, image.Pt(0, 0))
+
+	item := cellFor(img.ID, len(manifest.Items))
+	sheet = imaging.Paste(sheet, tile, image.Pt(item.X, item.Y))
+	manifest.Items = append(manifest.Items, item)
+
+	return s.save(manifest, sheet)
+}
+
+// loadTile reads an image's thumbnail and crops it to a sprite cell. The
+// subject-centered square thumbnail is preferred where there is one.
+func (s *SpriteService) loadTile(img *ImageMetadata) (image.Image, error) {
+	thumb := img.PreviewThumbnail()
+	if img.SquareThumbnail != "" {
+		thumb = img.SquareThumbnail
+	}
+	path, err := s.storageService.StoredPath(thumb)
+	if err != nil {
+		return nil, err
+	}
+	// Sheets mix many images, so tiles are normalized to sRGB
+	src, err := openSRGB(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open thumbnail: %w", err)
+	}
+	return imaging.Fill(src, SpriteCellSize, SpriteCellSize, imaging.Center, imaging.Lanczos), nil
+}
+
+// save writes the sheet image and its manifest
+func (s *SpriteService) save(manifest *SpriteManifest, sheet image.Image) error {
+	imagePath, _ := s.ImagePath(manifest.Category)
+	if err := os.MkdirAll(filepath.Dir(imagePath), 0755); err != nil {
+		return fmt.Errorf("failed to create sprite directory: %w", err)
+	}
+	if err := imaging.Save(sheet, imagePath, imaging.JPEGQuality(85)); err != nil {
+		return fmt.Errorf("failed to save sprite sheet: %w", err)
+	}
+
+	manifest.Width = sheet.Bounds().Dx()
+	manifest.Height = sheet.Bounds().Dy()
+	manifest.UpdatedAt = time.Now()
+	manifest.Version = manifest.UpdatedAt.UnixNano()
+	manifest.ImageURL = fmt.Sprintf("/api/v1/categories/%s/sprite.jpg?v=%d", manifest.Category, manifest.Version)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sprite manifest: %w", err)
+	}
+	return os.WriteFile(s.manifestPath(manifest.Category), data, 0644)
+}
+
+func (s *SpriteService) manifestPath(category string) string {
+	return filepath.Join(s.spriteDir, filepath.FromSlash(category), "sprite.json")
+}
+
+func (s *SpriteService) loadManifest(category string) (*SpriteManifest, error) {
+	data, err := os.ReadFile(s.manifestPath(category))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest SpriteManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse sprite manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// manifestMatches reports whether a manifest holds exactly the given images
+func manifestMatches(manifest *SpriteManifest, images []*ImageMetadata) bool {
+	if len(manifest.Items) != len(images) {
+		return false
+	}
+	ids := make(map[string]bool, len(images))
+	for _, img := range images {
+		ids[img.ID] = true
+	}
+	for _, item := range manifest.Items {
+		if !ids[item.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// cellFor returns the sprite position of the n-th tile
+func cellFor(id string, n int) SpriteItem {
+	return SpriteItem{
+		ID: id,
+		X:  (n % SpriteColumns) * SpriteCellSize,
+		Y:  (n / SpriteColumns) * SpriteCellSize,
+		W:  SpriteCellSize,
+		H:  SpriteCellSize,
+	}
+}
+
+func sheetWidth(count int) int {
+	cols := count
+	if cols > SpriteColumns {
+		cols = SpriteColumns
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	return cols * SpriteCellSize
+}
+
+func sheetHeight(count int) int {
+	rows := (count + SpriteColumns - 1) / SpriteColumns
+	if rows < 1 {
+		rows = 1
+	}
+	return rows * SpriteCellSize
+}
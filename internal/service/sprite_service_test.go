@@ -0,0 +1,101 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// addSpriteTestImage writes a thumbnail and indexes an image in category
+func addSpriteTestImage(t *testing.T, dataDir string, index *IndexService, id, category string) *models.Image {
+	t.Helper()
+	thumbRel := filepath.ToSlash(filepath.Join("categories", category, id+"_thumb.jpg"))
+	thumbPath := filepath.Join(dataDir, thumbRel)
+	if err := os.MkdirAll(filepath.Dir(thumbPath), 0755); err != nil {
+		t.Fatalf("failed to create category dir: %v", err)
+	}
+	if err := imaging.Save(imaging.New(40, 30, color.Black), thumbPath); err != nil {
+		t.Fatalf("failed to write thumbnail: %v", err)
+	}
+
+	img := &models.Image{ID: id, Title: id, Artist: "Alice", Category: category, Type: models.ImageType2D, UploadedAt: time.Now(), ThumbnailPath: thumbRel}
+	if err := index.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+	return img
+}
+
+func TestSpriteService_BuildAndExtend(t *testing.T) {
+	dataDir := t.TempDir()
+	index := NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	svc := NewSpriteService(index, NewStorageService(dataDir), dataDir, logrus.New())
+
+	addSpriteTestImage(t, dataDir, index, "a", "animals")
+	addSpriteTestImage(t, dataDir, index, "b", "animals/cats")
+	addSpriteTestImage(t, dataDir, index, "c", "landscapes")
+
+	manifest, err := svc.Get("animals")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(manifest.Items) != 2 {
+		t.Fatalf("expected 2 items including the sub category, got %d", len(manifest.Items))
+	}
+	if manifest.Items[1].X != SpriteCellSize || manifest.Items[1].Y != 0 {
+		t.Errorf("unexpected offset for second item: %+v", manifest.Items[1])
+	}
+
+	// A newly indexed image is appended without a rebuild
+	svc.HandleIndexed(addSpriteTestImage(t, dataDir, index, "d", "animals/cats"))
+	extended, err := svc.loadManifest("animals")
+	if err != nil {
+		t.Fatalf("loadManifest failed: %v", err)
+	}
+	if len(extended.Items) != 3 || extended.Items[2].ID != "d" {
+		t.Fatalf("expected d appended, got %+v", extended.Items)
+	}
+
+	imagePath, _ := svc.ImagePath("animals")
+	sheet, err := imaging.Open(imagePath)
+	if err != nil {
+		t.Fatalf("failed to open sheet: %v", err)
+	}
+	if sheet.Bounds() != image.Rect(0, 0, 3*SpriteCellSize, SpriteCellSize) {
+		t.Errorf("unexpected sheet size %v", sheet.Bounds())
+	}
+
+	// Deleting an image makes the manifest stale, so the next Get rebuilds
+	if _, err := index.DeleteImage("a", "test"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+	rebuilt, err := svc.Get("animals")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(rebuilt.Items) != 2 || rebuilt.Items[0].ID == "a" {
+		t.Errorf("expected rebuilt sheet without a, got %+v", rebuilt.Items)
+	}
+}
+
+func TestValidCategoryPath(t *testing.T) {
+	for _, category := range []string{"animals", "animals/big-cats"} {
+		if !ValidCategoryPath(category) {
+			t.Errorf("expected %q to be valid", category)
+		}
+	}
+	for _, category := range []string{"", "../etc", "animals/../x", "a/b/c", "Animals"} {
+		if ValidCategoryPath(category) {
+			t.Errorf("expected %q to be invalid", category)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// StatusStore holds the in-flight and recently finished processing status of
+// uploads. Finished entries are evicted after a TTL and the store is bounded
+// in size; it can be persisted to a JSON snapshot so statuses survive restarts.
+type StatusStore struct {
+	entries      map[string]*statusEntry
+	mutex        sync.RWMutex
+	ttl          time.Duration
+	maxEntries   int
+	snapshotPath string
+	saveMutex    sync.Mutex // one Save at a time: they share the temp file
+}
+
+type statusEntry struct {
+	Image     *models.Image `json:"image"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// NewStatusStore creates a status store. A zero ttl or maxEntries disables
+// that limit; an empty snapshotPath keeps the store in memory only.
+func NewStatusStore(snapshotPath string, ttl time.Duration, maxEntries int) *StatusStore {
+	return &StatusStore{
+		entries:      make(map[string]*statusEntry),
+		ttl:          ttl,
+		maxEntries:   maxEntries,
+		snapshotPath: snapshotPath,
+	}
+}
+
+// Get returns a copy of the status for an image
+func (s *StatusStore) Get(imageID string) (*models.Image, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entry, ok := s.entries[imageID]
+	if !ok {
+		return nil, false
+	}
+	return cloneStatus(entry.Image), true
+}
+
+// Set stores a copy of the status for an image, evicting old entries if the
+// store is full
+func (s *StatusStore) Set(img *models.Image) {
+	img = cloneStatus(img)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries[img.ID] = &statusEntry{Image: img, UpdatedAt: time.Now()}
+	s.enforceLimit()
+}
+
+// Update applies fn to the stored status of an image; returns false if unknown
+func (s *StatusStore) Update(imageID string, fn func(img *models.Image)) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[imageID]
+	if !ok {
+		return false
+	}
+	fn(entry.Image)
+	entry.UpdatedAt = time.Now()
+	return true
+}
+
+// Delete removes the status for an image
+func (s *StatusStore) Delete(imageID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, imageID)
+}
+
+// Len returns the number of tracked statuses
+func (s *StatusStore) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.entries)
+}
+
+// CountByStatus returns the number of tracked images per status value
+func (s *StatusStore) CountByStatus() map[string]int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	counts := make(map[string]int)
+	for _, entry := range s.entries {
+		counts[entry.Image.Status]++
+	}
+	return counts
+}
+
+// EvictExpired removes finished entries older than the TTL and returns how
+// many were removed. In-flight entries are never evicted.
+func (s *StatusStore) EvictExpired() int {
+	if s.ttl <= 0 {
+		return 0
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cutoff := time.Now().Add(-s.ttl)
+	evicted := 0
+	for id, entry := range s.entries {
+		if isFinalStatus(entry.Image.Status) && entry.UpdatedAt.Before(cutoff) {
+			delete(s.entries, id)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// enforceLimit drops the oldest finished entries until the store fits
+// maxEntries. Caller must hold the write lock.
+func (s *StatusStore) enforceLimit() {
+	if s.maxEntries <= 0 || len(s.entries) <= s.maxEntries {
+		return
+	}
+
+	var finished []string
+	for id, entry := range s.entries {
+		if isFinalStatus(entry.Image.Status) {
+			finished = append(finished, id)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return s.entries[finished[i]].UpdatedAt.Before(s.entries[finished[j]].UpdatedAt)
+	})
+
+	for _, id := range finished {
+		if len(s.entries) <= s.maxEntries {
+			break
+		}
+		delete(s.entries, id)
+	}
+}
+
+// Load restores statuses from the snapshot file, if one exists
+func (s *StatusStore) Load() error {
+	if s.snapshotPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.snapshotPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read status snapshot: %w", err)
+	}
+
+	var entries map[string]*statusEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse status snapshot: %w", err)
+	}
+
+	// Jobs don't survive a restart, so uploads still in flight when the
+	// snapshot was taken will never finish
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for id, entry := range entries {
+		if entry == nil || entry.Image == nil {
+			continue
+		}
+		if !isFinalStatus(entry.Image.Status) {
+			entry.Image.Status = StatusInterrupted
+			entry.Image.StatusReason = interruptedReason
+			entry.UpdatedAt = now
+		}
+		s.entries[id] = entry
+	}
+	s.enforceLimit()
+	return nil
+}
+
+// Save writes the current statuses to the snapshot file atomically
+func (s *StatusStore) Save() error {
+	if s.snapshotPath == "" {
+		return nil
+	}
+
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
+
+	s.mutex.RLock()
+	data, err := json.Marshal(s.entries)
+	s.mutex.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode status snapshot: %w", err)
+	}
+
+	tmpPath := s.snapshotPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write status snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.snapshotPath); err != nil {
+		return fmt.Errorf("failed to replace status snapshot: %w", err)
+	}
+	return nil
+}
+
+// Run evicts expired entries and saves a snapshot every interval until ctx is done
+func (s *StatusStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.EvictExpired()
+			s.Save()
+		}
+	}
+}
+
+// cloneStatus deep-copies a status through JSON, the form snapshots keep it
+// in, so no slice, map or pointer is shared with the caller
+func cloneStatus(img *models.Image) *models.Image {
+	clone := &models.Image{}
+	data, err := json.Marshal(img)
+	if err == nil {
+		err = json.Unmarshal(data, clone)
+	}
+	if err != nil {
+		// Can't happen for an Image; a shallow copy is the next best
+		shallow := *img
+		return &shallow
+	}
+	return clone
+}
+
+// StatusInterrupted is the status of an upload that was still processing when
+// the server stopped. Its job is gone, so it has to be uploaded again.
+const StatusInterrupted = "interrupted"
+
+const interruptedReason = "the server restarted before processing finished; upload the image again"
+
+// isFinalStatus reports whether processing has finished for a status value
+func isFinalStatus(status string) bool {
+	return status == "completed" || status == "error" || status == "cancelled" || status == StatusInterrupted
+}
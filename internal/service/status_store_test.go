@@ -0,0 +1,196 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestStatusStore_SetGet(t *testing.T) {
+	store := NewStatusStore("", 0, 0)
+	store.Set(&models.Image{ID: "img-001", Status: "processing"})
+
+	img, ok := store.Get("img-001")
+	if !ok {
+		t.Fatal("expected status to be found")
+	}
+	if img.Status != "processing" {
+		t.Errorf("expected status processing, got %s", img.Status)
+	}
+
+	// Returned value is a copy
+	img.Status = "mutated"
+	again, _ := store.Get("img-001")
+	if again.Status != "processing" {
+		t.Error("Get should return a copy, not the stored pointer")
+	}
+
+	if _, ok := store.Get("missing"); ok {
+		t.Error("expected missing status not to be found")
+	}
+}
+
+func TestStatusStore_CopiesDeeply(t *testing.T) {
+	store := NewStatusStore("", 0, 0)
+	stored := &models.Image{ID: "img-001", Status: "completed", ManualTags: []string{"cat"}, Views: map[string]string{"front": "a.png"}}
+	store.Set(stored)
+	stored.ManualTags[0] = "dog"
+	stored.Views["front"] = "b.png"
+
+	img, _ := store.Get("img-001")
+	if img.ManualTags[0] != "cat" || img.Views["front"] != "a.png" {
+		t.Errorf("Set should copy the tags and views, got %v %v", img.ManualTags, img.Views)
+	}
+	img.ManualTags[0] = "bird"
+	if again, _ := store.Get("img-001"); again.ManualTags[0] != "cat" {
+		t.Error("Get should copy the tags")
+	}
+}
+
+func TestStatusStore_Update(t *testing.T) {
+	store := NewStatusStore("", 0, 0)
+	store.Set(&models.Image{ID: "img-001", Status: "processing"})
+
+	if !store.Update("img-001", func(img *models.Image) { img.Status = "completed" }) {
+		t.Fatal("expected Update to find the entry")
+	}
+	img, _ := store.Get("img-001")
+	if img.Status != "completed" {
+		t.Errorf("expected status completed, got %s", img.Status)
+	}
+
+	if store.Update("missing", func(img *models.Image) {}) {
+		t.Error("expected Update on missing entry to return false")
+	}
+}
+
+func TestStatusStore_EvictExpired(t *testing.T) {
+	store := NewStatusStore("", time.Minute, 0)
+	store.Set(&models.Image{ID: "done", Status: "completed"})
+	store.Set(&models.Image{ID: "busy", Status: "processing"})
+
+	// Age both entries past the TTL
+	for _, entry := range store.entries {
+		entry.UpdatedAt = time.Now().Add(-time.Hour)
+	}
+
+	if evicted := store.EvictExpired(); evicted != 1 {
+		t.Errorf("expected 1 eviction, got %d", evicted)
+	}
+	if _, ok := store.Get("done"); ok {
+		t.Error("completed entry should have been evicted")
+	}
+	if _, ok := store.Get("busy"); !ok {
+		t.Error("in-flight entry should never be evicted")
+	}
+}
+
+func TestStatusStore_MaxEntries(t *testing.T) {
+	store := NewStatusStore("", 0, 3)
+	for i := 0; i < 5; i++ {
+		store.Set(&models.Image{ID: fmt.Sprintf("img-%d", i), Status: "completed"})
+		time.Sleep(time.Millisecond)
+	}
+
+	if store.Len() != 3 {
+		t.Errorf("expected 3 entries, got %d", store.Len())
+	}
+	if _, ok := store.Get("img-0"); ok {
+		t.Error("oldest entry should have been evicted")
+	}
+	if _, ok := store.Get("img-4"); !ok {
+		t.Error("newest entry should be kept")
+	}
+}
+
+func TestStatusStore_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+
+	store := NewStatusStore(path, 0, 0)
+	store.Set(&models.Image{ID: "img-001", Title: "Sunset", Status: "completed"})
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	restored := NewStatusStore(path, 0, 0)
+	if err := restored.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	img, ok := restored.Get("img-001")
+	if !ok {
+		t.Fatal("expected status to survive save/load")
+	}
+	if img.Title != "Sunset" {
+		t.Errorf("expected title Sunset, got %s", img.Title)
+	}
+}
+
+func TestStatusStore_ConcurrentSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	store := NewStatusStore(path, 0, 0)
+	store.Set(&models.Image{ID: "img-001", Status: "completed"})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- store.Save()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Save failed: %v", err)
+		}
+	}
+}
+
+func TestStatusStore_LoadMissingSnapshot(t *testing.T) {
+	store := NewStatusStore(filepath.Join(t.TempDir(), "missing.json"), 0, 0)
+	if err := store.Load(); err != nil {
+		t.Errorf("Load of missing snapshot should not fail: %v", err)
+	}
+}
+
+func TestStatusStore_LoadInterruptsUnfinished(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+
+	store := NewStatusStore(path, time.Hour, 0)
+	store.Set(&models.Image{ID: "img-001", Status: "processing"})
+	store.Set(&models.Image{ID: "img-002", Status: "pending"})
+	store.Set(&models.Image{ID: "img-003", Status: "completed"})
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	restored := NewStatusStore(path, time.Hour, 0)
+	if err := restored.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for _, id := range []string{"img-001", "img-002"} {
+		img, _ := restored.Get(id)
+		if img.Status != StatusInterrupted || img.StatusReason == "" {
+			t.Errorf("expected %s to be interrupted with a reason, got %q %q", id, img.Status, img.StatusReason)
+		}
+	}
+	if img, _ := restored.Get("img-003"); img.Status != "completed" || img.StatusReason != "" {
+		t.Errorf("expected the finished upload to be kept as is, got %+v", img)
+	}
+
+	// Interrupted entries expire like finished ones
+	restored.mutex.Lock()
+	for _, entry := range restored.entries {
+		entry.UpdatedAt = time.Now().Add(-2 * time.Hour)
+	}
+	restored.mutex.Unlock()
+	if evicted := restored.EvictExpired(); evicted != 3 {
+		t.Errorf("expected all 3 entries to expire, got %d", evicted)
+	}
+}
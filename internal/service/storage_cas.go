@@ -0,0 +1,163 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// casFolder is the folder under categories the cas layout stores files in
+const casFolder = "cas"
+
+// IsContentAddressed reports whether a data-relative path was stored by the
+// cas layout. Such files may be shared by several images.
+func IsContentAddressed(relPath string) bool {
+	return contentKey(relPath) != ""
+}
+
+// contentKey returns the "categories/cas/<xx>/<hash>" prefix a
+// content-addressed file shares with its thumbnails and renditions (or a 3D
+// object with the files in its folder); empty for other paths
+func contentKey(relPath string) string {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	if len(parts) < 4 || parts[0] != "categories" || parts[1] != casFolder {
+		return ""
+	}
+	hash := parts[3]
+	if i := strings.IndexAny(hash, "._"); i >= 0 {
+		hash = hash[:i]
+	}
+	return strings.Join([]string{parts[0], parts[1], parts[2], hash}, "/")
+}
+
+// imageSquareThumbnailPath returns the square thumbnail an image's focal
+// point is applied to. Identical uploads under the cas layout share the
+// content's square thumbnail, so each one gets its own copy, named after
+// the image, once its focal point is set.
+func imageSquareThumbnailPath(img *ImageMetadata) string {
+	if !IsContentAddressed(img.FilePath) {
+		return squareThumbnailPath(img.FilePath)
+	}
+	ext := filepath.Ext(img.FilePath)
+	return img.FilePath[:len(img.FilePath)-len(ext)] + "_" + img.ID + "_square.jpg"
+}
+
+// ownsSquareThumbnail reports whether an image has a square thumbnail of its
+// own next to shared content
+func ownsSquareThumbnail(img *ImageMetadata) bool {
+	return img.SquareThumbnail != "" && img.SquareThumbnail != squareThumbnailPath(img.FilePath)
+}
+
+// hashObjectDir returns a hex SHA-256 over the names and contents of the
+// uploaded files of a 3D object folder. Thumbnails and the turntable are
+// derived from the uploads and left out.
+func hashObjectDir(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		if strings.Contains(name, "_thumb") || name == TurntableFilename {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(rel), sum)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// casDir returns the absolute folder content with the given hash is stored in
+func (s *StorageService) casDir(hash string) string {
+	return filepath.Join(s.dataDir, "categories", casFolder, hash[:2])
+}
+
+// moveToCAS stores a 2D image and its thumbnails under the hash of the
+// image's content. When identical content is stored already, the temp files
+// are dropped and the stored ones reused.
+func (s *StorageService) moveToCAS(tempPath string) (string, string, error) {
+	hash, err := hashFile(tempPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to hash image: %w", err)
+	}
+
+	dir := s.casDir(hash)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create content directory: %w", err)
+	}
+
+	newPath := filepath.Join(dir, hash+filepath.Ext(tempPath))
+	newThumbPath := filepath.Join(dir, hash+"_thumb.jpg")
+	moves := []struct {
+		src, dst string
+		optional bool
+	}{
+		{tempPath, newPath, false},
+		{s.getThumbnailPath(tempPath), newThumbPath, false},
+		{s.getSquareThumbnailPath(tempPath), s.getSquareThumbnailPath(newPath), true},
+	}
+	for _, m := range moves {
+		if _, err := os.Stat(m.dst); err == nil {
+			os.Remove(m.src)
+			continue
+		}
+		if _, err := os.Stat(m.src); os.IsNotExist(err) && m.optional {
+			continue
+		}
+		if err := moveFile(m.src, m.dst); err != nil {
+			return "", "", fmt.Errorf("failed to store %s: %w", filepath.Base(m.src), err)
+		}
+	}
+
+	relPath, _ := filepath.Rel(s.dataDir, newPath)
+	relThumbPath, _ := filepath.Rel(s.dataDir, newThumbPath)
+
+	return relPath, relThumbPath, nil
+}
+
+// move3DToCAS stores a 3D object folder under the hash of its uploaded files
+// and returns the absolute folder. When an identical object is stored
+// already, the temp folder is dropped and the stored one reused.
+func (s *StorageService) move3DToCAS(imageID string) (string, error) {
+	tempObjectDir := filepath.Join(s.tempDir, imageID)
+	hash, err := hashObjectDir(tempObjectDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash object directory: %w", err)
+	}
+
+	dir := s.casDir(hash)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create content directory: %w", err)
+	}
+
+	objectDir := filepath.Join(dir, hash)
+	if _, err := os.Stat(objectDir); err == nil {
+		os.RemoveAll(tempObjectDir)
+		return objectDir, nil
+	}
+	if err := moveDir(tempObjectDir, objectDir); err != nil {
+		// Another worker may have stored the same object meanwhile
+		if _, statErr := os.Stat(objectDir); statErr == nil {
+			os.RemoveAll(tempObjectDir)
+			return objectDir, nil
+		}
+		return "", fmt.Errorf("failed to move object directory: %w", err)
+	}
+
+	return objectDir, nil
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func newCASStorage(t *testing.T) *StorageService {
+	t.Helper()
+	svc := NewStorageService(t.TempDir())
+	if err := svc.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if err := svc.SetLayout(LayoutCAS); err != nil {
+		t.Fatalf("SetLayout failed: %v", err)
+	}
+	return svc
+}
+
+// stageImage writes a 2D image and its thumbnail to temp
+func stageImage(t *testing.T, svc *StorageService, imageID, content string) string {
+	t.Helper()
+	tempPath := filepath.Join(svc.tempDir, imageID+".png")
+	if err := os.WriteFile(tempPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := os.WriteFile(svc.getThumbnailPath(tempPath), []byte("thumb of "+content), 0644); err != nil {
+		t.Fatalf("failed to create thumbnail: %v", err)
+	}
+	return tempPath
+}
+
+func TestMoveToCategory_CASDeduplicates(t *testing.T) {
+	svc := newCASStorage(t)
+
+	first, firstThumb, err := svc.MoveToCategory("img-1", stageImage(t, svc, "img-1", "same"), Placement{Category: "animals"})
+	if err != nil {
+		t.Fatalf("MoveToCategory failed: %v", err)
+	}
+	tempPath := stageImage(t, svc, "img-2", "same")
+	second, secondThumb, err := svc.MoveToCategory("img-2", tempPath, Placement{Category: "landscape"})
+	if err != nil {
+		t.Fatalf("MoveToCategory failed: %v", err)
+	}
+	other, _, err := svc.MoveToCategory("img-3", stageImage(t, svc, "img-3", "different"), Placement{Category: "animals"})
+	if err != nil {
+		t.Fatalf("MoveToCategory failed: %v", err)
+	}
+
+	if first != second || firstThumb != secondThumb {
+		t.Errorf("identical uploads stored twice: %s, %s", first, second)
+	}
+	if first == other {
+		t.Error("different uploads share a path")
+	}
+	if !IsContentAddressed(first) || IsContentAddressed(filepath.Join("categories", "animals", "img-1.png")) {
+		t.Errorf("IsContentAddressed wrong for %s", first)
+	}
+	if _, err := os.Stat(tempPath); !os.IsNotExist(err) {
+		t.Error("temp copy of a duplicate should be removed")
+	}
+}
+
+func TestMove3DToCategory_CASDeduplicates(t *testing.T) {
+	svc := newCASStorage(t)
+
+	stage := func(imageID, thumb string) {
+		dir := filepath.Join(svc.tempDir, imageID)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create object dir: %v", err)
+		}
+		// Thumbnails are derived and do not count towards the content
+		for name, content := range map[string]string{"model.stl": "solid", "front.png": "front", "front_thumb.jpg": thumb} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatalf("failed to create %s: %v", name, err)
+			}
+		}
+	}
+
+	stage("obj-1", "thumb-a")
+	first, _, _, err := svc.Move3DToCategory("obj-1", "", Placement{Category: "sculpture"})
+	if err != nil {
+		t.Fatalf("Move3DToCategory failed: %v", err)
+	}
+	stage("obj-2", "thumb-b")
+	second, modelPath, views, err := svc.Move3DToCategory("obj-2", "", Placement{Category: "sculpture"})
+	if err != nil {
+		t.Fatalf("Move3DToCategory failed: %v", err)
+	}
+
+	if first != second || filepath.Dir(modelPath) != second || views["front"] == "" {
+		t.Errorf("identical objects stored twice: %s, %s (model %s)", first, second, modelPath)
+	}
+	if _, err := os.Stat(filepath.Join(svc.tempDir, "obj-2")); !os.IsNotExist(err) {
+		t.Error("temp folder of a duplicate should be removed")
+	}
+
+	// Rolling back a shared folder leaves the stored copy in place
+	if err := svc.Restore3DToTemp("obj-2", second); err != nil {
+		t.Fatalf("Restore3DToTemp failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(svc.dataDir, modelPath)); err != nil {
+		t.Errorf("shared folder should remain after rollback: %v", err)
+	}
+}
+
+func TestDeleteImage_KeepsSharedContent(t *testing.T) {
+	storage := newCASStorage(t)
+	index := NewIndexService(storage.dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	imageService := NewImageService(storage, nil, index, nil, logrus.New())
+
+	for _, id := range []string{"img-1", "img-2"} {
+		filePath, thumbPath, err := storage.MoveToCategory(id, stageImage(t, storage, id, "same"), Placement{})
+		if err != nil {
+			t.Fatalf("MoveToCategory failed: %v", err)
+		}
+		img := &models.Image{ID: id, Title: id, Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), FilePath: filePath, ThumbnailPath: thumbPath}
+		if err := index.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+	stored, _ := index.GetImageByID("img-1")
+	fullPath := filepath.Join(storage.dataDir, stored.FilePath)
+
+	if err := imageService.DeleteImage("img-1", "tester"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		t.Errorf("content still used by img-2 was deleted: %v", err)
+	}
+
+	if err := imageService.DeleteImage("img-2", "tester"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+	if _, err := os.Stat(fullPath); !os.IsNotExist(err) {
+		t.Error("content should be deleted with its last image")
+	}
+}
+
+func TestFocalPoint_CASCopyOnWrite(t *testing.T) {
+	storage := newCASStorage(t)
+	index := NewIndexService(storage.dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	imageService := NewImageService(storage, nil, index, NewStatusStore("", 0, 0), logrus.New())
+
+	// The same wide image uploaded twice, sharing one square thumbnail
+	src := imaging.New(400, 100, color.White)
+	src = imaging.Paste(src, imaging.New(100, 100, color.Black), image.Pt(300, 0))
+	for _, id := range []string{"img-1", "img-2"} {
+		tempPath := filepath.Join(storage.tempDir, id+".png")
+		if err := imaging.Save(src, tempPath); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := storage.GenerateThumbnail(tempPath); err != nil {
+			t.Fatalf("GenerateThumbnail failed: %v", err)
+		}
+		if _, _, err := storage.GenerateSquareThumbnail(tempPath, &models.FocalPoint{X: 0.5, Y: 0.5}); err != nil {
+			t.Fatalf("GenerateSquareThumbnail failed: %v", err)
+		}
+		filePath, thumbPath, err := storage.MoveToCategory(id, tempPath, Placement{})
+		if err != nil {
+			t.Fatalf("MoveToCategory failed: %v", err)
+		}
+		img := &models.Image{ID: id, Title: id, Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(),
+			FilePath: filePath, ThumbnailPath: thumbPath, SquareThumbnail: squareThumbnailPath(filePath)}
+		if err := index.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+	shared, _ := index.GetImageByID("img-2")
+	sharedPath := filepath.Join(storage.dataDir, shared.SquareThumbnail)
+	before, _ := os.ReadFile(sharedPath)
+
+	updated, err := imageService.UpdateImage("img-1", 0, ImageUpdate{FocalPoint: &models.FocalPoint{X: 0.9, Y: 0.5, Source: models.FocalSourceManual}})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	if updated.SquareThumbnail == shared.SquareThumbnail {
+		t.Fatalf("expected img-1 to get its own square thumbnail, got %s", updated.SquareThumbnail)
+	}
+	ownPath := filepath.Join(storage.dataDir, updated.SquareThumbnail)
+	own, err := os.ReadFile(ownPath)
+	if err != nil || string(own) == string(before) {
+		t.Fatalf("expected img-1's square thumbnail to be recropped: %v", err)
+	}
+	if after, _ := os.ReadFile(sharedPath); string(after) != string(before) {
+		t.Error("recropping img-1 rewrote the square thumbnail img-2 shares")
+	}
+
+	// The copy goes with its image; the shared content stays for img-2
+	if err := imageService.DeleteImage("img-1", "tester"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+	if _, err := os.Stat(ownPath); !os.IsNotExist(err) {
+		t.Error("img-1's own square thumbnail should be deleted with it")
+	}
+	if _, err := os.Stat(sharedPath); err != nil {
+		t.Errorf("square thumbnail still used by img-2 was deleted: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Storage layouts decide which folder under the categories directory
+// MoveToCategory files an image in
+const (
+	LayoutCategory = "category"  // categories/<category>/
+	LayoutDate     = "date"      // categories/<YYYY>/<MM>/
+	LayoutArtist   = "artist"    // categories/<artist-slug>/
+	LayoutFlatHash = "flat-hash" // categories/<2 hex chars of the ID's hash>/
+	LayoutCAS      = "cas"       // categories/cas/<2 hex chars>/<content hash>; identical files are stored once
+)
+
+// ErrUnknownLayout is returned for a storage layout that is not one of the
+// Layout constants
+var ErrUnknownLayout = errors.New("unknown storage layout")
+
+// ErrInvalidCategory is returned for a category path that could leave or
+// nest unexpectedly inside the categories directory (see ValidCategoryPath)
+var ErrInvalidCategory = errors.New("invalid category path")
+
+// unknownArtistFolder holds images of the artist layout without an artist
+const unknownArtistFolder = "unknown-artist"
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// categorySegment matches one folder of a category path
+var categorySegment = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// SafeCategoryPath reports whether category is a slash-separated path of
+// lowercase letters, digits and hyphens: no "..", empty, absolute or
+// backslash segments that could escape the categories directory.
+// ValidCategoryPath is stricter and also bounds the depth.
+func SafeCategoryPath(category string) bool {
+	if category == "" {
+		return false
+	}
+	for _, segment := range strings.Split(category, "/") {
+		if !categorySegment.MatchString(segment) {
+			return false
+		}
+	}
+	return true
+}
+
+// Placement is what a storage layout may file an image by
+type Placement struct {
+	Category string // category path, "primary" or "primary/sub"
+	Artist   string
+	Time     time.Time // upload time, for the date layout
+}
+
+// ParseLayout validates a storage layout name; empty means LayoutCategory
+func ParseLayout(name string) (string, error) {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "":
+		return LayoutCategory, nil
+	case LayoutCategory, LayoutDate, LayoutArtist, LayoutFlatHash, LayoutCAS:
+		return name, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownLayout, name)
+}
+
+// layoutFolder returns the folder, relative to the categories directory, an
+// image is filed in under a layout
+func layoutFolder(layout, imageID string, place Placement) string {
+	switch layout {
+	case LayoutDate:
+		at := place.Time
+		if at.IsZero() {
+			at = time.Now()
+		}
+		return filepath.Join(at.Format("2006"), at.Format("01"))
+	case LayoutArtist:
+		slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(place.Artist), "-"), "-")
+		if slug == "" {
+			return unknownArtistFolder
+		}
+		return slug
+	case LayoutFlatHash:
+		sum := sha1.Sum([]byte(imageID))
+		return hex.EncodeToString(sum[:1])
+	default:
+		return filepath.FromSlash(place.Category)
+	}
+}
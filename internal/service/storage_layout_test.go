@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseLayout(t *testing.T) {
+	for name, want := range map[string]string{"": LayoutCategory, "Date": LayoutDate, " artist ": LayoutArtist, "flat-hash": LayoutFlatHash} {
+		if got, err := ParseLayout(name); err != nil || got != want {
+			t.Errorf("ParseLayout(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseLayout("by-color"); !errors.Is(err, ErrUnknownLayout) {
+		t.Errorf("err = %v, want ErrUnknownLayout", err)
+	}
+}
+
+func TestMoveToCategory_Layouts(t *testing.T) {
+	place := Placement{Category: "animals/dogs", Artist: "Jane O'Neil", Time: time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		layout string
+		want   string
+	}{
+		{LayoutCategory, "categories/animals/dogs/img-001.png"},
+		{LayoutDate, "categories/2024/03/img-001.png"},
+		{LayoutArtist, "categories/jane-o-neil/img-001.png"},
+		{LayoutFlatHash, "categories/" + layoutFolder(LayoutFlatHash, "img-001", place) + "/img-001.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			svc := NewStorageService(t.TempDir())
+			if err := svc.Initialize(); err != nil {
+				t.Fatalf("Initialize failed: %v", err)
+			}
+			if err := svc.SetLayout(tt.layout); err != nil {
+				t.Fatalf("SetLayout failed: %v", err)
+			}
+
+			tempPath := filepath.Join(svc.tempDir, "img-001.png")
+			if err := os.WriteFile(tempPath, []byte("image"), 0644); err != nil {
+				t.Fatalf("failed to create image: %v", err)
+			}
+			if err := os.WriteFile(svc.getThumbnailPath(tempPath), []byte("thumb"), 0644); err != nil {
+				t.Fatalf("failed to create thumbnail: %v", err)
+			}
+
+			relPath, relThumb, err := svc.MoveToCategory("img-001", tempPath, place)
+			if err != nil {
+				t.Fatalf("MoveToCategory failed: %v", err)
+			}
+			if filepath.ToSlash(relPath) != tt.want {
+				t.Errorf("path = %s, want %s", relPath, tt.want)
+			}
+			if filepath.Dir(relThumb) != filepath.Dir(relPath) {
+				t.Errorf("thumbnail %s not next to the image", relThumb)
+			}
+		})
+	}
+}
+
+func TestLayoutFolder_FlatHashAndUnknownArtist(t *testing.T) {
+	hash := layoutFolder(LayoutFlatHash, "img-001", Placement{})
+	if len(hash) != 2 || hash != layoutFolder(LayoutFlatHash, "img-001", Placement{Category: "other"}) {
+		t.Errorf("flat-hash folder %q should be 2 hex chars and depend only on the ID", hash)
+	}
+	if got := layoutFolder(LayoutArtist, "img-001", Placement{Artist: " !! "}); got != unknownArtistFolder {
+		t.Errorf("artist folder = %q, want %q", got, unknownArtistFolder)
+	}
+}
+
+func TestSafeCategoryPath(t *testing.T) {
+	for _, category := range []string{"animals", "animals/big-cats", "art/digital/fantasy/characters"} {
+		if !SafeCategoryPath(category) {
+			t.Errorf("expected %q to be safe", category)
+		}
+	}
+	for _, category := range []string{"", "..", "../etc", "animals/../..", "/etc", "animals/", "a//b", `a\b`, "Animals", "café"} {
+		if SafeCategoryPath(category) {
+			t.Errorf("expected %q to be rejected", category)
+		}
+	}
+}
+
+func TestMoveToCategory_RejectsUnsafeCategory(t *testing.T) {
+	svc := NewStorageService(t.TempDir())
+	if err := svc.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	tempPath := filepath.Join(svc.tempDir, "img-001.png")
+	if err := os.WriteFile(tempPath, []byte("image"), 0644); err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+
+	if _, _, err := svc.MoveToCategory("img-001", tempPath, Placement{Category: "../../outside"}); !errors.Is(err, ErrInvalidCategory) {
+		t.Errorf("expected ErrInvalidCategory, got %v", err)
+	}
+	if _, err := os.Stat(tempPath); err != nil {
+		t.Errorf("expected the image to stay in temp: %v", err)
+	}
+}
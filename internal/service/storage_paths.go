@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafePath is returned for a data-relative path that is not canonical or
+// leads outside the categories directory
+var ErrUnsafePath = errors.New("unsafe path")
+
+// CanonicalPath checks a data-relative path of a stored file, from the index
+// or a request, without touching the disk. Only canonical paths below
+// categories/ pass: relative, slash-separated, without ".", ".." or empty
+// segments, backslashes or NUL bytes. Index paths are canonical already
+// (normalizePath turns backslashes into slashes); a path that needs cleaning
+// is rejected rather than cleaned, so it can't mean something else to the
+// check than to the filesystem.
+func CanonicalPath(relPath string) (string, error) {
+	switch {
+	case relPath == "",
+		strings.ContainsAny(relPath, "\\\x00"),
+		path.IsAbs(relPath) || filepath.IsAbs(relPath) || filepath.VolumeName(relPath) != "",
+		path.Clean(relPath) != relPath:
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, relPath)
+	}
+	if rest, ok := strings.CutPrefix(relPath, "categories/"); !ok || rest == "" {
+		return "", fmt.Errorf("%w: outside categories: %q", ErrUnsafePath, relPath)
+	}
+	return relPath, nil
+}
+
+// ResolvePath resolves a data-relative path like StoredPath and also follows
+// symlinks, rejecting files that lie outside the categories directory once
+// resolved. Files are served through it; the path must exist.
+func (s *StorageService) ResolvePath(relPath string) (string, error) {
+	fullPath, err := s.StoredPath(relPath)
+	if err != nil {
+		return "", err
+	}
+
+	categoriesDir, err := filepath.EvalSymlinks(filepath.Join(s.dataDir, "categories"))
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(categoriesDir, resolved); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q leads outside categories", ErrUnsafePath, relPath)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanonicalPath(t *testing.T) {
+	for _, good := range []string{"categories/animals/img-1.jpg", "categories/cas/9f/9f86d0.jpg", "categories/a b/ü.png"} {
+		if _, err := CanonicalPath(good); err != nil {
+			t.Errorf("expected %q to pass, got %v", good, err)
+		}
+	}
+	for _, bad := range []string{
+		"", "index.md", "categories", "categories/", "temp/img-1.jpg",
+		"../categories/x.jpg", "categories/../index.md", "categories/a/../../index.md",
+		"categories/./x.jpg", "categories//x.jpg", "categories/x.jpg/",
+		"/categories/x.jpg", `categories\..\index.md`, "categories/x.jpg\x00.png",
+	} {
+		if _, err := CanonicalPath(bad); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("expected %q to be rejected, got %v", bad, err)
+		}
+	}
+}
+
+func TestResolvePath_Symlinks(t *testing.T) {
+	dataDir := t.TempDir()
+	svc := NewStorageService(dataDir)
+	if err := svc.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	animals := filepath.Join(dataDir, "categories", "animals")
+	if err := os.MkdirAll(animals, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(animals, "img-1.jpg"), []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "index.md"), []byte("# index"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A link within the categories folder is fine; one leading out is not
+	if err := os.Symlink(filepath.Join(animals, "img-1.jpg"), filepath.Join(animals, "alias.jpg")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(dataDir, "index.md"), filepath.Join(animals, "leak.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dataDir, filepath.Join(animals, "up")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, good := range []string{"categories/animals/img-1.jpg", "categories/animals/alias.jpg"} {
+		if _, err := svc.ResolvePath(good); err != nil {
+			t.Errorf("expected %s to resolve, got %v", good, err)
+		}
+	}
+	for _, bad := range []string{"categories/animals/leak.jpg", "categories/animals/up/index.md"} {
+		if _, err := svc.ResolvePath(bad); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("expected %s to be rejected, got %v", bad, err)
+		}
+	}
+	if _, err := svc.ResolvePath("categories/animals/missing.jpg"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -1,19 +1,24 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
+	"mime"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/disintegration/imaging"
 	"github.com/google/uuid"
+	"github.com/yourcompany/image-warehousing/internal/models"
 )
 
 const (
@@ -21,16 +26,63 @@ const (
 )
 
 type StorageService struct {
-	dataDir string
-	tempDir string
+	dataDir       string
+	tempDir       string
+	normalizeSRGB bool   // convert wide-gamut thumbnails to sRGB instead of embedding the profile
+	layout        string // storage layout of moved images; see ParseLayout
+
+	thumbnailCache *ThumbnailCache // bytes of hot thumbnails; nil when disabled
 }
 
 func NewStorageService(dataDir string) *StorageService {
-	tempDir := filepath.Join(dataDir, "temp")
+	return NewStorageServiceWithTempDir(dataDir, "")
+}
+
+// NewStorageServiceWithTempDir creates a storage service whose temp folder
+// lives outside the data dir (e.g. on a different mounted volume).
+// An empty tempDir falls back to <dataDir>/temp.
+func NewStorageServiceWithTempDir(dataDir, tempDir string) *StorageService {
+	if tempDir == "" {
+		tempDir = filepath.Join(dataDir, "temp")
+	}
 	return &StorageService{
 		dataDir: dataDir,
 		tempDir: tempDir,
+		layout:  LayoutCategory,
+	}
+}
+
+// SetNormalizeSRGB controls whether thumbnails of images with a wide-gamut
+// profile are converted to sRGB (true) or keep the source profile (false)
+func (s *StorageService) SetNormalizeSRGB(enabled bool) {
+	s.normalizeSRGB = enabled
+}
+
+// SetLayout chooses the storage layout MoveToCategory and Move3DToCategory
+// file images by (category, date, artist, flat-hash or cas). Images already
+// stored stay where they are.
+func (s *StorageService) SetLayout(layout string) error {
+	parsed, err := ParseLayout(layout)
+	if err != nil {
+		return err
 	}
+	s.layout = parsed
+	return nil
+}
+
+// SetThumbnailCache keeps the bytes of served thumbnails in cache
+func (s *StorageService) SetThumbnailCache(cache *ThumbnailCache) {
+	s.thumbnailCache = cache
+}
+
+// ThumbnailCache returns the thumbnail cache, nil when disabled
+func (s *StorageService) ThumbnailCache() *ThumbnailCache {
+	return s.thumbnailCache
+}
+
+// Layout returns the storage layout in use
+func (s *StorageService) Layout() string {
+	return s.layout
 }
 
 // Initialize creates necessary directories
@@ -50,7 +102,7 @@ func (s *StorageService) Initialize() error {
 }
 
 // SaveImageToTemp saves a 2D image temporarily and returns the path
-func (s *StorageService) SaveImageToTemp(file multipart.File, filename string) (string, string, error) {
+func (s *StorageService) SaveImageToTemp(file io.Reader, filename string) (string, string, error) {
 	imageID := uuid.New().String()
 	ext := filepath.Ext(filename)
 	tempPath := filepath.Join(s.tempDir, imageID+ext)
@@ -63,6 +115,7 @@ func (s *StorageService) SaveImageToTemp(file multipart.File, filename string) (
 	defer outFile.Close()
 
 	if _, err := io.Copy(outFile, file); err != nil {
+		os.Remove(tempPath)
 		return "", "", fmt.Errorf("failed to save file: %w", err)
 	}
 
@@ -117,7 +170,60 @@ func (s *StorageService) Save3DObjectToTemp(modelFile multipart.File, modelFilen
 	return imageID, paths, modelPath, nil
 }
 
-// GenerateThumbnail creates a 300x300 thumbnail for a 2D image
+// materialExtensions are the companion files a 3D model may reference
+var materialExtensions = map[string]bool{
+	".mtl": true, ".bin": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".tga": true, ".bmp": true, ".tif": true, ".tiff": true,
+	".webp": true, ".ktx2": true, ".dds": true, ".exr": true, ".hdr": true,
+}
+
+// IsMaterialFile reports whether a file name is an accepted companion file
+// of a 3D model: a .mtl library, a glTF buffer or a texture
+func IsMaterialFile(filename string) bool {
+	return materialExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// SaveMaterialsToTemp saves the companion files of a 3D model to the
+// materials folder of its temp object folder, under their base names
+func (s *StorageService) SaveMaterialsToTemp(imageID string, files []*multipart.FileHeader) error {
+	dir := filepath.Join(s.tempDir, imageID, MaterialsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create materials directory: %w", err)
+	}
+
+	for _, header := range files {
+		path := companionPath(filepath.Join(s.tempDir, imageID, "model"), header.Filename)
+		if path == "" {
+			return fmt.Errorf("invalid material file name: %s", header.Filename)
+		}
+		if err := saveUploadedFile(header, path); err != nil {
+			return fmt.Errorf("failed to save material %s: %w", header.Filename, err)
+		}
+	}
+	return nil
+}
+
+// saveUploadedFile copies an uploaded form file to path
+func saveUploadedFile(header *multipart.FileHeader, path string) error {
+	in, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// GenerateThumbnail creates a 300x300 thumbnail for a 2D image, keeping or
+// converting its color profile (see saveDerivative)
 func (s *StorageService) GenerateThumbnail(imagePath string) (string, error) {
 	// Open the image
 	src, err := imaging.Open(imagePath)
@@ -130,13 +236,90 @@ func (s *StorageService) GenerateThumbnail(imagePath string) (string, error) {
 
 	// Save thumbnail
 	thumbPath := s.getThumbnailPath(imagePath)
-	if err := imaging.Save(thumb, thumbPath); err != nil {
+	if err := s.saveDerivative(thumb, imagePath, thumbPath); err != nil {
 		return "", fmt.Errorf("failed to save thumbnail: %w", err)
 	}
 
 	return thumbPath, nil
 }
 
+// GenerateSquareThumbnail creates a square thumbnail cropped around the
+// image's subject. A nil focal point is estimated from the image; the focal
+// point used is returned so it can be recorded.
+func (s *StorageService) GenerateSquareThumbnail(imagePath string, focal *models.FocalPoint) (string, models.FocalPoint, error) {
+	return s.GenerateSquareThumbnailAt(imagePath, s.getSquareThumbnailPath(imagePath), focal)
+}
+
+// GenerateSquareThumbnailAt is GenerateSquareThumbnail writing to squarePath
+func (s *StorageService) GenerateSquareThumbnailAt(imagePath, squarePath string, focal *models.FocalPoint) (string, models.FocalPoint, error) {
+	src, err := imaging.Open(imagePath)
+	if err != nil {
+		return "", models.FocalPoint{}, fmt.Errorf("failed to open image: %w", err)
+	}
+
+	point := FindFocalPoint(src)
+	if focal != nil {
+		point = *focal
+	}
+
+	if err := s.saveDerivative(SquareCrop(src, ThumbnailSize, point), imagePath, squarePath); err != nil {
+		return "", models.FocalPoint{}, fmt.Errorf("failed to save square thumbnail: %w", err)
+	}
+
+	return squarePath, point, nil
+}
+
+// saveDerivative writes a JPEG derivative of sourcePath. Sources with a
+// wide-gamut ICC profile keep it, or are converted to sRGB when normalization
+// is enabled, so derivatives don't come out washed out.
+func (s *StorageService) saveDerivative(img image.Image, sourcePath, outPath string) error {
+	// A broken profile shouldn't fail the derivative; it's treated as sRGB
+	profile, err := ReadColorProfile(sourcePath)
+	if err != nil {
+		profile = nil
+	}
+
+	embedProfile := profile != nil && !profile.IsSRGB()
+	if embedProfile && s.normalizeSRGB && profile.CanConvert() {
+		img = profile.ToSRGB(img)
+		embedProfile = false
+	}
+
+	return replaceFile(outPath, func(tmpPath string) error {
+		if err := imaging.Save(img, tmpPath); err != nil {
+			return err
+		}
+		if embedProfile {
+			if err := embedJPEGProfile(tmpPath, profile.Data); err != nil {
+				return fmt.Errorf("failed to embed color profile: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// replaceFile writes a file through write to a temp file next to path and
+// renames it over path. A path hard-linked to other images' files by
+// duplicate consolidation then gets a file of its own, instead of being
+// rewritten through the link for all of them.
+func replaceFile(path string, write func(tmpPath string) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*"+filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if err := write(tmpPath); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
 // GenerateThumbnails3D creates thumbnails for all 6 views of a 3D object
 func (s *StorageService) GenerateThumbnails3D(viewPaths map[string]string) (map[string]string, error) {
 	thumbnails := make(map[string]string)
@@ -152,9 +335,17 @@ func (s *StorageService) GenerateThumbnails3D(viewPaths map[string]string) (map[
 	return thumbnails, nil
 }
 
-// MoveToCategory moves a 2D image from temp to its category folder
-func (s *StorageService) MoveToCategory(imageID, tempPath, category string) (string, string, error) {
-	categoryDir := filepath.Join(s.dataDir, "categories", category)
+// MoveToCategory moves a 2D image from temp to the folder the storage layout
+// files it in
+func (s *StorageService) MoveToCategory(imageID, tempPath string, place Placement) (string, string, error) {
+	if s.layout == LayoutCAS {
+		return s.moveToCAS(tempPath)
+	}
+
+	categoryDir, err := s.placementDir(imageID, place)
+	if err != nil {
+		return "", "", err
+	}
 	if err := os.MkdirAll(categoryDir, 0755); err != nil {
 		return "", "", fmt.Errorf("failed to create category directory: %w", err)
 	}
@@ -165,17 +356,25 @@ func (s *StorageService) MoveToCategory(imageID, tempPath, category string) (str
 	newThumbPath := filepath.Join(categoryDir, imageID+"_thumb.jpg")
 
 	// Move main image
-	if err := os.Rename(tempPath, newPath); err != nil {
+	if err := moveFile(tempPath, newPath); err != nil {
 		return "", "", fmt.Errorf("failed to move image: %w", err)
 	}
 
 	// Move thumbnail
-	if err := os.Rename(thumbPath, newThumbPath); err != nil {
+	if err := moveFile(thumbPath, newThumbPath); err != nil {
 		// If thumbnail move fails, try to move image back
-		os.Rename(newPath, tempPath)
+		moveFile(newPath, tempPath)
 		return "", "", fmt.Errorf("failed to move thumbnail: %w", err)
 	}
 
+	// The square thumbnail is optional; it follows the image when present
+	squarePath := s.getSquareThumbnailPath(tempPath)
+	if _, err := os.Stat(squarePath); err == nil {
+		if err := moveFile(squarePath, s.getSquareThumbnailPath(newPath)); err != nil {
+			os.Remove(squarePath)
+		}
+	}
+
 	// Make paths relative to data dir
 	relPath, _ := filepath.Rel(s.dataDir, newPath)
 	relThumbPath, _ := filepath.Rel(s.dataDir, newThumbPath)
@@ -183,18 +382,11 @@ func (s *StorageService) MoveToCategory(imageID, tempPath, category string) (str
 	return relPath, relThumbPath, nil
 }
 
-// Move3DToCategory moves a 3D object folder (including model file and views) from temp to its category folder
-func (s *StorageService) Move3DToCategory(imageID, tempDir, category string) (string, string, map[string]string, error) {
-	categoryDir := filepath.Join(s.dataDir, "categories", category)
-	if err := os.MkdirAll(categoryDir, 0755); err != nil {
-		return "", "", nil, fmt.Errorf("failed to create category directory: %w", err)
-	}
-
-	newObjectDir := filepath.Join(categoryDir, imageID)
-
-	// Move the entire object directory
-	if err := os.Rename(filepath.Join(s.tempDir, imageID), newObjectDir); err != nil {
-		return "", "", nil, fmt.Errorf("failed to move object directory: %w", err)
+// Move3DToCategory moves a 3D object folder (including model file and views) from temp to the folder the storage layout files it in
+func (s *StorageService) Move3DToCategory(imageID, tempDir string, place Placement) (string, string, map[string]string, error) {
+	newObjectDir, err := s.moveObjectDir(imageID, place)
+	if err != nil {
+		return "", "", nil, err
 	}
 
 	// Find the model file and build relative paths for all views
@@ -221,8 +413,16 @@ func (s *StorageService) Move3DToCategory(imageID, tempDir, category string) (st
 			continue
 		}
 
+		// Frames extracted from a turntable clip are named by angle
+		base := strings.TrimSuffix(filename, filepath.Ext(filename))
+		if isFrameView(base) && !strings.Contains(filename, "_thumb") {
+			relPath, _ := filepath.Rel(s.dataDir, filepath.Join(newObjectDir, filename))
+			views[base] = relPath
+			continue
+		}
+
 		// Check if it's a view file (exclude thumbnails)
-		for _, view := range []string{"front", "back", "left", "right", "top", "bottom"} {
+		for _, view := range Views6 {
 			// Match exact view name (e.g., "front.png" but not "front_thumb.jpg")
 			if strings.HasPrefix(filename, view) && !strings.Contains(filename, "_thumb") {
 				fullPath := filepath.Join(newObjectDir, filename)
@@ -238,6 +438,116 @@ func (s *StorageService) Move3DToCategory(imageID, tempDir, category string) (st
 	return relFolderPath, modelPath, views, nil
 }
 
+// moveObjectDir moves a 3D object's temp folder to where the storage layout
+// files it and returns the new absolute folder
+func (s *StorageService) moveObjectDir(imageID string, place Placement) (string, error) {
+	if s.layout == LayoutCAS {
+		return s.move3DToCAS(imageID)
+	}
+
+	categoryDir, err := s.placementDir(imageID, place)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(categoryDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create category directory: %w", err)
+	}
+
+	// Move the entire object directory
+	newObjectDir := filepath.Join(categoryDir, imageID)
+	if err := moveDir(filepath.Join(s.tempDir, imageID), newObjectDir); err != nil {
+		return "", fmt.Errorf("failed to move object directory: %w", err)
+	}
+	return newObjectDir, nil
+}
+
+// Restore3DToTemp moves a 3D object folder, given relative to the data
+// directory as returned by Move3DToCategory, back to temp. It is the
+// compensating step for Move3DToCategory when a later step fails.
+// Content-addressed folders may be shared with other images, so they are
+// copied back instead.
+func (s *StorageService) Restore3DToTemp(imageID, folderPath string) error {
+	objectDir, err := s.StoredPath(folderPath)
+	if err != nil {
+		return err
+	}
+	tempObjectDir := filepath.Join(s.tempDir, imageID)
+
+	if _, err := os.Stat(tempObjectDir); err == nil {
+		return fmt.Errorf("temp directory already exists for %s", imageID)
+	}
+
+	restore := moveDir
+	if IsContentAddressed(folderPath) {
+		restore = copyDir
+	}
+	if err := restore(objectDir, tempObjectDir); err != nil {
+		return fmt.Errorf("failed to restore object directory: %w", err)
+	}
+
+	return nil
+}
+
+// CleanupTemp removes the temp files of a job that will not be processed
+func (s *StorageService) CleanupTemp(job *models.UploadJob) {
+	if job.Type == models.ImageType3D {
+		os.RemoveAll(filepath.Join(s.tempDir, job.ImageID))
+		return
+	}
+	if job.FilePath != "" {
+		os.Remove(job.FilePath)
+		os.Remove(s.getThumbnailPath(job.FilePath))
+		os.Remove(s.getSquareThumbnailPath(job.FilePath))
+	}
+}
+
+// HasTemp reports whether a job's uploaded files are still in the temp
+// directory, i.e. the job can be processed again
+func (s *StorageService) HasTemp(job *models.UploadJob) bool {
+	path := job.FilePath
+	if job.Type == models.ImageType3D {
+		path = filepath.Join(s.tempDir, job.ImageID)
+	}
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// DeleteStoredFiles removes stored files or folders given as paths relative to
+// the data directory. Paths that resolve outside the categories directory are
+// rejected.
+func (s *StorageService) DeleteStoredFiles(relPaths ...string) error {
+	for _, relPath := range relPaths {
+		if relPath == "" {
+			continue
+		}
+
+		fullPath, err := s.StoredPath(relPath)
+		if err != nil {
+			return err
+		}
+
+		if err := os.RemoveAll(fullPath); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", relPath, err)
+		}
+	}
+
+	return nil
+}
+
+// StoredPath resolves a data-relative path of a stored file to an absolute
+// path, rejecting paths that are not canonical or lie outside the categories
+// directory (see CanonicalPath)
+func (s *StorageService) StoredPath(relPath string) (string, error) {
+	clean, err := CanonicalPath(relPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dataDir, filepath.FromSlash(clean)), nil
+}
+
 // GetImageDimensions returns the width and height of an image
 func (s *StorageService) GetImageDimensions(imagePath string) (int, int, error) {
 	file, err := os.Open(imagePath)
@@ -263,6 +573,57 @@ func (s *StorageService) GetFileSize(path string) (int64, error) {
 	return info.Size(), nil
 }
 
+// extensionMimeTypes are image formats the content sniffer doesn't recognize
+// and the system MIME table may not list
+var extensionMimeTypes = map[string]string{
+	".tif": "image/tiff", ".tiff": "image/tiff", ".heic": "image/heic", ".heif": "image/heif",
+}
+
+// DetectMimeType returns the MIME type of a file from its content, or from
+// its extension for formats the content sniffer doesn't know (TIFF, HEIC, ...)
+func DetectMimeType(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	mimeType := http.DetectContentType(head[:n])
+	if mimeType == "application/octet-stream" || strings.HasPrefix(mimeType, "text/plain") {
+		ext := strings.ToLower(filepath.Ext(path))
+		if byExt, ok := extensionMimeTypes[ext]; ok {
+			return byExt
+		}
+		if byExt := mime.TypeByExtension(ext); byExt != "" {
+			return byExt
+		}
+	}
+	return mimeType
+}
+
+// CleanFilename reduces a client-supplied file name to its base name without
+// control characters, so it can be indexed and sent back in a
+// Content-Disposition header
+func CleanFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	// Some browsers send the full client path, with either separator
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(name)
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
 // getThumbnailPath returns the thumbnail path for a given image path
 func (s *StorageService) getThumbnailPath(imagePath string) string {
 	dir := filepath.Dir(imagePath)
@@ -272,8 +633,182 @@ func (s *StorageService) getThumbnailPath(imagePath string) string {
 	return filepath.Join(dir, name+"_thumb.jpg")
 }
 
+// getSquareThumbnailPath returns the square thumbnail path for a given image path
+func (s *StorageService) getSquareThumbnailPath(imagePath string) string {
+	return squareThumbnailPath(imagePath)
+}
+
+// squareThumbnailPath derives the square thumbnail path from an image path;
+// it works on absolute and data-relative paths alike
+func squareThumbnailPath(imagePath string) string {
+	ext := filepath.Ext(imagePath)
+	return imagePath[:len(imagePath)-len(ext)] + "_square.jpg"
+}
+
+// placementDir returns the absolute folder an image is filed in under the
+// storage layout. Category paths are checked, since they come from the AI.
+func (s *StorageService) placementDir(imageID string, place Placement) (string, error) {
+	if s.layout == LayoutCategory && !SafeCategoryPath(place.Category) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCategory, place.Category)
+	}
+	return filepath.Join(s.dataDir, "categories", layoutFolder(s.layout, imageID, place)), nil
+}
+
 // CreateCategoryDir creates a category directory if it doesn't exist
 func (s *StorageService) CreateCategoryDir(category string) error {
+	if !SafeCategoryPath(category) {
+		return fmt.Errorf("%w: %q", ErrInvalidCategory, category)
+	}
 	categoryPath := filepath.Join(s.dataDir, "categories", category)
 	return os.MkdirAll(categoryPath, 0755)
 }
+
+// rename is os.Rename; tests swap it to simulate cross-device failures
+var rename = os.Rename
+
+// isCrossDevice reports whether err is an EXDEV error from a rename across
+// filesystems (e.g. temp and categories on different mounted volumes)
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
+
+// moveFile renames src to dst, falling back to copy+fsync+delete when the
+// two paths live on different filesystems. If dst exists, the fallback fails
+// and leaves it alone.
+func moveFile(src, dst string) error {
+	err := rename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(dst)); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// moveDir renames a directory, falling back to a recursive copy followed by
+// removal of the source when the rename crosses filesystems. A failed copy
+// only removes the files and directories the copy created.
+func moveDir(src, dst string) error {
+	err := rename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	created, err := copyTree(src, dst)
+	if err != nil {
+		for i := len(created) - 1; i >= 0; i-- {
+			os.Remove(created[i])
+		}
+		return err
+	}
+	// The new directories' entries, then dst's own, must be on disk before
+	// the source goes
+	for i := len(created) - 1; i >= 0; i-- {
+		if info, err := os.Lstat(created[i]); err == nil && info.IsDir() {
+			if err := syncDir(created[i]); err != nil {
+				return err
+			}
+		}
+	}
+	if err := syncDir(filepath.Dir(dst)); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(src)
+}
+
+// syncDir fsyncs a directory, so the entries created in it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", dir, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
+	return nil
+}
+
+// copyFile copies a regular file and fsyncs the destination before returning.
+// dst must not exist; if the copy fails, the partial file is removed.
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", src, err)
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to sync %s: %w", dst, err)
+	}
+
+	return out.Close()
+}
+
+// copyDir recursively copies a directory tree
+func copyDir(src, dst string) error {
+	_, err := copyTree(src, dst)
+	return err
+}
+
+// copyTree copies a directory tree and returns the paths it created, parents
+// before their contents. Directories that already exist are merged into.
+func copyTree(src, dst string) ([]string, error) {
+	var created []string
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			if err := os.Mkdir(target, 0755); err != nil {
+				if info, statErr := os.Stat(target); statErr == nil && info.IsDir() {
+					return nil
+				}
+				return err
+			}
+			created = append(created, target)
+			return nil
+		}
+		if err := copyFile(path, target); err != nil {
+			return err
+		}
+		created = append(created, target)
+		return nil
+	})
+	return created, err
+}
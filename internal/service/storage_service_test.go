@@ -3,9 +3,11 @@ package service
 import (
 	"image"
 	"image/color"
+	"image/gif"
 	"image/png"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
 )
 
@@ -218,3 +220,367 @@ func TestCreateCategoryDir_NestedCategory(t *testing.T) {
 		t.Error("created path is not a directory")
 	}
 }
+
+func TestRestore3DToTemp(t *testing.T) {
+	tempDir := t.TempDir()
+	svc := NewStorageService(tempDir)
+	if err := svc.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	// Stage a 3D object in temp
+	imageID := "obj-001"
+	objectDir := filepath.Join(tempDir, "temp", imageID)
+	if err := os.MkdirAll(objectDir, 0755); err != nil {
+		t.Fatalf("failed to create object dir: %v", err)
+	}
+	for _, name := range []string{"model.glb", "front.png", "back.png"} {
+		if err := os.WriteFile(filepath.Join(objectDir, name), []byte("data"), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	folderPath, _, _, err := svc.Move3DToCategory(imageID, "", Placement{Category: "sculpture"})
+	if err != nil {
+		t.Fatalf("Move3DToCategory failed: %v", err)
+	}
+
+	if err := svc.Restore3DToTemp(imageID, folderPath); err != nil {
+		t.Fatalf("Restore3DToTemp failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(objectDir, "model.glb")); err != nil {
+		t.Errorf("model file was not restored to temp: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "categories", "sculpture", imageID)); !os.IsNotExist(err) {
+		t.Error("category folder should no longer contain the object")
+	}
+}
+
+func TestRestore3DToTemp_TempOccupied(t *testing.T) {
+	tempDir := t.TempDir()
+	svc := NewStorageService(tempDir)
+	if err := svc.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	imageID := "obj-002"
+	if err := os.MkdirAll(filepath.Join(tempDir, "temp", imageID), 0755); err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tempDir, "categories", "sculpture", imageID), 0755); err != nil {
+		t.Fatalf("failed to create category dir: %v", err)
+	}
+
+	if err := svc.Restore3DToTemp(imageID, filepath.Join("categories", "sculpture", imageID)); err == nil {
+		t.Error("expected error when temp directory already exists, got nil")
+	}
+}
+
+func TestNewStorageServiceWithTempDir(t *testing.T) {
+	svc := NewStorageServiceWithTempDir("/test/data", "/scratch/temp")
+	if svc.tempDir != "/scratch/temp" {
+		t.Errorf("expected tempDir /scratch/temp, got %s", svc.tempDir)
+	}
+
+	svc = NewStorageServiceWithTempDir("/test/data", "")
+	if svc.tempDir != filepath.Join("/test/data", "temp") {
+		t.Errorf("expected default tempDir, got %s", svc.tempDir)
+	}
+}
+
+// simulateCrossDevice makes every rename fail with EXDEV for the duration of the test
+func simulateCrossDevice(t *testing.T) {
+	t.Helper()
+	orig := rename
+	rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() { rename = orig })
+}
+
+func TestMoveToCategory_CrossDevice(t *testing.T) {
+	dataDir := t.TempDir()
+	svc := NewStorageServiceWithTempDir(dataDir, t.TempDir())
+	if err := svc.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	tempPath := filepath.Join(svc.tempDir, "img-001.png")
+	if err := os.WriteFile(tempPath, []byte("image"), 0644); err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	if err := os.WriteFile(svc.getThumbnailPath(tempPath), []byte("thumb"), 0644); err != nil {
+		t.Fatalf("failed to create thumbnail: %v", err)
+	}
+
+	simulateCrossDevice(t)
+
+	relPath, relThumb, err := svc.MoveToCategory("img-001", tempPath, Placement{Category: "animals"})
+	if err != nil {
+		t.Fatalf("MoveToCategory failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dataDir, relPath))
+	if err != nil || string(content) != "image" {
+		t.Errorf("image not copied correctly: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, relThumb)); err != nil {
+		t.Errorf("thumbnail not copied: %v", err)
+	}
+	if _, err := os.Stat(tempPath); !os.IsNotExist(err) {
+		t.Error("temp image should be removed after cross-device move")
+	}
+}
+
+func TestMove3DToCategory_CrossDevice(t *testing.T) {
+	dataDir := t.TempDir()
+	svc := NewStorageServiceWithTempDir(dataDir, t.TempDir())
+	if err := svc.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	objectDir := filepath.Join(svc.tempDir, "obj-001")
+	if err := os.MkdirAll(objectDir, 0755); err != nil {
+		t.Fatalf("failed to create object dir: %v", err)
+	}
+	for _, name := range []string{"model.stl", "front.png", "front_thumb.jpg"} {
+		if err := os.WriteFile(filepath.Join(objectDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	simulateCrossDevice(t)
+
+	_, modelPath, views, err := svc.Move3DToCategory("obj-001", "", Placement{Category: "sculpture"})
+	if err != nil {
+		t.Fatalf("Move3DToCategory failed: %v", err)
+	}
+
+	if filepath.ToSlash(modelPath) != "categories/sculpture/obj-001/model.stl" {
+		t.Errorf("unexpected model path %s", modelPath)
+	}
+	if len(views) != 1 {
+		t.Errorf("expected 1 view, got %d", len(views))
+	}
+	if _, err := os.Stat(objectDir); !os.IsNotExist(err) {
+		t.Error("temp object directory should be removed after cross-device move")
+	}
+}
+
+func TestMoveFile_CrossDeviceKeepsExistingDst(t *testing.T) {
+	tempDir := t.TempDir()
+	src, dst := filepath.Join(tempDir, "src"), filepath.Join(tempDir, "dst")
+	os.WriteFile(src, []byte("new"), 0644)
+	os.WriteFile(dst, []byte("existing"), 0644)
+	simulateCrossDevice(t)
+
+	if err := moveFile(src, dst); err == nil {
+		t.Fatal("expected the copy onto an existing file to fail")
+	}
+	if content, err := os.ReadFile(dst); err != nil || string(content) != "existing" {
+		t.Errorf("existing destination was touched: %q, %v", content, err)
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Errorf("source should be kept: %v", err)
+	}
+}
+
+func TestMoveDir_CrossDeviceRemovesOnlyWhatItCopied(t *testing.T) {
+	tempDir := t.TempDir()
+	src, dst := filepath.Join(tempDir, "src"), filepath.Join(tempDir, "dst")
+	for _, name := range []string{"a.png", "b.png", "views/c.png"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0755)
+		os.WriteFile(filepath.Join(src, name), []byte(name), 0644)
+	}
+	// b.png is already there, so the copy fails after a.png
+	os.MkdirAll(dst, 0755)
+	os.WriteFile(filepath.Join(dst, "b.png"), []byte("existing"), 0644)
+	simulateCrossDevice(t)
+
+	if err := moveDir(src, dst); err == nil {
+		t.Fatal("expected the copy onto an existing file to fail")
+	}
+	if content, err := os.ReadFile(filepath.Join(dst, "b.png")); err != nil || string(content) != "existing" {
+		t.Errorf("existing file was touched: %q, %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a.png")); !os.IsNotExist(err) {
+		t.Error("the partial copy should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(src, "views", "c.png")); err != nil {
+		t.Errorf("source should be kept: %v", err)
+	}
+}
+
+func TestMoveFile_OtherErrorsNotRetried(t *testing.T) {
+	tempDir := t.TempDir()
+	err := moveFile(filepath.Join(tempDir, "missing"), filepath.Join(tempDir, "dst"))
+	if err == nil {
+		t.Fatal("expected error for missing source, got nil")
+	}
+	if isCrossDevice(err) {
+		t.Error("missing source should not be reported as cross-device")
+	}
+}
+
+func TestDeleteStoredFiles(t *testing.T) {
+	dataDir := t.TempDir()
+	svc := NewStorageService(dataDir)
+
+	imgPath := filepath.Join(dataDir, "categories", "animals", "img.jpg")
+	if err := os.MkdirAll(filepath.Dir(imgPath), 0755); err != nil {
+		t.Fatalf("failed to create category dir: %v", err)
+	}
+	if err := os.WriteFile(imgPath, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+
+	if err := svc.DeleteStoredFiles("categories/animals/img.jpg", ""); err != nil {
+		t.Fatalf("DeleteStoredFiles failed: %v", err)
+	}
+	if _, err := os.Stat(imgPath); !os.IsNotExist(err) {
+		t.Error("expected image to be deleted")
+	}
+
+	for _, bad := range []string{"../outside", "index.md", "categories"} {
+		if err := svc.DeleteStoredFiles(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestGenerateTurntable(t *testing.T) {
+	dir := t.TempDir()
+	svc := NewStorageService(dir)
+
+	views := make(map[string]string)
+	for i, view := range []string{"front", "right", "back", "left"} {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+		for x := 0; x < 64; x++ {
+			for y := 0; y < 32; y++ {
+				img.Set(x, y, color.RGBA{uint8(i * 60), 0, 0, 255})
+			}
+		}
+		path := filepath.Join(dir, view+".png")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("failed to create view: %v", err)
+		}
+		png.Encode(f, img)
+		f.Close()
+		views[view] = path
+	}
+
+	if !HasTurntableViews(views) {
+		t.Fatal("expected full view set")
+	}
+
+	outPath, err := svc.GenerateTurntable(views)
+	if err != nil {
+		t.Fatalf("GenerateTurntable failed: %v", err)
+	}
+
+	f, err := os.Open(outPath)
+	if err != nil {
+		t.Fatalf("failed to open turntable: %v", err)
+	}
+	defer f.Close()
+
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatalf("failed to decode turntable: %v", err)
+	}
+	if len(anim.Image) != 4 {
+		t.Errorf("expected 4 frames, got %d", len(anim.Image))
+	}
+	if b := anim.Image[0].Bounds(); b.Dx() != TurntableSize || b.Dy() != TurntableSize {
+		t.Errorf("expected %dx%d frames, got %v", TurntableSize, TurntableSize, b)
+	}
+
+	delete(views, "back")
+	if _, err := svc.GenerateTurntable(views); err == nil {
+		t.Error("expected error for incomplete view set")
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	tempDir := t.TempDir()
+
+	pngPath := filepath.Join(tempDir, "photo.jpg") // content wins over the extension
+	f, err := os.Create(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	tiffPath := filepath.Join(tempDir, "scan.tif")
+	if err := os.WriteFile(tiffPath, []byte("II*\x00not sniffed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{pngPath: "image/png", tiffPath: "image/tiff"} {
+		if got := DetectMimeType(path); got != want {
+			t.Errorf("DetectMimeType(%s) = %q, want %q", filepath.Base(path), got, want)
+		}
+	}
+	if got := DetectMimeType(filepath.Join(tempDir, "missing.png")); got != "" {
+		t.Errorf("expected no type for a missing file, got %q", got)
+	}
+}
+
+func TestCleanFilename(t *testing.T) {
+	tests := map[string]string{
+		"sunset.jpg":                   "sunset.jpg",
+		`C:\Users\me\Pictures\a b.png`: "a b.png",
+		"../../etc/passwd":             "passwd",
+		"line\nbreak.jpg":              "linebreak.jpg",
+		"..":                           "",
+	}
+	for in, want := range tests {
+		if got := CleanFilename(in); got != want {
+			t.Errorf("CleanFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStorageStats(t *testing.T) {
+	dataDir := t.TempDir()
+	svc := NewStorageService(dataDir)
+	if err := svc.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	files := map[string]int{
+		"categories/animals/a.jpg":       100,
+		"categories/animals/a_thumb.jpg": 10,
+		"categories/products/obj/m.glb":  1000,
+		"temp/upload.jpg":                50,
+		"index.md":                       5,
+	}
+	for name, size := range files {
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := svc.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Files != 3 || stats.Bytes != 1110 || stats.TempFiles != 1 || stats.TempBytes != 50 {
+		t.Errorf("unexpected totals %+v", stats)
+	}
+	if animals := stats.Folders["animals"]; animals.Files != 2 || animals.Bytes != 110 {
+		t.Errorf("unexpected animals usage %+v", animals)
+	}
+	if products := stats.Folders["products"]; products.Files != 1 || products.Bytes != 1000 {
+		t.Errorf("unexpected products usage %+v", products)
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FolderUsage is the number and size of files under one folder
+type FolderUsage struct {
+	Files int64 `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// StorageStats describes the files on disk: stored images by top-level
+// folder of the categories directory (the category, with the default
+// layout), and uploads waiting in the temp directory. Hard-linked files
+// (consolidated duplicates) are counted once per link.
+type StorageStats struct {
+	Files      int64                  `json:"files"`
+	Bytes      int64                  `json:"bytes"`
+	Folders    map[string]FolderUsage `json:"folders"`
+	TempFiles  int64                  `json:"temp_files"`
+	TempBytes  int64                  `json:"temp_bytes"`
+	ComputedAt time.Time              `json:"computed_at"`
+}
+
+// Stats walks the categories and temp directories and totals their files.
+// It reads every directory, so callers should cache the result.
+func (s *StorageService) Stats() (*StorageStats, error) {
+	stats := &StorageStats{Folders: make(map[string]FolderUsage), ComputedAt: time.Now()}
+
+	categoriesDir := filepath.Join(s.dataDir, "categories")
+	err := filepath.WalkDir(categoriesDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == categoriesDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(categoriesDir, path)
+		folder := strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+		if folder == filepath.ToSlash(rel) {
+			folder = "."
+		}
+		usage := stats.Folders[folder]
+		usage.Files++
+		usage.Bytes += info.Size()
+		stats.Folders[folder] = usage
+		stats.Files++
+		stats.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.WalkDir(s.tempDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == s.tempDir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			stats.TempFiles++
+			stats.TempBytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
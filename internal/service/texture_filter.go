@@ -0,0 +1,57 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// TextureFilter restricts 3D objects by their largest texture and shading
+// model; zero fields match everything
+type TextureFilter struct {
+	MinResolution int    // longest side of the largest texture, in pixels
+	Shading       string // pbr, phong or unlit
+}
+
+// ParseTextureFilter parses a minimum resolution such as "2K" or "2048" and
+// a shading model
+func ParseTextureFilter(minResolution, shading string) (TextureFilter, error) {
+	resolution, err := models.ParseTextureResolution(minResolution)
+	if err != nil {
+		return TextureFilter{}, err
+	}
+	f := TextureFilter{MinResolution: resolution, Shading: strings.ToLower(strings.TrimSpace(shading))}
+	return f, f.Validate()
+}
+
+// IsZero reports whether the filter matches everything
+func (f TextureFilter) IsZero() bool {
+	return f == TextureFilter{}
+}
+
+// Matches reports whether an image passes the filter. Images without a
+// material summary only pass an empty filter.
+func (f TextureFilter) Matches(img *ImageMetadata) bool {
+	if f.IsZero() {
+		return true
+	}
+	if img.Materials == nil {
+		return false
+	}
+	if img.Materials.MaxTextureSize < f.MinResolution {
+		return false
+	}
+	return f.Shading == "" || slices.Contains(img.Materials.Shading, f.Shading)
+}
+
+// Validate checks the shading model
+func (f TextureFilter) Validate() error {
+	switch f.Shading {
+	case "", models.ShadingPBR, models.ShadingPhong, models.ShadingUnlit:
+		return nil
+	default:
+		return fmt.Errorf("invalid shading: %s (use pbr, phong or unlit)", f.Shading)
+	}
+}
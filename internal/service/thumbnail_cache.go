@@ -0,0 +1,149 @@
+package service
+
+import (
+	"container/list"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// thumbnailCacheEntryShare bounds a single cached file to this fraction of
+// the cache, so one large file can't flush every thumbnail
+const thumbnailCacheEntryShare = 8
+
+// IsThumbnail reports whether a path is a grid thumbnail (square or not),
+// whose bytes the thumbnail cache keeps
+func IsThumbnail(path string) bool {
+	base := filepath.Base(path)
+	return strings.HasSuffix(base, "_thumb.jpg") || strings.HasSuffix(base, "_square.jpg")
+}
+
+// ThumbnailCacheStats describes the thumbnail cache since the server started
+type ThumbnailCacheStats struct {
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"max_bytes"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+type cachedThumbnail struct {
+	path    string
+	modTime time.Time
+	content []byte
+}
+
+// ThumbnailCache keeps the bytes of recently served thumbnails in memory, so
+// scrolling a gallery doesn't read the same files from disk over and over.
+// Entries are checked against the file's modification time and size on
+// every read, so regenerated thumbnails are never served stale. The least
+// recently used are dropped beyond the size limit.
+type ThumbnailCache struct {
+	maxBytes  int64
+	entries   map[string]*list.Element
+	lru       *list.List // most recently used first
+	bytes     int64
+	hits      int64
+	misses    int64
+	evictions int64
+	mutex     sync.Mutex
+}
+
+func NewThumbnailCache(maxBytes int64) *ThumbnailCache {
+	return &ThumbnailCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Read returns the content and modification time of the file at path, from
+// memory if the cached copy is current and from disk otherwise
+func (c *ThumbnailCache) Read(path string) ([]byte, time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if content, ok := c.get(path, info); ok {
+		return content, info.ModTime(), nil
+	}
+
+	content, err := io.ReadAll(io.LimitReader(file, info.Size()))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if int64(len(content)) == info.Size() {
+		c.put(path, info.ModTime(), content)
+	}
+	return content, info.ModTime(), nil
+}
+
+// get returns the cached content of path if it matches info
+func (c *ThumbnailCache) get(path string, info os.FileInfo) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.entries[path]
+	if ok {
+		entry := elem.Value.(*cachedThumbnail)
+		if entry.modTime.Equal(info.ModTime()) && int64(len(entry.content)) == info.Size() {
+			c.lru.MoveToFront(elem)
+			c.hits++
+			return entry.content, true
+		}
+	}
+	c.misses++
+	return nil, false
+}
+
+// put caches the content of path, unless it is too large to share the cache
+func (c *ThumbnailCache) put(path string, modTime time.Time, content []byte) {
+	size := int64(len(content))
+	if size > c.maxBytes/thumbnailCacheEntryShare {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.entries[path]; ok {
+		c.remove(elem)
+	}
+	c.entries[path] = c.lru.PushFront(&cachedThumbnail{path: path, modTime: modTime, content: content})
+	c.bytes += size
+	for c.bytes > c.maxBytes {
+		c.remove(c.lru.Back())
+		c.evictions++
+	}
+}
+
+// remove drops an entry. Caller must hold the lock.
+func (c *ThumbnailCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cachedThumbnail)
+	delete(c.entries, entry.path)
+	c.bytes -= int64(len(entry.content))
+}
+
+// Stats returns the cache size and its hits, misses and evictions since
+// startup
+func (c *ThumbnailCache) Stats() ThumbnailCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return ThumbnailCacheStats{
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+		MaxBytes:  c.maxBytes,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
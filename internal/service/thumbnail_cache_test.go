@@ -0,0 +1,77 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThumbnailCache_ReadThroughAndEviction(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := write("a_thumb.jpg", strings.Repeat("a", 100))
+	b := write("b_thumb.jpg", strings.Repeat("b", 100))
+	c := write("c_thumb.jpg", strings.Repeat("c", 100))
+	big := write("big_thumb.jpg", strings.Repeat("x", 300))
+
+	cache := NewThumbnailCache(1600)
+	read := func(path string) string {
+		content, _, err := cache.Read(path)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return string(content)
+	}
+
+	read(a)
+	if got := read(a); got != strings.Repeat("a", 100) {
+		t.Errorf("unexpected content %q", got)
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 || stats.Bytes != 100 {
+		t.Errorf("expected one miss then one hit, got %+v", stats)
+	}
+
+	// A rewritten file is read again, never served stale
+	write("a_thumb.jpg", strings.Repeat("A", 100))
+	os.Chtimes(a, time.Now(), time.Now().Add(time.Second))
+	if got := read(a); got != strings.Repeat("A", 100) {
+		t.Errorf("expected the new content, got %q", got)
+	}
+
+	// Files above an eighth of the cache aren't kept
+	read(big)
+	read(big)
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Entries != 1 {
+		t.Errorf("expected the large file to bypass the cache, got %+v", stats)
+	}
+
+	// Beyond the limit the least recently used go first
+	small := NewThumbnailCache(800)
+	small.Read(a)
+	small.Read(b)
+	small.Read(a)
+	for i := 0; i < 6; i++ {
+		path := write("fill"+string(rune('0'+i))+"_thumb.jpg", strings.Repeat("f", 100))
+		small.Read(path)
+	}
+	small.Read(c)
+	stats := small.Stats()
+	if stats.Bytes > 800 || stats.Evictions == 0 {
+		t.Errorf("expected evictions within the limit, got %+v", stats)
+	}
+	if _, ok := small.entries[b]; ok {
+		t.Errorf("expected b, least recently used, to be evicted")
+	}
+
+	if _, _, err := cache.Read(filepath.Join(dir, "missing_thumb.jpg")); !os.IsNotExist(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Timeline dates and bucket sizes
+const (
+	TimelineByUpload  = "upload"
+	TimelineByCapture = "capture"
+
+	TimelineDay   = "day"
+	TimelineMonth = "month"
+)
+
+// TimelineBucket is the images of one day or month, newest first
+type TimelineBucket struct {
+	Date    string           // "2025-03-14" or "2025-03"
+	Count   int              // images in the bucket
+	Samples []*ImageMetadata // the newest few, for preview thumbnails
+}
+
+// Timeline is images grouped by date, newest bucket first
+type Timeline struct {
+	Buckets []TimelineBucket
+	Undated int // images without the chosen date (e.g. no EXIF capture time)
+}
+
+// BuildTimeline groups images by upload or capture date into day or month
+// buckets, keeping up to samples images per bucket
+func BuildTimeline(images []*ImageMetadata, by, interval string, samples int) (*Timeline, error) {
+	var layout string
+	switch interval {
+	case TimelineDay:
+		layout = "2006-01-02"
+	case TimelineMonth:
+		layout = "2006-01"
+	default:
+		return nil, fmt.Errorf("interval must be %s or %s", TimelineDay, TimelineMonth)
+	}
+	if by != TimelineByUpload && by != TimelineByCapture {
+		return nil, fmt.Errorf("by must be %s or %s", TimelineByUpload, TimelineByCapture)
+	}
+
+	type dated struct {
+		img *ImageMetadata
+		at  time.Time
+	}
+	timeline := &Timeline{}
+	var entries []dated
+	for _, img := range images {
+		var at time.Time
+		var err error
+		if by == TimelineByCapture {
+			at, err = img.CapturedTime()
+		} else {
+			at, err = img.UploadedTime()
+		}
+		if err != nil {
+			timeline.Undated++
+			continue
+		}
+		entries = append(entries, dated{img, at})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].at.After(entries[j].at) })
+
+	for _, e := range entries {
+		date := e.at.Format(layout)
+		if n := len(timeline.Buckets); n == 0 || timeline.Buckets[n-1].Date != date {
+			timeline.Buckets = append(timeline.Buckets, TimelineBucket{Date: date})
+		}
+		bucket := &timeline.Buckets[len(timeline.Buckets)-1]
+		bucket.Count++
+		if len(bucket.Samples) < samples {
+			bucket.Samples = append(bucket.Samples, e.img)
+		}
+	}
+	return timeline, nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import "testing"
+
+func TestBuildTimeline(t *testing.T) {
+	images := []*ImageMetadata{
+		{ID: "a", UploadedAt: "2025-03-01 09:00:00", CapturedAt: "2024-12-24 18:00:00"},
+		{ID: "b", UploadedAt: "2025-03-14 10:00:00"},
+		{ID: "c", UploadedAt: "2025-03-14 16:30:00", CapturedAt: "2025-01-02 08:00:00"},
+		{ID: "d", UploadedAt: "2025-02-28 23:59:59"},
+	}
+
+	days, err := BuildTimeline(images, TimelineByUpload, TimelineDay, 1)
+	if err != nil {
+		t.Fatalf("BuildTimeline failed: %v", err)
+	}
+	if len(days.Buckets) != 3 || days.Buckets[0].Date != "2025-03-14" || days.Buckets[0].Count != 2 {
+		t.Fatalf("unexpected day buckets: %+v", days.Buckets)
+	}
+	if samples := days.Buckets[0].Samples; len(samples) != 1 || samples[0].ID != "c" {
+		t.Errorf("expected the newest image as the only sample, got %+v", samples)
+	}
+
+	months, _ := BuildTimeline(images, TimelineByUpload, TimelineMonth, 4)
+	if len(months.Buckets) != 2 || months.Buckets[0].Count != 3 || months.Buckets[1].Date != "2025-02" {
+		t.Errorf("unexpected month buckets: %+v", months.Buckets)
+	}
+
+	captured, _ := BuildTimeline(images, TimelineByCapture, TimelineMonth, 4)
+	if len(captured.Buckets) != 2 || captured.Buckets[0].Date != "2025-01" || captured.Undated != 2 {
+		t.Errorf("unexpected capture timeline: %+v (undated %d)", captured.Buckets, captured.Undated)
+	}
+
+	if _, err := BuildTimeline(images, TimelineByUpload, "year", 4); err == nil {
+		t.Error("expected an error for an unknown interval")
+	}
+}
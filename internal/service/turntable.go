@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// TurntableFilename is the animated preview stored in a 3D object's folder
+	TurntableFilename = "turntable.gif"
+	// TurntableSize is the width and height of turntable frames
+	TurntableSize = 240
+	// turntableDelay is the time each frame is shown, in 1/100s
+	turntableDelay = 40
+)
+
+// turntableViews are the horizontal views of a full set, in rotation order
+var turntableViews = []string{"front", "right", "back", "left"}
+
+// HasTurntableViews reports whether a 3D object has every view needed for a turntable
+func HasTurntableViews(viewPaths map[string]string) bool {
+	return turntableOrder(viewPaths) != nil
+}
+
+// turntableOrder returns the views of a turntable in rotation order: the
+// frames extracted from a turntable clip, or else the horizontal views of a
+// full set. It returns nil if neither is complete.
+func turntableOrder(viewPaths map[string]string) []string {
+	var frames []string
+	for view := range viewPaths {
+		if isFrameView(view) {
+			frames = append(frames, view)
+		}
+	}
+	if _, ok := viewPaths["front"]; ok && len(frames) > 0 {
+		sort.Strings(frames) // angles are zero-padded
+		return append([]string{"front"}, frames...)
+	}
+
+	for _, view := range turntableViews {
+		if _, ok := viewPaths[view]; !ok {
+			return nil
+		}
+	}
+	return turntableViews
+}
+
+// GenerateTurntable renders the horizontal views of a 3D object into a looping
+// GIF next to the views and returns its path
+func (s *StorageService) GenerateTurntable(viewPaths map[string]string) (string, error) {
+	order := turntableOrder(viewPaths)
+	if order == nil {
+		return "", fmt.Errorf("turntable needs front, right, back and left views or clip frames")
+	}
+
+	anim := &gif.GIF{LoopCount: 0}
+	for _, view := range order {
+		src, err := openSRGB(viewPaths[view]) // GIFs can't carry a color profile
+		if err != nil {
+			return "", fmt.Errorf("failed to open %s view: %w", view, err)
+		}
+
+		// Letterbox every view onto the same square canvas
+		fitted := imaging.Fit(src, TurntableSize, TurntableSize, imaging.Lanczos)
+		canvas := imaging.New(TurntableSize, TurntableSize, color.White)
+		canvas = imaging.PasteCenter(canvas, fitted)
+
+		frame := image.NewPaletted(canvas.Bounds(), palette.WebSafe)
+		draw.FloydSteinberg.Draw(frame, frame.Bounds(), canvas, image.Point{})
+
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, turntableDelay)
+	}
+
+	outPath := filepath.Join(filepath.Dir(viewPaths["front"]), TurntableFilename)
+	err := replaceFile(outPath, func(tmpPath string) error {
+		out, err := os.Create(tmpPath)
+		if err != nil {
+			return fmt.Errorf("failed to create turntable: %w", err)
+		}
+		defer out.Close()
+
+		if err := gif.EncodeAll(out, anim); err != nil {
+			return fmt.Errorf("failed to encode turntable: %w", err)
+		}
+		return out.Close()
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return outPath, nil
+}
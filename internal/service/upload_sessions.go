@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Upload session states
+const (
+	UploadSessionPending   = "pending"   // created, upload not started
+	UploadSessionReceiving = "receiving" // body streaming in
+	UploadSessionComplete  = "complete"  // body received and the upload accepted
+	UploadSessionFailed    = "failed"    // upload rejected or interrupted
+)
+
+// ErrUploadSessionNotFound is returned for unknown or expired sessions
+var ErrUploadSessionNotFound = errors.New("upload session not found")
+
+// ErrUploadSessionUsed is returned when a session is reused for a second upload
+var ErrUploadSessionUsed = errors.New("upload session already used")
+
+// UploadSession is the progress of one upload as the server sees it
+type UploadSession struct {
+	ID         string    `json:"session"`
+	State      string    `json:"state"`
+	Received   int64     `json:"bytes_received"`
+	Total      int64     `json:"bytes_total,omitempty"` // request Content-Length; 0 if unknown (chunked)
+	Percent    float64   `json:"percent,omitempty"`     // only when the total is known
+	StatusCode int       `json:"status_code,omitempty"` // HTTP status of the upload response, once finished
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// UploadSessionStore tracks the bytes received by uploads that name a
+// session, so clients can poll progress while a large body streams in.
+// Sessions expire after the configured TTL.
+type UploadSessionStore struct {
+	sessions map[string]*UploadSession
+	mutex    sync.Mutex
+	ttl      time.Duration
+}
+
+func NewUploadSessionStore(ttl time.Duration) *UploadSessionStore {
+	return &UploadSessionStore{
+		sessions: make(map[string]*UploadSession),
+		ttl:      ttl,
+	}
+}
+
+// Create starts a pending session
+func (s *UploadSessionStore) Create() UploadSession {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	session := &UploadSession{ID: uuid.New().String(), State: UploadSessionPending, CreatedAt: now, UpdatedAt: now}
+	s.sessions[session.ID] = session
+	return *session
+}
+
+// Get returns a snapshot of a session
+func (s *UploadSessionStore) Get(id string) (UploadSession, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || s.expired(session) {
+		return UploadSession{}, ErrUploadSessionNotFound
+	}
+	snapshot := *session
+	if snapshot.Total > 0 {
+		snapshot.Percent = math.Floor(float64(snapshot.Received)*1000/float64(snapshot.Total)) / 10
+	}
+	return snapshot, nil
+}
+
+// Start marks a pending session as receiving a body of total bytes (0 if
+// unknown)
+func (s *UploadSessionStore) Start(id string, total int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || s.expired(session) {
+		return ErrUploadSessionNotFound
+	}
+	if session.State != UploadSessionPending {
+		return ErrUploadSessionUsed
+	}
+	session.State = UploadSessionReceiving
+	session.Total = max(total, 0)
+	session.UpdatedAt = time.Now()
+	return nil
+}
+
+// AddReceived counts n more bytes of a receiving session's body
+func (s *UploadSessionStore) AddReceived(id string, n int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if session, ok := s.sessions[id]; ok && session.State == UploadSessionReceiving {
+		session.Received += n
+		session.UpdatedAt = time.Now()
+	}
+}
+
+// Finish records the response status of a session's upload
+func (s *UploadSessionStore) Finish(id string, statusCode int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return
+	}
+	session.State = UploadSessionComplete
+	if statusCode >= 400 {
+		session.State = UploadSessionFailed
+	}
+	session.StatusCode = statusCode
+	session.UpdatedAt = time.Now()
+}
+
+// EvictExpired removes expired sessions and returns how many were removed
+func (s *UploadSessionStore) EvictExpired() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	evicted := 0
+	for id, session := range s.sessions {
+		if s.expired(session) {
+			delete(s.sessions, id)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Run evicts expired sessions every interval until ctx is done
+func (s *UploadSessionStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.EvictExpired()
+		}
+	}
+}
+
+// expired reports whether a session was idle past the TTL. Caller must hold
+// the lock.
+func (s *UploadSessionStore) expired(session *UploadSession) bool {
+	return s.ttl > 0 && time.Since(session.UpdatedAt) > s.ttl
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUploadSessionStore_Progress(t *testing.T) {
+	store := NewUploadSessionStore(time.Hour)
+	session := store.Create()
+	if session.State != UploadSessionPending {
+		t.Fatalf("expected a pending session, got %s", session.State)
+	}
+
+	if err := store.Start(session.ID, 1000); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	store.AddReceived(session.ID, 250)
+	store.AddReceived(session.ID, 100)
+
+	got, err := store.Get(session.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.State != UploadSessionReceiving || got.Received != 350 || got.Total != 1000 || got.Percent != 35 {
+		t.Errorf("unexpected progress %+v", got)
+	}
+
+	// A session tracks a single upload
+	if err := store.Start(session.ID, 1000); !errors.Is(err, ErrUploadSessionUsed) {
+		t.Errorf("expected ErrUploadSessionUsed, got %v", err)
+	}
+
+	store.Finish(session.ID, 202)
+	if got, _ := store.Get(session.ID); got.State != UploadSessionComplete || got.StatusCode != 202 {
+		t.Errorf("expected a complete session, got %+v", got)
+	}
+
+	failed := store.Create()
+	store.Start(failed.ID, 0)
+	store.Finish(failed.ID, 400)
+	if got, _ := store.Get(failed.ID); got.State != UploadSessionFailed || got.Percent != 0 {
+		t.Errorf("expected a failed session without percent, got %+v", got)
+	}
+}
+
+func TestUploadSessionStore_Expiry(t *testing.T) {
+	store := NewUploadSessionStore(time.Minute)
+	session := store.Create()
+	store.sessions[session.ID].UpdatedAt = time.Now().Add(-time.Hour)
+
+	if _, err := store.Get(session.ID); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("expected an expired session to be gone, got %v", err)
+	}
+	if err := store.Start(session.ID, 10); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("expected Start to reject an expired session, got %v", err)
+	}
+	if evicted := store.EvictExpired(); evicted != 1 {
+		t.Errorf("expected 1 eviction, got %d", evicted)
+	}
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+var (
+	// ErrInvalidUpscaleFactor is returned for factors other than 2 and 4
+	ErrInvalidUpscaleFactor = errors.New("upscale factor must be 2 or 4")
+	// ErrUpscaleUnsupported is returned for images without a single file,
+	// i.e. 3D objects
+	ErrUpscaleUnsupported = errors.New("only 2D images can be upscaled")
+	// ErrUpscaleTooLarge is returned when the result would exceed the
+	// configured size limit
+	ErrUpscaleTooLarge = errors.New("upscaled image would be too large")
+)
+
+// ValidUpscaleFactor reports whether factor is a supported upscale factor
+func ValidUpscaleFactor(factor int) bool {
+	return factor == 2 || factor == 4
+}
+
+// Upscaler produces a higher-resolution copy of an image
+type Upscaler interface {
+	Upscale(ctx context.Context, imagePath string, factor int) (image.Image, error)
+}
+
+// ResampleUpscaler is the built-in upscaler: Lanczos resampling followed by
+// light sharpening. It adds no detail but needs no external service.
+type ResampleUpscaler struct{}
+
+// Upscale resamples the image to factor times its size
+func (ResampleUpscaler) Upscale(ctx context.Context, imagePath string, factor int) (image.Image, error) {
+	src, err := imaging.Open(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	bounds := src.Bounds()
+	resized := imaging.Resize(src, bounds.Dx()*factor, bounds.Dy()*factor, imaging.Lanczos)
+	return imaging.Sharpen(resized, 0.5), nil
+}
+
+// HTTPUpscaler delegates upscaling to an external service, e.g. a
+// super-resolution model server. The image is POSTed as the raw request body
+// with the factor as a query parameter; the response must be the upscaled
+// image.
+type HTTPUpscaler struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewHTTPUpscaler creates an upscaler calling the service at serviceURL
+func NewHTTPUpscaler(serviceURL string, timeout time.Duration) *HTTPUpscaler {
+	return &HTTPUpscaler{
+		url:        serviceURL,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Upscale sends the image to the upscaling service
+func (u *HTTPUpscaler) Upscale(ctx context.Context, imagePath string, factor int) (image.Image, error) {
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	endpoint, err := url.Parse(u.url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upscaler URL: %w", err)
+	}
+	query := endpoint.Query()
+	query.Set("factor", strconv.Itoa(factor))
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upscale request: %w", err)
+	}
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(imagePath)))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call upscaler: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("upscaler returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode upscaled image: %w", err)
+	}
+	return img, nil
+}
+
+// UpscaleResult describes a stored upscaled rendition
+type UpscaleResult struct {
+	Factor int    `json:"factor"`
+	Path   string `json:"path"` // data-relative
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// UpscaleService creates upscaled renditions of 2D images, stored next to
+// the original as <name>_x<factor><ext> and linked in the index
+type UpscaleService struct {
+	imageService   *ImageService
+	storageService *StorageService
+	upscaler       Upscaler
+	maxMegapixels  float64
+	logger         *logrus.Logger
+	mutex          sync.Mutex
+}
+
+// NewUpscaleService creates an upscale service. Results larger than
+// maxMegapixels are refused.
+func NewUpscaleService(image *ImageService, storage *StorageService, upscaler Upscaler, maxMegapixels float64, logger *logrus.Logger) *UpscaleService {
+	return &UpscaleService{
+		imageService:   image,
+		storageService: storage,
+		upscaler:       upscaler,
+		maxMegapixels:  maxMegapixels,
+		logger:         logger,
+	}
+}
+
+// upscalePath derives the path of an upscaled rendition from the original.
+// Formats imaging can't write are stored as PNG.
+func upscalePath(imagePath string, factor int) string {
+	ext := filepath.Ext(imagePath)
+	outExt := ext
+	if _, err := imaging.FormatFromExtension(ext); err != nil {
+		outExt = ".png"
+	}
+	return fmt.Sprintf("%s_x%d%s", imagePath[:len(imagePath)-len(ext)], factor, outExt)
+}
+
+// Upscale creates the rendition of an image at factor and records it in the
+// index. An existing rendition that is newer than the original is reused.
+func (s *UpscaleService) Upscale(ctx context.Context, img *ImageMetadata, factor int) (*UpscaleResult, error) {
+	if !ValidUpscaleFactor(factor) {
+		return nil, ErrInvalidUpscaleFactor
+	}
+	if img.FilePath == "" {
+		return nil, ErrUpscaleUnsupported
+	}
+
+	sourcePath, err := s.storageService.StoredPath(img.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	sourceInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat original: %w", err)
+	}
+
+	// Entries indexed before dimensions were recorded are measured here
+	width, height := img.Width, img.Height
+	if width == 0 || height == 0 {
+		if width, height, err = s.storageService.GetImageDimensions(sourcePath); err != nil {
+			return nil, err
+		}
+	}
+	width, height = width*factor, height*factor
+	if megapixels, _ := models.DimensionStats(width, height); megapixels > s.maxMegapixels {
+		return nil, fmt.Errorf("%w: %dx%d exceeds %.0f megapixels", ErrUpscaleTooLarge, width, height, s.maxMegapixels)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	relPath := upscalePath(img.FilePath, factor)
+	outPath := upscalePath(sourcePath, factor)
+	if info, err := os.Stat(outPath); err != nil || info.ModTime().Before(sourceInfo.ModTime()) {
+		upscaled, err := s.upscaler.Upscale(ctx, sourcePath, factor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upscale image: %w", err)
+		}
+		err = replaceFile(outPath, func(tmpPath string) error {
+			return imaging.Save(upscaled, tmpPath, imaging.JPEGQuality(92))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to save upscaled image: %w", err)
+		}
+		s.logger.Infof("Upscaled %s by %dx", img.ID, factor)
+	}
+
+	width, height, err = s.storageService.GetImageDimensions(outPath)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%dx", factor)
+	if img.Upscales[key] != relPath {
+		update := ImageUpdate{Upscales: map[string]string{key: relPath}}
+		if _, err := s.imageService.UpdateImage(img.ID, 0, update); err != nil {
+			return nil, fmt.Errorf("failed to link upscaled image: %w", err)
+		}
+	}
+
+	return &UpscaleResult{
+		Factor: factor,
+		Path:   relPath,
+		URL:    DataURL(relPath),
+		Width:  width,
+		Height: height,
+	}, nil
+}
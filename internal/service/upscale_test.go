@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestUpscalePath(t *testing.T) {
+	if got := upscalePath("categories/art/a.jpg", 2); got != "categories/art/a_x2.jpg" {
+		t.Errorf("unexpected path %s", got)
+	}
+	if got := upscalePath("categories/art/a.webp", 4); got != "categories/art/a_x4.png" {
+		t.Errorf("expected PNG for unwritable formats, got %s", got)
+	}
+}
+
+func TestUpscaleService(t *testing.T) {
+	dataDir := t.TempDir()
+	logger := logrus.New()
+	index := NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	fileRel := "categories/art/ref.png"
+	filePath := filepath.Join(dataDir, filepath.FromSlash(fileRel))
+	os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err := imaging.Save(imaging.New(30, 20, color.White), filePath); err != nil {
+		t.Fatal(err)
+	}
+	img := &models.Image{ID: "ref", Title: "Ref", Artist: "A", Category: "art", Type: models.ImageType2D, UploadedAt: time.Now(), FilePath: fileRel}
+	img.SetDimensions(30, 20)
+	if err := index.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	storage := NewStorageService(dataDir)
+	svc := NewUpscaleService(NewImageService(storage, nil, index, nil, logger), storage, ResampleUpscaler{}, 0.01, logger)
+
+	metadata, _ := index.GetImageByID("ref")
+	result, err := svc.Upscale(context.Background(), metadata, 4)
+	if err != nil {
+		t.Fatalf("Upscale failed: %v", err)
+	}
+	if result.Width != 120 || result.Height != 80 || result.Path != "categories/art/ref_x4.png" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	linked, _ := index.GetImageByID("ref")
+	if linked.Upscales["4x"] != result.Path || linked.Revision != 2 {
+		t.Errorf("expected 4x rendition linked at revision 2, got %v (revision %d)", linked.Upscales, linked.Revision)
+	}
+
+	if _, err := svc.Upscale(context.Background(), metadata, 3); !errors.Is(err, ErrInvalidUpscaleFactor) {
+		t.Errorf("expected ErrInvalidUpscaleFactor, got %v", err)
+	}
+	// 120x80 fits in 0.01 megapixels but not in 0.005
+	svc.maxMegapixels = 0.005
+	if _, err := svc.Upscale(context.Background(), metadata, 4); !errors.Is(err, ErrUpscaleTooLarge) {
+		t.Errorf("expected ErrUpscaleTooLarge, got %v", err)
+	}
+	if _, err := svc.Upscale(context.Background(), &ImageMetadata{ID: "obj", Type: string(models.ImageType3D)}, 2); !errors.Is(err, ErrUpscaleUnsupported) {
+		t.Errorf("expected ErrUpscaleUnsupported, got %v", err)
+	}
+}
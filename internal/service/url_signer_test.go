@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner_SignAndVerify(t *testing.T) {
+	signer := NewURLSigner("secret", time.Hour)
+	now := time.Now()
+	relPath := "categories/animals/img 1.jpg"
+
+	signed, expires := signer.URL(relPath, 0, now)
+	if !strings.HasPrefix(signed, "/data/categories/animals/img%201.jpg?") {
+		t.Fatalf("unexpected URL %s", signed)
+	}
+	if d := expires.Sub(now); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expected the default TTL, got %v", d)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+
+	if err := signer.Verify(relPath, query, now); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+	if err := signer.Verify("categories/animals/img-2.jpg", query, now); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("expected another path to fail, got %v", err)
+	}
+	if err := NewURLSigner("other", time.Hour).Verify(relPath, query, now); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("expected another secret to fail, got %v", err)
+	}
+	if err := signer.Verify(relPath, query, now.Add(2*time.Hour)); !errors.Is(err, ErrSignatureExpired) {
+		t.Errorf("expected an expired signature, got %v", err)
+	}
+
+	// Extending the expiry invalidates the signature
+	query.Set("expires", "9999999999")
+	if err := signer.Verify(relPath, query, now); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("expected a changed expiry to fail, got %v", err)
+	}
+	if err := signer.Verify(relPath, url.Values{}, now); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("expected a missing signature to fail, got %v", err)
+	}
+}
+
+func TestURLSigner_TTLAndDisabled(t *testing.T) {
+	now := time.Now()
+	if _, expires := NewURLSigner("secret", time.Hour).URL("categories/a/b.jpg", 30*24*time.Hour, now); expires.Sub(now) > MaxSignedURLTTL {
+		t.Errorf("expected the TTL to be capped, got %v", expires.Sub(now))
+	}
+
+	var nilSigner *URLSigner
+	for _, signer := range []*URLSigner{NewURLSigner("", time.Hour), nilSigner} {
+		if signer.Enabled() {
+			t.Error("expected signing to be disabled")
+		}
+		if signed, expires := signer.URL("categories/a/b.jpg", 0, now); signed != "/data/categories/a/b.jpg" || !expires.IsZero() {
+			t.Errorf("expected a plain URL, got %s %v", signed, expires)
+		}
+	}
+}
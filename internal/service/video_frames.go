@@ -0,0 +1,135 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// DefaultClipFrames is how many frames are extracted from a turntable clip
+	// unless the upload asks for another count
+	DefaultClipFrames = 8
+	// MinClipFrames and MaxClipFrames bound the frames extracted from a clip
+	MinClipFrames = 4
+	MaxClipFrames = 24
+
+	// ClipView is the upload field (and temp file name) of a turntable clip
+	ClipView = "clip"
+
+	// frameViewPrefix names the views extracted from a clip after the front
+	// one, by rotation angle (e.g. angle-045)
+	frameViewPrefix = "angle-"
+)
+
+// ErrInvalidFrameCount is returned for frame counts outside the allowed range
+var ErrInvalidFrameCount = fmt.Errorf("frame count must be between %d and %d", MinClipFrames, MaxClipFrames)
+
+// FrameExtractor pulls evenly spaced frames out of a video clip
+type FrameExtractor interface {
+	ExtractFrames(ctx context.Context, clipPath string, count int) ([]image.Image, error)
+}
+
+// FFmpegFrameExtractor extracts frames with the ffmpeg and ffprobe binaries
+type FFmpegFrameExtractor struct {
+	ffmpeg  string
+	ffprobe string
+}
+
+// NewFFmpegFrameExtractor creates an extractor using the given binaries
+// (names on PATH or full paths)
+func NewFFmpegFrameExtractor(ffmpeg, ffprobe string) *FFmpegFrameExtractor {
+	return &FFmpegFrameExtractor{ffmpeg: ffmpeg, ffprobe: ffprobe}
+}
+
+// ExtractFrames grabs count frames spread evenly over the clip, each from the
+// middle of its slice of the clip so the first and last frames aren't the
+// (often identical) start and end of the loop
+func (e *FFmpegFrameExtractor) ExtractFrames(ctx context.Context, clipPath string, count int) ([]image.Image, error) {
+	duration, err := e.duration(ctx, clipPath)
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]image.Image, 0, count)
+	for i := 0; i < count; i++ {
+		at := duration * (float64(i) + 0.5) / float64(count)
+		cmd := exec.CommandContext(ctx, e.ffmpeg,
+			"-v", "error",
+			"-ss", strconv.FormatFloat(at, 'f', 3, 64),
+			"-i", clipPath,
+			"-frames:v", "1",
+			"-f", "image2pipe", "-vcodec", "png", "-",
+		)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("ffmpeg failed on frame %d: %w: %s", i+1, err, strings.TrimSpace(stderr.String()))
+		}
+
+		frame, err := png.Decode(&stdout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode frame %d: %w", i+1, err)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// duration returns the length of a clip in seconds
+func (e *FFmpegFrameExtractor) duration(ctx context.Context, clipPath string) (float64, error) {
+	out, err := exec.CommandContext(ctx, e.ffprobe,
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		clipPath,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || duration <= 0 {
+		return 0, errors.New("clip has no readable duration")
+	}
+	return duration, nil
+}
+
+// FrameViewName returns the view name of frame i of count evenly spaced
+// turntable frames: the first frame is the front view, the others are named
+// by their rotation angle
+func FrameViewName(i, count int) string {
+	if i == 0 {
+		return "front"
+	}
+	return fmt.Sprintf("%s%03d", frameViewPrefix, i*360/count)
+}
+
+// isFrameView reports whether a view was extracted from a turntable clip
+func isFrameView(view string) bool {
+	return strings.HasPrefix(view, frameViewPrefix)
+}
+
+// SaveClipFrames stores frames extracted from a clip as PNG views next to it
+// and returns the view paths
+func (s *StorageService) SaveClipFrames(clipPath string, frames []image.Image) (map[string]string, error) {
+	views := make(map[string]string, len(frames))
+	for i, frame := range frames {
+		view := FrameViewName(i, len(frames))
+		viewPath := filepath.Join(filepath.Dir(clipPath), view+".png")
+		if err := imaging.Save(frame, viewPath); err != nil {
+			return nil, fmt.Errorf("failed to save %s frame: %w", view, err)
+		}
+		views[view] = viewPath
+	}
+	return views, nil
+}
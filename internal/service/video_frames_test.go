@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// fakeExtractor returns count solid frames with increasing brightness
+type fakeExtractor struct{}
+
+func (fakeExtractor) ExtractFrames(ctx context.Context, clipPath string, count int) ([]image.Image, error) {
+	frames := make([]image.Image, count)
+	for i := range frames {
+		frames[i] = imaging.New(32, 24, color.Gray{Y: uint8(i * 20)})
+	}
+	return frames, nil
+}
+
+func TestFrameViewName(t *testing.T) {
+	var names []string
+	for i := 0; i < 8; i++ {
+		names = append(names, FrameViewName(i, 8))
+	}
+	want := "front,angle-045,angle-090,angle-135,angle-180,angle-225,angle-270,angle-315"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestClipFramesToViews(t *testing.T) {
+	dataDir := t.TempDir()
+	storage := NewStorageService(dataDir)
+	if err := storage.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	objectDir := filepath.Join(dataDir, "temp", "obj1")
+	os.MkdirAll(objectDir, 0755)
+	clipPath := filepath.Join(objectDir, "clip.mp4")
+	os.WriteFile(clipPath, []byte("video"), 0644)
+	os.WriteFile(filepath.Join(objectDir, "model.glb"), []byte("model"), 0644)
+
+	imageService := NewImageService(storage, nil, NewIndexService(dataDir), nil, logrus.New())
+	job := &models.UploadJob{ImageID: "obj1", Type: models.ImageType3D, ClipPath: clipPath, ClipFrames: 4}
+
+	if _, err := imageService.extractClipFrames(context.Background(), job); err == nil {
+		t.Error("expected an error without a frame extractor")
+	}
+	imageService.SetFrameExtractor(fakeExtractor{})
+
+	views, err := imageService.extractClipFrames(context.Background(), job)
+	if err != nil {
+		t.Fatalf("extractClipFrames failed: %v", err)
+	}
+	if len(views) != 4 || views["front"] == "" || views["angle-270"] == "" {
+		t.Fatalf("unexpected views %v", views)
+	}
+
+	// Frames make a turntable in rotation order
+	if got := strings.Join(turntableOrder(views), ","); got != "front,angle-090,angle-180,angle-270" {
+		t.Errorf("unexpected turntable order %s", got)
+	}
+	if _, err := storage.GenerateTurntable(views); err != nil {
+		t.Errorf("GenerateTurntable failed: %v", err)
+	}
+
+	// Frames (but not the clip or thumbnails) are picked up as views on move
+	if _, err := storage.GenerateThumbnails3D(views); err != nil {
+		t.Fatalf("GenerateThumbnails3D failed: %v", err)
+	}
+	_, _, moved, err := storage.Move3DToCategory("obj1", "", Placement{Category: "products"})
+	if err != nil {
+		t.Fatalf("Move3DToCategory failed: %v", err)
+	}
+	if len(moved) != 4 || moved["angle-090"] != filepath.Join("categories", "products", "obj1", "angle-090.png") {
+		t.Errorf("unexpected moved views %v", moved)
+	}
+}
+
+func TestParseImageSection_FrameViewNames(t *testing.T) {
+	section := "**Type:** 3D\n**Views:**\n- front: categories/p/o/front.png\n- angle-090: categories/p/o/angle-090.png\n**Total File Size:** 1.0 MB (2 views)\n"
+	views := parseImageSection("obj1", section).Views
+	if views["angle-090"] != "categories/p/o/angle-090.png" || len(views) != 2 {
+		t.Errorf("unexpected views %v", views)
+	}
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// Vocabulary fields: the analysis values mapped to stable codes
+const (
+	VocabularySceneType = "scene_type"
+	VocabularyMood      = "mood"
+	VocabularyStyle     = "style"
+)
+
+// VocabularyOther is the code of values no term matches
+const VocabularyOther = "other"
+
+// defaultLanguage is used for labels when the caller accepts no language
+// the vocabulary has
+const defaultLanguage = "en"
+
+// VocabularyTerm is one entry of the controlled vocabulary: a stable code,
+// the English words an AI value is matched on, and labels by language
+type VocabularyTerm struct {
+	Code     string            `json:"code"`
+	Synonyms []string          `json:"synonyms"`
+	Labels   map[string]string `json:"labels"` // language (en, de, ...) -> display name
+}
+
+// Vocabulary maps the free-form scene type, mood and style of AI analyses to
+// a fixed set of codes with localized labels. A value gets the term whose
+// code or synonym appears first in it, so "calm, slightly melancholic" is
+// calm; a value without any is other.
+type Vocabulary struct {
+	fields    map[string][]VocabularyTerm
+	other     map[string]string // language -> label of other
+	languages map[string]bool
+}
+
+// NewVocabulary creates a vocabulary from terms by field. other labels the
+// values no term matches, by language.
+func NewVocabulary(fields map[string][]VocabularyTerm, other map[string]string) *Vocabulary {
+	v := &Vocabulary{fields: fields, other: other, languages: make(map[string]bool)}
+	for language := range other {
+		v.languages[language] = true
+	}
+	for _, terms := range fields {
+		for i := range terms {
+			terms[i].Code = strings.ToLower(terms[i].Code)
+			for language := range terms[i].Labels {
+				v.languages[language] = true
+			}
+		}
+	}
+	return v
+}
+
+// LoadVocabulary reads a vocabulary from a JSON file keyed by field (see
+// VocabularyTerm). Fields the file leaves out keep their built-in terms. An
+// empty path loads the built-in vocabulary.
+func LoadVocabulary(path string) (*Vocabulary, error) {
+	fields := defaultVocabularyFields()
+	if path == "" {
+		return NewVocabulary(fields, defaultOtherLabels()), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vocabulary: %w", err)
+	}
+
+	var custom map[string][]VocabularyTerm
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("failed to parse vocabulary: %w", err)
+	}
+	for field, terms := range custom {
+		if _, ok := fields[field]; !ok {
+			return nil, fmt.Errorf("unknown vocabulary field %q (use scene_type, mood or style)", field)
+		}
+		for _, term := range terms {
+			if term.Code == "" || term.Labels[defaultLanguage] == "" {
+				return nil, fmt.Errorf("vocabulary field %s: every term needs a code and an en label", field)
+			}
+		}
+		fields[field] = terms
+	}
+	return NewVocabulary(fields, defaultOtherLabels()), nil
+}
+
+// DefaultVocabulary returns the built-in vocabulary
+func DefaultVocabulary() *Vocabulary {
+	v, _ := LoadVocabulary("")
+	return v
+}
+
+// Normalize returns the code of a value of field: the term that matches
+// first, other if none does, empty for an empty value
+func (v *Vocabulary) Normalize(field, value string) string {
+	words := " " + strings.Join(strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ") + " "
+	if strings.TrimSpace(words) == "" {
+		return ""
+	}
+
+	code, first := VocabularyOther, len(words)
+	for _, term := range v.fields[field] {
+		for _, word := range append([]string{strings.ReplaceAll(term.Code, "_", " ")}, term.Synonyms...) {
+			if i := strings.Index(words, " "+strings.ToLower(word)+" "); i >= 0 && i < first {
+				code, first = term.Code, i
+			}
+		}
+	}
+	return code
+}
+
+// Language picks the language of the labels from the caller's preferences,
+// most preferred first
+func (v *Vocabulary) Language(preferred []string) string {
+	for _, language := range preferred {
+		if v.languages[language] {
+			return language
+		}
+	}
+	return defaultLanguage
+}
+
+// Label returns the display name of a code in a language, falling back to
+// English and then to the code
+func (v *Vocabulary) Label(field, code, language string) string {
+	var labels map[string]string
+	if code == VocabularyOther {
+		labels = v.other
+	}
+	for _, term := range v.fields[field] {
+		if term.Code == code {
+			labels = term.Labels
+			break
+		}
+	}
+	for _, l := range []string{language, defaultLanguage} {
+		if label := labels[l]; label != "" {
+			return label
+		}
+	}
+	return code
+}
+
+// Terms maps the scene type, mood and style of an analysis to the
+// vocabulary, labeled in the first preferred language it has. It returns nil
+// when the analysis has none of them.
+func (v *Vocabulary) Terms(analysis *models.AIAnalysis, preferred []string) *models.AnalysisTerms {
+	language := v.Language(preferred)
+	term := func(field, value string) *models.Term {
+		code := v.Normalize(field, value)
+		if code == "" {
+			return nil
+		}
+		return &models.Term{Code: code, Label: v.Label(field, code, language)}
+	}
+
+	terms := &models.AnalysisTerms{
+		Language:  language,
+		SceneType: term(VocabularySceneType, analysis.SceneType),
+		Mood:      term(VocabularyMood, analysis.Mood),
+		Style:     term(VocabularyStyle, analysis.Style),
+	}
+	if terms.SceneType == nil && terms.Mood == nil && terms.Style == nil {
+		return nil
+	}
+	return terms
+}
+
+// ParseAcceptLanguage returns the primary language subtags of an
+// Accept-Language header, most preferred first ("de-CH, en;q=0.8" gives de,
+// en). Languages with q=0 are left out.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		language string
+		q        float64
+	}
+	var accepted []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if language == "" || language == "*" || q <= 0 {
+			continue
+		}
+		accepted = append(accepted, weighted{language, q})
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+
+	languages := make([]string, 0, len(accepted))
+	for _, a := range accepted {
+		languages = append(languages, a.language)
+	}
+	return languages
+}
+
+func defaultOtherLabels() map[string]string {
+	return map[string]string{"en": "Other", "de": "Sonstiges", "fr": "Autre", "es": "Otro"}
+}
+
+// defaultVocabularyFields is the built-in vocabulary, labeled in English,
+// German, French and Spanish
+func defaultVocabularyFields() map[string][]VocabularyTerm {
+	term := func(code string, synonyms []string, en, de, fr, es string) VocabularyTerm {
+		return VocabularyTerm{Code: code, Synonyms: synonyms, Labels: map[string]string{"en": en, "de": de, "fr": fr, "es": es}}
+	}
+	return map[string][]VocabularyTerm{
+		VocabularySceneType: {
+			term("landscape", []string{"nature", "outdoor", "outdoors", "mountain", "mountains", "seascape", "countryside", "scenery"}, "Landscape", "Landschaft", "Paysage", "Paisaje"),
+			term("urban", []string{"city", "cityscape", "street", "architecture", "building", "buildings", "skyline"}, "Urban", "Stadt", "Urbain", "Urbano"),
+			term("interior", []string{"indoor", "indoors", "room", "office", "kitchen", "studio"}, "Interior", "Innenraum", "Intérieur", "Interior"),
+			term("portrait", []string{"headshot", "person", "people", "selfie", "group"}, "Portrait", "Porträt", "Portrait", "Retrato"),
+			term("wildlife", []string{"animal", "animals", "pet", "pets", "bird", "birds"}, "Wildlife", "Tiere", "Faune", "Fauna"),
+			term("food", []string{"meal", "dish", "drink", "cuisine"}, "Food", "Essen", "Cuisine", "Comida"),
+			term("product", []string{"still life", "object", "objects", "packshot"}, "Product", "Produkt", "Produit", "Producto"),
+			term("event", []string{"party", "concert", "wedding", "sports", "sport", "festival", "ceremony"}, "Event", "Veranstaltung", "Événement", "Evento"),
+			term("abstract", []string{"pattern", "texture", "graphic"}, "Abstract", "Abstrakt", "Abstrait", "Abstracto"),
+		},
+		VocabularyMood: {
+			term("calm", []string{"serene", "peaceful", "tranquil", "relaxed", "relaxing", "quiet", "calming"}, "Calm", "Ruhig", "Calme", "Tranquilo"),
+			term("joyful", []string{"happy", "cheerful", "playful", "fun", "upbeat", "lively", "festive"}, "Joyful", "Fröhlich", "Joyeux", "Alegre"),
+			term("energetic", []string{"dynamic", "vibrant", "exciting", "energizing", "active"}, "Energetic", "Energisch", "Énergique", "Enérgico"),
+			term("romantic", []string{"warm", "intimate", "tender", "cozy", "cosy", "dreamy"}, "Romantic", "Romantisch", "Romantique", "Romántico"),
+			term("melancholic", []string{"sad", "melancholy", "somber", "sombre", "gloomy", "lonely", "nostalgic", "wistful"}, "Melancholic", "Melancholisch", "Mélancolique", "Melancólico"),
+			term("dramatic", []string{"intense", "powerful", "epic", "bold", "striking"}, "Dramatic", "Dramatisch", "Dramatique", "Dramático"),
+			term("mysterious", []string{"eerie", "dark", "moody", "ominous", "haunting", "mystical"}, "Mysterious", "Geheimnisvoll", "Mystérieux", "Misterioso"),
+			term("neutral", []string{"informative", "documentary", "professional", "clinical"}, "Neutral", "Neutral", "Neutre", "Neutral"),
+		},
+		VocabularyStyle: {
+			term("photographic", []string{"photo", "photograph", "photography", "realistic", "photorealistic", "documentary"}, "Photographic", "Fotografisch", "Photographique", "Fotográfico"),
+			term("illustration", []string{"illustrated", "drawing", "sketch", "cartoon", "comic", "anime", "line art"}, "Illustration", "Illustration", "Illustration", "Ilustración"),
+			term("painting", []string{"painterly", "watercolor", "watercolour", "oil", "acrylic", "impressionist", "impressionistic"}, "Painting", "Malerei", "Peinture", "Pintura"),
+			term("digital_art", []string{"digital", "cgi", "render", "rendered", "3d", "concept art", "pixel art"}, "Digital art", "Digitale Kunst", "Art numérique", "Arte digital"),
+			term("minimalist", []string{"minimal", "minimalism", "clean", "flat"}, "Minimalist", "Minimalistisch", "Minimaliste", "Minimalista"),
+			term("vintage", []string{"retro", "film", "analog", "sepia", "classic"}, "Vintage", "Vintage", "Vintage", "Vintage"),
+			term("abstract", []string{"surreal", "surrealist", "geometric"}, "Abstract", "Abstrakt", "Abstrait", "Abstracto"),
+			term("graphic_design", []string{"graphic", "typography", "logo", "poster", "infographic"}, "Graphic design", "Grafikdesign", "Design graphique", "Diseño gráfico"),
+		},
+	}
+}
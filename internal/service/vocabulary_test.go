@@ -0,0 +1,87 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestVocabulary_Normalize(t *testing.T) {
+	v := DefaultVocabulary()
+	for _, tc := range []struct {
+		field, value, want string
+	}{
+		{VocabularyMood, "Serene, slightly nostalgic", "calm"},
+		{VocabularyMood, "nostalgic yet peaceful", "melancholic"},
+		{VocabularyMood, "Bittersweet", VocabularyOther},
+		{VocabularyMood, " ", ""},
+		{VocabularyStyle, "Digital art", "digital_art"},
+		{VocabularyStyle, "Photorealistic 3D render", "photographic"},
+		{VocabularySceneType, "still life with fruit", "product"},
+		{VocabularySceneType, "Cityscape at night", "urban"},
+	} {
+		if got := v.Normalize(tc.field, tc.value); got != tc.want {
+			t.Errorf("Normalize(%s, %q) = %q, want %q", tc.field, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestVocabulary_Terms(t *testing.T) {
+	v := DefaultVocabulary()
+	analysis := &models.AIAnalysis{SceneType: "mountain landscape", Mood: "eerie", Style: "something new"}
+
+	terms := v.Terms(analysis, ParseAcceptLanguage("nl, de-CH;q=0.9, en;q=0.8"))
+	want := &models.AnalysisTerms{
+		Language:  "de",
+		SceneType: &models.Term{Code: "landscape", Label: "Landschaft"},
+		Mood:      &models.Term{Code: "mysterious", Label: "Geheimnisvoll"},
+		Style:     &models.Term{Code: VocabularyOther, Label: "Sonstiges"},
+	}
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("unexpected terms %+v", terms)
+	}
+	if terms := v.Terms(analysis, nil); terms.Language != "en" || terms.Mood.Label != "Mysterious" {
+		t.Errorf("expected English labels by default, got %+v", terms)
+	}
+	if terms := v.Terms(&models.AIAnalysis{Description: "A cat"}, nil); terms != nil {
+		t.Errorf("expected no terms without values, got %+v", terms)
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	got := ParseAcceptLanguage("fr-CA;q=0.5, *;q=0.1, es;q=0, DE , en;q=bad")
+	if want := []string{"de", "fr"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAcceptLanguage = %v, want %v", got, want)
+	}
+}
+
+func TestLoadVocabulary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vocabulary.json")
+	custom := `{"mood": [{"code": "festive", "synonyms": ["party"], "labels": {"en": "Festive", "nl": "Feestelijk"}}]}`
+	if err := os.WriteFile(path, []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := LoadVocabulary(path)
+	if err != nil {
+		t.Fatalf("LoadVocabulary failed: %v", err)
+	}
+	if code := v.Normalize(VocabularyMood, "party vibes"); code != "festive" || v.Label(VocabularyMood, code, "nl") != "Feestelijk" {
+		t.Errorf("expected the custom mood term, got %q", code)
+	}
+	if code := v.Normalize(VocabularyMood, "calm"); code != VocabularyOther {
+		t.Errorf("expected the built-in moods to be replaced, got %q", code)
+	}
+	if code := v.Normalize(VocabularyStyle, "watercolor"); code != "painting" {
+		t.Errorf("expected the built-in styles to be kept, got %q", code)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"colour": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadVocabulary(path); err == nil {
+		t.Error("expected an unknown field to be rejected")
+	}
+}
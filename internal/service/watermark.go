@@ -0,0 +1,233 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// watermarkTextWidth is the share of the image width covered by text
+	watermarkTextWidth = 0.6
+	// watermarkLogoWidth is the share of the image width covered by a logo
+	watermarkLogoWidth = 0.25
+)
+
+// Watermarker overlays a text or logo watermark on preview renditions.
+// Watermarked copies are cached under data/watermarked, keyed by the
+// watermark settings so changing them never serves stale copies.
+type Watermarker struct {
+	dataDir  string
+	cacheDir string
+	text     string
+	logo     image.Image
+	opacity  float64
+}
+
+// NewWatermarker creates a watermarker. An empty text and logoPath disable
+// watermarking; opacity is a percentage (1-100).
+func NewWatermarker(dataDir, text, logoPath string, opacity int) (*Watermarker, error) {
+	if opacity <= 0 || opacity > 100 {
+		return nil, fmt.Errorf("watermark opacity must be between 1 and 100, got %d", opacity)
+	}
+
+	w := &Watermarker{
+		dataDir: dataDir,
+		text:    strings.TrimSpace(text),
+		opacity: float64(opacity) / 100,
+	}
+
+	if logoPath != "" {
+		logo, err := imaging.Open(logoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open watermark logo: %w", err)
+		}
+		w.logo = logo
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d", w.text, logoPath, opacity)))
+	w.cacheDir = filepath.Join(dataDir, "watermarked", hex.EncodeToString(sum[:])[:12])
+
+	return w, nil
+}
+
+// Enabled reports whether a watermark is configured
+func (w *Watermarker) Enabled() bool {
+	return w != nil && (w.text != "" || w.logo != nil)
+}
+
+// IsPreviewRendition reports whether a stored file is a preview rendition
+// (thumbnail, square thumbnail, turntable or sprite sheet) rather than an
+// original
+func IsPreviewRendition(path string) bool {
+	base := filepath.Base(path)
+	return strings.HasSuffix(base, "_thumb.jpg") || strings.HasSuffix(base, "_square.jpg") ||
+		base == TurntableFilename || base == "sprite.jpg"
+}
+
+// Apply returns a copy of img with the watermark drawn on it
+func (w *Watermarker) Apply(img image.Image) *image.NRGBA {
+	out := imaging.Clone(img)
+	bounds := out.Bounds()
+
+	if w.text != "" {
+		mark := w.renderText(int(float64(bounds.Dx()) * watermarkTextWidth))
+		pos := image.Pt((bounds.Dx()-mark.Bounds().Dx())/2, (bounds.Dy()-mark.Bounds().Dy())/2)
+		out = imaging.Overlay(out, mark, pos, w.opacity)
+	}
+
+	if w.logo != nil {
+		logo := imaging.Resize(w.logo, int(float64(bounds.Dx())*watermarkLogoWidth), 0, imaging.Lanczos)
+		margin := bounds.Dx() / 40
+		pos := image.Pt(bounds.Dx()-logo.Bounds().Dx()-margin, bounds.Dy()-logo.Bounds().Dy()-margin)
+		out = imaging.Overlay(out, logo, pos, w.opacity)
+	}
+
+	return out
+}
+
+// renderText draws the watermark text with a dark outline, scaled to width
+func (w *Watermarker) renderText(width int) *image.NRGBA {
+	face := basicfont.Face7x13
+	textWidth := font.MeasureString(face, w.text).Ceil()
+	height := face.Metrics().Height.Ceil()
+
+	// Leave a one pixel border for the outline
+	canvas := image.NewNRGBA(image.Rect(0, 0, textWidth+2, height+2))
+	drawString := func(c color.Color, dx, dy int) {
+		d := &font.Drawer{
+			Dst:  canvas,
+			Src:  image.NewUniform(c),
+			Face: face,
+			Dot:  fixed.P(1+dx, 1+face.Metrics().Ascent.Ceil()+dy),
+		}
+		d.DrawString(w.text)
+	}
+	for _, offset := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		drawString(color.Black, offset[0], offset[1])
+	}
+	drawString(color.White, 0, 0)
+
+	if width < 1 {
+		width = 1
+	}
+	return imaging.Resize(canvas, width, 0, imaging.NearestNeighbor)
+}
+
+// WatermarkedPath returns the path of a watermarked copy of the stored file
+// at srcPath, creating or refreshing it when the source is newer
+func (w *Watermarker) WatermarkedPath(srcPath string) (string, error) {
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(w.dataDir, srcPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("path outside data directory: %s", srcPath)
+	}
+	outPath := filepath.Join(w.cacheDir, rel)
+
+	if outInfo, err := os.Stat(outPath); err == nil && !outInfo.ModTime().Before(srcInfo.ModTime()) {
+		return outPath, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create watermark cache: %w", err)
+	}
+
+	// Write to a unique temp file so concurrent requests don't collide
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), ".wm-*"+filepath.Ext(outPath))
+	if err != nil {
+		return "", fmt.Errorf("failed to create watermarked file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if strings.EqualFold(filepath.Ext(srcPath), ".gif") {
+		err = w.watermarkGIF(srcPath, tmpPath)
+	} else {
+		err = w.watermarkImage(srcPath, tmpPath)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		return "", fmt.Errorf("failed to store watermarked file: %w", err)
+	}
+	return outPath, nil
+}
+
+// watermarkImage watermarks a still image, keeping its format
+func (w *Watermarker) watermarkImage(srcPath, outPath string) error {
+	src, err := imaging.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open image: %w", err)
+	}
+
+	format, err := imaging.FormatFromFilename(srcPath)
+	if err != nil {
+		return fmt.Errorf("unsupported rendition format: %w", err)
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create watermarked file: %w", err)
+	}
+	err = imaging.Encode(out, w.Apply(src), format, imaging.JPEGQuality(85))
+	out.Close()
+	if err != nil {
+		return fmt.Errorf("failed to encode watermarked image: %w", err)
+	}
+
+	// Carry over the thumbnail's color profile
+	if profile, err := ReadColorProfile(srcPath); err == nil && profile != nil && format == imaging.JPEG {
+		return embedJPEGProfile(outPath, profile.Data)
+	}
+	return nil
+}
+
+// watermarkGIF watermarks every frame of an animation such as a turntable
+func (w *Watermarker) watermarkGIF(srcPath, outPath string) error {
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open animation: %w", err)
+	}
+	anim, err := gif.DecodeAll(in)
+	in.Close()
+	if err != nil {
+		return fmt.Errorf("failed to decode animation: %w", err)
+	}
+
+	for i, frame := range anim.Image {
+		marked := w.Apply(frame)
+		paletted := image.NewPaletted(frame.Bounds(), frame.Palette)
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), marked, marked.Bounds().Min)
+		anim.Image[i] = paletted
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create watermarked file: %w", err)
+	}
+	defer out.Close()
+
+	if err := gif.EncodeAll(out, anim); err != nil {
+		return fmt.Errorf("failed to encode watermarked animation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestWatermarker_Disabled(t *testing.T) {
+	wm, err := NewWatermarker(t.TempDir(), "", "", 40)
+	if err != nil {
+		t.Fatalf("NewWatermarker failed: %v", err)
+	}
+	if wm.Enabled() {
+		t.Error("expected watermarker without text or logo to be disabled")
+	}
+
+	var none *Watermarker
+	if none.Enabled() {
+		t.Error("expected nil watermarker to be disabled")
+	}
+
+	if _, err := NewWatermarker(t.TempDir(), "x", "", 0); err == nil {
+		t.Error("expected error for zero opacity")
+	}
+}
+
+func TestWatermarker_WatermarkedPath(t *testing.T) {
+	dataDir := t.TempDir()
+	srcPath := filepath.Join(dataDir, "categories", "animals", "cat_thumb.jpg")
+	os.MkdirAll(filepath.Dir(srcPath), 0755)
+	if err := imaging.Save(imaging.New(200, 100, color.NRGBA{40, 40, 40, 255}), srcPath); err != nil {
+		t.Fatalf("failed to save source: %v", err)
+	}
+
+	wm, err := NewWatermarker(dataDir, "PREVIEW", "", 100)
+	if err != nil {
+		t.Fatalf("NewWatermarker failed: %v", err)
+	}
+
+	marked, err := wm.WatermarkedPath(srcPath)
+	if err != nil {
+		t.Fatalf("WatermarkedPath failed: %v", err)
+	}
+	if marked == srcPath {
+		t.Fatal("expected a separate watermarked copy")
+	}
+
+	// The text is drawn across the middle of the image
+	img, err := imaging.Open(marked)
+	if err != nil {
+		t.Fatalf("failed to open watermarked copy: %v", err)
+	}
+	changed := false
+	for x := 0; x < 200 && !changed; x++ {
+		r, _, _, _ := img.At(x, 50).RGBA()
+		changed = r>>8 > 100
+	}
+	if !changed {
+		t.Error("expected watermark pixels in the middle row")
+	}
+
+	// Cached copies are reused until the source changes
+	info, _ := os.Stat(marked)
+	again, _ := wm.WatermarkedPath(srcPath)
+	if info2, _ := os.Stat(again); again != marked || !info2.ModTime().Equal(info.ModTime()) {
+		t.Error("expected cached copy to be reused")
+	}
+
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(srcPath, future, future)
+	again, _ = wm.WatermarkedPath(srcPath)
+	if info2, _ := os.Stat(again); !info2.ModTime().After(info.ModTime()) {
+		t.Error("expected copy to be regenerated after the source changed")
+	}
+}
+
+func TestIsPreviewRendition(t *testing.T) {
+	cases := map[string]bool{
+		"categories/animals/cat_thumb.jpg":       true,
+		"categories/objects/chair/turntable.gif": true,
+		"sprites/animals/sprite.jpg":             true,
+		"categories/animals/cat.jpg":             false,
+		"categories/objects/chair/front.png":     false,
+	}
+	for path, want := range cases {
+		if got := IsPreviewRendition(path); got != want {
+			t.Errorf("IsPreviewRendition(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
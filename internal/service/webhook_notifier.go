@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WebhookEvent is the JSON body posted to the event webhook
+type WebhookEvent struct {
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookNotifier posts domain events (e.g. workflow transitions) to a
+// configured URL. Delivery is asynchronous and best effort.
+type WebhookNotifier struct {
+	url        string
+	httpClient *http.Client
+	logger     *logrus.Logger
+}
+
+// NewWebhookNotifier creates a notifier; an empty url disables delivery
+func NewWebhookNotifier(url string, logger *logrus.Logger) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:        url,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+	}
+}
+
+// Notify sends an event in the background
+func (n *WebhookNotifier) Notify(event string, data interface{}) {
+	if n == nil || n.url == "" {
+		return
+	}
+
+	payload := WebhookEvent{Event: event, Timestamp: time.Now(), Data: data}
+	go func() {
+		if err := n.send(payload); err != nil {
+			n.logger.Warnf("Failed to deliver %s webhook: %v", event, err)
+		}
+	}()
+}
+
+func (n *WebhookNotifier) send(payload WebhookEvent) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	resp, err := n.httpClient.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
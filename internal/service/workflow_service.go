@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+var (
+	// ErrInvalidTransition is returned for workflow transitions that are not allowed
+	ErrInvalidTransition = errors.New("invalid workflow transition")
+	// ErrForbidden is returned when the caller's role does not permit an action
+	ErrForbidden = errors.New("insufficient role")
+)
+
+// EventWorkflowChanged is the webhook event sent after a workflow transition
+const EventWorkflowChanged = "image.workflow_changed"
+
+// WorkflowService moves images through the editorial workflow
+// (draft -> in-review -> approved/rejected), enforcing role checks and
+// announcing transitions via the webhook notifier
+type WorkflowService struct {
+	indexService *IndexService
+	imageService *ImageService
+	notifier     *WebhookNotifier
+	logger       *logrus.Logger
+}
+
+// NewWorkflowService creates a workflow service
+func NewWorkflowService(index *IndexService, image *ImageService, notifier *WebhookNotifier, logger *logrus.Logger) *WorkflowService {
+	return &WorkflowService{
+		indexService: index,
+		imageService: image,
+		notifier:     notifier,
+		logger:       logger,
+	}
+}
+
+// Transition moves an image to a new workflow state on behalf of actor
+func (s *WorkflowService) Transition(imageID, to string, actor models.Principal, comment string) (*ImageMetadata, error) {
+	current, err := s.indexService.GetImageByID(imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	minRole, ok := models.WorkflowTransitionRole(current.Workflow, to)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, current.Workflow, to)
+	}
+	if !actor.Role.AtLeast(minRole) {
+		return nil, fmt.Errorf("%w: %s -> %s requires %s", ErrForbidden, current.Workflow, to, minRole)
+	}
+
+	// Guard on the revision we checked so a concurrent transition can't be lost
+	updated, err := s.imageService.UpdateImage(imageID, current.Revision, ImageUpdate{Workflow: &to, Actor: actor.Name})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("Image %s workflow %s -> %s by %s", imageID, current.Workflow, to, actor.Name)
+	s.notifier.Notify(EventWorkflowChanged, models.WorkflowEvent{
+		ImageID: imageID,
+		From:    current.Workflow,
+		To:      to,
+		Actor:   actor.Name,
+		Comment: comment,
+	})
+
+	return updated, nil
+}
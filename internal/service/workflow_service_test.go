@@ -0,0 +1,83 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func newTestWorkflow(t *testing.T, webhookURL string) (*WorkflowService, *IndexService) {
+	t.Helper()
+	logger := logrus.New()
+	indexService := NewIndexService(t.TempDir())
+	if err := indexService.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+	img := &models.Image{ID: "a", Title: "A", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), Revision: 1}
+	if err := indexService.AppendToIndex(img); err != nil {
+		t.Fatalf("AppendToIndex failed: %v", err)
+	}
+
+	imageService := NewImageService(nil, nil, indexService, nil, logger)
+	notifier := NewWebhookNotifier(webhookURL, logger)
+	return NewWorkflowService(indexService, imageService, notifier, logger), indexService
+}
+
+func TestWorkflow_Transitions(t *testing.T) {
+	events := make(chan WebhookEvent, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event WebhookEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer server.Close()
+
+	svc, index := newTestWorkflow(t, server.URL)
+	editor := models.Principal{Name: "ed", Role: models.RoleEditor, Authenticated: true}
+	reviewer := models.Principal{Name: "rev", Role: models.RoleReviewer, Authenticated: true}
+
+	img, _ := index.GetImageByID("a")
+	if img.Workflow != models.WorkflowDraft {
+		t.Fatalf("expected new image in draft, got %q", img.Workflow)
+	}
+
+	if _, err := svc.Transition("a", models.WorkflowApproved, reviewer, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("expected ErrInvalidTransition for draft -> approved, got %v", err)
+	}
+	if _, err := svc.Transition("a", models.WorkflowInReview, editor, "ready"); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	if _, err := svc.Transition("a", models.WorkflowApproved, editor, ""); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected ErrForbidden for editor approval, got %v", err)
+	}
+	updated, err := svc.Transition("a", models.WorkflowApproved, reviewer, "")
+	if err != nil {
+		t.Fatalf("approve failed: %v", err)
+	}
+	if updated.Workflow != models.WorkflowApproved {
+		t.Errorf("expected approved, got %q", updated.Workflow)
+	}
+
+	select {
+	case event := <-events:
+		if event.Event != EventWorkflowChanged {
+			t.Errorf("unexpected event %q", event.Event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
+
+func TestWorkflow_NotFound(t *testing.T) {
+	svc, _ := newTestWorkflow(t, "")
+	admin := models.Principal{Name: "root", Role: models.RoleAdmin, Authenticated: true}
+	if _, err := svc.Transition("missing", models.WorkflowInReview, admin, ""); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("expected ErrImageNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+// ErrNoXMPSource is returned for images without an original file to put a
+// sidecar next to
+var ErrNoXMPSource = errors.New("image has no original file for a sidecar")
+
+// xmpNamespace holds the warehouse fields Adobe tools have no property for
+const xmpNamespace = "https://github.com/yourcompany/image-warehousing/ns/1.0/"
+
+// BuildXMP renders an image's metadata as an XMP packet readable by
+// Lightroom and Bridge: title, artist and description, tags and detected
+// objects as keywords, the category as a keyword hierarchy, and the license
+// as rights.
+func BuildXMP(img *ImageMetadata) []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("  <rdf:Description rdf:about=\"\"\n")
+	b.WriteString("    xmlns:dc=\"http://purl.org/dc/elements/1.1/\"\n")
+	b.WriteString("    xmlns:lr=\"http://ns.adobe.com/lightroom/1.0/\"\n")
+	b.WriteString("    xmlns:xmpRights=\"http://ns.adobe.com/xap/1.0/rights/\"\n")
+	b.WriteString("    xmlns:iw=\"" + xmpNamespace + "\"\n")
+	b.WriteString("    iw:ID=\"" + xmlEscape(img.ID) + "\"")
+	if img.Category != "" {
+		b.WriteString("\n    iw:Category=\"" + xmlEscape(img.Category) + "\"")
+	}
+	if img.Project != "" {
+		b.WriteString("\n    iw:Project=\"" + xmlEscape(img.Project) + "\"")
+	}
+	if img.ExternalID != "" {
+		b.WriteString("\n    iw:ExternalID=\"" + xmlEscape(img.ExternalID) + "\"")
+	}
+	b.WriteString(">\n")
+
+	writeXMPAlt(&b, "dc:title", img.Title)
+	if img.Artist != "" {
+		writeXMPList(&b, "dc:creator", "rdf:Seq", []string{img.Artist})
+	}
+	writeXMPAlt(&b, "dc:description", img.Description)
+	writeXMPList(&b, "dc:subject", "rdf:Bag", xmpKeywords(img))
+	if img.Category != "" {
+		writeXMPList(&b, "lr:hierarchicalSubject", "rdf:Bag", []string{strings.ReplaceAll(img.Category, "/", "|")})
+	}
+	if !img.License.IsZero() {
+		rights := img.License.Type
+		if img.License.RightsHolder != "" {
+			rights = strings.TrimSpace("© " + img.License.RightsHolder + " " + rights)
+		}
+		writeXMPAlt(&b, "dc:rights", rights)
+		writeXMPAlt(&b, "xmpRights:UsageTerms", img.License.Restrictions)
+	}
+
+	b.WriteString("  </rdf:Description>\n")
+	b.WriteString(" </rdf:RDF>\n")
+	b.WriteString("</x:xmpmeta>\n")
+	b.WriteString("<?xpacket end=\"w\"?>\n")
+	return b.Bytes()
+}
+
+// xmpKeywords returns the manual tags, detected objects and category
+// levels, without case-insensitive duplicates
+func xmpKeywords(img *ImageMetadata) []string {
+	var keywords []string
+	seen := make(map[string]bool)
+	add := func(values ...string) {
+		for _, value := range values {
+			value = strings.TrimSpace(value)
+			if value == "" || seen[strings.ToLower(value)] {
+				continue
+			}
+			seen[strings.ToLower(value)] = true
+			keywords = append(keywords, value)
+		}
+	}
+	add(img.Tags...)
+	add(img.Objects...)
+	if img.Category != "" {
+		add(strings.Split(img.Category, "/")...)
+	}
+	return keywords
+}
+
+// writeXMPAlt writes a language alternative (the form of dc:title and
+// dc:description), skipping empty values
+func writeXMPAlt(b *bytes.Buffer, property, value string) {
+	if value == "" {
+		return
+	}
+	b.WriteString("   <" + property + ">\n")
+	b.WriteString("    <rdf:Alt>\n")
+	b.WriteString("     <rdf:li xml:lang=\"x-default\">" + xmlEscape(value) + "</rdf:li>\n")
+	b.WriteString("    </rdf:Alt>\n")
+	b.WriteString("   </" + property + ">\n")
+}
+
+// writeXMPList writes an ordered (rdf:Seq) or unordered (rdf:Bag) array,
+// skipping empty ones
+func writeXMPList(b *bytes.Buffer, property, kind string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	b.WriteString("   <" + property + ">\n")
+	b.WriteString("    <" + kind + ">\n")
+	for _, value := range values {
+		b.WriteString("     <rdf:li>" + xmlEscape(value) + "</rdf:li>\n")
+	}
+	b.WriteString("    </" + kind + ">\n")
+	b.WriteString("   </" + property + ">\n")
+}
+
+// xmlEscape escapes a value for XML text; quotes are escaped too, so the
+// result is also safe in attributes
+func xmlEscape(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+// xmpSource returns the data-relative path of the file a sidecar belongs
+// to: the original of a 2D image or the model file of a 3D object
+func xmpSource(img *ImageMetadata) string {
+	if img.FilePath != "" {
+		return img.FilePath
+	}
+	return img.ModelFilePath
+}
+
+// xmpSidecarPath derives the sidecar path from its source path, named the
+// way Lightroom and Bridge look for it (photo.jpg -> photo.xmp)
+func xmpSidecarPath(sourcePath string) string {
+	ext := filepath.Ext(sourcePath)
+	return sourcePath[:len(sourcePath)-len(ext)] + ".xmp"
+}
+
+// XMPSidecarName is the file name of an image's sidecar, for downloads
+func XMPSidecarName(img *ImageMetadata) string {
+	name := img.OriginalFilename
+	if name == "" {
+		name = img.ModelFilename
+	}
+	if name == "" {
+		name = img.ID
+	}
+	return xmpSidecarPath(filepath.Base(name))
+}
+
+// XMPExportRequest selects the images of a bulk sidecar export. Filters
+// combine; an empty request exports every image.
+type XMPExportRequest struct {
+	IDs      []string `json:"ids,omitempty"`
+	Category string   `json:"category,omitempty"`
+	Project  string   `json:"project,omitempty"`
+}
+
+// matches reports whether an image is selected by the request
+func (r *XMPExportRequest) matches(img *ImageMetadata, ids map[string]bool) bool {
+	if len(ids) > 0 && !ids[img.ID] {
+		return false
+	}
+	if r.Category != "" && !img.InCategory(r.Category) {
+		return false
+	}
+	return r.Project == "" || img.Project == r.Project
+}
+
+// XMPService writes XMP sidecars next to the original files, so the
+// warehouse metadata is picked up when a folder is opened in Lightroom or
+// Bridge. Sidecars are written by a bulk export job, and at ingest and on
+// every edit when enabled.
+type XMPService struct {
+	indexService   *IndexService
+	imageService   *ImageService
+	storageService *StorageService
+	logger         *logrus.Logger
+}
+
+// NewXMPService creates an XMP sidecar service
+func NewXMPService(index *IndexService, image *ImageService, storage *StorageService, logger *logrus.Logger) *XMPService {
+	return &XMPService{
+		indexService:   index,
+		imageService:   image,
+		storageService: storage,
+		logger:         logger,
+	}
+}
+
+// WriteSidecar writes or replaces an image's sidecar
+func (s *XMPService) WriteSidecar(img *ImageMetadata) error {
+	source := xmpSource(img)
+	if source == "" {
+		return ErrNoXMPSource
+	}
+	sourcePath, err := s.storageService.StoredPath(source)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename, so Lightroom never reads half a sidecar
+	path := xmpSidecarPath(sourcePath)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, BuildXMP(img), 0644); err != nil {
+		return fmt.Errorf("failed to write sidecar: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write sidecar: %w", err)
+	}
+	return nil
+}
+
+// HandleIndexed writes the sidecar of a newly indexed image
+func (s *XMPService) HandleIndexed(img *models.Image) {
+	metadata, err := s.indexService.GetImageByID(img.ID)
+	if err != nil {
+		return
+	}
+	if err := s.WriteSidecar(metadata); err != nil && !errors.Is(err, ErrNoXMPSource) {
+		s.logger.Warnf("Failed to write XMP sidecar for %s: %v", img.ID, err)
+	}
+}
+
+// HandleUpdated rewrites an image's sidecar after an edit
+func (s *XMPService) HandleUpdated(img *ImageMetadata, update ImageUpdate) {
+	if err := s.WriteSidecar(img); err != nil && !errors.Is(err, ErrNoXMPSource) {
+		s.logger.Warnf("Failed to write XMP sidecar for %s: %v", img.ID, err)
+	}
+}
+
+// StartExport writes the sidecars of the selected images in the
+// background, returning the job ID
+func (s *XMPService) StartExport(req XMPExportRequest) (string, error) {
+	if req.Category != "" && !ValidCategoryPath(req.Category) {
+		return "", errors.New("invalid category")
+	}
+
+	jobID := "xmp-" + uuid.New().String()
+	s.imageService.StartBackgroundJob(jobID, models.JobKindXMPExport, "XMP sidecar export")
+
+	go func() {
+		err := s.runExport(context.Background(), jobID, req)
+		s.imageService.FinishBackgroundJob(jobID, err)
+		if err != nil {
+			s.logger.Errorf("XMP export %s failed: %v", jobID, err)
+		}
+	}()
+
+	return jobID, nil
+}
+
+func (s *XMPService) runExport(ctx context.Context, jobID string, req XMPExportRequest) error {
+	images, err := s.indexService.GetAllImages()
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	ids := make(map[string]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		ids[id] = true
+	}
+	var selected []*ImageMetadata
+	for _, img := range images {
+		if req.matches(img, ids) {
+			selected = append(selected, img)
+		}
+	}
+
+	progress := models.JobProgress{Total: len(selected)}
+	s.imageService.UpdateJobProgress(jobID, progress)
+
+	for _, img := range selected {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.WriteSidecar(img); err != nil {
+			if errors.Is(err, ErrNoXMPSource) {
+				progress.Skipped++
+			} else {
+				s.logger.Warnf("XMP export %s: failed to write sidecar for %s: %v", jobID, img.ID, err)
+				progress.Failed++
+			}
+		} else {
+			progress.Done++
+		}
+		s.imageService.UpdateJobProgress(jobID, progress)
+	}
+
+	s.logger.Infof("XMP export %s finished: %d written, %d skipped, %d failed", jobID, progress.Done, progress.Skipped, progress.Failed)
+	if progress.Failed > 0 {
+		return fmt.Errorf("%d of %d sidecars failed to write", progress.Failed, progress.Total)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourcompany/image-warehousing/internal/models"
+)
+
+func TestBuildXMP(t *testing.T) {
+	img := &ImageMetadata{
+		ID:          "abc",
+		Title:       `Cats & "Dogs"`,
+		Artist:      "Alice <alice@example.com>",
+		Category:    "animals/cats",
+		Description: "Two cats on a sofa",
+		Tags:        []string{"cute", "Sofa"},
+		Objects:     []string{"sofa", "cat"},
+		License:     &models.License{Type: "CC-BY-4.0", RightsHolder: "Studio", Restrictions: "No print"},
+	}
+	packet := BuildXMP(img)
+
+	// Well-formed XML, whatever the values contain
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	var text []string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid XML: %v\n%s", err, packet)
+		}
+		if data, ok := token.(xml.CharData); ok {
+			if value := strings.TrimSpace(string(data)); value != "" {
+				text = append(text, value)
+			}
+		}
+	}
+
+	expected := []string{
+		`Cats & "Dogs"`,
+		"Alice <alice@example.com>",
+		"Two cats on a sofa",
+		"cute", "Sofa", "cat", "animals", "cats", // keywords without duplicates
+		"animals|cats",
+		"© Studio CC-BY-4.0",
+		"No print",
+	}
+	if strings.Join(text, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected values:\n%s", strings.Join(text, "\n"))
+	}
+	if !bytes.Contains(packet, []byte(`iw:ID="abc"`)) || !bytes.Contains(packet, []byte(`iw:Category="animals/cats"`)) {
+		t.Errorf("expected warehouse ID and category attributes:\n%s", packet)
+	}
+}
+
+func TestXMPService_Export(t *testing.T) {
+	logger := logrus.New()
+	dataDir := t.TempDir()
+	index := NewIndexService(dataDir)
+	if err := index.InitializeIndex(); err != nil {
+		t.Fatalf("InitializeIndex failed: %v", err)
+	}
+
+	images := []*models.Image{
+		{ID: "a", Title: "A", Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now(), FilePath: "categories/animals/a.jpg", ManualTags: []string{"cat"}},
+		{ID: "b", Title: "B", Artist: "Alice", Category: "animals", Type: models.ImageType2D, UploadedAt: time.Now()},
+		{ID: "c", Title: "C", Artist: "Alice", Category: "landscape", Type: models.ImageType2D, UploadedAt: time.Now(), FilePath: "categories/landscape/c.jpg"},
+	}
+	for _, img := range images {
+		if img.FilePath != "" {
+			path := filepath.Join(dataDir, filepath.FromSlash(img.FilePath))
+			os.MkdirAll(filepath.Dir(path), 0755)
+			os.WriteFile(path, []byte("jpeg"), 0644)
+		}
+		if err := index.AppendToIndex(img); err != nil {
+			t.Fatalf("AppendToIndex failed: %v", err)
+		}
+	}
+
+	imageService := NewImageService(NewStorageService(dataDir), nil, index, nil, logger)
+	svc := NewXMPService(index, imageService, NewStorageService(dataDir), logger)
+
+	jobID, err := svc.StartExport(XMPExportRequest{Category: "animals"})
+	if err != nil {
+		t.Fatalf("StartExport failed: %v", err)
+	}
+	job := waitForJob(t, imageService, jobID)
+	if job.State != models.JobStateCompleted || job.Kind != models.JobKindXMPExport || job.Progress == nil ||
+		job.Progress.Done != 1 || job.Progress.Skipped != 1 {
+		t.Fatalf("unexpected job result %+v", job)
+	}
+
+	sidecar := filepath.Join(dataDir, "categories", "animals", "a.xmp")
+	content, err := os.ReadFile(sidecar)
+	if err != nil {
+		t.Fatalf("expected sidecar next to the original: %v", err)
+	}
+	if !bytes.Contains(content, []byte("<rdf:li>cat</rdf:li>")) {
+		t.Errorf("expected the tags in the sidecar:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "categories", "landscape", "c.xmp")); !os.IsNotExist(err) {
+		t.Errorf("expected no sidecar outside the exported category")
+	}
+
+	// Edits rewrite the sidecar, deletes remove it
+	description := "A cat"
+	updated, err := imageService.UpdateImage("a", 0, ImageUpdate{Description: &description})
+	if err != nil {
+		t.Fatalf("UpdateImage failed: %v", err)
+	}
+	svc.HandleUpdated(updated, ImageUpdate{Description: &description})
+	if content, _ := os.ReadFile(sidecar); !bytes.Contains(content, []byte("A cat")) {
+		t.Errorf("expected the updated description in the sidecar:\n%s", content)
+	}
+	if err := imageService.DeleteImage("a", "test"); err != nil {
+		t.Fatalf("DeleteImage failed: %v", err)
+	}
+	if _, err := os.Stat(sidecar); !os.IsNotExist(err) {
+		t.Errorf("expected the sidecar to be deleted with the image")
+	}
+
+	if _, err := svc.StartExport(XMPExportRequest{Category: "../etc"}); err == nil {
+		t.Error("expected an error for an invalid category")
+	}
+}
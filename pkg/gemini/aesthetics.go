@@ -0,0 +1,37 @@
+package gemini
+
+import "strings"
+
+// AestheticRating is the model's judgement of an image's appeal and
+// composition, each 1-10, with a short critique
+type AestheticRating struct {
+	Score       float64 `json:"score"`
+	Composition float64 `json:"composition"`
+	Critique    string  `json:"critique"`
+}
+
+// aestheticsInstructions asks the analysis prompts for an aesthetic rating
+const aestheticsInstructions = `Also rate the image as a curator would. Add an "aesthetics" object:
+{"score": overall aesthetic appeal from 1 (poor) to 10 (outstanding), "composition": framing, balance and use of space from 1 to 10, "critique": "1-2 sentences on what works and what could be better"}
+Use the whole scale; most images are average (4-6).
+
+`
+
+// SetAesthetics makes analyses include an aesthetic rating. It changes the
+// analysis prompts, and with them their prompt versions.
+func (c *Client) SetAesthetics(enabled bool) {
+	c.aesthetics = enabled
+}
+
+// analysisPrompt adds the optional instructions (aesthetic rating, category
+// prompts) to an analysis prompt
+func (c *Client) analysisPrompt(prompt string) string {
+	if c.aesthetics {
+		if base, ok := strings.CutSuffix(prompt, returnJSONOnly); ok {
+			prompt = base + aestheticsInstructions + returnJSONOnly
+		} else {
+			prompt += "\n\n" + aestheticsInstructions
+		}
+	}
+	return c.withCategoryPrompts(prompt)
+}
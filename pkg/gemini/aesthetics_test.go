@@ -0,0 +1,23 @@
+package gemini
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalysisPrompt_Aesthetics(t *testing.T) {
+	c := &Client{prompt2D: DefaultAnalysis2DPrompt}
+	before := c.PromptVersion2D()
+	if c.analysisPrompt(DefaultAnalysis2DPrompt) != DefaultAnalysis2DPrompt {
+		t.Error("expected the prompt unchanged without aesthetics")
+	}
+
+	c.SetAesthetics(true)
+	prompt := c.analysisPrompt(DefaultAnalysis2DPrompt)
+	if !strings.Contains(prompt, `"aesthetics"`) || !strings.HasSuffix(prompt, returnJSONOnly) {
+		t.Errorf("expected the aesthetics instructions ahead of the JSON-only line, got %q", prompt)
+	}
+	if c.PromptVersion2D() == before {
+		t.Error("expected the prompt version to change with aesthetics")
+	}
+}
@@ -0,0 +1,131 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CategoryPrompt specializes analysis for one primary category: extra
+// instructions and structured fields to return (name -> description)
+type CategoryPrompt struct {
+	Instructions string            `json:"instructions,omitempty"`
+	Fields       map[string]string `json:"fields,omitempty"`
+}
+
+// ExtraFields are the category-specific fields of an analysis. Models don't
+// always return strings, so numbers and lists are accepted and flattened.
+type ExtraFields map[string]string
+
+// UnmarshalJSON accepts any JSON values, joining lists with ", "
+func (e *ExtraFields) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	fields := make(ExtraFields, len(raw))
+	for name, value := range raw {
+		if text := flattenValue(value); text != "" {
+			fields[name] = text
+		}
+	}
+	*e = fields
+	return nil
+}
+
+// flattenValue renders a JSON value as text
+func flattenValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if text := flattenValue(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// SetCategoryPrompts sets the per-category prompt specializations, keyed by
+// primary category
+func (c *Client) SetCategoryPrompts(prompts map[string]CategoryPrompt) {
+	c.categoryPrompts = make(map[string]CategoryPrompt, len(prompts))
+	for category, prompt := range prompts {
+		c.categoryPrompts[strings.ToLower(category)] = prompt
+	}
+}
+
+// returnJSONOnly ends the built-in analysis prompts
+const returnJSONOnly = "IMPORTANT: Return ONLY valid JSON, no other text."
+
+// withCategoryPrompts adds the per-category instructions to an analysis
+// prompt, ahead of its closing JSON-only line if it has one
+func (c *Client) withCategoryPrompts(prompt string) string {
+	if len(c.categoryPrompts) == 0 {
+		return prompt
+	}
+
+	categories := make([]string, 0, len(c.categoryPrompts))
+	for category := range c.categoryPrompts {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	var sb strings.Builder
+	sb.WriteString("Additional instructions by primary_category (follow only those for the primary_category you chose):\n")
+	for _, category := range categories {
+		cp := c.categoryPrompts[category]
+		sb.WriteString(fmt.Sprintf("- %s:", category))
+		if cp.Instructions != "" {
+			sb.WriteString(" " + cp.Instructions)
+		}
+		if len(cp.Fields) > 0 {
+			names := make([]string, 0, len(cp.Fields))
+			for name := range cp.Fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			fields := make([]string, len(names))
+			for i, name := range names {
+				fields[i] = fmt.Sprintf("%q (%s)", name, cp.Fields[name])
+			}
+			sb.WriteString(fmt.Sprintf(" Add an \"extra\" object with string values for: %s.", strings.Join(fields, ", ")))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Leave out \"extra\" when none of these categories applies.\n\n")
+
+	if base, ok := strings.CutSuffix(prompt, returnJSONOnly); ok {
+		return base + sb.String() + returnJSONOnly
+	}
+	return prompt + "\n\n" + sb.String()
+}
+
+// categoryExtra keeps the extra fields configured for the analyzed category
+func (c *Client) categoryExtra(category string, extra ExtraFields) ExtraFields {
+	cp, ok := c.categoryPrompts[strings.ToLower(category)]
+	if !ok || len(extra) == 0 {
+		return nil
+	}
+
+	kept := make(ExtraFields)
+	for name, value := range extra {
+		if _, ok := cp.Fields[name]; ok {
+			kept[name] = value
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
@@ -0,0 +1,52 @@
+package gemini
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWithCategoryPrompts(t *testing.T) {
+	c := &Client{}
+	if got := c.withCategoryPrompts(DefaultAnalysis2DPrompt); got != DefaultAnalysis2DPrompt {
+		t.Error("expected the prompt unchanged without category prompts")
+	}
+
+	c.SetCategoryPrompts(map[string]CategoryPrompt{
+		"Products":     {Instructions: "Identify the product.", Fields: map[string]string{"brand": "brand name", "materials": "main materials"}},
+		"architecture": {Fields: map[string]string{"era": "period"}},
+	})
+	prompt := c.withCategoryPrompts(DefaultAnalysis2DPrompt)
+	if !strings.HasSuffix(prompt, returnJSONOnly) {
+		t.Error("expected the JSON-only instruction to stay last")
+	}
+	for _, want := range []string{
+		`- architecture: Add an "extra" object with string values for: "era" (period).`,
+		`- products: Identify the product. Add an "extra" object with string values for: "brand" (brand name), "materials" (main materials).`,
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected prompt to contain %q", want)
+		}
+	}
+}
+
+func TestCategoryExtra(t *testing.T) {
+	var resp Analysis2DResponse
+	body := `{"primary_category": "products", "extra": {"brand": "Acme", "materials": ["steel", "glass"], "weight": 2.5, "era": null}}`
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if resp.Extra["materials"] != "steel, glass" || resp.Extra["weight"] != "2.5" {
+		t.Errorf("unexpected extra fields %v", resp.Extra)
+	}
+
+	c := &Client{}
+	c.SetCategoryPrompts(map[string]CategoryPrompt{"products": {Fields: map[string]string{"brand": "", "materials": ""}}})
+	kept := c.categoryExtra("Products", resp.Extra)
+	if len(kept) != 2 || kept["brand"] != "Acme" {
+		t.Errorf("expected only the configured fields, got %v", kept)
+	}
+	if c.categoryExtra("animals", resp.Extra) != nil {
+		t.Error("expected no extra fields for an unconfigured category")
+	}
+}
@@ -4,47 +4,105 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
 
 type Client struct {
-	apiKey string
-	client *genai.Client
-	model  string
+	apiKey          string
+	client          *genai.Client
+	model           string
+	embeddingModel  string
+	imageEmbedding  string // multimodal model for text and images; empty embeds text only
+	prompt2D        string
+	categoryPrompts map[string]CategoryPrompt // primary category -> specialization
+	aesthetics      bool                      // ask for an aesthetic rating
+
+	// Files API (REST) for images too large to send inline
+	apiBaseURL  string
+	httpClient  *http.Client
+	inlineLimit int64
+
+	// embedContent calls the embedding API; replaced in tests
+	embedContent func(ctx context.Context, model string, parts ...genai.Part) ([]float32, error)
 }
 
+// DefaultEmbeddingModel is used for text embeddings unless overridden
+const DefaultEmbeddingModel = "text-embedding-004"
+
+// DefaultMultimodalEmbeddingModel is the usual model for image embeddings
+const DefaultMultimodalEmbeddingModel = "multimodalembedding"
+
+// EmbedInput is the content to embed: text, an image, or an image with
+// accompanying text. Images need image embeddings enabled (see
+// SetMultimodalEmbeddingModel).
+type EmbedInput struct {
+	Text      string
+	ImagePath string
+}
+
+// DefaultAnalysis2DPrompt is the prompt used to analyze 2D images unless
+// overridden (e.g. to evaluate a new prompt version)
+const DefaultAnalysis2DPrompt = `Analyze this 2D image and provide categorization + detailed analysis.
+
+Return as JSON with this structure:
+{
+  "type": "2D",
+  "primary_category": "artwork|conceptual-art|surrealism|figurines|character-design|sculpture|performance-art|animals|landscapes|portraits|3d-renders|abstract|architecture|products|uncategorized",
+  "sub_category": "narrower subject within the primary category, 1-2 words (e.g. cats, mountains, oil-painting)",
+  "description": "2-3 sentence detailed description",
+  "objects": ["object1", "object2"],
+  "colors": ["color1", "color2"],
+  "scene_type": "indoor|outdoor|studio",
+  "mood": "calm|dark|energetic|mysterious|whimsical|etc",
+  "style": "photorealistic|cartoon|3D|painting|sketch|sculpture",
+  "features": ["at least 10 descriptive tags"]
+}
+
+IMPORTANT: Return ONLY valid JSON, no other text.`
+
 // Analysis2DResponse represents the JSON response for 2D image analysis
 type Analysis2DResponse struct {
-	Type            string   `json:"type"`
-	PrimaryCategory string   `json:"primary_category"`
-	Description     string   `json:"description"`
-	Objects         []string `json:"objects"`
-	Colors          []string `json:"colors"`
-	SceneType       string   `json:"scene_type"`
-	Mood            string   `json:"mood"`
-	Style           string   `json:"style"`
-	Features        []string `json:"features"`
+	Type            string           `json:"type"`
+	PrimaryCategory string           `json:"primary_category"`
+	SubCategory     string           `json:"sub_category"`
+	Description     string           `json:"description"`
+	Objects         []string         `json:"objects"`
+	Colors          []string         `json:"colors"`
+	SceneType       string           `json:"scene_type"`
+	Mood            string           `json:"mood"`
+	Style           string           `json:"style"`
+	Features        []string         `json:"features"`
+	Extra           ExtraFields      `json:"extra,omitempty"`      // category-specific fields
+	Aesthetics      *AestheticRating `json:"aesthetics,omitempty"` // with SetAesthetics
 }
 
 // Analysis3DResponse represents the JSON response for 3D object analysis
 type Analysis3DResponse struct {
-	Type                  string   `json:"type"`
-	PrimaryCategory       string   `json:"primary_category"`
-	Description           string   `json:"description"`
-	Objects               []string `json:"objects"`
-	Colors                []string `json:"colors"`
-	Style                 string   `json:"style"`
-	Mood                  string   `json:"mood"`
-	Lighting              string   `json:"lighting"`
-	ThreeDCharacteristics string   `json:"three_d_characteristics"`
-	Features              []string `json:"features"`
-	Symmetry              string   `json:"symmetry"`
-	Complexity            string   `json:"complexity"`
+	Type                  string           `json:"type"`
+	PrimaryCategory       string           `json:"primary_category"`
+	SubCategory           string           `json:"sub_category"`
+	Description           string           `json:"description"`
+	Objects               []string         `json:"objects"`
+	Colors                []string         `json:"colors"`
+	Style                 string           `json:"style"`
+	Mood                  string           `json:"mood"`
+	Lighting              string           `json:"lighting"`
+	ThreeDCharacteristics string           `json:"three_d_characteristics"`
+	Features              []string         `json:"features"`
+	Symmetry              string           `json:"symmetry"`
+	Complexity            string           `json:"complexity"`
+	BestView              string           `json:"best_view"`            // the view that best represents the object
+	Extra                 ExtraFields      `json:"extra,omitempty"`      // category-specific fields
+	Aesthetics            *AestheticRating `json:"aesthetics,omitempty"` // with SetAesthetics
 }
 
 func NewClient(apiKey, model string) (*Client, error) {
@@ -59,11 +117,40 @@ func NewClient(apiKey, model string) (*Client, error) {
 		model = "gemini-3-flash-preview"
 	}
 
-	return &Client{
-		apiKey: apiKey,
-		client: client,
-		model:  model,
-	}, nil
+	c := &Client{
+		apiKey:         apiKey,
+		client:         client,
+		model:          model,
+		embeddingModel: DefaultEmbeddingModel,
+		prompt2D:       DefaultAnalysis2DPrompt,
+		apiBaseURL:     DefaultAPIBaseURL,
+		httpClient:     &http.Client{Timeout: 5 * time.Minute},
+		inlineLimit:    DefaultInlineLimit,
+	}
+	c.embedContent = c.genaiEmbedContent
+	return c, nil
+}
+
+// SetEmbeddingModel overrides the model used to embed text
+func (c *Client) SetEmbeddingModel(model string) {
+	if model != "" {
+		c.embeddingModel = model
+	}
+}
+
+// SetPrompt2D overrides the prompt used to analyze 2D images. The response
+// must still follow the Analysis2DResponse JSON structure.
+func (c *Client) SetPrompt2D(prompt string) {
+	if prompt != "" {
+		c.prompt2D = prompt
+	}
+}
+
+// SetMultimodalEmbeddingModel enables image embeddings with model. Text is
+// then embedded with it too, so query vectors can be compared with image
+// vectors. An empty model embeds text only.
+func (c *Client) SetMultimodalEmbeddingModel(model string) {
+	c.imageEmbedding = model
 }
 
 func (c *Client) Close() error {
@@ -110,50 +197,12 @@ func cleanMarkdownJSON(text string) string {
 
 // AnalyzeImage2D analyzes a single 2D image
 func (c *Client) AnalyzeImage2D(ctx context.Context, imagePath string) (*Analysis2DResponse, error) {
-	// Read image file
-	imgData, err := os.ReadFile(imagePath)
+	// Large images go through the Files API
+	responseText, err := c.generateWithImages(ctx, c.analysisPrompt(c.prompt2D), 0.4, []string{imagePath})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read image: %w", err)
+		return nil, err
 	}
 
-	// Detect image format from file extension
-	format := detectImageFormat(imagePath)
-
-	prompt := `Analyze this 2D image and provide categorization + detailed analysis.
-
-Return as JSON with this structure:
-{
-  "type": "2D",
-  "primary_category": "artwork|conceptual-art|surrealism|figurines|character-design|sculpture|performance-art|animals|landscapes|portraits|3d-renders|abstract|architecture|products|uncategorized",
-  "description": "2-3 sentence detailed description",
-  "objects": ["object1", "object2"],
-  "colors": ["color1", "color2"],
-  "scene_type": "indoor|outdoor|studio",
-  "mood": "calm|dark|energetic|mysterious|whimsical|etc",
-  "style": "photorealistic|cartoon|3D|painting|sketch|sculpture",
-  "features": ["at least 10 descriptive tags"]
-}
-
-IMPORTANT: Return ONLY valid JSON, no other text.`
-
-	model := c.client.GenerativeModel(c.model)
-	model.SetTemperature(0.4)
-
-	resp, err := model.GenerateContent(ctx,
-		genai.Text(prompt),
-		genai.ImageData(format, imgData),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("gemini API error: %w", err)
-	}
-
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("empty response from Gemini")
-	}
-
-	// Extract text from response
-	responseText := fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])
-
 	// Clean markdown code fences if present
 	responseText = cleanMarkdownJSON(responseText)
 
@@ -162,6 +211,7 @@ IMPORTANT: Return ONLY valid JSON, no other text.`
 	if err := json.Unmarshal([]byte(responseText), &analysis); err != nil {
 		return nil, fmt.Errorf("failed to parse Gemini response: %w\nResponse: %s", err, responseText)
 	}
+	analysis.Extra = c.categoryExtra(analysis.PrimaryCategory, analysis.Extra)
 
 	return &analysis, nil
 }
@@ -171,29 +221,36 @@ func (c *Client) AnalyzeImage3D(ctx context.Context, viewPaths map[string]string
 	// Read all surface view images (dynamically handles 4 or 6 views)
 	possibleViews := []string{"front", "back", "left", "right", "top", "bottom"}
 	views := []string{}
-	imageParts := []genai.Part{}
+	imagePaths := []string{}
 
 	// Only include views that are present
 	for _, view := range possibleViews {
 		if path, ok := viewPaths[view]; ok {
 			views = append(views, view)
+			imagePaths = append(imagePaths, path)
+		}
+	}
 
-			imgData, err := os.ReadFile(path)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read %s view: %w", view, err)
-			}
-
-			// Detect format from file extension
-			format := detectImageFormat(path)
-			imageParts = append(imageParts, genai.ImageData(format, imgData))
+	// Then any other views (e.g. frames of a turntable clip, named by
+	// angle), in name order
+	var extra []string
+	for view := range viewPaths {
+		if !slices.Contains(possibleViews, view) {
+			extra = append(extra, view)
 		}
 	}
+	sort.Strings(extra)
+	for _, view := range extra {
+		views = append(views, view)
+		imagePaths = append(imagePaths, viewPaths[view])
+	}
 
 	if len(views) == 0 {
 		return nil, fmt.Errorf("no surface views provided")
 	}
 
-	// Build dynamic prompt based on views present
+	// Build dynamic prompt based on views present (bump prompt3DRevision
+	// when changing it)
 	viewsList := ""
 	for i, view := range views {
 		viewsList += fmt.Sprintf("%d. %s view\n", i+1, view)
@@ -211,6 +268,7 @@ Return as JSON with this structure:`, len(views), viewsJoined, len(views), views
 {
   "type": "3D",
   "primary_category": "sculpture|figurines|character-design|3d-renders|products|characters|environments|architecture|vehicles|artwork|uncategorized",
+  "sub_category": "narrower subject within the primary category, 1-2 words (e.g. robots, busts, sports-cars)",
   "description": "2-3 sentence detailed description of the 3D object",
   "objects": ["primary objects identified"],
   "colors": ["dominant colors across all views"],
@@ -220,30 +278,19 @@ Return as JSON with this structure:`, len(views), viewsJoined, len(views), views
   "three_d_characteristics": "describe topology, modeling style, material type",
   "features": ["at least 10 descriptive tags"],
   "symmetry": "symmetrical|asymmetrical",
-  "complexity": "simple|moderate|complex|highly-detailed"
+  "complexity": "simple|moderate|complex|highly-detailed",
+  "best_view": "` + strings.Join(views, "|") + ` (the one view that best represents the object as its thumbnail)"
 }
 
 IMPORTANT: Return ONLY valid JSON, no other text.`
 
-	model := c.client.GenerativeModel(c.model)
-	model.SetTemperature(0.4)
-
-	// Build parts array: prompt first, then all images
-	parts := []genai.Part{genai.Text(prompt)}
-	parts = append(parts, imageParts...)
-
-	resp, err := model.GenerateContent(ctx, parts...)
+	// Prompt first, then all views in order; large view sets go through
+	// the Files API
+	responseText, err := c.generateWithImages(ctx, c.analysisPrompt(prompt), 0.4, imagePaths)
 	if err != nil {
-		return nil, fmt.Errorf("gemini API error: %w", err)
+		return nil, err
 	}
 
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("empty response from Gemini")
-	}
-
-	// Extract text from response
-	responseText := fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])
-
 	// Clean markdown code fences if present
 	responseText = cleanMarkdownJSON(responseText)
 
@@ -252,6 +299,7 @@ IMPORTANT: Return ONLY valid JSON, no other text.`
 	if err := json.Unmarshal([]byte(responseText), &analysis); err != nil {
 		return nil, fmt.Errorf("failed to parse Gemini response: %w\nResponse: %s", err, responseText)
 	}
+	analysis.Extra = c.categoryExtra(analysis.PrimaryCategory, analysis.Extra)
 
 	return &analysis, nil
 }
@@ -299,3 +347,158 @@ IMPORTANT: Return ONLY valid JSON array, no other text.`, indexContent, query)
 
 	return responseText, nil
 }
+
+// SearchImagesBatch uses Gemini to search through an index for several
+// queries in one call. The response is a JSON array with the results of each
+// query, identified by its 1-based number.
+func (c *Client) SearchImagesBatch(ctx context.Context, indexContent string, queries []string) (string, error) {
+	var list strings.Builder
+	for i, query := range queries {
+		list.WriteString(fmt.Sprintf("%d. \"%s\"\n", i+1, query))
+	}
+
+	prompt := fmt.Sprintf(`Given the following image index and several numbered user search queries, find all relevant images for each query independently.
+
+Image Index:
+%s
+
+User Queries:
+%s
+Analyze the index and return a JSON array with one entry per query, each listing its matching image IDs ranked by relevance:
+[
+  {"query": 1, "results": [{"image_id": "uuid", "relevance_score": 0.95, "reason": "why it matches"}, ...]},
+  ...
+]
+
+Consider:
+- Semantic similarity (e.g., "dark cat" matches "black cat at night")
+- AI features and confidence scores
+- Manual tags
+- Scene type and mood
+- Object detection results
+
+IMPORTANT: Return ONLY valid JSON array, no other text. Include every query, with an empty results array if nothing matches.`, indexContent, list.String())
+
+	model := c.client.GenerativeModel(c.model)
+	model.SetTemperature(0.2)
+
+	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	if err != nil {
+		return "", fmt.Errorf("gemini API error: %w", err)
+	}
+
+	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("empty response from Gemini")
+	}
+
+	responseText := fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])
+
+	return cleanMarkdownJSON(responseText), nil
+}
+
+// SearchTurn is an earlier query of a search conversation and the JSON
+// results it was answered with
+type SearchTurn struct {
+	Query string
+	Reply string
+}
+
+// RefineSearch continues a search conversation in a Gemini chat. The earlier
+// turns are replayed as the chat history, so the refinement is answered
+// against the given index excerpt with the earlier queries still in effect.
+func (c *Client) RefineSearch(ctx context.Context, indexContent string, history []SearchTurn, query string) (string, error) {
+	model := c.client.GenerativeModel(c.model)
+	model.SetTemperature(0.2)
+
+	chat := model.StartChat()
+	for i, turn := range history {
+		ask := fmt.Sprintf("Refine the search: \"%s\"", turn.Query)
+		if i == 0 {
+			ask = fmt.Sprintf("Search the image index for: \"%s\"", turn.Query)
+		}
+		chat.History = append(chat.History,
+			&genai.Content{Role: "user", Parts: []genai.Part{genai.Text(ask)}},
+			&genai.Content{Role: "model", Parts: []genai.Part{genai.Text(turn.Reply)}},
+		)
+	}
+
+	prompt := fmt.Sprintf(`The user is narrowing down an image search. The earlier turns of this conversation hold their previous queries and the images you found for them.
+
+Image Index:
+%s
+
+Refinement: "%s"
+
+Apply the refinement on top of the earlier queries and constraints: "only the darker ones" keeps the earlier matches that are dark, "exclude AI-generated" drops images marked as AI-generated. Only start over if the refinement clearly asks for something else.
+
+Return a JSON array of matching image IDs ranked by relevance:
+[
+  {"image_id": "uuid", "relevance_score": 0.95, "reason": "why it matches"},
+  ...
+]
+
+IMPORTANT: Return ONLY valid JSON array, no other text.`, indexContent, query)
+
+	resp, err := chat.SendMessage(ctx, genai.Text(prompt))
+	if err != nil {
+		return "", fmt.Errorf("gemini API error: %w", err)
+	}
+
+	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("empty response from Gemini")
+	}
+
+	responseText := fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])
+
+	return cleanMarkdownJSON(responseText), nil
+}
+
+// Embed returns the embedding vector for a piece of text or an image. With
+// image embeddings enabled, everything uses the multimodal model, with the
+// text (if any) embedded alongside the image; otherwise the text embedding
+// model.
+func (c *Client) Embed(ctx context.Context, input EmbedInput) ([]float32, error) {
+	modelName := c.embeddingModel
+	if c.imageEmbedding != "" {
+		modelName = c.imageEmbedding
+	}
+	var parts []genai.Part
+
+	if input.ImagePath != "" {
+		if c.imageEmbedding == "" {
+			return nil, fmt.Errorf("image embeddings are not enabled")
+		}
+		imgData, err := os.ReadFile(input.ImagePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+		parts = append(parts, genai.ImageData(detectImageFormat(input.ImagePath), imgData))
+	}
+	if input.Text != "" {
+		parts = append(parts, genai.Text(input.Text))
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("nothing to embed")
+	}
+
+	vector, err := c.embedContent(ctx, modelName, parts...)
+	if err != nil {
+		return nil, fmt.Errorf("gemini API error: %w", err)
+	}
+	if len(vector) == 0 {
+		return nil, fmt.Errorf("empty embedding from Gemini")
+	}
+
+	return vector, nil
+}
+
+func (c *Client) genaiEmbedContent(ctx context.Context, model string, parts ...genai.Part) ([]float32, error) {
+	resp, err := c.client.EmbeddingModel(model).EmbedContent(ctx, parts...)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.Embedding == nil {
+		return nil, nil
+	}
+	return resp.Embedding.Values, nil
+}
@@ -0,0 +1,101 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// embedCall is one call of a fake embedding API
+type embedCall struct {
+	model string
+	parts []genai.Part
+}
+
+func fakeEmbedClient(calls *[]embedCall) *Client {
+	c := &Client{embeddingModel: DefaultEmbeddingModel}
+	c.embedContent = func(ctx context.Context, model string, parts ...genai.Part) ([]float32, error) {
+		*calls = append(*calls, embedCall{model: model, parts: parts})
+		return []float32{0.1, 0.2}, nil
+	}
+	return c
+}
+
+func TestEmbed(t *testing.T) {
+	var calls []embedCall
+	c := fakeEmbedClient(&calls)
+
+	vector, err := c.Embed(context.Background(), EmbedInput{Text: "red bicycle"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vector) != 2 || calls[0].model != DefaultEmbeddingModel || len(calls[0].parts) != 1 || calls[0].parts[0] != genai.Text("red bicycle") {
+		t.Errorf("unexpected call %+v -> %v", calls, vector)
+	}
+
+	c.SetEmbeddingModel("custom-embedding")
+	c.Embed(context.Background(), EmbedInput{Text: "blue car"})
+	if calls[1].model != "custom-embedding" {
+		t.Errorf("expected the overridden model, got %q", calls[1].model)
+	}
+
+	if _, err := c.Embed(context.Background(), EmbedInput{}); err == nil || len(calls) != 2 {
+		t.Error("expected empty input to be rejected without a call")
+	}
+	if _, err := c.Embed(context.Background(), EmbedInput{ImagePath: "cat.jpg"}); err == nil || len(calls) != 2 {
+		t.Error("expected an image to be rejected while image embeddings are off")
+	}
+}
+
+func TestEmbed_ImagesShareTheModelWithText(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "cat.png")
+	if err := os.WriteFile(imagePath, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var calls []embedCall
+	c := fakeEmbedClient(&calls)
+	c.SetMultimodalEmbeddingModel(DefaultMultimodalEmbeddingModel)
+
+	if _, err := c.Embed(context.Background(), EmbedInput{Text: "a cat", ImagePath: imagePath}); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if _, err := c.Embed(context.Background(), EmbedInput{Text: "cat"}); err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	for _, call := range calls {
+		if call.model != DefaultMultimodalEmbeddingModel {
+			t.Errorf("expected images and queries on the multimodal model, got %q", call.model)
+		}
+	}
+	if len(calls[0].parts) != 2 || calls[0].parts[1] != genai.Text("a cat") {
+		t.Errorf("expected the image and its text, got %+v", calls[0].parts)
+	}
+	if blob, ok := calls[0].parts[0].(genai.Blob); !ok || string(blob.Data) != "png" {
+		t.Errorf("expected the image data, got %+v", calls[0].parts[0])
+	}
+
+	if _, err := c.Embed(context.Background(), EmbedInput{ImagePath: filepath.Join(t.TempDir(), "missing.png")}); err == nil {
+		t.Error("expected an unreadable image to fail")
+	}
+}
+
+func TestEmbed_Errors(t *testing.T) {
+	c := &Client{embeddingModel: DefaultEmbeddingModel}
+	c.embedContent = func(ctx context.Context, model string, parts ...genai.Part) ([]float32, error) {
+		return nil, errors.New("quota exceeded")
+	}
+	if _, err := c.Embed(context.Background(), EmbedInput{Text: "x"}); err == nil {
+		t.Error("expected the API error")
+	}
+
+	c.embedContent = func(ctx context.Context, model string, parts ...genai.Part) ([]float32, error) {
+		return nil, nil
+	}
+	if _, err := c.Embed(context.Background(), EmbedInput{Text: "x"}); err == nil {
+		t.Error("expected an empty embedding error")
+	}
+}
@@ -0,0 +1,275 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+const (
+	// DefaultAPIBaseURL is the Gemini REST endpoint used for the Files API
+	DefaultAPIBaseURL = "https://generativelanguage.googleapis.com"
+
+	// DefaultInlineLimit is the largest total image payload sent inline.
+	// Requests are capped at 20MB including the prompt and base64 overhead,
+	// so anything bigger is uploaded through the Files API instead.
+	DefaultInlineLimit = 14 << 20
+
+	// filePollInterval is how often an uploaded file is checked until it is
+	// ready to be referenced
+	filePollInterval = time.Second
+)
+
+// mediaInput is an image sent along with a prompt
+type mediaInput struct {
+	path     string
+	mimeType string
+	data     []byte
+}
+
+// uploadedFile is a file stored with the Files API
+type uploadedFile struct {
+	Name     string `json:"name"` // files/<id>
+	URI      string `json:"uri"`
+	MIMEType string `json:"mimeType"`
+	State    string `json:"state"` // PROCESSING, ACTIVE, FAILED
+}
+
+// SetInlineLimit overrides the total image size above which images are
+// uploaded through the Files API instead of being sent inline
+func (c *Client) SetInlineLimit(limit int64) {
+	if limit > 0 {
+		c.inlineLimit = limit
+	}
+}
+
+// readMedia reads the images to send with a prompt
+func readMedia(paths []string) ([]mediaInput, int64, error) {
+	media := make([]mediaInput, 0, len(paths))
+	var total int64
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+		}
+		media = append(media, mediaInput{path: path, mimeType: "image/" + detectImageFormat(path), data: data})
+		total += int64(len(data))
+	}
+	return media, total, nil
+}
+
+// generateWithImages runs a prompt over one or more images and returns the
+// response text. Small payloads are sent inline; larger ones are uploaded
+// through the Files API and referenced by URI, then deleted.
+func (c *Client) generateWithImages(ctx context.Context, prompt string, temperature float32, paths []string) (string, error) {
+	media, total, err := readMedia(paths)
+	if err != nil {
+		return "", err
+	}
+
+	if total <= c.inlineLimit {
+		parts := []genai.Part{genai.Text(prompt)}
+		for _, m := range media {
+			parts = append(parts, genai.ImageData(strings.TrimPrefix(m.mimeType, "image/"), m.data))
+		}
+
+		model := c.client.GenerativeModel(c.model)
+		model.SetTemperature(temperature)
+
+		resp, err := model.GenerateContent(ctx, parts...)
+		if err != nil {
+			return "", fmt.Errorf("gemini API error: %w", err)
+		}
+		if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+			return "", fmt.Errorf("empty response from Gemini")
+		}
+		return fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0]), nil
+	}
+
+	files := make([]*uploadedFile, 0, len(media))
+	defer func() {
+		// Uploaded files expire on their own; deleting them is a courtesy
+		for _, f := range files {
+			c.deleteFile(context.Background(), f.Name)
+		}
+	}()
+	for _, m := range media {
+		f, err := c.uploadFile(ctx, m)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, f)
+	}
+
+	return c.generateWithFiles(ctx, prompt, temperature, files)
+}
+
+// uploadFile stores an image with the Files API using a resumable upload and
+// waits until it can be referenced
+func (c *Client) uploadFile(ctx context.Context, m mediaInput) (*uploadedFile, error) {
+	metadata, _ := json.Marshal(map[string]interface{}{
+		"file": map[string]string{"display_name": filepath.Base(m.path)},
+	})
+
+	start, err := c.newRequest(ctx, http.MethodPost, "/upload/v1beta/files", bytes.NewReader(metadata))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload request: %w", err)
+	}
+	start.Header.Set("Content-Type", "application/json")
+	start.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	start.Header.Set("X-Goog-Upload-Command", "start")
+	start.Header.Set("X-Goog-Upload-Header-Content-Length", strconv.Itoa(len(m.data)))
+	start.Header.Set("X-Goog-Upload-Header-Content-Type", m.mimeType)
+
+	resp, err := c.do(start, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start upload: %w", err)
+	}
+	uploadURL := resp.Header.Get("X-Goog-Upload-URL")
+	if uploadURL == "" {
+		return nil, fmt.Errorf("failed to start upload: no upload URL returned")
+	}
+
+	upload, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, bytes.NewReader(m.data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload request: %w", err)
+	}
+	upload.Header.Set("X-Goog-Upload-Command", "upload, finalize")
+	upload.Header.Set("X-Goog-Upload-Offset", "0")
+
+	var result struct {
+		File uploadedFile `json:"file"`
+	}
+	if _, err := c.do(upload, &result); err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", filepath.Base(m.path), err)
+	}
+
+	return c.waitForFile(ctx, &result.File)
+}
+
+// waitForFile polls an uploaded file until it leaves the PROCESSING state
+func (c *Client) waitForFile(ctx context.Context, f *uploadedFile) (*uploadedFile, error) {
+	for f.State == "PROCESSING" {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(filePollInterval):
+		}
+
+		req, err := c.newRequest(ctx, http.MethodGet, "/v1beta/"+f.Name, nil)
+		if err != nil {
+			return nil, err
+		}
+		var current uploadedFile
+		if _, err := c.do(req, &current); err != nil {
+			return nil, fmt.Errorf("failed to check uploaded file: %w", err)
+		}
+		f = &current
+	}
+
+	if f.State == "FAILED" {
+		return nil, fmt.Errorf("uploaded file %s failed processing", f.Name)
+	}
+	return f, nil
+}
+
+// deleteFile removes an uploaded file; failures are ignored
+func (c *Client) deleteFile(ctx context.Context, name string) {
+	req, err := c.newRequest(ctx, http.MethodDelete, "/v1beta/"+name, nil)
+	if err != nil {
+		return
+	}
+	c.do(req, nil)
+}
+
+// generateWithFiles calls generateContent over REST with file references;
+// the pinned genai client can only send inline data
+func (c *Client) generateWithFiles(ctx context.Context, prompt string, temperature float32, files []*uploadedFile) (string, error) {
+	type fileData struct {
+		MIMEType string `json:"mime_type"`
+		FileURI  string `json:"file_uri"`
+	}
+	type part struct {
+		Text     string    `json:"text,omitempty"`
+		FileData *fileData `json:"file_data,omitempty"`
+	}
+
+	parts := []part{{Text: prompt}}
+	for _, f := range files {
+		parts = append(parts, part{FileData: &fileData{MIMEType: f.MIMEType, FileURI: f.URI}})
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"contents":         []map[string]interface{}{{"role": "user", "parts": parts}},
+		"generationConfig": map[string]interface{}{"temperature": temperature},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	path := "/v1beta/models/" + strings.TrimPrefix(c.model, "models/") + ":generateContent"
+	req, err := c.newRequest(ctx, http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+	}
+	if _, err := c.do(req, &result); err != nil {
+		return "", fmt.Errorf("gemini API error: %w", err)
+	}
+	if len(result.Candidates) == 0 || len(result.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("empty response from Gemini")
+	}
+
+	return result.Candidates[0].Content.Parts[0].Text, nil
+}
+
+// newRequest creates a request for a REST path, authenticated with the API
+// key. The key goes in a header, never the URL: transport errors quote the
+// URL, and those end up in logs and job errors.
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.apiBaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-goog-api-key", c.apiKey)
+	return req, nil
+}
+
+// do sends a REST request and decodes a JSON response into out (if not nil)
+func (c *Client) do(req *http.Request, out interface{}) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,110 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGenerateWithImages_UsesFilesAPIAboveInlineLimit(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		uploaded []byte
+		deleted  []string
+		request  map[string]interface{}
+	)
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Header.Get("x-goog-api-key") != "k" && r.URL.Path != "/resumable" {
+			t.Errorf("missing API key on %s", r.URL.Path)
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/upload/v1beta/files":
+			if r.Header.Get("X-Goog-Upload-Header-Content-Type") != "image/png" {
+				t.Errorf("unexpected upload content type %q", r.Header.Get("X-Goog-Upload-Header-Content-Type"))
+			}
+			w.Header().Set("X-Goog-Upload-URL", srv.URL+"/resumable")
+		case r.Method == http.MethodPost && r.URL.Path == "/resumable":
+			uploaded, _ = io.ReadAll(r.Body)
+			w.Write([]byte(`{"file":{"name":"files/abc","uri":"https://files/abc","mimeType":"image/png","state":"ACTIVE"}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/v1beta/models/m:generateContent":
+			json.NewDecoder(r.Body).Decode(&request)
+			w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"{\"ok\":true}"}]}}]}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/v1beta/files/abc":
+			deleted = append(deleted, "files/abc")
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "big.png")
+	if err := os.WriteFile(path, []byte("not really a png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Client{apiKey: "k", model: "m", apiBaseURL: srv.URL, httpClient: srv.Client(), inlineLimit: 1}
+	text, err := c.generateWithImages(context.Background(), "describe", 0.4, []string{path})
+	if err != nil {
+		t.Fatalf("generateWithImages failed: %v", err)
+	}
+	if text != `{"ok":true}` {
+		t.Errorf("unexpected response %q", text)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if string(uploaded) != "not really a png" {
+		t.Errorf("unexpected uploaded body %q", uploaded)
+	}
+	encoded, _ := json.Marshal(request)
+	if !strings.Contains(string(encoded), `"file_uri":"https://files/abc"`) {
+		t.Errorf("expected the file URI to be referenced, got %s", encoded)
+	}
+	if len(deleted) != 1 {
+		t.Errorf("expected the uploaded file to be deleted, got %v", deleted)
+	}
+}
+
+func TestWaitForFile_Failed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"files/x","state":"FAILED"}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{apiKey: "k", apiBaseURL: srv.URL, httpClient: srv.Client()}
+	if _, err := c.waitForFile(context.Background(), &uploadedFile{Name: "files/x", State: "PROCESSING"}); err == nil {
+		t.Error("expected an error for a file that failed processing")
+	}
+}
+
+func TestFilesAPI_TransportErrorHidesKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close() // every request fails to connect
+
+	c := &Client{apiKey: "secret-key", model: "m", apiBaseURL: srv.URL, httpClient: srv.Client()}
+	_, err := c.uploadFile(context.Background(), mediaInput{path: "a.png", mimeType: "image/png", data: []byte("x")})
+	if err == nil {
+		t.Fatal("expected a transport error")
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("API key leaked into the error: %v", err)
+	}
+
+	_, err = c.generateWithFiles(context.Background(), "describe", 0.4, nil)
+	if err == nil || strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("expected a transport error without the API key, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package gemini
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// prompt3DRevision versions the 3D analysis prompt template of
+// AnalyzeImage3D. Bump it when the template changes, so analyses cached
+// with the old one are not reused.
+const prompt3DRevision = 1
+
+// Model returns the model used for analysis and search
+func (c *Client) Model() string {
+	return c.model
+}
+
+// PromptVersion2D identifies the 2D analysis prompt in effect, category
+// prompts and aesthetic rating included: a short hash that changes whenever the prompt does
+func (c *Client) PromptVersion2D() string {
+	return promptHash(c.analysisPrompt(c.prompt2D))
+}
+
+// PromptVersion3D identifies the 3D analysis prompt in effect. That prompt
+// is built per object from its views, so this hashes the template revision
+// and the optional instructions.
+func (c *Client) PromptVersion3D() string {
+	return promptHash(fmt.Sprintf("3d/%d\n%s", prompt3DRevision, c.analysisPrompt(returnJSONOnly)))
+}
+
+func promptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:6])
+}